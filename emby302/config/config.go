@@ -16,6 +16,10 @@ type Config struct {
 	Openlist *Openlist `yaml:"openlist"`
 	// VideoPreview 网盘转码链接代理配置
 	VideoPreview *VideoPreview `yaml:"video-preview"`
+	// PlaybackPolicy 按客户端匹配的播放策略
+	PlaybackPolicy *PlaybackPolicy `yaml:"playback-policy"`
+	// StreamLimit Emby 用户的最大同时播放数限制
+	StreamLimit *StreamLimit `yaml:"stream-limit"`
 	// Path 路径相关配置
 	Path *Path `yaml:"path"`
 	// Cache 缓存相关配置
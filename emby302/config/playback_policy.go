@@ -0,0 +1,206 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PolicyAction 播放策略动作
+type PolicyAction string
+
+const (
+	PolicyDirect    PolicyAction = "direct"    // 302 重定向到网盘直链
+	PolicyProxy     PolicyAction = "proxy"     // 通过 QMediaSync 本地分块代理播放
+	PolicyTranscode PolicyAction = "transcode" // 只返回转码资源, 优先使用 openlist 转码, 否则交给 Emby 转码
+	PolicyReject    PolicyAction = "reject"    // 拒绝播放
+)
+
+// validPolicyAction 用于校验用户配置的播放策略动作是否合法
+var validPolicyAction = map[PolicyAction]struct{}{
+	PolicyDirect: {}, PolicyProxy: {}, PolicyTranscode: {}, PolicyReject: {},
+}
+
+// PlaybackPolicy 按客户端匹配的播放策略
+//
+// 规则自上而下匹配, 第一个命中的规则生效, 没有命中时使用默认动作
+type PlaybackPolicy struct {
+	// Default 没有命中规则时的动作, 默认 direct
+	Default PolicyAction `yaml:"default"`
+	// Rules 播放策略规则
+	Rules []*PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule 播放策略规则, 所有配置了的条件都满足时命中, 不配置的条件不做限制
+type PolicyRule struct {
+	// Name 规则名称, 用于日志
+	Name string `yaml:"name"`
+	// Users Emby 用户名或者用户 Id
+	Users []string `yaml:"users"`
+	// Clients 客户端名称或设备名称, 不区分大小写, 支持 * 通配符
+	Clients []string `yaml:"clients"`
+	// Ips 客户端 IP 或 CIDR
+	Ips []string `yaml:"ips"`
+	// ExcludeIps 排除的客户端 IP 或 CIDR
+	ExcludeIps []string `yaml:"exclude-ips"`
+	// Libraries 媒体库名称
+	Libraries []string `yaml:"libraries"`
+	// MinSizeMb 文件大小下限, 单位: MB
+	MinSizeMb int64 `yaml:"min-size-mb"`
+	// MaxSizeMb 文件大小上限, 单位: MB
+	MaxSizeMb int64 `yaml:"max-size-mb"`
+	// MinBitrate 码率下限, 单位: Mbps
+	MinBitrate float64 `yaml:"min-bitrate"`
+	// MaxBitrate 码率上限, 单位: Mbps
+	MaxBitrate float64 `yaml:"max-bitrate"`
+	// Action 命中后的动作
+	Action PolicyAction `yaml:"action"`
+
+	// clientRegs 根据 Clients 初始化的正则
+	clientRegs []*regexp.Regexp
+	// ipNets 根据 Ips 初始化的网段
+	ipNets []*net.IPNet
+	// excludeIpNets 根据 ExcludeIps 初始化的网段
+	excludeIpNets []*net.IPNet
+}
+
+// PolicyTarget 匹配播放策略的客户端和媒体信息
+type PolicyTarget struct {
+	UserId    string   // Emby 用户 Id
+	UserName  string   // Emby 用户名
+	Client    string   // 客户端名称
+	Device    string   // 设备名称
+	Ip        string   // 客户端 IP
+	Libraries []string // 媒体所在的媒体库
+	Size      int64    // 文件大小, 单位: 字节, 0 表示未知
+	Bitrate   int64    // 码率, 单位: bps, 0 表示未知
+}
+
+func (pp *PlaybackPolicy) Init() error {
+	pp.Default = PolicyAction(strings.TrimSpace(string(pp.Default)))
+	if pp.Default == "" {
+		// 默认保持原有的直链播放
+		pp.Default = PolicyDirect
+	}
+	if _, ok := validPolicyAction[pp.Default]; !ok {
+		return fmt.Errorf("playback-policy.default 配置错误: %s, 有效值: direct, proxy, transcode, reject", pp.Default)
+	}
+	for i, rule := range pp.Rules {
+		if rule == nil {
+			return fmt.Errorf("playback-policy.rules[%d] 配置不能为空", i)
+		}
+		if err := rule.init(); err != nil {
+			return fmt.Errorf("playback-policy.rules[%d] 配置错误: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *PolicyRule) init() error {
+	r.Action = PolicyAction(strings.TrimSpace(string(r.Action)))
+	if _, ok := validPolicyAction[r.Action]; !ok {
+		return fmt.Errorf("action 配置错误: %s, 有效值: direct, proxy, transcode, reject", r.Action)
+	}
+	if r.Name == "" {
+		r.Name = string(r.Action)
+	}
+	r.clientRegs = make([]*regexp.Regexp, 0, len(r.Clients))
+	for _, client := range r.Clients {
+		pattern := strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSpace(client)), `\*`, ".*")
+		r.clientRegs = append(r.clientRegs, regexp.MustCompile("(?i)^"+pattern+"$"))
+	}
+	var err error
+	if r.ipNets, err = parseIpNets(r.Ips); err != nil {
+		return fmt.Errorf("ips %v", err)
+	}
+	if r.excludeIpNets, err = parseIpNets(r.ExcludeIps); err != nil {
+		return fmt.Errorf("exclude-ips %v", err)
+	}
+	return nil
+}
+
+// parseIpNets 解析 IP 或 CIDR, 单个 IP 转换成只包含自身的网段
+func parseIpNets(ips []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(ips))
+	for _, ip := range ips {
+		ip = strings.TrimSpace(ip)
+		if !strings.Contains(ip, "/") {
+			parsed := net.ParseIP(ip)
+			if parsed == nil {
+				return nil, fmt.Errorf("不是有效的 IP: %s", ip)
+			}
+			bits := 128
+			if parsed.To4() != nil {
+				bits = 32
+			}
+			ip = fmt.Sprintf("%s/%d", ip, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return nil, fmt.Errorf("不是有效的 CIDR: %s", ip)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// NeedLibraries 是否有规则按媒体库匹配, 没有时不需要查询媒体所在的媒体库
+func (pp *PlaybackPolicy) NeedLibraries() bool {
+	return slices.ContainsFunc(pp.Rules, func(r *PolicyRule) bool { return len(r.Libraries) > 0 })
+}
+
+// Match 返回第一个命中的规则和动作, 没有命中时规则为 nil
+func (pp *PlaybackPolicy) Match(target PolicyTarget) (*PolicyRule, PolicyAction) {
+	for _, rule := range pp.Rules {
+		if rule.Match(target) {
+			return rule, rule.Action
+		}
+	}
+	return nil, pp.Default
+}
+
+// Match 判断规则是否命中
+func (r *PolicyRule) Match(target PolicyTarget) bool {
+	if len(r.Users) > 0 && !slices.ContainsFunc(r.Users, func(u string) bool {
+		return u == target.UserId || strings.EqualFold(u, target.UserName)
+	}) {
+		return false
+	}
+	if len(r.clientRegs) > 0 && !slices.ContainsFunc(r.clientRegs, func(reg *regexp.Regexp) bool {
+		return reg.MatchString(target.Client) || reg.MatchString(target.Device)
+	}) {
+		return false
+	}
+	ip := net.ParseIP(target.Ip)
+	if len(r.ipNets) > 0 && (ip == nil || !containsIp(r.ipNets, ip)) {
+		return false
+	}
+	if ip != nil && containsIp(r.excludeIpNets, ip) {
+		return false
+	}
+	if len(r.Libraries) > 0 && !slices.ContainsFunc(r.Libraries, func(l string) bool {
+		return slices.Contains(target.Libraries, l)
+	}) {
+		return false
+	}
+	// 大小和码率未知时, 下限条件不命中, 上限条件命中
+	if r.MinSizeMb > 0 && target.Size < r.MinSizeMb*1024*1024 {
+		return false
+	}
+	if r.MaxSizeMb > 0 && target.Size > r.MaxSizeMb*1024*1024 {
+		return false
+	}
+	if r.MinBitrate > 0 && float64(target.Bitrate) < r.MinBitrate*1000*1000 {
+		return false
+	}
+	if r.MaxBitrate > 0 && float64(target.Bitrate) > r.MaxBitrate*1000*1000 {
+		return false
+	}
+	return true
+}
+
+func containsIp(nets []*net.IPNet, ip net.IP) bool {
+	return slices.ContainsFunc(nets, func(n *net.IPNet) bool { return n.Contains(ip) })
+}
@@ -0,0 +1,52 @@
+package config
+
+import "testing"
+
+func TestPlaybackPolicyMatch(t *testing.T) {
+	pp := &PlaybackPolicy{Rules: []*PolicyRule{
+		{Name: "拉黑", Users: []string{"guest"}, Action: PolicyReject},
+		{Name: "外网原画", Ips: []string{"0.0.0.0/0"}, ExcludeIps: []string{"192.168.0.0/16", "127.0.0.1"}, MinBitrate: 20, Action: PolicyTranscode},
+		{Name: "电视", Clients: []string{"*android tv*"}, Libraries: []string{"电影"}, MaxSizeMb: 10240, Action: PolicyProxy},
+	}}
+	if err := pp.Init(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		target PolicyTarget
+		want   PolicyAction
+	}{
+		{"用户名不区分大小写", PolicyTarget{UserName: "Guest", Ip: "192.168.1.2"}, PolicyReject},
+		{"外网高码率", PolicyTarget{Ip: "8.8.8.8", Bitrate: 80 * 1000 * 1000}, PolicyTranscode},
+		{"外网低码率", PolicyTarget{Ip: "8.8.8.8", Bitrate: 8 * 1000 * 1000}, PolicyDirect},
+		{"码率未知", PolicyTarget{Ip: "8.8.8.8"}, PolicyDirect},
+		{"内网高码率", PolicyTarget{Ip: "192.168.1.2", Bitrate: 80 * 1000 * 1000}, PolicyDirect},
+		{"排除单个IP", PolicyTarget{Ip: "127.0.0.1", Bitrate: 80 * 1000 * 1000}, PolicyDirect},
+		{"设备名通配", PolicyTarget{Client: "Emby", Device: "Xiaomi Android TV", Libraries: []string{"电影"}, Size: 1024}, PolicyProxy},
+		{"媒体库不匹配", PolicyTarget{Client: "Android TV", Libraries: []string{"电视剧"}}, PolicyDirect},
+		{"超过大小上限", PolicyTarget{Client: "Android TV", Libraries: []string{"电影"}, Size: 20 * 1024 * 1024 * 1024}, PolicyDirect},
+	}
+	for _, tt := range tests {
+		if _, got := pp.Match(tt.target); got != tt.want {
+			t.Errorf("%s: Match() = %s; want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlaybackPolicyInit(t *testing.T) {
+	invalid := []*PlaybackPolicy{
+		{Default: "redirect"},
+		{Rules: []*PolicyRule{{Action: "allow"}}},
+		{Rules: []*PolicyRule{{Action: PolicyProxy, Ips: []string{"192.168.1"}}}},
+		{Rules: []*PolicyRule{{Action: PolicyProxy, ExcludeIps: []string{"10.0.0.0/33"}}}},
+	}
+	for i, pp := range invalid {
+		if err := pp.Init(); err == nil {
+			t.Errorf("invalid[%d].Init() = nil; want error", i)
+		}
+	}
+	pp := &PlaybackPolicy{}
+	if err := pp.Init(); err != nil || pp.Default != PolicyDirect {
+		t.Errorf("empty policy: default = %s, err = %v", pp.Default, err)
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import "fmt"
+
+// StreamLimit Emby 用户的最大同时播放数限制
+//
+// 网盘账号的最大同时播放数在 QMediaSync 的账号管理中设置
+type StreamLimit struct {
+	// MaxPerUser 每个 Emby 用户的最大同时播放数, 0 表示不限制
+	MaxPerUser int `yaml:"max-per-user"`
+	// Users 单独设置的用户最大同时播放数, key 为 Emby 用户名或者用户 Id, 0 表示不限制
+	Users map[string]int `yaml:"users"`
+}
+
+func (sl *StreamLimit) Init() error {
+	if sl.MaxPerUser < 0 {
+		return fmt.Errorf("stream-limit.max-per-user 配置错误: %d, 不能小于 0", sl.MaxPerUser)
+	}
+	for user, limit := range sl.Users {
+		if limit < 0 {
+			return fmt.Errorf("stream-limit.users.%s 配置错误: %d, 不能小于 0", user, limit)
+		}
+	}
+	return nil
+}
+
+// UserLimit 获取用户的最大同时播放数, 优先使用单独设置的限制
+func (sl *StreamLimit) UserLimit(userId, userName string) int {
+	if limit, ok := sl.Users[userId]; ok && userId != "" {
+		return limit
+	}
+	if limit, ok := sl.Users[userName]; ok && userName != "" {
+		return limit
+	}
+	return sl.MaxPerUser
+}
@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestStreamLimitUserLimit(t *testing.T) {
+	sl := &StreamLimit{MaxPerUser: 2, Users: map[string]int{"guest": 1, "admin-id": 0}}
+	if err := sl.Init(); err != nil {
+		t.Fatalf("Init() = %v", err)
+	}
+	cases := []struct {
+		userId, userName string
+		want             int
+	}{
+		{"guest-id", "guest", 1},
+		{"admin-id", "admin", 0},
+		{"other-id", "other", 2},
+		{"", "", 2},
+	}
+	for _, tc := range cases {
+		if got := sl.UserLimit(tc.userId, tc.userName); got != tc.want {
+			t.Errorf("UserLimit(%q, %q) = %d; want %d", tc.userId, tc.userName, got, tc.want)
+		}
+	}
+	if err := (&StreamLimit{MaxPerUser: -1}).Init(); err == nil {
+		t.Error("negative max-per-user should fail")
+	}
+}
@@ -29,20 +29,42 @@ import (
 // MediaSourceIdSegment 自定义 MediaSourceId 的分隔符
 const MediaSourceIdSegment = "[[_]]"
 
-// getEmbyFileLocalPath 获取 Emby 指定媒体的 Path 参数
-//
-// uri 中必须有 query 参数 MediaSourceId,
-// 如果没有携带该参数, 可能会请求到多个媒体, 默认返回第一个媒体的本地路径
-func getEmbyFileLocalPath(itemInfo ItemInfo) (string, error) {
-	var header http.Header
+// embyMediaSource Emby 媒体的 MediaSource 信息
+type embyMediaSource struct {
+	Path    string
+	Id      string
+	Size    int64
+	Bitrate int64
+}
+
+// apiKeyHeader 将客户端的 api key 转换成请求 Emby 接口的请求头
+func apiKeyHeader(itemInfo ItemInfo) http.Header {
 	switch itemInfo.ApiKeyType {
 	case Header:
 		// 带上请求头的 api key
-		header = http.Header{itemInfo.ApiKeyName: []string{itemInfo.ApiKey}}
+		return http.Header{itemInfo.ApiKeyName: []string{itemInfo.ApiKey}}
 	case Query:
 		// 如果是 query 格式的 api key, 则往请求头中补充信息
-		header = http.Header{HeaderFullAuthName: []string{"Token=" + itemInfo.ApiKey}}
+		return http.Header{HeaderFullAuthName: []string{"Token=" + itemInfo.ApiKey}}
+	}
+	return nil
+}
+
+// getEmbyFileLocalPath 获取 Emby 指定媒体的 Path 参数
+//
+// uri 中必须有 query 参数 MediaSourceId,
+// 如果没有携带该参数, 可能会请求到多个媒体, 默认返回第一个媒体的本地路径
+func getEmbyFileLocalPath(itemInfo ItemInfo) (string, error) {
+	ms, err := getEmbyMediaSource(itemInfo)
+	if err != nil {
+		return "", err
 	}
+	return ms.Path, nil
+}
+
+// getEmbyMediaSource 获取 Emby 指定媒体的 MediaSource 信息, 规则同 getEmbyFileLocalPath
+func getEmbyMediaSource(itemInfo ItemInfo) (embyMediaSource, error) {
+	header := apiKeyHeader(itemInfo)
 
 	innerRequest := func(method string) (*http.Response, error) {
 		resp, err := https.Request(method, config.C.Emby.Host+itemInfo.PlaybackInfoUri).Header(header).Do()
@@ -66,64 +88,62 @@ func getEmbyFileLocalPath(itemInfo ItemInfo) (string, error) {
 	if err != nil {
 		resp, err = innerRequest(http.MethodGet)
 		if err != nil {
-			return "", err
+			return embyMediaSource{}, err
 		}
 	}
 	defer resp.Body.Close()
 
 	type MediaSourcesHolder struct {
-		MediaSources []struct {
-			Path string
-			Id   string
-		}
+		MediaSources []embyMediaSource
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取 Emby 响应异常, error: %v", err)
+		return embyMediaSource{}, fmt.Errorf("读取 Emby 响应异常, error: %v", err)
 	}
 	var holder MediaSourcesHolder
 	if err = json.Unmarshal(bodyBytes, &holder); err != nil {
-		return "", fmt.Errorf("解析 Emby 响应异常, error: %v, 原始响应: %s", err, string(bodyBytes))
+		return embyMediaSource{}, fmt.Errorf("解析 Emby 响应异常, error: %v, 原始响应: %s", err, string(bodyBytes))
 	}
 
 	if len(holder.MediaSources) == 0 {
-		return "", fmt.Errorf("获取不到 MediaSources, 原始响应: %v", string(bodyBytes))
+		return embyMediaSource{}, fmt.Errorf("获取不到 MediaSources, 原始响应: %v", string(bodyBytes))
 	}
 
-	var path string
-	var defaultPath string
+	var ms, defaultMs embyMediaSource
 
 	reqId := itemInfo.MsInfo.OriginId
 	// 获取指定 MediaSourceId 的 Path
 	for _, value := range holder.MediaSources {
-		if strs.AnyEmpty(defaultPath) {
+		if strs.AnyEmpty(defaultMs.Path) {
 			// 默认选择第一个路径
-			defaultPath = value.Path
+			defaultMs = value
 		}
 		if itemInfo.MsInfo.Empty {
 			// 如果没有传递 MediaSourceId, 就使用默认的 Path
 			break
 		}
 		if value.Id == reqId {
-			path = value.Path
+			ms = value
 			break
 		}
 	}
 
-	if strs.AllNotEmpty(path) {
-		return path, nil
+	if strs.AllNotEmpty(ms.Path) {
+		return ms, nil
 	}
-	if strs.AllNotEmpty(defaultPath) {
-		return defaultPath, nil
+	if strs.AllNotEmpty(defaultMs.Path) {
+		return defaultMs, nil
 	}
-	return "", fmt.Errorf("获取不到 Path 参数, 原始响应: %v", string(bodyBytes))
+	return embyMediaSource{}, fmt.Errorf("获取不到 Path 参数, 原始响应: %v", string(bodyBytes))
 }
 
 // findVideoPreviewInfos 查找 source 的所有转码资源
 //
 // 传递 resChan 进行异步查询, 通过监听 resChan 获取查询结果
-func findVideoPreviewInfos(source *jsons.Item, clientApiKey string, resChan chan []*jsons.Item) {
+//
+// force 为 true 时忽略 video-preview 的开关和容器配置, 用于播放策略要求转码的场景
+func findVideoPreviewInfos(source *jsons.Item, clientApiKey string, force bool, resChan chan []*jsons.Item) {
 	if resChan == nil {
 		return
 	}
@@ -137,7 +157,7 @@ func findVideoPreviewInfos(source *jsons.Item, clientApiKey string, resChan chan
 	// 未启用配置
 	cfg := config.C.VideoPreview
 	srcContainer, _ := source.Attr("Container").String()
-	if !cfg.Enable || !cfg.ContainerValid(srcContainer) {
+	if !force && (!cfg.Enable || !cfg.ContainerValid(srcContainer)) {
 		resChan <- nil
 		return
 	}
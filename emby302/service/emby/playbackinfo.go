@@ -87,6 +87,43 @@ func TransferPlaybackInfo(c *gin.Context) {
 		return
 	}
 
+	// 按播放策略过滤 MediaSources, 移除拒绝播放的资源
+	target := newPolicyTarget(c, itemInfo)
+	sourceActions := make(map[*jsons.Item]config.PolicyAction)
+	mediaSources = mediaSources.Filter(func(source *jsons.Item) bool {
+		size, _ := source.Attr("Size").Int64()
+		bitrate, _ := source.Attr("Bitrate").Int64()
+		sourceActions[source] = matchPlaybackPolicy(target, size, bitrate)
+		return sourceActions[source] != config.PolicyReject
+	})
+	if mediaSources.Empty() {
+		rejectPlayback(c, "播放策略禁止播放该媒体")
+		return
+	}
+
+	// 按同时播放数限制过滤 MediaSources, 全部超过限制时返回 Emby 的播放次数超限错误
+	var limitErr error
+	mediaSources = mediaSources.Filter(func(source *jsons.Item) bool {
+		strmUrl, _ := source.Attr("Path").String()
+		if err := checkPlaybackLimit(c, target, itemInfo.Id, urls.Unescape(strmUrl)); err != nil {
+			limitErr = err
+			return false
+		}
+		return true
+	})
+	if mediaSources.Empty() {
+		playbackRateLimitExceeded(c, resJson, limitErr)
+		return
+	}
+	resJson.Put("MediaSources", mediaSources)
+
+	// transcodeSource 播放策略要求转码的资源, 获取到转码资源后移除原画
+	type transcodeSource struct {
+		source  *jsons.Item
+		resChan chan []*jsons.Item
+	}
+	transcodeSources := make([]transcodeSource, 0)
+
 	var haveReturned = errors.New("have returned")
 	resChans := make([]chan []*jsons.Item, 0, mediaSources.Len())
 	err = mediaSources.RangeArr(func(_ int, source *jsons.Item) error {
@@ -123,6 +160,20 @@ func TransferPlaybackInfo(c *gin.Context) {
 			source.Attr("Path").Set(urls.Unescape(path))
 		}
 		// logs.Info("Path 解码后: %s", path)
+
+		// 播放策略要求转码, 不返回原画直链, 优先使用 openlist 转码资源, 获取不到时交给 Emby 转码
+		if sourceActions[source] == config.PolicyTranscode && !msInfo.Transcode {
+			source.Put("SupportsDirectPlay", jsons.FromValue(false))
+			source.Put("SupportsDirectStream", jsons.FromValue(false))
+			source.DelKey("DirectStreamUrl")
+			if msInfo.Empty {
+				resChan := make(chan []*jsons.Item, 1)
+				go findVideoPreviewInfos(source, itemInfo.ApiKey, true, resChan)
+				transcodeSources = append(transcodeSources, transcodeSource{source, resChan})
+			}
+			return nil
+		}
+
 		// 转换直链链接
 		source.Put("SupportsDirectPlay", jsons.FromValue(true))
 		source.Put("SupportsDirectStream", jsons.FromValue(true))
@@ -164,7 +215,7 @@ func TransferPlaybackInfo(c *gin.Context) {
 			return nil
 		}
 		resChan := make(chan []*jsons.Item, 1)
-		go findVideoPreviewInfos(source, itemInfo.ApiKey, resChan)
+		go findVideoPreviewInfos(source, itemInfo.ApiKey, false, resChan)
 		resChans = append(resChans, resChan)
 		return nil
 	})
@@ -188,6 +239,15 @@ func TransferPlaybackInfo(c *gin.Context) {
 			mediaSources.Append(previewInfos...)
 		}
 	}
+	for _, ts := range transcodeSources {
+		previewInfos := <-ts.resChan
+		if len(previewInfos) == 0 {
+			logs.Warn("获取不到 openlist 转码资源, 交给 Emby 转码: %s", ts.source.Attr("Path").Val())
+			continue
+		}
+		mediaSources.DelIdx(mediaSources.FindIdx(func(val *jsons.Item) bool { return val == ts.source }))
+		mediaSources.Append(previewInfos...)
+	}
 
 	https.CloneHeader(c.Writer, respHeader)
 	jsons.OkResp(c.Writer, resJson)
@@ -32,6 +32,7 @@ func PlayingStoppedHelper(c *gin.Context) {
 
 	// 代理原始 Stopped 接口
 	ProxyOrigin(c)
+	stopPlaySession(c)
 
 	// 提取 api apiKey
 	kType, kName, apiKey := getApiKey(c)
@@ -71,6 +72,13 @@ func PlayingProgressHelper(c *gin.Context) {
 		return
 	}
 
+	// 续期播放会话, 用于统计同时播放数
+	itemId, _ := bodyJson.Attr("ItemId").String()
+	if itemIdNum, ok := bodyJson.Attr("ItemId").Int(); ok {
+		itemId = strconv.Itoa(itemIdNum)
+	}
+	touchPlaySession(c, itemId)
+
 	if pt, ok := bodyJson.Attr("PositionTicks").Int64(); ok && pt <= 10_000_000 {
 		c.Status(http.StatusNoContent)
 		return
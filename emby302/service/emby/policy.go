@@ -0,0 +1,177 @@
+package emby
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"Q115-STRM/emby302/config"
+	"Q115-STRM/emby302/util/jsons"
+	"Q115-STRM/emby302/util/logs"
+	"Q115-STRM/emby302/util/maps"
+	"Q115-STRM/emby302/web/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// AuthorizationClientExtractReg 匹配 Authorization 头中 Client 字段
+	AuthorizationClientExtractReg = regexp.MustCompile(`(?i)\bclient="([^"]+)"`)
+	// AuthorizationDeviceExtractReg 匹配 Authorization 头中 Device 字段
+	AuthorizationDeviceExtractReg = regexp.MustCompile(`(?i)\bdevice="([^"]+)"`)
+
+	// localWinPathReg 匹配 windows 盘符开头的路径
+	localWinPathReg = regexp.MustCompile(`^[A-Za-z]:`)
+)
+
+var (
+	// tokenUsers 客户端 api_key 所属的 Emby 用户, 通过 Emby 接口查询
+	//
+	// 不使用客户端传递的 UserId 参数, 防止冒充其他用户绕过播放策略和同时播放数限制
+	tokenUsers = maps.NewTTL[string, embyUser](10*time.Minute, 1000)
+
+	// itemLibraries item 所在的媒体库名称, 过期后重新查询, 媒体移动到其他媒体库后可以更新
+	itemLibraries = maps.NewTTL[string, []string](10*time.Minute, 10000)
+)
+
+// embyUser api_key 所属的 Emby 用户, 查询不到时 Id 为空
+type embyUser struct {
+	Id   string
+	Name string
+}
+
+// newPolicyTarget 解析请求的客户端信息, 用于匹配播放策略
+//
+// 媒体大小和码率由调用方根据 MediaSource 补充
+func newPolicyTarget(c *gin.Context, itemInfo ItemInfo) config.PolicyTarget {
+	target := config.PolicyTarget{Ip: c.ClientIP()}
+	target.Client, target.Device = getClientInfo(c)
+	user := getTokenUser(c, itemInfo)
+	target.UserId, target.UserName = user.Id, user.Name
+	if config.C.PlaybackPolicy.NeedLibraries() {
+		target.Libraries = getItemLibraries(itemInfo)
+	}
+	return target
+}
+
+// rejectPlayback 播放策略拒绝播放, 返回 403
+func rejectPlayback(c *gin.Context, msg string) {
+	logs.Warn("%s, uri: %s", msg, c.Request.RequestURI)
+	c.Header(cache.HeaderKeyExpired, "-1")
+	c.String(http.StatusForbidden, msg)
+}
+
+// isLocalMediaPath 判断是否是 Emby 本地媒体路径, 以 / 或者 windows 盘符开头
+func isLocalMediaPath(embyPath string) bool {
+	return strings.HasPrefix(embyPath, "/") || localWinPathReg.MatchString(embyPath)
+}
+
+// getClientInfo 获取客户端名称和设备名称, 获取不到客户端名称时使用 User-Agent
+func getClientInfo(c *gin.Context) (client, device string) {
+	client = c.Query("X-Emby-Client")
+	if client == "" {
+		client = c.GetHeader("X-Emby-Client")
+	}
+	device = c.Query("X-Emby-Device-Name")
+	if device == "" {
+		device = c.GetHeader("X-Emby-Device-Name")
+	}
+
+	auth := c.GetHeader(HeaderFullAuthName)
+	if auth == "" {
+		auth = c.GetHeader(HeaderAuthName)
+	}
+	if matches := AuthorizationClientExtractReg.FindStringSubmatch(auth); client == "" && len(matches) > 1 {
+		client = matches[1]
+	}
+	if matches := AuthorizationDeviceExtractReg.FindStringSubmatch(auth); device == "" && len(matches) > 1 {
+		device = matches[1]
+	}
+
+	if client == "" {
+		client = c.Request.UserAgent()
+	}
+	return
+}
+
+// getTokenUser 获取 api_key 所属的 Emby 用户, 结果会被缓存
+func getTokenUser(c *gin.Context, itemInfo ItemInfo) embyUser {
+	if itemInfo.ApiKey == "" {
+		return embyUser{}
+	}
+	if user, ok := tokenUsers.Load(itemInfo.ApiKey); ok {
+		return user
+	}
+	user := fetchTokenUser(c, itemInfo)
+	tokenUsers.Store(itemInfo.ApiKey, user)
+	return user
+}
+
+// fetchTokenUser 请求 Emby 查询 api_key 所属的用户
+//
+// 优先使用 /Users/Me, 不支持时通过当前设备的会话查询
+func fetchTokenUser(c *gin.Context, itemInfo ItemInfo) embyUser {
+	header := apiKeyHeader(itemInfo)
+	res, _ := Fetch("/Users/Me", http.MethodGet, header, nil)
+	if res.Code == http.StatusOK && res.Data.Type() == jsons.JsonTypeObj {
+		if id, _ := res.Data.Attr("Id").String(); id != "" {
+			name, _ := res.Data.Attr("Name").String()
+			return embyUser{Id: id, Name: name}
+		}
+	}
+
+	deviceId := getDeviceId(c)
+	if deviceId == "" {
+		return embyUser{}
+	}
+	res, _ = Fetch("/Sessions?DeviceId="+url.QueryEscape(deviceId), http.MethodGet, header, nil)
+	if res.Code != http.StatusOK || res.Data.Type() != jsons.JsonTypeArr {
+		logs.Warn("获取 Emby 用户信息失败, deviceId: %s, err: %s", deviceId, res.Msg)
+		return embyUser{}
+	}
+	for _, session := range res.Data.ValuesArr() {
+		if id, _ := session.Attr("DeviceId").String(); id != deviceId {
+			continue
+		}
+		id, _ := session.Attr("UserId").String()
+		name, _ := session.Attr("UserName").String()
+		return embyUser{Id: id, Name: name}
+	}
+	return embyUser{}
+}
+
+// getItemLibraries 请求 Emby 获取 item 所在的媒体库, 结果会被缓存
+func getItemLibraries(itemInfo ItemInfo) []string {
+	if libraries, ok := itemLibraries.Load(itemInfo.Id); ok {
+		return libraries
+	}
+	res, _ := Fetch("/Items/"+itemInfo.Id+"/Ancestors", http.MethodGet, apiKeyHeader(itemInfo), nil)
+	if res.Code != http.StatusOK || res.Data.Type() != jsons.JsonTypeArr {
+		logs.Warn("获取 Emby 媒体所在的媒体库失败, itemId: %s, err: %s", itemInfo.Id, res.Msg)
+		return nil
+	}
+	libraries := make([]string, 0)
+	res.Data.RangeArr(func(_ int, ancestor *jsons.Item) error {
+		if t, _ := ancestor.Attr("Type").String(); t != "CollectionFolder" {
+			return nil
+		}
+		if name, ok := ancestor.Attr("Name").String(); ok {
+			libraries = append(libraries, name)
+		}
+		return nil
+	})
+	itemLibraries.Store(itemInfo.Id, libraries)
+	return libraries
+}
+
+// matchPlaybackPolicy 根据媒体大小和码率匹配播放策略
+func matchPlaybackPolicy(target config.PolicyTarget, size, bitrate int64) config.PolicyAction {
+	target.Size, target.Bitrate = size, bitrate
+	rule, action := config.C.PlaybackPolicy.Match(target)
+	if rule != nil {
+		logs.Tip("命中播放策略 [%s] => %s, 用户: %s(%s), 客户端: %s, 设备: %s, IP: %s", rule.Name, action, target.UserName, target.UserId, target.Client, target.Device, target.Ip)
+	}
+	return action
+}
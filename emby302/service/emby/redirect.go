@@ -2,6 +2,7 @@ package emby
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +19,8 @@ import (
 
 	"Q115-STRM/emby302/util/urls"
 	"Q115-STRM/emby302/web/cache"
+	"Q115-STRM/internal/linkresolver"
+	"Q115-STRM/internal/playsession"
 
 	"github.com/gin-gonic/gin"
 )
@@ -70,11 +73,20 @@ func Redirect2OpenlistLink(c *gin.Context) {
 		return
 	}
 	// logs.Info("解析到的 itemInfo: %v", itemInfo)
+	target := newPolicyTarget(c, itemInfo)
 
 	// 2 如果请求的是转码资源, 重定向到本地的 m3u8 代理服务
 	msInfo := itemInfo.MsInfo
 	useTranscode := !msInfo.Empty && msInfo.Transcode
 	if useTranscode && msInfo.OpenlistPath != "" {
+		// 转码资源的大小和码率与原画无关, 只按客户端匹配
+		if matchPlaybackPolicy(target, 0, 0) == config.PolicyReject {
+			rejectPlayback(c, "播放策略禁止播放该媒体")
+			return
+		}
+		if _, ok := admitPlayback(c, target, itemInfo.Id, ""); !ok {
+			return
+		}
 		u, _ := url.Parse(strings.ReplaceAll(MasterM3U8UrlTemplate, "${itemId}", itemInfo.Id))
 		q := u.Query()
 		q.Set("template_id", itemInfo.MsInfo.TemplateId)
@@ -87,10 +99,11 @@ func Redirect2OpenlistLink(c *gin.Context) {
 	}
 
 	// 3 请求资源在 Emby 中的 Path 参数
-	embyPath, err := getEmbyFileLocalPath(itemInfo)
+	mediaSource, err := getEmbyMediaSource(itemInfo)
 	if checkErr(c, err) {
 		return
 	}
+	embyPath := mediaSource.Path
 
 	// logs.Info("检查 %s 是否nfs协议的strm文件", embyPath)
 	strmUrl := ""
@@ -111,8 +124,50 @@ func Redirect2OpenlistLink(c *gin.Context) {
 	if strmUrl == "" {
 		strmUrl = embyPath
 	}
+
+	// 4 按播放策略处理, 本地媒体不做转码限制
+	action := matchPlaybackPolicy(target, mediaSource.Size, mediaSource.Bitrate)
+	switch action {
+	case config.PolicyReject:
+		rejectPlayback(c, "播放策略禁止播放该媒体")
+		return
+	case config.PolicyTranscode:
+		if !isLocalMediaPath(embyPath) {
+			rejectPlayback(c, "播放策略要求转码播放, 请选择转码版本")
+			return
+		}
+	}
+
+	// 5 登记播放会话, 超过 Emby 用户或者网盘账号的最大同时播放数时拒绝播放
+	sessionKey, ok := admitPlayback(c, target, itemInfo.Id, strmUrl)
+	if !ok {
+		return
+	}
+	if action == config.PolicyProxy && proxyPlayback(c, strmUrl, sessionKey) {
+		return
+	}
+
+	// 6 在进程内解析直链, 包括 QMediaSync 生成的 115、百度网盘、OpenList 链接以及命中 emby2openlist 映射的路径
+	// 不需要访问 strm 中的地址, Emby 访问不到 strm 中的域名时也能播放
+	directLink, err := resolveDirectLink(c.Request, strmUrl, embyPath)
+	if errors.Is(err, linkresolver.ErrNeedProxy) && proxyPlayback(c, strmUrl, sessionKey) {
+		// 百度网盘的下载链接带有 access_token, 不能重定向给客户端
+		return
+	}
+	if err == nil {
+		logs.Success("重定向到直连地址: %s", directLink)
+		c.Header(cache.HeaderKeyExpired, cache.Duration(time.Minute*10))
+		c.Redirect(http.StatusTemporaryRedirect, directLink)
+		return
+	}
+	if !errors.Is(err, linkresolver.ErrNotMatched) {
+		logs.Warn("进程内解析直链失败, 尝试请求 strm 链接: %v", err)
+		// 释放播放会话, 请求 strm 链接播放成功后上报进度时会重新登记
+		playsession.GetManager().Remove(sessionKey)
+	}
+
 	isProxyUrl := ""
-	// 4 如果是远程地址 (strm) 且不包含qmediasync的本地代理播放链接, 重定向处理
+	// 7 如果是远程地址 (strm) 且不包含qmediasync的本地代理播放链接, 重定向处理
 	if urls.IsRemote(strmUrl) || strings.HasPrefix(strmUrl, "http") || strings.HasPrefix(strmUrl, "nfs:") {
 		finalPath := getFinalRedirectLink(strmUrl, c.Request.Header.Clone())
 		if !strings.Contains(finalPath, "/proxy-115") {
@@ -126,7 +181,7 @@ func Redirect2OpenlistLink(c *gin.Context) {
 		}
 	}
 
-	// 5 如果是本地地址, 回源处理
+	// 8 如果是本地地址, 回源处理
 	// 1. 以/开头
 	// 2. 以windows盘符开头, 正则匹配
 	pattern := `^[A-Za-z]:`
@@ -139,59 +194,7 @@ func Redirect2OpenlistLink(c *gin.Context) {
 		c.Redirect(http.StatusTemporaryRedirect, newUri)
 		return
 	}
-	// // 6 请求 openlist 资源
-	// fi := openlist.FetchInfo{
-	// 	Header:       c.Request.Header.Clone(),
-	// 	UseTranscode: useTranscode,
-	// 	Format:       msInfo.TemplateId,
-	// }
-	// openlistPathRes := path.Emby2Openlist(embyPath)
-
-	// allErrors := strings.Builder{}
-	// // handleOpenlistResource 根据传递的 path 请求 openlist 资源
-	// handleOpenlistResource := func(path string) bool {
-	// 	logs.Info("尝试请求 Openlist 资源: %s", path)
-	// 	fi.Path = path
-	// 	res := openlist.FetchResource(fi)
-
-	// 	if res.Code != http.StatusOK {
-	// 		allErrors.WriteString(fmt.Sprintf("请求 Openlist 失败, code: %d, msg: %s, path: %s;", res.Code, res.Msg, path))
-	// 		return false
-	// 	}
-
-	// 	// 处理直链
-	// 	if !fi.UseTranscode {
-	// 		res.Data.Url = config.C.Emby.Strm.MapPath(res.Data.Url)
-	// 		logs.Success("请求成功, 重定向到: %s", res.Data.Url)
-	// 		c.Header(cache.HeaderKeyExpired, cache.Duration(time.Minute*10))
-	// 		c.Redirect(http.StatusTemporaryRedirect, res.Data.Url)
-	// 		return true
-	// 	}
-
-	// 	// 代理转码 m3u
-	// 	u, _ := url.Parse(https.ClientRequestHost(c.Request) + "/videos/proxy_playlist")
-	// 	q := u.Query()
-	// 	q.Set("template_id", itemInfo.MsInfo.TemplateId)
-	// 	q.Set(QueryApiKeyName, itemInfo.ApiKey)
-	// 	q.Set("openlist_path", openlist.PathEncode(path))
-	// 	u.RawQuery = q.Encode()
-	// 	c.Redirect(http.StatusTemporaryRedirect, u.String())
-	// 	return true
-	// }
-
-	// if openlistPathRes.Success && handleOpenlistResource(openlistPathRes.Path) {
-	// 	return
-	// }
-	// paths, err := openlistPathRes.Range()
-	// if checkErr(c, err) {
-	// 	return
-	// }
-	// if slices.ContainsFunc(paths, func(path string) bool {
-	// 	return handleOpenlistResource(path)
-	// }) {
-	// 	return
-	// }
-
+	playsession.GetManager().Remove(sessionKey)
 	checkErr(c, fmt.Errorf("没有兼容的流"))
 }
 
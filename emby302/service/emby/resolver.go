@@ -0,0 +1,106 @@
+package emby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"Q115-STRM/emby302/config"
+	"Q115-STRM/emby302/service/openlist"
+	"Q115-STRM/emby302/service/path"
+	"Q115-STRM/emby302/util/logs"
+	"Q115-STRM/emby302/web/cache"
+	"Q115-STRM/internal/linkresolver"
+	"Q115-STRM/internal/playproxy"
+	"Q115-STRM/internal/playsession"
+
+	"github.com/gin-gonic/gin"
+)
+
+// linkResolvers 播放时在进程内解析直链, 先解析 QMediaSync 生成的 STRM 链接, 最后按 emby2openlist 映射请求 openlist
+var linkResolvers = linkresolver.NewChain(append(linkresolver.Default(), &openlistPathResolver{})...)
+
+// openlistPathResolver 将命中 emby2openlist 映射的 Emby 路径转换成 openlist 路径后请求直链
+type openlistPathResolver struct{}
+
+func (*openlistPathResolver) Name() string {
+	return "emby2openlist"
+}
+
+func (*openlistPathResolver) Match(req *linkresolver.Request) bool {
+	return req.EmbyPath != "" && path.HitEmby2Openlist(req.EmbyPath)
+}
+
+func (*openlistPathResolver) Resolve(ctx context.Context, req *linkresolver.Request) (string, error) {
+	fi := openlist.FetchInfo{Header: req.Header}
+	allErrors := strings.Builder{}
+	// fetch 根据传递的 path 请求 openlist 原画直链
+	fetch := func(openlistPath string) (string, bool) {
+		logs.Info("尝试请求 Openlist 资源: %s", openlistPath)
+		fi.Path = openlistPath
+		res := openlist.FetchResource(fi)
+		if res.Code != http.StatusOK {
+			allErrors.WriteString(fmt.Sprintf("请求 Openlist 失败, code: %d, msg: %s, path: %s;", res.Code, res.Msg, openlistPath))
+			return "", false
+		}
+		return config.C.Emby.Strm.MapPath(res.Data.Url), true
+	}
+
+	openlistPathRes := path.Emby2Openlist(req.EmbyPath)
+	if link, ok := fetch(openlistPathRes.Path); ok {
+		return link, nil
+	}
+	paths, err := openlistPathRes.Range()
+	if err != nil {
+		return "", err
+	}
+	for _, p := range paths {
+		if link, ok := fetch(p); ok {
+			return link, nil
+		}
+	}
+	return "", fmt.Errorf("%s", allErrors.String())
+}
+
+// resolveDirectLink 在进程内解析直链, 使用 Emby 客户端的 UA 获取网盘直链
+func resolveDirectLink(req *http.Request, strmUrl, embyPath string) (string, error) {
+	return linkResolvers.Resolve(req.Context(), &linkresolver.Request{
+		Url:       strmUrl,
+		EmbyPath:  embyPath,
+		UserAgent: req.UserAgent(),
+		Header:    req.Header.Clone(),
+	})
+}
+
+// proxyPlayback 通过 QMediaSync 的本地分块代理播放 strm 链接
+//
+// 只有 115 和百度网盘的链接支持代理, 不支持时返回 false, 由调用方继续按直链处理
+// 发送的字节数记录到 sessionKey 对应的播放会话
+func proxyPlayback(c *gin.Context, strmUrl, sessionKey string) bool {
+	proxyReq, err := linkresolver.ProxyRequest(c.Request.Context(), &linkresolver.Request{Url: strmUrl, UserAgent: c.Request.UserAgent()})
+	if errors.Is(err, linkresolver.ErrProxyNotSupported) {
+		logs.Warn("播放策略要求本地代理, 但该链接不支持本地代理, 按直链处理: %s", strmUrl)
+		return false
+	}
+	if err != nil {
+		// 没有播放成功, 释放播放会话
+		playsession.GetManager().Remove(sessionKey)
+		checkErr(c, err)
+		return true
+	}
+
+	// 代理的响应体不能缓存
+	c.Header(cache.HeaderKeyExpired, "-1")
+	logs.Success("通过本地代理播放: %s", strmUrl)
+	w := playsession.GetManager().Writer(c.Writer, sessionKey)
+	if err := playproxy.GetProxy().Serve(w, c.Request, proxyReq); err != nil {
+		logs.Error("本地代理播放失败: %v", err)
+		if !c.Writer.Written() {
+			playsession.GetManager().Remove(sessionKey)
+			c.String(http.StatusBadGateway, "本地代理播放失败")
+		}
+	}
+	return true
+}
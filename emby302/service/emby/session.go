@@ -0,0 +1,131 @@
+package emby
+
+import (
+	"net/http"
+	"regexp"
+
+	"Q115-STRM/emby302/config"
+	"Q115-STRM/emby302/util/jsons"
+	"Q115-STRM/emby302/util/logs"
+	"Q115-STRM/emby302/web/cache"
+	"Q115-STRM/internal/linkresolver"
+	"Q115-STRM/internal/playsession"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthorizationDeviceIdExtractReg 匹配 Authorization 头中 DeviceId 字段
+var AuthorizationDeviceIdExtractReg = regexp.MustCompile(`(?i)\bdeviceid="([^"]+)"`)
+
+// getDeviceId 获取客户端的 DeviceId
+func getDeviceId(c *gin.Context) string {
+	deviceId := c.Query("DeviceId")
+	if deviceId == "" {
+		deviceId = c.Query("X-Emby-Device-Id")
+	}
+	if deviceId == "" {
+		deviceId = c.GetHeader("X-Emby-Device-Id")
+	}
+	if deviceId == "" {
+		auth := c.GetHeader(HeaderFullAuthName)
+		if auth == "" {
+			auth = c.GetHeader(HeaderAuthName)
+		}
+		if matches := AuthorizationDeviceIdExtractReg.FindStringSubmatch(auth); len(matches) > 1 {
+			deviceId = matches[1]
+		}
+	}
+	return deviceId
+}
+
+// playSessionKey 播放会话标识, 同一个设备同时只算一个播放
+//
+// 优先使用客户端的 DeviceId, 获取不到时使用客户端名称、设备名称和 IP
+func playSessionKey(c *gin.Context, target config.PolicyTarget) string {
+	deviceId := getDeviceId(c)
+	if deviceId == "" {
+		deviceId = target.Client + "|" + target.Device + "|" + target.Ip
+	}
+	return "emby:" + deviceId
+}
+
+// newSessionTarget 解析播放进度接口的客户端信息, 通过 api_key 查询 Emby 用户
+func newSessionTarget(c *gin.Context) config.PolicyTarget {
+	target := config.PolicyTarget{Ip: c.ClientIP()}
+	target.Client, target.Device = getClientInfo(c)
+	keyType, keyName, apiKey := getApiKey(c)
+	user := getTokenUser(c, ItemInfo{ApiKey: apiKey, ApiKeyType: keyType, ApiKeyName: keyName})
+	target.UserId, target.UserName = user.Id, user.Name
+	return target
+}
+
+// newPlaySession 根据客户端信息创建播放会话
+func newPlaySession(c *gin.Context, target config.PolicyTarget, itemId string) playsession.Session {
+	return playsession.Session{
+		Key:          playSessionKey(c, target),
+		Kind:         playsession.KindEmby,
+		EmbyUserId:   target.UserId,
+		EmbyUserName: target.UserName,
+		ItemId:       itemId,
+		Client:       target.Client,
+		Device:       target.Device,
+		Ip:           target.Ip,
+	}
+}
+
+// streamLimits 获取播放会话的同时播放数限制
+//
+// strm 是 QMediaSync 生成的网盘链接时, 同时按网盘账号限制, 并在会话中记录账号
+func streamLimits(session *playsession.Session, strmUrl string) playsession.Limits {
+	limits := playsession.Limits{User: config.C.StreamLimit.UserLimit(session.EmbyUserId, session.EmbyUserName)}
+	if strmUrl == "" {
+		return limits
+	}
+	account, pickCode, err := linkresolver.StrmAccount(&linkresolver.Request{Url: strmUrl})
+	if err != nil {
+		return limits
+	}
+	session.AccountId, session.AccountName, session.PickCode = account.ID, account.Name, pickCode
+	limits.Account = account.MaxStreams
+	return limits
+}
+
+// admitPlayback 登记播放会话, 超过 Emby 用户或者网盘账号的最大同时播放数时返回 429
+//
+// 返回会话标识, ok 为 false 表示请求已经被处理
+func admitPlayback(c *gin.Context, target config.PolicyTarget, itemId, strmUrl string) (key string, ok bool) {
+	session := newPlaySession(c, target, itemId)
+	if err := playsession.GetManager().Admit(session, streamLimits(&session, strmUrl)); err != nil {
+		logs.Warn("%v, uri: %s", err, c.Request.RequestURI)
+		c.Header(cache.HeaderKeyExpired, "-1")
+		c.String(http.StatusTooManyRequests, err.Error())
+		return "", false
+	}
+	return session.Key, true
+}
+
+// checkPlaybackLimit 检查 MediaSource 是否超过同时播放数限制, 不登记会话
+func checkPlaybackLimit(c *gin.Context, target config.PolicyTarget, itemId, strmUrl string) error {
+	session := newPlaySession(c, target, itemId)
+	return playsession.GetManager().Check(session, streamLimits(&session, strmUrl))
+}
+
+// playbackRateLimitExceeded 返回 Emby 的 RateLimitExceeded 错误, 客户端会提示播放次数超过限制
+func playbackRateLimitExceeded(c *gin.Context, resJson *jsons.Item, err error) {
+	logs.Warn("%v, uri: %s", err, c.Request.RequestURI)
+	resJson.Put("MediaSources", jsons.NewEmptyArr())
+	resJson.Put("ErrorCode", jsons.FromValue("RateLimitExceeded"))
+	c.Header(cache.HeaderKeyExpired, "-1")
+	jsons.OkResp(c.Writer, resJson)
+}
+
+// touchPlaySession 客户端上报播放进度时续期播放会话
+func touchPlaySession(c *gin.Context, itemId string) {
+	target := newSessionTarget(c)
+	playsession.GetManager().Touch(newPlaySession(c, target, itemId))
+}
+
+// stopPlaySession 客户端停止播放时移除播放会话
+func stopPlaySession(c *gin.Context) {
+	playsession.GetManager().Remove(playSessionKey(c, newSessionTarget(c)))
+}
@@ -76,6 +76,13 @@ func Emby2Openlist(embyPath string) OpenlistPathRes {
 	}
 }
 
+// HitEmby2Openlist 判断 Emby 资源路径是否命中 emby2openlist 映射
+func HitEmby2Openlist(embyPath string) bool {
+	embyPath = urls.TransferSlash(urls.Unescape(embyPath))
+	_, ok := config.C.Path.MapEmby2Openlist(strings.TrimPrefix(embyPath, config.C.Emby.MountPath))
+	return ok
+}
+
 // SplitFromSecondSlash 找到给定字符串 str 中第二个 '/' 字符的位置
 // 并以该位置为首字符切割剩余的子串返回
 func SplitFromSecondSlash(str string) (string, error) {
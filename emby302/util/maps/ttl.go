@@ -0,0 +1,83 @@
+package maps
+
+import (
+	"sync"
+	"time"
+)
+
+// TTL 带过期时间和容量上限的并发安全 map
+//
+// 超过容量时先清理过期的元素, 仍然超过时淘汰最早写入的元素
+type TTL[K comparable, V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[K]ttlEntry[V]
+	now     func() time.Time
+}
+
+type ttlEntry[V any] struct {
+	value    V
+	storedAt time.Time
+}
+
+// NewTTL 创建 TTL map, max 小于等于 0 时不限制容量
+func NewTTL[K comparable, V any](ttl time.Duration, max int) *TTL[K, V] {
+	return &TTL[K, V]{ttl: ttl, max: max, entries: make(map[K]ttlEntry[V]), now: time.Now}
+}
+
+// Load 读取未过期的值
+func (m *TTL[K, V]) Load(key K) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok || m.now().Sub(e.storedAt) > m.ttl {
+		delete(m.entries, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Store 写入值, 重新计算过期时间
+func (m *TTL[K, V]) Store(key K, value V) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if _, ok := m.entries[key]; !ok && m.max > 0 && len(m.entries) >= m.max {
+		m.evict(now)
+	}
+	m.entries[key] = ttlEntry[V]{value: value, storedAt: now}
+}
+
+// Delete 删除值
+func (m *TTL[K, V]) Delete(key K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+}
+
+// Len 元素个数, 包括还没有清理的过期元素
+func (m *TTL[K, V]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// evict 清理过期元素, 仍然没有空位时淘汰最早写入的元素
+func (m *TTL[K, V]) evict(now time.Time) {
+	var oldestKey K
+	var oldest time.Time
+	for k, e := range m.entries {
+		if now.Sub(e.storedAt) > m.ttl {
+			delete(m.entries, k)
+			continue
+		}
+		if oldest.IsZero() || e.storedAt.Before(oldest) {
+			oldestKey, oldest = k, e.storedAt
+		}
+	}
+	if len(m.entries) >= m.max {
+		delete(m.entries, oldestKey)
+	}
+}
@@ -0,0 +1,39 @@
+package maps
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	m := NewTTL[string, int](time.Minute, 2)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	m.Store("a", 1)
+	now = now.Add(time.Second)
+	m.Store("b", 2)
+	if v, ok := m.Load("a"); !ok || v != 1 {
+		t.Fatalf("Load(a) = %d, %v", v, ok)
+	}
+
+	// 超过容量时淘汰最早写入的元素
+	now = now.Add(time.Second)
+	m.Store("c", 3)
+	if _, ok := m.Load("a"); ok {
+		t.Error("a 应该被淘汰")
+	}
+	if m.Len() != 2 {
+		t.Errorf("Len() = %d; want 2", m.Len())
+	}
+
+	// 过期后读取不到
+	now = now.Add(2 * time.Minute)
+	if _, ok := m.Load("b"); ok {
+		t.Error("b 应该已经过期")
+	}
+	m.Store("d", 4)
+	if v, ok := m.Load("d"); !ok || v != 4 {
+		t.Errorf("Load(d) = %d, %v", v, ok)
+	}
+}
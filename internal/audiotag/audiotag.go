@@ -0,0 +1,289 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bogem/id3v2"
+)
+
+var ErrUnsupported = errors.New("不支持的音频标签格式")
+
+// 音频文件内嵌的标签，只保留整理音乐需要的字段
+type Tags struct {
+	Title                  string `json:"title"`
+	Artist                 string `json:"artist"`
+	AlbumArtist            string `json:"album_artist"`
+	Album                  string `json:"album"`
+	Year                   int    `json:"year"`
+	Track                  int    `json:"track"`
+	TrackTotal             int    `json:"track_total"`
+	Disc                   int    `json:"disc"`
+	Genre                  string `json:"genre"`
+	MusicBrainzAlbumId     string `json:"musicbrainz_album_id"`
+	MusicBrainzRecordingId string `json:"musicbrainz_recording_id"`
+}
+
+// 专辑目录使用的艺术家，优先使用专辑艺术家，合辑中每首歌的艺术家不同
+func (t *Tags) GetAlbumArtist() string {
+	if t.AlbumArtist != "" {
+		return t.AlbumArtist
+	}
+	return t.Artist
+}
+
+// 是否读取到了可以用来整理的标签
+func (t *Tags) IsEmpty() bool {
+	return t.Title == "" && t.Artist == "" && t.Album == ""
+}
+
+// 评论块和Ogg包的最大长度，超过时认为文件损坏，避免封面图过大占用内存
+const maxBlockSize = 16 * 1024 * 1024
+
+var yearRe = regexp.MustCompile(`(\d{4})`)
+
+// ReadFile 读取本地音频文件的标签
+func ReadFile(path string) (*Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read 按文件头识别格式并读取标签，支持ID3v2（mp3等）、FLAC和Ogg（Vorbis、Opus）
+// 只按需读取文件头部，r可以是网盘文件的分段读取
+func Read(r io.ReaderAt) (*Tags, error) {
+	header := make([]byte, 10)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		tags, err := readID3(r)
+		if err != nil {
+			return nil, err
+		}
+		// 部分FLAC文件前面有ID3标签，ID3标签为空时继续读取FLAC的评论块
+		if tags.IsEmpty() {
+			offset := int64(10 + syncSafeSize(header[6:10]))
+			magic := make([]byte, 4)
+			if _, err := r.ReadAt(magic, offset); err == nil && string(magic) == "fLaC" {
+				return readFlac(r, offset)
+			}
+		}
+		return tags, nil
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return readFlac(r, 0)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return readOgg(r)
+	}
+	return nil, ErrUnsupported
+}
+
+func syncSafeSize(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+func readID3(r io.ReaderAt) (*Tags, error) {
+	tag, err := id3v2.ParseReader(io.NewSectionReader(r, 0, math.MaxInt64), id3v2.Options{
+		Parse:       true,
+		ParseFrames: []string{"TIT2", "TPE1", "TPE2", "TALB", "TYER", "TDRC", "TRCK", "TPOS", "TCON", "TXXX", "UFID"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tag.Close()
+	tags := &Tags{
+		Title:       strings.TrimSpace(tag.Title()),
+		Artist:      strings.TrimSpace(tag.Artist()),
+		AlbumArtist: strings.TrimSpace(tag.GetTextFrame("TPE2").Text),
+		Album:       strings.TrimSpace(tag.Album()),
+		Genre:       strings.TrimSpace(tag.Genre()),
+	}
+	for _, id := range []string{"TDRC", "TYER"} {
+		if tags.Year = parseYear(tag.GetTextFrame(id).Text); tags.Year > 0 {
+			break
+		}
+	}
+	tags.Track, tags.TrackTotal = parseNumber(tag.GetTextFrame("TRCK").Text)
+	tags.Disc, _ = parseNumber(tag.GetTextFrame("TPOS").Text)
+	for _, f := range tag.GetFrames("TXXX") {
+		if udtf, ok := f.(id3v2.UserDefinedTextFrame); ok && strings.EqualFold(udtf.Description, "MusicBrainz Album Id") {
+			tags.MusicBrainzAlbumId = strings.TrimSpace(udtf.Value)
+		}
+	}
+	for _, f := range tag.GetFrames("UFID") {
+		if ufid, ok := f.(id3v2.UFIDFrame); ok && ufid.OwnerIdentifier == "http://musicbrainz.org" {
+			tags.MusicBrainzRecordingId = strings.TrimSpace(string(ufid.Identifier))
+		}
+	}
+	return tags, nil
+}
+
+// FLAC元数据块：1字节类型（最高位表示最后一块）+ 3字节长度，类型4是VORBIS_COMMENT
+func readFlac(r io.ReaderAt, offset int64) (*Tags, error) {
+	offset += 4
+	header := make([]byte, 4)
+	for {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		offset += 4
+		if blockType == 4 {
+			block := make([]byte, size)
+			if _, err := r.ReadAt(block, offset); err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			return parseVorbisComment(block)
+		}
+		if last {
+			return &Tags{}, nil
+		}
+		offset += size
+	}
+}
+
+// Ogg页面：27字节头 + 分段表，包可以跨页面，第二个包是评论头
+func readOgg(r io.ReaderAt) (*Tags, error) {
+	var offset int64
+	packets := 0
+	packet := make([]byte, 0)
+	header := make([]byte, 27)
+	for {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+		if string(header[:4]) != "OggS" {
+			return nil, errors.New("Ogg页面头错误")
+		}
+		segments := make([]byte, header[26])
+		if _, err := r.ReadAt(segments, offset+27); err != nil {
+			return nil, err
+		}
+		offset += 27 + int64(len(segments))
+		for _, seg := range segments {
+			data := make([]byte, seg)
+			if _, err := r.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			offset += int64(seg)
+			packet = append(packet, data...)
+			if len(packet) > maxBlockSize {
+				return nil, errors.New("Ogg评论头过大")
+			}
+			// 长度小于255的分段表示包结束
+			if seg == 255 {
+				continue
+			}
+			packets++
+			if packets == 2 {
+				switch {
+				case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+					return parseVorbisComment(packet[7:])
+				case bytes.HasPrefix(packet, []byte("OpusTags")):
+					return parseVorbisComment(packet[8:])
+				}
+				return nil, ErrUnsupported
+			}
+			packet = packet[:0]
+		}
+	}
+}
+
+// Vorbis评论：小端长度 + 厂商字符串，评论数量，每条评论为长度 + KEY=value
+func parseVorbisComment(data []byte) (*Tags, error) {
+	rd := bytes.NewReader(data)
+	readString := func() (string, error) {
+		var size uint32
+		if err := binary.Read(rd, binary.LittleEndian, &size); err != nil {
+			return "", err
+		}
+		if int64(size) > int64(rd.Len()) {
+			return "", errors.New("Vorbis评论长度错误")
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+	if _, err := readString(); err != nil {
+		return nil, err
+	}
+	var count uint32
+	if err := binary.Read(rd, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	tags := &Tags{}
+	for i := uint32(0); i < count; i++ {
+		comment, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			tags.Title = value
+		case "ARTIST":
+			tags.Artist = value
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			tags.AlbumArtist = value
+		case "ALBUM":
+			tags.Album = value
+		case "DATE", "YEAR":
+			if tags.Year == 0 {
+				tags.Year = parseYear(value)
+			}
+		case "TRACKNUMBER":
+			track, total := parseNumber(value)
+			tags.Track = track
+			if total > 0 {
+				tags.TrackTotal = total
+			}
+		case "TRACKTOTAL", "TOTALTRACKS":
+			tags.TrackTotal, _ = strconv.Atoi(value)
+		case "DISCNUMBER":
+			tags.Disc, _ = parseNumber(value)
+		case "GENRE":
+			tags.Genre = value
+		case "MUSICBRAINZ_ALBUMID":
+			tags.MusicBrainzAlbumId = value
+		case "MUSICBRAINZ_TRACKID":
+			tags.MusicBrainzRecordingId = value
+		}
+	}
+	return tags, nil
+}
+
+func parseYear(s string) int {
+	m := yearRe.FindString(s)
+	if m == "" {
+		return 0
+	}
+	year, _ := strconv.Atoi(m)
+	return year
+}
+
+// 解析 3 或 3/12 格式的编号
+func parseNumber(s string) (int, int) {
+	num, total, _ := strings.Cut(strings.TrimSpace(s), "/")
+	n, _ := strconv.Atoi(strings.TrimSpace(num))
+	t, _ := strconv.Atoi(strings.TrimSpace(total))
+	return n, t
+}
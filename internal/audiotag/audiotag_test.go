@@ -0,0 +1,154 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bogem/id3v2"
+)
+
+func makeID3(t *testing.T) []byte {
+	tag := id3v2.NewEmptyTag()
+	tag.SetVersion(4)
+	tag.SetDefaultEncoding(id3v2.EncodingUTF8)
+	tag.SetTitle("晴天")
+	tag.SetArtist("周杰伦")
+	tag.SetAlbum("叶惠美")
+	tag.AddTextFrame("TDRC", id3v2.EncodingUTF8, "2003-07-31")
+	tag.AddTextFrame("TRCK", id3v2.EncodingUTF8, "3/11")
+	tag.AddTextFrame("TPOS", id3v2.EncodingUTF8, "1/1")
+	tag.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{Encoding: id3v2.EncodingUTF8, Description: "MusicBrainz Album Id", Value: "album-id"})
+	tag.AddUFIDFrame(id3v2.UFIDFrame{OwnerIdentifier: "http://musicbrainz.org", Identifier: []byte("recording-id")})
+	// 封面图放在标签中间，检查跳过大帧
+	tag.AddAttachedPicture(id3v2.PictureFrame{Encoding: id3v2.EncodingUTF8, MimeType: "image/jpeg", PictureType: id3v2.PTFrontCover, Picture: bytes.Repeat([]byte{0xff}, 600*1024)})
+	buf := &bytes.Buffer{}
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatalf("生成ID3标签失败: %v", err)
+	}
+	buf.Write(bytes.Repeat([]byte{0}, 1024))
+	return buf.Bytes()
+}
+
+func vorbisComment(comments ...string) []byte {
+	buf := &bytes.Buffer{}
+	writeString := func(s string) {
+		binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+	writeString("test")
+	binary.Write(buf, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		writeString(c)
+	}
+	return buf.Bytes()
+}
+
+func flacBlock(blockType byte, last bool, data []byte) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	size := len(data)
+	return append([]byte{blockType, byte(size >> 16), byte(size >> 8), byte(size)}, data...)
+}
+
+func makeFlac() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("fLaC")
+	buf.Write(flacBlock(0, false, make([]byte, 34)))
+	buf.Write(flacBlock(6, false, make([]byte, 2048)))
+	buf.Write(flacBlock(4, true, vorbisComment("TITLE=Yellow", "ARTIST=Coldplay", "ALBUMARTIST=Coldplay", "ALBUM=Parachutes", "DATE=2000", "TRACKNUMBER=5", "TRACKTOTAL=10", "DISCNUMBER=1/1", "MUSICBRAINZ_ALBUMID=album-id")))
+	return buf.Bytes()
+}
+
+// 按255字节分段，一个包一页
+func oggPage(packet []byte) []byte {
+	segments := make([]byte, 0)
+	for n := len(packet); ; n -= 255 {
+		if n < 255 {
+			segments = append(segments, byte(n))
+			break
+		}
+		segments = append(segments, 255)
+	}
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	header[26] = byte(len(segments))
+	return append(append(header, segments...), packet...)
+}
+
+func makeOpus() []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(oggPage([]byte("OpusHead\x01\x02\x38\x01\x80\xbb\x00\x00\x00\x00\x00")))
+	// 评论超过255字节，检查跨分段的包
+	buf.Write(oggPage(append([]byte("OpusTags"), vorbisComment("TITLE="+strings.Repeat("长", 100), "ARTIST=Artist", "ALBUM=Album", "TRACKNUMBER=2/9")...)))
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected Tags
+	}{
+		{
+			name:     "ID3",
+			data:     makeID3(t),
+			expected: Tags{Title: "晴天", Artist: "周杰伦", Album: "叶惠美", Year: 2003, Track: 3, TrackTotal: 11, Disc: 1, MusicBrainzAlbumId: "album-id", MusicBrainzRecordingId: "recording-id"},
+		},
+		{
+			name:     "FLAC",
+			data:     makeFlac(),
+			expected: Tags{Title: "Yellow", Artist: "Coldplay", AlbumArtist: "Coldplay", Album: "Parachutes", Year: 2000, Track: 5, TrackTotal: 10, Disc: 1, MusicBrainzAlbumId: "album-id"},
+		},
+		{
+			name:     "Opus",
+			data:     makeOpus(),
+			expected: Tags{Title: strings.Repeat("长", 100), Artist: "Artist", Album: "Album", Track: 2, TrackTotal: 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := Read(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("读取标签失败: %v", err)
+			}
+			if *tags != tt.expected {
+				t.Errorf("标签 %+v 与预期 %+v 不符", *tags, tt.expected)
+			}
+		})
+	}
+	if _, err := Read(bytes.NewReader([]byte("RIFF0000WAVE"))); err != ErrUnsupported {
+		t.Errorf("不支持的格式应该返回ErrUnsupported，实际: %v", err)
+	}
+}
+
+func TestReadUrl(t *testing.T) {
+	data := append(makeID3(t), bytes.Repeat([]byte{0}, 4*1024*1024)...)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("User-Agent") != "test-ua" || r.Header.Get("Range") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "test.mp3", time.Now(), bytes.NewReader(data))
+	}))
+	defer srv.Close()
+	tags, err := ReadUrl(srv.URL, "test-ua")
+	if err != nil {
+		t.Fatalf("读取网盘文件标签失败: %v", err)
+	}
+	if tags.Title != "晴天" || tags.Track != 3 {
+		t.Errorf("标签错误: %+v", *tags)
+	}
+	// 只读取标签所在的块
+	if n := requests.Load(); n > 4 {
+		t.Errorf("读取标签请求了 %d 次，应该只读取文件头部", n)
+	}
+}
@@ -0,0 +1,99 @@
+package audiotag
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 分段读取的块大小，标签一般在文件头部，封面图较大时需要多读几块
+const httpBlockSize = 256 * 1024
+
+// 通过HTTP Range请求按需读取网盘文件，只缓存已读取的块，不下载整个文件
+type httpReaderAt struct {
+	url       string
+	userAgent string
+	client    *http.Client
+	mu        sync.Mutex
+	blocks    map[int64][]byte
+}
+
+// ReadUrl 使用Range请求读取网盘文件的标签，userAgent需要和获取下载链接时使用的一致
+func ReadUrl(url, userAgent string) (*Tags, error) {
+	return Read(newHttpReaderAt(url, userAgent))
+}
+
+func newHttpReaderAt(url, userAgent string) *httpReaderAt {
+	return &httpReaderAt{
+		url:       url,
+		userAgent: userAgent,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		blocks:    make(map[int64][]byte),
+	}
+}
+
+func (h *httpReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		index := (off + int64(n)) / httpBlockSize
+		block, err := h.getBlock(index)
+		if err != nil {
+			return n, err
+		}
+		start := int(off + int64(n) - index*httpBlockSize)
+		if start >= len(block) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], block[start:])
+		// 块不完整说明已经到文件末尾
+		if len(block) < httpBlockSize && n < len(p) {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func (h *httpReaderAt) getBlock(index int64) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if block, ok := h.blocks[index]; ok {
+		return block, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.userAgent != "" {
+		req.Header.Set("User-Agent", h.userAgent)
+	}
+	start := index * httpBlockSize
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, start+httpBlockSize-1))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var block []byte
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		block, err = io.ReadAll(io.LimitReader(resp.Body, httpBlockSize))
+	case http.StatusRequestedRangeNotSatisfiable:
+		block = []byte{}
+	case http.StatusOK:
+		// 不支持Range时服务器返回整个文件，跳过前面的部分
+		if _, err = io.CopyN(io.Discard, resp.Body, start); err == nil {
+			block, err = io.ReadAll(io.LimitReader(resp.Body, httpBlockSize))
+		} else if err == io.EOF {
+			block, err = []byte{}, nil
+		}
+	default:
+		return nil, fmt.Errorf("读取文件失败，HTTP状态码 %d", resp.StatusCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.blocks[index] = block
+	return block, nil
+}
@@ -82,6 +82,9 @@ func stopAllTasks() error {
 	if synccron.ScrapeCron != nil {
 		synccron.ScrapeCron.Stop()
 	}
+	if synccron.RefreshCron != nil {
+		synccron.RefreshCron.Stop()
+	}
 	if models.GlobalDownloadQueue != nil {
 		models.GlobalDownloadQueue.Stop()
 	}
@@ -97,6 +100,7 @@ func startAllTasks() error {
 	synccron.InitCron()
 	synccron.InitSyncCron()
 	synccron.InitScrapeCron()
+	synccron.InitRefreshCron()
 	if models.GlobalDownloadQueue != nil {
 		models.GlobalDownloadQueue.Start()
 	}
@@ -128,8 +132,6 @@ func Backup(backupType string, reason string) error {
 	SetRunning(true)
 	defer SetRunning(false)
 	SetRunningResult("backup", fmt.Sprintf("开始%s备份", backupType), totalTable, count, "", true)
-	// 清理旧备份
-	models.GetBackupService().CleanupOldBackups()
 	record := &models.BackupRecord{
 		Status:        models.BackupStatusRunning,
 		BackupType:    backupType,
@@ -181,6 +183,25 @@ func Backup(backupType string, reason string) error {
 		helpers.AppLogger.Errorf("打包备份目录失败: %v", err)
 		return err
 	}
+	// 删除目录
+	os.RemoveAll(backupRecordDir)
+	config := models.GetBackupService().GetBackupConfig()
+	if config.BackupEncrypt == 1 {
+		if config.BackupEncryptPassword == "" {
+			helpers.AppLogger.Warnf("已启用备份加密但没有设置密码，跳过加密")
+		} else {
+			SetRunningResult("backup", "正在加密备份文件", totalTable, count, "", false)
+			encryptedPath := filePath + ".enc"
+			if err := helpers.EncryptFile(filePath, encryptedPath, config.BackupEncryptPassword); err != nil {
+				helpers.AppLogger.Errorf("加密备份文件失败: %v", err)
+				os.Remove(encryptedPath)
+				return err
+			}
+			os.Remove(filePath)
+			filePath = encryptedPath
+			record.IsEncrypted = 1
+		}
+	}
 	stat, err := os.Stat(filePath)
 	if err != nil {
 		helpers.AppLogger.Errorf("获取备份文件状态失败: %v", err)
@@ -191,9 +212,11 @@ func Backup(backupType string, reason string) error {
 	record.BackupDuration = int64(time.Since(startTime).Seconds())
 	record.TableCount = totalTable
 	db.Db.Save(record)
-	// 删除目录
-	os.RemoveAll(backupRecordDir)
 	helpers.AppLogger.Infof("备份完成: 共%d张表, 耗%.1f秒, 文件大小%.2fMB", totalTable, time.Since(startTime).Seconds(), float64(stat.Size())/1024/1024)
+	// 上传到远程目标
+	uploadToDestinations(record)
+	// 清理旧备份，放在备份完成后，保证最新的备份参与保留策略
+	models.GetBackupService().CleanupOldBackups()
 	return nil
 }
 
@@ -0,0 +1,426 @@
+package backup
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/openlist"
+	"Q115-STRM/internal/v115open"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 远程目标中的备份文件
+type RemoteFile struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+}
+
+// Destination 备份存储目标
+// 所有文件都直接存放在目标配置的目录中，name是不带目录的文件名
+type Destination interface {
+	Upload(ctx context.Context, localPath string) error
+	Download(ctx context.Context, name string, localPath string) error
+	Delete(ctx context.Context, name string) error
+	List(ctx context.Context) ([]RemoteFile, error)
+}
+
+func init() {
+	models.DeleteRemoteBackupFunc = deleteRemoteBackup
+}
+
+// NewDestination 根据配置创建备份目标
+func NewDestination(d *models.BackupDestination) (Destination, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	switch d.Type {
+	case models.BackupDestinationLocal:
+		return &localDestination{dir: d.Path}, nil
+	case models.BackupDestinationWebDAV:
+		return newWebDAVDestination(d.Url, d.Path, d.Username, d.Password), nil
+	case models.BackupDestinationS3:
+		return newS3Destination(d.Url, d.Region, d.Bucket, d.Path, d.Username, d.Password), nil
+	case models.BackupDestination115, models.BackupDestinationOpenList:
+		account, err := models.GetAccountById(d.AccountId)
+		if err != nil || account == nil {
+			return nil, fmt.Errorf("账号 %d 不存在", d.AccountId)
+		}
+		if d.Type == models.BackupDestination115 {
+			if account.SourceType != models.SourceType115 {
+				return nil, fmt.Errorf("账号 %d 不是115账号", d.AccountId)
+			}
+			return &open115Destination{client: account.Get115Client(), dir: d.Path}, nil
+		}
+		if account.SourceType != models.SourceTypeOpenList {
+			return nil, fmt.Errorf("账号 %d 不是OpenList账号", d.AccountId)
+		}
+		return &openListDestination{client: account.GetOpenListClient(), dir: d.Path}, nil
+	}
+	return nil, fmt.Errorf("不支持的备份目标类型: %s", d.Type)
+}
+
+// 上传备份文件到所有启用的目标，单个目标失败不影响其他目标
+func uploadToDestinations(record *models.BackupRecord) {
+	for _, d := range models.GetEnabledBackupDestinations() {
+		upload := &models.BackupUpload{
+			RecordId:      record.ID,
+			DestinationId: d.ID,
+			FileName:      record.FileName(),
+			Status:        models.BackupUploadStatusCompleted,
+		}
+		SetRunningResult("backup", fmt.Sprintf("正在上传备份到 %s", d.Name), 0, 0, "", false)
+		dest, err := NewDestination(&d)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), models.MaxBackupTimeout)
+			err = dest.Upload(ctx, record.FilePath)
+			cancel()
+		}
+		if err != nil {
+			helpers.AppLogger.Errorf("上传备份 %s 到 %s 失败: %v", upload.FileName, d.Name, err)
+			upload.Status = models.BackupUploadStatusFailed
+			upload.ErrorMsg = err.Error()
+		} else {
+			helpers.AppLogger.Infof("已上传备份 %s 到 %s", upload.FileName, d.Name)
+		}
+		if err := models.SaveBackupUpload(upload); err != nil {
+			helpers.AppLogger.Errorf("保存备份上传记录失败: %v", err)
+		}
+	}
+}
+
+func deleteRemoteBackup(upload *models.BackupUpload) error {
+	d, err := models.GetBackupDestinationById(upload.DestinationId)
+	if err != nil {
+		return fmt.Errorf("备份目标 %d 不存在", upload.DestinationId)
+	}
+	dest, err := NewDestination(d)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return dest.Delete(ctx, upload.FileName)
+}
+
+// RestoreFromDestination 从备份目标下载备份文件并还原
+func RestoreFromDestination(destinationId uint, name string) error {
+	if IsRunning() {
+		return fmt.Errorf("备份或还原任务正在运行中")
+	}
+	if name == "" || name != path.Base(name) || strings.Contains(name, "\\") {
+		return fmt.Errorf("无效的备份文件名: %s", name)
+	}
+	d, err := models.GetBackupDestinationById(destinationId)
+	if err != nil {
+		return fmt.Errorf("备份目标不存在")
+	}
+	dest, err := NewDestination(d)
+	if err != nil {
+		return err
+	}
+	tempDir, err := os.MkdirTemp(filepath.Join(helpers.ConfigDir, "backups"), "backup-download-*")
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	localPath := filepath.Join(tempDir, name)
+	ctx, cancel := context.WithTimeout(context.Background(), models.MaxBackupTimeout)
+	defer cancel()
+	helpers.AppLogger.Infof("开始从 %s 下载备份文件 %s", d.Name, name)
+	if err := dest.Download(ctx, name, localPath); err != nil {
+		return fmt.Errorf("下载备份文件失败: %v", err)
+	}
+	return Restore(localPath)
+}
+
+// RestoreFromRecord 从备份记录还原，本地文件不存在时从上传成功的备份目标下载
+func RestoreFromRecord(record *models.BackupRecord) error {
+	if _, err := os.Stat(record.FilePath); err == nil {
+		return Restore(record.FilePath)
+	}
+	for _, upload := range models.GetBackupUploads(record.ID) {
+		if upload.Status != models.BackupUploadStatusCompleted {
+			continue
+		}
+		helpers.AppLogger.Infof("本地备份文件 %s 不存在，尝试从备份目标 %d 下载", record.FilePath, upload.DestinationId)
+		err := RestoreFromDestination(upload.DestinationId, upload.FileName)
+		if err == nil {
+			return nil
+		}
+		helpers.AppLogger.Warnf("从备份目标 %d 还原失败: %v", upload.DestinationId, err)
+	}
+	return fmt.Errorf("备份文件不存在")
+}
+
+// ListDestinationFiles 列出备份目标中的备份文件，按修改时间倒序
+func ListDestinationFiles(d *models.BackupDestination) ([]RemoteFile, error) {
+	dest, err := NewDestination(d)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	files, err := dest.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]RemoteFile, 0, len(files))
+	for _, f := range files {
+		if isBackupFileName(f.Name) {
+			result = append(result, f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ModTime > result[j].ModTime
+	})
+	return result, nil
+}
+
+// TestDestination 检查备份目标是否可以访问
+func TestDestination(d *models.BackupDestination) error {
+	dest, err := NewDestination(d)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = dest.List(ctx)
+	return err
+}
+
+func isBackupFileName(name string) bool {
+	return strings.HasPrefix(name, "backup_") && (strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".zip.enc"))
+}
+
+// 复制文件，先写临时文件再改名
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFile(in, dst)
+}
+
+func writeFile(r io.Reader, dst string) error {
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// 本地目录，可以是挂载的其他磁盘或网络存储
+type localDestination struct {
+	dir string
+}
+
+func (l *localDestination) Upload(ctx context.Context, localPath string) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+	return copyFile(localPath, filepath.Join(l.dir, filepath.Base(localPath)))
+}
+
+func (l *localDestination) Download(ctx context.Context, name string, localPath string) error {
+	return copyFile(filepath.Join(l.dir, name), localPath)
+}
+
+func (l *localDestination) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(l.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *localDestination) List(ctx context.Context) ([]RemoteFile, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	files := make([]RemoteFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, RemoteFile{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime().Unix()})
+	}
+	return files, nil
+}
+
+// 115网盘，使用账号的开放平台客户端
+type open115Destination struct {
+	client *v115open.OpenClient
+	dir    string
+}
+
+// 逐级查找目录，不存在时创建
+func (o *open115Destination) dirId(ctx context.Context, create bool) (string, error) {
+	parentId := "0"
+	current := ""
+	for _, part := range strings.Split(strings.Trim(filepath.ToSlash(o.dir), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current += "/" + part
+		detail, err := o.client.GetFsDetailByPath(ctx, current)
+		if err == nil && detail != nil && detail.FileId != "" {
+			parentId = detail.FileId
+			continue
+		}
+		if !create {
+			return "", fmt.Errorf("115目录 %s 不存在", current)
+		}
+		fileId, err := o.client.MkDir(ctx, parentId, part)
+		if err != nil || fileId == "" {
+			return "", fmt.Errorf("创建115目录 %s 失败: %v", current, err)
+		}
+		parentId = fileId
+	}
+	return parentId, nil
+}
+
+func (o *open115Destination) Upload(ctx context.Context, localPath string) error {
+	dirId, err := o.dirId(ctx, true)
+	if err != nil {
+		return err
+	}
+	_, err = o.client.Upload(ctx, localPath, dirId, "", "")
+	return err
+}
+
+func (o *open115Destination) find(ctx context.Context, name string) (*v115open.FileDetail, error) {
+	detail, err := o.client.GetFsDetailByPath(ctx, path.Join("/", filepath.ToSlash(o.dir), name))
+	if err != nil {
+		return nil, err
+	}
+	if detail == nil || detail.FileId == "" {
+		return nil, fmt.Errorf("115中不存在文件 %s", name)
+	}
+	return detail, nil
+}
+
+func (o *open115Destination) Download(ctx context.Context, name string, localPath string) error {
+	detail, err := o.find(ctx, name)
+	if err != nil {
+		return err
+	}
+	url := o.client.GetDownloadUrl(ctx, detail.PickCode, v115open.DEFAULTUA, false)
+	if url == "" {
+		return fmt.Errorf("获取115下载地址失败")
+	}
+	return helpers.DownloadFile(url, localPath, v115open.DEFAULTUA)
+}
+
+func (o *open115Destination) Delete(ctx context.Context, name string) error {
+	detail, err := o.find(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = o.client.Del(ctx, []string{detail.FileId}, "")
+	return err
+}
+
+func (o *open115Destination) List(ctx context.Context) ([]RemoteFile, error) {
+	dirId, err := o.dirId(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	const limit = 1000
+	files := make([]RemoteFile, 0)
+	for offset := 0; ; offset += limit {
+		resp, err := o.client.GetFsList(ctx, dirId, true, false, false, offset, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range resp.Data {
+			if f.FileCategory == v115open.TypeDir {
+				continue
+			}
+			files = append(files, RemoteFile{Name: f.FileName, Size: f.FileSize, ModTime: f.Ptime})
+		}
+		if len(resp.Data) < limit {
+			return files, nil
+		}
+	}
+}
+
+// OpenList，使用账号的OpenList客户端
+type openListDestination struct {
+	client *openlist.Client
+	dir    string
+}
+
+func (o *openListDestination) remotePath(name string) string {
+	return path.Join("/", filepath.ToSlash(o.dir), name)
+}
+
+func (o *openListDestination) Upload(ctx context.Context, localPath string) error {
+	if err := o.client.Mkdir(o.remotePath("")); err != nil {
+		helpers.AppLogger.Warnf("创建OpenList目录 %s 失败: %v", o.dir, err)
+	}
+	_, err := o.client.UploadUseHttp(localPath, o.remotePath(filepath.Base(localPath)))
+	return err
+}
+
+func (o *openListDestination) Download(ctx context.Context, name string, localPath string) error {
+	url := o.client.GetRawUrl(o.remotePath(name))
+	if url == "" {
+		return fmt.Errorf("获取OpenList下载地址失败")
+	}
+	return helpers.DownloadFile(url, localPath, v115open.DEFAULTUA)
+}
+
+func (o *openListDestination) Delete(ctx context.Context, name string) error {
+	return o.client.Del(o.remotePath(""), []string{name})
+}
+
+func (o *openListDestination) List(ctx context.Context) ([]RemoteFile, error) {
+	const perPage = 500
+	files := make([]RemoteFile, 0)
+	for page := 1; ; page++ {
+		resp, err := o.client.FileList(ctx, o.remotePath(""), page, perPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range resp.Content {
+			if f.IsDir {
+				continue
+			}
+			var modTime int64
+			if t, err := time.Parse(time.RFC3339, f.Modified); err == nil {
+				modTime = t.Unix()
+			}
+			files = append(files, RemoteFile{Name: f.Name, Size: f.Size, ModTime: modTime})
+		}
+		if len(resp.Content) < perPage {
+			return files, nil
+		}
+	}
+}
@@ -25,6 +25,15 @@ func Restore(filePath string) error {
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return fmt.Errorf("备份文件不存在")
 	}
+	// 加密的备份先使用配置的密码解密
+	if helpers.IsEncryptedFile(filePath) {
+		decryptedPath, err := decryptBackupFile(filePath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(decryptedPath)
+		filePath = decryptedPath
+	}
 	SetRunning(true)
 	defer SetRunning(false)
 	// 停止所有任务
@@ -61,6 +70,24 @@ func Restore(filePath string) error {
 	return nil
 }
 
+// 解密备份文件到备份目录下的临时文件，返回临时文件路径
+func decryptBackupFile(filePath string) (string, error) {
+	password := models.GetBackupService().GetBackupConfig().BackupEncryptPassword
+	if password == "" {
+		return "", fmt.Errorf("备份文件已加密，请先在备份配置中设置加密密码")
+	}
+	tempFile, err := os.CreateTemp(filepath.Join(helpers.ConfigDir, "backups"), "backup-decrypt-*.zip")
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tempFile.Close()
+	if err := helpers.DecryptFile(filePath, tempFile.Name(), password); err != nil {
+		os.Remove(tempFile.Name())
+		return "", fmt.Errorf("解密备份文件失败: %v", err)
+	}
+	return tempFile.Name(), nil
+}
+
 // 从json文件还原到数据库
 func restoreFromJsonFile(backupDir string, modelName string, totalTable int, count *int, model any) error {
 	backupFilePath := filepath.Join(backupDir, modelName+".json")
@@ -0,0 +1,188 @@
+package backup
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WebDAV服务器，只使用MKCOL、PUT、GET、DELETE、PROPFIND
+type webDAVDestination struct {
+	baseUrl  string
+	dir      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newWebDAVDestination(baseUrl, dir, username, password string) *webDAVDestination {
+	return &webDAVDestination{
+		baseUrl:  strings.TrimRight(baseUrl, "/"),
+		dir:      strings.Trim(filepath.ToSlash(dir), "/"),
+		username: username,
+		password: password,
+		// 超时由调用方的context控制，上传大文件时不能使用太短的超时
+		client: &http.Client{},
+	}
+}
+
+func (w *webDAVDestination) url(p string) string {
+	u := w.baseUrl
+	for _, part := range strings.Split(p, "/") {
+		if part != "" {
+			u += "/" + url.PathEscape(part)
+		}
+	}
+	return u
+}
+
+func (w *webDAVDestination) do(ctx context.Context, method, p string, body io.Reader, size int64, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, w.url(p), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return w.client.Do(req)
+}
+
+// 逐级创建目录，目录已存在时服务器返回405
+func (w *webDAVDestination) mkdirAll(ctx context.Context) error {
+	current := ""
+	for _, part := range strings.Split(w.dir, "/") {
+		if part == "" {
+			continue
+		}
+		current += "/" + part
+		resp, err := w.do(ctx, "MKCOL", current+"/", nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("创建WebDAV目录 %s 失败: %s", current, resp.Status)
+		}
+	}
+	return nil
+}
+
+func (w *webDAVDestination) Upload(ctx context.Context, localPath string) error {
+	if err := w.mkdirAll(ctx); err != nil {
+		return err
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	resp, err := w.do(ctx, http.MethodPut, path.Join(w.dir, filepath.Base(localPath)), file, stat.Size(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("上传到WebDAV失败: %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *webDAVDestination) Download(ctx context.Context, name string, localPath string) error {
+	resp, err := w.do(ctx, http.MethodGet, path.Join(w.dir, name), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("从WebDAV下载失败: %s", resp.Status)
+	}
+	return writeFile(resp.Body, localPath)
+}
+
+func (w *webDAVDestination) Delete(ctx context.Context, name string) error {
+	resp, err := w.do(ctx, http.MethodDelete, path.Join(w.dir, name), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("删除WebDAV文件失败: %s", resp.Status)
+	}
+	return nil
+}
+
+type webDAVMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const webDAVPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+func (w *webDAVDestination) List(ctx context.Context) ([]RemoteFile, error) {
+	body := strings.NewReader(webDAVPropfindBody)
+	resp, err := w.do(ctx, "PROPFIND", w.dir+"/", body, int64(body.Len()), map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("查询WebDAV目录失败: %s", resp.Status)
+	}
+	var ms webDAVMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("解析WebDAV响应失败: %v", err)
+	}
+	files := make([]RemoteFile, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		if len(r.Propstat) == 0 || r.Propstat[0].Prop.ResourceType.Collection != nil {
+			continue
+		}
+		href := r.Href
+		if u, err := url.Parse(href); err == nil {
+			href = u.Path
+		}
+		name := path.Base(strings.TrimRight(href, "/"))
+		prop := r.Propstat[0].Prop
+		var modTime int64
+		if t, err := http.ParseTime(prop.LastModified); err == nil {
+			modTime = t.Unix()
+		}
+		files = append(files, RemoteFile{Name: name, Size: prop.ContentLength, ModTime: modTime})
+	}
+	return files, nil
+}
@@ -27,6 +27,9 @@ type RefreshResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// 百度网盘的下载链接只能使用该UA访问
+const DEFAULTUA = "pan.baidu.com"
+
 // 全局HTTP客户端实例
 var cachedClients map[string]*Client = make(map[string]*Client, 0)
 var cachedClientsMutex sync.RWMutex
@@ -252,6 +255,18 @@ func (c *Client) GetFileDetail(ctx context.Context, fileId string, dlink int32)
 	return fileDetail.List[0], nil
 }
 
+// 获取文件的下载链接，dlink要带上access_token才能下载，只能使用DEFAULTUA访问
+func (c *Client) GetDownloadUrl(ctx context.Context, fileId string) (string, error) {
+	fsDetail, err := c.GetFileDetail(ctx, fileId, 1)
+	if err != nil {
+		return "", err
+	}
+	if fsDetail == nil || fsDetail.Dlink == "" {
+		return "", fmt.Errorf("获取百度网盘文件 %s 的下载链接失败", fileId)
+	}
+	return fmt.Sprintf("%s&access_token=%s", fsDetail.Dlink, c.accessToken), nil
+}
+
 func (c *Client) Mkdir(ctx context.Context, path string) error {
 	if path == "" {
 		return fmt.Errorf("路径不能为空")
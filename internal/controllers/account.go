@@ -3,6 +3,7 @@ package controllers
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"Q115-STRM/internal/helpers"
@@ -42,8 +43,12 @@ func GetAccountList(c *gin.Context) {
 		BaseUrl           string            `json:"base_url"`
 		AuthType          string            `json:"auth_type"`
 	}
+	ids := getAccessibleResourceIds(c, models.ResourceTypeAccount)
 	resp := make([]accountResp, 0, len(accounts))
 	for _, account := range accounts {
+		if ids != nil && !slices.Contains(ids, account.ID) {
+			continue
+		}
 		a := accountResp{
 			ID:                account.ID,
 			SourceType:        account.SourceType,
@@ -163,9 +168,45 @@ func DeleteAccount(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
 		return
 	}
+	models.DeleteResourceGrants(models.ResourceTypeAccount, account.ID)
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "删除开放平台账号成功", Data: nil})
 }
 
+// SetAccountMaxStreams 设置账号的最大同时播放数
+// @Summary 设置账号最大同时播放数
+// @Description 设置网盘账号的最大同时播放数，超过时拒绝新的播放，0表示不限制
+// @Tags 账号管理
+// @Accept json
+// @Produce json
+// @Param id body integer true "账号ID"
+// @Param max_streams body integer true "最大同时播放数"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /account/max-streams [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func SetAccountMaxStreams(c *gin.Context) {
+	type maxStreamsReq struct {
+		ID         uint `json:"id" form:"id"`
+		MaxStreams int  `json:"max_streams" form:"max_streams"`
+	}
+	req := &maxStreamsReq{}
+	if err := c.ShouldBind(req); err != nil || req.MaxStreams < 0 {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "请求参数错误", Data: nil})
+		return
+	}
+	account, err := models.GetAccountById(req.ID)
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "查询开放平台账号失败", Data: nil})
+		return
+	}
+	if err := account.UpdateMaxStreams(req.MaxStreams); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "保存最大同时播放数失败: " + err.Error(), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "保存最大同时播放数成功", Data: account})
+}
+
 // CreateOpenListAccount 创建或更新OpenList账号
 // @Summary 创建/更新OpenList账号
 // @Description 创建新的OpenList账号或更新现有账号的凭证，支持直接使用Token认证
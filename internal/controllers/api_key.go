@@ -4,6 +4,7 @@ import (
 	"Q115-STRM/internal/models"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -11,7 +12,8 @@ import (
 
 // CreateAPIKeyRequest 创建API Key请求
 type CreateAPIKeyRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes"` // 权限范围，为空表示和当前用户的角色一致
 }
 
 // CreateAPIKeyResponse 创建API Key响应
@@ -22,6 +24,7 @@ type CreateAPIKeyResponse struct {
 	KeyPrefix string `json:"key_prefix"` // 前缀用于显示
 	CreatedAt int64  `json:"created_at"`
 	IsActive  bool   `json:"is_active"`
+	Scopes    string `json:"scopes"`
 }
 
 // APIKeyListItem API Key列表项（不包含完整密钥）
@@ -32,6 +35,7 @@ type APIKeyListItem struct {
 	LastUsedAt int64  `json:"last_used_at"`
 	CreatedAt  int64  `json:"created_at"`
 	IsActive   bool   `json:"is_active"`
+	Scopes     string `json:"scopes"`
 }
 
 // CreateAPIKey 创建新的API Key
@@ -41,11 +45,11 @@ type APIKeyListItem struct {
 // @Accept json
 // @Produce json
 // @Param name body string true "API密钥名称"
+// @Param scopes body []string false "权限范围，为空表示和当前用户的角色一致，不能超出当前用户的权限范围"
 // @Success 200 {object} object
 // @Failure 200 {object} object
 // @Router /api-key/create [post]
 // @Security JwtAuth
-// @Security ApiKeyAuth
 func CreateAPIKey(c *gin.Context) {
 	var req CreateAPIKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -54,13 +58,22 @@ func CreateAPIKey(c *gin.Context) {
 	}
 
 	// 获取当前登录用户
-	if LoginedUser == nil {
+	loginedUser := GetLoginedUser(c)
+	if loginedUser == nil {
 		c.JSON(http.StatusUnauthorized, APIResponse[any]{Code: BadRequest, Message: "用户未登录", Data: nil})
 		return
 	}
+	// API Key的权限范围不能超出当前用户角色的权限范围
+	scopes := getScopes(c)
+	for _, scope := range req.Scopes {
+		if !slices.Contains(scopes, scope) {
+			c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("当前用户没有权限范围：%s", scope), Data: nil})
+			return
+		}
+	}
 
 	// 创建API Key
-	apiKey, rawKey, err := models.CreateAPIKey(LoginedUser.ID, req.Name)
+	apiKey, rawKey, err := models.CreateAPIKey(loginedUser.ID, req.Name, req.Scopes)
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("创建API Key失败：%v", err), Data: nil})
 		return
@@ -74,6 +87,7 @@ func CreateAPIKey(c *gin.Context) {
 		KeyPrefix: apiKey.KeyPrefix,
 		CreatedAt: apiKey.CreatedAt,
 		IsActive:  apiKey.IsActive,
+		Scopes:    apiKey.Scopes,
 	}
 
 	c.JSON(http.StatusOK, APIResponse[CreateAPIKeyResponse]{
@@ -93,16 +107,16 @@ func CreateAPIKey(c *gin.Context) {
 // @Failure 200 {object} object
 // @Router /api-key/list [get]
 // @Security JwtAuth
-// @Security ApiKeyAuth
 func ListAPIKeys(c *gin.Context) {
 	// 获取当前登录用户
-	if LoginedUser == nil {
+	loginedUser := GetLoginedUser(c)
+	if loginedUser == nil {
 		c.JSON(http.StatusUnauthorized, APIResponse[any]{Code: BadRequest, Message: "用户未登录", Data: nil})
 		return
 	}
 
 	// 查询用户的API Keys
-	apiKeys, err := models.GetAPIKeysByUserID(LoginedUser.ID)
+	apiKeys, err := models.GetAPIKeysByUserID(loginedUser.ID)
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("查询API Keys失败：%v", err), Data: nil})
 		return
@@ -118,6 +132,7 @@ func ListAPIKeys(c *gin.Context) {
 			LastUsedAt: apiKey.LastUsedAt,
 			CreatedAt:  apiKey.CreatedAt,
 			IsActive:   apiKey.IsActive,
+			Scopes:     apiKey.Scopes,
 		})
 	}
 
@@ -139,10 +154,10 @@ func ListAPIKeys(c *gin.Context) {
 // @Failure 200 {object} object
 // @Router /api-key/delete/{id} [delete]
 // @Security JwtAuth
-// @Security ApiKeyAuth
 func DeleteAPIKey(c *gin.Context) {
 	// 获取当前登录用户
-	if LoginedUser == nil {
+	loginedUser := GetLoginedUser(c)
+	if loginedUser == nil {
 		c.JSON(http.StatusUnauthorized, APIResponse[any]{Code: BadRequest, Message: "用户未登录", Data: nil})
 		return
 	}
@@ -156,7 +171,7 @@ func DeleteAPIKey(c *gin.Context) {
 	}
 
 	// 删除API Key（确保只能删除自己的）
-	err = models.DeleteAPIKey(uint(id), LoginedUser.ID)
+	err = models.DeleteAPIKey(uint(id), loginedUser.ID)
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("删除API Key失败：%v", err), Data: nil})
 		return
@@ -186,10 +201,10 @@ type UpdateAPIKeyStatusRequest struct {
 // @Failure 200 {object} object
 // @Router /api-key/status/{id} [put]
 // @Security JwtAuth
-// @Security ApiKeyAuth
 func UpdateAPIKeyStatus(c *gin.Context) {
 	// 获取当前登录用户
-	if LoginedUser == nil {
+	loginedUser := GetLoginedUser(c)
+	if loginedUser == nil {
 		c.JSON(http.StatusUnauthorized, APIResponse[any]{Code: BadRequest, Message: "用户未登录", Data: nil})
 		return
 	}
@@ -210,7 +225,7 @@ func UpdateAPIKeyStatus(c *gin.Context) {
 	}
 
 	// 更新API Key状态（确保只能更新自己的）
-	err = models.UpdateAPIKeyStatus(uint(id), LoginedUser.ID, req.IsActive)
+	err = models.UpdateAPIKeyStatus(uint(id), loginedUser.ID, req.IsActive)
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("更新API Key状态失败：%v", err), Data: nil})
 		return
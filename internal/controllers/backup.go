@@ -22,16 +22,24 @@ type BackupCreateRequest struct {
 	Reason string `json:"reason"`
 }
 
+// 指定备份记录ID，或者指定备份目标ID和其中的文件名
 type BackupRestoreRequest struct {
-	RecordID uint `json:"record_id"`
+	RecordID      uint   `json:"record_id"`
+	DestinationID uint   `json:"destination_id"`
+	FileName      string `json:"file_name"`
 }
 
 type BackupConfigUpdateRequest struct {
-	BackupEnabled   int    `json:"backup_enabled"`
-	BackupCron      string `json:"backup_cron"`
-	BackupRetention int    `json:"backup_retention"`
-	BackupMaxCount  int    `json:"backup_max_count"`
-	BackupCompress  int    `json:"backup_compress"`
+	BackupEnabled         int    `json:"backup_enabled"`
+	BackupCron            string `json:"backup_cron"`
+	BackupRetention       int    `json:"backup_retention"`
+	BackupMaxCount        int    `json:"backup_max_count"`
+	BackupCompress        int    `json:"backup_compress"`
+	BackupEncrypt         int    `json:"backup_encrypt"`
+	BackupEncryptPassword string `json:"backup_encrypt_password"` // 为空时不修改
+	KeepDaily             int    `json:"keep_daily"`
+	KeepWeekly            int    `json:"keep_weekly"`
+	KeepMonthly           int    `json:"keep_monthly"`
 }
 
 func CreateBackup(c *gin.Context) {
@@ -203,12 +211,14 @@ func DownloadBackup(c *gin.Context) {
 
 func GetBackupConfig(c *gin.Context) {
 	service := models.GetBackupService()
-	config := service.GetBackupConfig()
+	config := *service.GetBackupConfig()
+	// 不返回加密密码，保存时为空表示不修改
+	config.BackupEncryptPassword = ""
 
 	c.JSON(http.StatusOK, APIResponse[models.BackupConfig]{
 		Code:    Success,
 		Message: "success",
-		Data:    *config,
+		Data:    config,
 	})
 }
 
@@ -238,6 +248,27 @@ func UpdateBackupConfig(c *gin.Context) {
 	if req.BackupCompress >= 0 {
 		config.BackupCompress = req.BackupCompress
 	}
+	if req.BackupEncryptPassword != "" {
+		config.BackupEncryptPassword = req.BackupEncryptPassword
+	}
+	if req.BackupEncrypt == 1 && config.BackupEncryptPassword == "" {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: "启用加密时必须设置加密密码",
+			Data:    nil,
+		})
+		return
+	}
+	config.BackupEncrypt = req.BackupEncrypt
+	if req.KeepDaily >= 0 {
+		config.KeepDaily = req.KeepDaily
+	}
+	if req.KeepWeekly >= 0 {
+		config.KeepWeekly = req.KeepWeekly
+	}
+	if req.KeepMonthly >= 0 {
+		config.KeepMonthly = req.KeepMonthly
+	}
 	config.BackupEnabled = req.BackupEnabled
 
 	if err := service.UpdateBackupConfig(config); err != nil {
@@ -284,10 +315,10 @@ func RestoreFromBackup(c *gin.Context) {
 		return
 	}
 
-	if req.RecordID == 0 {
+	if req.RecordID == 0 && (req.DestinationID == 0 || req.FileName == "") {
 		c.JSON(http.StatusOK, APIResponse[any]{
 			Code:    BadRequest,
-			Message: "请指定要恢复的备份记录ID",
+			Message: "请指定要恢复的备份记录ID或备份目标中的文件",
 			Data:    nil,
 		})
 		return
@@ -301,6 +332,28 @@ func RestoreFromBackup(c *gin.Context) {
 		return
 	}
 
+	if req.RecordID == 0 {
+		if _, err := models.GetBackupDestinationById(req.DestinationID); err != nil {
+			c.JSON(http.StatusOK, APIResponse[any]{
+				Code:    BadRequest,
+				Message: "备份目标不存在",
+				Data:    nil,
+			})
+			return
+		}
+		go func() {
+			if err := backup.RestoreFromDestination(req.DestinationID, req.FileName); err != nil {
+				helpers.AppLogger.Errorf("从备份目标恢复失败: %v", err)
+			}
+		}()
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    Success,
+			Message: "已触发数据恢复任务",
+			Data:    nil,
+		})
+		return
+	}
+
 	var record models.BackupRecord
 	if err := db.Db.First(&record, req.RecordID).Error; err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{
@@ -312,7 +365,9 @@ func RestoreFromBackup(c *gin.Context) {
 	}
 
 	go func() {
-		backup.Restore(record.FilePath)
+		if err := backup.RestoreFromRecord(&record); err != nil {
+			helpers.AppLogger.Errorf("从备份恢复失败: %v", err)
+		}
 	}()
 
 	c.JSON(http.StatusOK, APIResponse[any]{
@@ -335,10 +390,10 @@ func UploadAndRestore(c *gin.Context) {
 	defer file.Close()
 
 	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if ext != ".zip" {
+	if ext != ".zip" && ext != ".enc" {
 		c.JSON(http.StatusOK, APIResponse[any]{
 			Code:    BadRequest,
-			Message: "仅支持.zip格式的备份文件",
+			Message: "仅支持.zip或.zip.enc格式的备份文件",
 			Data:    nil,
 		})
 		return
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"Q115-STRM/internal/backup"
+	"Q115-STRM/internal/models"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getBackupDestinationFromParam(c *gin.Context) *models.BackupDestination {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: "无效的备份目标ID",
+			Data:    nil,
+		})
+		return nil
+	}
+	destination, err := models.GetBackupDestinationById(uint(id))
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: "备份目标不存在",
+			Data:    nil,
+		})
+		return nil
+	}
+	return destination
+}
+
+// GetBackupDestinations 获取所有备份目标，不返回密码
+func GetBackupDestinations(c *gin.Context) {
+	destinations := models.GetBackupDestinations()
+	for i := range destinations {
+		destinations[i].Password = ""
+	}
+	c.JSON(http.StatusOK, APIResponse[[]models.BackupDestination]{
+		Code:    Success,
+		Message: "success",
+		Data:    destinations,
+	})
+}
+
+// SaveBackupDestination 添加或修改备份目标，ID为0时添加
+func SaveBackupDestination(c *gin.Context) {
+	var req models.BackupDestination
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: "请求参数无效",
+			Data:    nil,
+		})
+		return
+	}
+	if req.ID > 0 {
+		existing, err := models.GetBackupDestinationById(req.ID)
+		if err != nil {
+			c.JSON(http.StatusOK, APIResponse[any]{
+				Code:    BadRequest,
+				Message: "备份目标不存在",
+				Data:    nil,
+			})
+			return
+		}
+		req.CreatedAt = existing.CreatedAt
+		// 没有传密码时保留原密码
+		if req.Password == "" {
+			req.Password = existing.Password
+		}
+	}
+	if err := models.SaveBackupDestination(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: fmt.Sprintf("保存备份目标失败: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+	// 不返回密码，保存时为空表示不修改
+	req.Password = ""
+	c.JSON(http.StatusOK, APIResponse[models.BackupDestination]{
+		Code:    Success,
+		Message: "备份目标已保存",
+		Data:    req,
+	})
+}
+
+// DeleteBackupDestination 删除备份目标，已上传的备份文件不会删除
+func DeleteBackupDestination(c *gin.Context) {
+	destination := getBackupDestinationFromParam(c)
+	if destination == nil {
+		return
+	}
+	if err := models.DeleteBackupDestination(destination.ID); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: fmt.Sprintf("删除备份目标失败: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{
+		Code:    Success,
+		Message: "备份目标已删除",
+		Data:    nil,
+	})
+}
+
+// TestBackupDestination 测试备份目标是否可以访问，使用请求中的配置，不需要先保存
+func TestBackupDestination(c *gin.Context) {
+	var req models.BackupDestination
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: "请求参数无效",
+			Data:    nil,
+		})
+		return
+	}
+	if req.ID > 0 && req.Password == "" {
+		if existing, err := models.GetBackupDestinationById(req.ID); err == nil {
+			req.Password = existing.Password
+		}
+	}
+	if err := backup.TestDestination(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: fmt.Sprintf("连接备份目标失败: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{
+		Code:    Success,
+		Message: "连接成功",
+		Data:    nil,
+	})
+}
+
+// GetBackupDestinationFiles 列出备份目标中的备份文件，用于从远程还原
+func GetBackupDestinationFiles(c *gin.Context) {
+	destination := getBackupDestinationFromParam(c)
+	if destination == nil {
+		return
+	}
+	files, err := backup.ListDestinationFiles(destination)
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: fmt.Sprintf("获取备份文件列表失败: %v", err),
+			Data:    nil,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[[]backup.RemoteFile]{
+		Code:    Success,
+		Message: "success",
+		Data:    files,
+	})
+}
+
+// GetBackupRecordUploads 获取备份记录上传到各个目标的结果
+func GetBackupRecordUploads(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{
+			Code:    BadRequest,
+			Message: "无效的备份记录ID",
+			Data:    nil,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[[]models.BackupUpload]{
+		Code:    Success,
+		Message: "success",
+		Data:    models.GetBackupUploads(uint(id)),
+	})
+}
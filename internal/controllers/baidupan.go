@@ -2,9 +2,10 @@ package controllers
 
 import (
 	"Q115-STRM/internal/baidupan"
-	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/linkresolver"
 	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/playproxy"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -205,6 +206,7 @@ func GetBaiduPanUrlByPickCode(c *gin.Context) {
 		UserId   string `json:"userid" form:"userid"`
 		PickCode string `json:"pickcode" form:"pickcode"`
 		Force    int    `json:"force" form:"force"`
+		strmSignReq
 	}
 	var req fileIdReq
 	if err := c.ShouldBind(&req); err != nil {
@@ -213,64 +215,30 @@ func GetBaiduPanUrlByPickCode(c *gin.Context) {
 	}
 	pickCode := req.PickCode
 	userId := req.UserId
-	var account *models.Account
-	if userId == "" {
-		// 查询SyncFile
-		syncFile := models.GetFileByPickCode(pickCode)
-		if syncFile == nil {
-			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "文件PickCode不存在", Data: nil})
-			return
-		}
-		var err error
-		account, err = models.GetAccountById(syncFile.AccountId)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "账号ID不存在", Data: nil})
-			return
-		}
-	} else {
-		var err error
-		// 通过userId查询账号
-		account, err = models.GetAccountByUserId(userId)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "用户ID不存在", Data: nil})
-			return
-		}
+	if !checkStrmSign(c, pickCode, userId, req.strmSignReq) {
+		return
 	}
-	ua := c.Request.UserAgent()
-	client := account.GetBaiDuPanClient()
-	cacheKey := fmt.Sprintf("baidupanurl:%s, ua=%s", pickCode, ua)
-	if keyLock.LockWithTimeout(cacheKey, 10*time.Second) {
-		defer keyLock.Unlock(cacheKey)
-		cachedUrl := string(db.Cache.Get(cacheKey))
-		if cachedUrl == "" {
-			fsDetail, err := client.GetFileDetail(context.Background(), pickCode, 1)
-			if err != nil {
-				c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取百度网盘文件详情失败", Data: nil})
-				return
-			}
-			cachedUrl = fmt.Sprintf("%s&access_token=%s", fsDetail.Dlink, account.Token)
-			if cachedUrl == "" {
-				c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取百度网盘下载链接失败", Data: nil})
-				return
-			}
-			helpers.AppLogger.Infof("从接口中查询到百度网盘下载链接: %s => %s", pickCode, cachedUrl)
-			// 缓存8小时
-			db.Cache.Set(cacheKey, []byte(cachedUrl), 27000)
-		} else {
-			helpers.AppLogger.Infof("从缓存中查询到百度网盘下载链接: %s => %s", pickCode, cachedUrl)
-		}
-		// 检查是否开启了本地播放代理，如果开启则跳转到代理链接
-		// if models.SettingsGlobal.LocalProxy == 1 {
-		// 跳转到本地代理
-		proxyUrl := fmt.Sprintf("/proxy-115?baidupan=1&url=%s", url.QueryEscape(cachedUrl))
-		helpers.AppLogger.Infof("通过本地代理访问百度网盘下载链接播放: %s", url.QueryEscape(cachedUrl))
-		c.Redirect(http.StatusFound, proxyUrl)
+	account, err := linkresolver.GetAccount(pickCode, userId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
 		return
-		// } else {
-		// 	helpers.AppLogger.Infof("302重定向到百度网盘下载链接播放: %s", url.QueryEscape(cachedUrl))
-		// 	c.Redirect(http.StatusFound, cachedUrl)
-		// 	return
-		// }
 	}
-
+	// 文件失效时自动使用其他网盘或者其他账号中的副本
+	link, err := linkresolver.GetLink(context.Background(), account, pickCode, c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	cachedUrl := link.URL
+	if link.Account.SourceType == models.SourceType115 {
+		// 115下载链接和客户端的UA绑定，不能通过本地代理播放
+		helpers.AppLogger.Infof("302重定向到115副本的下载链接: %s", cachedUrl)
+		c.Redirect(http.StatusFound, cachedUrl)
+		return
+	}
+	pickCode = link.PickCode
+	// 跳转到本地代理
+	proxyUrl := playproxy.ProxyPath(playproxy.SourceBaiduPan, pickCode, cachedUrl)
+	helpers.AppLogger.Infof("通过本地代理访问百度网盘下载链接播放: %s", url.QueryEscape(cachedUrl))
+	c.Redirect(http.StatusFound, proxyUrl)
 }
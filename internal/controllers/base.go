@@ -3,13 +3,14 @@ package controllers
 import (
 	"Q115-STRM/internal/helpers"
 	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/playproxy"
+	"Q115-STRM/internal/playsession"
 	"Q115-STRM/internal/v115open"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"slices"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -47,9 +48,9 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 				// 获取关联的用户信息
 				user, err := models.GetUserById(apiKeyModel.UserID)
 				if err == nil && user != nil {
-					LoginedUser = user
-					// 将用户名保存到上下文
-					c.Set("username", user.Username)
+					// 将用户和权限范围保存到上下文
+					setLoginedUser(c, user, apiKeyModel.GetEffectiveScopes(user))
+					c.Set("api_key_id", apiKeyModel.ID)
 					// 异步更新最后使用时间
 					go func() {
 						apiKeyModel.UpdateLastUsedAt()
@@ -93,17 +94,103 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 			return
 		}
 		// helpers.AppLogger.Debugf("Authenticated user: %s", loginUser.Username)
-		LoginedUser, err = models.GetUserById(loginUser.ID)
+		user, err := models.GetUserById(loginUser.ID)
 		if err != nil {
 			c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("获取用户信息失败：%v", err), Data: nil})
 			c.Abort()
 			return
-		} else {
-			// helpers.AppLogger.Debugf("获取用户信息成功: %+v", LoginedUser)
 		}
-		// 将当前请求的username信息保存到请求的上下文c上
-		c.Set("username", loginUser.Username)
-		c.Next() // 后续的处理函数可以用过c.Get("username")来获取当前请求的用户信息
+		// 将当前请求的用户信息保存到请求的上下文c上
+		setLoginedUser(c, user, user.GetScopes())
+		c.Next() // 后续的处理函数可以用过GetLoginedUser(c)来获取当前请求的用户信息
+	}
+}
+
+// 保存当前请求的用户和权限范围
+func setLoginedUser(c *gin.Context, user *models.User, scopes []string) {
+	c.Set("username", user.Username)
+	c.Set("user", user)
+	c.Set("scopes", scopes)
+}
+
+// GetLoginedUser 获取当前请求的登录用户，未登录返回nil
+func GetLoginedUser(c *gin.Context) *models.User {
+	if user, ok := c.Get("user"); ok {
+		return user.(*models.User)
+	}
+	return nil
+}
+
+// 当前请求实际拥有的权限范围，使用API Key访问时是API Key和用户角色的交集
+func getScopes(c *gin.Context) []string {
+	scopes, ok := c.Get("scopes")
+	if !ok {
+		return nil
+	}
+	return scopes.([]string)
+}
+
+// 当前请求是否拥有权限范围
+func hasScope(c *gin.Context, scope string) bool {
+	return slices.Contains(getScopes(c), scope)
+}
+
+// RequireScope 要求当前请求拥有指定权限范围的中间件，需要放在JWTAuthMiddleware之后
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasScope(c, scope) {
+			c.JSON(http.StatusForbidden, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("没有权限，需要权限范围：%s", scope), Data: nil})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireLogin 要求当前请求使用账号登录的Token访问，使用API Key访问时拒绝，需要放在JWTAuthMiddleware之后
+// 用于API Key管理等不能交给API Key自己操作的接口
+func RequireLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key_id"); ok {
+			c.JSON(http.StatusForbidden, APIResponse[any]{Code: BadRequest, Message: "不能使用API Key访问，请登录后操作", Data: nil})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 检查当前用户是否被授权访问资源，没有权限会直接返回403，调用方只需要return
+func checkResourceAccess(c *gin.Context, resourceType models.ResourceType, resourceId uint) bool {
+	user := GetLoginedUser(c)
+	if user != nil && user.CanAccessResource(resourceType, resourceId) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, APIResponse[any]{Code: BadRequest, Message: "没有权限访问该资源", Data: nil})
+	return false
+}
+
+// 当前用户可以访问的某类资源的ID，返回nil表示可以访问全部
+func getAccessibleResourceIds(c *gin.Context, resourceType models.ResourceType) []uint {
+	user := GetLoginedUser(c)
+	if user == nil {
+		return []uint{}
+	}
+	ids, all := user.GetResourceIds(resourceType)
+	if all {
+		return nil
+	}
+	return ids
+}
+
+// 非管理员新建的资源自动授权给自己
+func grantCreatedResource(c *gin.Context, resourceType models.ResourceType, resourceId uint) {
+	user := GetLoginedUser(c)
+	if user == nil || user.IsAdmin() {
+		return
+	}
+	if err := models.GrantUserResource(user.ID, resourceType, resourceId); err != nil {
+		helpers.AppLogger.Errorf("给用户 %s 授权资源 %s:%d 失败: %v", user.Username, resourceType, resourceId, err)
 	}
 }
 
@@ -124,55 +211,41 @@ func ValidateJWT(tokenString string) (*LoginUser, error) {
 	return claims, nil
 }
 
+// 反代网盘下载链接，只允许白名单中的域名，分块并发下载并缓存到磁盘
+// pickcode用作缓存key，baidupan=1表示百度网盘的下载链接
 func Proxy115(c *gin.Context) {
-	// 获取原始url参数
-	target := c.Request.URL.Query().Get("url")
-	baidupan := c.Request.URL.Query().Get("baidupan")
+	target := c.Query("url")
 	if target == "" {
 		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "缺少url参数", Data: nil})
 		return
 	}
-	helpers.AppLogger.Infof("反代网盘下载链接: %s", target)
-	// // 只允许反代 cdnfhnfile.115cdn.net 域名
-	// if !strings.HasPrefix(target, "https://cdnfhnfile.115cdn.net/") {
-	// 	c.JSON(http.StatusForbidden, APIResponse[any]{Code: BadRequest, Message: "只允许反代115CDN链接", Data: nil})
-	// 	return
-	// }
-	// 创建请求
-	req, err := http.NewRequest("GET", target, nil)
-	if err != nil {
-		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "请求创建失败", Data: nil})
-		return
+	req := &playproxy.Request{
+		URL:       target,
+		Source:    playproxy.Source115,
+		Key:       c.Query("pickcode"),
+		UserAgent: v115open.DEFAULTUA,
 	}
-	// 复制客户端的 Range、Cookie、Referer 等头部
-	for k, v := range c.Request.Header {
-		if k == "Range" || k == "Cookie" || k == "Referer" {
-			// helpers.AppLogger.Infof("响应头: %s=%s", k, v)
-			req.Header[k] = v
-		}
+	if c.Query("baidupan") != "" {
+		req.Source = playproxy.SourceBaiduPan
+		req.UserAgent = "pan.baidu.com"
 	}
-	if baidupan != "" {
-		req.Header.Set("User-Agent", "pan.baidu.com")
-	} else {
-		req.Header.Set("User-Agent", v115open.DEFAULTUA)
+	if !playproxy.IsAllowedURL(req.Source, target) {
+		helpers.AppLogger.Warnf("拒绝反代不在白名单中的链接: %s", target)
+		c.JSON(http.StatusForbidden, APIResponse[any]{Code: BadRequest, Message: "只允许反代网盘的下载链接", Data: nil})
+		return
 	}
-	// 发起请求
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusBadGateway, APIResponse[any]{Code: BadRequest, Message: "反代请求失败: " + err.Error(), Data: nil})
+	// 缓存按pickcode保存，pickcode和下载链接必须是获取下载链接时签名的组合
+	if !playproxy.Verify(req.Source, req.Key, target, c.Query("sign")) {
+		helpers.AppLogger.Warnf("拒绝签名无效的反代请求: pickcode=%s", req.Key)
+		c.JSON(http.StatusForbidden, APIResponse[any]{Code: BadRequest, Message: "反代链接签名无效", Data: nil})
 		return
 	}
-	defer resp.Body.Close()
-	// 复制响应头
-	for k, v := range resp.Header {
-		for _, vv := range v {
-			c.Header(k, vv)
-		}
+	helpers.AppLogger.Infof("反代网盘下载链接: %s", target)
+	// 流量记录到跳转时登记的播放会话
+	w := playsession.GetManager().Writer(c.Writer, playsession.RedirectKey(req.Key, c.ClientIP()))
+	if err := playproxy.GetProxy().Serve(w, c.Request, req); err != nil {
+		c.JSON(http.StatusBadGateway, APIResponse[any]{Code: BadRequest, Message: "反代请求失败: " + err.Error(), Data: nil})
 	}
-	c.Status(resp.StatusCode)
-	// 复制响应内容
-	_, _ = io.Copy(c.Writer, resp.Body)
 }
 
 func Cors() gin.HandlerFunc {
@@ -202,8 +275,8 @@ func Cors() gin.HandlerFunc {
 			c.Set("content-type", "application/json")                                                                                                                                                              // 设置返回格式是json
 		}
 
-		// 放行所有OPTIONS方法
-		if method == "OPTIONS" {
+		// 放行所有OPTIONS方法，WebDAV的OPTIONS需要返回DAV头，交给WebDAV处理
+		if method == "OPTIONS" && !strings.HasPrefix(c.Request.URL.Path, WebDavPrefix) {
 			c.JSON(http.StatusOK, "Options Request!")
 		}
 		// 处理请求
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(apiKey bool) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if apiKey {
+				c.Set("api_key_id", uint(1))
+			}
+		})
+		r.DELETE("/api-keys/:id", RequireLogin(), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return r
+	}
+	cases := []struct {
+		name   string
+		apiKey bool
+		want   int
+	}{
+		{"登录Token", false, http.StatusOK},
+		{"API Key", true, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		newRouter(c.apiKey).ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api-keys/2", nil))
+		if w.Code != c.want {
+			t.Errorf("%s: status = %d; want %d", c.name, w.Code, c.want)
+		}
+	}
+}
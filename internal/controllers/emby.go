@@ -2,14 +2,13 @@ package controllers
 
 import (
 	"Q115-STRM/internal/emby"
-	embyclientrestgo "Q115-STRM/internal/embyclient-rest-go"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/mediaserver"
 	"Q115-STRM/internal/models"
 	"Q115-STRM/internal/notification"
 	"Q115-STRM/internal/notificationmanager"
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -23,36 +22,6 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type EmbyEvent struct {
-	Title    string `json:"Title"`
-	Date     string `json:"Date"`
-	Event    string `json:"Event"`
-	Severity string `json:"Severity"`
-	Server   struct {
-		Name    string `json:"Name"`
-		ID      string `json:"Id"`
-		Version string `json:"Version"`
-	} `json:"Server"`
-	Item struct {
-		Name              string            `json:"Name"`
-		ID                string            `json:"Id"`
-		Type              string            `json:"Type"`
-		IsFolder          bool              `json:"IsFolder"`
-		FileName          string            `json:"FileName"`
-		Path              string            `json:"Path"`
-		Overview          string            `json:"Overview"`
-		SeriesName        string            `json:"SeriesName"`
-		SeasonName        string            `json:"SeasonName"`
-		SeriesId          string            `json:"SeriesId"`
-		SeasonId          string            `json:"SeasonId"`
-		IndexNumber       int               `json:"IndexNumber"`
-		ParentIndexNumber int               `json:"ParentIndexNumber"`
-		ProductionYear    int               `json:"ProductionYear"`
-		Genres            []string          `json:"Genres"`
-		ImageTags         map[string]string `json:"ImageTags"`
-	} `json:"Item"`
-}
-
 var refreshLibraryLock bool = false
 var refreshLibraryLockMu = sync.Mutex{}
 
@@ -78,23 +47,33 @@ var playbackEventCacheMu = sync.Mutex{}
 var newSeriesBufferTickerStarted bool = false
 var newSeriesBufferTickerStartedMu = sync.Mutex{}
 
-// Webhook Emby事件回调（公开接口）
-// @Summary Emby Webhook
-// @Description 接收Emby的事件回调（library.new）并触发通知/元数据提取
+// Webhook 媒体服务器事件回调（公开接口）
+// @Summary 媒体服务器Webhook
+// @Description 接收Emby、Jellyfin（jellyfin-plugin-webhook）或Plex的事件回调（入库、删除、播放）并触发通知/元数据提取/联动删除
+// @Description Plex以multipart/form-data的payload字段发送，其余以JSON请求体发送
 // @Tags Emby管理
 // @Accept json
+// @Accept multipart/form-data
 // @Produce json
+// @Param api_key query string false "启用鉴权时必填的API Key"
 // @Success 200 {object} object
 // @Failure 200 {object} object
 // @Router /emby/webhook [post]
 func Webhook(ctx *gin.Context) {
 	// 将请求的body内容完整打印到日志
 	var body []byte
-	if ctx.Request.Body != nil {
+	if strings.HasPrefix(ctx.ContentType(), "multipart/form-data") {
+		// Plex的事件在payload字段中
+		if payload := ctx.PostForm("payload"); payload != "" {
+			body = []byte(payload)
+		}
+	} else if ctx.Request.Body != nil {
 		body, _ = io.ReadAll(ctx.Request.Body)
-		helpers.AppLogger.Infof("emby webhook body: %s", string(body))
 	}
-	if body == nil || (models.GlobalEmbyConfig != nil && (models.GlobalEmbyConfig.EmbyUrl == "" || models.GlobalEmbyConfig.EmbyApiKey == "")) {
+	if body != nil {
+		helpers.AppLogger.Infof("media server webhook body: %s", string(body))
+	}
+	if body == nil || models.GlobalEmbyConfig == nil || models.GlobalEmbyConfig.EmbyUrl == "" || models.GlobalEmbyConfig.EmbyApiKey == "" {
 		ctx.JSON(http.StatusOK, gin.H{
 			"message": "webhook",
 		})
@@ -123,44 +102,51 @@ func Webhook(ctx *gin.Context) {
 		}
 	}
 
-	// 处理 body内容，解析成json
-	var event EmbyEvent
+	// 按媒体服务器类型解析成统一格式的事件
+	server, err := models.GlobalEmbyConfig.NewMediaServer()
+	if err != nil {
+		helpers.AppLogger.Errorf("创建媒体服务器客户端失败: %v", err)
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "webhook",
+		})
+		return
+	}
 	// 如果解析失败，记录错误日志并返回
-	err := json.Unmarshal(body, &event)
+	event, err := server.ParseWebhook(body)
 	if err != nil {
-		helpers.AppLogger.Errorf("emby webhook bind json error: %v", err)
+		helpers.AppLogger.Errorf("%s webhook bind json error: %v", server.Type().DisplayName(), err)
 		ctx.JSON(http.StatusOK, gin.H{
 			"message": "webhook",
 		})
 		return
 	}
-	if event.Event == "library.new" {
+	serverName := server.Type().DisplayName()
+	if event.Event == mediaserver.EventLibraryNew {
 		// 新入库通知
 		// 如果是Episode就先存起来，等待10s，如果后续有通series的library.new事件就合并通知
 		// 触发通知
 		go func() {
-			if event.Item.Type == "Episode" {
-				addItemToEpisodeBuffer(event.Item.SeriesId, event.Item.ParentIndexNumber, event.Item.IndexNumber)
+			if event.Item.Type == mediaserver.ItemTypeEpisode {
+				addItemToEpisodeBuffer(event.Item.SeriesId, event.Item.SeasonNumber, event.Item.EpisodeNumber)
 				return
 			}
-			if event.Item.Type == "Movie" {
-				sendNewMovieNotification(event.Item.ID)
+			if event.Item.Type == mediaserver.ItemTypeMovie {
+				sendNewMovieNotification(event.Item.Id)
 			}
 
 		}()
-		if event.Item.Type == "Movie" || event.Item.Type == "Episode" {
-			// 触发媒体信息提取
-			if models.GlobalEmbyConfig != nil && models.GlobalEmbyConfig.EnableExtractMediaInfo == 1 {
+		if event.Item.Type == mediaserver.ItemTypeMovie || event.Item.Type == mediaserver.ItemTypeEpisode {
+			// 触发媒体信息提取，依赖Emby的PlaybackInfo接口，只支持Emby
+			if models.GlobalEmbyConfig.EnableExtractMediaInfo == 1 && models.GlobalEmbyConfig.IsEmby() {
 				go func() {
 					// 获取Emby地址和Emby Api Key
-					url := fmt.Sprintf("%s/emby/Items/%s/PlaybackInfo?api_key=%s", models.GlobalEmbyConfig.EmbyUrl, event.Item.ID, models.GlobalEmbyConfig.EmbyApiKey)
-					models.AddDownloadTaskFromEmbyMedia(url, event.Item.ID, event.Item.Name)
-					if err != nil {
-						helpers.AppLogger.Errorf("触发Emby信息提取失败 错误: %v", err)
+					url := fmt.Sprintf("%s/emby/Items/%s/PlaybackInfo?api_key=%s", models.GlobalEmbyConfig.EmbyUrl, event.Item.Id, models.GlobalEmbyConfig.EmbyApiKey)
+					if task := models.AddDownloadTaskFromEmbyMedia(url, event.Item.Id, event.Item.Name); task == nil {
+						helpers.AppLogger.Errorf("触发Emby信息提取失败 ItemID: %s", event.Item.Id)
 					}
 				}()
 			} else {
-				helpers.AppLogger.Infof("Emby媒体信息提取功能未启用，跳过媒体信息提取")
+				helpers.AppLogger.Infof("媒体信息提取功能未启用或当前媒体服务器不是Emby，跳过媒体信息提取")
 			}
 		}
 		// 1分钟后同步一次Emby媒体库
@@ -178,58 +164,58 @@ func Webhook(ctx *gin.Context) {
 				refreshLibraryLockMu.Unlock()
 			}()
 			time.Sleep(1 * time.Minute)
-			emby.IncrementalSyncEmbyMediaItems(event.Item.ID)
+			emby.IncrementalSyncEmbyMediaItems(event.Item.Id)
 		}()
 	}
-	if event.Event == "library.deleted" {
+	if event.Event == mediaserver.EventLibraryDeleted {
 		// 删除媒体通知
 		if helpers.IsRelease {
-			helpers.AppLogger.Infof("Emby媒体已删除 %+v", event.Item)
+			helpers.AppLogger.Infof("%s媒体已删除 %+v", serverName, event.Item)
 		}
 		// 触发通知
 		// 删除消息也应该按照新入库消息一样对剧集进行分组
 		go func() {
-			if event.Item.Type == "Episode" {
-				addItemToDeletedEpisodeBuffer(event.Item.SeriesId, event.Item.ParentIndexNumber, event.Item.IndexNumber, event.Item.SeriesName)
+			if event.Item.Type == mediaserver.ItemTypeEpisode {
+				addItemToDeletedEpisodeBuffer(event.Item.SeriesId, event.Item.SeasonNumber, event.Item.EpisodeNumber, event.Item.SeriesName)
 				return
 			}
-			if event.Item.Type == "Movie" {
-				sendDeletedMovieNotification(event.Item.ID, event.Item.Name)
+			if event.Item.Type == mediaserver.ItemTypeMovie {
+				sendDeletedMovieNotification(event.Item.Id, event.Item.Name)
 			}
 		}()
-		if event.Item.Type == "Movie" || event.Item.Type == "Episode" || event.Item.Type == "Season" || event.Item.Type == "Series" {
+		if event.Item.Type == mediaserver.ItemTypeMovie || event.Item.Type == mediaserver.ItemTypeEpisode || event.Item.Type == mediaserver.ItemTypeSeason || event.Item.Type == mediaserver.ItemTypeSeries {
 			// 触发联动删除
-			if models.GlobalEmbyConfig != nil && models.GlobalEmbyConfig.EnableDeleteNetdisk == 1 {
+			if models.GlobalEmbyConfig.EnableDeleteNetdisk == 1 {
 				// 检查是否允许删除媒体库
 				// if !models.IsDeleteNetdiskLibraryEnabled(event.) {
 				// 	helpers.AppLogger.Infof("Emby媒体库 %s 未配置允许删除，跳过删除", event.Item.LibraryId)
 				// 	return
 				// }
 				switch event.Item.Type {
-				case "Movie":
+				case mediaserver.ItemTypeMovie:
 					// 电影：在网盘中将视频文件的父目录一起删除
 					// 查找Item.Id对应的SyncFileId
-					models.DeleteNetdiskMovieByEmbyItemId(event.Item.ID)
-				case "Episode":
+					models.DeleteNetdiskMovieByEmbyItemId(event.Item.Id)
+				case mediaserver.ItemTypeEpisode:
 					// 集：删除视频文件+元数据（nfo、封面)
 					// 查找Item.Id对应的SyncFileId
-					models.DeleteNetdiskEpisodeByEmbyItemId(event.Item.ID)
-				case "Season":
+					models.DeleteNetdiskEpisodeByEmbyItemId(event.Item.Id)
+				case mediaserver.ItemTypeSeason:
 					// 季：先检查视频文件的父目录，如果父目录是季文件夹则删除该文件夹；如果父目录是有tvshow的目录则仅删除季下所有集对应的视频文件+元数据（nfo、封面)
 					// 查找EmbyMediaItem.SeasonId = item.Id的记录，取其中一条的ItemId对应的SyncFileId的SyncFile.Path作为季目录来处理
-					models.DeleteNetdiskSeasonByItemId(event.Item.ID)
-				case "Series":
+					models.DeleteNetdiskSeasonByItemId(event.Item.Id)
+				case mediaserver.ItemTypeSeries:
 					// 剧：在网盘中将tvshow.nfo的父目录删除
 					// 查找EmbyMediaItem.SeriesId = item.Id的记录，取其中一条的ItemId对应的SyncFileId的SyncFile.Path作为季目录来处理
-					models.DeleteNetdiskTvshowByItemId(event.Item.ID)
+					models.DeleteNetdiskTvshowByItemId(event.Item.Id)
 				default:
 				}
 			}
 		}
 	}
 	// 处理播放事件（playback.start、playback.pause、playback.stop）
-	if event.Event == "playback.start" || event.Event == "playback.pause" || event.Event == "playback.stop" {
-		go handlePlaybackEvent(body, event)
+	if event.Event == mediaserver.EventPlaybackStart || event.Event == mediaserver.EventPlaybackPause || event.Event == mediaserver.EventPlaybackStop {
+		go handlePlaybackEvent(event)
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
@@ -378,7 +364,7 @@ var notificationTemplate = `
 func sendNewMovieNotification(itemId string) {
 	detail := emby.GetEmbyItemDetail(itemId)
 	if detail == nil {
-		helpers.AppLogger.Errorf("获取Emby媒体 %s 详情失败，无法发送新电影通知", itemId)
+		helpers.AppLogger.Errorf("获取媒体 %s 详情失败，无法发送新电影通知", itemId)
 		return
 	}
 	// 使用变量格式化通知内容
@@ -394,20 +380,8 @@ func sendNewMovieNotification(itemId string) {
 	}
 	// 拼接主演
 	actors := ""
-	if len(detail.People) > 0 {
-		actorNames := make([]string, 0)
-		// 计数
-		actorCount := 0
-		for _, person := range detail.People {
-			if person.Type == "Actor" {
-				actorNames = append(actorNames, person.Name)
-				actorCount++
-			}
-			if actorCount >= 5 {
-				break
-			}
-		}
-		actors = strings.Join(actorNames, ", ")
+	if len(detail.Actors) > 0 {
+		actors = strings.Join(detail.Actors[:min(len(detail.Actors), 5)], ", ")
 	} else {
 		actors = "暂无数据"
 	}
@@ -435,7 +409,7 @@ func sendNewMovieNotification(itemId string) {
 func sendNewSeriesNotification(seriesId string, seasons map[int][]int) {
 	detail := emby.GetEmbyItemDetail(seriesId)
 	if detail == nil {
-		helpers.AppLogger.Errorf("获取Emby媒体 %s 详情失败，无法发送新剧集通知", seriesId)
+		helpers.AppLogger.Errorf("获取媒体 %s 详情失败，无法发送新剧集通知", seriesId)
 		return
 	}
 	// 使用变量格式化通知内容
@@ -455,21 +429,8 @@ func sendNewSeriesNotification(seriesId string, seasons map[int][]int) {
 	}
 
 	// 拼接主演
-	actors := ""
-	if len(detail.People) > 0 {
-		actorNames := make([]string, 0)
-		// 计数
-		actorCount := 0
-		for _, person := range detail.People {
-			if person.Type == "Actor" {
-				actorNames = append(actorNames, person.Name)
-				actorCount++
-			}
-			if actorCount >= 5 {
-				break
-			}
-		}
-		actors = strings.Join(actorNames, ", ")
+	if len(detail.Actors) > 0 {
+		actors := strings.Join(detail.Actors[:min(len(detail.Actors), 5)], ", ")
 		content = strings.ReplaceAll(content, "{{actors}}", actors)
 	} else {
 		content = strings.ReplaceAll(content, "{{actors}}", "暂无数据")
@@ -493,30 +454,26 @@ func sendNewSeriesNotification(seriesId string, seasons map[int][]int) {
 	sendNewItemNotification(content, detail, "电视剧")
 }
 
-func sendNewItemNotification(content string, detail *embyclientrestgo.BaseItemDtoV2, mediaType string) {
+func sendNewItemNotification(content string, detail *mediaserver.ItemDetail, mediaType string) {
 	imagePath := ""
-	if detail.ImageTags != nil {
-		imageUrl := ""
-		// 检查是否有backdrop或者banner
-		if tag, ok := detail.ImageTags["backdrop"]; ok {
-			imageUrl = fmt.Sprintf("%s/emby/Items/%s/Images/Backdrop?tag=%s&api_key=%s", models.GlobalEmbyConfig.EmbyUrl, detail.Id, tag, models.GlobalEmbyConfig.EmbyApiKey)
-		} else if tag, ok := detail.ImageTags["Primary"]; ok {
-			imageUrl = fmt.Sprintf("%s/emby/Items/%s/Images/Primary?tag=%s&api_key=%s", models.GlobalEmbyConfig.EmbyUrl, detail.Id, tag, models.GlobalEmbyConfig.EmbyApiKey)
-		}
-		if imageUrl != "" {
-			// 将图片下载/tmp目录，作为通知图片
-			posterPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s.jpg", detail.Id))
-			derr := helpers.DownloadFile(imageUrl, posterPath, "Q115-STRM")
-			if derr != nil {
-				helpers.AppLogger.Errorf("下载Emby海报失败: %v", derr)
-			} else {
-				imagePath = posterPath
-			}
+	// 优先使用背景图，没有则使用海报
+	imageUrl := detail.BackdropImageUrl
+	if imageUrl == "" {
+		imageUrl = detail.PrimaryImageUrl
+	}
+	if imageUrl != "" {
+		// 将图片下载/tmp目录，作为通知图片
+		posterPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s.jpg", detail.Id))
+		derr := helpers.DownloadFile(imageUrl, posterPath, "Q115-STRM")
+		if derr != nil {
+			helpers.AppLogger.Errorf("下载海报失败: %v", derr)
+		} else {
+			imagePath = posterPath
 		}
 	}
 	notif := &models.Notification{
 		Type:      models.MediaAdded,
-		Title:     fmt.Sprintf("📚 Emby %s 入库通知", mediaType),
+		Title:     fmt.Sprintf("📚 %s %s 入库通知", models.GlobalEmbyConfig.GetServerType().DisplayName(), mediaType),
 		Content:   content,
 		Timestamp: time.Now(),
 		Priority:  models.NormalPriority,
@@ -540,7 +497,7 @@ func sendDeletedMovieNotification(itemId, itemName string) {
 	content := fmt.Sprintf("电影名称：%s\n⏰ 删除时间: %s", itemName, time.Now().Format("2006-01-02 15:04:05"))
 	notif := &models.Notification{
 		Type:      models.MediaRemoved,
-		Title:     "🗑️ Emby媒体删除通知",
+		Title:     fmt.Sprintf("🗑️ %s媒体删除通知", models.GlobalEmbyConfig.GetServerType().DisplayName()),
 		Content:   content,
 		Timestamp: time.Now(),
 		Priority:  models.NormalPriority,
@@ -560,7 +517,7 @@ func sendDeletedSeriesNotification(seriesId string, seriesName string, seasons m
 	content := fmt.Sprintf("电视剧名称：%s\n删除季集：%s\n⏰ 删除时间: %s", seriesName, seasonEpisodes, time.Now().Format("2006-01-02 15:04:05"))
 	notif := &models.Notification{
 		Type:      models.MediaRemoved,
-		Title:     "🗑️ Emby媒体删除通知",
+		Title:     fmt.Sprintf("🗑️ %s媒体删除通知", models.GlobalEmbyConfig.GetServerType().DisplayName()),
 		Content:   content,
 		Timestamp: time.Now(),
 		Priority:  models.NormalPriority,
@@ -633,14 +590,9 @@ func removeDuplicates(episodes []int) []int {
 	return result
 }
 
-// handlePlaybackEvent 处理 Emby 播放事件
-func handlePlaybackEvent(body []byte, event EmbyEvent) {
-	// 解析完整的播放事件数据
-	var playbackWebhook models.EmbyPlaybackWebhook
-	if err := json.Unmarshal(body, &playbackWebhook); err != nil {
-		helpers.AppLogger.Errorf("解析播放事件失败: %v", err)
-		return
-	}
+// handlePlaybackEvent 处理媒体服务器的播放事件
+func handlePlaybackEvent(event *mediaserver.WebhookEvent) {
+	playbackWebhook := newPlaybackWebhook(event)
 
 	// 检查去重（1分钟内不重复通知）
 	cacheKey := fmt.Sprintf("%s_%s_%s_%s_%s",
@@ -670,7 +622,7 @@ func handlePlaybackEvent(body []byte, event EmbyEvent) {
 	playbackEventCacheMu.Unlock()
 
 	// 构造并发送通知
-	notif := createPlaybackNotification(&playbackWebhook)
+	notif := createPlaybackNotification(playbackWebhook)
 	imagePath := notif.Image // 保存图片路径以便后续清理
 	if notificationmanager.GlobalEnhancedNotificationManager != nil {
 		if err := notificationmanager.GlobalEnhancedNotificationManager.SendNotification(context.Background(), notif); err != nil {
@@ -684,6 +636,30 @@ func handlePlaybackEvent(body []byte, event EmbyEvent) {
 	}
 }
 
+// newPlaybackWebhook 将统一格式的事件转换为播放通知使用的结构
+func newPlaybackWebhook(event *mediaserver.WebhookEvent) *models.EmbyPlaybackWebhook {
+	return &models.EmbyPlaybackWebhook{
+		Event: event.Event,
+		User:  models.EmbyPlaybackUser{Name: event.UserName, ID: event.UserId},
+		Item: models.EmbyPlaybackItem{
+			Name:            event.Item.Name,
+			Type:            event.Item.Type,
+			ProductionYear:  event.Item.ProductionYear,
+			SeriesName:      event.Item.SeriesName,
+			SeasonNumber:    event.Item.SeasonNumber,
+			EpisodeNumber:   event.Item.EpisodeNumber,
+			ID:              event.Item.Id,
+			PrimaryImageUrl: event.Item.PrimaryImageUrl,
+		},
+		Session: models.EmbyPlaybackSession{DeviceName: event.DeviceName, Client: event.Client},
+		PlaybackInfo: models.EmbyPlaybackInfo{
+			PositionTicks: event.PositionTicks,
+			PlaySessionId: event.PlaySessionId,
+			MediaSource:   models.EmbyMediaSource{RunTimeTicks: event.RunTimeTicks},
+		},
+	}
+}
+
 // createPlaybackNotification 构造播放通知
 func createPlaybackNotification(webhook *models.EmbyPlaybackWebhook) *notification.Notification {
 	// 构造通知内容
@@ -692,20 +668,13 @@ func createPlaybackNotification(webhook *models.EmbyPlaybackWebhook) *notificati
 
 	// 下载海报图片（如果有）
 	imagePath := ""
-	if webhook.Item.ImageTags != nil {
-		if tag, ok := webhook.Item.ImageTags["Primary"]; ok {
-			imageUrl := fmt.Sprintf("%s/emby/Items/%s/Images/Primary?tag=%s&api_key=%s",
-				models.GlobalEmbyConfig.EmbyUrl,
-				webhook.Item.ID,
-				tag,
-				models.GlobalEmbyConfig.EmbyApiKey)
-			posterPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s_playback.jpg", webhook.Item.ID))
-			derr := helpers.DownloadFile(imageUrl, posterPath, "QMediaSync")
-			if derr != nil {
-				helpers.AppLogger.Errorf("下载Emby海报失败: %v", derr)
-			} else {
-				imagePath = posterPath
-			}
+	if webhook.Item.PrimaryImageUrl != "" {
+		posterPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s_playback.jpg", webhook.Item.ID))
+		derr := helpers.DownloadFile(webhook.Item.PrimaryImageUrl, posterPath, "QMediaSync")
+		if derr != nil {
+			helpers.AppLogger.Errorf("下载海报失败: %v", derr)
+		} else {
+			imagePath = posterPath
 		}
 	}
 
@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/mediaserver"
 	"Q115-STRM/internal/models"
 	"Q115-STRM/internal/synccron"
 	"net/http"
@@ -43,6 +45,7 @@ func GetEmbyConfig(c *gin.Context) {
 }
 
 type updateEmbyConfigRequest struct {
+	ServerType              string `json:"server_type"`
 	EmbyUrl                 string `json:"emby_url"`
 	EmbyApiKey              string `json:"emby_api_key"`
 	EnableDeleteNetdisk     int    `json:"enable_delete_netdisk"`
@@ -61,12 +64,13 @@ type updateEmbyConfigRequest struct {
 
 // UpdateEmbyConfig 更新Emby配置
 // @Summary 更新Emby配置
-// @Description 更新Emby媒体服务器的配置信息
+// @Description 更新媒体服务器（Emby、Jellyfin或Plex）的配置信息，切换服务器类型会清空已同步的媒体库数据
 // @Tags Emby管理
 // @Accept json
 // @Produce json
-// @Param emby_url body string false "Emby服务器地址"
-// @Param emby_api_key body string false "Emby API密钥"
+// @Param server_type body string false "媒体服务器类型：emby、jellyfin、plex，默认emby"
+// @Param emby_url body string false "媒体服务器地址"
+// @Param emby_api_key body string false "Emby/Jellyfin API密钥，Plex填写X-Plex-Token"
 // @Param enable_delete_netdisk body integer false "是否启用网盘删除"
 // @Param enable_refresh_library body integer false "是否启用库刷新"
 // @Param enable_media_notification body integer false "是否启用媒体通知"
@@ -85,6 +89,13 @@ func UpdateEmbyConfig(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "请求参数错误: " + err.Error()})
 		return
 	}
+	if req.ServerType == "" {
+		req.ServerType = string(mediaserver.ServerTypeEmby)
+	}
+	if !mediaserver.IsValidServerType(mediaserver.ServerType(req.ServerType)) {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "不支持的媒体服务器类型: " + req.ServerType})
+		return
+	}
 
 	config, err := models.GetEmbyConfig()
 	if err != nil && err != gorm.ErrRecordNotFound {
@@ -94,9 +105,11 @@ func UpdateEmbyConfig(c *gin.Context) {
 	isNew := err == gorm.ErrRecordNotFound
 	oldSyncEnabled := 0
 	oldSyncCron := req.SyncCron
+	oldServerType := mediaserver.ServerType(req.ServerType)
 	if !isNew {
 		oldSyncEnabled = config.SyncEnabled
 		oldSyncCron = config.SyncCron
+		oldServerType = config.GetServerType()
 	}
 	if isNew {
 		config = &models.EmbyConfig{}
@@ -104,6 +117,7 @@ func UpdateEmbyConfig(c *gin.Context) {
 	if req.SyncCron == "" {
 		req.SyncCron = "0 * * * *"
 	}
+	config.ServerType = req.ServerType
 	config.EmbyUrl = req.EmbyUrl
 	config.EmbyApiKey = req.EmbyApiKey
 	config.EnableDeleteNetdisk = req.EnableDeleteNetdisk
@@ -131,6 +145,13 @@ func UpdateEmbyConfig(c *gin.Context) {
 		return
 	}
 
+	if oldServerType != config.GetServerType() {
+		// 不同媒体服务器的媒体项ID不通用，清空已同步的数据
+		if err := models.CleanupAllEmbyLibraryData(); err != nil {
+			helpers.AppLogger.Warnf("切换媒体服务器后清理媒体库数据失败: %v", err)
+		}
+	}
+
 	if oldSyncEnabled != config.SyncEnabled || oldSyncCron != config.SyncCron {
 		// 同步状态改变，需要重新加载cron
 		synccron.InitCron()
@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"Q115-STRM/internal/emby"
-	embyclientrestgo "Q115-STRM/internal/embyclient-rest-go"
 	"Q115-STRM/internal/helpers"
 	"Q115-STRM/internal/models"
 	"net/http"
@@ -67,9 +66,9 @@ func GetEmbySyncStatus(c *gin.Context) {
 	})
 }
 
-// GetEmbyLibraries 获取所有可用的Emby媒体库
-// @Summary 获取Emby媒体库列表
-// @Description 获取所有可用的Emby媒体库列表
+// GetEmbyLibraries 获取所有可用的媒体服务器媒体库
+// @Summary 获取媒体库列表
+// @Description 获取已配置的媒体服务器（Emby、Jellyfin或Plex）中所有可用的媒体库列表
 // @Tags Emby管理
 // @Accept json
 // @Produce json
@@ -93,11 +92,15 @@ func GetEmbyLibraries(c *gin.Context) {
 		return
 	}
 
-	// 直接从 Emby 查询媒体库，并写入本地 emby_libraries 表
-	client := embyclientrestgo.NewClient(config.EmbyUrl, config.EmbyApiKey)
-	libs, err := client.GetAllMediaLibraries()
+	// 直接从媒体服务器查询媒体库，并写入本地 emby_libraries 表
+	server, err := config.NewMediaServer()
 	if err != nil {
-		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "查询Emby媒体库失败: " + err.Error()})
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error()})
+		return
+	}
+	libs, err := server.GetLibraries()
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "查询" + server.Type().DisplayName() + "媒体库失败: " + err.Error()})
 		return
 	}
 	if err := models.UpsertEmbyLibraries(libs); err != nil {
@@ -105,7 +108,7 @@ func GetEmbyLibraries(c *gin.Context) {
 		return
 	}
 
-	// 清理已不在Emby中存在的媒体库记录
+	// 清理已不在媒体服务器中存在的媒体库记录
 	activeLibraryIds := make([]string, 0, len(libs))
 	for _, lib := range libs {
 		activeLibraryIds = append(activeLibraryIds, lib.ID)
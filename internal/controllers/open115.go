@@ -3,15 +3,16 @@ package controllers
 import (
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/linkresolver"
 	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/playproxy"
+	"Q115-STRM/internal/playsession"
 	"Q115-STRM/internal/v115open"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -26,43 +27,6 @@ type v115StatusResp struct {
 	ExpireTime  string      `json:"expire_time"`
 }
 
-type KeyLockWithTimeout struct {
-	mutexes sync.Map // key -> *sync.Mutex
-	global  sync.Mutex
-}
-
-// LockWithTimeout 尝试获取锁，如果超时则返回 false
-func (kl *KeyLockWithTimeout) LockWithTimeout(key string, timeout time.Duration) bool {
-	kl.global.Lock()
-	mutex, _ := kl.mutexes.LoadOrStore(key, &sync.Mutex{})
-	kl.global.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return false // 超时
-		default:
-			if mutex.(*sync.Mutex).TryLock() {
-				return true // 成功获取锁
-			}
-			time.Sleep(10 * time.Millisecond) // 短暂等待后重试
-		}
-	}
-}
-
-func (kl *KeyLockWithTimeout) Unlock(key string) {
-	kl.global.Lock()
-	mutex, ok := kl.mutexes.Load(key)
-	kl.global.Unlock()
-
-	if ok {
-		mutex.(*sync.Mutex).Unlock()
-	}
-}
-
 // Get115Status 查询115账号状态
 // @Summary 查询115账号状态
 // @Description 获取指定115账号的登录状态及存储信息
@@ -134,7 +98,7 @@ func GetFileDetail(c *gin.Context) {
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "获取文件详情成功", Data: fullPath})
 }
 
-var keyLock KeyLockWithTimeout
+var keyLock helpers.KeyLockWithTimeout
 
 // Get115UrlByPickCode 查询115直链并重定向
 // @Summary 获取115文件直链
@@ -153,6 +117,7 @@ func Get115UrlByPickCode(c *gin.Context) {
 		UserId   string `json:"userid" form:"userid"`
 		PickCode string `json:"pickcode" form:"pickcode"`
 		Force    int    `json:"force" form:"force"`
+		strmSignReq
 	}
 	var req fileIdReq
 	if err := c.ShouldBind(&req); err != nil {
@@ -161,77 +126,83 @@ func Get115UrlByPickCode(c *gin.Context) {
 	}
 	pickCode := req.PickCode
 	userId := req.UserId
-	var account *models.Account
-	if userId == "" {
-		// 查询SyncFile
-		syncFile := models.GetFileByPickCode(pickCode)
-		if syncFile == nil {
-			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "文件PickCode不存在", Data: nil})
-			return
-		}
-		var err error
-		account, err = models.GetAccountById(syncFile.AccountId)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "账号ID不存在", Data: nil})
-			return
-		}
-		// helpers.AppLogger.Infof("通过PickCode查询到115账号: %s", account.Username)
-	} else {
-		var err error
-		// 通过userId查询账号
-		account, err = models.GetAccountByUserId(userId)
+	if !checkStrmSign(c, pickCode, userId, req.strmSignReq) {
+		return
+	}
+	account, err := linkresolver.GetAccount(pickCode, userId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	// 登记直链跳转的播放会话，超过账号的最大同时播放数时拒绝
+	sessionKey := playsession.RedirectKey(pickCode, c.ClientIP())
+	err = playsession.GetManager().Admit(playsession.Session{
+		Key:         sessionKey,
+		Kind:        playsession.KindRedirect,
+		AccountId:   account.ID,
+		AccountName: account.Name,
+		PickCode:    pickCode,
+		Client:      c.Request.UserAgent(),
+		Ip:          c.ClientIP(),
+	}, playsession.Limits{Account: account.MaxStreams})
+	if err != nil {
+		helpers.AppLogger.Warnf("拒绝播放 %s: %v", pickCode, err)
+		c.JSON(http.StatusTooManyRequests, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	ua := c.Request.UserAgent()
+	// helpers.AppLogger.Debugf("是否启用本地代理：%d", models.SettingsGlobal.LocalProxy)
+	if req.Force == 0 && models.SettingsGlobal.LocalProxy == 1 {
+		// 跳转到本地代理时使用统一的UA
+		ua = v115open.DEFAULTUA
+		helpers.AppLogger.Infof("因为直链标识=%d, 本地播放代理开关=%d，所以使用默认UA: %s", req.Force, models.SettingsGlobal.LocalProxy, ua)
+	}
+	// 文件失效时自动使用其他网盘或者其他账号中的副本
+	link, err := linkresolver.GetLink(context.Background(), account, pickCode, ua)
+	if err != nil {
+		// 没有播放成功，释放账号的播放数
+		playsession.GetManager().Remove(sessionKey)
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	if link.PickCode != pickCode {
+		// 播放会话改为实际播放的副本，本地代理的流量记录到副本的账号，副本账号同样要检查最大同时播放数
+		playsession.GetManager().Remove(sessionKey)
+		err = playsession.GetManager().Admit(playsession.Session{
+			Key:         playsession.RedirectKey(link.PickCode, c.ClientIP()),
+			Kind:        playsession.KindRedirect,
+			AccountId:   link.Account.ID,
+			AccountName: link.Account.Name,
+			PickCode:    link.PickCode,
+			Client:      c.Request.UserAgent(),
+			Ip:          c.ClientIP(),
+		}, playsession.Limits{Account: link.Account.MaxStreams})
 		if err != nil {
-			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "用户ID不存在", Data: nil})
+			helpers.AppLogger.Warnf("拒绝播放副本 %s: %v", link.PickCode, err)
+			c.JSON(http.StatusTooManyRequests, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
 			return
 		}
-		// helpers.AppLogger.Infof("通过用户ID查询到115账号: %s", account.Username)
 	}
-	ua := c.Request.UserAgent()
-	client := account.Get115Client()
-	// helpers.AppLogger.Infof("检查是否具有直链播放标记， force=%d", req.Force)
-	cacheKey := fmt.Sprintf("115url:%s, ua=%s", pickCode, ua)
-	// helpers.AppLogger.Infof("准备获取115文件下载链接: pickcode=%s, ua=%s，8095播放=%d 加锁10秒", pickCode, ua, req.Force)
-	if keyLock.LockWithTimeout(cacheKey, 10*time.Second) {
-		defer keyLock.Unlock(cacheKey)
-		// helpers.AppLogger.Debugf("是否启用本地代理：%d", models.SettingsGlobal.LocalProxy)
-		if req.Force == 0 && models.SettingsGlobal.LocalProxy == 1 {
-			// 跳转到本地代理时使用统一的UA
-			ua = v115open.DEFAULTUA
-			helpers.AppLogger.Infof("因为直链标识=%d, 本地播放代理开关=%d，所以使用默认UA: %s", req.Force, models.SettingsGlobal.LocalProxy, ua)
-		}
-		cachedUrl := string(db.Cache.Get(cacheKey))
-		if cachedUrl != "" {
-			helpers.AppLogger.Infof("从缓存中查询到115下载链接: pickcode=%s, ua=%s => %s", pickCode, ua, cachedUrl)
-			if !checkURLValidity(cachedUrl, ua) {
-				helpers.AppLogger.Infof("缓存链接已失效，删除缓存并重新获取: pickcode=%s", req.PickCode)
-				db.Cache.Delete(cacheKey)
-				cachedUrl = ""
-			}
-		}
-		if cachedUrl == "" {
-			cachedUrl = client.GetDownloadUrl(context.Background(), pickCode, ua, true)
-			if cachedUrl == "" {
-				c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取115下载链接失败", Data: nil})
-				return
-			}
-			helpers.AppLogger.Infof("从接口中查询到115下载链接: pickcode=%s, ua=%s => %s", pickCode, ua, cachedUrl)
-			// 缓存50分钟
-			db.Cache.Set(cacheKey, []byte(cachedUrl), 3000)
-		}
-		if req.Force == 0 {
-			if models.SettingsGlobal.LocalProxy == 1 {
-				// 跳转到本地代理
-				helpers.AppLogger.Infof("通过本地代理访问115下载链接，emby端口播放: %s", cachedUrl)
-				proxyUrl := fmt.Sprintf("/proxy-115?url=%s", url.QueryEscape(cachedUrl))
-				c.Redirect(http.StatusFound, proxyUrl)
-			} else {
-				helpers.AppLogger.Infof("302重定向到115下载链接，emby端口播放: %s", cachedUrl)
-				c.Redirect(http.StatusFound, cachedUrl)
-			}
+	cachedUrl := link.URL
+	if link.Account.SourceType == models.SourceTypeBaiduPan {
+		// 百度网盘的下载链接只能使用pan.baidu.com的UA访问，不能直接跳转，副本在百度网盘时总是走本地代理
+		helpers.AppLogger.Infof("通过本地代理访问百度网盘副本的下载链接: %s", cachedUrl)
+		c.Redirect(http.StatusFound, playproxy.ProxyPath(playproxy.SourceBaiduPan, link.PickCode, cachedUrl))
+		return
+	}
+	if req.Force == 0 {
+		if models.SettingsGlobal.LocalProxy == 1 {
+			// 跳转到本地代理
+			helpers.AppLogger.Infof("通过本地代理访问115下载链接，emby端口播放: %s", cachedUrl)
+			proxyUrl := playproxy.ProxyPath(playproxy.Source115, link.PickCode, cachedUrl)
+			c.Redirect(http.StatusFound, proxyUrl)
 		} else {
-			helpers.AppLogger.Infof("302重定向到115下载链接， 直链播放: %s", cachedUrl)
+			helpers.AppLogger.Infof("302重定向到115下载链接，emby端口播放: %s", cachedUrl)
 			c.Redirect(http.StatusFound, cachedUrl)
 		}
+	} else {
+		helpers.AppLogger.Infof("302重定向到115下载链接， 直链播放: %s", cachedUrl)
+		c.Redirect(http.StatusFound, cachedUrl)
 	}
 }
 
@@ -486,6 +457,7 @@ func GetQueueStats(c *gin.Context) {
 		"is_throttled":             throttleStatus.IsThrottled,
 		"throttled_elapsed_time":   throttleStatus.ElapsedTime.String(),
 		"throttled_remaining_time": throttleStatus.RemainingTime.String(),
+		"play_proxy":               playproxy.GetProxy().GetStats(), // 播放代理的缓存命中统计
 	}
 
 	c.JSON(http.StatusOK, APIResponse[gin.H]{Code: Success, Message: "获取队列统计数据成功", Data: responseData})
@@ -628,50 +600,3 @@ func CleanOldRequestStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: fmt.Sprintf("已清理 %d 天前的请求统计数据", req.Days), Data: nil})
 }
-
-// checkURLValidity 使用HEAD请求检查URL是否有效
-// 返回true表示URL有效（2xx状态码），false表示URL已失效
-// ua参数：必须使用当前请求的USER-AGENT访问115链接（否则返回403）
-func checkURLValidity(urlStr string, ua string) bool {
-	helpers.AppLogger.Infof("URL有效性检查开始: %s, UA=%s", urlStr, ua)
-	client := &http.Client{
-		Timeout: 3 * time.Second,
-		Transport: &http.Transport{
-			ResponseHeaderTimeout: 2 * time.Second, // 等待响应头的超时
-			DisableKeepAlives:     true,            // 禁用长连接，请求完立即关闭
-			TLSHandshakeTimeout:   1 * time.Second, // TLS握手超时
-			MaxIdleConns:          0,               // 不保持空闲连接
-		},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// 不跟随重定向，只检查第一次响应
-			return http.ErrUseLastResponse
-		},
-	}
-
-	req, err := http.NewRequest("HEAD", urlStr, nil)
-	if err != nil {
-		helpers.AppLogger.Errorf("创建HEAD请求失败: %v", err)
-		return false
-	}
-
-	// 设置User-Agent，这是关键！115链接必须使用请求时的UA
-	if ua != "" {
-		req.Header.Set("User-Agent", ua)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		helpers.AppLogger.Errorf("HEAD请求失败: %v", err)
-		return false
-	}
-	defer resp.Body.Close()
-
-	// 2xx状态码表示有效
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		helpers.AppLogger.Infof("URL有效性检查通过: 状态码=%d", resp.StatusCode)
-		return true
-	}
-
-	helpers.AppLogger.Infof("URL已失效: 状态码=%d", resp.StatusCode)
-	return false
-}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Open123StatusResp 123云盘状态响应
+type Open123StatusResp struct {
+	UserId     int64  `json:"user_id"`
+	Username   string `json:"username"`
+	UsedSpace  int64  `json:"used_space"`
+	TotalSpace int64  `json:"total_space"`
+	Vip        bool   `json:"vip"`
+}
+
+// SaveOpen123Account 创建或更新123云盘账号
+// @Summary 创建/更新123云盘账号
+// @Description 使用123云盘开放平台的clientID和clientSecret创建账号，会立即获取一次访问凭证验证是否有效
+// @Tags 账号管理
+// @Accept json
+// @Produce json
+// @Param id body integer false "账号ID（指定则为更新操作）"
+// @Param name body string false "账号备注"
+// @Param client_id body string true "开放平台clientID"
+// @Param client_secret body string true "开放平台clientSecret"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /account/123 [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func SaveOpen123Account(c *gin.Context) {
+	type saveOpen123AccountReq struct {
+		Id           uint   `json:"id" form:"id"`
+		Name         string `json:"name" form:"name"`
+		ClientId     string `json:"client_id" form:"client_id"`
+		ClientSecret string `json:"client_secret" form:"client_secret"`
+	}
+	req := &saveOpen123AccountReq{}
+	if err := c.ShouldBind(req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "请求参数错误", Data: nil})
+		return
+	}
+	if req.ClientId == "" || req.ClientSecret == "" {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "必须提供clientID和clientSecret", Data: nil})
+		return
+	}
+	account, err := models.Create123Account(req.Id, req.Name, req.ClientId, req.ClientSecret)
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("保存123云盘账号失败: %s", err.Error()), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "保存123云盘账号成功", Data: account.ID})
+}
+
+// GetOpen123Status 查询123云盘账号状态
+// @Summary 查询123云盘账号状态
+// @Description 获取指定123云盘账号的用户信息及存储空间
+// @Tags 123云盘
+// @Accept json
+// @Produce json
+// @Param account_id query integer true "账号ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /123/status [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetOpen123Status(c *gin.Context) {
+	type statusReq struct {
+		AccountId uint `json:"account_id" form:"account_id"`
+	}
+	var req statusReq
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "参数错误", Data: nil})
+		return
+	}
+	account, err := models.GetAccountById(req.AccountId)
+	if err != nil || account.SourceType != models.SourceType123 {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "账号ID不存在", Data: nil})
+		return
+	}
+	userInfo, err := account.Get123Client().GetUserInfo(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取123云盘用户信息失败: " + err.Error(), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "成功", Data: Open123StatusResp{
+		UserId:     userInfo.UID,
+		Username:   userInfo.Nickname,
+		UsedSpace:  userInfo.SpaceUsed,
+		TotalSpace: userInfo.SpacePermanent + userInfo.SpaceTemp,
+		Vip:        userInfo.Vip,
+	}})
+}
+
+// 通过123云盘文件ID（参数名叫pickcode，跟115保持一致）获取下载链接
+func Get123UrlByPickCode(c *gin.Context) {
+	type fileIdReq struct {
+		UserId   string `json:"userid" form:"userid"`
+		PickCode string `json:"pickcode" form:"pickcode"`
+		Force    int    `json:"force" form:"force"`
+		strmSignReq
+	}
+	var req fileIdReq
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "参数错误", Data: nil})
+		return
+	}
+	pickCode := req.PickCode
+	userId := req.UserId
+	if !checkStrmSign(c, pickCode, userId, req.strmSignReq) {
+		return
+	}
+	var account *models.Account
+	if userId == "" {
+		// 查询SyncFile
+		syncFile := models.GetFileByPickCode(pickCode)
+		if syncFile == nil {
+			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "文件PickCode不存在", Data: nil})
+			return
+		}
+		var err error
+		account, err = models.GetAccountById(syncFile.AccountId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "账号ID不存在", Data: nil})
+			return
+		}
+	} else {
+		var err error
+		// 通过userId查询账号
+		account, err = models.GetAccountByUserId(userId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "用户ID不存在", Data: nil})
+			return
+		}
+	}
+	// pickcode和userid都可能属于其他类型的网盘账号
+	if account.SourceType != models.SourceType123 {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "不是123云盘账号", Data: nil})
+		return
+	}
+	client := account.Get123Client()
+	cacheKey := fmt.Sprintf("123url:%s", pickCode)
+	if keyLock.LockWithTimeout(cacheKey, 10*time.Second) {
+		defer keyLock.Unlock(cacheKey)
+		cachedUrl := ""
+		if req.Force == 0 {
+			cachedUrl = string(db.Cache.Get(cacheKey))
+		}
+		if cachedUrl == "" {
+			var err error
+			cachedUrl, err = client.GetDirectLink(context.Background(), helpers.StringToInt64(pickCode))
+			if err != nil || cachedUrl == "" {
+				helpers.AppLogger.Errorf("获取123云盘下载链接失败: %s %v", pickCode, err)
+				c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取123云盘下载链接失败", Data: nil})
+				return
+			}
+			helpers.AppLogger.Infof("从接口中查询到123云盘下载链接: %s => %s", pickCode, cachedUrl)
+			// 缓存1小时，123云盘的下载链接有效期较短
+			db.Cache.Set(cacheKey, []byte(cachedUrl), 3600)
+		} else {
+			helpers.AppLogger.Infof("从缓存中查询到123云盘下载链接: %s => %s", pickCode, cachedUrl)
+		}
+		// 123云盘的下载链接不校验UA，直接302
+		c.Redirect(http.StatusFound, cachedUrl)
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取123云盘下载链接超时", Data: nil})
+}
@@ -52,6 +52,9 @@ func GetPathList(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "参数错误", Data: nil})
 		return
 	}
+	if req.SourceType != models.SourceTypeLocal && !checkResourceAccess(c, models.ResourceTypeAccount, req.AccountId) {
+		return
+	}
 	var pathes []DirResp
 	var err error
 	switch req.SourceType {
@@ -63,6 +66,8 @@ func GetPathList(c *gin.Context) {
 		pathes, err = Get115PathList(req.ParentId, req.AccountId)
 	case models.SourceTypeBaiduPan:
 		pathes, err = GetBaiduPanPathList(req.ParentId, req.AccountId)
+	case models.SourceType123:
+		pathes, err = Get123PathList(req.ParentId, req.ParentPath, req.AccountId)
 	default:
 		// 报错
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "未知的同步源类型", Data: nil})
@@ -255,6 +260,32 @@ func GetBaiduPanPathList(parentId string, accountId uint) ([]DirResp, error) {
 	return items, nil
 }
 
+func Get123PathList(parentId string, parentPath string, accountId uint) ([]DirResp, error) {
+	// 获取123云盘目录列表
+	account, err := models.GetAccountById(accountId)
+	if err != nil {
+		return nil, err
+	}
+	client := account.Get123Client()
+	fileList, fileErr := client.ListAllFiles(context.Background(), helpers.StringToInt64(parentId))
+	if fileErr != nil {
+		helpers.AppLogger.Warnf("获取123云盘目录列表失败: 父目录：%s, 错误:%v", parentId, fileErr)
+		return nil, fileErr
+	}
+	folders := make([]DirResp, 0)
+	for _, item := range fileList {
+		if !item.IsDir() {
+			continue
+		}
+		folders = append(folders, DirResp{
+			Id:   helpers.Int64ToString(item.FileID),
+			Name: item.FileName,
+			Path: filepath.ToSlash(filepath.Join(parentPath, item.FileName)),
+		})
+	}
+	return folders, nil
+}
+
 type FileItem struct {
 	Id          string `json:"id"`
 	IsDirectory bool   `json:"is_directory"`
@@ -282,6 +313,9 @@ func GetNetFileList(c *gin.Context) {
 	if req.PageSize == 0 {
 		req.PageSize = 1150
 	}
+	if !checkResourceAccess(c, models.ResourceTypeAccount, req.AccountId) {
+		return
+	}
 	account, err := models.GetAccountById(req.AccountId)
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取账号信息失败: " + err.Error(), Data: nil})
@@ -295,6 +329,8 @@ func GetNetFileList(c *gin.Context) {
 		list, err = get115Dirs(req.ParentId, account, req.Page, req.PageSize)
 	case models.SourceTypeBaiduPan:
 		list, err = getBaiduPanDirs(req.ParentId, account, req.Page, req.PageSize)
+	case models.SourceType123:
+		list, err = get123Dirs(req.ParentId, account, req.Page, req.PageSize)
 	default:
 		// 报错
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "未知的网盘类型", Data: nil})
@@ -388,6 +424,32 @@ func getBaiduPanDirs(parentId string, account *models.Account, page, pageSize in
 	return items, nil
 }
 
+// 123云盘的列表接口只能按lastFileId翻页，这里取全部后再分页
+func get123Dirs(parentId string, account *models.Account, page, pageSize int) ([]*FileItem, error) {
+	client := account.Get123Client()
+	fileList, err := client.ListAllFiles(context.Background(), helpers.StringToInt64(parentId))
+	if err != nil {
+		helpers.AppLogger.Warnf("获取123云盘目录列表失败: 父目录：%s, 错误:%v", parentId, err)
+		return nil, err
+	}
+	items := make([]*FileItem, 0)
+	start := (page - 1) * pageSize
+	if start >= len(fileList) {
+		return items, nil
+	}
+	end := min(start+pageSize, len(fileList))
+	for _, item := range fileList[start:end] {
+		items = append(items, &FileItem{
+			Id:          helpers.Int64ToString(item.FileID),
+			IsDirectory: item.IsDir(),
+			Name:        item.FileName,
+			Size:        item.Size,
+			ModifiedAt:  item.UpdateTime(),
+		})
+	}
+	return items, nil
+}
+
 // 创建文件夹
 func CreateDir(c *gin.Context) {
 	type createDirReq struct {
@@ -427,6 +489,8 @@ func CreateDir(c *gin.Context) {
 		pathId, err = make115PathList(req.ParentId, req.ParentPath, req.Name, req.AccountId)
 	case models.SourceTypeBaiduPan:
 		pathId, err = makeBaiduPanPathList(req.ParentId, req.Name, req.AccountId)
+	case models.SourceType123:
+		pathId, err = make123Path(req.ParentId, req.Name, req.AccountId)
 	default:
 		// 报错
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "未知的同步源类型", Data: nil})
@@ -507,6 +571,20 @@ func make115PathList(parentId, parentPath, folderName string, accountId uint) (s
 	return newPathId, nil
 }
 
+// 创建123云盘目录，parentId为空表示根目录
+func make123Path(parentId string, folderName string, accountId uint) (string, error) {
+	account, err := models.GetAccountById(accountId)
+	if err != nil {
+		return "", fmt.Errorf("获取账号失败: %v", err)
+	}
+	client := account.Get123Client()
+	resp, err := client.CreateFolder(context.Background(), folderName, helpers.StringToInt64(parentId))
+	if err != nil {
+		return "", fmt.Errorf("创建123云盘目录失败: %s, 错误: %v", folderName, err)
+	}
+	return helpers.Int64ToString(resp.DirID), nil
+}
+
 func makeBaiduPanPathList(parentId string, folderName string, accountId uint) (string, error) {
 	if parentId == "" {
 		parentId = "/"
@@ -595,6 +673,9 @@ func DeleteDir(c *gin.Context) {
 	case models.SourceTypeBaiduPan:
 		client := account.GetBaiDuPanClient()
 		err = client.Del(context.Background(), []string{req.FileId})
+	case models.SourceType123:
+		client := account.Get123Client()
+		err = client.TrashFiles(context.Background(), []int64{helpers.StringToInt64(req.FileId)})
 	default:
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "不支持的文件系统", Data: nil})
 		return
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"Q115-STRM/internal/playsession"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPlaySessions 查询正在播放的会话
+// @Summary 查询播放会话
+// @Description 查询正在播放的会话和每个网盘账号的同时播放数、本地代理流量，会话变化时也会通过/api/events/ws推送play_sessions事件
+// @Tags 播放
+// @Accept json
+// @Produce json
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /play/sessions [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetPlaySessions(c *gin.Context) {
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "查询播放会话成功", Data: playsession.GetManager().Snapshot()})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
 	"Q115-STRM/internal/models"
 	"Q115-STRM/internal/synccron"
 	"encoding/json"
@@ -39,6 +40,14 @@ type TmdbSettings struct {
 	TmdbEnableProxy   bool   `json:"tmdb_enable_proxy" form:"tmdb_enable_proxy"`
 }
 
+type MetadataProviderSettings struct {
+	TvdbApiKey   string   `json:"tvdb_api_key" form:"tvdb_api_key"`
+	TvdbPin      string   `json:"tvdb_pin" form:"tvdb_pin"`
+	DoubanApiKey string   `json:"douban_api_key" form:"douban_api_key"`
+	BangumiToken string   `json:"bangumi_token" form:"bangumi_token"`
+	Providers    []string `json:"providers" form:"-"` // 支持的元数据提供者，只读
+}
+
 type AiSettings struct {
 	EnableAi    models.AiAction `json:"enable_ai" form:"enable_ai"`
 	AiApiKey    string          `json:"ai_api_key" form:"ai_api_key"`
@@ -151,6 +160,56 @@ func TestTmdbSettings(c *gin.Context) {
 	c.JSON(http.StatusOK, APIResponse[bool]{Code: Success, Message: "", Data: testResult})
 }
 
+// GetMetadataProviders 获取元数据提供者设置
+// @Summary 获取元数据提供者设置
+// @Description 获取TheTVDB、豆瓣、Bangumi的配置和支持的元数据提供者列表
+// @Tags 刮削管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /scrape/providers [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetMetadataProviders(c *gin.Context) {
+	settings := MetadataProviderSettings{
+		TvdbApiKey:   models.GlobalScrapeSettings.TvdbApiKey,
+		TvdbPin:      models.GlobalScrapeSettings.TvdbPin,
+		DoubanApiKey: models.GlobalScrapeSettings.DoubanApiKey,
+		BangumiToken: models.GlobalScrapeSettings.BangumiToken,
+		Providers:    metadata.Names(),
+	}
+	c.JSON(http.StatusOK, APIResponse[MetadataProviderSettings]{Code: Success, Message: "", Data: settings})
+}
+
+// SaveMetadataProviders 保存元数据提供者设置
+// @Summary 保存元数据提供者设置
+// @Description 保存TheTVDB、豆瓣、Bangumi的配置
+// @Tags 刮削管理
+// @Accept json
+// @Produce json
+// @Param tvdb_api_key body string false "TheTVDB v4 API KEY"
+// @Param tvdb_pin body string false "TheTVDB订阅PIN"
+// @Param douban_api_key body string false "豆瓣API KEY"
+// @Param bangumi_token body string false "Bangumi Access Token"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /scrape/providers [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func SaveMetadataProviders(c *gin.Context) {
+	reqData := MetadataProviderSettings{}
+	if err := c.ShouldBindJSON(&reqData); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	if err := models.GlobalScrapeSettings.SaveMetadataProviders(reqData.TvdbApiKey, reqData.TvdbPin, reqData.DoubanApiKey, reqData.BangumiToken); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "保存元数据提供者设置成功", Data: nil})
+}
+
 // SaveAiSettings 保存AI识别设置
 // @Summary 保存AI识别设置
 // @Description 保存或更新AI识别模型的配置
@@ -459,6 +518,11 @@ func DeleteTvshowCategory(c *gin.Context) {
 func GetScrapePathes(c *gin.Context) {
 	sourceType := c.Query("source_type")
 	scrapePathes := models.GetScrapePathes(sourceType)
+	if ids := getAccessibleResourceIds(c, models.ResourceTypeScrapePath); ids != nil {
+		scrapePathes = slices.DeleteFunc(scrapePathes, func(scrapePath *models.ScrapePath) bool {
+			return !slices.Contains(ids, scrapePath.ID)
+		})
+	}
 	for _, scrapePath := range scrapePathes {
 		// 检查是否正在运行
 		scrapePath.IsTaskRunning = synccron.CheckNewTaskStatus(scrapePath.ID, synccron.SyncTaskTypeScrape)
@@ -485,6 +549,9 @@ func GetScrapePath(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "刮削目录不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, scrapePath.ID) {
+		return
+	}
 	if scrapePath.EnableAi == "" {
 		scrapePath.EnableAi = models.AiActionOff
 	}
@@ -512,6 +579,29 @@ func SaveScrapePath(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
 		return
 	}
+	if reqData.ID > 0 && !checkResourceAccess(c, models.ResourceTypeScrapePath, reqData.ID) {
+		return
+	}
+	if reqData.AccountId > 0 && !checkResourceAccess(c, models.ResourceTypeAccount, reqData.AccountId) {
+		return
+	}
+	if err := reqData.CheckMetadataProviders(); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	if err := reqData.CheckAnimeEpisodeOffsets(); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	if err := reqData.CheckRefreshRules(); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	if err := reqData.CheckNfoOptions(); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
+		return
+	}
+	isNew := reqData.ID == 0
 	// 如果是115，用ID查询实际的目录
 	if reqData.SourceType == models.SourceType115 {
 		// 用ID查询实际的目录
@@ -541,12 +631,15 @@ func SaveScrapePath(c *gin.Context) {
 	// 检查 cron 表达式是否发生变化
 	var oldCronExpr string
 	var cronChanged bool
+	// 元数据刷新规则是否发生变化
+	refreshChanged := reqData.RefreshEnabled()
 	if reqData.ID > 0 {
 		// 更新操作
 		oldScrapePath := models.GetScrapePathByID(reqData.ID)
 		if oldScrapePath != nil {
 			oldCronExpr = oldScrapePath.CronExpression
 			cronChanged = oldCronExpr != reqData.CronExpression
+			refreshChanged = oldScrapePath.RefreshEnabled() != reqData.RefreshEnabled() || oldScrapePath.RefreshCron != reqData.RefreshCron
 		}
 	} else {
 		// 新增操作：如果设置了 cron 表达式，则需要重新加载定时任务
@@ -557,12 +650,19 @@ func SaveScrapePath(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
 		return
 	}
+	if isNew {
+		grantCreatedResource(c, models.ResourceTypeScrapePath, reqData.ID)
+	}
 
 	// 如果 cron 表达式发生变化或新增了启用 cron 的刮削目录，重新加载定时任务
 	if cronChanged {
 		helpers.AppLogger.Infof("检测到刮削目录的 cron 配置发生变化，重新加载定时任务")
 		synccron.InitScrapeCron()
 	}
+	if refreshChanged {
+		helpers.AppLogger.Infof("检测到刮削目录的元数据刷新规则发生变化，重新加载元数据刷新定时任务")
+		synccron.InitRefreshCron()
+	}
 
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "保存刮削目录成功", Data: nil})
 }
@@ -581,6 +681,9 @@ func SaveScrapePath(c *gin.Context) {
 // @Security ApiKeyAuth
 func DeleteScrapePath(c *gin.Context) {
 	id := helpers.StringToInt(c.Param("id"))
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, uint(id)) {
+		return
+	}
 
 	// 检查是否是启用了 cron 的刮削目录
 	oldScrapePath := models.GetScrapePathByID(uint(id))
@@ -593,12 +696,16 @@ func DeleteScrapePath(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
 		return
 	}
+	models.DeleteResourceGrants(models.ResourceTypeScrapePath, uint(id))
 
 	// 如果删除的是启用了 cron 的刮削目录，重新加载定时任务
 	if shouldReloadCron {
 		helpers.AppLogger.Infof("检测到删除的刮削目录 %d 启用了 cron，重新加载定时任务", id)
 		synccron.InitScrapeCron()
 	}
+	if oldScrapePath != nil && oldScrapePath.RefreshEnabled() {
+		synccron.InitRefreshCron()
+	}
 
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "删除刮削目录成功", Data: nil})
 }
@@ -630,6 +737,9 @@ func ScanScrapePath(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "刮削目录不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, scrapePath.ID) {
+		return
+	}
 	// 添加刮削任务到队列
 	taskObj := &synccron.NewSyncTask{
 		ID:           scrapePath.ID,
@@ -678,7 +788,7 @@ func GetScrapeRecords(c *gin.Context) {
 	status := c.Query("status")
 	name := c.Query("name")
 	scrapePathesCache := make(map[uint]*models.ScrapePath)
-	total, scrapeRecords := models.GetScrapeMediaFiles(page, pageSize, mediaType, status, name)
+	total, scrapeRecords := models.GetScrapeMediaFiles(page, pageSize, mediaType, status, name, getAccessibleResourceIds(c, models.ResourceTypeScrapePath))
 	type scrapeMediaResp struct {
 		ID              uint   `json:"id"`
 		Type            string `json:"type"`
@@ -893,6 +1003,9 @@ func ExportScrapeRecords(c *gin.Context) {
 		idUint, _ := strconv.ParseUint(id, 10, 32)
 		idUintList = append(idUintList, uint(idUint))
 	}
+	if !checkScrapeRecordsAccess(c, idUintList) {
+		return
+	}
 	scrapeRecords := models.GetScrapeMediaFilesByIds(idUintList)
 	if len(scrapeRecords) == 0 {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "没有找到要导出的记录", Data: nil})
@@ -971,6 +1084,9 @@ func ReScrape(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "没有找到要重新刮削的记录的刮削目录", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, scrapePath.ID) {
+		return
+	}
 	oldStatus := scrapeMedia.Status
 	err := scrapeMedia.ReScrape("", 0, req.TmdbId, req.Season, req.Episode)
 	if err != nil {
@@ -989,9 +1105,303 @@ func ReScrape(c *gin.Context) {
 	}
 }
 
+// GetScrapeReviews 获取待确认队列
+// @Summary 获取待确认队列
+// @Description 分页获取识别结果可信度低于阈值、需要手工确认的记录和候选条目
+// @Tags 刮削管理
+// @Accept json
+// @Produce json
+// @Param page query integer false "页码"
+// @Param pageSize query integer false "每页数量"
+// @Param type query string false "媒体类型"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /scrape/reviews [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetScrapeReviews(c *gin.Context) {
+	page := helpers.StringToInt(c.Query("page"))
+	if page == 0 {
+		page = 1
+	}
+	pageSize := helpers.StringToInt(c.Query("pageSize"))
+	if pageSize == 0 {
+		pageSize = 100
+	}
+	total, records := models.GetNeedsReviewScrapeMediaFiles(page, pageSize, c.Query("type"), getAccessibleResourceIds(c, models.ResourceTypeScrapePath))
+	type candidateResp struct {
+		TmdbID        int64   `json:"tmdb_id"`
+		Title         string  `json:"title"`
+		OriginalTitle string  `json:"original_title"`
+		Year          int     `json:"year"`
+		Runtime       int64   `json:"runtime"`
+		PosterUrl     string  `json:"poster_url"`
+		Overview      string  `json:"overview"`
+		Confidence    float64 `json:"confidence"`
+	}
+	type reviewResp struct {
+		ID           uint             `json:"id"`
+		Type         string           `json:"type"`
+		ScrapePathId uint             `json:"scrape_path_id"`
+		Path         string           `json:"path"`
+		FileName     string           `json:"file_name"`
+		Name         string           `json:"name"`
+		Year         int              `json:"year"`
+		Confidence   float64          `json:"confidence"`
+		Candidates   []*candidateResp `json:"candidates"`
+		ScrapedAt    int64            `json:"scraped_at"`
+	}
+	list := make([]*reviewResp, 0, len(records))
+	for _, record := range records {
+		item := &reviewResp{
+			ID:           record.ID,
+			Type:         string(record.MediaType),
+			ScrapePathId: record.ScrapePathId,
+			Path:         record.Path,
+			FileName:     record.VideoFilename,
+			Name:         record.Name,
+			Year:         record.Year,
+			Confidence:   record.MatchConfidence,
+			Candidates:   make([]*candidateResp, 0, len(record.MatchCandidates)),
+			ScrapedAt:    record.ScrapeTime,
+		}
+		for _, candidate := range record.MatchCandidates {
+			item.Candidates = append(item.Candidates, &candidateResp{
+				TmdbID:        candidate.TmdbId,
+				Title:         candidate.Name,
+				OriginalTitle: candidate.OriginalName,
+				Year:          candidate.Year,
+				Runtime:       candidate.Runtime,
+				PosterUrl:     models.GetTmdbImageUrl(candidate.PosterPath),
+				Overview:      candidate.Overview,
+				Confidence:    candidate.Confidence,
+			})
+		}
+		list = append(list, item)
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功", Data: map[string]any{"total": total, "list": list}})
+}
+
+// AcceptScrapeReview 确认待确认队列中的识别结果
+// @Summary 确认识别结果
+// @Description 选择候选条目或者输入其他TMDB ID，确认后继续刮削和整理
+// @Tags 刮削管理
+// @Accept json
+// @Produce json
+// @Param id body integer true "记录ID"
+// @Param tmdb_id body integer true "TMDB ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /scrape/reviews/accept [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func AcceptScrapeReview(c *gin.Context) {
+	type acceptReviewReq struct {
+		ID     uint  `json:"id" binding:"required"`
+		TmdbId int64 `json:"tmdb_id" binding:"required"`
+	}
+	var req acceptReviewReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "请求参数错误: " + err.Error(), Data: nil})
+		return
+	}
+	scrapeMedia := models.GetScrapeMediaFileById(req.ID)
+	if scrapeMedia == nil || scrapeMedia.Status != models.ScrapeMediaStatusNeedsReview {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "没有找到待确认的记录", Data: nil})
+		return
+	}
+	scrapePath := models.GetScrapePathByID(scrapeMedia.ScrapePathId)
+	if scrapePath == nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "没有找到记录的刮削目录", Data: nil})
+		return
+	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, scrapePath.ID) {
+		return
+	}
+	var name string
+	var year int
+	for _, candidate := range scrapeMedia.MatchCandidates {
+		if candidate.TmdbId == req.TmdbId {
+			name = candidate.Name
+			year = candidate.Year
+			break
+		}
+	}
+	if name == "" {
+		// 不在候选中，使用输入的TMDB ID查询
+		tmdbClient := models.GlobalScrapeSettings.GetTmdbClient()
+		if scrapeMedia.MediaType == models.MediaTypeTvShow {
+			tvDetail, err := tmdbClient.GetTvDetail(req.TmdbId, models.GlobalScrapeSettings.GetTmdbLanguage())
+			if err != nil {
+				c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取电视剧详情失败: " + err.Error(), Data: nil})
+				return
+			}
+			name = tvDetail.Name
+			year = helpers.ParseYearFromDate(tvDetail.FirstAirDate)
+		} else {
+			movieDetail, err := tmdbClient.GetMovieDetail(req.TmdbId, models.GlobalScrapeSettings.GetTmdbLanguage())
+			if err != nil {
+				c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取电影详情失败: " + err.Error(), Data: nil})
+				return
+			}
+			name = movieDetail.Title
+			year = helpers.ParseYearFromDate(movieDetail.ReleaseDate)
+		}
+	}
+	if err := scrapeMedia.AcceptMatch(req.TmdbId, name, year); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "确认识别结果失败: " + err.Error(), Data: nil})
+		return
+	}
+	data := map[string]any{
+		"name":    scrapeMedia.Name,
+		"year":    scrapeMedia.Year,
+		"tmdb_id": scrapeMedia.TmdbId,
+	}
+	// 添加刮削任务到队列，继续刮削和整理
+	taskObj := &synccron.NewSyncTask{
+		ID:         scrapePath.ID,
+		AccountId:  scrapePath.AccountId,
+		SourceType: scrapePath.SourceType,
+		TaskType:   synccron.SyncTaskTypeScrape,
+	}
+	if err := synccron.AddNewSyncTask(taskObj); err != nil {
+		helpers.AppLogger.Warnf("确认识别结果后添加刮削任务失败: %v", err)
+		c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功，下次刮削时会使用确认的结果继续刮削", Data: data})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功，已添加刮削任务", Data: data})
+}
+
+// GetMovieCollections 获取电影合集列表
+// @Summary 获取电影合集列表
+// @Description 分页获取已刮削电影所属的合集，包含已有和缺少的电影
+// @Tags 刮削管理
+// @Accept json
+// @Produce json
+// @Param page query integer false "页码"
+// @Param pageSize query integer false "每页数量"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /scrape/collections [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetMovieCollections(c *gin.Context) {
+	page := helpers.StringToInt(c.Query("page"))
+	if page == 0 {
+		page = 1
+	}
+	pageSize := helpers.StringToInt(c.Query("pageSize"))
+	if pageSize == 0 {
+		pageSize = 100
+	}
+	total, collections := models.GetMediaCollections(page, pageSize, getAccessibleResourceIds(c, models.ResourceTypeScrapePath))
+	if collections == nil {
+		collections = make([]*models.MediaCollectionSummary, 0)
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功", Data: map[string]any{"total": total, "list": collections}})
+}
+
+// GetTvshowMissing 获取电视剧的缺集报告
+// @Summary 获取电视剧的缺集报告
+// @Description 从TMDB查询电视剧的所有季，和已刮削的集对比，返回已播出缺少、未播出和特别篇的集
+// @Tags 刮削管理
+// @Accept json
+// @Produce json
+// @Param id path integer true "媒体ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /scrape/tvshows/{id}/missing [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetTvshowMissing(c *gin.Context) {
+	id := helpers.StringToInt(c.Param("id"))
+	media, err := models.GetMediaById(uint(id))
+	if err != nil || media.MediaType != models.MediaTypeTvShow {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "电视剧不存在", Data: nil})
+		return
+	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, media.ScrapePathId) {
+		return
+	}
+	report, err := models.RefreshTvshowMissingReport(media)
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "检查缺集失败: " + err.Error(), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功", Data: report})
+}
+
+// GetTvshowMissingSummary 获取全库缺集统计
+// @Summary 获取全库缺集统计
+// @Description 返回已检查电视剧的缺集汇总，以及分页的有缺集的电视剧列表
+// @Tags 刮削管理
+// @Accept json
+// @Produce json
+// @Param page query integer false "页码"
+// @Param pageSize query integer false "每页数量"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /scrape/tvshows/missing [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetTvshowMissingSummary(c *gin.Context) {
+	page := helpers.StringToInt(c.Query("page"))
+	if page == 0 {
+		page = 1
+	}
+	pageSize := helpers.StringToInt(c.Query("pageSize"))
+	if pageSize == 0 {
+		pageSize = 100
+	}
+	scrapePathIds := getAccessibleResourceIds(c, models.ResourceTypeScrapePath)
+	total, reports := models.GetTvshowMissingReports(page, pageSize, scrapePathIds)
+	if reports == nil {
+		reports = make([]*models.TvshowMissingReport, 0)
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功", Data: map[string]any{
+		"totals": models.GetTvshowMissingTotals(scrapePathIds),
+		"total":  total,
+		"list":   reports,
+	}})
+}
+
+// RefreshTvshowMissing 检查全库电视剧的缺集
+// @Summary 检查全库电视剧的缺集
+// @Description 在后台逐个检查所有已刮削的电视剧，发现新的已播出缺集时发送通知
+// @Tags 刮削管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /scrape/tvshows/missing/refresh [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func RefreshTvshowMissing(c *gin.Context) {
+	if !models.RefreshAllTvshowMissingReports() {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "缺集检查正在执行中", Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "已开始在后台检查缺集", Data: nil})
+}
+
+// 检查当前用户是否可以操作所有选中的刮削记录，有一条记录所在的刮削目录没有授权就返回403
+func checkScrapeRecordsAccess(c *gin.Context, ids []uint) bool {
+	scrapePathIds := getAccessibleResourceIds(c, models.ResourceTypeScrapePath)
+	if scrapePathIds == nil {
+		return true
+	}
+	for _, sm := range models.GetScrapeMediaFilesByIds(ids) {
+		if !slices.Contains(scrapePathIds, sm.ScrapePathId) {
+			c.JSON(http.StatusForbidden, APIResponse[any]{Code: BadRequest, Message: "没有权限访问该资源", Data: nil})
+			return false
+		}
+	}
+	return true
+}
+
 // 清除所有刮削失败的记录
 func ClearFailedScrapeRecords(c *gin.Context) {
-	err := models.ClearFailedScrapeRecords([]uint{})
+	err := models.ClearFailedScrapeRecords([]uint{}, getAccessibleResourceIds(c, models.ResourceTypeScrapePath))
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: err.Error(), Data: nil})
 		return
@@ -1026,6 +1436,9 @@ func FinishScrapeMediaFile(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "没有找到要整理的记录", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, scrapeMedia.ScrapePathId) {
+		return
+	}
 	scrapeMedia.FinishFromRenaming()
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功，记录已标记为已整理", Data: nil})
 }
@@ -1056,8 +1469,11 @@ func DeleteScrapeMediaFile(c *gin.Context) {
 		idUint, _ := strconv.ParseUint(id, 10, 32)
 		idUintList = append(idUintList, uint(idUint))
 	}
+	if !checkScrapeRecordsAccess(c, idUintList) {
+		return
+	}
 	// 删除记录
-	err := models.ClearFailedScrapeRecords(idUintList)
+	err := models.ClearFailedScrapeRecords(idUintList, nil)
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "删除记录失败: " + err.Error(), Data: nil})
 		return
@@ -1091,6 +1507,9 @@ func RenameFailedScrapeMediaFile(c *gin.Context) {
 		idUint, _ := strconv.ParseUint(id, 10, 32)
 		idUintList = append(idUintList, uint(idUint))
 	}
+	if !checkScrapeRecordsAccess(c, idUintList) {
+		return
+	}
 	// 将这些ID对应的记录标记为待整理
 	err := models.RenameFailedScrapeRecords(idUintList)
 	if err != nil {
@@ -1127,6 +1546,9 @@ func ToggleScrapePathCron(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "没有找到要操作的记录", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, scrapePath.ID) {
+		return
+	}
 	// 切换定时刮削
 	err := scrapePath.ToggleCron()
 	if err != nil {
@@ -1162,6 +1584,9 @@ func StopScrape(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "请求参数错误: " + err.Error(), Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, req.ID) {
+		return
+	}
 	synccron.CancelNewSyncTask(req.ID, synccron.SyncTaskTypeScrape)
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功，刮削任务已停止", Data: nil})
 }
@@ -1202,6 +1627,14 @@ func SaveScrapeStrmPath(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "刮削目录不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, scrapePath.ID) {
+		return
+	}
+	for _, syncPathId := range req.SyncPathIDs {
+		if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPathId) {
+			return
+		}
+	}
 	if err := scrapePath.SaveStrmPath(req.SyncPathIDs); err != nil {
 		helpers.AppLogger.Errorf("保存刮削目录关联的同步目录失败: %v", err)
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "保存刮削目录关联的同步目录失败: " + err.Error(), Data: nil})
@@ -1224,6 +1657,9 @@ func GetScrapeStrmPaths(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "没有找到要操作的记录", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeScrapePath, scrapePath.ID) {
+		return
+	}
 	ssp := scrapePath.GetRelatStrmPath()
 	syncPathIds := make([]uint, 0)
 	for _, sp := range ssp {
@@ -1350,6 +1786,9 @@ func TmdbSearch(c *gin.Context) {
 			c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "操作成功", Data: tmdbResp})
 			return
 		}
+	case models.MediaTypeMusic:
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "音乐按音频标签整理，不支持TMDB搜索", Data: nil})
+		return
 	default:
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "请求参数错误: 类型必须是 movie 或 tv_show", Data: nil})
 		return
@@ -56,6 +56,10 @@ func ParseEmby(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "Emby Url和Emby API Key没有填写，无法提取媒体信息", Data: nil})
 		return
 	}
+	if !models.GlobalEmbyConfig.IsEmby() {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "提取媒体信息只支持Emby", Data: nil})
+		return
+	}
 	if emby.EmbyMediaInfoStart {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "Emby媒体信息解析任务已在运行", Data: nil})
 		return
@@ -474,6 +478,7 @@ func GetThreads(c *gin.Context) {
 // @Produce json
 // @Param download_threads body integer true "下载QPS"
 // @Param file_detail_threads body integer true "115接口QPS"
+// @Param disk_sync_cache_min_files body integer false "同步路径的文件数达到该值时使用磁盘同步缓存"
 // @Success 200 {object} object
 // @Failure 200 {object} object
 // @Router /setting/threads [post]
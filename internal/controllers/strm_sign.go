@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/synccron"
+	"Q115-STRM/internal/syncstrm"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// STRM链接中的签名参数
+type strmSignReq struct {
+	Kid  uint   `json:"kid" form:"kid"`
+	Exp  int64  `json:"exp" form:"exp"`
+	Sign string `json:"sign" form:"sign"`
+}
+
+// 校验STRM链接签名，校验失败会直接返回403，调用方只需要return
+func checkStrmSign(c *gin.Context, pickCode, userId string, req strmSignReq) bool {
+	err := models.CheckStrmSign(pickCode, userId, req.Exp, req.Kid, req.Sign)
+	if err == nil {
+		return true
+	}
+	helpers.AppLogger.Warnf("STRM链接签名校验失败: pickcode=%s, userid=%s, ip=%s, %v", pickCode, userId, c.ClientIP(), err)
+	message := "STRM链接签名错误"
+	if errors.Is(err, models.ErrStrmSignExpired) {
+		message = "STRM链接已过期，请重新同步"
+	}
+	c.JSON(http.StatusForbidden, APIResponse[any]{Code: BadRequest, Message: message, Data: nil})
+	return false
+}
+
+// GetStrmSignSetting 获取STRM链接签名设置
+// @Summary 获取STRM链接签名设置
+// @Description 获取签名模式和所有签名密钥（不含密钥内容）
+// @Tags 系统设置
+// @Accept json
+// @Produce json
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /setting/strm-sign [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetStrmSignSetting(c *gin.Context) {
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "获取STRM签名设置成功", Data: map[string]any{
+		"mode":        models.GetStrmSignMode(),
+		"sign_expire": models.SettingsGlobal.SignExpire,
+		"keys":        models.GetStrmSignKeys(),
+	}})
+}
+
+// UpdateStrmSignSetting 更新STRM链接签名模式
+// @Summary 更新STRM链接签名模式
+// @Description 0-关闭，1-兼容（未签名的旧链接依然可用），2-强制；开启后需要迁移或者重新同步才会给已有STRM签名
+// @Tags 系统设置
+// @Accept json
+// @Produce json
+// @Param mode body integer true "签名模式"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /setting/strm-sign [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func UpdateStrmSignSetting(c *gin.Context) {
+	type updateStrmSignReq struct {
+		Mode models.StrmSignMode `json:"mode" form:"mode"`
+	}
+	var req updateStrmSignReq
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "请求参数错误: " + err.Error(), Data: nil})
+		return
+	}
+	if req.Mode < models.StrmSignModeOff || req.Mode > models.StrmSignModeEnforce {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "签名模式不正确", Data: nil})
+		return
+	}
+	if req.Mode != models.StrmSignModeOff && models.GetActiveStrmSignKey() == nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "生成签名密钥失败", Data: nil})
+		return
+	}
+	if !models.SettingsGlobal.UpdateStrmSignMode(req.Mode) {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "更新STRM签名模式失败", Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "更新STRM签名模式成功", Data: nil})
+}
+
+// RotateStrmSignKey 轮换STRM签名密钥
+// @Summary 轮换STRM签名密钥
+// @Description 生成新的签名密钥，旧密钥依然可以校验直到被删除，之后的同步或迁移会使用新密钥重新签名
+// @Tags 系统设置
+// @Accept json
+// @Produce json
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /setting/strm-sign/rotate [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func RotateStrmSignKey(c *gin.Context) {
+	key, err := models.RotateStrmSignKey()
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "轮换STRM签名密钥失败: " + err.Error(), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "轮换STRM签名密钥成功", Data: key})
+}
+
+// DeleteStrmSignKey 删除已轮换的STRM签名密钥
+// @Summary 删除STRM签名密钥
+// @Description 删除后使用该密钥签名的STRM链接将无法播放，不能删除当前密钥
+// @Tags 系统设置
+// @Accept json
+// @Produce json
+// @Param id path integer true "密钥ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /setting/strm-sign/key/{id} [delete]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func DeleteStrmSignKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "密钥ID不正确", Data: nil})
+		return
+	}
+	if err := models.DeleteStrmSignKey(uint(id)); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "删除STRM签名密钥失败: " + err.Error(), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "删除STRM签名密钥成功", Data: nil})
+}
+
+// MigrateStrmSign 重新签名已有的STRM文件
+// @Summary 迁移STRM签名
+// @Description 使用已同步的文件记录改写STRM文件中的签名，不需要重新查询网盘；不传sync_path_id则迁移所有115、百度网盘和123云盘同步路径
+// @Tags 系统设置
+// @Accept json
+// @Produce json
+// @Param sync_path_id body integer false "同步路径ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /setting/strm-sign/migrate [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func MigrateStrmSign(c *gin.Context) {
+	type migrateReq struct {
+		SyncPathId uint `json:"sync_path_id" form:"sync_path_id"`
+	}
+	var req migrateReq
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "请求参数错误: " + err.Error(), Data: nil})
+		return
+	}
+	var syncPaths []*models.SyncPath
+	if req.SyncPathId > 0 {
+		syncPath := models.GetSyncPathById(req.SyncPathId)
+		if syncPath == nil {
+			c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
+			return
+		}
+		syncPaths = append(syncPaths, syncPath)
+	} else {
+		list, _ := models.GetSyncPathList(1, 10000, false, "")
+		for _, sp := range list {
+			if sp.SourceType == models.SourceType115 || sp.SourceType == models.SourceTypeBaiduPan || sp.SourceType == models.SourceType123 {
+				sp.ParseVideoAndMetaExt()
+				syncPaths = append(syncPaths, sp)
+			}
+		}
+	}
+	go func() {
+		for _, sp := range syncPaths {
+			if synccron.CheckNewTaskStatus(sp.ID, synccron.SyncTaskTypeStrm) != synccron.TaskStatusNone {
+				helpers.AppLogger.Warnf("同步路径 %s 正在同步中，跳过STRM签名迁移，同步完成后会自动更新签名", sp.RemotePath)
+				continue
+			}
+			if _, err := syncstrm.MigrateStrmSign(sp); err != nil {
+				helpers.AppLogger.Errorf("同步路径 %s 的STRM签名迁移失败: %v", sp.RemotePath, err)
+			}
+		}
+	}()
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "已开始迁移STRM签名，请在日志中查看进度", Data: len(syncPaths)})
+}
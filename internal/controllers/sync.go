@@ -4,6 +4,7 @@ import (
 	"Q115-STRM/internal/helpers"
 	"Q115-STRM/internal/models"
 	"Q115-STRM/internal/synccron"
+	"Q115-STRM/internal/syncstrm"
 	"Q115-STRM/internal/v115open"
 	"context"
 	"fmt"
@@ -67,7 +68,7 @@ func GetSyncRecords(c *gin.Context) {
 	}
 
 	// 获取同步记录
-	records, total, err := models.GetSyncRecords(page, pageSize)
+	records, total, err := models.GetSyncRecordsBySyncPathIds(page, pageSize, getAccessibleResourceIds(c, models.ResourceTypeSyncPath))
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: 500, Message: "获取同步记录失败", Data: nil})
 		return
@@ -111,6 +112,9 @@ func GetSyncTask(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: 404, Message: "未找到对应的同步任务", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, sync.SyncPathId) {
+		return
+	}
 
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "获取同步任务详情成功", Data: sync})
 }
@@ -148,7 +152,7 @@ func GetSyncPathList(c *gin.Context) {
 		pageSize = 20
 	}
 
-	syncPaths, total := models.GetSyncPathList(page, pageSize, false, req.SourceType)
+	syncPaths, total := models.GetSyncPathListByIds(page, pageSize, false, req.SourceType, getAccessibleResourceIds(c, models.ResourceTypeSyncPath))
 
 	for _, sp := range syncPaths {
 		status := synccron.CheckNewTaskStatus(sp.ID, synccron.SyncTaskTypeStrm)
@@ -207,6 +211,9 @@ func AddSyncPath(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "账号不存在", Data: nil})
 			return
 		}
+		if !checkResourceAccess(c, models.ResourceTypeAccount, account.ID) {
+			return
+		}
 		// 检查来源类型是否正确
 		if req.SourceType != account.SourceType {
 			c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "账号类型与同步源类型不一致", Data: nil})
@@ -236,6 +243,7 @@ func AddSyncPath(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "创建同步路径失败", Data: nil})
 		return
 	}
+	grantCreatedResource(c, models.ResourceTypeSyncPath, syncPath.ID)
 	if syncPath.EnableCron && syncPath.Cron != "" {
 		synccron.InitSyncCron()
 	}
@@ -278,7 +286,13 @@ func UpdateSyncPath(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
 	oldCron := syncPath.Cron
+	if req.SourceType != models.SourceTypeLocal && !checkResourceAccess(c, models.ResourceTypeAccount, req.AccountId) {
+		return
+	}
 	if req.SourceType != models.SourceTypeLocal {
 		// 检查accountId是否存在
 		account, err := models.GetAccountById(syncPath.AccountId)
@@ -318,6 +332,10 @@ func UpdateSyncPath(c *gin.Context) {
 	if oldCron != syncPath.Cron {
 		synccron.InitSyncCron()
 	}
+	if syncPath.EnableWatch {
+		// 路径或者扩展名可能变化，重启实时监控
+		synccron.InitSyncWatch()
+	}
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "更新同步路径成功", Data: syncPath})
 }
 
@@ -347,13 +365,19 @@ func DeleteSyncPath(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "id 参数不能为空", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, id) {
+		return
+	}
 	// 删除同步路径
 	success := models.DeleteSyncPathById(id)
 	if !success {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "删除同步路径失败", Data: nil})
 		return
 	}
+	models.DeleteResourceGrants(models.ResourceTypeSyncPath, id)
+	syncstrm.RemoveDiskSyncCache(id)
 	synccron.InitSyncCron()
+	synccron.InitSyncWatch()
 	synccron.InitCron()
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "删除同步路径成功", Data: nil})
 }
@@ -384,6 +408,9 @@ func GetSyncPathById(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
 
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "获取同步路径详情成功", Data: syncPath})
 }
@@ -415,6 +442,9 @@ func DelSyncRecords(c *gin.Context) {
 		return
 	}
 	for _, id := range ids {
+		if record, err := models.GetSyncByID(id); err == nil && !checkResourceAccess(c, models.ResourceTypeSyncPath, record.SyncPathId) {
+			return
+		}
 		deleteErr := models.DeleteSyncRecordById(id)
 		if deleteErr != nil {
 			c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "删除同步记录失败: " + deleteErr.Error(), Data: nil})
@@ -458,6 +488,9 @@ func StartSyncByPath(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
 	// syncPath.SetIsFullSync(false)
 	// 添加同步任务到队列
 	taskObj := &synccron.NewSyncTask{
@@ -478,6 +511,79 @@ func StartSyncByPath(c *gin.Context) {
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "同步任务已添加到队列", Data: nil})
 }
 
+// StartSyncPlan 预演同步路径
+// @Summary 预演同步路径
+// @Description 按当前配置执行一次同步但不修改任何文件，把要新建、更新、删除的STRM，要下载、上传的元数据和要删除的网盘文件记录为同步计划
+// @Tags 同步管理
+// @Accept json
+// @Produce json
+// @Param id path integer true "同步路径ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /sync/path/{id}/plan [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func StartSyncPlan(c *gin.Context) {
+	id := uint(helpers.StringToInt(c.Param("id")))
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "id 参数格式错误", Data: nil})
+		return
+	}
+	syncPath := models.GetSyncPathById(id)
+	if syncPath == nil {
+		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
+		return
+	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
+	taskObj := &synccron.NewSyncTask{
+		ID:         syncPath.ID,
+		AccountId:  syncPath.AccountId,
+		SourceType: syncPath.SourceType,
+		IsFile:     false,
+		TaskType:   synccron.SyncTaskTypeStrm,
+		DryRun:     true,
+	}
+	if err := synccron.AddNewSyncTask(taskObj); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "添加预演任务失败: " + err.Error(), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "预演任务已添加到队列，完成后可以查看同步计划", Data: nil})
+}
+
+// GetSyncPlan 获取同步路径最近一次的同步计划
+// @Summary 获取同步计划
+// @Description 返回同步路径最近一次预演的同步记录和计划，预演未完成时plan为空
+// @Tags 同步管理
+// @Accept json
+// @Produce json
+// @Param id path integer true "同步路径ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /sync/path/{id}/plan [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetSyncPlan(c *gin.Context) {
+	id := uint(helpers.StringToInt(c.Param("id")))
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "id 参数格式错误", Data: nil})
+		return
+	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, id) {
+		return
+	}
+	syncRecord := models.GetLastDryRunSync(id)
+	if syncRecord == nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "该同步路径还没有预演记录", Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "获取同步计划成功", Data: map[string]any{
+		"sync": syncRecord,
+		"plan": syncRecord.GetPlan(),
+	}})
+}
+
 // StopSyncByPath 停止指定路径的同步任务
 // @Summary 停止同步路径
 // @Description 停止指定同步目录的同步任务
@@ -510,6 +616,9 @@ func StopSyncByPath(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
 	// syncPath.SetIsFullSync(false)
 	synccron.CancelNewSyncTask(syncPath.ID, synccron.SyncTaskTypeStrm)
 
@@ -547,6 +656,9 @@ func ToggleSyncByPath(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
 	syncPath.ToggleCron()
 	synccron.InitCron()
 	// 重启自定义定时任务
@@ -561,6 +673,48 @@ func ToggleSyncByPath(c *gin.Context) {
 
 }
 
+// ToggleWatchByPath 切换同步路径的实时监控
+// @Summary 切换实时监控同步
+// @Description 开启或关闭本地同步目录的实时监控，开启后来源目录的新增、重命名、删除会在几秒内同步
+// @Tags 同步管理
+// @Accept json
+// @Produce json
+// @Param id body integer true "同步路径ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /sync/path/toggle-watch [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func ToggleWatchByPath(c *gin.Context) {
+	type toggleWatchRequest struct {
+		ID uint `form:"id" json:"id" binding:"required"` // 同步路径ID
+	}
+	var req toggleWatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse[any]{Code: BadRequest, Message: "请求参数错误", Data: nil})
+		return
+	}
+	syncPath := models.GetSyncPathById(req.ID)
+	if syncPath == nil {
+		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
+		return
+	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
+	if syncPath.SourceType != models.SourceTypeLocal && !syncPath.EnableWatch {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "只有本地目录（含挂载目录）支持实时监控同步", Data: nil})
+		return
+	}
+	syncPath.ToggleWatch()
+	synccron.InitSyncWatch()
+	if syncPath.EnableWatch {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "实时监控同步已开启", Data: nil})
+	} else {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "实时监控同步已关闭", Data: nil})
+	}
+}
+
 // FullStart115Sync 启动115全量同步
 // @Summary 启动115全量同步
 // @Description 删除本地缓存数据并触发115的全量同步
@@ -592,6 +746,9 @@ func FullStart115Sync(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
 	// 删除所有的数据库记录，重新查询接口
 	// if syncPath.SourceType == models.SourceType115 {
 	// 	// 清空数据表
@@ -639,6 +796,9 @@ func SaveRelScrapePath(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
 	// 保存关联的刮削路径
 	if err := syncPath.SaveScrapePaths(req.ScrapePathId); err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "保存关联的刮削路径失败: " + err.Error(), Data: nil})
@@ -663,6 +823,9 @@ func GetRelScrapePath(c *gin.Context) {
 		c.JSON(http.StatusNotFound, APIResponse[any]{Code: BadRequest, Message: "同步路径不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeSyncPath, syncPath.ID) {
+		return
+	}
 	// 获取关联的刮削路径
 	scrapePathIds := syncPath.GetScrapePathIds()
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "关联的刮削路径获取成功", Data: scrapePathIds})
@@ -691,6 +854,9 @@ func ManualSync(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "账号不存在", Data: nil})
 		return
 	}
+	if !checkResourceAccess(c, models.ResourceTypeAccount, account.ID) {
+		return
+	}
 	if req.Path == "" {
 		// 使用文件ID查询详情
 		switch account.SourceType {
@@ -714,6 +880,16 @@ func ManualSync(c *gin.Context) {
 			}
 			req.Path = fileDetail.Path
 			req.IsFile = fileDetail.IsDir == 0
+		case models.SourceType123:
+			client := account.Get123Client()
+			// 123云盘文件详情接口，路径需要逐级查询
+			fileDetail, parentPath, err := client.GetFileDetailWithPath(context.Background(), helpers.StringToInt64(req.PathId))
+			if err != nil {
+				c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "获取文件详情失败: " + err.Error(), Data: nil})
+				return
+			}
+			req.Path = filepath.ToSlash(filepath.Join(parentPath, fileDetail.FileName))
+			req.IsFile = !fileDetail.IsDir()
 		default:
 			c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "不支持的文件类型", Data: nil})
 			return
@@ -17,8 +17,6 @@ type LoginRequest struct {
 	RememberMe bool   `json:"rememberMe" form:"rememberMe"`
 }
 
-var LoginedUser *models.User = nil
-
 // LoginAction 用户登录
 // @Summary 用户登录
 // @Description 用户登录并返回JWT Token
@@ -82,13 +80,12 @@ func LoginAction(c *gin.Context) {
 		false,                      // Secure（false 兼容飞牛 HTTP 环境）
 		true,                       // HttpOnly（防止 XSS 攻击）
 	)
-	LoginedUser = user
 	res := make(map[string]interface{})
 	u := make(map[string]string)
 	u["id"] = fmt.Sprintf("%d", user.ID)
 	u["username"] = user.Username
 	u["email"] = ""
-	u["role"] = "admin"
+	u["role"] = string(user.GetRole())
 	res["user"] = u
 	res["token"] = tokenString
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "登录成功", Data: res})
@@ -120,13 +117,14 @@ func ChangePassword(c *gin.Context) {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "用户名不能为空", Data: nil})
 		return
 	}
+	loginedUser := GetLoginedUser(c)
 	isChange := false
 	isChange2 := false
 	var err error
-	if req.Username != LoginedUser.Username {
+	if req.Username != loginedUser.Username {
 		isChange = true
 	}
-	isChange2, err = LoginedUser.ChangeUsernameAndPassword(req.Username, req.NewPassword)
+	isChange2, err = loginedUser.ChangeUsernameAndPassword(req.Username, req.NewPassword)
 	if err != nil {
 		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "修改失败: " + err.Error(), Data: nil})
 		return
@@ -136,7 +134,7 @@ func ChangePassword(c *gin.Context) {
 
 // GetUserInfo 获取当前用户信息
 // @Summary 获取用户信息
-// @Description 获取当前登录用户的ID和用户名
+// @Description 获取当前登录用户的ID、用户名、角色和权限范围
 // @Tags 用户管理
 // @Accept json
 // @Produce json
@@ -146,9 +144,200 @@ func ChangePassword(c *gin.Context) {
 // @Security JwtAuth
 // @Security ApiKeyAuth
 func GetUserInfo(c *gin.Context) {
-	// 返回当前用户ID和用户名
-	respData := make(map[string]string)
-	respData["id"] = fmt.Sprintf("%d", LoginedUser.ID)
-	respData["username"] = LoginedUser.Username
+	// 返回当前用户ID、用户名、角色和权限范围
+	loginedUser := GetLoginedUser(c)
+	scopes, _ := c.Get("scopes")
+	respData := make(map[string]any)
+	respData["id"] = fmt.Sprintf("%d", loginedUser.ID)
+	respData["username"] = loginedUser.Username
+	respData["role"] = loginedUser.GetRole()
+	respData["scopes"] = scopes
 	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "获取用户信息成功", Data: respData})
 }
+
+// ListUsers 获取用户列表
+// @Summary 获取用户列表
+// @Description 获取所有用户及其角色，仅管理员可用
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /users [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func ListUsers(c *gin.Context) {
+	users, err := models.GetAllUsers()
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("查询用户列表失败：%v", err), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "查询成功", Data: map[string]any{
+		"list":        users,
+		"role_scopes": models.RoleScopes,
+	}})
+}
+
+// CreateUser 创建用户
+// @Summary 创建用户
+// @Description 创建新用户并指定角色，仅管理员可用
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param username body string true "用户名"
+// @Param password body string true "密码"
+// @Param role body string true "角色：admin、operator、viewer"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /users [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func CreateUser(c *gin.Context) {
+	var req struct {
+		Username string          `json:"username" binding:"required"`
+		Password string          `json:"password" binding:"required"`
+		Role     models.UserRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("参数错误：%v", err), Data: nil})
+		return
+	}
+	user, err := models.CreateUser(req.Username, req.Password, req.Role)
+	if err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("创建用户失败：%v", err), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "创建用户成功", Data: user})
+}
+
+// UpdateUser 修改用户
+// @Summary 修改用户
+// @Description 修改指定用户的角色或者重置密码，仅管理员可用
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param id path integer true "用户ID"
+// @Param role body string false "角色：admin、operator、viewer"
+// @Param password body string false "新密码，为空不修改"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /users/{id} [put]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func UpdateUser(c *gin.Context) {
+	var req struct {
+		Role     models.UserRole `json:"role"`
+		Password string          `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("参数错误：%v", err), Data: nil})
+		return
+	}
+	user, err := models.GetUserById(uint(helpers.StringToInt(c.Param("id"))))
+	if err != nil || user.ID == 0 {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "用户不存在", Data: nil})
+		return
+	}
+	if req.Role != "" && req.Role != user.GetRole() {
+		if err := user.UpdateRole(req.Role); err != nil {
+			c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("修改角色失败：%v", err), Data: nil})
+			return
+		}
+	}
+	if req.Password != "" {
+		if _, err := user.ChangeUsernameAndPassword(user.Username, req.Password); err != nil {
+			c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("重置密码失败：%v", err), Data: nil})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "修改用户成功", Data: user})
+}
+
+// DeleteUser 删除用户
+// @Summary 删除用户
+// @Description 删除指定用户及其API Key和资源授权，不能删除自己，仅管理员可用
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param id path integer true "用户ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /users/{id} [delete]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func DeleteUser(c *gin.Context) {
+	user, err := models.GetUserById(uint(helpers.StringToInt(c.Param("id"))))
+	if err != nil || user.ID == 0 {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "用户不存在", Data: nil})
+		return
+	}
+	if user.ID == GetLoginedUser(c).ID {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "不能删除当前登录的用户", Data: nil})
+		return
+	}
+	if err := models.DeleteUser(user); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("删除用户失败：%v", err), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "删除用户成功", Data: nil})
+}
+
+// GetUserResources 获取用户被授权的资源
+// @Summary 获取用户授权的资源
+// @Description 获取非管理员用户被授权的同步路径、刮削路径和网盘账号ID，管理员可以访问所有资源，仅管理员可用
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param id path integer true "用户ID"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /users/{id}/resources [get]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func GetUserResources(c *gin.Context) {
+	userId := uint(helpers.StringToInt(c.Param("id")))
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "查询成功", Data: map[string][]uint{
+		string(models.ResourceTypeSyncPath):   models.GetUserResourceIds(userId, models.ResourceTypeSyncPath),
+		string(models.ResourceTypeScrapePath): models.GetUserResourceIds(userId, models.ResourceTypeScrapePath),
+		string(models.ResourceTypeAccount):    models.GetUserResourceIds(userId, models.ResourceTypeAccount),
+	}})
+}
+
+// SaveUserResources 保存用户被授权的资源
+// @Summary 保存用户授权的资源
+// @Description 覆盖保存非管理员用户被授权的某类资源，仅管理员可用
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Param id path integer true "用户ID"
+// @Param resource_type body string true "资源类型：sync_path、scrape_path、account"
+// @Param resource_ids body []integer true "资源ID列表"
+// @Success 200 {object} object
+// @Failure 200 {object} object
+// @Router /users/{id}/resources [post]
+// @Security JwtAuth
+// @Security ApiKeyAuth
+func SaveUserResources(c *gin.Context) {
+	var req struct {
+		ResourceType models.ResourceType `json:"resource_type" binding:"required"`
+		ResourceIds  []uint              `json:"resource_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("参数错误：%v", err), Data: nil})
+		return
+	}
+	if !models.IsValidResourceType(req.ResourceType) {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "无效的资源类型", Data: nil})
+		return
+	}
+	user, err := models.GetUserById(uint(helpers.StringToInt(c.Param("id"))))
+	if err != nil || user.ID == 0 {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: "用户不存在", Data: nil})
+		return
+	}
+	if err := models.SaveUserResources(user.ID, req.ResourceType, req.ResourceIds); err != nil {
+		c.JSON(http.StatusOK, APIResponse[any]{Code: BadRequest, Message: fmt.Sprintf("保存授权失败：%v", err), Data: nil})
+		return
+	}
+	c.JSON(http.StatusOK, APIResponse[any]{Code: Success, Message: "保存授权成功", Data: nil})
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"Q115-STRM/internal/davfs"
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+)
+
+// WebDAV服务的路由前缀
+const WebDavPrefix = "/dav"
+
+// 需要注册到WebDAV路由的请求方法，只读服务只处理OPTIONS、GET、HEAD、PROPFIND，其他方法返回405
+var WebDavMethods = []string{
+	"OPTIONS", http.MethodGet, http.MethodHead, "PROPFIND",
+	http.MethodPost, http.MethodPut, http.MethodDelete, "MKCOL", "COPY", "MOVE", "PROPPATCH", "LOCK", "UNLOCK",
+}
+
+// 重定向到播放地址时签名的有效期，单位秒
+const webDavSignExpire = 4 * 3600
+
+// 认证成功的缓存时间，单位秒，避免每个请求都校验一次密码
+const webDavAuthExpire = 300
+
+var webDavFS = davfs.NewSyncFileSystem()
+
+var webDavHandler = &webdav.Handler{
+	Prefix:     WebDavPrefix,
+	FileSystem: webDavFS,
+	LockSystem: webdav.NewMemLS(),
+	Logger: func(r *http.Request, err error) {
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			helpers.AppLogger.Warnf("WebDAV请求 %s %s 失败: %v", r.Method, r.URL.Path, err)
+		}
+	},
+}
+
+// WebDav 只读WebDAV服务
+// @Summary WebDAV服务
+// @Description 以只读WebDAV的方式浏览所有同步路径，目录和文件信息来自同步记录，GET文件会302跳转到直链，本地文件直接返回内容。使用Basic认证，密码可以是登录密码或者API Key，需要playback权限，非管理员只能看到被授权的同步路径
+// @Tags WebDAV
+// @Produce xml
+// @Param path path string false "同步路径目录名及其下的相对路径"
+// @Success 207 {string} string "PROPFIND结果"
+// @Success 302 {string} string "跳转到播放地址"
+// @Failure 401 {string} string "未认证"
+// @Failure 405 {string} string "不支持写入"
+// @Router /dav/{path} [get]
+func WebDav(c *gin.Context) {
+	user := checkWebDavAuth(c)
+	if user == nil {
+		return
+	}
+	method := c.Request.Method
+	switch method {
+	case "OPTIONS", http.MethodGet, http.MethodHead, "PROPFIND":
+	default:
+		c.Header("Allow", "OPTIONS, GET, HEAD, PROPFIND")
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := davfs.WithRequestCache(c.Request.Context())
+	if ids, all := user.GetResourceIds(models.ResourceTypeSyncPath); !all {
+		ctx = davfs.WithAllowedSyncPaths(ctx, ids)
+	}
+	req := c.Request.WithContext(ctx)
+	if method == http.MethodGet || method == http.MethodHead {
+		name := strings.TrimPrefix(req.URL.Path, WebDavPrefix)
+		node, err := webDavFS.Lookup(ctx, name)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if !node.IsDir() {
+			serveWebDavFile(c, node)
+			return
+		}
+	}
+	if method == "PROPFIND" {
+		// 不允许无限深度，否则一次请求会遍历整个同步路径
+		depth := req.Header.Get("Depth")
+		if depth == "" || strings.EqualFold(depth, "infinity") {
+			req.Header.Set("Depth", "1")
+		}
+	}
+	webDavHandler.ServeHTTP(c.Writer, req)
+}
+
+// 校验Basic认证，用户名和密码使用登录账号，也可以在密码中填写API Key（用户名任意）
+// 需要有playback权限，返回认证通过的用户，失败返回nil
+func checkWebDavAuth(c *gin.Context) *models.User {
+	username, password, ok := c.Request.BasicAuth()
+	if ok && password != "" {
+		// 缓存key使用凭据的SHA256，不在内存缓存中保存明文密码或者API Key
+		credential := sha256.Sum256([]byte(username + "\x00" + password))
+		cacheKey := "webdav:auth:" + hex.EncodeToString(credential[:])
+		if userId := helpers.StringToInt(string(db.Cache.Get(cacheKey))); userId > 0 {
+			if user, err := models.GetUserById(uint(userId)); err == nil {
+				return user
+			}
+		}
+		var user *models.User
+		var scopes []string
+		if u, err := models.CheckLogin(username, password); err == nil {
+			user = u
+			scopes = u.GetScopes()
+		} else if apiKey, err := models.ValidateAPIKey(password); err == nil && apiKey != nil {
+			if u, err := models.GetUserById(apiKey.UserID); err == nil {
+				go apiKey.UpdateLastUsedAt()
+				user = u
+				scopes = apiKey.GetEffectiveScopes(u)
+			}
+		}
+		if user != nil && slices.Contains(scopes, models.ScopePlayback) {
+			db.Cache.Set(cacheKey, []byte(fmt.Sprintf("%d", user.ID)), webDavAuthExpire)
+			return user
+		}
+		helpers.AppLogger.Warnf("WebDAV认证失败: 用户名=%s, ip=%s", username, c.ClientIP())
+	}
+	c.Header("WWW-Authenticate", `Basic realm="QMediaSync WebDAV", charset="UTF-8"`)
+	c.AbortWithStatus(http.StatusUnauthorized)
+	return nil
+}
+
+// 返回文件内容：网盘文件跳转到直链地址，本地文件直接返回
+func serveWebDavFile(c *gin.Context, node *davfs.Node) {
+	file := node.File
+	switch node.SyncPath.SourceType {
+	case models.SourceTypeLocal:
+		// 本地文件的PickCode是完整路径
+		f, err := os.Open(file.PickCode)
+		if err != nil {
+			helpers.AppLogger.Errorf("WebDAV打开本地文件 %s 失败: %v", file.PickCode, err)
+			c.Status(http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+		stat, err := f.Stat()
+		if err != nil || stat.IsDir() {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(c.Writer, c.Request, stat.Name(), stat.ModTime(), f)
+	case models.SourceTypeOpenList:
+		// OpenList文件的PickCode是完整的下载地址
+		c.Redirect(http.StatusFound, file.PickCode)
+	default:
+		playUrl, err := makeWebDavPlayUrl(node.SyncPath, file)
+		if err != nil {
+			helpers.AppLogger.Errorf("WebDAV生成文件 %s 的播放地址失败: %v", file.FileName, err)
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Redirect(http.StatusFound, playUrl)
+	}
+}
+
+// 生成网盘文件的播放地址，和STRM文件中的地址相同，由对应的直链接口再跳转到网盘直链
+func makeWebDavPlayUrl(syncPath *models.SyncPath, file *models.SyncFile) (string, error) {
+	var prefix string
+	switch syncPath.SourceType {
+	case models.SourceType115:
+		prefix = "/115/url"
+	case models.SourceType123:
+		prefix = "/123/url"
+	case models.SourceTypeBaiduPan:
+		prefix = "/baidupan/url"
+	default:
+		return "", fmt.Errorf("不支持的来源类型 %s", syncPath.SourceType)
+	}
+	account, err := models.GetAccountById(syncPath.AccountId)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Add("pickcode", file.PickCode)
+	params.Add("userid", account.UserId)
+	if models.GetStrmSignMode() != models.StrmSignModeOff {
+		kid, exp, sign, err := models.SignStrm(file.PickCode, account.UserId, webDavSignExpire)
+		if err != nil {
+			return "", err
+		}
+		params.Add("kid", fmt.Sprintf("%d", kid))
+		params.Add("exp", fmt.Sprintf("%d", exp))
+		params.Add("sign", sign)
+	}
+	return fmt.Sprintf("%s/video%s?%s", prefix, filepath.Ext(file.FileName), params.Encode()), nil
+}
@@ -0,0 +1,370 @@
+package davfs
+
+import (
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/v115open"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// 文件内容不通过WebDAV读取，GET请求会被重定向到直链
+var ErrNotReadable = errors.New("WebDAV不提供文件内容，请通过重定向地址播放")
+
+// 只读的WebDAV文件系统
+// 根目录下每个同步路径是一个目录，同步路径下的目录结构和文件信息全部来自SyncFile表，不访问网盘
+type SyncFileSystem struct{}
+
+func NewSyncFileSystem() *SyncFileSystem {
+	return &SyncFileSystem{}
+}
+
+// WebDAV路径对应的节点
+type Node struct {
+	SyncPath *models.SyncPath // 根目录为nil
+	File     *models.SyncFile // 根目录、同步路径目录和没有目录记录的中间目录为nil
+	DirPath  string           // 目录在来源中的路径，去掉了开头和结尾的/，只有目录才有
+	name     string
+	isDir    bool
+	modTime  int64
+}
+
+func (n *Node) IsDir() bool {
+	return n.isDir
+}
+
+func (n *Node) info() *fileInfo {
+	fi := &fileInfo{name: n.name, isDir: n.isDir, modTime: n.modTime}
+	if n.File != nil {
+		fi.size = n.File.FileSize
+	}
+	return fi
+}
+
+// 一次请求内的查询缓存
+// PROPFIND列目录时会对每个子项分别调用Stat和OpenFile，有缓存就不用每个子项都查一次数据库
+type requestCache struct {
+	mu        sync.Mutex
+	syncPaths map[string]*models.SyncPath
+	nodes     map[string]*Node
+}
+
+type requestCacheKey struct{}
+
+// 给请求的context添加查询缓存
+func WithRequestCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestCacheKey{}, &requestCache{nodes: make(map[string]*Node)})
+}
+
+func getRequestCache(ctx context.Context) *requestCache {
+	cache, _ := ctx.Value(requestCacheKey{}).(*requestCache)
+	return cache
+}
+
+type allowedSyncPathsKey struct{}
+
+// 限制请求只能看到指定的同步路径，没有设置时可以看到全部
+func WithAllowedSyncPaths(ctx context.Context, ids []uint) context.Context {
+	return context.WithValue(ctx, allowedSyncPathsKey{}, ids)
+}
+
+func isSyncPathAllowed(ctx context.Context, id uint) bool {
+	ids, ok := ctx.Value(allowedSyncPathsKey{}).([]uint)
+	return !ok || slices.Contains(ids, id)
+}
+
+func (c *requestCache) getNode(name string) *Node {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nodes[name]
+}
+
+func (c *requestCache) setNode(name string, node *Node) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nodes[name] = node
+}
+
+// 根目录下的同步路径，key是目录名
+func (fs *SyncFileSystem) syncPaths(ctx context.Context) map[string]*models.SyncPath {
+	cache := getRequestCache(ctx)
+	if cache != nil {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		if cache.syncPaths != nil {
+			return cache.syncPaths
+		}
+	}
+	// 先按全部同步路径生成目录名，保证不同用户看到的目录名一致
+	syncPaths := SyncPathDirNames(models.GetAllSyncPaths())
+	for name, syncPath := range syncPaths {
+		if !isSyncPathAllowed(ctx, syncPath.ID) {
+			delete(syncPaths, name)
+		}
+	}
+	if cache != nil {
+		cache.syncPaths = syncPaths
+	}
+	return syncPaths
+}
+
+// 生成同步路径在根目录下的目录名，使用来源路径的最后一级，重名的在后面加上同步路径ID
+func SyncPathDirNames(syncPaths []*models.SyncPath) map[string]*models.SyncPath {
+	baseNames := make(map[uint]string, len(syncPaths))
+	count := make(map[string]int)
+	for _, syncPath := range syncPaths {
+		name := path.Base(normalizePath(syncPath.RemotePath))
+		if name == "" || name == "." || name == "/" {
+			name = fmt.Sprintf("同步路径%d", syncPath.ID)
+		}
+		baseNames[syncPath.ID] = name
+		count[name]++
+	}
+	result := make(map[string]*models.SyncPath, len(syncPaths))
+	for _, syncPath := range syncPaths {
+		name := baseNames[syncPath.ID]
+		if count[name] > 1 {
+			name = fmt.Sprintf("%s (%d)", name, syncPath.ID)
+		}
+		result[name] = syncPath
+	}
+	return result
+}
+
+// 统一路径格式：转换为/分隔，去掉开头和结尾的/
+func normalizePath(p string) string {
+	return strings.Trim(filepath.ToSlash(p), "/")
+}
+
+// 同一个目录在SyncFile表中可能的几种写法
+func pathVariants(p string) []string {
+	if p == "" {
+		return []string{"", "/"}
+	}
+	return []string{p, "/" + p, p + "/", "/" + p + "/"}
+}
+
+// 解析WebDAV路径
+func (fs *SyncFileSystem) Lookup(ctx context.Context, name string) (*Node, error) {
+	name = path.Clean("/" + name)
+	cache := getRequestCache(ctx)
+	if node := cache.getNode(name); node != nil {
+		return node, nil
+	}
+	node, err := fs.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	cache.setNode(name, node)
+	return node, nil
+}
+
+func (fs *SyncFileSystem) lookup(ctx context.Context, name string) (*Node, error) {
+	if name == "/" {
+		return &Node{name: "/", isDir: true}, nil
+	}
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	syncPath, ok := fs.syncPaths(ctx)[segments[0]]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	rootPath := normalizePath(syncPath.RemotePath)
+	if len(segments) == 1 {
+		return &Node{SyncPath: syncPath, DirPath: rootPath, name: segments[0], isDir: true, modTime: syncPath.LastSyncAt}, nil
+	}
+	parentPath := path.Join(append([]string{rootPath}, segments[1:len(segments)-1]...)...)
+	fileName := segments[len(segments)-1]
+	file := models.GetSyncFileByPathAndName(syncPath.ID, pathVariants(normalizePath(parentPath)), fileName)
+	if file != nil {
+		return newFileNode(syncPath, file, parentPath), nil
+	}
+	// 路径补全等情况下中间目录可能没有记录，有子项就认为目录存在
+	dirPath := normalizePath(path.Join(parentPath, fileName))
+	if models.HasSyncFilesInPath(syncPath.ID, pathVariants(dirPath)) {
+		return &Node{SyncPath: syncPath, DirPath: dirPath, name: fileName, isDir: true}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func newFileNode(syncPath *models.SyncPath, file *models.SyncFile, parentPath string) *Node {
+	node := &Node{SyncPath: syncPath, File: file, name: file.FileName, modTime: file.MTime}
+	if node.modTime == 0 {
+		node.modTime = file.UpdatedAt
+	}
+	if file.FileType == v115open.TypeDir {
+		node.isDir = true
+		node.DirPath = normalizePath(path.Join(parentPath, file.FileName))
+	}
+	return node
+}
+
+// 列出目录的子项，同时写入请求缓存
+func (fs *SyncFileSystem) readDir(ctx context.Context, name string, node *Node) ([]os.FileInfo, error) {
+	cache := getRequestCache(ctx)
+	infos := make([]os.FileInfo, 0)
+	if node.SyncPath == nil {
+		for dirName, syncPath := range fs.syncPaths(ctx) {
+			child := &Node{SyncPath: syncPath, DirPath: normalizePath(syncPath.RemotePath), name: dirName, isDir: true, modTime: syncPath.LastSyncAt}
+			cache.setNode(path.Join(name, dirName), child)
+			infos = append(infos, child.info())
+		}
+		return infos, nil
+	}
+	files, err := models.GetSyncFilesByPath(node.SyncPath.ID, pathVariants(node.DirPath))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		child := newFileNode(node.SyncPath, file, node.DirPath)
+		cache.setNode(path.Join(name, file.FileName), child)
+		infos = append(infos, child.info())
+	}
+	return infos, nil
+}
+
+func (fs *SyncFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (fs *SyncFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+	node, err := fs.Lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &davFile{fs: fs, ctx: ctx, name: path.Clean("/" + name), node: node}, nil
+}
+
+func (fs *SyncFileSystem) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (fs *SyncFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+func (fs *SyncFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	node, err := fs.Lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(), nil
+}
+
+// WebDAV文件，只能列目录和查看信息
+type davFile struct {
+	fs       *SyncFileSystem
+	ctx      context.Context
+	name     string
+	node     *Node
+	children []os.FileInfo
+	loaded   bool
+	pos      int
+}
+
+func (f *davFile) Close() error {
+	return nil
+}
+
+func (f *davFile) Read(p []byte) (int, error) {
+	return 0, ErrNotReadable
+}
+
+func (f *davFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
+
+func (f *davFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *davFile) Stat() (os.FileInfo, error) {
+	return f.node.info(), nil
+}
+
+func (f *davFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.node.isDir {
+		return nil, os.ErrInvalid
+	}
+	if !f.loaded {
+		children, err := f.fs.readDir(f.ctx, f.name, f.node)
+		if err != nil {
+			return nil, err
+		}
+		f.children = children
+		f.loaded = true
+	}
+	if count <= 0 {
+		rest := f.children[f.pos:]
+		f.pos = len(f.children)
+		return rest, nil
+	}
+	if f.pos >= len(f.children) {
+		return nil, io.EOF
+	}
+	end := min(f.pos+count, len(f.children))
+	rest := f.children[f.pos:end]
+	f.pos = end
+	return rest, nil
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime int64
+	isDir   bool
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.isDir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return time.Unix(fi.modTime, 0)
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.isDir
+}
+
+func (fi *fileInfo) Sys() any {
+	return nil
+}
+
+// 按扩展名返回内容类型，默认实现会读取文件开头来判断，这里文件不可读
+func (fi *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if ctype := mime.TypeByExtension(path.Ext(fi.name)); ctype != "" {
+		return ctype, nil
+	}
+	return "application/octet-stream", nil
+}
@@ -0,0 +1,107 @@
+package davfs
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/v115open"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"golang.org/x/net/webdav"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupTestDb(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.SyncPath{}, &models.SyncFile{}); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+	db.Db = gdb
+	syncPath := &models.SyncPath{RemotePath: "/媒体/电影", SourceType: models.SourceType115, BaseCid: "100"}
+	syncPath.ID = 1
+	db.Db.Create(syncPath)
+	files := []*models.SyncFile{
+		{SyncPathId: 1, FileId: "200", Path: "媒体/电影", FileName: "A (2020)", FileType: v115open.TypeDir},
+		{SyncPathId: 1, FileId: "201", Path: "媒体/电影/A (2020)", FileName: "A.mkv", FileType: v115open.TypeFile, FileSize: 1024, PickCode: "pc201", IsVideo: true},
+		// 没有目录记录的中间目录
+		{SyncPathId: 1, FileId: "301", Path: "媒体/电影/B (2021)", FileName: "B.mkv", FileType: v115open.TypeFile, PickCode: "pc301", IsVideo: true},
+	}
+	for _, file := range files {
+		db.Db.Create(file)
+	}
+}
+
+func TestSyncPathDirNames(t *testing.T) {
+	syncPaths := []*models.SyncPath{
+		{BaseModel: models.BaseModel{ID: 1}, RemotePath: "/媒体/电影"},
+		{BaseModel: models.BaseModel{ID: 2}, RemotePath: "备份/电影/"},
+		{BaseModel: models.BaseModel{ID: 3}, RemotePath: "D:/剧集"},
+		{BaseModel: models.BaseModel{ID: 4}, RemotePath: "/"},
+	}
+	names := SyncPathDirNames(syncPaths)
+	expected := map[string]uint{"电影 (1)": 1, "电影 (2)": 2, "剧集": 3, "同步路径4": 4}
+	if len(names) != len(expected) {
+		t.Fatalf("目录数量不正确: %v", names)
+	}
+	for name, id := range expected {
+		if sp, ok := names[name]; !ok || sp.ID != id {
+			t.Errorf("目录 %s 应该对应同步路径 %d", name, id)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	setupTestDb(t)
+	fs := NewSyncFileSystem()
+	ctx := WithRequestCache(context.Background())
+	node, err := fs.Lookup(ctx, "/电影/A (2020)/A.mkv")
+	if err != nil {
+		t.Fatalf("查找文件失败: %v", err)
+	}
+	if node.IsDir() || node.File == nil || node.File.PickCode != "pc201" {
+		t.Errorf("文件节点不正确: %+v", node)
+	}
+	node, err = fs.Lookup(ctx, "/电影/B (2021)")
+	if err != nil || !node.IsDir() || node.DirPath != "媒体/电影/B (2021)" {
+		t.Errorf("没有目录记录的中间目录应该存在: %+v, %v", node, err)
+	}
+	if _, err := fs.Lookup(ctx, "/电影/C"); err == nil {
+		t.Errorf("不存在的路径应该返回错误")
+	}
+	if _, err := fs.Lookup(ctx, "/其他"); err == nil {
+		t.Errorf("不存在的同步路径应该返回错误")
+	}
+}
+
+func TestPropfind(t *testing.T) {
+	setupTestDb(t)
+	handler := &webdav.Handler{Prefix: "/dav", FileSystem: NewSyncFileSystem(), LockSystem: webdav.NewMemLS()}
+	req := httptest.NewRequest("PROPFIND", "/dav/电影/A%20(2020)/", nil)
+	req.Header.Set("Depth", "1")
+	req = req.WithContext(WithRequestCache(req.Context()))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND状态码不正确: %d %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "A.mkv") || !strings.Contains(body, "<D:getcontentlength>1024</D:getcontentlength>") {
+		t.Errorf("PROPFIND结果不正确: %s", body)
+	}
+	// 只读文件系统不允许创建目录
+	req = httptest.NewRequest("MKCOL", "/dav/电影/新目录", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code < 400 {
+		t.Errorf("创建目录应该失败: %d", w.Code)
+	}
+}
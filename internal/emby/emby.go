@@ -3,9 +3,11 @@ package emby
 import (
 	embyclientrestgo "Q115-STRM/internal/embyclient-rest-go"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/mediaserver"
 	"Q115-STRM/internal/models"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
 	"strings"
 	"sync"
@@ -31,43 +33,61 @@ func SetEmbySyncRunning(running bool) {
 type embySyncTask struct {
 	LibraryId   string
 	LibraryName string
-	Item        embyclientrestgo.BaseItemDtoV2
+	Item        mediaserver.Item
 }
 
-// 同步Emby媒体库到本地数据库
+var (
+	serverMu     sync.Mutex
+	cachedServer mediaserver.MediaServer
+	cachedKey    string
+)
+
+// 获取当前配置的媒体服务器客户端，配置不变时复用，避免重复查询Emby用户
+func getMediaServer(config *models.EmbyConfig) (mediaserver.MediaServer, error) {
+	key := string(config.GetServerType()) + "|" + config.EmbyUrl + "|" + config.EmbyApiKey
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	if cachedServer != nil && cachedKey == key {
+		return cachedServer, nil
+	}
+	server, err := config.NewMediaServer()
+	if err != nil {
+		return nil, err
+	}
+	cachedServer = server
+	cachedKey = key
+	return server, nil
+}
+
+// 同步媒体服务器的媒体库到本地数据库
 func PerformEmbySync() (int, error) {
 	// 检查是否已有任务在运行，避免并发执行
 	if IsEmbySyncRunning() {
-		helpers.AppLogger.Warnf("Emby同步任务已在运行，跳过本次定时执行")
+		helpers.AppLogger.Warnf("媒体服务器同步任务已在运行，跳过本次定时执行")
 		return 0, nil
 	}
 	config, cerr := models.GetEmbyConfig()
-	if config.EmbyUrl == "" || config.EmbyApiKey == "" {
-		return 0, errors.New("Emby Url或ApiKey为空")
-	}
 	if cerr != nil || config.SyncEnabled != 1 {
-		return 0, errors.New("Emby同步未启用")
+		return 0, errors.New("媒体服务器同步未启用")
 	}
-	if !atomic.CompareAndSwapInt32(&embySyncRunning, 0, 1) {
-		return 0, errors.New("Emby同步任务已在运行")
+	if config.EmbyUrl == "" || config.EmbyApiKey == "" {
+		return 0, errors.New("媒体服务器地址或密钥为空")
 	}
-	defer atomic.StoreInt32(&embySyncRunning, 0)
-
-	client := embyclientrestgo.NewClient(config.EmbyUrl, config.EmbyApiKey)
-	users, err := client.GetUsersWithAllLibrariesAccess()
+	server, err := getMediaServer(config)
 	if err != nil {
 		return 0, err
 	}
-	if len(users) == 0 {
-		return 0, errors.New("没有找到可访问全部媒体库的Emby用户")
+	if !atomic.CompareAndSwapInt32(&embySyncRunning, 0, 1) {
+		return 0, errors.New("媒体服务器同步任务已在运行")
 	}
+	defer atomic.StoreInt32(&embySyncRunning, 0)
 
-	libs, err := client.GetAllMediaLibraries()
+	libs, err := server.GetLibraries()
 	if err != nil {
 		return 0, err
 	}
 	if len(libs) == 0 {
-		return 0, errors.New("未获取到任何Emby媒体库")
+		return 0, fmt.Errorf("未获取到任何%s媒体库", server.Type().DisplayName())
 	}
 	if err := models.UpsertEmbyLibraries(libs); err != nil {
 		helpers.AppLogger.Warnf("保存媒体库信息失败: %v", err)
@@ -78,13 +98,13 @@ func PerformEmbySync() (int, error) {
 		var selectedLibIds []string
 		if err := json.Unmarshal([]byte(config.SelectedLibraries), &selectedLibIds); err == nil {
 			// 创建 ID 到库的映射
-			libMap := make(map[string]embyclientrestgo.EmbyLibrary)
+			libMap := make(map[string]mediaserver.Library)
 			for _, lib := range libs {
 				libMap[lib.ID] = lib
 			}
 
 			// 只保留选中的媒体库
-			filteredLibs := make([]embyclientrestgo.EmbyLibrary, 0, len(selectedLibIds))
+			filteredLibs := make([]mediaserver.Library, 0, len(selectedLibIds))
 			for _, id := range selectedLibIds {
 				if lib, ok := libMap[id]; ok {
 					filteredLibs = append(filteredLibs, lib)
@@ -113,74 +133,18 @@ func PerformEmbySync() (int, error) {
 	var mu sync.Mutex
 	validItemIds := make([]string, 0, 256)
 	var processed int64
-	// clientHttp := &http.Client{Timeout: 30 * time.Second}
 
 	worker := func() {
 		defer wg.Done()
 		for task := range jobs {
-			pickCode, mediaPath, err := extractPickCode(task.Item.MediaSources)
-			// pickCode, mediaPath := "", ""
-			if err != nil {
-				// helpers.AppLogger.Warnf("从MediaSource中查询PickCode失败 item=%s name=%s path=%s err=%v", task.Item.Id, task.Item.Name, mediaPath, err)
-				// 没有pickcode不入库
-				continue
-			}
-			pathStr := mediaPath
-			if pathStr == "" {
-				pathStr = task.Item.Path
-			}
-			mediaItem := &models.EmbyMediaItem{
-				ItemId:            task.Item.Id,
-				ItemIdInt:         helpers.StringToInt64(task.Item.Id),
-				ServerId:          "",
-				Name:              task.Item.Name,
-				Type:              task.Item.Type,
-				ParentId:          task.Item.ParentId,
-				SeriesId:          task.Item.SeriesId,
-				SeasonId:          task.Item.SeasonId,
-				SeasonName:        task.Item.SeasonName,
-				SeriesName:        task.Item.SeriesName,
-				LibraryId:         task.LibraryId,
-				Path:              pathStr,
-				PickCode:          pickCode,
-				MediaSourcePath:   mediaPath,
-				IndexNumber:       task.Item.IndexNumber,
-				ParentIndexNumber: task.Item.ParentIndexNumber,
-				ProductionYear:    task.Item.ProductionYear,
-				PremiereDate:      task.Item.PremiereDate,
-				DateCreated:       task.Item.DateCreated,
-				DateModified:      task.Item.DateModified,
-				IsFolder:          task.Item.IsFolder,
-			}
-			// 将DateCreated转成时间戳赋值给DateCreatedTime
-			if mediaItem.DateCreated != "" {
-				if t, err := time.Parse(time.RFC3339, mediaItem.DateCreated); err == nil {
-					mediaItem.DateCreatedTime = t.Unix()
-				}
-			}
-			// 将DateModified转成时间戳赋值给DateModifiedTime
-			if mediaItem.DateModified != "" {
-				if t, err := time.Parse(time.RFC3339, mediaItem.DateModified); err == nil {
-					mediaItem.DateModifiedTime = t.Unix()
-				}
-			}
-			if err := models.CreateOrUpdateEmbyMediaItem(mediaItem); err != nil {
-				helpers.AppLogger.Errorf("保存Emby媒体项失败 id=%s name=%s err=%v", task.Item.Id, task.Item.Name, err)
+			if !saveMediaItem(&task.Item, task.LibraryId, task.LibraryName) {
 				continue
 			}
 			mu.Lock()
 			validItemIds = append(validItemIds, task.Item.Id)
 			mu.Unlock()
 			atomic.AddInt64(&processed, 1)
-			if pickCode != "" {
-				if sf := models.GetFileByPickCode(pickCode); sf != nil {
-					if err := models.CreateEmbyMediaSyncFile(task.Item.Id, sf.ID, pickCode, sf.SyncPathId); err != nil {
-						helpers.AppLogger.Warnf("关联SyncFile失败 item=%s pickcode=%s err=%v", task.Item.Id, pickCode, err)
-					}
-					models.CreateOrUpdateEmbyLibrarySyncPath(task.LibraryId, sf.SyncPathId, task.LibraryName)
-				}
-			}
-			time.Sleep(100 * time.Millisecond) // 休息100毫秒，避免对Emby API的过度请求，也让其他协程有机会写入数据库
+			time.Sleep(100 * time.Millisecond) // 休息100毫秒，避免对媒体服务器API的过度请求，也让其他协程有机会写入数据库
 		}
 	}
 
@@ -190,7 +154,7 @@ func PerformEmbySync() (int, error) {
 	}
 
 	for _, lib := range libs {
-		items, gerr := client.GetMediaItemsByLibraryID(lib.ID, 0)
+		items, gerr := server.GetLibraryItems(lib.ID, 0)
 		if gerr != nil {
 			helpers.AppLogger.Warnf("获取媒体库%s失败: %v", lib.Name, gerr)
 			continue
@@ -204,13 +168,13 @@ func PerformEmbySync() (int, error) {
 
 	if processed > 0 {
 		if err := models.CleanupOrphanedEmbyMediaItems(validItemIds); err != nil {
-			helpers.AppLogger.Warnf("清理过期Emby媒体项失败: %v", err)
+			helpers.AppLogger.Warnf("清理过期媒体项失败: %v", err)
 		}
 	}
 	if err := models.UpdateLastSyncTime(); err != nil {
-		helpers.AppLogger.Warnf("更新Emby最后同步时间失败: %v", err)
+		helpers.AppLogger.Warnf("更新媒体服务器最后同步时间失败: %v", err)
 	}
-	helpers.AppLogger.Infof("Emby同步完成，处理 %d 个项目", processed)
+	helpers.AppLogger.Infof("%s同步完成，处理 %d 个项目", server.Type().DisplayName(), processed)
 	return int(processed), nil
 }
 
@@ -218,31 +182,27 @@ func PerformEmbySync() (int, error) {
 func IncrementalSyncEmbyMediaItems(itemId string) error {
 	// 检查是否已有任务在运行，避免并发执行
 	if IsEmbySyncRunning() {
-		helpers.AppLogger.Warnf("Emby同步任务已在运行，跳过本次定时执行")
+		helpers.AppLogger.Warnf("媒体服务器同步任务已在运行，跳过本次定时执行")
 		return nil
 	}
 	config, cerr := models.GetEmbyConfig()
-	if config.EmbyUrl == "" || config.EmbyApiKey == "" {
-		return errors.New("Emby Url或ApiKey为空")
-	}
 	if cerr != nil || config.SyncEnabled != 1 {
-		return errors.New("Emby同步未启用")
+		return errors.New("媒体服务器同步未启用")
 	}
-	if !atomic.CompareAndSwapInt32(&embySyncRunning, 0, 1) {
-		return errors.New("Emby同步任务已在运行")
+	if config.EmbyUrl == "" || config.EmbyApiKey == "" {
+		return errors.New("媒体服务器地址或密钥为空")
 	}
-	defer atomic.StoreInt32(&embySyncRunning, 0)
-
-	client := embyclientrestgo.NewClient(config.EmbyUrl, config.EmbyApiKey)
-	users, err := client.GetUsersWithAllLibrariesAccess()
+	server, err := getMediaServer(config)
 	if err != nil {
 		return err
 	}
-	if len(users) == 0 {
-		return errors.New("没有找到可访问全部媒体库的Emby用户")
+	if !atomic.CompareAndSwapInt32(&embySyncRunning, 0, 1) {
+		return errors.New("媒体服务器同步任务已在运行")
 	}
+	defer atomic.StoreInt32(&embySyncRunning, 0)
+
 	// 查询item id 所属的媒体库
-	librarys, err := client.GetItemLibraryId(itemId)
+	librarys, err := server.GetItemLibraries(itemId)
 	if err != nil {
 		return err
 	}
@@ -255,86 +215,81 @@ func IncrementalSyncEmbyMediaItems(itemId string) error {
 			helpers.AppLogger.Warnf("获取媒体库%s最后一此同步时间失败，可能是因为没有同步过任何媒体项", lib.ID)
 			continue
 		}
-		items, gerr := client.GetMediaItemsByLibraryID(lib.ID, lastDateCreatedTime)
+		items, gerr := server.GetLibraryItems(lib.ID, lastDateCreatedTime)
 		if gerr != nil {
 			helpers.AppLogger.Warnf("获取媒体库%s失败: %v", lib.ID, gerr)
 			continue
 		}
 		for _, item := range items {
-			pickCode, mediaPath, err := extractPickCode(item.MediaSources)
-			// pickCode, mediaPath := "", ""
-			if err != nil {
-				continue
-			}
-			pathStr := mediaPath
-			if pathStr == "" {
-				pathStr = item.Path
-			}
-			mediaItem := &models.EmbyMediaItem{
-				ItemId:            item.Id,
-				ItemIdInt:         helpers.StringToInt64(item.Id),
-				ServerId:          "",
-				Name:              item.Name,
-				Type:              item.Type,
-				ParentId:          item.ParentId,
-				SeriesId:          item.SeriesId,
-				SeasonId:          item.SeasonId,
-				SeasonName:        item.SeasonName,
-				SeriesName:        item.SeriesName,
-				LibraryId:         lib.ID,
-				Path:              pathStr,
-				PickCode:          pickCode,
-				MediaSourcePath:   mediaPath,
-				IndexNumber:       item.IndexNumber,
-				ParentIndexNumber: item.ParentIndexNumber,
-				ProductionYear:    item.ProductionYear,
-				PremiereDate:      item.PremiereDate,
-				DateCreated:       item.DateCreated,  // 2026-01-21T16:00:00.0000000Z
-				DateModified:      item.DateModified, // 2026-01-21T16:00:00.0000000Z
-				IsFolder:          item.IsFolder,
-			}
-			// 将DateCreated转成时间戳赋值给DateCreatedTime
-			if item.DateCreated != "" {
-				if t, err := time.Parse(time.RFC3339, item.DateCreated); err == nil {
-					mediaItem.DateCreatedTime = t.Unix()
-				}
-			}
-			// 将DateModified转成时间戳赋值给DateModifiedTime
-			if item.DateModified != "" {
-				if t, err := time.Parse(time.RFC3339, item.DateModified); err == nil {
-					mediaItem.DateModifiedTime = t.Unix()
-				}
-			}
-			if err := models.CreateOrUpdateEmbyMediaItem(mediaItem); err != nil {
-				helpers.AppLogger.Errorf("保存Emby媒体项失败 id=%s name=%s err=%v", item.Id, item.Name, err)
+			if !saveMediaItem(&item, lib.ID, lib.Name) {
 				continue
 			}
-			if pickCode != "" {
-				if sf := models.GetFileByPickCode(pickCode); sf != nil {
-					if err := models.CreateEmbyMediaSyncFile(item.Id, sf.ID, pickCode, sf.SyncPathId); err != nil {
-						helpers.AppLogger.Warnf("关联SyncFile失败 item=%s pickcode=%s err=%v", item.Id, pickCode, err)
-					}
-					models.CreateOrUpdateEmbyLibrarySyncPath(lib.ID, sf.SyncPathId, lib.Name)
-				}
-			}
-			time.Sleep(100 * time.Millisecond) // 休息100毫秒，避免对Emby API的过度请求，也让其他协程有机会写入数据库
+			time.Sleep(100 * time.Millisecond) // 休息100毫秒，避免对媒体服务器API的过度请求
 		}
 	}
 
 	return nil
 }
 
-func extractPickCode(ms []embyclientrestgo.MediaSource) (string, string, error) {
+// 保存媒体项并关联到SyncFile，媒体源路径中没有pickcode的不入库
+func saveMediaItem(item *mediaserver.Item, libraryId string, libraryName string) bool {
+	pickCode, mediaPath, err := extractPickCode(item.MediaSourcePaths)
+	if err != nil {
+		return false
+	}
+	pathStr := mediaPath
+	if pathStr == "" {
+		pathStr = item.Path
+	}
+	mediaItem := &models.EmbyMediaItem{
+		ItemId:            item.Id,
+		ItemIdInt:         helpers.StringToInt64(item.Id),
+		ServerId:          "",
+		Name:              item.Name,
+		Type:              item.Type,
+		ParentId:          item.ParentId,
+		SeriesId:          item.SeriesId,
+		SeasonId:          item.SeasonId,
+		SeasonName:        item.SeasonName,
+		SeriesName:        item.SeriesName,
+		LibraryId:         libraryId,
+		Path:              pathStr,
+		PickCode:          pickCode,
+		MediaSourcePath:   mediaPath,
+		IndexNumber:       item.IndexNumber,
+		ParentIndexNumber: item.ParentIndexNumber,
+		ProductionYear:    item.ProductionYear,
+		PremiereDate:      item.PremiereDate,
+		DateCreated:       item.DateCreated,
+		DateCreatedTime:   item.DateCreatedTime,
+		DateModified:      item.DateModified,
+		DateModifiedTime:  item.DateModifiedTime,
+		IsFolder:          item.IsFolder,
+	}
+	if err := models.CreateOrUpdateEmbyMediaItem(mediaItem); err != nil {
+		helpers.AppLogger.Errorf("保存媒体项失败 id=%s name=%s err=%v", item.Id, item.Name, err)
+		return false
+	}
+	if sf := models.GetFileByPickCode(pickCode); sf != nil {
+		if err := models.CreateEmbyMediaSyncFile(item.Id, sf.ID, pickCode, sf.SyncPathId); err != nil {
+			helpers.AppLogger.Warnf("关联SyncFile失败 item=%s pickcode=%s err=%v", item.Id, pickCode, err)
+		}
+		models.CreateOrUpdateEmbyLibrarySyncPath(libraryId, sf.SyncPathId, libraryName)
+	}
+	return true
+}
+
+func extractPickCode(paths []string) (string, string, error) {
 	code := ""
 	pathStr := ""
-	for _, src := range ms {
-		code = extractPickCodeFromPath(src.Path)
-		pathStr = src.Path
+	for _, p := range paths {
+		code = extractPickCodeFromPath(p)
+		pathStr = p
 		if code != "" {
 			return code, pathStr, nil
 		}
 	}
-	return code, pathStr, errors.New("未从Item.MediaSource.Path中解析到pickcode")
+	return code, pathStr, errors.New("未从媒体源路径中解析到pickcode")
 }
 
 func extractPickCodeFromPath(path string) string {
@@ -375,6 +330,10 @@ func StartParseEmbyMediaInfo() {
 		helpers.AppLogger.Info("Emby Url或ApiKey为空，无法同步emby库来提取视频信息")
 		return
 	}
+	if !models.GlobalEmbyConfig.IsEmby() {
+		helpers.AppLogger.Infof("提取视频信息只支持Emby，当前媒体服务器是%s", models.GlobalEmbyConfig.GetServerType().DisplayName())
+		return
+	}
 	EmbyMediaInfoStart = true
 	defer func() {
 		EmbyMediaInfoStart = false
@@ -394,32 +353,20 @@ func StartParseEmbyMediaInfo() {
 	}()
 }
 
-var embyUserId string = ""
-
-// 查询Emby媒体详情
-func GetEmbyItemDetail(itemId string) *embyclientrestgo.BaseItemDtoV2 {
+// 查询媒体服务器的媒体详情
+func GetEmbyItemDetail(itemId string) *mediaserver.ItemDetail {
 	if models.GlobalEmbyConfig.EmbyUrl == "" || models.GlobalEmbyConfig.EmbyApiKey == "" {
-		helpers.AppLogger.Info("Emby Url或ApiKey为空，无法查询Emby媒体详情")
+		helpers.AppLogger.Info("媒体服务器地址或密钥为空，无法查询媒体详情")
 		return nil
 	}
-	client := embyclientrestgo.NewClient(models.GlobalEmbyConfig.EmbyUrl, models.GlobalEmbyConfig.EmbyApiKey)
-	if embyUserId == "" {
-		// 获取有权限的用户
-		users, err := client.GetUsersWithAllLibrariesAccess()
-		if err != nil {
-			helpers.AppLogger.Errorf("获取用户失败: %v", err)
-			return nil
-		}
-		if len(users) == 0 {
-			helpers.AppLogger.Errorf("没有找到可以访问所有媒体库的用户")
-			return nil
-		}
-		// 使用第一个有权限的用户
-		embyUserId = users[0].ID
+	server, err := getMediaServer(models.GlobalEmbyConfig)
+	if err != nil {
+		helpers.AppLogger.Errorf("创建媒体服务器客户端失败: %v", err)
+		return nil
 	}
-	item, err := client.GetItemDetailByUser(itemId, embyUserId)
+	item, err := server.GetItemDetail(itemId)
 	if err != nil {
-		helpers.AppLogger.Errorf("获取Emby媒体 %s 用户ID %s 详情失败： %s", itemId, embyUserId, err.Error())
+		helpers.AppLogger.Errorf("获取%s媒体 %s 详情失败： %s", server.Type().DisplayName(), itemId, err.Error())
 		return nil
 	}
 	return item
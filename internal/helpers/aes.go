@@ -1,13 +1,17 @@
 package helpers
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -92,3 +96,191 @@ func Decrypt(encrypted string) (string, error) {
 
 	return string(ciphertext[:len(ciphertext)-paddingLen]), nil
 }
+
+// 加密文件的文件头，用于识别加密的备份文件
+var encryptedFileMagic = []byte("QMSENC02")
+
+// 文件加密的盐长度和PBKDF2迭代次数
+const (
+	fileSaltSize   = 16
+	fileKeyIterate = 600000
+)
+
+// 文件加密使用的密钥和HMAC密钥，由密码和盐通过PBKDF2-SHA256派生
+func fileKeys(password string, salt []byte) ([]byte, []byte, error) {
+	key, err := pbkdf2.Key(sha256.New, password, salt, fileKeyIterate, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key[:32], key[32:], nil
+}
+
+// EncryptFile 使用AES-256-CBC加密文件
+// 文件格式：文件头 + 盐 + IV + 密文 + HMAC-SHA256(文件头+盐+IV+密文)
+func EncryptFile(srcPath string, dstPath string, password string) error {
+	if password == "" {
+		return errors.New("加密密码不能为空")
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	salt := make([]byte, fileSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	encKey, macKey, err := fileKeys(password, salt)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, macKey)
+	w := io.MultiWriter(dst, mac)
+	if _, err := w.Write(encryptedFileMagic); err != nil {
+		return err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return err
+	}
+	if _, err := w.Write(iv); err != nil {
+		return err
+	}
+	mode := cipher.NewCBCEncrypter(block, iv)
+	buf := make([]byte, 64*1024)
+	for {
+		n, rerr := io.ReadFull(src, buf)
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			// 最后一块，PKCS7 填充
+			padding := aes.BlockSize - n%aes.BlockSize
+			last := append(buf[:n:n], bytes.Repeat([]byte{byte(padding)}, padding)...)
+			mode.CryptBlocks(last, last)
+			if _, err := w.Write(last); err != nil {
+				return err
+			}
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+		mode.CryptBlocks(buf, buf)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	if _, err := dst.Write(mac.Sum(nil)); err != nil {
+		return err
+	}
+	return dst.Sync()
+}
+
+// DecryptFile 解密EncryptFile加密的文件，先校验HMAC，密码错误或文件损坏时返回错误
+func DecryptFile(srcPath string, dstPath string, password string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	headerSize := int64(len(encryptedFileMagic) + fileSaltSize + aes.BlockSize)
+	cipherSize := stat.Size() - headerSize - sha256.Size
+	if cipherSize < aes.BlockSize || cipherSize%aes.BlockSize != 0 || !IsEncryptedFile(srcPath) {
+		return errors.New("不是有效的加密文件")
+	}
+
+	salt := make([]byte, fileSaltSize)
+	if _, err := src.ReadAt(salt, int64(len(encryptedFileMagic))); err != nil {
+		return err
+	}
+	encKey, macKey, err := fileKeys(password, salt)
+	if err != nil {
+		return err
+	}
+	// 第一遍校验HMAC
+	mac := hmac.New(sha256.New, macKey)
+	if _, err := io.CopyN(mac, src, headerSize+cipherSize); err != nil {
+		return err
+	}
+	expected := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(src, expected); err != nil {
+		return err
+	}
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errors.New("密码错误或文件已损坏")
+	}
+
+	// 第二遍解密
+	if _, err := src.Seek(int64(len(encryptedFileMagic)+fileSaltSize), io.SeekStart); err != nil {
+		return err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(src, iv); err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return err
+	}
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	mode := cipher.NewCBCDecrypter(block, iv)
+	buf := make([]byte, 64*1024)
+	remaining := cipherSize
+	for remaining > 0 {
+		n := int64(len(buf))
+		if remaining < n {
+			n = remaining
+		}
+		chunk := buf[:n]
+		if _, err := io.ReadFull(src, chunk); err != nil {
+			return err
+		}
+		mode.CryptBlocks(chunk, chunk)
+		remaining -= n
+		if remaining == 0 {
+			// 去除PKCS7填充
+			paddingLen := int(chunk[len(chunk)-1])
+			if paddingLen == 0 || paddingLen > aes.BlockSize {
+				return errors.New("invalid padding")
+			}
+			chunk = chunk[:len(chunk)-paddingLen]
+		}
+		if _, err := dst.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return dst.Sync()
+}
+
+// IsEncryptedFile 检查文件是否为EncryptFile加密的文件
+func IsEncryptedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, len(encryptedFileMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, encryptedFileMagic)
+}
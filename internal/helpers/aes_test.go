@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptDecryptFile(t *testing.T) {
+	dir := t.TempDir()
+	// 覆盖空文件、不足一个块、刚好整块以及跨多个读缓冲区的情况
+	for _, size := range []int{0, 10, 16, 64 * 1024, 200*1024 + 7} {
+		data := make([]byte, size)
+		rand.Read(data)
+		src := filepath.Join(dir, "src.zip")
+		enc := filepath.Join(dir, "src.zip.enc")
+		dst := filepath.Join(dir, "dst.zip")
+		if err := os.WriteFile(src, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := EncryptFile(src, enc, "password"); err != nil {
+			t.Fatalf("加密失败(%d): %v", size, err)
+		}
+		if !IsEncryptedFile(enc) || IsEncryptedFile(src) {
+			t.Fatalf("加密文件识别错误(%d)", size)
+		}
+		if err := DecryptFile(enc, dst, "wrong"); err == nil {
+			t.Fatalf("错误的密码应该解密失败(%d)", size)
+		}
+		if err := DecryptFile(enc, dst, "password"); err != nil {
+			t.Fatalf("解密失败(%d): %v", size, err)
+		}
+		got, _ := os.ReadFile(dst)
+		if !bytes.Equal(got, data) {
+			t.Fatalf("解密后内容不一致(%d)", size)
+		}
+	}
+}
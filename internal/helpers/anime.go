@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 动画发布组的文件名信息，例如：[Group] Title - 1047 [1080p].mkv
+type AnimeEpisodeInfo struct {
+	Group      string `json:"group"`      // 字幕组或发布组
+	Name       string `json:"name"`       // 标题
+	Year       int    `json:"year"`       // 年份，没有时为0
+	Season     int    `json:"season"`     // 文件名中明确标注的季，没有时为-1
+	Episode    int    `json:"episode"`    // 集数，没有标注季时是绝对集数，没有时为-1
+	Version    int    `json:"version"`    // 版本，例如v2，没有时为0
+	Resolution string `json:"resolution"` // 分辨率，例如1080p
+}
+
+var (
+	animeExtRe        = regexp.MustCompile(`^\.[A-Za-z0-9]{2,4}$`)
+	animeTagRe        = regexp.MustCompile(`[\[【(（]([^\]】)）]*)[\]】)）]`)
+	animeResolutionRe = regexp.MustCompile(`(?i)\b(\d{3,4}[pi]|[248]k|\d{3,4}x\d{3,4})\b`)
+	animeYearRe       = regexp.MustCompile(`^(19|20)\d{2}$`)
+	animeVersionRe    = regexp.MustCompile(`(?i)^v(\d)$`)
+	animeSpaceRe      = regexp.MustCompile(`\s+`)
+	// [11]、[11v2]、[第11话]、[EP11]这种放在括号里的集数
+	animeEpisodeTagRe = regexp.MustCompile(`(?i)^(?:EP?|第)?\s*(\d{1,4})\s*(?:v(\d))?\s*(?:话|話|集|END)?$`)
+	animeSeasonRes    = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bS(\d{1,2})E(\d{1,4})(?:v(\d))?\b`), // S02E05
+		regexp.MustCompile(`(?i)\bS(\d{1,2})\b`),                     // S2 - 05
+		regexp.MustCompile(`(?i)\bSeason\s*(\d{1,2})\b`),             // Season 2 - 05
+		regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)\s+Season\b`),
+		regexp.MustCompile(`第\s*([0-9一二三四五六七八九十]{1,3})\s*季`),
+	}
+	animeEpisodeRes = []*regexp.Regexp{
+		regexp.MustCompile(`(?:^|\s)[-–—]\s*(\d{1,4})(?:\.\d)?(?:v(\d))?(?:\s|$)`), // Title - 1047
+		regexp.MustCompile(`第\s*(\d{1,4})\s*[话話集]`),                                // Title 第1047话
+		regexp.MustCompile(`(?i)\bEP?(\d{1,4})(?:v(\d))?\b`),                       // Title EP12
+		regexp.MustCompile(`\s(\d{1,4})(?:v(\d))?$`),                               // Title 12
+	}
+)
+
+var chineseNumbers = map[rune]int{'一': 1, '二': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+
+// 解析季编号，支持阿拉伯数字和十以内的中文数字（十一、二十等）
+func parseAnimeNumber(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	n := 0
+	for _, r := range s {
+		if r == '十' {
+			if n == 0 {
+				n = 1
+			}
+			n *= 10
+			continue
+		}
+		n = n/10*10 + chineseNumbers[r]
+	}
+	return n
+}
+
+// ExtractAnimeEpisode 从动画发布组的文件名中提取发布组、标题、季、集、版本和分辨率
+// 没有明确标注季时集数按绝对集数处理，由调用方换算成TMDB的季和集
+// name是文件名，不能包含目录，标题中可能有 / 分隔的多语言标题
+func ExtractAnimeEpisode(name string) *AnimeEpisodeInfo {
+	info := &AnimeEpisodeInfo{Season: -1, Episode: -1}
+	name = strings.TrimSpace(name)
+	if ext := filepath.Ext(name); animeExtRe.MatchString(ext) {
+		name = strings.TrimSuffix(name, ext)
+	}
+	// [Group]_Title_-_05_[1080p]这种用下划线代替空格的格式
+	if !strings.Contains(name, " ") {
+		name = strings.ReplaceAll(name, "_", " ")
+	}
+	// 取出所有括号标签，剩下的部分是标题和集数
+	tags := make([]string, 0)
+	for _, m := range animeTagRe.FindAllStringSubmatchIndex(name, -1) {
+		tag := strings.TrimSpace(name[m[2]:m[3]])
+		if m[0] == 0 && info.Group == "" {
+			info.Group = tag
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	text := strings.TrimSpace(animeTagRe.ReplaceAllString(name, " "))
+	titleTag := ""
+	for _, tag := range tags {
+		if res := animeResolutionRe.FindString(tag); res != "" {
+			if info.Resolution == "" {
+				info.Resolution = res
+			}
+			continue
+		}
+		if animeYearRe.MatchString(tag) {
+			info.Year, _ = strconv.Atoi(tag)
+			continue
+		}
+		if m := animeVersionRe.FindStringSubmatch(tag); m != nil {
+			info.Version, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if m := animeEpisodeTagRe.FindStringSubmatch(tag); m != nil {
+			if info.Episode == -1 {
+				info.Episode, _ = strconv.Atoi(m[1])
+				if m[2] != "" {
+					info.Version, _ = strconv.Atoi(m[2])
+				}
+			}
+			continue
+		}
+		// [Group][Title][11]这种格式，标题在集数前面，中英文标题用下划线分隔时只取第一个
+		if titleTag == "" && info.Episode == -1 {
+			titleTag = strings.Split(tag, "_")[0]
+		}
+	}
+	if text == "" {
+		text = titleTag
+	}
+	// 先提取季，S02E05直接得到季和集
+	for i, re := range animeSeasonRes {
+		m := re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		info.Season = parseAnimeNumber(m[1])
+		if i == 0 {
+			info.Episode, _ = strconv.Atoi(m[2])
+			if m[3] != "" {
+				info.Version, _ = strconv.Atoi(m[3])
+			}
+		}
+		idx := strings.Index(text, m[0])
+		text = strings.TrimSpace(text[:idx] + " " + text[idx+len(m[0]):])
+		break
+	}
+	// 括号里已经有集数时不再从标题中提取，避免把标题末尾的数字当成集数
+	for _, re := range animeEpisodeRes {
+		if info.Episode != -1 {
+			break
+		}
+		m := re.FindStringSubmatchIndex(text)
+		if m == nil {
+			continue
+		}
+		info.Episode, _ = strconv.Atoi(text[m[2]:m[3]])
+		if len(m) > 4 && m[4] >= 0 {
+			info.Version, _ = strconv.Atoi(text[m[4]:m[5]])
+		}
+		// 集数后面一般是END、简介等，标题只取集数前面的部分
+		text = text[:m[0]]
+		break
+	}
+	// 多语言标题只取第一个
+	text = strings.Split(text, " / ")[0]
+	text = strings.Split(text, " | ")[0]
+	text = animeSpaceRe.ReplaceAllString(text, " ")
+	info.Name = strings.Trim(text, " -–—.")
+	return info
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"testing"
+)
+
+func TestExtractAnimeEpisode(t *testing.T) {
+	testCases := []struct {
+		filename string
+		expected AnimeEpisodeInfo
+	}{
+		{
+			filename: "[Group] One Piece - 1047 [1080p].mkv",
+			expected: AnimeEpisodeInfo{Group: "Group", Name: "One Piece", Season: -1, Episode: 1047, Resolution: "1080p"},
+		},
+		{
+			filename: "[SubsPlease] Sousou no Frieren - 05v2 (1080p) [A1B2C3D4].mkv",
+			expected: AnimeEpisodeInfo{Group: "SubsPlease", Name: "Sousou no Frieren", Season: -1, Episode: 5, Version: 2, Resolution: "1080p"},
+		},
+		{
+			filename: "[Nekomoe kissaten][Kimetsu no Yaiba][11][1080p][CHS].mp4",
+			expected: AnimeEpisodeInfo{Group: "Nekomoe kissaten", Name: "Kimetsu no Yaiba", Season: -1, Episode: 11, Resolution: "1080p"},
+		},
+		{
+			filename: "【悠哈璃羽字幕社】[死神千年血战相克谭_Bleach - Thousand-Year Blood War - Soukoku Tan][11v2][1080p][CHT]",
+			expected: AnimeEpisodeInfo{Group: "悠哈璃羽字幕社", Name: "死神千年血战相克谭", Season: -1, Episode: 11, Version: 2, Resolution: "1080p"},
+		},
+		{
+			filename: "[Group] Shingeki no Kyojin S2 - 05 [720p].mkv",
+			expected: AnimeEpisodeInfo{Group: "Group", Name: "Shingeki no Kyojin", Season: 2, Episode: 5, Resolution: "720p"},
+		},
+		{
+			filename: "[Group] Mushoku Tensei 2nd Season - 03 END [1080p].mkv",
+			expected: AnimeEpisodeInfo{Group: "Group", Name: "Mushoku Tensei", Season: 2, Episode: 3, Resolution: "1080p"},
+		},
+		{
+			filename: "[Group] 葬送的芙莉莲 / Sousou no Frieren - 28 [1080p].mp4",
+			expected: AnimeEpisodeInfo{Group: "Group", Name: "葬送的芙莉莲", Season: -1, Episode: 28, Resolution: "1080p"},
+		},
+		{
+			filename: "海贼王 第1047话 [4K].mp4",
+			expected: AnimeEpisodeInfo{Name: "海贼王", Season: -1, Episode: 1047, Resolution: "4K"},
+		},
+		{
+			filename: "[Group] 进击的巨人 第二季 [05][1080p].mkv",
+			expected: AnimeEpisodeInfo{Group: "Group", Name: "进击的巨人", Season: 2, Episode: 5, Resolution: "1080p"},
+		},
+		{
+			filename: "[Group] Mob Psycho 100 [12v2][1080p].mkv",
+			expected: AnimeEpisodeInfo{Group: "Group", Name: "Mob Psycho 100", Season: -1, Episode: 12, Version: 2, Resolution: "1080p"},
+		},
+		{
+			filename: "[Group]_Cowboy_Bebop_-_26_(2001)_[480p].mkv",
+			expected: AnimeEpisodeInfo{Group: "Group", Name: "Cowboy Bebop", Year: 2001, Season: -1, Episode: 26, Resolution: "480p"},
+		},
+	}
+	for _, tc := range testCases {
+		info := ExtractAnimeEpisode(tc.filename)
+		if *info != tc.expected {
+			t.Errorf("提取动画文件名信息失败： '%s'，结果 %+v 与预期 %+v 不符", tc.filename, *info, tc.expected)
+		}
+	}
+}
@@ -63,19 +63,27 @@ type ConfigStrm struct {
 	Cron         string   `yaml:"cron"` // 定时任务表达式
 }
 
+type ConfigPlayProxy struct {
+	CacheSize  int64               `yaml:"cacheSize"`  // 播放代理的磁盘缓存上限，单位MB，0使用默认值，小于0不缓存
+	ChunkSize  int64               `yaml:"chunkSize"`  // 分块大小，单位MB
+	Threads    int                 `yaml:"threads"`    // 每个播放请求并发拉取的分块数，也是预读的分块数
+	ExtraHosts map[string][]string `yaml:"extraHosts"` // 额外允许反代的域名，key是来源类型：115、baidupan
+}
+
 type Config struct {
-	Log           ConfigLog  `yaml:"log"`
-	Db            ConfigDb   `yaml:"db"`
-	CacheSize     int        `yaml:"cacheSize"` // 数据库缓存大小，单位字节
-	JwtSecret     string     `yaml:"jwtSecret"`
-	HttpHost      string     `yaml:"httpHost"`  // HTTP主机地址
-	HttpsHost     string     `yaml:"httpsHost"` // HTTPS主机地址
-	Strm          ConfigStrm `yaml:"strm"`
-	AuthServer    string     `yaml:"authServer"`
-	NewAuthServer string     `yaml:"newAuthServer"`
-	BaiDuPanAppId string     `yaml:"baiDuPanAppId"`
-	AdminUsername string     `yaml:"adminUsername"`
-	AdminPassword string     `yaml:"adminPassword"`
+	Log           ConfigLog       `yaml:"log"`
+	Db            ConfigDb        `yaml:"db"`
+	CacheSize     int             `yaml:"cacheSize"` // 数据库缓存大小，单位字节
+	JwtSecret     string          `yaml:"jwtSecret"`
+	HttpHost      string          `yaml:"httpHost"`  // HTTP主机地址
+	HttpsHost     string          `yaml:"httpsHost"` // HTTPS主机地址
+	Strm          ConfigStrm      `yaml:"strm"`
+	AuthServer    string          `yaml:"authServer"`
+	NewAuthServer string          `yaml:"newAuthServer"`
+	BaiDuPanAppId string          `yaml:"baiDuPanAppId"`
+	AdminUsername string          `yaml:"adminUsername"`
+	AdminPassword string          `yaml:"adminPassword"`
+	PlayProxy     ConfigPlayProxy `yaml:"playProxy"` // 本地播放代理
 }
 
 var GlobalConfig Config
@@ -113,6 +121,15 @@ func InitConfig() error {
 	// if GlobalConfig.Strm.Cron == "" {
 	GlobalConfig.Strm.Cron = "30 * * * *" // 每小时30分执行
 	// }
+	if GlobalConfig.PlayProxy.CacheSize == 0 {
+		GlobalConfig.PlayProxy.CacheSize = 2048
+	}
+	if GlobalConfig.PlayProxy.ChunkSize <= 0 {
+		GlobalConfig.PlayProxy.ChunkSize = 4
+	}
+	if GlobalConfig.PlayProxy.Threads <= 0 {
+		GlobalConfig.PlayProxy.Threads = 4
+	}
 	if GlobalConfig.AuthServer == "" {
 		GlobalConfig.AuthServer = "https://api.mqfamily.top"
 	}
@@ -32,6 +32,7 @@ type FFprobeStream struct {
 	BitRate            string            `json:"bit_rate"`
 	NB_Frames          string            `json:"nb_frames"`
 	Tags               map[string]string `json:"tags"`
+	Disposition        map[string]int    `json:"disposition"`
 }
 
 type FFprobeFormat struct {
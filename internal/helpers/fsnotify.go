@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -19,6 +20,8 @@ type AdvancedFolderWatcher struct {
 	// 事件去重
 	eventCache map[string]time.Time
 	debounce   time.Duration
+	// 事件回调，为空时只打印日志
+	onEvent func(event fsnotify.Event)
 }
 
 func NewAdvancedFolderWatcher(path string) *AdvancedFolderWatcher {
@@ -37,6 +40,38 @@ func NewAdvancedFolderWatcher(path string) *AdvancedFolderWatcher {
 	}
 }
 
+// NewFolderWatcherWithHandler 创建带回调的监控，extensions为空表示监控所有文件
+// 删除和重命名事件无法判断是文件还是目录，所以不按扩展名过滤，由回调自己判断
+func NewFolderWatcherWithHandler(path string, extensions []string, ignoreDirs []string, onEvent func(event fsnotify.Event)) (*AdvancedFolderWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("创建目录监控失败: %w", err)
+	}
+	lowerExts := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		lowerExts = append(lowerExts, strings.ToLower(ext))
+	}
+	return &AdvancedFolderWatcher{
+		watcher:    watcher,
+		watchPath:  path,
+		extensions: lowerExts,
+		ignoreDirs: ignoreDirs,
+		eventCache: make(map[string]time.Time),
+		debounce:   100 * time.Millisecond,
+		onEvent:    onEvent,
+	}, nil
+}
+
+// isIgnoreDir 检查路径是否在忽略的目录中
+func (afw *AdvancedFolderWatcher) isIgnoreDir(path string) bool {
+	for _, ignoreDir := range afw.ignoreDirs {
+		if strings.Contains(path, ignoreDir) {
+			return true
+		}
+	}
+	return false
+}
+
 // shouldIgnore 检查是否应该忽略该路径
 func (afw *AdvancedFolderWatcher) shouldIgnore(path string) bool {
 	// 检查是否在忽略目录中
@@ -77,8 +112,8 @@ func (afw *AdvancedFolderWatcher) addWatchRecursive(path string) error {
 		}
 
 		if info.IsDir() {
-			// 跳过忽略的目录
-			if afw.shouldIgnore(walkPath) {
+			// 跳过忽略的目录（目录没有扩展名，不能用shouldIgnore）
+			if afw.isIgnoreDir(walkPath) {
 				return filepath.SkipDir
 			}
 
@@ -94,9 +129,15 @@ func (afw *AdvancedFolderWatcher) addWatchRecursive(path string) error {
 // processEvent 处理事件（带去重）
 func (afw *AdvancedFolderWatcher) processEvent(event fsnotify.Event) {
 	// 检查是否应该忽略
-	if afw.shouldIgnore(event.Name) {
+	if afw.isIgnoreDir(event.Name) {
 		return
 	}
+	if event.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+		// 新建的目录需要继续监控，不能按扩展名过滤
+		if info, err := os.Stat(event.Name); err == nil && !info.IsDir() && !afw.isWatchedExtension(event.Name) {
+			return
+		}
+	}
 
 	// 事件去重
 	now := time.Now()
@@ -116,6 +157,9 @@ func (afw *AdvancedFolderWatcher) processEvent(event fsnotify.Event) {
 
 	// 处理事件
 	afw.handleEvent(event)
+	if afw.onEvent != nil {
+		afw.onEvent(event)
+	}
 }
 
 // handleEvent 处理具体事件
@@ -139,14 +183,19 @@ func (afw *AdvancedFolderWatcher) handleCreate(event fsnotify.Event) {
 	}
 
 	if info.IsDir() {
-		log.Printf("📁 新目录创建: %s", event.Name)
+		if afw.onEvent == nil {
+			log.Printf("📁 新目录创建: %s", event.Name)
+		}
 		afw.addWatchRecursive(event.Name)
-	} else {
+	} else if afw.onEvent == nil {
 		log.Printf("📄 新文件创建: %s", event.Name)
 	}
 }
 
 func (afw *AdvancedFolderWatcher) handleWrite(event fsnotify.Event) {
+	if afw.onEvent != nil {
+		return
+	}
 	info, err := os.Stat(event.Name)
 	if err != nil || info.IsDir() {
 		return
@@ -155,11 +204,15 @@ func (afw *AdvancedFolderWatcher) handleWrite(event fsnotify.Event) {
 }
 
 func (afw *AdvancedFolderWatcher) handleRemove(event fsnotify.Event) {
-	log.Printf("🗑️  文件/目录删除: %s", event.Name)
+	if afw.onEvent == nil {
+		log.Printf("🗑️  文件/目录删除: %s", event.Name)
+	}
 }
 
 func (afw *AdvancedFolderWatcher) handleRename(event fsnotify.Event) {
-	log.Printf("📝 文件重命名: %s", event.Name)
+	if afw.onEvent == nil {
+		log.Printf("📝 文件重命名: %s", event.Name)
+	}
 }
 
 // Start 开始监控
@@ -173,6 +226,19 @@ func (afw *AdvancedFolderWatcher) Start() {
 	log.Printf("📊 监控文件类型: %v", afw.extensions)
 	log.Printf("🚫 忽略目录: %v", afw.ignoreDirs)
 
+	afw.run()
+}
+
+// Run 添加监控后在当前协程处理事件，直到Close；添加监控失败时返回错误，不会退出程序
+func (afw *AdvancedFolderWatcher) Run() error {
+	if err := afw.addWatchRecursive(afw.watchPath); err != nil {
+		return fmt.Errorf("监控目录 %s 失败: %w", afw.watchPath, err)
+	}
+	afw.run()
+	return nil
+}
+
+func (afw *AdvancedFolderWatcher) run() {
 	for {
 		select {
 		case event, ok := <-afw.watcher.Events:
@@ -185,7 +251,11 @@ func (afw *AdvancedFolderWatcher) Start() {
 			if !ok {
 				return
 			}
-			log.Printf("❌ 监控错误: %v", err)
+			if AppLogger != nil {
+				AppLogger.Warnf("目录 %s 监控错误: %v", afw.watchPath, err)
+			} else {
+				log.Printf("❌ 监控错误: %v", err)
+			}
 		}
 	}
 }
@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 按key加锁，同一个key同时只有一个请求在执行
+type KeyLockWithTimeout struct {
+	mutexes sync.Map // key -> *sync.Mutex
+	global  sync.Mutex
+}
+
+// LockWithTimeout 尝试获取锁，如果超时则返回 false
+func (kl *KeyLockWithTimeout) LockWithTimeout(key string, timeout time.Duration) bool {
+	kl.global.Lock()
+	mutex, _ := kl.mutexes.LoadOrStore(key, &sync.Mutex{})
+	kl.global.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false // 超时
+		default:
+			if mutex.(*sync.Mutex).TryLock() {
+				return true // 成功获取锁
+			}
+			time.Sleep(10 * time.Millisecond) // 短暂等待后重试
+		}
+	}
+}
+
+func (kl *KeyLockWithTimeout) Unlock(key string) {
+	kl.global.Lock()
+	mutex, ok := kl.mutexes.Load(key)
+	kl.global.Unlock()
+
+	if ok {
+		mutex.(*sync.Mutex).Unlock()
+	}
+}
@@ -2,10 +2,7 @@ package helpers
 
 import (
 	"encoding/xml"
-	"fmt"
 	"io"
-	"os"
-	"strings"
 )
 
 type TVShowEpisode struct {
@@ -44,21 +41,8 @@ func ReadEpisodeNfo(r io.Reader) (*TVShowEpisode, error) {
 	return &m, nil
 }
 
-func WriteEpisodeNfo(m *TVShowEpisode, filename string) error {
-	xmlHeader := []byte("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n")
-	data, err := xml.MarshalIndent(m, "", "  ")
-	if err != nil {
-		return err
-	}
-	content := append(xmlHeader, data...)
-	// 将字符串中的实体编码替换回原内容
-	strOutput := string(content)
-	strOutput = strings.Replace(strOutput, "&lt;![CDATA[", "<![CDATA[", -1)
-	strOutput = strings.Replace(strOutput, "]]&gt;", "]]>", -1)
-	err = os.WriteFile(filename, []byte(strOutput), 0766)
-	if err != nil {
-		return fmt.Errorf("写入文件失败: %v", err)
-	}
-
-	return nil
+// opts为nil时生成通用格式
+func WriteEpisodeNfo(m *TVShowEpisode, filename string, opts *NfoOptions) error {
+	opts.applyEpisode(m)
+	return writeNfo(m, filename, opts)
 }
@@ -2,9 +2,6 @@ package helpers
 
 import (
 	"encoding/xml"
-	"fmt"
-	"os"
-	"strings"
 )
 
 // <fileinfo>
@@ -72,6 +69,8 @@ type Movie struct {
 	Ratings       struct {
 		Rating []Rating `xml:"rating,omitempty"`
 	} `xml:"ratings"`
+	Rating     float64    `xml:"rating,omitempty"` // Emby、Jellyfin读取的评分
+	Votes      int64      `xml:"votes,omitempty"`
 	UserRating float64    `xml:"userrating,omitempty"`
 	Top250     int64      `xml:"top250,omitempty"`
 	Outline    string     `xml:"outline,omitempty"`
@@ -199,6 +198,7 @@ type Actor struct {
 	Thumb   string `xml:"thumb,omitempty"`
 	TmdbId  int64  `xml:"tmdbid,omitempty"`
 	Profile string `xml:"profile,omitempty"`
+	Type    string `xml:"type,omitempty"` // Emby、Jellyfin的人员类型，例如Actor
 }
 
 func ReadMovieNfo(b []byte) (*Movie, error) {
@@ -210,22 +210,8 @@ func ReadMovieNfo(b []byte) (*Movie, error) {
 	return &m, nil
 }
 
-func WriteMovieNfo(m *Movie, filename string) error {
-	xmlHeader := []byte("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n")
-
-	data, err := xml.MarshalIndent(m, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化 XML 失败: %v", err)
-	}
-
-	content := append(xmlHeader, data...)
-	strOutput := string(content)
-	strOutput = strings.Replace(strOutput, "&lt;![CDATA[", "<![CDATA[", -1)
-	strOutput = strings.Replace(strOutput, "]]&gt;", "]]>", -1)
-	err = os.WriteFile(filename, []byte(strOutput), 0766)
-	if err != nil {
-		return fmt.Errorf("写入文件失败: %v", err)
-	}
-
-	return nil
+// opts为nil时生成通用格式
+func WriteMovieNfo(m *Movie, filename string, opts *NfoOptions) error {
+	opts.applyMovie(m)
+	return writeNfo(m, filename, opts)
 }
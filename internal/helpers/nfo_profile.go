@@ -0,0 +1,396 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// NFO格式，不同媒体服务器读取nfo的方式不同
+type NfoProfile string
+
+const (
+	NfoProfileDefault  NfoProfile = ""         // 通用格式，和以前生成的nfo一致
+	NfoProfileKodi     NfoProfile = "kodi"     // Kodi：只读取uniqueid，TMDB ID为默认值，保留ratings和fileinfo
+	NfoProfileEmby     NfoProfile = "emby"     // Emby：读取顶层rating，不读取ratings和fileinfo，IMDB ID为默认值
+	NfoProfileJellyfin NfoProfile = "jellyfin" // Jellyfin：读取顶层rating和ratings，不读取fileinfo，TMDB ID为默认值
+)
+
+var NfoProfiles = []NfoProfile{NfoProfileDefault, NfoProfileKodi, NfoProfileEmby, NfoProfileJellyfin}
+
+// 各格式不需要的顶层元素
+var nfoProfileDropElements = map[NfoProfile][]string{
+	NfoProfileKodi:     {"tmdbid", "imdbid"},
+	NfoProfileEmby:     {"ratings", "fileinfo"},
+	NfoProfileJellyfin: {"fileinfo"},
+}
+
+// 可以出现多次的元素，自定义字段的值按逗号拆分成多个元素追加，其他元素直接替换
+var nfoMultiValueElements = map[string]bool{
+	"tag":     true,
+	"genre":   true,
+	"studio":  true,
+	"country": true,
+	"credits": true,
+}
+
+// Emby、Jellyfin的lockedfields中的字段对应的nfo元素
+var nfoLockedFieldElements = map[string][]string{
+	"name":                {"title"},
+	"originaltitle":       {"originaltitle"},
+	"sortname":            {"sorttitle"},
+	"overview":            {"plot", "outline"},
+	"tagline":             {"tagline"},
+	"taglines":            {"tagline"},
+	"genres":              {"genre"},
+	"tags":                {"tag"},
+	"cast":                {"actor", "director", "credits"},
+	"studios":             {"studio"},
+	"officialrating":      {"mpaa"},
+	"productionlocations": {"country"},
+	"runtime":             {"runtime"},
+	"collections":         {"set"},
+	"communityrating":     {"rating", "ratings", "userrating"},
+}
+
+var nfoElementNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// 自定义nfo字段，Value是已经渲染好的值
+type NfoExtraField struct {
+	Name  string
+	Value string
+}
+
+// 生成nfo的选项，为nil时生成通用格式
+type NfoOptions struct {
+	Profile       NfoProfile
+	ExtraFields   []NfoExtraField
+	PreserveEdits bool     // 重新生成时保留已存在nfo中锁定的字段
+	LockedFields  []string // 始终保留的元素名称，和已存在nfo中lockedfields的字段合并
+	ExistingNfo   []string // 可能存在的旧nfo的本地路径，按顺序使用第一个存在的
+}
+
+func IsValidNfoProfile(profile NfoProfile) bool {
+	return slices.Contains(NfoProfiles, profile)
+}
+
+// 是否是合法的nfo元素名称
+func IsValidNfoElementName(name string) bool {
+	return nfoElementNameRe.MatchString(name)
+}
+
+func (o *NfoOptions) applyMovie(m *Movie) {
+	if o == nil {
+		return
+	}
+	if o.Profile == NfoProfileKodi || o.Profile == NfoProfileJellyfin {
+		m.Uniqueid = preferUniqueId(m.Uniqueid, "tmdb")
+		markDefaultRating(m.Ratings.Rating)
+	}
+	if o.Profile == NfoProfileEmby || o.Profile == NfoProfileJellyfin {
+		if len(m.Ratings.Rating) > 0 {
+			m.Rating = m.Ratings.Rating[0].Value
+			m.Votes = m.Ratings.Rating[0].Votes
+		}
+		m.Actor = serverActors(m.Actor)
+	}
+}
+
+func (o *NfoOptions) applyTVShow(m *TVShow) {
+	if o == nil {
+		return
+	}
+	if o.Profile == NfoProfileKodi || o.Profile == NfoProfileJellyfin {
+		m.Uniqueid = preferUniqueId(m.Uniqueid, "tmdb")
+		markDefaultRating(m.Ratings.Rating)
+	}
+	if o.Profile == NfoProfileEmby || o.Profile == NfoProfileJellyfin {
+		if len(m.Ratings.Rating) > 0 {
+			m.Rating = m.Ratings.Rating[0].Value
+			m.Votes = m.Ratings.Rating[0].Votes
+		}
+		m.Actor = serverActors(m.Actor)
+	}
+}
+
+func (o *NfoOptions) applyEpisode(m *TVShowEpisode) {
+	if o == nil {
+		return
+	}
+	if o.Profile == NfoProfileEmby || o.Profile == NfoProfileJellyfin {
+		m.Actor = serverActors(m.Actor)
+	}
+}
+
+// 把指定类型的uniqueid设为默认值并放在最前面
+// 只在默认值是TMDB或IMDB时调整，主元数据提供者是其他网站时保持不变
+func preferUniqueId(ids []UniqueId, typ string) []UniqueId {
+	index := -1
+	for i, id := range ids {
+		if id.Default && id.Type != "tmdb" && id.Type != "imdb" {
+			return ids
+		}
+		if id.Type == typ && index < 0 {
+			index = i
+		}
+	}
+	if index < 0 {
+		return ids
+	}
+	result := make([]UniqueId, 0, len(ids))
+	result = append(result, UniqueId{Type: typ, Default: true, Id: ids[index].Id})
+	for i, id := range ids {
+		if i == index {
+			continue
+		}
+		id.Default = false
+		result = append(result, id)
+	}
+	return result
+}
+
+// Kodi使用default属性选择显示的评分
+func markDefaultRating(ratings []Rating) {
+	for i := range ratings {
+		ratings[i].Default = i == 0
+	}
+}
+
+// Emby、Jellyfin的演员需要type，不读取Kodi的profile
+func serverActors(actors []Actor) []Actor {
+	result := make([]Actor, 0, len(actors))
+	for _, actor := range actors {
+		actor.Type = "Actor"
+		actor.Profile = ""
+		result = append(result, actor)
+	}
+	return result
+}
+
+// nfo的顶层元素，Raw是元素的原始内容
+type nfoElement struct {
+	Name string
+	Raw  string
+}
+
+// 按顶层元素拆分的nfo，用于追加自定义字段和合并旧nfo中锁定的字段
+type nfoDocument struct {
+	Header   string // 根元素开始标签及之前的内容
+	Elements []nfoElement
+	Footer   string // 根元素结束标签
+}
+
+func parseNfoDocument(data []byte) (*nfoDocument, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	doc := &nfoDocument{}
+	depth := 0
+	start := int64(0)
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				doc.Header = string(data[:dec.InputOffset()])
+			} else if depth == 2 {
+				start = offset
+			}
+		case xml.EndElement:
+			if depth == 1 {
+				doc.Footer = string(data[offset:dec.InputOffset()])
+			} else if depth == 2 {
+				doc.Elements = append(doc.Elements, nfoElement{Name: t.Name.Local, Raw: string(data[start:dec.InputOffset()])})
+			}
+			depth--
+		}
+	}
+	if doc.Header == "" || doc.Footer == "" {
+		return nil, fmt.Errorf("nfo缺少根元素")
+	}
+	return doc, nil
+}
+
+func (d *nfoDocument) String() string {
+	var sb strings.Builder
+	sb.WriteString(d.Header)
+	for _, e := range d.Elements {
+		sb.WriteString("\n  ")
+		sb.WriteString(e.Raw)
+	}
+	sb.WriteString("\n")
+	sb.WriteString(d.Footer)
+	return sb.String()
+}
+
+// 元素的文本内容
+func (d *nfoDocument) text(name string) string {
+	for _, e := range d.Elements {
+		if e.Name != name {
+			continue
+		}
+		var v struct {
+			Text string `xml:",chardata"`
+		}
+		if err := xml.Unmarshal([]byte(e.Raw), &v); err == nil {
+			return strings.TrimSpace(v.Text)
+		}
+	}
+	return ""
+}
+
+func (d *nfoDocument) remove(names ...string) {
+	elements := d.Elements[:0]
+	for _, e := range d.Elements {
+		if !slices.Contains(names, e.Name) {
+			elements = append(elements, e)
+		}
+	}
+	d.Elements = elements
+}
+
+// 追加自定义字段，可以出现多次的元素追加，其他元素替换已有的同名元素
+func (d *nfoDocument) addExtraFields(fields []NfoExtraField) {
+	for _, field := range fields {
+		value := strings.TrimSpace(field.Value)
+		if value == "" || !IsValidNfoElementName(field.Name) {
+			continue
+		}
+		values := []string{value}
+		if nfoMultiValueElements[field.Name] {
+			values = values[:0]
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, v)
+				}
+			}
+		} else {
+			d.remove(field.Name)
+		}
+		for _, v := range values {
+			var buf bytes.Buffer
+			xml.EscapeText(&buf, []byte(v))
+			d.Elements = append(d.Elements, nfoElement{Name: field.Name, Raw: fmt.Sprintf("<%s>%s</%s>", field.Name, buf.String(), field.Name)})
+		}
+	}
+}
+
+// 用旧nfo中锁定的元素替换新生成的元素，返回是否整个nfo都被锁定
+func (d *nfoDocument) mergeLocked(old *nfoDocument, lockedFields []string) bool {
+	if strings.EqualFold(old.text("lockdata"), "true") {
+		return true
+	}
+	locked := map[string]bool{"lockdata": true, "lockedfields": true}
+	for _, name := range lockedFields {
+		if name = strings.TrimSpace(name); name != "" {
+			locked[name] = true
+		}
+	}
+	for _, field := range strings.Split(old.text("lockedfields"), "|") {
+		for _, name := range nfoLockedFieldElements[strings.ToLower(strings.TrimSpace(field))] {
+			locked[name] = true
+		}
+	}
+	oldElements := func(name string) []nfoElement {
+		result := make([]nfoElement, 0)
+		for _, e := range old.Elements {
+			if e.Name == name {
+				result = append(result, e)
+			}
+		}
+		return result
+	}
+	inserted := make(map[string]bool)
+	elements := make([]nfoElement, 0, len(d.Elements))
+	for _, e := range d.Elements {
+		if !locked[e.Name] {
+			elements = append(elements, e)
+			continue
+		}
+		if !inserted[e.Name] {
+			inserted[e.Name] = true
+			elements = append(elements, oldElements(e.Name)...)
+		}
+	}
+	// 新nfo中没有的锁定元素追加到最后
+	for _, e := range old.Elements {
+		if locked[e.Name] && !inserted[e.Name] {
+			inserted[e.Name] = true
+			elements = append(elements, oldElements(e.Name)...)
+		}
+	}
+	d.Elements = elements
+	return false
+}
+
+// 读取第一个存在的旧nfo
+func (o *NfoOptions) existingNfo() []byte {
+	for _, path := range o.ExistingNfo {
+		if path == "" || !PathExists(path) {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			AppLogger.Errorf("读取旧nfo文件 %s 失败: %v", path, err)
+			continue
+		}
+		return data
+	}
+	return nil
+}
+
+// 处理nfo格式、自定义字段和保留用户修改的字段
+func (o *NfoOptions) process(content []byte) []byte {
+	if o == nil || (len(nfoProfileDropElements[o.Profile]) == 0 && len(o.ExtraFields) == 0 && !o.PreserveEdits) {
+		return content
+	}
+	doc, err := parseNfoDocument(content)
+	if err != nil {
+		AppLogger.Errorf("解析生成的nfo失败: %v", err)
+		return content
+	}
+	doc.remove(nfoProfileDropElements[o.Profile]...)
+	doc.addExtraFields(o.ExtraFields)
+	if o.PreserveEdits {
+		if data := o.existingNfo(); data != nil {
+			old, err := parseNfoDocument(data)
+			if err != nil {
+				AppLogger.Errorf("解析旧nfo失败，不保留修改的字段: %v", err)
+			} else if doc.mergeLocked(old, o.LockedFields) {
+				return data
+			}
+		}
+	}
+	return []byte(doc.String())
+}
+
+// 序列化nfo并写入文件
+func writeNfo(v any, filename string, opts *NfoOptions) error {
+	xmlHeader := []byte("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n")
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 XML 失败: %v", err)
+	}
+	content := append(xmlHeader, data...)
+	// 将字符串中的实体编码替换回原内容
+	strOutput := string(content)
+	strOutput = strings.Replace(strOutput, "&lt;![CDATA[", "<![CDATA[", -1)
+	strOutput = strings.Replace(strOutput, "]]&gt;", "]]>", -1)
+	output := opts.process([]byte(strOutput))
+	err = os.WriteFile(filename, output, 0766)
+	if err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testMovie() *Movie {
+	m := &Movie{
+		Title:    "沙丘",
+		Plot:     "<![CDATA[香料争夺战]]>",
+		TmdbId:   438631,
+		ImdbId:   "tt1160419",
+		Uniqueid: []UniqueId{{Type: "imdb", Default: true, Id: "tt1160419"}, {Type: "tmdb", Id: "438631"}},
+		Actor:    []Actor{{Name: "提莫西·查拉梅", Profile: "https://www.themoviedb.org/person/1190668"}},
+	}
+	m.Ratings.Rating = []Rating{{Name: "tmdb", Max: 10, Value: 7.8, Votes: 100}}
+	m.FileInfo.StreamDetails.Video = []StreamVideo{{Codec: "hevc"}}
+	return m
+}
+
+func TestWriteMovieNfoProfiles(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		profile  NfoProfile
+		contains []string
+		excludes []string
+	}{
+		{NfoProfileDefault, []string{"<ratings>", "<fileinfo>", "<tmdbid>438631</tmdbid>", `<uniqueid type="imdb" default="true">`}, []string{"<type>"}},
+		{NfoProfileKodi, []string{"<ratings>", "<fileinfo>", `<uniqueid type="tmdb" default="true">`, `default="true">`, "<profile>"}, []string{"<tmdbid>438631</tmdbid>", "<imdbid>"}},
+		{NfoProfileEmby, []string{"<rating>7.8</rating>", "<votes>100</votes>", `<uniqueid type="imdb" default="true">`, "<type>Actor</type>"}, []string{"<ratings>", "<fileinfo>", "<profile>"}},
+		{NfoProfileJellyfin, []string{"<ratings>", "<rating>7.8</rating>", `<uniqueid type="tmdb" default="true">`, "<type>Actor</type>"}, []string{"<fileinfo>"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.profile), func(t *testing.T) {
+			filename := filepath.Join(dir, string(tt.profile)+".nfo")
+			if err := WriteMovieNfo(testMovie(), filename, &NfoOptions{Profile: tt.profile}); err != nil {
+				t.Fatal(err)
+			}
+			b, _ := os.ReadFile(filename)
+			content := string(b)
+			if !strings.Contains(content, "<![CDATA[香料争夺战]]>") {
+				t.Errorf("CDATA lost:\n%s", content)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(content, s) {
+					t.Errorf("missing %q in:\n%s", s, content)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(content, s) {
+					t.Errorf("unexpected %q in:\n%s", s, content)
+				}
+			}
+			if _, err := ReadMovieNfo(b); err != nil {
+				t.Errorf("ReadMovieNfo: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteNfoExtraFields(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "movie.nfo")
+	opts := &NfoOptions{ExtraFields: []NfoExtraField{
+		{Name: "tag", Value: "4K, 杜比视界"},
+		{Name: "mpaa", Value: "PG-13"},
+		{Name: "source", Value: "A&B"},
+		{Name: "bad name", Value: "x"},
+		{Name: "empty", Value: " "},
+	}}
+	m := testMovie()
+	m.MPAA = "R"
+	if err := WriteMovieNfo(m, filename, opts); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(filename)
+	movie, err := ReadMovieNfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movie.Tag) != 2 || movie.Tag[0] != "4K" || movie.Tag[1] != "杜比视界" {
+		t.Errorf("Tag = %v", movie.Tag)
+	}
+	if movie.MPAA != "PG-13" {
+		t.Errorf("MPAA = %q; want PG-13", movie.MPAA)
+	}
+	content := string(b)
+	if strings.Count(content, "<mpaa>") != 1 || !strings.Contains(content, "<source>A&amp;B</source>") || strings.Contains(content, "<empty>") {
+		t.Errorf("unexpected extra fields:\n%s", content)
+	}
+}
+
+func TestWriteNfoPreserveEdits(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "old.nfo")
+	old := `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<movie>
+  <plot><![CDATA[用户修改的简介]]></plot>
+  <lockdata>false</lockdata>
+  <lockedfields>Overview|Tags</lockedfields>
+  <title>旧标题</title>
+  <tag>收藏</tag>
+  <tag>4K</tag>
+  <mpaa>用户分级</mpaa>
+</movie>`
+	if err := os.WriteFile(existing, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "movie.nfo")
+	opts := &NfoOptions{PreserveEdits: true, LockedFields: []string{"mpaa"}, ExistingNfo: []string{filepath.Join(dir, "none.nfo"), existing}}
+	m := testMovie()
+	m.MPAA = "PG-13"
+	m.Tag = []string{"新标签"}
+	if err := WriteMovieNfo(m, filename, opts); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(filename)
+	movie, err := ReadMovieNfo(b)
+	if err != nil {
+		t.Fatalf("ReadMovieNfo: %v\n%s", err, b)
+	}
+	if movie.Title != "沙丘" {
+		t.Errorf("Title = %q; want 沙丘", movie.Title)
+	}
+	if movie.Plot != "用户修改的简介" {
+		t.Errorf("Plot = %q; want 用户修改的简介", movie.Plot)
+	}
+	if movie.Outline != "" {
+		t.Errorf("Outline = %q; want removed", movie.Outline)
+	}
+	if len(movie.Tag) != 2 || movie.Tag[0] != "收藏" {
+		t.Errorf("Tag = %v; want [收藏 4K]", movie.Tag)
+	}
+	if movie.MPAA != "用户分级" {
+		t.Errorf("MPAA = %q; want 用户分级", movie.MPAA)
+	}
+	if !strings.Contains(string(b), "<lockedfields>Overview|Tags</lockedfields>") {
+		t.Errorf("lockedfields lost:\n%s", b)
+	}
+
+	// lockdata为true时保留整个旧nfo
+	locked := strings.Replace(old, "<lockdata>false</lockdata>", "<lockdata>true</lockdata>", 1)
+	if err := os.WriteFile(existing, []byte(locked), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMovieNfo(testMovie(), filename, opts); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filename); string(b) != locked {
+		t.Errorf("locked nfo was rewritten:\n%s", b)
+	}
+}
@@ -2,10 +2,7 @@ package helpers
 
 import (
 	"encoding/xml"
-	"fmt"
 	"io"
-	"os"
-	"strings"
 )
 
 type TVShow struct {
@@ -17,6 +14,8 @@ type TVShow struct {
 	Ratings       struct {
 		Rating []Rating `xml:"rating,omitempty"`
 	} `xml:"ratings"`
+	Rating         float64    `xml:"rating,omitempty"` // Emby、Jellyfin读取的评分
+	Votes          int64      `xml:"votes,omitempty"`
 	UserRating     float64    `xml:"userrating,omitempty"`
 	Top250         int64      `xml:"top250,omitempty"`
 	Season         int64      `xml:"season,omitempty"`
@@ -69,21 +68,8 @@ func ReadTVShowNfo(r io.Reader) (*TVShow, error) {
 	return &m, nil
 }
 
-func WriteTVShowNfo(m *TVShow, filename string) error {
-	xmlHeader := []byte("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n")
-	data, err := xml.MarshalIndent(m, "", "  ")
-	if err != nil {
-		return err
-	}
-	content := append(xmlHeader, data...)
-	// 将字符串中的实体编码替换回原内容
-	strOutput := string(content)
-	strOutput = strings.Replace(strOutput, "&lt;![CDATA[", "<![CDATA[", -1)
-	strOutput = strings.Replace(strOutput, "]]&gt;", "]]>", -1)
-	err = os.WriteFile(filename, []byte(strOutput), 0766)
-	if err != nil {
-		return fmt.Errorf("写入文件失败: %v", err)
-	}
-
-	return nil
+// opts为nil时生成通用格式
+func WriteTVShowNfo(m *TVShow, filename string, opts *NfoOptions) error {
+	opts.applyTVShow(m)
+	return writeNfo(m, filename, opts)
 }
@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -196,6 +197,15 @@ func FormatSeason(seasonNumber int) string {
 	return fmt.Sprintf("Season %d", seasonNumber)
 }
 
+// TMDB在正式标题公布前使用的占位集标题，例如：TBA、Episode 5、第 5 集
+var placeholderTitleRe = regexp.MustCompile(`(?i)^(tba|tbd|to be announced|episode\s*#?[\d.]+|第\s*\d+\s*集|folge\s*\d+|épisode\s*\d+|episodio\s*\d+|エピソード\s*\d+|\d+)$`)
+
+// IsPlaceholderTitle 判断集标题是否为空或者是占位标题
+func IsPlaceholderTitle(title string) bool {
+	title = strings.TrimSpace(title)
+	return title == "" || placeholderTitleRe.MatchString(title)
+}
+
 func ParseYearFromDate(date string) int {
 	if date == "" {
 		return 0
@@ -95,3 +95,29 @@ func TestChineseToPinyin(t *testing.T) {
 		})
 	}
 }
+
+func TestIsPlaceholderTitle(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", true},
+		{"TBA", true},
+		{"tbd", true},
+		{"Episode 5", true},
+		{"Episode #1.5", true},
+		{"第 5 集", true},
+		{"第12集", true},
+		{"12", true},
+		{"Pilot", false},
+		{"凛冬将至", false},
+		{"Episode of the Year", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := IsPlaceholderTitle(tt.input); result != tt.expected {
+				t.Errorf("IsPlaceholderTitle(%q) = %v; want %v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// 字幕文件名和内封字幕流中常见的语言标记，值是ISO 639-1代码，繁体中文使用zh-TW
+var subtitleLanguageTags = map[string]string{
+	"chs": "zh", "sc": "zh", "gb": "zh", "zhs": "zh", "zh-hans": "zh", "zh-cn": "zh", "zh-sg": "zh", "chi": "zh", "zho": "zh", "chn": "zh",
+	"简": "zh", "简体": "zh", "简中": "zh", "中文": "zh", "简英": "zh", "简日": "zh",
+	"cht": "zh-TW", "tc": "zh-TW", "big5": "zh-TW", "zht": "zh-TW", "zh-hant": "zh-TW", "zh-tw": "zh-TW", "zh-hk": "zh-TW",
+	"繁": "zh-TW", "繁体": "zh-TW", "繁體": "zh-TW", "繁中": "zh-TW", "繁英": "zh-TW", "繁日": "zh-TW",
+	"eng": "en", "英文": "en", "英语": "en",
+	"jpn": "ja", "jp": "ja", "日文": "ja", "日语": "ja",
+	"kor": "ko", "kr": "ko", "韩文": "ko", "韩语": "ko",
+	"fre": "fr", "fra": "fr", "ger": "de", "deu": "de", "spa": "es", "ita": "it", "rus": "ru", "por": "pt",
+	"ara": "ar", "tha": "th", "vie": "vi", "dut": "nl", "nld": "nl", "pol": "pl", "tur": "tr", "ind": "id",
+}
+
+// 强制字幕和听障字幕的标记
+var (
+	subtitleForcedTags = []string{"forced", "强制"}
+	subtitleSDHTags    = []string{"sdh", "cc", "hi"}
+)
+
+// 文本字幕格式，值是提取为外挂字幕时使用的扩展名和ffmpeg编码参数
+var textSubtitleCodecs = map[string][2]string{
+	"subrip":   {".srt", "copy"},
+	"srt":      {".srt", "copy"},
+	"ass":      {".ass", "copy"},
+	"ssa":      {".ass", "ass"},
+	"webvtt":   {".vtt", "copy"},
+	"mov_text": {".srt", "srt"},
+	"text":     {".srt", "srt"},
+}
+
+type SubtitleInfo struct {
+	Language string `json:"language"` // ISO 639-1代码，空表示未知
+	Forced   bool   `json:"forced"`   // 强制字幕
+	SDH      bool   `json:"sdh"`      // 听障字幕
+}
+
+// NormalizeLanguageCode 把字幕标记或ffprobe的语言标签（ISO 639-2）转换为ISO 639-1代码，无法识别时返回空字符串
+func NormalizeLanguageCode(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if code, ok := subtitleLanguageTags[tag]; ok {
+		return code
+	}
+	if len(tag) == 2 && GetLanguageName(tag) != "" {
+		return tag
+	}
+	return ""
+}
+
+// ParseSubtitleFileName 从字幕文件名的标记中提取语言、强制和听障标记
+// 只检查视频文件名之后的部分，例如 电影.chs.forced.srt 中的 chs 和 forced
+func ParseSubtitleFileName(videoBaseName, filename string) SubtitleInfo {
+	info := SubtitleInfo{}
+	rest := strings.TrimSuffix(filename, filepath.Ext(filename))
+	matched := videoBaseName != "" && strings.HasPrefix(rest, videoBaseName)
+	if matched {
+		rest = strings.TrimPrefix(rest, videoBaseName)
+	}
+	tokens := strings.FieldsFunc(strings.ToLower(rest), func(r rune) bool {
+		return r == '.' || r == ' ' || r == '[' || r == ']' || r == '(' || r == ')'
+	})
+	if !matched && len(tokens) > 3 {
+		// 和视频文件名不一致时只看最后几个标记，避免把片名中的单词当成语言
+		tokens = tokens[len(tokens)-3:]
+	}
+	for _, token := range tokens {
+		if slices.Contains(subtitleForcedTags, token) {
+			info.Forced = true
+			continue
+		}
+		if slices.Contains(subtitleSDHTags, token) {
+			info.SDH = true
+			continue
+		}
+		if info.Language != "" {
+			continue
+		}
+		if code := NormalizeLanguageCode(token); code != "" {
+			info.Language = code
+			continue
+		}
+		// 双语字幕只取第一种语言，例如 chs&eng
+		parts := strings.FieldsFunc(token, func(r rune) bool { return r == '&' || r == '+' || r == '_' || r == '-' })
+		if len(parts) > 1 {
+			info.Language = NormalizeLanguageCode(parts[0])
+		}
+	}
+	return info
+}
+
+// SubtitleFileName 生成Emby/Jellyfin可以识别语言的字幕文件名：<视频文件名>.<语言>[.forced][.sdh].ext
+func SubtitleFileName(videoBaseName string, info SubtitleInfo, ext string) string {
+	name := videoBaseName + "." + info.Language
+	if info.Forced {
+		name += ".forced"
+	}
+	if info.SDH {
+		name += ".sdh"
+	}
+	return name + ext
+}
+
+// 解码字幕内容，支持UTF-8、带BOM的UTF-16和GBK
+func decodeSubtitleText(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		return string(content[3:])
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}), bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		if decoded, err := xunicode.UTF16(xunicode.LittleEndian, xunicode.UseBOM).NewDecoder().Bytes(content); err == nil {
+			return string(decoded)
+		}
+	case utf8.Valid(content):
+		return string(content)
+	}
+	if decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(content); err == nil {
+		return string(decoded)
+	}
+	return string(content)
+}
+
+var (
+	subtitleTagRe    = regexp.MustCompile(`\{[^}]*\}|<[^>]*>`)
+	subtitleTimingRe = regexp.MustCompile(`-->`)
+	// 只在一种中文写法中出现的常用字，用来区分简体和繁体
+	simplifiedOnlyChars  = []rune("这们个来说时为国会对过还没么吗让话见东门开关样经问长书")
+	traditionalOnlyChars = []rune("這們個來說時為國會對過還沒麼嗎讓話見東門開關樣經問長書")
+	latinStopWords       = map[string][]string{
+		"en": {"the", "you", "and", "is", "what", "it", "to", "that", "of", "this"},
+		"fr": {"le", "la", "les", "je", "vous", "est", "et", "pas", "que", "une"},
+		"de": {"der", "die", "und", "ich", "nicht", "das", "ist", "sie", "du", "ein"},
+		"es": {"el", "que", "los", "las", "y", "no", "es", "por", "una", "qué"},
+		"it": {"il", "che", "non", "di", "è", "la", "un", "per", "sono", "questo"},
+		"pt": {"o", "que", "não", "de", "é", "um", "uma", "você", "com", "os"},
+	}
+)
+
+// 提取字幕文件中的台词文本，去掉序号、时间轴和样式标签
+func subtitleDialogue(text string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || subtitleTimingRe.MatchString(line) {
+			continue
+		}
+		if _, err := strconv.Atoi(line); err == nil {
+			continue
+		}
+		if strings.HasPrefix(line, "[") || strings.HasPrefix(line, "Style:") || strings.HasPrefix(line, "Format:") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "WEBVTT") {
+			continue
+		}
+		if strings.HasPrefix(line, "Dialogue:") {
+			// ASS的台词在第9个逗号之后
+			fields := strings.SplitN(line, ",", 10)
+			if len(fields) < 10 {
+				continue
+			}
+			line = strings.ReplaceAll(fields[9], `\N`, " ")
+		}
+		sb.WriteString(subtitleTagRe.ReplaceAllString(line, ""))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// DetectSubtitleLanguage 抽样字幕内容按文字比例判断语言，支持srt、ass、ssa、vtt，无法判断时返回空字符串
+func DetectSubtitleLanguage(content []byte) string {
+	if len(content) > 64*1024 {
+		content = content[:64*1024]
+	}
+	text := subtitleDialogue(decodeSubtitleText(content))
+	var han, kana, hangul, cyrillic, arabic, thai, latin, simplified, traditional int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+			if slices.Contains(simplifiedOnlyChars, r) {
+				simplified++
+			} else if slices.Contains(traditionalOnlyChars, r) {
+				traditional++
+			}
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Thai, r):
+			thai++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	total := han + kana + hangul + cyrillic + arabic + thai + latin
+	if total < 20 {
+		return ""
+	}
+	// 日文夹杂汉字，假名占一定比例就认为是日文
+	if kana*10 > han+kana && kana > 0 {
+		return "ja"
+	}
+	// 中英双语字幕的拉丁字母通常比汉字多，汉字达到一定比例就认为是中文
+	if han*5 > total {
+		if traditional > simplified {
+			return "zh-TW"
+		}
+		return "zh"
+	}
+	counts := map[string]int{"ko": hangul, "ru": cyrillic, "ar": arabic, "th": thai}
+	for code, n := range counts {
+		if n*2 > total {
+			return code
+		}
+	}
+	if latin*2 <= total {
+		return ""
+	}
+	return detectLatinLanguage(text)
+}
+
+// 按常用词出现次数区分拉丁字母的语言
+func detectLatinLanguage(text string) string {
+	hits := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for code, words := range latinStopWords {
+			if slices.Contains(words, word) {
+				hits[code]++
+			}
+		}
+	}
+	best, bestHits := "", 0
+	for _, code := range []string{"en", "fr", "de", "es", "it", "pt"} {
+		if hits[code] > bestHits {
+			best, bestHits = code, hits[code]
+		}
+	}
+	return best
+}
+
+// TextSubtitleExt 返回文本字幕流提取为外挂字幕时的扩展名，图形字幕（PGS、VobSub）返回空字符串
+func TextSubtitleExt(codec string) string {
+	if c, ok := textSubtitleCodecs[strings.ToLower(codec)]; ok {
+		return c[0]
+	}
+	return ""
+}
+
+// ExtractSubtitleStream 用ffmpeg把视频中的文本字幕流提取为外挂字幕文件
+func ExtractSubtitleStream(videoPath string, streamIndex int, codec string, outPath string) error {
+	c, ok := textSubtitleCodecs[strings.ToLower(codec)]
+	if !ok {
+		return fmt.Errorf("不支持提取 %s 格式的字幕流", codec)
+	}
+	cmd := exec.Command("ffmpeg", "-v", "error", "-y", "-i", videoPath, "-map", fmt.Sprintf("0:%d", streamIndex), "-c:s", c[1], outPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		AppLogger.Errorf("提取字幕流失败: %s %v", string(output), err)
+		return err
+	}
+	return nil
+}
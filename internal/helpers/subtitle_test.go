@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestParseSubtitleFileName(t *testing.T) {
+	tests := []struct {
+		videoBaseName string
+		filename      string
+		expected      SubtitleInfo
+	}{
+		{"Dune.2021", "Dune.2021.chs.srt", SubtitleInfo{Language: "zh"}},
+		{"Dune.2021", "Dune.2021.zh-Hans.ass", SubtitleInfo{Language: "zh"}},
+		{"Dune.2021", "Dune.2021.cht.srt", SubtitleInfo{Language: "zh-TW"}},
+		{"Dune.2021", "Dune.2021.eng.forced.srt", SubtitleInfo{Language: "en", Forced: true}},
+		{"Dune.2021", "Dune.2021.en.sdh.srt", SubtitleInfo{Language: "en", SDH: true}},
+		{"Dune.2021", "Dune.2021.chs&eng.ass", SubtitleInfo{Language: "zh"}},
+		{"Dune.2021", "Dune.2021.简体.srt", SubtitleInfo{Language: "zh"}},
+		{"Dune.2021", "Dune.2021.srt", SubtitleInfo{}},
+		// 和视频文件名不一致时只看最后几个标记
+		{"Dune.2021", "It.Follows.2014.1080p.BluRay.srt", SubtitleInfo{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if result := ParseSubtitleFileName(tt.videoBaseName, tt.filename); result != tt.expected {
+				t.Errorf("ParseSubtitleFileName(%q) = %+v; want %+v", tt.filename, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectSubtitleLanguage(t *testing.T) {
+	srt := func(lines ...string) string {
+		content := ""
+		for i, line := range lines {
+			content += "1\n00:00:0" + string(rune('0'+i)) + ",000 --> 00:00:0" + string(rune('1'+i)) + ",000\n" + line + "\n\n"
+		}
+		return content
+	}
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(srt("我们这就出发吧", "你说什么？我没听清楚", "时间不多了，快走"))
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"简体中文", srt("我们这就出发吧", "你说什么？我没听清楚", "时间不多了，快走"), "zh"},
+		{"GBK编码", gbk, "zh"},
+		{"繁体中文", srt("我們這就出發吧", "你說什麼？我沒聽清楚", "時間不多了，快走"), "zh-TW"},
+		{"日文", srt("今日はいい天気ですね", "ありがとうございます", "どこへ行くの？"), "ja"},
+		{"英文", srt("What is this place?", "I don't know, but it is the only way out.", "You and I have to go now."), "en"},
+		{"中英双语", srt("你说什么？\nWhat did you say?", "我们这就出发吧\nLet's go right now, it is time.", "时间不多了\nThere is no time left."), "zh"},
+		{"ASS", "[Script Info]\nTitle: test\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\nDialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,{\\fad(200,200)}What is this place?\\NYou and I have to go.\nDialogue: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,I don't know what it is, but it is the way.\n", "en"},
+		{"内容太少", srt("OK"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := DetectSubtitleLanguage([]byte(tt.content)); result != tt.expected {
+				t.Errorf("DetectSubtitleLanguage() = %q; want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSubtitleFileName(t *testing.T) {
+	if name := SubtitleFileName("沙丘 (2021)", SubtitleInfo{Language: "en", Forced: true, SDH: true}, ".srt"); name != "沙丘 (2021).en.forced.sdh.srt" {
+		t.Errorf("SubtitleFileName() = %q", name)
+	}
+	if code := NormalizeLanguageCode("chi"); code != "zh" {
+		t.Errorf("NormalizeLanguageCode(chi) = %q", code)
+	}
+	if code := NormalizeLanguageCode("und"); code != "" {
+		t.Errorf("NormalizeLanguageCode(und) = %q", code)
+	}
+}
@@ -0,0 +1,95 @@
+package linkresolver
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 失效的文件在这段时间内播放时先尝试其他副本，避免每次都请求失效文件的下载链接
+const deadRetryInterval = 10 * time.Minute
+
+// 下载链接，回退到其他副本时PickCode和Account是实际播放的副本
+type Link struct {
+	URL      string
+	PickCode string
+	Account  *models.Account
+}
+
+// 获取文件的下载链接，失败时依次尝试其他健康的等价副本，并标记失效的文件
+// 115和百度网盘的副本可以互相替代
+func GetLink(ctx context.Context, account *models.Account, pickCode, ua string) (*Link, error) {
+	fc := models.GetFileCopyByPickCode(pickCode)
+	if fc != nil && fc.RecentlyDead(deadRetryInterval) {
+		helpers.AppLogger.Infof("文件 %s 最近获取下载链接失败，先尝试其他副本", fc.FileName)
+		if link := getAlternateLink(ctx, fc, ua); link != nil {
+			return link, nil
+		}
+	}
+
+	linkUrl, err := getLink(ctx, account, pickCode, ua)
+	if err == nil {
+		if fc != nil {
+			fc.MarkHealthy()
+		}
+		return &Link{URL: linkUrl, PickCode: pickCode, Account: account}, nil
+	}
+	// 等待超时或者客户端断开时不认为文件失效
+	if errors.Is(err, ErrLinkTimeout) || ctx.Err() != nil {
+		return nil, err
+	}
+	if fc == nil {
+		fc = models.GetFileCopy(pickCode)
+	}
+	if fc == nil {
+		return nil, err
+	}
+	helpers.AppLogger.Warnf("获取文件 %s 的下载链接失败，尝试其他副本: %v", fc.FileName, err)
+	var alternate *models.FileCopy
+	link := getAlternateLink(ctx, fc, ua)
+	if link != nil {
+		alternate = models.GetFileCopyByPickCode(link.PickCode)
+	}
+	fc.MarkDead(err.Error(), alternate)
+	if link == nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// 依次尝试健康的副本，获取失败的副本标记为失效
+func getAlternateLink(ctx context.Context, fc *models.FileCopy, ua string) *Link {
+	for _, cp := range fc.Alternates() {
+		account, err := models.GetAccountById(cp.AccountId)
+		if err != nil {
+			continue
+		}
+		linkUrl, err := getLink(ctx, account, cp.PickCode, ua)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !errors.Is(err, ErrLinkTimeout) {
+				cp.MarkDead(err.Error(), nil)
+			}
+			continue
+		}
+		helpers.AppLogger.Infof("文件 %s 无法播放，使用副本 %s 播放: 账号=%s pickcode=%s", fc.FileName, cp.FileName, account.Name, cp.PickCode)
+		return &Link{URL: linkUrl, PickCode: cp.PickCode, Account: account}
+	}
+	return nil
+}
+
+// 按账号类型获取下载链接
+func getLink(ctx context.Context, account *models.Account, pickCode, ua string) (string, error) {
+	switch account.SourceType {
+	case models.SourceType115:
+		return Get115Link(ctx, account, pickCode, ua)
+	case models.SourceTypeBaiduPan:
+		return GetBaiduPanLink(ctx, account, pickCode, ua)
+	}
+	return "", fmt.Errorf("不支持获取%s的下载链接", account.SourceType)
+}
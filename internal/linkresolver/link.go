@@ -0,0 +1,137 @@
+package linkresolver
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// 同一个文件和UA同时只请求一次网盘接口
+var linkLock helpers.KeyLockWithTimeout
+
+// 等待其他请求获取同一个下载链接超时，不代表文件已经失效
+var ErrLinkTimeout = errors.New("等待获取下载链接超时")
+
+// 通过pickcode或者网盘用户ID查询账号，userId为空时通过pickcode查询同步文件所属的账号
+func GetAccount(pickCode, userId string) (*models.Account, error) {
+	if userId != "" {
+		account, err := models.GetAccountByUserId(userId)
+		if err != nil {
+			return nil, errors.New("用户ID不存在")
+		}
+		return account, nil
+	}
+	syncFile := models.GetFileByPickCode(pickCode)
+	if syncFile == nil {
+		return nil, errors.New("文件PickCode不存在")
+	}
+	account, err := models.GetAccountById(syncFile.AccountId)
+	if err != nil {
+		return nil, errors.New("账号ID不存在")
+	}
+	return account, nil
+}
+
+// 获取115下载链接，按pickcode和UA缓存50分钟，缓存的链接失效后重新获取
+func Get115Link(ctx context.Context, account *models.Account, pickCode, ua string) (string, error) {
+	cacheKey := fmt.Sprintf("115url:%s, ua=%s", pickCode, ua)
+	if !linkLock.LockWithTimeout(cacheKey, 10*time.Second) {
+		return "", fmt.Errorf("获取115下载链接失败: %w", ErrLinkTimeout)
+	}
+	defer linkLock.Unlock(cacheKey)
+	cachedUrl := string(db.Cache.Get(cacheKey))
+	if cachedUrl != "" {
+		helpers.AppLogger.Infof("从缓存中查询到115下载链接: pickcode=%s, ua=%s => %s", pickCode, ua, cachedUrl)
+		if checkURLValidity(cachedUrl, ua) {
+			return cachedUrl, nil
+		}
+		helpers.AppLogger.Infof("缓存链接已失效，删除缓存并重新获取: pickcode=%s", pickCode)
+		db.Cache.Delete(cacheKey)
+	}
+	cachedUrl = account.Get115Client().GetDownloadUrl(ctx, pickCode, ua, true)
+	if cachedUrl == "" {
+		return "", errors.New("获取115下载链接失败")
+	}
+	helpers.AppLogger.Infof("从接口中查询到115下载链接: pickcode=%s, ua=%s => %s", pickCode, ua, cachedUrl)
+	// 缓存50分钟
+	db.Cache.Set(cacheKey, []byte(cachedUrl), 3000)
+	return cachedUrl, nil
+}
+
+// 获取百度网盘下载链接，按fsid和UA缓存
+func GetBaiduPanLink(ctx context.Context, account *models.Account, pickCode, ua string) (string, error) {
+	cacheKey := fmt.Sprintf("baidupanurl:%s, ua=%s", pickCode, ua)
+	if !linkLock.LockWithTimeout(cacheKey, 10*time.Second) {
+		return "", fmt.Errorf("获取百度网盘下载链接失败: %w", ErrLinkTimeout)
+	}
+	defer linkLock.Unlock(cacheKey)
+	cachedUrl := string(db.Cache.Get(cacheKey))
+	if cachedUrl != "" {
+		helpers.AppLogger.Infof("从缓存中查询到百度网盘下载链接: %s => %s", pickCode, cachedUrl)
+		return cachedUrl, nil
+	}
+	fsDetail, err := account.GetBaiDuPanClient().GetFileDetail(ctx, pickCode, 1)
+	if err != nil {
+		return "", fmt.Errorf("获取百度网盘文件详情失败: %w", err)
+	}
+	if fsDetail.Dlink == "" {
+		return "", errors.New("获取百度网盘下载链接失败")
+	}
+	cachedUrl = fmt.Sprintf("%s&access_token=%s", fsDetail.Dlink, account.Token)
+	helpers.AppLogger.Infof("从接口中查询到百度网盘下载链接: %s => %s", pickCode, cachedUrl)
+	// 缓存8小时
+	db.Cache.Set(cacheKey, []byte(cachedUrl), 27000)
+	return cachedUrl, nil
+}
+
+// checkURLValidity 使用HEAD请求检查URL是否有效
+// 返回true表示URL有效（2xx状态码），false表示URL已失效
+// ua参数：必须使用当前请求的USER-AGENT访问115链接（否则返回403）
+func checkURLValidity(urlStr string, ua string) bool {
+	helpers.AppLogger.Infof("URL有效性检查开始: %s, UA=%s", urlStr, ua)
+	client := &http.Client{
+		Timeout: 3 * time.Second,
+		Transport: &http.Transport{
+			ResponseHeaderTimeout: 2 * time.Second, // 等待响应头的超时
+			DisableKeepAlives:     true,            // 禁用长连接，请求完立即关闭
+			TLSHandshakeTimeout:   1 * time.Second, // TLS握手超时
+			MaxIdleConns:          0,               // 不保持空闲连接
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// 不跟随重定向，只检查第一次响应
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest("HEAD", urlStr, nil)
+	if err != nil {
+		helpers.AppLogger.Errorf("创建HEAD请求失败: %v", err)
+		return false
+	}
+
+	// 设置User-Agent，这是关键！115链接必须使用请求时的UA
+	if ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		helpers.AppLogger.Errorf("HEAD请求失败: %v", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	// 2xx状态码表示有效
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		helpers.AppLogger.Infof("URL有效性检查通过: 状态码=%d", resp.StatusCode)
+		return true
+	}
+
+	helpers.AppLogger.Infof("URL已失效: 状态码=%d", resp.StatusCode)
+	return false
+}
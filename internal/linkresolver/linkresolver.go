@@ -0,0 +1,86 @@
+package linkresolver
+
+import (
+	"Q115-STRM/internal/helpers"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 在进程内把STRM链接解析成网盘直链，不再通过HTTP请求QMediaSync的/115/url等接口
+// STRM中的域名只用来识别链接类型，Emby所在的网络访问不到该域名时也能解析
+type Request struct {
+	Url       string      // STRM文件中的链接
+	EmbyPath  string      // 媒体在Emby中的路径
+	UserAgent string      // 播放客户端的UA，115直链和UA绑定
+	Header    http.Header // 播放客户端的请求头
+}
+
+// 直链解析器
+type Resolver interface {
+	// 解析器名称，用于日志
+	Name() string
+	// 是否能解析该请求
+	Match(req *Request) bool
+	// 返回直链
+	Resolve(ctx context.Context, req *Request) (string, error)
+}
+
+var ErrNotMatched = errors.New("没有可以解析该链接的解析器")
+
+// 百度网盘的下载链接带有账号的access_token，只能通过本地代理播放，不能返回给客户端
+var ErrNeedProxy = errors.New("百度网盘的下载链接只能通过本地代理播放")
+
+// 按顺序尝试的解析器链，第一个解析成功的直链生效
+type Chain []Resolver
+
+func NewChain(resolvers ...Resolver) Chain {
+	return Chain(resolvers)
+}
+
+// 内置的解析器：115、百度网盘、QMediaSync的OpenList链接
+func Default() Chain {
+	return NewChain(&Pan115Resolver{}, &BaiduPanResolver{}, &OpenListResolver{})
+}
+
+// 依次尝试匹配的解析器，全部失败时返回所有错误，没有匹配的解析器返回ErrNotMatched
+// 链接只能通过本地代理播放时返回ErrNeedProxy
+func (ch Chain) Resolve(ctx context.Context, req *Request) (string, error) {
+	errs := make([]error, 0)
+	for _, r := range ch {
+		if !r.Match(req) {
+			continue
+		}
+		link, err := r.Resolve(ctx, req)
+		if err == nil && link == "" {
+			err = errors.New("直链为空")
+		}
+		if err == nil {
+			helpers.AppLogger.Infof("[%s] 解析直链成功: %s => %s", r.Name(), req.Url, link)
+			return link, nil
+		}
+		if errors.Is(err, ErrNeedProxy) {
+			helpers.AppLogger.Infof("[%s] 需要通过本地代理播放: %s", r.Name(), req.Url)
+			return "", err
+		}
+		helpers.AppLogger.Warnf("[%s] 解析直链失败: %s, %v", r.Name(), req.Url, err)
+		errs = append(errs, fmt.Errorf("%s: %w", r.Name(), err))
+	}
+	if len(errs) == 0 {
+		return "", ErrNotMatched
+	}
+	return "", errors.Join(errs...)
+}
+
+// 解析STRM中的http链接，不是http链接返回nil
+func parseStrmUrl(req *Request) *url.URL {
+	// strm文件内容末尾可能有换行
+	u, err := url.Parse(strings.TrimSpace(req.Url))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	return u
+}
@@ -0,0 +1,108 @@
+package linkresolver
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	helpers.AppLogger = &helpers.QLogger{
+		Logger: log.New(io.Discard, "", 0),
+	}
+	os.Exit(m.Run())
+}
+
+type fakeResolver struct {
+	name  string
+	match bool
+	link  string
+	err   error
+	calls int
+}
+
+func (f *fakeResolver) Name() string            { return f.name }
+func (f *fakeResolver) Match(req *Request) bool { return f.match }
+func (f *fakeResolver) Resolve(ctx context.Context, req *Request) (string, error) {
+	f.calls++
+	return f.link, f.err
+}
+
+func TestChainResolve(t *testing.T) {
+	skipped := &fakeResolver{name: "skipped", link: "http://skipped"}
+	failed := &fakeResolver{name: "failed", match: true, err: errors.New("boom")}
+	empty := &fakeResolver{name: "empty", match: true}
+	ok := &fakeResolver{name: "ok", match: true, link: "http://direct"}
+	after := &fakeResolver{name: "after", match: true, link: "http://after"}
+
+	link, err := NewChain(skipped, failed, empty, ok, after).Resolve(context.Background(), &Request{})
+	if err != nil || link != "http://direct" {
+		t.Fatalf("Resolve() = %q, %v; want http://direct", link, err)
+	}
+	if skipped.calls != 0 || failed.calls != 1 || empty.calls != 1 || after.calls != 0 {
+		t.Errorf("calls = skipped %d, failed %d, empty %d, after %d", skipped.calls, failed.calls, empty.calls, after.calls)
+	}
+
+	_, err = NewChain(skipped).Resolve(context.Background(), &Request{})
+	if !errors.Is(err, ErrNotMatched) {
+		t.Errorf("no match error = %v; want ErrNotMatched", err)
+	}
+	_, err = NewChain(skipped, failed, empty).Resolve(context.Background(), &Request{})
+	if err == nil || errors.Is(err, ErrNotMatched) || !strings.Contains(err.Error(), "failed: boom") || !strings.Contains(err.Error(), "empty: 直链为空") {
+		t.Errorf("all failed error = %v", err)
+	}
+}
+
+func TestDefaultMatch(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://qms.lan:12333/115/url/video.mkv?pickcode=abc&userid=1\n", "115"},
+		{"https://qms.example.com/base/115/newurl?pickcode=abc", "115"},
+		{"http://qms.lan:12333/baidupan/url/video.mp4?pickcode=123", "百度网盘"},
+		{"http://qms.lan:12333/openlist/url?account_id=1&path=/a.mkv", "OpenList"},
+		{"http://qms.lan:12333/123/url/video.mkv?pickcode=1", ""},
+		{"/mnt/115/url/video.mkv", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		for _, r := range Default() {
+			if r.Match(&Request{Url: tt.url}) {
+				got = r.Name()
+				break
+			}
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q) = %q; want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestChainResolveNeedProxy(t *testing.T) {
+	baidu := &fakeResolver{name: "baidu", match: true, err: ErrNeedProxy}
+	after := &fakeResolver{name: "after", match: true, link: "http://after"}
+	_, err := NewChain(baidu, after).Resolve(context.Background(), &Request{})
+	if !errors.Is(err, ErrNeedProxy) || after.calls != 0 {
+		t.Errorf("need proxy error = %v, after calls = %d", err, after.calls)
+	}
+}
+
+func TestDirectURL(t *testing.T) {
+	link, err := directURL(&Link{URL: "http://115", Account: &models.Account{SourceType: models.SourceType115}})
+	if err != nil || link != "http://115" {
+		t.Errorf("115 directURL() = %q, %v", link, err)
+	}
+	// 百度网盘的下载链接带有access_token，115文件的百度网盘副本也不能直接跳转
+	link, err = directURL(&Link{URL: "http://baidu?access_token=secret", Account: &models.Account{SourceType: models.SourceTypeBaiduPan}})
+	if !errors.Is(err, ErrNeedProxy) || link != "" {
+		t.Errorf("baidu directURL() = %q, %v", link, err)
+	}
+}
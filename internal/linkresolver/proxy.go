@@ -0,0 +1,34 @@
+package linkresolver
+
+import (
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/playproxy"
+	"Q115-STRM/internal/v115open"
+	"context"
+	"errors"
+)
+
+var ErrProxyNotSupported = errors.New("只有115和百度网盘的STRM链接支持本地代理播放")
+
+// 解析本地代理播放需要的下载链接，和/proxy-115接口使用相同的UA
+// 文件失效时使用其他副本，代理的来源按实际播放的副本设置
+func ProxyRequest(ctx context.Context, req *Request) (*playproxy.Request, error) {
+	if !(&Pan115Resolver{}).Match(req) && !(&BaiduPanResolver{}).Match(req) {
+		return nil, ErrProxyNotSupported
+	}
+	account, pickCode, err := strmAccount(req)
+	if err != nil {
+		return nil, err
+	}
+	// 115下载链接和UA绑定，代理时获取和请求都使用统一的UA，百度网盘的下载链接和UA无关
+	link, err := GetLink(ctx, account, pickCode, v115open.DEFAULTUA)
+	if err != nil {
+		return nil, err
+	}
+	proxyReq := &playproxy.Request{URL: link.URL, Source: playproxy.Source115, Key: link.PickCode, UserAgent: v115open.DEFAULTUA}
+	if link.Account.SourceType == models.SourceTypeBaiduPan {
+		proxyReq.Source = playproxy.SourceBaiduPan
+		proxyReq.UserAgent = "pan.baidu.com"
+	}
+	return proxyReq, nil
+}
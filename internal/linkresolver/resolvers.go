@@ -0,0 +1,128 @@
+package linkresolver
+
+import (
+	"Q115-STRM/internal/models"
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 校验STRM链接中的签名参数
+func checkStrmSign(q url.Values) error {
+	kid, _ := strconv.ParseUint(q.Get("kid"), 10, 64)
+	exp, _ := strconv.ParseInt(q.Get("exp"), 10, 64)
+	return models.CheckStrmSign(q.Get("pickcode"), q.Get("userid"), exp, uint(kid), q.Get("sign"))
+}
+
+// 从STRM链接中取出pickcode并查询账号
+func strmAccount(req *Request) (*models.Account, string, error) {
+	q := parseStrmUrl(req).Query()
+	pickCode := q.Get("pickcode")
+	if pickCode == "" {
+		return nil, "", errors.New("STRM链接中没有pickcode")
+	}
+	if err := checkStrmSign(q); err != nil {
+		return nil, "", err
+	}
+	account, err := GetAccount(pickCode, q.Get("userid"))
+	if err != nil {
+		return nil, "", err
+	}
+	return account, pickCode, nil
+}
+
+// 查询QMediaSync生成的115或百度网盘STRM链接对应的账号和pickcode，其他链接返回ErrNotMatched
+func StrmAccount(req *Request) (*models.Account, string, error) {
+	if !(&Pan115Resolver{}).Match(req) && !(&BaiduPanResolver{}).Match(req) {
+		return nil, "", ErrNotMatched
+	}
+	return strmAccount(req)
+}
+
+// 返回可以直接跳转的下载链接，百度网盘的链接（包括115文件失效后使用的百度网盘副本）返回ErrNeedProxy
+func directURL(link *Link) (string, error) {
+	if link.Account.SourceType == models.SourceTypeBaiduPan {
+		return "", ErrNeedProxy
+	}
+	return link.URL, nil
+}
+
+// 解析QMediaSync生成的115 STRM链接：/115/url/video.ext 和 /115/newurl
+type Pan115Resolver struct{}
+
+func (*Pan115Resolver) Name() string {
+	return "115"
+}
+
+func (*Pan115Resolver) Match(req *Request) bool {
+	u := parseStrmUrl(req)
+	return u != nil && (strings.Contains(u.Path, "/115/url/") || strings.HasSuffix(u.Path, "/115/newurl"))
+}
+
+func (*Pan115Resolver) Resolve(ctx context.Context, req *Request) (string, error) {
+	account, pickCode, err := strmAccount(req)
+	if err != nil {
+		return "", err
+	}
+	link, err := GetLink(ctx, account, pickCode, req.UserAgent)
+	if err != nil {
+		return "", err
+	}
+	return directURL(link)
+}
+
+// 解析QMediaSync生成的百度网盘STRM链接：/baidupan/url/video.ext
+type BaiduPanResolver struct{}
+
+func (*BaiduPanResolver) Name() string {
+	return "百度网盘"
+}
+
+func (*BaiduPanResolver) Match(req *Request) bool {
+	u := parseStrmUrl(req)
+	return u != nil && strings.Contains(u.Path, "/baidupan/url/")
+}
+
+func (*BaiduPanResolver) Resolve(ctx context.Context, req *Request) (string, error) {
+	account, pickCode, err := strmAccount(req)
+	if err != nil {
+		return "", err
+	}
+	link, err := GetLink(ctx, account, pickCode, req.UserAgent)
+	if err != nil {
+		return "", err
+	}
+	return directURL(link)
+}
+
+// 解析QMediaSync的OpenList链接：/openlist/url?account_id=&path=
+type OpenListResolver struct{}
+
+func (*OpenListResolver) Name() string {
+	return "OpenList"
+}
+
+func (*OpenListResolver) Match(req *Request) bool {
+	u := parseStrmUrl(req)
+	return u != nil && strings.HasSuffix(u.Path, "/openlist/url")
+}
+
+func (*OpenListResolver) Resolve(ctx context.Context, req *Request) (string, error) {
+	q := parseStrmUrl(req).Query()
+	path := q.Get("path")
+	if path == "" {
+		return "", errors.New("STRM链接中没有path")
+	}
+	accountId, _ := strconv.ParseUint(q.Get("account_id"), 10, 64)
+	account, err := models.GetAccountById(uint(accountId))
+	if err != nil {
+		return "", errors.New("账号ID不存在")
+	}
+	fileDetail, err := account.GetOpenListClient().FileDetail(path)
+	if err != nil {
+		return "", err
+	}
+	return fileDetail.RawURL, nil
+}
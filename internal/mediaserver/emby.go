@@ -0,0 +1,206 @@
+package mediaserver
+
+import (
+	embyclientrestgo "Q115-STRM/internal/embyclient-rest-go"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Emby，使用embyclient-rest-go
+type Emby struct {
+	url    string
+	apiKey string
+	client *embyclientrestgo.Client
+	mu     sync.Mutex
+	userId string // 可以访问所有媒体库的用户，查询详情时使用
+}
+
+func newEmby(serverUrl, apiKey string) *Emby {
+	return &Emby{url: serverUrl, apiKey: apiKey, client: embyclientrestgo.NewClient(serverUrl, apiKey)}
+}
+
+func (e *Emby) Type() ServerType {
+	return ServerTypeEmby
+}
+
+func (e *Emby) GetLibraries() ([]Library, error) {
+	libs, err := e.client.GetAllMediaLibraries()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Library, 0, len(libs))
+	for _, lib := range libs {
+		result = append(result, Library{ID: lib.ID, Name: lib.Name})
+	}
+	return result, nil
+}
+
+func (e *Emby) GetLibraryItems(libraryId string, lastDateCreatedTime int64) ([]Item, error) {
+	items, err := e.client.GetMediaItemsByLibraryID(libraryId, lastDateCreatedTime)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Item, 0, len(items))
+	for _, item := range items {
+		result = append(result, embyItem(&item))
+	}
+	return result, nil
+}
+
+func (e *Emby) GetItemLibraries(itemId string) ([]Library, error) {
+	folders, err := e.client.GetItemLibraryId(itemId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Library, 0, len(folders))
+	for _, folder := range folders {
+		result = append(result, Library{ID: folder.ID, Name: folder.Name})
+	}
+	return result, nil
+}
+
+func (e *Emby) getUserId() (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.userId != "" {
+		return e.userId, nil
+	}
+	users, err := e.client.GetUsersWithAllLibrariesAccess()
+	if err != nil {
+		return "", err
+	}
+	if len(users) == 0 {
+		return "", errors.New("没有找到可以访问所有媒体库的Emby用户")
+	}
+	// 使用第一个有权限的用户
+	e.userId = users[0].ID
+	return e.userId, nil
+}
+
+func (e *Emby) GetItemDetail(itemId string) (*ItemDetail, error) {
+	userId, err := e.getUserId()
+	if err != nil {
+		return nil, err
+	}
+	item, err := e.client.GetItemDetailByUser(itemId, userId)
+	if err != nil {
+		return nil, err
+	}
+	detail := &ItemDetail{
+		Item:            embyItem(item),
+		Overview:        item.Overview,
+		CommunityRating: item.CommunityRating,
+		Genres:          item.Genres,
+	}
+	for _, person := range item.People {
+		if person.Type == "Actor" {
+			detail.Actors = append(detail.Actors, person.Name)
+		}
+	}
+	if tag, ok := item.ImageTags["Primary"]; ok {
+		detail.PrimaryImageUrl = e.imageUrl(item.Id, "Primary", tag)
+	}
+	if tag, ok := item.ImageTags["backdrop"]; ok {
+		detail.BackdropImageUrl = e.imageUrl(item.Id, "Backdrop", tag)
+	}
+	return detail, nil
+}
+
+func (e *Emby) RefreshLibrary(libraryId string, libraryName string) error {
+	return e.client.RefreshLibrary(libraryId, libraryName)
+}
+
+func (e *Emby) imageUrl(itemId, imageType, tag string) string {
+	return fmt.Sprintf("%s/emby/Items/%s/Images/%s?tag=%s&api_key=%s", e.url, itemId, imageType, tag, e.apiKey)
+}
+
+// Emby Webhook消息
+type embyWebhook struct {
+	Event string `json:"Event"`
+	Item  struct {
+		Name              string            `json:"Name"`
+		ID                string            `json:"Id"`
+		Type              string            `json:"Type"`
+		SeriesName        string            `json:"SeriesName"`
+		SeriesId          string            `json:"SeriesId"`
+		IndexNumber       int               `json:"IndexNumber"`
+		ParentIndexNumber int               `json:"ParentIndexNumber"`
+		ProductionYear    int               `json:"ProductionYear"`
+		ImageTags         map[string]string `json:"ImageTags"`
+	} `json:"Item"`
+	User struct {
+		Name string `json:"Name"`
+		ID   string `json:"Id"`
+	} `json:"User"`
+	Session struct {
+		DeviceName string `json:"DeviceName"`
+		Client     string `json:"Client"`
+	} `json:"Session"`
+	PlaybackInfo struct {
+		PositionTicks int64  `json:"PositionTicks"`
+		PlaySessionId string `json:"PlaySessionId"`
+		MediaSource   struct {
+			RunTimeTicks int64 `json:"RunTimeTicks"`
+		} `json:"MediaSource"`
+	} `json:"PlaybackInfo"`
+}
+
+func (e *Emby) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var w embyWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return nil, err
+	}
+	event := &WebhookEvent{
+		Event: w.Event,
+		Item: WebhookItem{
+			Id:             w.Item.ID,
+			Name:           w.Item.Name,
+			Type:           w.Item.Type,
+			SeriesId:       w.Item.SeriesId,
+			SeriesName:     w.Item.SeriesName,
+			SeasonNumber:   w.Item.ParentIndexNumber,
+			EpisodeNumber:  w.Item.IndexNumber,
+			ProductionYear: w.Item.ProductionYear,
+		},
+		UserId:        w.User.ID,
+		UserName:      w.User.Name,
+		DeviceName:    w.Session.DeviceName,
+		Client:        w.Session.Client,
+		PositionTicks: w.PlaybackInfo.PositionTicks,
+		RunTimeTicks:  w.PlaybackInfo.MediaSource.RunTimeTicks,
+		PlaySessionId: w.PlaybackInfo.PlaySessionId,
+	}
+	if tag, ok := w.Item.ImageTags["Primary"]; ok {
+		event.Item.PrimaryImageUrl = e.imageUrl(w.Item.ID, "Primary", tag)
+	}
+	return event, nil
+}
+
+func embyItem(item *embyclientrestgo.BaseItemDtoV2) Item {
+	result := Item{
+		Id:                item.Id,
+		Name:              item.Name,
+		Type:              item.Type,
+		ParentId:          item.ParentId,
+		SeriesId:          item.SeriesId,
+		SeriesName:        item.SeriesName,
+		SeasonId:          item.SeasonId,
+		SeasonName:        item.SeasonName,
+		Path:              item.Path,
+		IndexNumber:       item.IndexNumber,
+		ParentIndexNumber: item.ParentIndexNumber,
+		ProductionYear:    item.ProductionYear,
+		PremiereDate:      item.PremiereDate,
+		DateCreated:       item.DateCreated,
+		DateCreatedTime:   parseTime(item.DateCreated),
+		DateModified:      item.DateModified,
+		DateModifiedTime:  parseTime(item.DateModified),
+		IsFolder:          item.IsFolder,
+	}
+	for _, src := range item.MediaSources {
+		result.MediaSourcePaths = append(result.MediaSourcePaths, src.Path)
+	}
+	return result
+}
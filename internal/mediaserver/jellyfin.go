@@ -0,0 +1,273 @@
+package mediaserver
+
+import (
+	"Q115-STRM/internal/helpers"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Jellyfin，接口和Emby基本一致，但是没有/emby前缀，使用Authorization头认证
+type Jellyfin struct {
+	url    string
+	apiKey string
+}
+
+func newJellyfin(serverUrl, apiKey string) *Jellyfin {
+	return &Jellyfin{url: serverUrl, apiKey: apiKey}
+}
+
+func (j *Jellyfin) Type() ServerType {
+	return ServerTypeJellyfin
+}
+
+type jellyfinItem struct {
+	Id                string            `json:"Id"`
+	Name              string            `json:"Name"`
+	Type              string            `json:"Type"`
+	ParentId          string            `json:"ParentId"`
+	SeriesId          string            `json:"SeriesId"`
+	SeriesName        string            `json:"SeriesName"`
+	SeasonId          string            `json:"SeasonId"`
+	SeasonName        string            `json:"SeasonName"`
+	Path              string            `json:"Path"`
+	IndexNumber       int               `json:"IndexNumber"`
+	ParentIndexNumber int               `json:"ParentIndexNumber"`
+	ProductionYear    int               `json:"ProductionYear"`
+	PremiereDate      string            `json:"PremiereDate"`
+	DateCreated       string            `json:"DateCreated"`
+	DateModified      string            `json:"DateLastSaved"`
+	IsFolder          bool              `json:"IsFolder"`
+	Overview          string            `json:"Overview"`
+	CommunityRating   float64           `json:"CommunityRating"`
+	Genres            []string          `json:"Genres"`
+	ImageTags         map[string]string `json:"ImageTags"`
+	BackdropImageTags []string          `json:"BackdropImageTags"`
+	MediaSources      []struct {
+		Path string `json:"Path"`
+	} `json:"MediaSources"`
+	People []struct {
+		Name string `json:"Name"`
+		Type string `json:"Type"`
+	} `json:"People"`
+}
+
+type jellyfinItemsResponse struct {
+	Items            []jellyfinItem `json:"Items"`
+	TotalRecordCount int            `json:"TotalRecordCount"`
+}
+
+func (j *Jellyfin) get(path string, params url.Values, out any) error {
+	return j.do(http.MethodGet, path, params, out)
+}
+
+func (j *Jellyfin) do(method, path string, params url.Values, out any) error {
+	u := j.url + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求时出错: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf(`MediaBrowser Client="QMediaSync", Token="%s"`, j.apiKey))
+	return doJSON(req, out)
+}
+
+func (j *Jellyfin) GetLibraries() ([]Library, error) {
+	var resp jellyfinItemsResponse
+	if err := j.get("/Library/MediaFolders", nil, &resp); err != nil {
+		return nil, err
+	}
+	result := make([]Library, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		result = append(result, Library{ID: item.Id, Name: item.Name})
+	}
+	return result, nil
+}
+
+func (j *Jellyfin) GetLibraryItems(libraryId string, lastDateCreatedTime int64) ([]Item, error) {
+	const limit = 100
+	result := make([]Item, 0)
+	startIndex := 0
+	for {
+		params := url.Values{}
+		params.Add("ParentId", libraryId)
+		params.Add("StartIndex", fmt.Sprintf("%d", startIndex))
+		params.Add("Limit", fmt.Sprintf("%d", limit))
+		params.Add("Recursive", "true")
+		params.Add("IncludeItemTypes", "Movie,Video,Episode")
+		params.Add("Fields", "Path,MediaSources,DateCreated,DateLastSaved,ParentId,PremiereDate")
+		params.Add("SortBy", "DateCreated")
+		params.Add("SortOrder", "Descending")
+		var resp jellyfinItemsResponse
+		if err := j.get("/Items", params, &resp); err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Items {
+			converted := j.item(&item)
+			if lastDateCreatedTime > 0 && converted.DateCreatedTime == lastDateCreatedTime {
+				helpers.AppLogger.Infof("找到最后一个项目 %s =>%d", item.Id, lastDateCreatedTime)
+				return result, nil
+			}
+			result = append(result, converted)
+		}
+		startIndex += len(resp.Items)
+		if len(resp.Items) == 0 || startIndex >= resp.TotalRecordCount {
+			return result, nil
+		}
+	}
+}
+
+// 媒体项的祖先中类型为CollectionFolder的就是媒体库
+func (j *Jellyfin) GetItemLibraries(itemId string) ([]Library, error) {
+	var ancestors []jellyfinItem
+	if err := j.get(fmt.Sprintf("/Items/%s/Ancestors", itemId), nil, &ancestors); err != nil {
+		return nil, err
+	}
+	result := make([]Library, 0, 1)
+	for _, ancestor := range ancestors {
+		if ancestor.Type == "CollectionFolder" {
+			result = append(result, Library{ID: ancestor.Id, Name: ancestor.Name})
+		}
+	}
+	return result, nil
+}
+
+func (j *Jellyfin) GetItemDetail(itemId string) (*ItemDetail, error) {
+	params := url.Values{}
+	params.Add("Ids", itemId)
+	params.Add("Fields", "Path,MediaSources,DateCreated,Overview,Genres,People")
+	var resp jellyfinItemsResponse
+	if err := j.get("/Items", params, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Items) == 0 {
+		return nil, fmt.Errorf("Jellyfin中不存在媒体 %s", itemId)
+	}
+	item := &resp.Items[0]
+	detail := &ItemDetail{
+		Item:            j.item(item),
+		Overview:        item.Overview,
+		CommunityRating: item.CommunityRating,
+		Genres:          item.Genres,
+	}
+	for _, person := range item.People {
+		if person.Type == "Actor" {
+			detail.Actors = append(detail.Actors, person.Name)
+		}
+	}
+	if tag, ok := item.ImageTags["Primary"]; ok {
+		detail.PrimaryImageUrl = j.imageUrl(item.Id, "Primary", tag)
+	}
+	if len(item.BackdropImageTags) > 0 {
+		detail.BackdropImageUrl = j.imageUrl(item.Id, "Backdrop", item.BackdropImageTags[0])
+	}
+	return detail, nil
+}
+
+func (j *Jellyfin) RefreshLibrary(libraryId string, libraryName string) error {
+	params := url.Values{}
+	params.Add("Recursive", "true")
+	if err := j.do(http.MethodPost, fmt.Sprintf("/Items/%s/Refresh", libraryId), params, nil); err != nil {
+		return err
+	}
+	helpers.AppLogger.Infof("已触发Jellyfin媒体库 %s => %s 刷新", libraryId, libraryName)
+	return nil
+}
+
+func (j *Jellyfin) imageUrl(itemId, imageType, tag string) string {
+	return fmt.Sprintf("%s/Items/%s/Images/%s?tag=%s&api_key=%s", j.url, itemId, imageType, tag, j.apiKey)
+}
+
+func (j *Jellyfin) item(item *jellyfinItem) Item {
+	result := Item{
+		Id:                item.Id,
+		Name:              item.Name,
+		Type:              item.Type,
+		ParentId:          item.ParentId,
+		SeriesId:          item.SeriesId,
+		SeriesName:        item.SeriesName,
+		SeasonId:          item.SeasonId,
+		SeasonName:        item.SeasonName,
+		Path:              item.Path,
+		IndexNumber:       item.IndexNumber,
+		ParentIndexNumber: item.ParentIndexNumber,
+		ProductionYear:    item.ProductionYear,
+		PremiereDate:      item.PremiereDate,
+		DateCreated:       item.DateCreated,
+		DateCreatedTime:   parseTime(item.DateCreated),
+		DateModified:      item.DateModified,
+		DateModifiedTime:  parseTime(item.DateModified),
+		IsFolder:          item.IsFolder,
+	}
+	for _, src := range item.MediaSources {
+		result.MediaSourcePaths = append(result.MediaSourcePaths, src.Path)
+	}
+	return result
+}
+
+// jellyfin-plugin-webhook 默认模板的消息
+type jellyfinWebhook struct {
+	NotificationType      string `json:"NotificationType"`
+	ItemId                string `json:"ItemId"`
+	ItemType              string `json:"ItemType"`
+	Name                  string `json:"Name"`
+	SeriesId              string `json:"SeriesId"`
+	SeriesName            string `json:"SeriesName"`
+	SeasonNumber          int    `json:"SeasonNumber"`
+	EpisodeNumber         int    `json:"EpisodeNumber"`
+	Year                  int    `json:"Year"`
+	NotificationUsername  string `json:"NotificationUsername"`
+	UserId                string `json:"UserId"`
+	DeviceName            string `json:"DeviceName"`
+	ClientName            string `json:"ClientName"`
+	PlaybackPositionTicks int64  `json:"PlaybackPositionTicks"`
+	RunTimeTicks          int64  `json:"RunTimeTicks"`
+	IsPaused              bool   `json:"IsPaused"`
+	PlaySessionId         string `json:"PlaySessionId"`
+}
+
+func (j *Jellyfin) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var w jellyfinWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return nil, err
+	}
+	event := &WebhookEvent{
+		Item: WebhookItem{
+			Id:              w.ItemId,
+			Name:            w.Name,
+			Type:            w.ItemType,
+			SeriesId:        w.SeriesId,
+			SeriesName:      w.SeriesName,
+			SeasonNumber:    w.SeasonNumber,
+			EpisodeNumber:   w.EpisodeNumber,
+			ProductionYear:  w.Year,
+			PrimaryImageUrl: j.imageUrl(w.ItemId, "Primary", ""),
+		},
+		UserId:        w.UserId,
+		UserName:      w.NotificationUsername,
+		DeviceName:    w.DeviceName,
+		Client:        w.ClientName,
+		PositionTicks: w.PlaybackPositionTicks,
+		RunTimeTicks:  w.RunTimeTicks,
+		PlaySessionId: w.PlaySessionId,
+	}
+	switch w.NotificationType {
+	case "ItemAdded":
+		event.Event = EventLibraryNew
+	case "ItemDeleted":
+		event.Event = EventLibraryDeleted
+	case "PlaybackStart":
+		event.Event = EventPlaybackStart
+	case "PlaybackStop":
+		event.Event = EventPlaybackStop
+	case "PlaybackProgress":
+		// 进度事件很频繁，只处理暂停
+		if w.IsPaused {
+			event.Event = EventPlaybackPause
+		}
+	}
+	return event, nil
+}
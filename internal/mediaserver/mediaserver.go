@@ -0,0 +1,190 @@
+package mediaserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 媒体服务器类型
+type ServerType string
+
+const (
+	ServerTypeEmby     ServerType = "emby"
+	ServerTypeJellyfin ServerType = "jellyfin"
+	ServerTypePlex     ServerType = "plex"
+)
+
+// 用于日志和通知的显示名称
+func (t ServerType) DisplayName() string {
+	switch t {
+	case ServerTypeJellyfin:
+		return "Jellyfin"
+	case ServerTypePlex:
+		return "Plex"
+	default:
+		return "Emby"
+	}
+}
+
+func IsValidServerType(t ServerType) bool {
+	return t == ServerTypeEmby || t == ServerTypeJellyfin || t == ServerTypePlex
+}
+
+// 媒体类型，统一使用Emby的命名
+const (
+	ItemTypeMovie   = "Movie"
+	ItemTypeEpisode = "Episode"
+	ItemTypeSeason  = "Season"
+	ItemTypeSeries  = "Series"
+	ItemTypeVideo   = "Video"
+)
+
+// Webhook事件类型，统一使用Emby的命名
+const (
+	EventLibraryNew     = "library.new"
+	EventLibraryDeleted = "library.deleted"
+	EventPlaybackStart  = "playback.start"
+	EventPlaybackPause  = "playback.pause"
+	EventPlaybackStop   = "playback.stop"
+)
+
+// 媒体库
+type Library struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// 媒体项
+type Item struct {
+	Id                string
+	Name              string
+	Type              string
+	ParentId          string
+	SeriesId          string
+	SeriesName        string
+	SeasonId          string
+	SeasonName        string
+	Path              string
+	MediaSourcePaths  []string // 所有媒体源的路径，STRM文件是其中的链接
+	IndexNumber       int
+	ParentIndexNumber int
+	ProductionYear    int
+	PremiereDate      string
+	DateCreated       string
+	DateCreatedTime   int64
+	DateModified      string
+	DateModifiedTime  int64
+	IsFolder          bool
+}
+
+// 媒体详情，用于发送通知
+type ItemDetail struct {
+	Item
+	Overview         string
+	CommunityRating  float64
+	Genres           []string
+	Actors           []string
+	PrimaryImageUrl  string
+	BackdropImageUrl string
+}
+
+// Webhook中的媒体项
+type WebhookItem struct {
+	Id              string
+	Name            string
+	Type            string
+	SeriesId        string
+	SeriesName      string
+	SeasonNumber    int
+	EpisodeNumber   int
+	ProductionYear  int
+	PrimaryImageUrl string
+}
+
+// 统一格式的Webhook事件
+type WebhookEvent struct {
+	Event         string // 不需要处理的事件为空
+	Item          WebhookItem
+	UserId        string
+	UserName      string
+	DeviceName    string
+	Client        string
+	PositionTicks int64 // 播放位置，单位100纳秒
+	RunTimeTicks  int64 // 总时长，单位100纳秒
+	PlaySessionId string
+}
+
+// 媒体服务器
+type MediaServer interface {
+	Type() ServerType
+	// 所有媒体库
+	GetLibraries() ([]Library, error)
+	// 媒体库中的视频，按入库时间倒序，查到入库时间等于lastDateCreatedTime的项目就停止，为0则查询全部
+	GetLibraryItems(libraryId string, lastDateCreatedTime int64) ([]Item, error)
+	// 媒体项所属的媒体库
+	GetItemLibraries(itemId string) ([]Library, error)
+	GetItemDetail(itemId string) (*ItemDetail, error)
+	RefreshLibrary(libraryId string, libraryName string) error
+	// 解析Webhook请求体，Plex的请求体是multipart中的payload字段
+	ParseWebhook(body []byte) (*WebhookEvent, error)
+}
+
+// 创建媒体服务器客户端，token在Emby和Jellyfin中是API Key，在Plex中是X-Plex-Token
+func New(serverType ServerType, serverUrl string, token string) (MediaServer, error) {
+	serverUrl = strings.TrimSuffix(serverUrl, "/")
+	if serverUrl == "" || token == "" {
+		return nil, fmt.Errorf("%s地址或密钥为空", serverType.DisplayName())
+	}
+	switch serverType {
+	case ServerTypeEmby, "":
+		return newEmby(serverUrl, token), nil
+	case ServerTypeJellyfin:
+		return newJellyfin(serverUrl, token), nil
+	case ServerTypePlex:
+		return newPlex(serverUrl, token), nil
+	default:
+		return nil, fmt.Errorf("不支持的媒体服务器类型 %s", serverType)
+	}
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// 发送请求并解析JSON响应
+func doJSON(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求时出错: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("错误: 收到非 200 状态码: %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应体时出错: %w", err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析 json 时出错: %w", err)
+	}
+	return nil
+}
+
+// 解析RFC3339格式的时间，失败返回0
+func parseTime(s string) int64 {
+	if s == "" {
+		return 0
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
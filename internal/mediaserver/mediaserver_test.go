@@ -0,0 +1,178 @@
+package mediaserver
+
+import (
+	"Q115-STRM/internal/helpers"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	helpers.AppLogger = &helpers.QLogger{
+		Logger: log.New(os.Stdout, "", 0),
+	}
+	os.Exit(m.Run())
+}
+
+func TestJellyfinGetLibraryItems(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != `MediaBrowser Client="QMediaSync", Token="key"` {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/Items":
+			if r.URL.Query().Get("ParentId") != "lib1" {
+				t.Errorf("ParentId错误: %s", r.URL.Query().Get("ParentId"))
+			}
+			fmt.Fprint(w, `{"TotalRecordCount":2,"Items":[
+				{"Id":"a1b2","Name":"第1集","Type":"Episode","SeriesId":"s1","SeasonId":"se1","IndexNumber":1,"ParentIndexNumber":1,"DateCreated":"2026-01-21T16:00:00.0000000Z","MediaSources":[{"Path":"http://127.0.0.1:12333/115/url/video.mkv?pickcode=abc"}]},
+				{"Id":"c3d4","Name":"电影","Type":"Movie","DateCreated":"2026-01-20T16:00:00Z","MediaSources":[{"Path":"/media/movie.mkv"}]}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	server, err := New(ServerTypeJellyfin, srv.URL+"/", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := server.GetLibraryItems("lib1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("应该返回2个媒体项，实际 %d", len(items))
+	}
+	if items[0].Id != "a1b2" || items[0].SeriesId != "s1" || items[0].DateCreatedTime != 1769011200 {
+		t.Errorf("媒体项转换错误: %+v", items[0])
+	}
+	if !slices.Equal(items[0].MediaSourcePaths, []string{"http://127.0.0.1:12333/115/url/video.mkv?pickcode=abc"}) {
+		t.Errorf("媒体源路径错误: %v", items[0].MediaSourcePaths)
+	}
+	// 查到上次同步的最后一项就停止
+	items, err = server.GetLibraryItems("lib1", 1769011200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("增量查询应该没有新媒体项，实际 %d", len(items))
+	}
+}
+
+func TestJellyfinParseWebhook(t *testing.T) {
+	server, _ := New(ServerTypeJellyfin, "http://jellyfin:8096", "key")
+	event, err := server.ParseWebhook([]byte(`{"NotificationType":"PlaybackProgress","ItemId":"a1b2","ItemType":"Episode","Name":"第1集","SeriesName":"剧","SeasonNumber":1,"EpisodeNumber":2,"NotificationUsername":"user","IsPaused":true,"PlaybackPositionTicks":600000000,"RunTimeTicks":1200000000}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != EventPlaybackPause || event.Item.SeasonNumber != 1 || event.Item.EpisodeNumber != 2 || event.UserName != "user" {
+		t.Errorf("暂停事件解析错误: %+v", event)
+	}
+	event, _ = server.ParseWebhook([]byte(`{"NotificationType":"PlaybackProgress","ItemId":"a1b2","IsPaused":false}`))
+	if event.Event != "" {
+		t.Errorf("播放进度事件应该忽略: %s", event.Event)
+	}
+	event, _ = server.ParseWebhook([]byte(`{"NotificationType":"ItemDeleted","ItemId":"c3d4","ItemType":"Movie"}`))
+	if event.Event != EventLibraryDeleted || event.Item.Type != ItemTypeMovie {
+		t.Errorf("删除事件解析错误: %+v", event)
+	}
+}
+
+func TestPlexGetLibraryItems(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("X-Plex-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/library/sections":
+			fmt.Fprint(w, `{"MediaContainer":{"Directory":[{"key":"1","title":"电影","type":"movie"},{"key":"2","title":"剧集","type":"show"},{"key":"3","title":"音乐","type":"artist"}]}}`)
+		case "/library/sections/2/all":
+			if r.URL.Query().Get("type") != "4" {
+				t.Errorf("剧集库应该查询集: %s", r.URL.Query().Get("type"))
+			}
+			fmt.Fprint(w, `{"MediaContainer":{"size":1,"totalSize":1,"Metadata":[
+				{"ratingKey":"101","parentRatingKey":"100","grandparentRatingKey":"99","type":"episode","title":"第3集","grandparentTitle":"剧","index":3,"parentIndex":2,"addedAt":1769011200,"Media":[{"Part":[{"file":"/strm/剧/S02E03.strm"}]}]}
+			]}}`)
+		case "/library/metadata/101":
+			fmt.Fprint(w, `{"MediaContainer":{"librarySectionID":2,"librarySectionTitle":"剧集","Metadata":[{"ratingKey":"101","type":"episode","title":"第3集","summary":"简介","audienceRating":8.5,"thumb":"/library/metadata/101/thumb/1","Role":[{"tag":"演员"}]}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	server, err := New(ServerTypePlex, srv.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	libs, err := server.GetLibraries()
+	if err != nil || len(libs) != 3 {
+		t.Fatalf("媒体库查询错误: %v %v", libs, err)
+	}
+	items, err := server.GetLibraryItems("2", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("应该返回1个媒体项，实际 %d", len(items))
+	}
+	item := items[0]
+	if item.Type != ItemTypeEpisode || item.SeriesId != "99" || item.SeasonId != "100" || item.ParentIndexNumber != 2 || item.Path != "/strm/剧/S02E03.strm" {
+		t.Errorf("媒体项转换错误: %+v", item)
+	}
+	// 音乐库没有视频
+	if items, _ := server.GetLibraryItems("3", 0); len(items) != 0 {
+		t.Errorf("音乐库不应该返回媒体项")
+	}
+	libs, err = server.GetItemLibraries("101")
+	if err != nil || len(libs) != 1 || libs[0].ID != "2" {
+		t.Errorf("媒体项所属媒体库错误: %v %v", libs, err)
+	}
+	detail, err := server.GetItemDetail("101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.CommunityRating != 8.5 || !slices.Equal(detail.Actors, []string{"演员"}) || detail.PrimaryImageUrl != srv.URL+"/library/metadata/101/thumb/1?X-Plex-Token=token" {
+		t.Errorf("媒体详情错误: %+v", detail)
+	}
+}
+
+func TestPlexParseWebhook(t *testing.T) {
+	server, _ := New(ServerTypePlex, "http://plex:32400", "token")
+	event, err := server.ParseWebhook([]byte(`{"event":"media.resume","Account":{"id":1,"title":"user"},"Player":{"title":"客厅电视","uuid":"abc"},"Metadata":{"ratingKey":"101","type":"episode","title":"第3集","grandparentRatingKey":"99","grandparentTitle":"剧","index":3,"parentIndex":2,"viewOffset":60000,"duration":120000}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != EventPlaybackStart || event.UserId != "1" || event.DeviceName != "客厅电视" {
+		t.Errorf("播放事件解析错误: %+v", event)
+	}
+	if event.Item.SeriesName != "剧" || event.Item.SeasonNumber != 2 || event.Item.EpisodeNumber != 3 {
+		t.Errorf("剧集信息解析错误: %+v", event.Item)
+	}
+	// 毫秒转换为100纳秒
+	if event.PositionTicks != 600000000 || event.RunTimeTicks != 1200000000 {
+		t.Errorf("播放进度转换错误: %d / %d", event.PositionTicks, event.RunTimeTicks)
+	}
+	event, _ = server.ParseWebhook([]byte(`{"event":"library.new","Metadata":{"ratingKey":"102","type":"movie","title":"电影","year":2026}}`))
+	if event.Event != EventLibraryNew || event.Item.Type != ItemTypeMovie || event.Item.ProductionYear != 2026 {
+		t.Errorf("入库事件解析错误: %+v", event)
+	}
+}
+
+func TestNewUnsupportedServer(t *testing.T) {
+	if _, err := New("kodi", "http://kodi", "key"); err == nil {
+		t.Errorf("不支持的媒体服务器类型应该返回错误")
+	}
+	server, err := New("", "http://emby:8096", "key")
+	if err != nil || server.Type() != ServerTypeEmby {
+		t.Errorf("未设置类型时应该是Emby: %v", err)
+	}
+}
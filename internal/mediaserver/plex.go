@@ -0,0 +1,362 @@
+package mediaserver
+
+import (
+	"Q115-STRM/internal/helpers"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Plex，使用X-Plex-Token认证，媒体项的ID是ratingKey
+type Plex struct {
+	url          string
+	token        string
+	mu           sync.Mutex
+	sectionTypes map[string]string // 媒体库ID => 类型（movie、show）
+}
+
+func newPlex(serverUrl, token string) *Plex {
+	return &Plex{url: serverUrl, token: token, sectionTypes: make(map[string]string)}
+}
+
+func (p *Plex) Type() ServerType {
+	return ServerTypePlex
+}
+
+type plexTag struct {
+	Tag string `json:"tag"`
+}
+
+type plexMetadata struct {
+	RatingKey             string    `json:"ratingKey"`
+	ParentRatingKey       string    `json:"parentRatingKey"`
+	GrandparentRatingKey  string    `json:"grandparentRatingKey"`
+	Type                  string    `json:"type"`
+	Title                 string    `json:"title"`
+	ParentTitle           string    `json:"parentTitle"`
+	GrandparentTitle      string    `json:"grandparentTitle"`
+	Summary               string    `json:"summary"`
+	Index                 int       `json:"index"`
+	ParentIndex           int       `json:"parentIndex"`
+	Year                  int       `json:"year"`
+	OriginallyAvailableAt string    `json:"originallyAvailableAt"`
+	AddedAt               int64     `json:"addedAt"`
+	UpdatedAt             int64     `json:"updatedAt"`
+	Rating                float64   `json:"rating"`
+	AudienceRating        float64   `json:"audienceRating"`
+	Thumb                 string    `json:"thumb"`
+	Art                   string    `json:"art"`
+	ViewOffset            int64     `json:"viewOffset"` // 毫秒
+	Duration              int64     `json:"duration"`   // 毫秒
+	LibrarySectionID      any       `json:"librarySectionID"`
+	LibrarySectionTitle   string    `json:"librarySectionTitle"`
+	Genre                 []plexTag `json:"Genre"`
+	Role                  []plexTag `json:"Role"`
+	Media                 []struct {
+		Part []struct {
+			File string `json:"file"`
+		} `json:"Part"`
+	} `json:"Media"`
+}
+
+type plexDirectory struct {
+	Key   string `json:"key"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+}
+
+type plexResponse struct {
+	MediaContainer struct {
+		Size                int             `json:"size"`
+		TotalSize           int             `json:"totalSize"`
+		LibrarySectionID    any             `json:"librarySectionID"`
+		LibrarySectionTitle string          `json:"librarySectionTitle"`
+		Directory           []plexDirectory `json:"Directory"`
+		Metadata            []plexMetadata  `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+func (p *Plex) do(method, path string, params url.Values, headers map[string]string, out any) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("X-Plex-Token", p.token)
+	req, err := http.NewRequest(method, p.url+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("创建请求时出错: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return doJSON(req, out)
+}
+
+func (p *Plex) GetLibraries() ([]Library, error) {
+	var resp plexResponse
+	if err := p.do(http.MethodGet, "/library/sections", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := make([]Library, 0, len(resp.MediaContainer.Directory))
+	for _, dir := range resp.MediaContainer.Directory {
+		p.sectionTypes[dir.Key] = dir.Type
+		result = append(result, Library{ID: dir.Key, Name: dir.Title})
+	}
+	return result, nil
+}
+
+func (p *Plex) getSectionType(libraryId string) (string, error) {
+	p.mu.Lock()
+	sectionType, ok := p.sectionTypes[libraryId]
+	p.mu.Unlock()
+	if ok {
+		return sectionType, nil
+	}
+	if _, err := p.GetLibraries(); err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sectionTypes[libraryId], nil
+}
+
+// 电影库查询电影，剧集库查询集，其他类型的媒体库没有视频
+func (p *Plex) GetLibraryItems(libraryId string, lastDateCreatedTime int64) ([]Item, error) {
+	sectionType, err := p.getSectionType(libraryId)
+	if err != nil {
+		return nil, err
+	}
+	var plexType string
+	switch sectionType {
+	case "movie":
+		plexType = "1"
+	case "show":
+		plexType = "4"
+	default:
+		return nil, nil
+	}
+	const limit = 100
+	result := make([]Item, 0)
+	start := 0
+	for {
+		params := url.Values{}
+		params.Add("type", plexType)
+		params.Add("sort", "addedAt:desc")
+		headers := map[string]string{
+			"X-Plex-Container-Start": fmt.Sprintf("%d", start),
+			"X-Plex-Container-Size":  fmt.Sprintf("%d", limit),
+		}
+		var resp plexResponse
+		if err := p.do(http.MethodGet, fmt.Sprintf("/library/sections/%s/all", libraryId), params, headers, &resp); err != nil {
+			return nil, err
+		}
+		for _, metadata := range resp.MediaContainer.Metadata {
+			if lastDateCreatedTime > 0 && metadata.AddedAt == lastDateCreatedTime {
+				helpers.AppLogger.Infof("找到最后一个项目 %s =>%d", metadata.RatingKey, lastDateCreatedTime)
+				return result, nil
+			}
+			result = append(result, p.item(&metadata))
+		}
+		start += len(resp.MediaContainer.Metadata)
+		if len(resp.MediaContainer.Metadata) == 0 || start >= resp.MediaContainer.TotalSize {
+			return result, nil
+		}
+	}
+}
+
+func (p *Plex) getMetadata(itemId string) (*plexResponse, error) {
+	var resp plexResponse
+	if err := p.do(http.MethodGet, fmt.Sprintf("/library/metadata/%s", itemId), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.MediaContainer.Metadata) == 0 {
+		return nil, fmt.Errorf("Plex中不存在媒体 %s", itemId)
+	}
+	return &resp, nil
+}
+
+func (p *Plex) GetItemLibraries(itemId string) ([]Library, error) {
+	resp, err := p.getMetadata(itemId)
+	if err != nil {
+		return nil, err
+	}
+	sectionId := plexSectionId(resp.MediaContainer.LibrarySectionID)
+	if sectionId == "" {
+		sectionId = plexSectionId(resp.MediaContainer.Metadata[0].LibrarySectionID)
+	}
+	if sectionId == "" {
+		return nil, nil
+	}
+	return []Library{{ID: sectionId, Name: resp.MediaContainer.LibrarySectionTitle}}, nil
+}
+
+func (p *Plex) GetItemDetail(itemId string) (*ItemDetail, error) {
+	resp, err := p.getMetadata(itemId)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &resp.MediaContainer.Metadata[0]
+	detail := &ItemDetail{
+		Item:            p.item(metadata),
+		Overview:        metadata.Summary,
+		CommunityRating: metadata.AudienceRating,
+	}
+	if detail.CommunityRating == 0 {
+		detail.CommunityRating = metadata.Rating
+	}
+	for _, genre := range metadata.Genre {
+		detail.Genres = append(detail.Genres, genre.Tag)
+	}
+	for _, role := range metadata.Role {
+		detail.Actors = append(detail.Actors, role.Tag)
+	}
+	detail.PrimaryImageUrl = p.imageUrl(metadata.Thumb)
+	detail.BackdropImageUrl = p.imageUrl(metadata.Art)
+	return detail, nil
+}
+
+func (p *Plex) RefreshLibrary(libraryId string, libraryName string) error {
+	if err := p.do(http.MethodGet, fmt.Sprintf("/library/sections/%s/refresh", libraryId), nil, nil, nil); err != nil {
+		return err
+	}
+	helpers.AppLogger.Infof("已触发Plex媒体库 %s => %s 刷新", libraryId, libraryName)
+	return nil
+}
+
+func (p *Plex) imageUrl(path string) string {
+	if path == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s%s?X-Plex-Token=%s", p.url, path, url.QueryEscape(p.token))
+}
+
+func (p *Plex) item(metadata *plexMetadata) Item {
+	item := Item{
+		Id:             metadata.RatingKey,
+		Name:           metadata.Title,
+		Type:           plexItemType(metadata.Type),
+		IndexNumber:    metadata.Index,
+		ProductionYear: metadata.Year,
+		PremiereDate:   metadata.OriginallyAvailableAt,
+	}
+	switch metadata.Type {
+	case "episode":
+		item.ParentId = metadata.ParentRatingKey
+		item.SeasonId = metadata.ParentRatingKey
+		item.SeasonName = metadata.ParentTitle
+		item.SeriesId = metadata.GrandparentRatingKey
+		item.SeriesName = metadata.GrandparentTitle
+		item.ParentIndexNumber = metadata.ParentIndex
+	case "season":
+		item.ParentId = metadata.ParentRatingKey
+		item.SeriesId = metadata.ParentRatingKey
+		item.SeriesName = metadata.ParentTitle
+		item.IsFolder = true
+	case "show":
+		item.IsFolder = true
+	}
+	if metadata.AddedAt > 0 {
+		item.DateCreatedTime = metadata.AddedAt
+		item.DateCreated = time.Unix(metadata.AddedAt, 0).UTC().Format(time.RFC3339)
+	}
+	if metadata.UpdatedAt > 0 {
+		item.DateModifiedTime = metadata.UpdatedAt
+		item.DateModified = time.Unix(metadata.UpdatedAt, 0).UTC().Format(time.RFC3339)
+	}
+	for _, media := range metadata.Media {
+		for _, part := range media.Part {
+			item.MediaSourcePaths = append(item.MediaSourcePaths, part.File)
+		}
+	}
+	if len(item.MediaSourcePaths) > 0 {
+		item.Path = item.MediaSourcePaths[0]
+	}
+	return item
+}
+
+// Plex的媒体类型转换为统一的类型
+func plexItemType(t string) string {
+	switch t {
+	case "movie":
+		return ItemTypeMovie
+	case "episode":
+		return ItemTypeEpisode
+	case "season":
+		return ItemTypeSeason
+	case "show":
+		return ItemTypeSeries
+	default:
+		return ItemTypeVideo
+	}
+}
+
+// librarySectionID在不同接口中可能是数字也可能是字符串
+func plexSectionId(v any) string {
+	switch id := v.(type) {
+	case string:
+		return id
+	case float64:
+		return fmt.Sprintf("%d", int64(id))
+	default:
+		return ""
+	}
+}
+
+// Plex Webhook消息，Plex不发送删除事件
+type plexWebhook struct {
+	Event   string `json:"event"`
+	Account struct {
+		ID    any    `json:"id"`
+		Title string `json:"title"`
+	} `json:"Account"`
+	Player struct {
+		Title string `json:"title"`
+		UUID  string `json:"uuid"`
+	} `json:"Player"`
+	Metadata plexMetadata `json:"Metadata"`
+}
+
+func (p *Plex) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var w plexWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return nil, err
+	}
+	metadata := &w.Metadata
+	event := &WebhookEvent{
+		Item: WebhookItem{
+			Id:              metadata.RatingKey,
+			Name:            metadata.Title,
+			Type:            plexItemType(metadata.Type),
+			ProductionYear:  metadata.Year,
+			PrimaryImageUrl: p.imageUrl(metadata.Thumb),
+		},
+		UserId:        fmt.Sprintf("%v", w.Account.ID),
+		UserName:      w.Account.Title,
+		DeviceName:    w.Player.Title,
+		Client:        "Plex",
+		PositionTicks: metadata.ViewOffset * 10000,
+		RunTimeTicks:  metadata.Duration * 10000,
+		PlaySessionId: w.Player.UUID,
+	}
+	if metadata.Type == "episode" {
+		event.Item.SeriesId = metadata.GrandparentRatingKey
+		event.Item.SeriesName = metadata.GrandparentTitle
+		event.Item.SeasonNumber = metadata.ParentIndex
+		event.Item.EpisodeNumber = metadata.Index
+	}
+	switch w.Event {
+	case "library.new":
+		event.Event = EventLibraryNew
+	case "media.play", "media.resume":
+		event.Event = EventPlaybackStart
+	case "media.pause":
+		event.Event = EventPlaybackPause
+	case "media.stop":
+		event.Event = EventPlaybackStop
+	}
+	return event, nil
+}
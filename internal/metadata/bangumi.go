@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"resty.dev/v3"
+)
+
+const BANGUMI_API_URL = "https://api.bgm.tv"
+
+// Bangumi条目类型：动画
+const bangumiTypeAnime = 2
+
+// Bangumi番组计划，只查询动画条目，剧场版对应电影，其他对应电视剧
+type BangumiProvider struct {
+	client *resty.Client
+	token  string
+}
+
+func NewBangumiProvider(cfg *Config) *BangumiProvider {
+	return &BangumiProvider{
+		client: newRestyClient(cfg.baseUrl(ProviderBangumi, BANGUMI_API_URL), cfg.ProxyUrl),
+		token:  cfg.BangumiToken,
+	}
+}
+
+func (b *BangumiProvider) Name() string {
+	return ProviderBangumi
+}
+
+func (b *BangumiProvider) newRequest() *resty.Request {
+	req := b.client.R()
+	if b.token != "" {
+		req.SetAuthToken(b.token)
+	}
+	return req
+}
+
+type bangumiSubject struct {
+	Id       int64    `json:"id"`
+	Name     string   `json:"name"`
+	NameCn   string   `json:"name_cn"`
+	Summary  string   `json:"summary"`
+	Date     string   `json:"date"`
+	Platform string   `json:"platform"`
+	MetaTags []string `json:"meta_tags"`
+	Images   struct {
+		Large string `json:"large"`
+	} `json:"images"`
+	Rating struct {
+		Score float64 `json:"score"`
+		Total int64   `json:"total"`
+	} `json:"rating"`
+}
+
+func (s *bangumiSubject) toDetail() *Detail {
+	id := strconv.FormatInt(s.Id, 10)
+	d := &Detail{
+		Provider:      ProviderBangumi,
+		Id:            id,
+		Title:         s.NameCn,
+		OriginalTitle: s.Name,
+		Overview:      s.Summary,
+		ReleaseDate:   s.Date,
+		Year:          parseYear(s.Date),
+		Rating:        s.Rating.Score,
+		VoteCount:     s.Rating.Total,
+		Genres:        s.MetaTags,
+		PosterUrl:     s.Images.Large,
+	}
+	if d.Title == "" {
+		d.Title = s.Name
+	}
+	d.setId(ProviderBangumi, id)
+	return d
+}
+
+// 平台为空时不过滤
+func (s *bangumiSubject) matchKind(kind MediaKind) bool {
+	if s.Platform == "" {
+		return true
+	}
+	isMovie := s.Platform == "剧场版" || s.Platform == "Movie"
+	return isMovie == (kind == KindMovie)
+}
+
+func (b *BangumiProvider) Search(ctx context.Context, kind MediaKind, name string, year int) ([]*Detail, error) {
+	body := map[string]any{
+		"keyword": name,
+		"filter":  map[string]any{"type": []int{bangumiTypeAnime}},
+	}
+	if year > 0 {
+		// 年份允许前后相差一年
+		body["filter"].(map[string]any)["air_date"] = []string{
+			">=" + strconv.Itoa(year-1) + "-01-01",
+			"<" + strconv.Itoa(year+2) + "-01-01",
+		}
+	}
+	result := struct {
+		Data []bangumiSubject `json:"data"`
+	}{}
+	req := b.newRequest().SetBody(body).SetQueryParam("limit", "10")
+	if err := doRequest(ctx, req, http.MethodPost, "/v0/search/subjects", &result); err != nil {
+		return nil, err
+	}
+	details := make([]*Detail, 0, len(result.Data))
+	for i := range result.Data {
+		if result.Data[i].matchKind(kind) {
+			details = append(details, result.Data[i].toDetail())
+		}
+	}
+	return details, nil
+}
+
+func (b *BangumiProvider) Get(ctx context.Context, kind MediaKind, id string) (*Detail, error) {
+	subject := bangumiSubject{}
+	if err := doRequest(ctx, b.newRequest(), http.MethodGet, "/v0/subjects/"+id, &subject); err != nil {
+		return nil, err
+	}
+	return subject.toDetail(), nil
+}
@@ -0,0 +1,42 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"resty.dev/v3"
+)
+
+const (
+	DEFAULT_TIMEOUT    = 30 // 秒
+	DEFAULT_USER_AGENT = "Q115-STRM/1.0 (https://github.com/Ckid-Home/QMediaSync)"
+)
+
+func newRestyClient(baseUrl, proxyUrl string) *resty.Client {
+	client := resty.New()
+	client.SetTimeout(DEFAULT_TIMEOUT * time.Second)
+	client.SetHeader("Accept", "application/json")
+	client.SetHeader("User-Agent", DEFAULT_USER_AGENT)
+	client.SetBaseURL(baseUrl)
+	if proxyUrl != "" {
+		client.SetProxy(proxyUrl)
+	}
+	return client
+}
+
+// 发送请求并检查HTTP状态码，result为解析响应的结构
+func doRequest(ctx context.Context, req *resty.Request, method, url string, result any) error {
+	req.SetContext(ctx).SetResult(result)
+	resp, err := req.Execute(method, url)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() == 404 {
+		return ErrNotFound
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode(), resp.String())
+	}
+	return nil
+}
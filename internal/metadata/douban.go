@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"resty.dev/v3"
+)
+
+const DOUBAN_API_URL = "https://api.douban.com/api/v2"
+
+// 豆瓣移动端接口，需要apikey，豆瓣不返回IMDB和TMDB的ID，只能通过标题和年份对应
+type DoubanProvider struct {
+	client *resty.Client
+	apiKey string
+}
+
+func NewDoubanProvider(cfg *Config) *DoubanProvider {
+	return &DoubanProvider{
+		client: newRestyClient(cfg.baseUrl(ProviderDouban, DOUBAN_API_URL), cfg.ProxyUrl),
+		apiKey: cfg.DoubanApiKey,
+	}
+}
+
+func (d *DoubanProvider) Name() string {
+	return ProviderDouban
+}
+
+func (d *DoubanProvider) request(ctx context.Context, url string, query map[string]string, result any) error {
+	if d.apiKey == "" {
+		return errors.New("没有配置豆瓣API KEY")
+	}
+	req := d.client.R().SetQueryParams(query).SetQueryParam("apikey", d.apiKey)
+	return doRequest(ctx, req, http.MethodGet, url, result)
+}
+
+type doubanRating struct {
+	Value float64 `json:"value"`
+	Count int64   `json:"count"`
+}
+
+type doubanSubject struct {
+	Id            string       `json:"id"`
+	Type          string       `json:"type"` // movie或者tv
+	Title         string       `json:"title"`
+	OriginalTitle string       `json:"original_title"`
+	Year          string       `json:"year"`
+	Intro         string       `json:"intro"`
+	Genres        []string     `json:"genres"`
+	Pubdate       []string     `json:"pubdate"`
+	Rating        doubanRating `json:"rating"`
+	CoverUrl      string       `json:"cover_url"`
+	Pic           struct {
+		Large string `json:"large"`
+	} `json:"pic"`
+}
+
+func doubanType(kind MediaKind) string {
+	if kind == KindMovie {
+		return "movie"
+	}
+	return "tv"
+}
+
+func (s *doubanSubject) toDetail() *Detail {
+	d := &Detail{
+		Provider:      ProviderDouban,
+		Id:            s.Id,
+		Title:         s.Title,
+		OriginalTitle: s.OriginalTitle,
+		Overview:      s.Intro,
+		Rating:        s.Rating.Value,
+		VoteCount:     s.Rating.Count,
+		Genres:        s.Genres,
+		PosterUrl:     s.Pic.Large,
+	}
+	if d.PosterUrl == "" {
+		d.PosterUrl = s.CoverUrl
+	}
+	if len(s.Pubdate) > 0 {
+		d.ReleaseDate = s.Pubdate[0]
+	}
+	d.Year, _ = strconv.Atoi(s.Year)
+	if d.Year == 0 {
+		d.Year = parseYear(d.ReleaseDate)
+	}
+	d.setId(ProviderDouban, s.Id)
+	return d
+}
+
+func (d *DoubanProvider) Search(ctx context.Context, kind MediaKind, name string, year int) ([]*Detail, error) {
+	result := struct {
+		Items []struct {
+			TargetType string        `json:"target_type"`
+			Target     doubanSubject `json:"target"`
+		} `json:"items"`
+	}{}
+	if err := d.request(ctx, "/search/movie", map[string]string{"q": name, "count": "20"}, &result); err != nil {
+		return nil, err
+	}
+	details := make([]*Detail, 0, len(result.Items))
+	for _, item := range result.Items {
+		// 电影和剧集在同一个搜索接口中返回
+		if item.TargetType != doubanType(kind) {
+			continue
+		}
+		details = append(details, item.Target.toDetail())
+	}
+	return details, nil
+}
+
+func (d *DoubanProvider) Get(ctx context.Context, kind MediaKind, id string) (*Detail, error) {
+	subject := doubanSubject{}
+	if err := d.request(ctx, "/"+doubanType(kind)+"/"+id, nil, &subject); err != nil {
+		return nil, err
+	}
+	return subject.toDetail(), nil
+}
@@ -0,0 +1,159 @@
+package metadata
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 在提供者中查找条目的条件
+type Query struct {
+	Titles []string          // 候选标题，按顺序搜索
+	Year   int               // 年份，0表示不限制
+	Ids    map[string]string // 已知的ID，任一ID相同即认为匹配
+}
+
+func normalizeTitle(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "", "·", "", ":", "", "：", "", "-", "", "_", "").Replace(s)
+}
+
+func sameTitle(a, b string) bool {
+	return a != "" && b != "" && normalizeTitle(a) == normalizeTitle(b)
+}
+
+// 年份允许相差一年，不同站点的上映日期经常不一致
+func sameYear(a, b int) bool {
+	if a == 0 || b == 0 {
+		return true
+	}
+	return a-b <= 1 && b-a <= 1
+}
+
+func shareId(a, b map[string]string) bool {
+	for k, v := range a {
+		if v != "" && b[k] == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 在提供者中搜索与查询对应的条目并返回详情
+// 优先使用ID匹配，其次是标题和年份都相同的第一条结果
+func Match(ctx context.Context, p Provider, kind MediaKind, q Query) (*Detail, error) {
+	var candidate *Detail
+	searched := make([]string, 0, len(q.Titles))
+	for _, title := range q.Titles {
+		if title == "" || slices.Contains(searched, title) {
+			continue
+		}
+		searched = append(searched, title)
+		results, err := p.Search(ctx, kind, title, q.Year)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if shareId(r.Ids, q.Ids) {
+				return getDetail(ctx, p, kind, r)
+			}
+			if candidate != nil || !sameYear(r.Year, q.Year) {
+				continue
+			}
+			for _, t := range q.Titles {
+				if sameTitle(r.Title, t) || sameTitle(r.OriginalTitle, t) {
+					candidate = r
+					break
+				}
+			}
+		}
+		if candidate != nil {
+			break
+		}
+	}
+	if candidate == nil {
+		return nil, ErrNotFound
+	}
+	return getDetail(ctx, p, kind, candidate)
+}
+
+// 查询详情，并合并搜索结果中的外部ID（部分接口只在搜索结果中返回）
+func getDetail(ctx context.Context, p Provider, kind MediaKind, r *Detail) (*Detail, error) {
+	detail, err := p.Get(ctx, kind, r.Id)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Ids {
+		if _, ok := detail.Ids[k]; !ok {
+			detail.setId(k, v)
+		}
+	}
+	return detail, nil
+}
+
+// Merge 按顺序合并多个提供者的详情，前面的优先，后面的只补充空字段，ID取并集
+func Merge(details ...*Detail) *Detail {
+	merged := &Detail{Ids: make(map[string]string)}
+	for _, d := range details {
+		if d == nil {
+			continue
+		}
+		if merged.Provider == "" {
+			merged.Provider = d.Provider
+			merged.Id = d.Id
+		}
+		for k, v := range d.Ids {
+			if _, ok := merged.Ids[k]; !ok {
+				merged.setId(k, v)
+			}
+		}
+		if merged.Title == "" {
+			merged.Title = d.Title
+		}
+		if merged.OriginalTitle == "" {
+			merged.OriginalTitle = d.OriginalTitle
+		}
+		if merged.Overview == "" {
+			merged.Overview = strings.TrimSpace(d.Overview)
+		}
+		if merged.Year == 0 {
+			merged.Year = d.Year
+		}
+		if merged.ReleaseDate == "" {
+			merged.ReleaseDate = d.ReleaseDate
+		}
+		if merged.Rating == 0 {
+			merged.Rating = d.Rating
+			merged.VoteCount = d.VoteCount
+		}
+		if len(merged.Genres) == 0 {
+			merged.Genres = d.Genres
+		}
+		if merged.PosterUrl == "" {
+			merged.PosterUrl = d.PosterUrl
+		}
+		if merged.BackdropUrl == "" {
+			merged.BackdropUrl = d.BackdropUrl
+		}
+	}
+	return merged
+}
+
+// TmdbId 从ID中取出TMDB ID，没有时返回0
+func (d *Detail) TmdbId() int64 {
+	id, _ := strconv.ParseInt(d.Ids[ProviderTmdb], 10, 64)
+	return id
+}
+
+// 从日期字符串开头解析年份，例如 2008-01-20 或 1993-07-26(中国大陆)
+func parseYear(s string) int {
+	if len(s) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
@@ -0,0 +1,170 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"resty.dev/v3"
+)
+
+const (
+	ProviderMusicBrainz  = "musicbrainz"
+	MUSICBRAINZ_API_URL  = "https://musicbrainz.org"
+	musicBrainzMinScore  = 90
+	musicBrainzRateLimit = time.Second // 匿名访问每秒最多1个请求
+)
+
+// 音乐发行（专辑）
+type MusicRelease struct {
+	Id         string `json:"id"`          // MusicBrainz Release ID
+	Title      string `json:"title"`       // 专辑名称
+	Artist     string `json:"artist"`      // 专辑艺术家
+	Year       int    `json:"year"`        // 发行年份
+	TrackCount int    `json:"track_count"` // 曲目数量
+}
+
+// 音乐录音（单曲）
+type MusicRecording struct {
+	Id     string `json:"id"`     // MusicBrainz Recording ID
+	Title  string `json:"title"`  // 曲目名称
+	Artist string `json:"artist"` // 艺术家
+}
+
+// 音乐元数据提供者，刮削时可以替换成测试用的实现
+type MusicProvider interface {
+	SearchRelease(ctx context.Context, artist, album string) (*MusicRelease, error)
+	SearchRecording(ctx context.Context, artist, title, releaseId string) (*MusicRecording, error)
+}
+
+// MusicBrainz开放数据库，不需要API KEY，但必须限制请求频率并带上User-Agent
+type MusicBrainzProvider struct {
+	client   *resty.Client
+	interval time.Duration
+	mu       sync.Mutex
+	last     time.Time
+}
+
+func NewMusicBrainzProvider(cfg *Config) *MusicBrainzProvider {
+	return &MusicBrainzProvider{
+		client:   newRestyClient(cfg.baseUrl(ProviderMusicBrainz, MUSICBRAINZ_API_URL), cfg.ProxyUrl),
+		interval: musicBrainzRateLimit,
+	}
+}
+
+// 等待到下一个可以发送请求的时间
+func (m *MusicBrainzProvider) wait(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d := m.interval - time.Since(m.last); d > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+	m.last = time.Now()
+	return nil
+}
+
+type musicBrainzArtistCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+}
+
+func joinArtistCredit(credits []musicBrainzArtistCredit) string {
+	var sb strings.Builder
+	for _, c := range credits {
+		sb.WriteString(c.Name)
+		sb.WriteString(c.JoinPhrase)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Lucene查询语法中的短语需要转义引号和反斜杠
+func musicBrainzPhrase(field, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return fmt.Sprintf(`%s:"%s"`, field, value)
+}
+
+func (m *MusicBrainzProvider) search(ctx context.Context, entity string, query []string, result any) error {
+	if err := m.wait(ctx); err != nil {
+		return err
+	}
+	req := m.client.R().SetQueryParams(map[string]string{
+		"query": strings.Join(query, " AND "),
+		"fmt":   "json",
+		"limit": "5",
+	})
+	return doRequest(ctx, req, http.MethodGet, "/ws/2/"+entity, result)
+}
+
+// SearchRelease 按艺术家和专辑名称搜索发行，只返回匹配度足够高的第一个结果
+func (m *MusicBrainzProvider) SearchRelease(ctx context.Context, artist, album string) (*MusicRelease, error) {
+	query := []string{musicBrainzPhrase("release", album)}
+	if artist != "" {
+		query = append(query, musicBrainzPhrase("artist", artist))
+	}
+	result := struct {
+		Releases []struct {
+			Id           string                    `json:"id"`
+			Score        int                       `json:"score"`
+			Title        string                    `json:"title"`
+			Date         string                    `json:"date"`
+			TrackCount   int                       `json:"track-count"`
+			ArtistCredit []musicBrainzArtistCredit `json:"artist-credit"`
+		} `json:"releases"`
+	}{}
+	if err := m.search(ctx, "release", query, &result); err != nil {
+		return nil, err
+	}
+	for _, r := range result.Releases {
+		if r.Score < musicBrainzMinScore {
+			continue
+		}
+		return &MusicRelease{
+			Id:         r.Id,
+			Title:      r.Title,
+			Artist:     joinArtistCredit(r.ArtistCredit),
+			Year:       parseYear(r.Date),
+			TrackCount: r.TrackCount,
+		}, nil
+	}
+	return nil, ErrNotFound
+}
+
+// SearchRecording 按艺术家和曲目名称搜索录音，releaseId不为空时限定在该发行内
+func (m *MusicBrainzProvider) SearchRecording(ctx context.Context, artist, title, releaseId string) (*MusicRecording, error) {
+	query := []string{musicBrainzPhrase("recording", title)}
+	if artist != "" {
+		query = append(query, musicBrainzPhrase("artist", artist))
+	}
+	if releaseId != "" {
+		query = append(query, "reid:"+releaseId)
+	}
+	result := struct {
+		Recordings []struct {
+			Id           string                    `json:"id"`
+			Score        int                       `json:"score"`
+			Title        string                    `json:"title"`
+			ArtistCredit []musicBrainzArtistCredit `json:"artist-credit"`
+		} `json:"recordings"`
+	}{}
+	if err := m.search(ctx, "recording", query, &result); err != nil {
+		return nil, err
+	}
+	for _, r := range result.Recordings {
+		if r.Score < musicBrainzMinScore {
+			continue
+		}
+		return &MusicRecording{
+			Id:     r.Id,
+			Title:  r.Title,
+			Artist: joinArtistCredit(r.ArtistCredit),
+		}, nil
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,136 @@
+package metadata
+
+import (
+	"Q115-STRM/internal/tmdb"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 元数据提供者名称，也是NFO中uniqueid的type
+const (
+	ProviderTmdb    = "tmdb"
+	ProviderTvdb    = "tvdb"
+	ProviderDouban  = "douban"
+	ProviderBangumi = "bangumi"
+	// IMDB不提供接口，只作为其他提供者返回的外部ID
+	ProviderImdb = "imdb"
+)
+
+type MediaKind string
+
+const (
+	KindMovie  MediaKind = "movie"
+	KindTvShow MediaKind = "tvshow"
+)
+
+var ErrNotFound = errors.New("没有找到匹配的条目")
+
+// 各提供者统一后的条目信息，搜索结果和详情都使用这个结构，搜索结果只填充部分字段
+type Detail struct {
+	Provider      string            `json:"provider"`       // 提供者名称
+	Id            string            `json:"id"`             // 在该提供者中的ID
+	Ids           map[string]string `json:"ids"`            // 所有已知的ID，key为提供者名称，包含自身
+	Title         string            `json:"title"`          // 标题（配置的语言）
+	OriginalTitle string            `json:"original_title"` // 原始标题
+	Overview      string            `json:"overview"`       // 简介
+	Year          int               `json:"year"`           // 年份
+	ReleaseDate   string            `json:"release_date"`   // 上映或首播日期
+	Rating        float64           `json:"rating"`         // 评分，10分制
+	VoteCount     int64             `json:"vote_count"`     // 评分人数
+	Genres        []string          `json:"genres"`         // 流派名称
+	PosterUrl     string            `json:"poster_url"`     // 海报地址
+	BackdropUrl   string            `json:"backdrop_url"`   // 背景图地址
+}
+
+func (d *Detail) setId(provider, id string) {
+	if id == "" || id == "0" {
+		return
+	}
+	if d.Ids == nil {
+		d.Ids = make(map[string]string)
+	}
+	d.Ids[provider] = id
+}
+
+// 元数据提供者
+type Provider interface {
+	Name() string
+	// 按名称和年份搜索，year为0时不限制年份
+	Search(ctx context.Context, kind MediaKind, name string, year int) ([]*Detail, error)
+	// 按提供者自己的ID查询详情
+	Get(ctx context.Context, kind MediaKind, id string) (*Detail, error)
+}
+
+// 创建提供者需要的配置
+type Config struct {
+	Language     string            // 语言，TMDB格式，例如zh-CN
+	ProxyUrl     string            // 代理地址
+	TmdbClient   *tmdb.Client      // TMDB客户端
+	TmdbImageUrl string            // TMDB图片地址
+	TvdbApiKey   string            // TheTVDB v4 API KEY
+	TvdbPin      string            // TheTVDB订阅PIN，可以为空
+	DoubanApiKey string            // 豆瓣API KEY
+	BangumiToken string            // Bangumi Access Token，可以为空
+	BaseUrls     map[string]string // 覆盖提供者的接口地址，key为提供者名称
+}
+
+func (c *Config) baseUrl(provider, defaultUrl string) string {
+	if u, ok := c.BaseUrls[provider]; ok && u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return defaultUrl
+}
+
+type Factory func(cfg *Config) Provider
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register 注册提供者，重复注册会覆盖
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[name] = factory
+}
+
+// New 按名称创建提供者
+func New(name string, cfg *Config) (Provider, error) {
+	registryMutex.RLock()
+	factory, ok := registry[name]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的元数据提供者: %s", name)
+	}
+	return factory(cfg), nil
+}
+
+// Names 所有已注册的提供者名称
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsRegistered 提供者是否已注册
+func IsRegistered(name string) bool {
+	return slices.Contains(Names(), name)
+}
+
+func init() {
+	Register(ProviderTmdb, func(cfg *Config) Provider { return NewTmdbProvider(cfg) })
+	Register(ProviderTvdb, func(cfg *Config) Provider { return NewTvdbProvider(cfg) })
+	Register(ProviderDouban, func(cfg *Config) Provider { return NewDoubanProvider(cfg) })
+	Register(ProviderBangumi, func(cfg *Config) Provider { return NewBangumiProvider(cfg) })
+}
@@ -0,0 +1,192 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// 用录制的接口响应启动测试服务器，key为 "方法 路径"，value为testdata中的文件名
+func newFixtureServer(t *testing.T, routes map[string]string, check func(r *http.Request) bool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil && !check(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("读取测试数据失败: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{ProviderTmdb, ProviderTvdb, ProviderDouban, ProviderBangumi} {
+		p, err := New(name, &Config{})
+		if err != nil || p.Name() != name {
+			t.Errorf("创建提供者 %s 失败: %v", name, err)
+		}
+	}
+	if _, err := New("unknown", &Config{}); err == nil {
+		t.Errorf("未注册的提供者应该返回错误")
+	}
+}
+
+func TestTvdbProvider(t *testing.T) {
+	var logins atomic.Int32
+	srv := newFixtureServer(t, map[string]string{
+		"POST /login":                "tvdb_login.json",
+		"GET /search":                "tvdb_search_series.json",
+		"GET /series/81189/extended": "tvdb_series_extended.json",
+	}, func(r *http.Request) bool {
+		if r.URL.Path == "/login" {
+			logins.Add(1)
+			return true
+		}
+		return r.Header.Get("Authorization") == "Bearer eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.test"
+	})
+	p, _ := New(ProviderTvdb, &Config{Language: "zh-CN", TvdbApiKey: "key", BaseUrls: map[string]string{ProviderTvdb: srv.URL}})
+	detail, err := Match(context.Background(), p, KindTvShow, Query{Titles: []string{"绝命毒师", "Breaking Bad"}, Year: 2008})
+	if err != nil {
+		t.Fatalf("匹配失败: %v", err)
+	}
+	if detail.Title != "绝命毒师" || detail.OriginalTitle != "Breaking Bad" || detail.Year != 2008 {
+		t.Errorf("标题或年份错误: %+v", detail)
+	}
+	if detail.Overview == "" || len(detail.Genres) != 2 {
+		t.Errorf("简介或流派错误: %+v", detail)
+	}
+	if detail.Ids[ProviderTvdb] != "81189" || detail.Ids[ProviderImdb] != "tt0903747" || detail.TmdbId() != 1396 {
+		t.Errorf("外部ID错误: %+v", detail.Ids)
+	}
+	if logins.Load() != 1 {
+		t.Errorf("token应该只获取一次，实际 %d 次", logins.Load())
+	}
+
+	// 没有配置API KEY
+	p, _ = New(ProviderTvdb, &Config{BaseUrls: map[string]string{ProviderTvdb: srv.URL}})
+	if _, err := p.Search(context.Background(), KindTvShow, "Breaking Bad", 0); err == nil {
+		t.Errorf("没有API KEY时应该返回错误")
+	}
+}
+
+func TestDoubanProvider(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{
+		"GET /search/movie":  "douban_search.json",
+		"GET /movie/1291546": "douban_movie.json",
+	}, func(r *http.Request) bool {
+		return r.URL.Query().Get("apikey") == "key"
+	})
+	p, _ := New(ProviderDouban, &Config{DoubanApiKey: "key", BaseUrls: map[string]string{ProviderDouban: srv.URL}})
+	results, err := p.Search(context.Background(), KindMovie, "霸王别姬", 1993)
+	if err != nil {
+		t.Fatalf("搜索失败: %v", err)
+	}
+	// 剧集结果需要过滤掉
+	if len(results) != 2 {
+		t.Fatalf("搜索结果数量错误: %d", len(results))
+	}
+	detail, err := Match(context.Background(), p, KindMovie, Query{Titles: []string{"霸王别姬"}, Year: 1993})
+	if err != nil {
+		t.Fatalf("匹配失败: %v", err)
+	}
+	if detail.Id != "1291546" || detail.OriginalTitle != "霸王別姬" || detail.Rating != 9.6 || detail.ReleaseDate != "1993-07-26(中国大陆)" {
+		t.Errorf("详情错误: %+v", detail)
+	}
+	if detail.Ids[ProviderDouban] != "1291546" || detail.TmdbId() != 0 {
+		t.Errorf("外部ID错误: %+v", detail.Ids)
+	}
+	// 年份对不上时不匹配
+	if _, err := Match(context.Background(), p, KindMovie, Query{Titles: []string{"霸王别姬"}, Year: 2010}); err != ErrNotFound {
+		t.Errorf("年份不匹配时应该返回ErrNotFound: %v", err)
+	}
+}
+
+func TestBangumiProvider(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{
+		"POST /v0/search/subjects": "bangumi_search.json",
+		"GET /v0/subjects/55770":   "bangumi_subject.json",
+	}, func(r *http.Request) bool {
+		return r.Header.Get("User-Agent") == DEFAULT_USER_AGENT
+	})
+	p, _ := New(ProviderBangumi, &Config{BaseUrls: map[string]string{ProviderBangumi: srv.URL}})
+	// 剧场版不应该匹配到电视剧
+	detail, err := Match(context.Background(), p, KindTvShow, Query{Titles: []string{"進撃の巨人"}, Year: 2013})
+	if err != nil {
+		t.Fatalf("匹配失败: %v", err)
+	}
+	if detail.Id != "55770" || detail.Title != "进击的巨人" || detail.OriginalTitle != "進撃の巨人" || detail.Year != 2013 {
+		t.Errorf("详情错误: %+v", detail)
+	}
+	if detail.Rating != 8.2 || len(detail.Genres) != 4 || detail.PosterUrl == "" {
+		t.Errorf("评分、流派或海报错误: %+v", detail)
+	}
+	results, err := p.Search(context.Background(), KindMovie, "進撃の巨人", 0)
+	if err != nil || len(results) != 1 || results[0].Id != "88412" {
+		t.Errorf("电影只应该返回剧场版: %+v %v", results, err)
+	}
+	if _, err := p.Get(context.Background(), KindTvShow, "1"); err != ErrNotFound {
+		t.Errorf("不存在的条目应该返回ErrNotFound: %v", err)
+	}
+}
+
+func TestMusicBrainzProvider(t *testing.T) {
+	var queries []string
+	srv := newFixtureServer(t, map[string]string{
+		"GET /ws/2/release":   "musicbrainz_release.json",
+		"GET /ws/2/recording": "musicbrainz_recording.json",
+	}, func(r *http.Request) bool {
+		queries = append(queries, r.URL.Query().Get("query"))
+		return r.Header.Get("User-Agent") == DEFAULT_USER_AGENT && r.URL.Query().Get("fmt") == "json"
+	})
+	p := NewMusicBrainzProvider(&Config{BaseUrls: map[string]string{ProviderMusicBrainz: srv.URL}})
+	p.interval = 0
+	release, err := p.SearchRelease(context.Background(), "Coldplay", "Parachutes")
+	if err != nil {
+		t.Fatalf("搜索专辑失败: %v", err)
+	}
+	if release.Id != "3e8d4a29-8f2b-4d5a-9d1e-6b0b8c0f7a11" || release.Artist != "Coldplay" || release.Year != 2000 || release.TrackCount != 10 {
+		t.Errorf("专辑信息错误: %+v", release)
+	}
+	recording, err := p.SearchRecording(context.Background(), "Coldplay", `Yellow "Live"`, release.Id)
+	if err != nil {
+		t.Fatalf("搜索曲目失败: %v", err)
+	}
+	if recording.Id != "e3f3c2d4-55c0-4b3a-b2d2-1e5a1f9e8b77" || recording.Artist != "Coldplay feat. Guest" {
+		t.Errorf("曲目信息错误: %+v", recording)
+	}
+	expected := `recording:"Yellow \"Live\"" AND artist:"Coldplay" AND reid:` + release.Id
+	if len(queries) != 2 || queries[1] != expected {
+		t.Errorf("查询语句错误: %v", queries)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	primary := &Detail{Provider: ProviderBangumi, Id: "55770", Ids: map[string]string{ProviderBangumi: "55770"}, Title: "进击的巨人", Rating: 8.2}
+	fallback := &Detail{Provider: ProviderTvdb, Id: "267440", Ids: map[string]string{ProviderTvdb: "267440", ProviderTmdb: "1429"}, Title: "Attack on Titan", Overview: "简介", Rating: 8.6, Genres: []string{"Anime"}}
+	merged := Merge(primary, nil, fallback)
+	if merged.Provider != ProviderBangumi || merged.Title != "进击的巨人" || merged.Rating != 8.2 {
+		t.Errorf("主提供者的字段应该优先: %+v", merged)
+	}
+	if merged.Overview != "简介" || len(merged.Genres) != 1 {
+		t.Errorf("空字段应该由后备提供者补充: %+v", merged)
+	}
+	if len(merged.Ids) != 3 || merged.TmdbId() != 1429 {
+		t.Errorf("ID应该取并集: %+v", merged.Ids)
+	}
+}
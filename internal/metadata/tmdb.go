@@ -0,0 +1,137 @@
+package metadata
+
+import (
+	"Q115-STRM/internal/tmdb"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// TMDB提供者，使用现有的tmdb客户端
+type TmdbProvider struct {
+	client   *tmdb.Client
+	language string
+	imageUrl string
+}
+
+func NewTmdbProvider(cfg *Config) *TmdbProvider {
+	return &TmdbProvider{
+		client:   cfg.TmdbClient,
+		language: cfg.Language,
+		imageUrl: cfg.TmdbImageUrl,
+	}
+}
+
+func (t *TmdbProvider) Name() string {
+	return ProviderTmdb
+}
+
+func (t *TmdbProvider) image(path string) string {
+	if path == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/t/p/original%s", t.imageUrl, path)
+}
+
+func (t *TmdbProvider) Search(ctx context.Context, kind MediaKind, name string, year int) ([]*Detail, error) {
+	if t.client == nil {
+		return nil, errors.New("没有配置TMDB客户端")
+	}
+	results := make([]*Detail, 0)
+	if kind == KindMovie {
+		resp, err := t.client.SearchMovie(name, year, t.language, true, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range resp.Results {
+			d := &Detail{Provider: ProviderTmdb, Id: strconv.FormatInt(r.ID, 10), Title: r.Title, OriginalTitle: r.OriginalTitle, Year: parseYear(r.ReleaseDate)}
+			d.setId(ProviderTmdb, d.Id)
+			results = append(results, d)
+		}
+		return results, nil
+	}
+	resp, err := t.client.SearchTv(name, year, t.language, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resp.Results {
+		d := &Detail{Provider: ProviderTmdb, Id: strconv.FormatInt(r.ID, 10), Title: r.Name, OriginalTitle: r.OriginalName, Year: parseYear(r.FirstAirDate)}
+		d.setId(ProviderTmdb, d.Id)
+		results = append(results, d)
+	}
+	return results, nil
+}
+
+func (t *TmdbProvider) Get(ctx context.Context, kind MediaKind, id string) (*Detail, error) {
+	if t.client == nil {
+		return nil, errors.New("没有配置TMDB客户端")
+	}
+	tmdbId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的TMDB ID: %s", id)
+	}
+	d := &Detail{Provider: ProviderTmdb, Id: id}
+	d.setId(ProviderTmdb, id)
+	var genres []tmdb.Genre
+	if kind == KindMovie {
+		movie, err := t.client.GetMovieDetail(tmdbId, t.language)
+		if err != nil {
+			return nil, err
+		}
+		d.Title, d.OriginalTitle, d.Overview = movie.Title, movie.OriginalTitle, movie.Overview
+		d.ReleaseDate, d.Rating, d.VoteCount = movie.ReleaseDate, movie.VoteAverage, movie.VoteCount
+		d.PosterUrl, d.BackdropUrl = t.image(movie.PosterPath), t.image(movie.BackdropPath)
+		d.setId(ProviderImdb, movie.ImdbID)
+		genres = movie.Genres
+	} else {
+		tv, err := t.client.GetTvDetail(tmdbId, t.language)
+		if err != nil {
+			return nil, err
+		}
+		d.Title, d.OriginalTitle, d.Overview = tv.Name, tv.OriginalName, tv.Overview
+		d.ReleaseDate, d.Rating, d.VoteCount = tv.FirstAirDate, tv.VoteAverage, tv.VoteCount
+		d.PosterUrl, d.BackdropUrl = t.image(tv.PosterPath), t.image(tv.BackdropPath)
+		genres = tv.Genres
+		if externalIds, err := t.client.GetTvExternalIds(tmdbId); err == nil {
+			d.setId(ProviderImdb, externalIds.ImdbID)
+			d.setId(ProviderTvdb, strconv.FormatInt(externalIds.TvdbID, 10))
+		}
+	}
+	d.Year = parseYear(d.ReleaseDate)
+	for _, g := range genres {
+		d.Genres = append(d.Genres, g.Name)
+	}
+	return d, nil
+}
+
+// FindTmdbId 用详情中的外部ID在TMDB中查找对应的TMDB ID，找不到时返回0
+func FindTmdbId(client *tmdb.Client, kind MediaKind, d *Detail, language string) int64 {
+	if id := d.TmdbId(); id > 0 {
+		return id
+	}
+	if client == nil {
+		return 0
+	}
+	sources := []struct{ provider, source string }{
+		{ProviderImdb, tmdb.ExternalSourceImdb},
+		{ProviderTvdb, tmdb.ExternalSourceTvdb},
+	}
+	for _, s := range sources {
+		externalId := d.Ids[s.provider]
+		if externalId == "" {
+			continue
+		}
+		resp, err := client.FindByExternalId(externalId, s.source, language)
+		if err != nil {
+			continue
+		}
+		if kind == KindMovie && len(resp.MovieResults) > 0 {
+			return resp.MovieResults[0].ID
+		}
+		if kind == KindTvShow && len(resp.TvResults) > 0 {
+			return resp.TvResults[0].ID
+		}
+	}
+	return 0
+}
@@ -0,0 +1,219 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"resty.dev/v3"
+)
+
+const TVDB_API_URL = "https://api4.thetvdb.com/v4"
+
+// TheTVDB v4接口，先用API KEY登录换取token，token有效期一个月
+type TvdbProvider struct {
+	client   *resty.Client
+	apiKey   string
+	pin      string
+	language string
+	token    string
+	mutex    sync.Mutex
+}
+
+func NewTvdbProvider(cfg *Config) *TvdbProvider {
+	return &TvdbProvider{
+		client:   newRestyClient(cfg.baseUrl(ProviderTvdb, TVDB_API_URL), cfg.ProxyUrl),
+		apiKey:   cfg.TvdbApiKey,
+		pin:      cfg.TvdbPin,
+		language: tvdbLanguage(cfg.Language),
+	}
+}
+
+// TMDB的语言代码转换成TVDB使用的ISO 639-2代码
+func tvdbLanguage(language string) string {
+	switch strings.ToLower(strings.Split(language, "-")[0]) {
+	case "zh":
+		return "zho"
+	case "ja":
+		return "jpn"
+	case "ko":
+		return "kor"
+	default:
+		return "eng"
+	}
+}
+
+func (t *TvdbProvider) Name() string {
+	return ProviderTvdb
+}
+
+type tvdbResponse[T any] struct {
+	Status string `json:"status"`
+	Data   T      `json:"data"`
+}
+
+func (t *TvdbProvider) login(ctx context.Context) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.token != "" {
+		return t.token, nil
+	}
+	if t.apiKey == "" {
+		return "", errors.New("没有配置TheTVDB API KEY")
+	}
+	body := map[string]string{"apikey": t.apiKey}
+	if t.pin != "" {
+		body["pin"] = t.pin
+	}
+	result := tvdbResponse[struct {
+		Token string `json:"token"`
+	}]{}
+	if err := doRequest(ctx, t.client.R().SetBody(body), http.MethodPost, "/login", &result); err != nil {
+		return "", err
+	}
+	if result.Data.Token == "" {
+		return "", errors.New("TheTVDB登录失败")
+	}
+	t.token = result.Data.Token
+	return t.token, nil
+}
+
+func (t *TvdbProvider) request(ctx context.Context, url string, query map[string]string, result any) error {
+	token, err := t.login(ctx)
+	if err != nil {
+		return err
+	}
+	return doRequest(ctx, t.client.R().SetAuthToken(token).SetQueryParams(query), http.MethodGet, url, result)
+}
+
+type tvdbRemoteId struct {
+	Id         string `json:"id"`
+	SourceName string `json:"sourceName"`
+}
+
+// 把TVDB的外部ID转换成提供者名称
+func (d *Detail) setTvdbRemoteIds(remoteIds []tvdbRemoteId) {
+	for _, r := range remoteIds {
+		switch {
+		case strings.EqualFold(r.SourceName, "IMDB"):
+			d.setId(ProviderImdb, r.Id)
+		case strings.HasPrefix(r.SourceName, "TheMovieDB"):
+			d.setId(ProviderTmdb, r.Id)
+		}
+	}
+}
+
+type tvdbSearchResult struct {
+	TvdbId          string            `json:"tvdb_id"`
+	Name            string            `json:"name"`
+	Year            string            `json:"year"`
+	Overview        string            `json:"overview"`
+	ImageUrl        string            `json:"image_url"`
+	Translations    map[string]string `json:"translations"`
+	Overviews       map[string]string `json:"overviews"`
+	RemoteIds       []tvdbRemoteId    `json:"remote_ids"`
+	PrimaryLanguage string            `json:"primary_language"`
+}
+
+func (t *TvdbProvider) Search(ctx context.Context, kind MediaKind, name string, year int) ([]*Detail, error) {
+	query := map[string]string{"query": name, "type": "series"}
+	if kind == KindMovie {
+		query["type"] = "movie"
+	}
+	if year > 0 {
+		query["year"] = strconv.Itoa(year)
+	}
+	result := tvdbResponse[[]tvdbSearchResult]{}
+	if err := t.request(ctx, "/search", query, &result); err != nil {
+		return nil, err
+	}
+	details := make([]*Detail, 0, len(result.Data))
+	for _, r := range result.Data {
+		d := &Detail{
+			Provider:      ProviderTvdb,
+			Id:            r.TvdbId,
+			Title:         r.Name,
+			OriginalTitle: r.Name,
+			Overview:      r.Overview,
+			PosterUrl:     r.ImageUrl,
+		}
+		d.Year, _ = strconv.Atoi(r.Year)
+		if title, ok := r.Translations[t.language]; ok && title != "" {
+			d.Title = title
+		}
+		if overview, ok := r.Overviews[t.language]; ok && overview != "" {
+			d.Overview = overview
+		}
+		d.setId(ProviderTvdb, r.TvdbId)
+		d.setTvdbRemoteIds(r.RemoteIds)
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+type tvdbTranslation struct {
+	Language string `json:"language"`
+	Name     string `json:"name"`
+	Overview string `json:"overview"`
+}
+
+type tvdbExtended struct {
+	Id         int64          `json:"id"`
+	Name       string         `json:"name"`
+	Year       string         `json:"year"`
+	FirstAired string         `json:"firstAired"`
+	Overview   string         `json:"overview"`
+	Image      string         `json:"image"`
+	RemoteIds  []tvdbRemoteId `json:"remoteIds"`
+	Genres     []struct {
+		Name string `json:"name"`
+	} `json:"genres"`
+	Translations struct {
+		NameTranslations     []tvdbTranslation `json:"nameTranslations"`
+		OverviewTranslations []tvdbTranslation `json:"overviewTranslations"`
+	} `json:"translations"`
+}
+
+func (t *TvdbProvider) Get(ctx context.Context, kind MediaKind, id string) (*Detail, error) {
+	url := "/series/" + id + "/extended"
+	if kind == KindMovie {
+		url = "/movies/" + id + "/extended"
+	}
+	result := tvdbResponse[tvdbExtended]{}
+	if err := t.request(ctx, url, map[string]string{"meta": "translations", "short": "true"}, &result); err != nil {
+		return nil, err
+	}
+	data := result.Data
+	d := &Detail{
+		Provider:      ProviderTvdb,
+		Id:            id,
+		Title:         data.Name,
+		OriginalTitle: data.Name,
+		Overview:      data.Overview,
+		ReleaseDate:   data.FirstAired,
+		PosterUrl:     data.Image,
+	}
+	d.Year, _ = strconv.Atoi(data.Year)
+	if d.Year == 0 {
+		d.Year = parseYear(d.ReleaseDate)
+	}
+	for _, tr := range data.Translations.NameTranslations {
+		if tr.Language == t.language && tr.Name != "" {
+			d.Title = tr.Name
+		}
+	}
+	for _, tr := range data.Translations.OverviewTranslations {
+		if tr.Language == t.language && tr.Overview != "" {
+			d.Overview = tr.Overview
+		}
+	}
+	for _, g := range data.Genres {
+		d.Genres = append(d.Genres, g.Name)
+	}
+	d.setId(ProviderTvdb, id)
+	d.setTvdbRemoteIds(data.RemoteIds)
+	return d, nil
+}
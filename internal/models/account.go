@@ -5,6 +5,7 @@ import (
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
 	"Q115-STRM/internal/notificationmanager"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/openlist"
 	"Q115-STRM/internal/v115open"
 	"context"
@@ -22,9 +23,10 @@ type Account struct {
 	TokenExpiriesTime int64      `json:"token_expiries_time"`
 	UserId            string     `json:"user_id"`                                         // 账号对应的用户id，唯一
 	Username          string     `json:"username" gorm:"type:string;size:32"`             // 网盘对应的用户名或者openlist的登录用户名
-	Password          string     `json:"password" gorm:"type:string;size:256"`            // openlist的用户密码
+	Password          string     `json:"password" gorm:"type:string;size:256"`            // openlist的用户密码或者123云盘的clientSecret
 	BaseUrl           string     `json:"base_url" gorm:"type:string;size:1024"`           // openlist的访问地址http[s]://ip:port
 	TokenFailedReason string     `json:"token_failed_reason" gorm:"type:string;size:256"` // 刷新token失败的原因
+	MaxStreams        int        `json:"max_streams" gorm:"default:0"`                    // 最大同时播放数，0表示不限制，超过网盘限制会被封号或者限速
 }
 
 func (account *Account) TableName() string {
@@ -81,6 +83,11 @@ func (account *Account) GetBaiDuPanClient() *baidupan.Client {
 	return baidupan.NewBaiDuPanClient(account.ID, account.Token)
 }
 
+// 123云盘使用AppId保存clientID，Password保存clientSecret
+func (account *Account) Get123Client() *open123.Client {
+	return open123.GetClient(account.ID, account.AppId, account.Password, account.Token, account.TokenExpiriesTime)
+}
+
 func (account *Account) Delete() error {
 	// 检查是否有关联的同步目录没有删除
 	syncPaths := GetAllSyncPathByAccountId(account.ID)
@@ -97,6 +104,17 @@ func (account *Account) Delete() error {
 	return nil
 }
 
+// 更新最大同时播放数
+func (account *Account) UpdateMaxStreams(maxStreams int) error {
+	account.MaxStreams = maxStreams
+	err := db.Db.Model(account).Where("id = ?", account.ID).Update("max_streams", maxStreams).Error
+	if err != nil {
+		helpers.AppLogger.Errorf("更新开放平台账号最大同时播放数失败: %v", err)
+		return err
+	}
+	return nil
+}
+
 func (account *Account) ClearToken(reason string) {
 	account.Token = ""
 	account.RefreshToken = ""
@@ -236,6 +254,64 @@ func CreateOpenListAccount(baseUrl string, username string, password string, tok
 	return account, nil
 }
 
+// 创建或更新123云盘账号
+// id: 账号ID，不为0时更新已有账号
+// clientId, clientSecret: 123云盘开放平台的应用凭证
+func Create123Account(id uint, name string, clientId string, clientSecret string) (*Account, error) {
+	account := &Account{}
+	if id > 0 {
+		var err error
+		account, err = GetAccountById(id)
+		if err != nil {
+			return nil, err
+		}
+		if account.SourceType != SourceType123 {
+			return nil, fmt.Errorf("账号 %d 不是123云盘账号", id)
+		}
+	}
+	account.SourceType = SourceType123
+	if name != "" {
+		account.Name = name
+	}
+	if account.AppId != clientId || account.Password != clientSecret {
+		// 凭证变更后旧token作废
+		account.Token = ""
+		account.TokenExpiriesTime = 0
+	}
+	account.AppId = clientId
+	account.Password = clientSecret
+	// 先获取一次访问凭证和用户信息，验证clientId和clientSecret是否正确
+	client := open123.NewClient(clientId, clientSecret)
+	defer client.Close()
+	ctx := context.Background()
+	if err := client.RefreshToken(ctx); err != nil {
+		helpers.AppLogger.Errorf("验证123云盘应用凭证失败: %v", err)
+		return nil, err
+	}
+	userInfo, err := client.GetUserInfo(ctx)
+	if err != nil {
+		helpers.AppLogger.Errorf("获取123云盘用户信息失败: %v", err)
+		return nil, err
+	}
+	account.Token = client.GetAccessToken()
+	account.TokenExpiriesTime = client.GetExpiredAt().Unix()
+	account.TokenFailedReason = ""
+	account.UserId = helpers.Int64ToString(userInfo.UID)
+	account.Username = userInfo.Nickname
+	if account.Name == "" {
+		account.Name = userInfo.Nickname
+	}
+	err = db.Db.Save(account).Error
+	if err != nil {
+		helpers.AppLogger.Errorf("保存123云盘账号失败: %v", err)
+		return nil, err
+	}
+	// 更新已缓存客户端的凭证
+	account.Get123Client()
+	helpers.AppLogger.Infof("保存123云盘账号成功，用户ID：%s，用户名：%s", account.UserId, account.Username)
+	return account, nil
+}
+
 // 创建115账号，如果userId已经存在，则更新
 // token: 115账号的token
 // refreshToken: 115账号的refreshToken
@@ -405,3 +481,32 @@ func HandleOpenListTokenSaveSync(event helpers.Event) helpers.EventResult {
 		}
 	}
 }
+
+// 处理123云盘访问凭证保存事件（同步版本）
+func Handle123TokenSaveSync(event helpers.Event) helpers.EventResult {
+	eventData := event.Data.(map[string]any)
+	account, err := GetAccountById(eventData["account_id"].(uint))
+	if err != nil {
+		helpers.AppLogger.Errorf("查询123云盘账号失败: %v", err)
+		return helpers.EventResult{
+			Success: false,
+			Error:   err,
+			Data:    nil,
+		}
+	}
+	expiresTime := eventData["expired_at"].(int64) - time.Now().Unix()
+	if suc := account.UpdateToken(eventData["token"].(string), "", expiresTime); !suc {
+		helpers.AppLogger.Warn("123云盘访问凭证保存失败")
+		return helpers.EventResult{
+			Success: false,
+			Error:   fmt.Errorf("123云盘访问凭证保存失败"),
+			Data:    nil,
+		}
+	}
+	helpers.AppLogger.Infof("123云盘访问凭证保存成功，账号ID：%d", account.ID)
+	return helpers.EventResult{
+		Success: true,
+		Error:   nil,
+		Data:    nil,
+	}
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"Q115-STRM/internal/helpers"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// 动画绝对集数偏移表的一项，Start是该季第一集对应的绝对集数
+// 例如第2季从第26集开始：{"season":2,"start":26}，绝对集数30换算成S02E05
+type AnimeEpisodeOffset struct {
+	Season int `json:"season"`
+	Start  int `json:"start"`
+}
+
+// TMDB中一季的集数，用来把绝对集数换算成季和集
+type AnimeSeasonEpisodes struct {
+	Season int
+	Count  int
+}
+
+// 是否启用动画模式，只对电视剧有效
+func (sp *ScrapePath) IsAnimeMode() bool {
+	return sp.AnimeMode && sp.MediaType == MediaTypeTvShow
+}
+
+// 解析动画绝对集数偏移表，按起始集数排序，解析失败返回空
+func (sp *ScrapePath) GetAnimeEpisodeOffsets() []AnimeEpisodeOffset {
+	if strings.TrimSpace(sp.AnimeEpisodeOffsets) == "" {
+		return nil
+	}
+	offsets := make([]AnimeEpisodeOffset, 0)
+	if err := json.Unmarshal([]byte(sp.AnimeEpisodeOffsets), &offsets); err != nil {
+		helpers.AppLogger.Errorf("解析刮削目录 %s 的动画集数偏移表失败: %v", sp.SourcePath, err)
+		return nil
+	}
+	slices.SortFunc(offsets, func(a, b AnimeEpisodeOffset) int {
+		return a.Start - b.Start
+	})
+	return offsets
+}
+
+// 检查动画绝对集数偏移表是否有效
+func (sp *ScrapePath) CheckAnimeEpisodeOffsets() error {
+	if strings.TrimSpace(sp.AnimeEpisodeOffsets) == "" {
+		return nil
+	}
+	offsets := make([]AnimeEpisodeOffset, 0)
+	if err := json.Unmarshal([]byte(sp.AnimeEpisodeOffsets), &offsets); err != nil {
+		return fmt.Errorf("动画集数偏移表格式错误: %v", err)
+	}
+	starts := make([]int, 0, len(offsets))
+	for _, o := range offsets {
+		if o.Season <= 0 || o.Start <= 0 {
+			return fmt.Errorf("动画集数偏移表中的季和起始集数必须大于0: %+v", o)
+		}
+		if slices.Contains(starts, o.Start) {
+			return fmt.Errorf("动画集数偏移表中的起始集数 %d 重复", o.Start)
+		}
+		starts = append(starts, o.Start)
+	}
+	return nil
+}
+
+// 动画模式下从文件名中提取季和集，返回是否提取到集数
+// 文件名中明确标注了季的按季内集数处理，否则记录为绝对集数，季暂时为-1，等识别电视剧后再换算
+func (sm *ScrapeMediaFile) ExtractAnimeEpisode(sp *ScrapePath) bool {
+	if !sp.IsAnimeMode() {
+		return false
+	}
+	info := helpers.ExtractAnimeEpisode(filepath.Base(sm.VideoFilename))
+	if info.Episode <= 0 {
+		return false
+	}
+	sm.SeasonNumber = info.Season
+	sm.EpisodeNumber = info.Episode
+	if info.Season == -1 {
+		sm.AbsoluteEpisode = info.Episode
+	}
+	helpers.AppLogger.Infof("动画模式从文件名中提取到季集: %s 季 %d 集 %d 版本 %d 分辨率 %s", sm.VideoFilename, info.Season, info.Episode, info.Version, info.Resolution)
+	return true
+}
+
+// 季文件夹或电视剧文件夹中有季时以文件夹为准，集数按季内集数处理
+func (sm *ScrapeMediaFile) CheckAbsoluteEpisode() {
+	if sm.AbsoluteEpisode > 0 && sm.SeasonNumber != -1 {
+		helpers.AppLogger.Infof("文件 %s 从文件夹中确定了季 %d，集 %d 不再按绝对集数处理", sm.VideoFilename, sm.SeasonNumber, sm.EpisodeNumber)
+		sm.AbsoluteEpisode = 0
+	}
+}
+
+// MapAbsoluteEpisode 把绝对集数换算成季和集
+// 优先使用偏移表：取起始集数不大于绝对集数的最后一项
+// 没有偏移表时按TMDB每季的集数累加（不含第0季特别篇），超出已知集数的算作最后一季，连载中的动画TMDB可能还没有最新的集
+func MapAbsoluteEpisode(absolute int, offsets []AnimeEpisodeOffset, seasons []AnimeSeasonEpisodes) (int, int, bool) {
+	if absolute <= 0 {
+		return 0, 0, false
+	}
+	if len(offsets) > 0 {
+		var matched *AnimeEpisodeOffset
+		for i := range offsets {
+			if offsets[i].Start <= absolute {
+				matched = &offsets[i]
+			}
+		}
+		if matched == nil {
+			return 0, 0, false
+		}
+		return matched.Season, absolute - matched.Start + 1, true
+	}
+	total := 0
+	last := -1
+	for i, s := range seasons {
+		if s.Season <= 0 || s.Count <= 0 {
+			continue
+		}
+		if absolute <= total+s.Count {
+			return s.Season, absolute - total, true
+		}
+		total += s.Count
+		last = i
+	}
+	if last == -1 {
+		return 0, 0, false
+	}
+	return seasons[last].Season, absolute - (total - seasons[last].Count), true
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestMapAbsoluteEpisode(t *testing.T) {
+	// 进击的巨人：第1季25集，第2季12集，第3季22集
+	seasons := []AnimeSeasonEpisodes{{Season: 1, Count: 25}, {Season: 2, Count: 12}, {Season: 3, Count: 22}}
+	sp := &ScrapePath{AnimeEpisodeOffsets: `[{"season":3,"start":38},{"season":2,"start":26}]`}
+	if err := sp.CheckAnimeEpisodeOffsets(); err != nil {
+		t.Fatalf("偏移表检查失败: %v", err)
+	}
+	offsets := sp.GetAnimeEpisodeOffsets()
+	tests := []struct {
+		name     string
+		absolute int
+		offsets  []AnimeEpisodeOffset
+		season   int
+		episode  int
+		ok       bool
+	}{
+		{name: "第1季", absolute: 25, season: 1, episode: 25, ok: true},
+		{name: "按TMDB集数换算", absolute: 49, season: 3, episode: 12, ok: true},
+		{name: "超出已知集数算最后一季", absolute: 60, season: 3, episode: 23, ok: true},
+		{name: "按偏移表换算", absolute: 49, offsets: offsets, season: 3, episode: 12, ok: true},
+		{name: "偏移表第2季", absolute: 26, offsets: offsets, season: 2, episode: 1, ok: true},
+		{name: "小于偏移表起始集数", absolute: 3, offsets: offsets, ok: false},
+		{name: "无效集数", absolute: 0, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			season, episode, ok := MapAbsoluteEpisode(tt.absolute, tt.offsets, seasons)
+			if ok != tt.ok || (ok && (season != tt.season || episode != tt.episode)) {
+				t.Errorf("绝对集数 %d 换算结果 S%02dE%02d %v，期望 S%02dE%02d %v", tt.absolute, season, episode, ok, tt.season, tt.episode, tt.ok)
+			}
+		})
+	}
+
+	// 换算后按模板重命名
+	sm := createTestTVShowData()
+	sm.AbsoluteEpisode = 49
+	sm.SeasonNumber, sm.EpisodeNumber, _ = MapAbsoluteEpisode(sm.AbsoluteEpisode, nil, seasons)
+	if result := sm.GenerateNameByTemplate("{{season_episode}}"); result != "S03E12" {
+		t.Errorf("换算后的文件名错误: %s", result)
+	}
+
+	for _, invalid := range []string{`[{"season":0,"start":1}]`, `[{"season":1,"start":1},{"season":2,"start":1}]`, `{`} {
+		sp := &ScrapePath{AnimeEpisodeOffsets: invalid}
+		if err := sp.CheckAnimeEpisodeOffsets(); err == nil {
+			t.Errorf("无效的偏移表 %s 应该返回错误", invalid)
+		}
+	}
+}
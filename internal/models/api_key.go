@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -19,6 +20,7 @@ type ApiKey struct {
 	KeyPrefix  string `gorm:"not null" json:"key_prefix"`              // Key前缀（前8位明文，用于显示）
 	LastUsedAt int64  `gorm:"default:0" json:"last_used_at"`           // 最后使用时间
 	IsActive   bool   `gorm:"default:true" json:"is_active"`           // 是否启用
+	Scopes     string `json:"scopes"`                                  // 权限范围，多个用逗号分隔，为空表示和所属用户的角色一致
 	User       *User  `gorm:"foreignKey:UserID" json:"user,omitempty"` // 关联的用户对象
 }
 
@@ -55,7 +57,11 @@ func HashAPIKey(key string) string {
 }
 
 // CreateAPIKey 创建新的API Key
-func CreateAPIKey(userID uint, name string) (*ApiKey, string, error) {
+// scopes为空表示和所属用户的角色拥有相同的权限范围
+func CreateAPIKey(userID uint, name string, scopes []string) (*ApiKey, string, error) {
+	if err := CheckScopes(scopes); err != nil {
+		return nil, "", err
+	}
 	// 生成原始密钥
 	rawKey, err := GenerateAPIKey()
 	if err != nil {
@@ -78,6 +84,7 @@ func CreateAPIKey(userID uint, name string) (*ApiKey, string, error) {
 		KeyHash:   keyHash,
 		KeyPrefix: keyPrefix,
 		IsActive:  true,
+		Scopes:    strings.Join(scopes, ","),
 	}
 
 	if err := db.Db.Save(apiKey).Error; err != nil {
@@ -16,6 +16,8 @@ import (
 var (
 	PauseSyncQueuesFunc  func()
 	ResumeSyncQueuesFunc func()
+	// 删除远程备份文件，由backup包注册，避免循环引用
+	DeleteRemoteBackupFunc func(upload *BackupUpload) error
 )
 
 const (
@@ -60,6 +62,18 @@ type BackupConfig struct {
 	BackupRetention int    `json:"backup_retention" gorm:"default:7"`  // 备份保留天数
 	BackupMaxCount  int    `json:"backup_max_count" gorm:"default:10"` // 最多保留的备份数量
 	BackupCompress  int    `json:"backup_compress" gorm:"default:1"`   // 是否压缩备份，0表示不压缩，1表示压缩
+	// 加密
+	BackupEncrypt         int    `json:"backup_encrypt" gorm:"default:0"` // 是否加密备份文件，0表示不加密，1表示加密
+	BackupEncryptPassword string `json:"backup_encrypt_password"`         // 加密密码，还原加密的备份时也使用这个密码
+	// GFS保留策略，任意一项大于0时代替保留天数和最大数量
+	KeepDaily   int `json:"keep_daily" gorm:"default:0"`   // 保留最近N天每天最新的一个备份
+	KeepWeekly  int `json:"keep_weekly" gorm:"default:0"`  // 保留最近N周每周最新的一个备份
+	KeepMonthly int `json:"keep_monthly" gorm:"default:0"` // 保留最近N月每月最新的一个备份
+}
+
+// 是否使用GFS保留策略
+func (c *BackupConfig) UseGFS() bool {
+	return c.KeepDaily > 0 || c.KeepWeekly > 0 || c.KeepMonthly > 0
 }
 
 func (*BackupConfig) TableName() string {
@@ -82,6 +96,12 @@ type BackupRecord struct {
 	CompressionRatio float64 `json:"compression_ratio"` // 压缩比例
 	IsCompressed     int     `json:"is_compressed"`     // 是否已压缩，0表示否，1表示是
 	CompletedAt      int64   `json:"completed_at"`      // 完成时间戳
+	IsEncrypted      int     `json:"is_encrypted"`      // 是否已加密，0表示否，1表示是
+}
+
+// 备份文件名
+func (r *BackupRecord) FileName() string {
+	return filepath.Base(r.FilePath)
 }
 
 func (*BackupRecord) TableName() string {
@@ -153,6 +173,21 @@ func (s *BackupService) CleanupOldBackups() {
 		Order("created_at DESC").
 		Find(&records)
 
+	if config.UseGFS() {
+		keep := SelectGFSBackupsToKeep(records, config.KeepDaily, config.KeepWeekly, config.KeepMonthly)
+		for _, record := range records {
+			if keep[record.ID] {
+				continue
+			}
+			if err := s.DeleteBackup(record.ID, false); err != nil {
+				helpers.AppLogger.Warnf("清理旧备份失败 ID=%d: %v, 原因: 不在GFS保留策略内", record.ID, err)
+			} else {
+				helpers.AppLogger.Infof("已清理旧备份 ID=%d, 原因: 不在GFS保留策略内", record.ID)
+			}
+		}
+		return
+	}
+
 	now := time.Now().Unix()
 	retentionSeconds := int64(config.BackupRetention * 24 * 60 * 60)
 
@@ -180,6 +215,41 @@ func (s *BackupService) CleanupOldBackups() {
 	}
 }
 
+// SelectGFSBackupsToKeep 按GFS（祖父-父-子）策略选出需要保留的备份
+// records 必须按创建时间倒序排列
+// 每天、每ISO周、每月各保留最新的一个，分别最多保留daily、weekly、monthly个，最新的备份总是保留
+func SelectGFSBackupsToKeep(records []BackupRecord, daily, weekly, monthly int) map[uint]bool {
+	keep := make(map[uint]bool)
+	if len(records) == 0 {
+		return keep
+	}
+	keep[records[0].ID] = true
+	bucket := func(limit int, key func(t time.Time) string) {
+		if limit <= 0 {
+			return
+		}
+		seen := make(map[string]bool)
+		for _, record := range records {
+			k := key(time.Unix(record.CreatedAt, 0))
+			if seen[k] {
+				continue
+			}
+			if len(seen) >= limit {
+				return
+			}
+			seen[k] = true
+			keep[record.ID] = true
+		}
+	}
+	bucket(daily, func(t time.Time) string { return t.Format("2006-01-02") })
+	bucket(weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	bucket(monthly, func(t time.Time) string { return t.Format("2006-01") })
+	return keep
+}
+
 func (s *BackupService) DeleteBackup(recordID uint, checkRunning bool) error {
 	var record BackupRecord
 	if err := db.Db.First(&record, recordID).Error; err != nil {
@@ -194,6 +264,16 @@ func (s *BackupService) DeleteBackup(recordID uint, checkRunning bool) error {
 		}
 	}
 
+	// 删除上传到远程目标的备份文件
+	for _, upload := range GetBackupUploads(record.ID) {
+		if DeleteRemoteBackupFunc != nil && upload.Status == BackupUploadStatusCompleted {
+			if err := DeleteRemoteBackupFunc(&upload); err != nil {
+				helpers.AppLogger.Warnf("删除远程备份文件 %s 失败: %v", upload.FileName, err)
+			}
+		}
+		db.Db.Delete(&upload)
+	}
+
 	if err := db.Db.Delete(&record).Error; err != nil {
 		return fmt.Errorf("删除备份记录失败: %v", err)
 	}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"fmt"
+)
+
+type BackupDestinationType string
+
+const (
+	BackupDestinationLocal    BackupDestinationType = "local"
+	BackupDestinationWebDAV   BackupDestinationType = "webdav"
+	BackupDestinationS3       BackupDestinationType = "s3"
+	BackupDestination115      BackupDestinationType = "115"
+	BackupDestinationOpenList BackupDestinationType = "openlist"
+
+	BackupUploadStatusCompleted = "completed"
+	BackupUploadStatusFailed    = "failed"
+)
+
+// BackupDestination 备份的远程存储目标，备份完成后上传到所有启用的目标
+type BackupDestination struct {
+	BaseModel
+	Name      string                `json:"name"`
+	Type      BackupDestinationType `json:"type"`
+	Enabled   int                   `json:"enabled" gorm:"default:1"` // 是否启用，0表示禁用，1表示启用
+	Path      string                `json:"path"`                     // 存储目录：本地目录、WebDAV目录、S3对象前缀、115或OpenList中的目录
+	Url       string                `json:"url"`                      // WebDAV地址或S3 Endpoint
+	Username  string                `json:"username"`                 // WebDAV用户名或S3 Access Key
+	Password  string                `json:"password"`                 // WebDAV密码或S3 Secret Key
+	Bucket    string                `json:"bucket"`                   // S3存储桶
+	Region    string                `json:"region"`                   // S3区域，MinIO可以留空
+	AccountId uint                  `json:"account_id"`               // 115或OpenList账号ID
+}
+
+func (*BackupDestination) TableName() string {
+	return "backup_destination"
+}
+
+// 校验必填项
+func (d *BackupDestination) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("名称不能为空")
+	}
+	switch d.Type {
+	case BackupDestinationLocal:
+		if d.Path == "" {
+			return fmt.Errorf("本地目录不能为空")
+		}
+	case BackupDestinationWebDAV:
+		if d.Url == "" {
+			return fmt.Errorf("WebDAV地址不能为空")
+		}
+	case BackupDestinationS3:
+		if d.Url == "" || d.Bucket == "" || d.Username == "" || d.Password == "" {
+			return fmt.Errorf("S3 Endpoint、存储桶、Access Key和Secret Key不能为空")
+		}
+	case BackupDestination115, BackupDestinationOpenList:
+		if d.AccountId == 0 {
+			return fmt.Errorf("请选择账号")
+		}
+		if d.Path == "" {
+			return fmt.Errorf("存储目录不能为空")
+		}
+	default:
+		return fmt.Errorf("不支持的备份目标类型: %s", d.Type)
+	}
+	return nil
+}
+
+// BackupUpload 备份文件上传到远程目标的记录
+type BackupUpload struct {
+	BaseModel
+	RecordId      uint   `json:"record_id" gorm:"index"`      // 备份记录ID
+	DestinationId uint   `json:"destination_id" gorm:"index"` // 备份目标ID
+	FileName      string `json:"file_name"`                   // 远程文件名
+	Status        string `json:"status"`                      // completed/failed
+	ErrorMsg      string `json:"error_msg"`
+}
+
+func (*BackupUpload) TableName() string {
+	return "backup_upload"
+}
+
+func GetBackupDestinations() []BackupDestination {
+	var destinations []BackupDestination
+	db.Db.Order("id").Find(&destinations)
+	return destinations
+}
+
+func GetEnabledBackupDestinations() []BackupDestination {
+	var destinations []BackupDestination
+	db.Db.Where("enabled = ?", 1).Order("id").Find(&destinations)
+	return destinations
+}
+
+func GetBackupDestinationById(id uint) (*BackupDestination, error) {
+	var destination BackupDestination
+	if err := db.Db.First(&destination, id).Error; err != nil {
+		return nil, err
+	}
+	return &destination, nil
+}
+
+func SaveBackupDestination(destination *BackupDestination) error {
+	if err := destination.Validate(); err != nil {
+		return err
+	}
+	return db.Db.Save(destination).Error
+}
+
+// 删除备份目标，已上传的文件保留在远程
+func DeleteBackupDestination(id uint) error {
+	if err := db.Db.Where("destination_id = ?", id).Delete(&BackupUpload{}).Error; err != nil {
+		return err
+	}
+	return db.Db.Delete(&BackupDestination{}, id).Error
+}
+
+func GetBackupUploads(recordId uint) []BackupUpload {
+	var uploads []BackupUpload
+	db.Db.Where("record_id = ?", recordId).Find(&uploads)
+	return uploads
+}
+
+func SaveBackupUpload(upload *BackupUpload) error {
+	return db.Db.Save(upload).Error
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectGFSBackupsToKeep(t *testing.T) {
+	// 2026-03-31 是周二，每天两个备份，共60天，按时间倒序
+	start := time.Date(2026, 3, 31, 23, 0, 0, 0, time.Local)
+	records := make([]BackupRecord, 0)
+	id := uint(1000)
+	for day := 0; day < 60; day++ {
+		for _, hour := range []int{0, 12} {
+			records = append(records, BackupRecord{
+				BaseModel: BaseModel{ID: id, CreatedAt: start.AddDate(0, 0, -day).Add(-time.Duration(hour) * time.Hour).Unix()},
+			})
+			id--
+		}
+	}
+
+	keep := SelectGFSBackupsToKeep(records, 3, 0, 0)
+	if len(keep) != 3 || !keep[1000] || !keep[998] || !keep[996] || keep[999] {
+		t.Errorf("每天保留最新的一个备份: %v", keep)
+	}
+
+	// 周保留：3-31(周二)所在周，3-29(周日)所在周，3-22所在周
+	keep = SelectGFSBackupsToKeep(records, 0, 3, 0)
+	weekly := []time.Time{start, start.AddDate(0, 0, -2), start.AddDate(0, 0, -9)}
+	if len(keep) != 3 {
+		t.Fatalf("应该保留3个周备份，实际 %d", len(keep))
+	}
+	for _, record := range records {
+		if !keep[record.ID] {
+			continue
+		}
+		found := false
+		for _, w := range weekly {
+			if time.Unix(record.CreatedAt, 0).Format("2006-01-02") == w.Format("2006-01-02") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("保留了错误的周备份: %s", time.Unix(record.CreatedAt, 0))
+		}
+	}
+
+	// 月保留：数据覆盖到1月31日，3月、2月、1月各一个，限制为2个时只保留3月和2月
+	keep = SelectGFSBackupsToKeep(records, 0, 0, 2)
+	if len(keep) != 2 || !keep[1000] {
+		t.Errorf("月备份保留错误: %v", keep)
+	}
+
+	// 组合策略取并集
+	keep = SelectGFSBackupsToKeep(records, 7, 4, 2)
+	if len(keep) != 7+2+1 {
+		t.Errorf("组合策略保留数量错误: %d", len(keep))
+	}
+
+	if len(SelectGFSBackupsToKeep(nil, 1, 1, 1)) != 0 {
+		t.Errorf("没有备份时不应该保留任何记录")
+	}
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/tmdb"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 合集信息的缓存时间，超过后刮削时重新从TMDB查询
+const collectionRefreshInterval = 7 * 24 * time.Hour
+
+// 电影合集（系列），用于生成nfo的<set>和统计缺少的电影
+type MediaCollection struct {
+	BaseModel
+	TmdbId       int64             `json:"tmdb_id" gorm:"uniqueIndex"` // TMDB合集ID
+	Name         string            `json:"name"`                       // 合集名称
+	Overview     string            `json:"overview"`                   // 合集描述
+	PosterPath   string            `json:"poster_path"`                // 海报
+	BackdropPath string            `json:"backdrop_path"`              // 背景图片
+	Parts        []*CollectionPart `json:"parts" gorm:"-"`             // 合集包含的电影
+	PartsJson    string            `json:"-" gorm:"type:text"`         // Parts的JSON字符串
+	RefreshTime  int64             `json:"refresh_time"`               // 上次从TMDB更新的时间
+}
+
+// 合集中的电影
+type CollectionPart struct {
+	TmdbId      int64  `json:"tmdb_id"`      // TMDB ID
+	Title       string `json:"title"`        // 标题
+	Year        int    `json:"year"`         // 年份
+	ReleaseDate string `json:"release_date"` // 上映日期
+	PosterPath  string `json:"poster_path"`  // 海报
+}
+
+func (c *MediaCollection) Save() error {
+	c.PartsJson = helpers.JsonString(c.Parts)
+	if err := db.Db.Save(c).Error; err != nil {
+		helpers.AppLogger.Errorf("保存合集 %s 失败: %v", c.Name, err)
+		return err
+	}
+	return nil
+}
+
+func (c *MediaCollection) DecodeJson() {
+	if c.PartsJson == "" {
+		return
+	}
+	parts, err := helpers.StringJson[[]*CollectionPart](c.PartsJson)
+	if err != nil {
+		helpers.AppLogger.Warnf("解码合集 %s 的电影列表失败: %v", c.Name, err)
+		return
+	}
+	c.Parts = parts
+}
+
+// 是否需要从TMDB重新查询
+func (c *MediaCollection) NeedsRefresh() bool {
+	return time.Since(time.Unix(c.RefreshTime, 0)) > collectionRefreshInterval
+}
+
+// 使用TMDB的合集详情更新
+func (c *MediaCollection) FillByTmdb(detail *tmdb.CollectionDetail) {
+	imageUrl := GlobalScrapeSettings.GetTmdbImageUrl()
+	c.TmdbId = detail.ID
+	c.Name = detail.Name
+	c.Overview = detail.Overview
+	c.PosterPath = ""
+	if detail.PosterPath != "" {
+		c.PosterPath = fmt.Sprintf("%s/t/p/original%s", imageUrl, detail.PosterPath)
+	}
+	c.BackdropPath = ""
+	if detail.BackdropPath != "" {
+		c.BackdropPath = fmt.Sprintf("%s/t/p/original%s", imageUrl, detail.BackdropPath)
+	}
+	c.Parts = make([]*CollectionPart, 0, len(detail.Parts))
+	for _, part := range detail.Parts {
+		p := &CollectionPart{
+			TmdbId:      part.ID,
+			Title:       part.Title,
+			Year:        helpers.ParseYearFromDate(part.ReleaseDate),
+			ReleaseDate: part.ReleaseDate,
+		}
+		if part.PosterPath != "" {
+			p.PosterPath = fmt.Sprintf("%s/t/p/w500%s", imageUrl, part.PosterPath)
+		}
+		c.Parts = append(c.Parts, p)
+	}
+	// 按上映日期排序，未定档的排在最后
+	slices.SortStableFunc(c.Parts, func(a, b *CollectionPart) int {
+		if a.ReleaseDate == "" || b.ReleaseDate == "" {
+			return len(b.ReleaseDate) - len(a.ReleaseDate)
+		}
+		if a.ReleaseDate < b.ReleaseDate {
+			return -1
+		}
+		if a.ReleaseDate > b.ReleaseDate {
+			return 1
+		}
+		return 0
+	})
+	c.RefreshTime = time.Now().Unix()
+}
+
+// 按TMDB合集ID查询，不存在时返回nil
+func GetMediaCollectionByTmdbId(tmdbId int64) *MediaCollection {
+	collection := &MediaCollection{}
+	if err := db.Db.Where("tmdb_id = ?", tmdbId).First(collection).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.AppLogger.Errorf("查询合集 %d 失败: %v", tmdbId, err)
+		}
+		return nil
+	}
+	collection.DecodeJson()
+	return collection
+}
+
+// 合集列表项，区分已有和缺少的电影
+type MediaCollectionSummary struct {
+	*MediaCollection
+	Owned   []*CollectionPart `json:"owned"`   // 已刮削的电影
+	Missing []*CollectionPart `json:"missing"` // 缺少的电影
+}
+
+// 分页查询已刮削电影所属的合集，scrapePathIds为nil时统计所有刮削目录
+func GetMediaCollections(page int, pageSize int, scrapePathIds []uint) (int64, []*MediaCollectionSummary) {
+	ownedQuery := func() *gorm.DB {
+		tx := db.Db.Model(&Media{}).Where("media_type = ? AND collection_id > 0", MediaTypeMovie)
+		if scrapePathIds != nil {
+			tx = tx.Where("scrape_path_id IN ?", scrapePathIds)
+		}
+		return tx
+	}
+	query := func() *gorm.DB {
+		return db.Db.Model(&MediaCollection{}).Where("tmdb_id IN (?)", ownedQuery().Select("collection_id"))
+	}
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		helpers.AppLogger.Errorf("查询合集总数失败: %v", err)
+		return 0, nil
+	}
+	var collections []*MediaCollection
+	if err := query().Order("name asc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&collections).Error; err != nil {
+		helpers.AppLogger.Errorf("查询合集列表失败: %v", err)
+		return 0, nil
+	}
+	collectionIds := make([]int64, 0, len(collections))
+	for _, c := range collections {
+		collectionIds = append(collectionIds, c.TmdbId)
+	}
+	type ownedMovie struct {
+		CollectionId int64
+		TmdbId       int64
+	}
+	var owned []ownedMovie
+	if err := ownedQuery().Where("collection_id IN ?", collectionIds).Select("collection_id, tmdb_id").Find(&owned).Error; err != nil {
+		helpers.AppLogger.Errorf("查询合集中已有的电影失败: %v", err)
+		return 0, nil
+	}
+	ownedMap := make(map[int64][]int64)
+	for _, o := range owned {
+		ownedMap[o.CollectionId] = append(ownedMap[o.CollectionId], o.TmdbId)
+	}
+	summaries := make([]*MediaCollectionSummary, 0, len(collections))
+	for _, c := range collections {
+		c.DecodeJson()
+		summary := &MediaCollectionSummary{MediaCollection: c, Owned: make([]*CollectionPart, 0), Missing: make([]*CollectionPart, 0)}
+		for _, part := range c.Parts {
+			if slices.Contains(ownedMap[c.TmdbId], part.TmdbId) {
+				summary.Owned = append(summary.Owned, part)
+			} else {
+				summary.Missing = append(summary.Missing, part)
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return total, summaries
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"Q115-STRM/internal/tmdb"
+	"strings"
+	"testing"
+)
+
+func TestMediaCollectionFillByTmdb(t *testing.T) {
+	detail := &tmdb.CollectionDetail{
+		CollectionBase: tmdb.CollectionBase{ID: 726871, Name: "沙丘（系列）", PosterPath: "/poster.jpg"},
+		Overview:       "沙丘系列电影",
+		Parts: []tmdb.SearchMovie{
+			{ID: 3, Title: "沙丘3", ReleaseDate: ""},
+			{ID: 2, Title: "沙丘2", ReleaseDate: "2024-02-27"},
+			{ID: 1, Title: "沙丘", ReleaseDate: "2021-09-15"},
+		},
+	}
+	c := &MediaCollection{}
+	c.FillByTmdb(detail)
+	if c.TmdbId != 726871 || c.Name != "沙丘（系列）" || c.Overview != "沙丘系列电影" {
+		t.Fatalf("合集信息错误: %+v", c)
+	}
+	if !strings.HasSuffix(c.PosterPath, "/t/p/original/poster.jpg") || c.BackdropPath != "" {
+		t.Errorf("合集图片错误: %s %s", c.PosterPath, c.BackdropPath)
+	}
+	if len(c.Parts) != 3 || c.Parts[0].TmdbId != 1 || c.Parts[1].TmdbId != 2 || c.Parts[2].TmdbId != 3 {
+		t.Errorf("合集中的电影应该按上映日期排序，未定档的排在最后")
+	}
+	if c.Parts[1].Year != 2024 {
+		t.Errorf("年份错误: %d", c.Parts[1].Year)
+	}
+	if c.NeedsRefresh() {
+		t.Errorf("刚更新的合集不需要重新查询")
+	}
+	if !(&MediaCollection{}).NeedsRefresh() {
+		t.Errorf("没有更新过的合集需要查询")
+	}
+}
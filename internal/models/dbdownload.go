@@ -3,6 +3,7 @@ package models
 import (
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/v115open"
 	"context"
 	"errors"
@@ -130,6 +131,7 @@ func (task *DbDownloadTask) Download() {
 		case SourceTypeBaiduPan:
 			task.DownloadBaiduPanFile()
 		case SourceType123:
+			task.Download123File()
 		}
 	case DownloadSourceEmbyMedia:
 		// emby媒体信息提取，从emby下载
@@ -206,6 +208,34 @@ func (task *DbDownloadTask) Download115File() {
 	task.Complete()
 }
 
+// 123云盘的RemoteFileId是文件ID
+func (task *DbDownloadTask) Download123File() {
+	account := task.GetAccount()
+	if account == nil {
+		task.Fail(fmt.Errorf("账户不存在，无法下载文件%s", task.LocalFullPath))
+		return
+	}
+	// 标记为下载中
+	task.Downloading()
+	client := account.Get123Client()
+	url, err := client.GetDirectLink(context.Background(), helpers.StringToInt64(task.RemoteFileId))
+	if err != nil || url == "" {
+		helpers.AppLogger.Warnf("[下载] 获取123云盘下载链接失败: %s %v", task.RemoteFileId, err)
+		task.Fail(fmt.Errorf("获取 %s => %s 的下载链接失败", task.RemoteFileId, task.FileName))
+		return
+	}
+	downloadErr := helpers.DownloadFile(url, task.LocalFullPath, open123.DEFAULTUA)
+	if downloadErr != nil {
+		helpers.AppLogger.Warnf("[下载] 下载文件失败: %s", downloadErr.Error())
+		task.Fail(downloadErr)
+		return
+	}
+	// 设置文件修改时间
+	task.SetMTime()
+	// 下载完成
+	task.Complete()
+}
+
 func (task *DbDownloadTask) DownloadOpenListFile() {
 	account := task.GetAccount()
 	if account == nil {
@@ -3,6 +3,7 @@ package models
 import (
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/open123"
 	"context"
 	"errors"
 	"fmt"
@@ -146,6 +147,10 @@ func (task *DbUploadTask) Upload() {
 		if !task.UploadBaiduPanFile() {
 			return
 		}
+	case SourceType123:
+		if !task.Upload123File() {
+			return
+		}
 	default:
 		task.Fail(fmt.Errorf("未知的上传来源类型 %s", task.SourceType))
 		return
@@ -283,6 +288,65 @@ func (task *DbUploadTask) UploadBaiduPanFile() bool {
 	return true
 }
 
+// 123云盘上传文件，RemotePathId是父目录ID
+func (task *DbUploadTask) Upload123File() bool {
+	// 检查账户是否存在
+	account := task.GetAccount()
+	if account == nil {
+		task.Fail(fmt.Errorf("账户 %d 不存在", task.AccountId))
+		return false
+	}
+	client := account.Get123Client()
+	task.Uploading()
+	parentId := helpers.StringToInt64(task.RemotePathId)
+	// 查找父目录下的同名文件
+	findFile := func() (*open123.FileInfo, error) {
+		files, err := client.ListAllFiles(context.Background(), parentId)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !f.IsDir() && f.FileName == task.FileName {
+				return &f, nil
+			}
+		}
+		return nil, nil
+	}
+	existsFile, err := findFile()
+	if err != nil {
+		task.Fail(fmt.Errorf("123云盘检查父目录 %s 失败: %v", task.RemotePathId, err))
+		return false
+	}
+	if existsFile == nil {
+		helpers.AppLogger.Infof("准备将文件 %s 上传到123云盘目录 %s", task.LocalFullPath, task.RemotePathId)
+		resp, err := client.UploadFile(context.Background(), task.LocalFullPath, task.FileName, parentId)
+		if err != nil {
+			task.Fail(fmt.Errorf("123云盘上传文件 %s 失败: %v", task.FileName, err))
+			return false
+		}
+		helpers.AppLogger.Infof("123云盘上传文件 %s 成功, 新的文件ID: %d", task.LocalFullPath, resp.FileID)
+	} else if task.Source == UploadSourceScrape {
+		helpers.AppLogger.Infof("回调刮削整理：刮削文件 %d 已存在, 文件ID: %d", task.ScrapeMediaFileId, existsFile.FileID)
+	}
+	if task.Source == UploadSourceStrm {
+		// 查询文件的修改时间，然后更新本地文件的修改时间
+		if existsFile == nil {
+			existsFile, err = findFile()
+			if err != nil || existsFile == nil {
+				task.Fail(fmt.Errorf("123云盘查询文件详情 %s 失败: %v", task.FileName, err))
+				return false
+			}
+		}
+		t := time.Unix(existsFile.UpdateTime(), 0)
+		err = os.Chtimes(task.LocalFullPath, t, t)
+		if err != nil {
+			task.Fail(fmt.Errorf("更新本地文件 %s 修改时间失败: %v", task.LocalFullPath, err))
+			return false
+		}
+	}
+	return true
+}
+
 func (task *DbUploadTask) UploadOpenListFile() bool {
 	// 检查账户是否存在
 	account := task.GetAccount()
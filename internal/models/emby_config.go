@@ -1,12 +1,16 @@
 package models
 
-import "Q115-STRM/internal/db"
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/mediaserver"
+)
 
-// EmbyConfig 独立的Emby配置表
+// EmbyConfig 独立的媒体服务器配置表，表名沿用Emby
 type EmbyConfig struct {
 	BaseModel
+	ServerType              string `json:"server_type" gorm:"type:varchar(20);default:'emby'"` // 媒体服务器类型：emby、jellyfin、plex
 	EmbyUrl                 string `json:"emby_url" gorm:"type:varchar(500)"`
-	EmbyApiKey              string `json:"emby_api_key" gorm:"type:varchar(200)"`
+	EmbyApiKey              string `json:"emby_api_key" gorm:"type:varchar(200)"` // Plex填写X-Plex-Token
 	EnableDeleteNetdisk     int    `json:"enable_delete_netdisk" gorm:"default:0"`
 	EnableRefreshLibrary    int    `json:"enable_refresh_library" gorm:"default:0"`
 	EnableMediaNotification int    `json:"enable_media_notification" gorm:"default:0"`
@@ -45,3 +49,21 @@ func GetEmbyConfig() (*EmbyConfig, error) {
 func (c *EmbyConfig) Update(updates map[string]interface{}) error {
 	return db.Db.Model(c).Updates(updates).Error
 }
+
+// GetServerType 媒体服务器类型，旧数据为空时是Emby
+func (c *EmbyConfig) GetServerType() mediaserver.ServerType {
+	if c.ServerType == "" {
+		return mediaserver.ServerTypeEmby
+	}
+	return mediaserver.ServerType(c.ServerType)
+}
+
+// IsEmby 是否为Emby，媒体信息提取和302播放只支持Emby
+func (c *EmbyConfig) IsEmby() bool {
+	return c.GetServerType() == mediaserver.ServerTypeEmby
+}
+
+// NewMediaServer 根据配置创建媒体服务器客户端
+func (c *EmbyConfig) NewMediaServer() (mediaserver.MediaServer, error) {
+	return mediaserver.New(c.GetServerType(), c.EmbyUrl, c.EmbyApiKey)
+}
@@ -3,8 +3,9 @@ package models
 import (
 	"Q115-STRM/internal/baidupan"
 	"Q115-STRM/internal/db"
-	embyclientrestgo "Q115-STRM/internal/embyclient-rest-go"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/mediaserver"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/openlist"
 	"Q115-STRM/internal/v115open"
 	"context"
@@ -51,7 +52,8 @@ func (*EmbyMediaItem) TableName() string {
 type EmbyMediaSyncFile struct {
 	BaseModel
 	SyncPathId uint   `json:"sync_path_id" gorm:"index:idx_emby_sync_path_id"`
-	EmbyItemId uint   `json:"emby_item_id" gorm:"index:idx_emby_media_item_id"`
+	EmbyItemId uint   `json:"emby_item_id" gorm:"index:idx_emby_media_item_id"` // 旧字段，只有Emby的数字ID
+	ItemId     string `json:"item_id" gorm:"index:idx_emby_sf_item_id"`         // 媒体服务器的媒体项ID，Jellyfin和Plex的ID不是数字
 	SyncFileId uint   `json:"sync_file_id" gorm:"index:idx_emby_sync_file_id"`
 	PickCode   string `json:"pick_code" gorm:"index:idx_emby_sf_pick_code"`
 }
@@ -85,7 +87,7 @@ func (*EmbyLibrary) TableName() string {
 }
 
 // UpsertEmbyLibraries 更新或创建媒体库记录
-func UpsertEmbyLibraries(libs []embyclientrestgo.EmbyLibrary) error {
+func UpsertEmbyLibraries(libs []mediaserver.Library) error {
 	for _, lib := range libs {
 		existing := &EmbyLibrary{}
 		err := db.Db.Where("library_id = ?", lib.ID).First(existing).Error
@@ -109,7 +111,7 @@ func UpsertEmbyLibraries(libs []embyclientrestgo.EmbyLibrary) error {
 	return nil
 }
 
-// CleanupDeletedEmbyLibraries 清理已不在Emby中存在的媒体库记录
+// CleanupDeletedEmbyLibraries 清理已不在媒体服务器中存在的媒体库记录
 func CleanupDeletedEmbyLibraries(activeLibraryIds []string) error {
 	if len(activeLibraryIds) == 0 {
 		return nil
@@ -196,18 +198,17 @@ func CleanupOrphanedEmbyMediaItems(validItemIds []string) error {
 }
 
 // CreateEmbyMediaSyncFile 创建关联（存在则跳过）
-func CreateEmbyMediaSyncFile(embyItemId string, syncFileId uint, pickCode string, syncPathId uint) error {
+func CreateEmbyMediaSyncFile(itemId string, syncFileId uint, pickCode string, syncPathId uint) error {
 	var count int64
-	embyItemIdInt := helpers.StringToInt(embyItemId)
 	if err := db.Db.Model(&EmbyMediaSyncFile{}).
-		Where("emby_item_id = ? AND sync_file_id = ?", uint(embyItemIdInt), syncFileId).
+		Where("item_id = ? AND sync_file_id = ?", itemId, syncFileId).
 		Count(&count).Error; err != nil {
 		return err
 	}
 	if count > 0 {
 		return nil
 	}
-	relation := &EmbyMediaSyncFile{EmbyItemId: uint(embyItemIdInt), SyncFileId: syncFileId, PickCode: pickCode, SyncPathId: syncPathId}
+	relation := &EmbyMediaSyncFile{ItemId: itemId, EmbyItemId: uint(helpers.StringToInt(itemId)), SyncFileId: syncFileId, PickCode: pickCode, SyncPathId: syncPathId}
 	return db.Db.Save(relation).Error
 }
 
@@ -266,17 +267,19 @@ func GetEmbyLibraryIdsBySyncPathId(syncPathId uint) map[string]string {
 	return libraryIds
 }
 
-// 刷新Emby媒体库通过SyncPathId
+// 刷新媒体服务器的媒体库通过SyncPathId
 func RefreshEmbyLibraryBySyncPathId(syncPathId uint) error {
 	if GlobalEmbyConfig == nil || GlobalEmbyConfig.EmbyUrl == "" || GlobalEmbyConfig.EmbyApiKey == "" || GlobalEmbyConfig.EnableRefreshLibrary == 0 {
-		helpers.AppLogger.Infof("Emby未配置或未启用刷新媒体库，跳过刷新")
+		helpers.AppLogger.Infof("媒体服务器未配置或未启用刷新媒体库，跳过刷新")
 		return nil
 	}
-	// 创建一个新的 Emby 客户端
-	client := embyclientrestgo.NewClient(GlobalEmbyConfig.EmbyUrl, GlobalEmbyConfig.EmbyApiKey)
+	server, err := GlobalEmbyConfig.NewMediaServer()
+	if err != nil {
+		return err
+	}
 	libraryIds := GetEmbyLibraryIdsBySyncPathId(syncPathId)
 	for libId, libName := range libraryIds {
-		if err := client.RefreshLibrary(libId, libName); err != nil {
+		if err := server.RefreshLibrary(libId, libName); err != nil {
 			return err
 		}
 	}
@@ -285,9 +288,8 @@ func RefreshEmbyLibraryBySyncPathId(syncPathId uint) error {
 
 // 联动删除网盘的电影
 func DeleteNetdiskMovieByEmbyItemId(itemId string) error {
-	itemIdUint := uint(helpers.StringToInt(itemId))
 	embyItem := &EmbyMediaSyncFile{}
-	if err := db.Db.Where("emby_item_id = ?", itemIdUint).First(embyItem).Error; err != nil {
+	if err := db.Db.Where("item_id = ?", itemId).First(embyItem).Error; err != nil {
 		helpers.AppLogger.Errorf("Emby Item %s 没有关联的网盘文件", itemId)
 		return err
 	}
@@ -357,6 +359,15 @@ func DeleteNetdiskMovieByEmbyItemId(itemId string) error {
 			// 删除视频文件+元数据
 			success, delErr = deleteBaiduPanFiles(client, syncFile, metaFiles)
 		}
+	case SourceType123:
+		client := account.Get123Client()
+		if videoFileCount == 1 {
+			// 删除目录
+			success, delErr = delete123Folder(client, syncFile.Path, syncFile.ParentId, syncFile.SyncPathId)
+		} else {
+			// 删除视频文件+元数据
+			success, delErr = delete123Files(client, syncFile, metaFiles)
+		}
 	}
 	if delErr != nil {
 		helpers.AppLogger.Errorf("删除Emby Item %s 关联的网盘视频文件+元数据失败: %v", itemId, delErr)
@@ -364,7 +375,7 @@ func DeleteNetdiskMovieByEmbyItemId(itemId string) error {
 	}
 	if success {
 		helpers.AppLogger.Infof("删除Emby Item %s 关联的网盘视频文件+元数据成功: %v", itemId, success)
-		if err := db.Db.Where("emby_item_id = ?", itemIdUint).Delete(&EmbyMediaSyncFile{}).Error; err != nil {
+		if err := db.Db.Where("item_id = ?", itemId).Delete(&EmbyMediaSyncFile{}).Error; err != nil {
 			helpers.AppLogger.Errorf("删除Emby Item %s 关联的EmbyMediaSyncFile记录失败: %v", itemId, err)
 			return err
 		}
@@ -378,9 +389,8 @@ func DeleteNetdiskMovieByEmbyItemId(itemId string) error {
 
 // 联动删除网盘的集
 func DeleteNetdiskEpisodeByEmbyItemId(itemId string) error {
-	itemIdUint := uint(helpers.StringToInt(itemId))
 	embyItem := &EmbyMediaSyncFile{}
-	if err := db.Db.Where("emby_item_id = ?", itemIdUint).First(embyItem).Error; err != nil {
+	if err := db.Db.Where("item_id = ?", itemId).First(embyItem).Error; err != nil {
 		helpers.AppLogger.Errorf("Emby Item %s 没有关联的网盘文件", itemId)
 		return err
 	}
@@ -426,6 +436,9 @@ func DeleteNetdiskEpisodeByEmbyItemId(itemId string) error {
 		// 执行BaiduPan网盘删除逻辑
 		client := account.GetBaiDuPanClient()
 		success, delErr = deleteBaiduPanFiles(client, syncFile, filesToDelete)
+	case SourceType123:
+		client := account.Get123Client()
+		success, delErr = delete123Files(client, syncFile, filesToDelete)
 	}
 	if delErr != nil {
 		helpers.AppLogger.Errorf("删除Emby Item %s 关联的网盘集视频文件+元数据失败: %v", itemId, delErr)
@@ -435,7 +448,7 @@ func DeleteNetdiskEpisodeByEmbyItemId(itemId string) error {
 	// 删除EmbyMediaSyncFile数据
 	// 删除EmbyMediaItem数据
 	if success {
-		if err := db.Db.Where("emby_item_id = ?", itemIdUint).Delete(&EmbyMediaSyncFile{}).Error; err != nil {
+		if err := db.Db.Where("item_id = ?", itemId).Delete(&EmbyMediaSyncFile{}).Error; err != nil {
 			helpers.AppLogger.Errorf("删除Emby Item %s 关联的EmbyMediaSyncFile记录失败: %v", itemId, err)
 			return err
 		}
@@ -459,7 +472,7 @@ func DeleteNetdiskSeasonByItemId(itemId string) error {
 	syncFileIds := []uint{}
 	for _, embyItem := range embyItems {
 		var embyMediaSyncFiles []EmbyMediaSyncFile
-		if err := db.Db.Where("emby_item_id = ?", embyItem.ID).Find(&embyMediaSyncFiles).Error; err != nil {
+		if err := db.Db.Where("item_id = ?", embyItem.ItemId).Find(&embyMediaSyncFiles).Error; err != nil {
 			helpers.AppLogger.Errorf("查询Emby Item %s 关联的EmbyMediaSyncFile记录失败: %v", embyItem.ItemId, err)
 			continue
 		}
@@ -499,6 +512,9 @@ func DeleteNetdiskSeasonByItemId(itemId string) error {
 		case SourceTypeBaiduPan:
 			client := account.GetBaiDuPanClient()
 			_, delErr = deleteBaiduPanFolders(client, seasonPath)
+		case SourceType123:
+			client := account.Get123Client()
+			_, delErr = delete123Folder(client, seasonPath, syncFile.ParentId, syncFile.SyncPathId)
 		}
 		if delErr != nil {
 			helpers.AppLogger.Errorf("删除Emby Item %s 关联的网盘电视剧 季目录 %s失败: %v", itemId, seasonPath, delErr)
@@ -541,7 +557,7 @@ func DeleteNetdiskTvshowByItemId(itemId string) error {
 	syncFileIds := []uint{}
 	for _, embyItem := range embyItems {
 		var embyMediaSyncFiles []EmbyMediaSyncFile
-		if err := db.Db.Where("emby_item_id = ?", embyItem.ItemId).Find(&embyMediaSyncFiles).Error; err != nil {
+		if err := db.Db.Where("item_id = ?", embyItem.ItemId).Find(&embyMediaSyncFiles).Error; err != nil {
 			helpers.AppLogger.Errorf("查询Emby Item %s 关联的EmbyMediaSyncFile记录失败: %v", embyItem.ItemId, err)
 			continue
 		}
@@ -594,6 +610,9 @@ func DeleteNetdiskTvshowByItemId(itemId string) error {
 	case SourceTypeBaiduPan:
 		client := account.GetBaiDuPanClient()
 		_, delErr = deleteBaiduPanFolders(client, tvshowPath)
+	case SourceType123:
+		client := account.Get123Client()
+		_, delErr = delete123Folder(client, tvshowPath, tvshowPathId, syncFile.SyncPathId)
 	}
 	if delErr != nil {
 		helpers.AppLogger.Errorf("删除Emby Item %s 关联的网盘电视剧 目录 %s=>%s失败: %v", itemId, tvshowPathId, tvshowPath, delErr)
@@ -772,7 +791,7 @@ func deleteBaiduPanFiles(client *baidupan.Client, syncFile SyncFile, metaFiles [
 
 func GetLastItemDateCreatedTimeByLibraryID(libraryID string) int64 {
 	var lastItem EmbyMediaItem
-	if err := db.Db.Where("library_id = ?", libraryID).Order("item_id_int DESC").First(&lastItem).Error; err != nil {
+	if err := db.Db.Where("library_id = ?", libraryID).Order("date_created_time DESC").First(&lastItem).Error; err != nil {
 		helpers.AppLogger.Errorf("查询媒体库 %s 最后一个项目失败：%v", libraryID, err)
 	}
 	helpers.AppLogger.Infof("查询媒体库 %s 最后一个项目成功：%d => %d", libraryID, lastItem.ItemIdInt, lastItem.DateCreatedTime)
@@ -802,7 +821,7 @@ func CleanupAllEmbyLibraryData() error {
 	}
 
 	// 清理 emby_media_sync_files
-	if err := tx.Exec("DELETE FROM emby_media_sync_files WHERE emby_item_id IN (SELECT id FROM emby_media_items)").Error; err != nil {
+	if err := tx.Exec("DELETE FROM emby_media_sync_files WHERE item_id IN (SELECT item_id FROM emby_media_items)").Error; err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -850,7 +869,7 @@ func CleanupUnselectedEmbyLibraryData(selectedLibIds []string) error {
 	}
 
 	// 清理 emby_media_sync_files
-	if err := tx.Exec("DELETE FROM emby_media_sync_files WHERE emby_item_id IN (SELECT id FROM emby_media_items WHERE library_id IN ?)", unselectedLibIds).Error; err != nil {
+	if err := tx.Exec("DELETE FROM emby_media_sync_files WHERE item_id IN (SELECT item_id FROM emby_media_items WHERE library_id IN ?)", unselectedLibIds).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -869,3 +888,35 @@ func CleanupUnselectedEmbyLibraryData(selectedLibIds []string) error {
 
 	return tx.Commit().Error
 }
+
+// 删除 123云盘 文件（视频 + 元数据），移入回收站
+func delete123Files(client *open123.Client, syncFile SyncFile, metaFiles []SyncFile) (bool, error) {
+	fileIds := []int64{helpers.StringToInt64(syncFile.FileId)}
+	for _, mf := range metaFiles {
+		fileIds = append(fileIds, helpers.StringToInt64(mf.FileId))
+	}
+	helpers.AppLogger.Infof("准备删除123云盘文件 %s/%s 及 %d 个元数据文件", syncFile.Path, syncFile.FileName, len(metaFiles))
+	if err := client.TrashFiles(context.Background(), fileIds); err != nil {
+		helpers.AppLogger.Errorf("删除123云盘文件失败: %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// 删除 123云盘 目录，移入回收站，不允许删除同步根目录
+func delete123Folder(client *open123.Client, delPath string, delPathId string, syncPathId uint) (bool, error) {
+	if delPath == "" || delPath == "." || delPath == "/" || delPathId == "" || delPathId == "0" {
+		helpers.AppLogger.Errorf("删除123云盘目录失败 - 已到达根目录 %s", delPath)
+		return false, nil
+	}
+	if syncPath := GetSyncPathById(syncPathId); syncPath != nil && syncPath.BaseCid == delPathId {
+		helpers.AppLogger.Errorf("删除123云盘目录失败 - %s 是同步根目录", delPath)
+		return false, nil
+	}
+	helpers.AppLogger.Infof("准备删除123云盘目录 %s => %s", delPathId, delPath)
+	if err := client.TrashFiles(context.Background(), []int64{helpers.StringToInt64(delPathId)}); err != nil {
+		helpers.AppLogger.Errorf("删除123云盘目录 %s 失败: %v", delPath, err)
+		return false, err
+	}
+	return true, nil
+}
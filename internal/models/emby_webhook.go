@@ -50,6 +50,8 @@ type EmbyPlaybackItem struct {
 	EpisodeNumber  int               `json:"IndexNumber,omitempty"`       // 集号（剧集）
 	ImageTags      map[string]string `json:"ImageTags,omitempty"`         // 图片标签
 	ID             string            `json:"Id"`                          // 媒体ID
+	// 海报地址，由媒体服务器客户端生成
+	PrimaryImageUrl string `json:"-"`
 }
 
 // GetUserID 获取用户ID
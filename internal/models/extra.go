@@ -0,0 +1,175 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 花絮、预告片等附属视频的类型，值是Emby/Jellyfin约定的子目录名称
+type ExtraType string
+
+const (
+	ExtraTypeTrailers        ExtraType = "trailers"
+	ExtraTypeFeaturettes     ExtraType = "featurettes"
+	ExtraTypeBehindTheScenes ExtraType = "behind the scenes"
+	ExtraTypeDeletedScenes   ExtraType = "deleted scenes"
+	ExtraTypeInterviews      ExtraType = "interviews"
+	ExtraTypeScenes          ExtraType = "scenes"
+	ExtraTypeShorts          ExtraType = "shorts"
+	ExtraTypeClips           ExtraType = "clips"
+	ExtraTypeSamples         ExtraType = "samples"
+	ExtraTypeExtras          ExtraType = "extras"
+	ExtraTypeOther           ExtraType = "other"
+)
+
+var extraFolderTypes = []ExtraType{
+	ExtraTypeTrailers,
+	ExtraTypeFeaturettes,
+	ExtraTypeBehindTheScenes,
+	ExtraTypeDeletedScenes,
+	ExtraTypeInterviews,
+	ExtraTypeScenes,
+	ExtraTypeShorts,
+	ExtraTypeClips,
+	ExtraTypeSamples,
+	ExtraTypeExtras,
+	ExtraTypeOther,
+}
+
+// 文件名后缀，例如 电影-trailer.mkv，电影.featurette.mkv
+var extraSuffixTypes = map[string]ExtraType{
+	"trailer":         ExtraTypeTrailers,
+	"featurette":      ExtraTypeFeaturettes,
+	"behindthescenes": ExtraTypeBehindTheScenes,
+	"deleted":         ExtraTypeDeletedScenes,
+	"deletedscene":    ExtraTypeDeletedScenes,
+	"interview":       ExtraTypeInterviews,
+	"scene":           ExtraTypeScenes,
+	"short":           ExtraTypeShorts,
+	"clip":            ExtraTypeClips,
+	"sample":          ExtraTypeSamples,
+	"extra":           ExtraTypeExtras,
+	"other":           ExtraTypeOther,
+}
+
+// ClassifyExtra 按文件夹和文件名后缀判断视频是不是附属视频
+// 返回附属视频的类型和正片所在的文件夹，不是附属视频时返回空字符串
+func ClassifyExtra(parentPath, filename string) (ExtraType, string) {
+	folder := strings.ToLower(filepath.Base(parentPath))
+	for _, t := range extraFolderTypes {
+		if folder == string(t) {
+			return t, filepath.Dir(parentPath)
+		}
+	}
+	baseName := strings.ToLower(strings.TrimSuffix(filename, filepath.Ext(filename)))
+	for suffix, t := range extraSuffixTypes {
+		if baseName == suffix || strings.HasSuffix(baseName, "-"+suffix) || strings.HasSuffix(baseName, "."+suffix) || strings.HasSuffix(baseName, "_"+suffix) {
+			return t, parentPath
+		}
+	}
+	return "", ""
+}
+
+var (
+	// 文件名中明确标注的版本，例如 {edition-Director's Cut}
+	explicitEditionRe = regexp.MustCompile(`(?i)\{edition-([^}]+)\}`)
+	editionPatterns   = []struct {
+		re      *regexp.Regexp
+		edition string
+	}{
+		{regexp.MustCompile(`(?i)\bdirector'?s\s?cut\b|导演剪辑版`), "Director's Cut"},
+		{regexp.MustCompile(`(?i)\bextended(\s(edition|cut|version))?\b|加长版`), "Extended Edition"},
+		{regexp.MustCompile(`(?i)\btheatrical(\s(edition|cut|version))?\b|院线版`), "Theatrical Cut"},
+		{regexp.MustCompile(`(?i)\bfinal\scut\b`), "Final Cut"},
+		{regexp.MustCompile(`(?i)\bultimate\s(edition|cut)\b`), "Ultimate Edition"},
+		{regexp.MustCompile(`(?i)\bspecial\sedition\b`), "Special Edition"},
+		{regexp.MustCompile(`(?i)\bcriterion\b`), "Criterion"},
+		{regexp.MustCompile(`(?i)\bunrated\b`), "Unrated"},
+		{regexp.MustCompile(`(?i)\buncut\b|未删减版`), "Uncut"},
+		{regexp.MustCompile(`(?i)\bremastered\b|重制版`), "Remastered"},
+		{regexp.MustCompile(`(?i)\bimax\b`), "IMAX"},
+	}
+)
+
+// ExtractEdition 从文件名中提取电影的版本，例如导演剪辑版、加长版，没有时返回空字符串
+func ExtractEdition(filename string) string {
+	baseName := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if m := explicitEditionRe.FindStringSubmatch(baseName); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	baseName = strings.NewReplacer(".", " ", "_", " ").Replace(baseName)
+	for _, p := range editionPatterns {
+		if p.re.MatchString(baseName) {
+			return p.edition
+		}
+	}
+	return ""
+}
+
+// 版本名称，文件名中没有版本时使用分辨率区分，例如 4K 和 1080p
+func (sm *ScrapeMediaFile) GetEdition() string {
+	if sm.Edition != "" {
+		return sm.Edition
+	}
+	return sm.ResolutionLevel
+}
+
+// 同一个刮削目录中同一部电影的其他版本数量
+func CountMovieVersions(scrapePathId uint, tmdbId int64, excludeId uint) int64 {
+	var total int64
+	if err := db.Db.Model(&ScrapeMediaFile{}).Where("scrape_path_id = ? AND tmdb_id = ? AND id != ? AND extra_type = ''", scrapePathId, tmdbId, excludeId).Count(&total).Error; err != nil {
+		helpers.AppLogger.Errorf("查询电影 %d 的其他版本失败: %v", tmdbId, err)
+		return 0
+	}
+	return total
+}
+
+// 同一个刮削目录中同一部电影已经确定了文件夹名称的其他版本，没有时返回nil
+func GetNamedMovieVersion(scrapePathId uint, tmdbId int64, excludeId uint) *ScrapeMediaFile {
+	var version ScrapeMediaFile
+	statuses := []ScrapeMediaStatus{ScrapeMediaStatusScraped, ScrapeMediaStatusRenaming, ScrapeMediaStatusRenamed}
+	if err := db.Db.Where("scrape_path_id = ? AND tmdb_id = ? AND id != ? AND extra_type = '' AND new_path_name != '' AND status IN ?", scrapePathId, tmdbId, excludeId, statuses).Order("id asc").First(&version).Error; err != nil {
+		return nil
+	}
+	return &version
+}
+
+// 查询待刮削但还没有识别出TMDB ID的电影正片，按ID从afterId之后分批查询
+func GetUnidentifiedMovieFiles(scrapePathId uint, afterId uint, limit int) []*ScrapeMediaFile {
+	var mediaFiles []*ScrapeMediaFile
+	if err := db.Db.Where("scrape_path_id = ? AND media_type = ? AND status = ? AND tmdb_id = 0 AND is_re_scrape = ? AND extra_type = '' AND id > ?", scrapePathId, MediaTypeMovie, ScrapeMediaStatusScanned, false, afterId).Order("id asc").Limit(limit).Find(&mediaFiles).Error; err != nil {
+		helpers.AppLogger.Errorf("查询待识别的电影失败: %v", err)
+		return nil
+	}
+	return DecodeScrapeMediaFile(mediaFiles)
+}
+
+// 查询正片文件夹中等待整理的附属视频
+func GetMovieExtras(scrapePathId uint, ownerPath string) []*ScrapeMediaFile {
+	var extras []*ScrapeMediaFile
+	if err := db.Db.Where("scrape_path_id = ? AND extra_owner_path = ? AND status = ?", scrapePathId, ownerPath, ScrapeMediaStatusExtra).Order("id asc").Find(&extras).Error; err != nil {
+		helpers.AppLogger.Errorf("查询 %s 的附属视频失败: %v", ownerPath, err)
+		return nil
+	}
+	return extras
+}
+
+// 附属视频已经移动到正片的新目录
+func (sm *ScrapeMediaFile) ExtraRenamed(owner *ScrapeMediaFile, newPathId string) {
+	sm.MediaId = owner.MediaId
+	sm.TmdbId = owner.TmdbId
+	sm.Name = owner.Name
+	sm.Year = owner.Year
+	sm.NewPathName = filepath.Join(owner.NewPathName, string(sm.ExtraType))
+	sm.NewPathId = newPathId
+	sm.NewVideoBaseName = strings.TrimSuffix(sm.VideoFilename, filepath.Ext(sm.VideoFilename))
+	sm.VideoExt = filepath.Ext(sm.VideoFilename)
+	sm.CategoryName = owner.CategoryName
+	sm.Status = ScrapeMediaStatusRenamed
+	sm.RenameTime = time.Now().Unix()
+	sm.Save()
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestClassifyExtra(t *testing.T) {
+	tests := []struct {
+		parentPath, filename string
+		extraType            ExtraType
+		ownerPath            string
+	}{
+		{"/电影/沙丘 (2021)", "Dune.2021.2160p.mkv", "", ""},
+		{"/电影/沙丘 (2021)", "Dune-trailer.mp4", ExtraTypeTrailers, "/电影/沙丘 (2021)"},
+		{"/电影/沙丘 (2021)", "Dune.featurette.mkv", ExtraTypeFeaturettes, "/电影/沙丘 (2021)"},
+		{"/电影/沙丘 (2021)", "sample.mkv", ExtraTypeSamples, "/电影/沙丘 (2021)"},
+		{"/电影/沙丘 (2021)/Featurettes", "Making of.mkv", ExtraTypeFeaturettes, "/电影/沙丘 (2021)"},
+		{"/电影/沙丘 (2021)/Behind The Scenes", "Part 1.mkv", ExtraTypeBehindTheScenes, "/电影/沙丘 (2021)"},
+		// 片名以后缀单词结尾但没有分隔符
+		{"/电影/Trailer (2020)", "The Last Scene.mkv", "", ""},
+	}
+	for _, tt := range tests {
+		extraType, ownerPath := ClassifyExtra(tt.parentPath, tt.filename)
+		if extraType != tt.extraType || ownerPath != tt.ownerPath {
+			t.Errorf("%s/%s 分类为 %q %q，预期 %q %q", tt.parentPath, tt.filename, extraType, ownerPath, tt.extraType, tt.ownerPath)
+		}
+	}
+}
+
+func TestExtractEdition(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{"Blade.Runner.1982.Final.Cut.2160p.mkv", "Final Cut"},
+		{"Aliens.1986.Directors.Cut.1080p.mkv", "Director's Cut"},
+		{"指环王：护戒使者 (2001) 加长版.mkv", "Extended Edition"},
+		{"Dune (2021) {edition-IMAX Enhanced}.mkv", "IMAX Enhanced"},
+		{"Dune.2021.2160p.mkv", ""},
+	}
+	for _, tt := range tests {
+		if result := ExtractEdition(tt.filename); result != tt.expected {
+			t.Errorf("%s 的版本 %q 与预期 %q 不符", tt.filename, result, tt.expected)
+		}
+	}
+	sm := &ScrapeMediaFile{Name: "沙丘", Year: 2021, ResolutionLevel: "4K"}
+	if name := sm.GenerateNameByTemplate("{title} ({year}) - {edition}"); name != "沙丘 (2021) - 4K" {
+		t.Errorf("没有版本时应该使用分辨率: %s", name)
+	}
+	sm.Edition = "Director's Cut"
+	if name := sm.GenerateNameByTemplate("{{title}} ({{year}}) - {{edition}}"); name != "沙丘 (2021) - Director's Cut" {
+		t.Errorf("版本名称错误: %s", name)
+	}
+}
+
+func TestMovieVersionQueries(t *testing.T) {
+	helpers.AppLogger = &helpers.QLogger{Logger: log.New(io.Discard, "", 0)}
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := gdb.AutoMigrate(&ScrapeMediaFile{}); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+	db.Db = gdb
+	files := []*ScrapeMediaFile{
+		// 上次整理的版本保留了原文件夹名称
+		{ScrapePathId: 1, MediaType: MediaTypeMovie, TmdbId: 27205, Status: ScrapeMediaStatusRenamed, NewPathName: "Inception.2010.1080p"},
+		{ScrapePathId: 1, MediaType: MediaTypeMovie, TmdbId: 27205, Status: ScrapeMediaStatusScanned},
+		{ScrapePathId: 1, MediaType: MediaTypeMovie, Status: ScrapeMediaStatusScanned},
+		{ScrapePathId: 1, MediaType: MediaTypeMovie, Status: ScrapeMediaStatusScanned, ExtraType: ExtraTypeTrailers},
+		{ScrapePathId: 1, MediaType: MediaTypeMovie, Status: ScrapeMediaStatusScanned},
+	}
+	for _, file := range files {
+		db.Db.Create(file)
+	}
+	if version := GetNamedMovieVersion(1, 27205, files[1].ID); version == nil || version.ID != files[0].ID {
+		t.Errorf("GetNamedMovieVersion() = %+v; want id %d", version, files[0].ID)
+	}
+	if version := GetNamedMovieVersion(1, 27205, files[0].ID); version != nil {
+		t.Errorf("还没有命名的版本不应该返回: %+v", version)
+	}
+	unidentified := GetUnidentifiedMovieFiles(1, 0, 1)
+	if len(unidentified) != 1 || unidentified[0].ID != files[2].ID {
+		t.Fatalf("GetUnidentifiedMovieFiles() = %+v; want id %d", unidentified, files[2].ID)
+	}
+	// 附属视频不单独识别
+	unidentified = GetUnidentifiedMovieFiles(1, unidentified[0].ID, 10)
+	if len(unidentified) != 1 || unidentified[0].ID != files[4].ID {
+		t.Errorf("GetUnidentifiedMovieFiles() = %+v; want id %d", unidentified, files[4].ID)
+	}
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/notificationmanager"
+	"context"
+	"fmt"
+	"time"
+)
+
+// 网盘文件副本，同一部电影或者同一集在多个网盘或者多个账号中的文件可以在播放失败时互相替代
+// sha1相同，或者TMDB ID、季、集相同并且文件大小相同的文件认为是等价副本
+type FileCopy struct {
+	BaseModel
+	PickCode      string     `json:"pick_code" gorm:"uniqueIndex:idx_file_copy_pick_code"` // 115的pickcode或者百度网盘的fsid
+	SourceType    SourceType `json:"source_type"`
+	AccountId     uint       `json:"account_id"`
+	SyncFileId    uint       `json:"sync_file_id"`
+	FileName      string     `json:"file_name"`
+	FileSize      int64      `json:"file_size"`
+	Sha1          string     `json:"sha1" gorm:"index:idx_file_copy_sha1"`
+	MediaType     MediaType  `json:"media_type"`
+	TmdbId        int64      `json:"tmdb_id" gorm:"index:idx_file_copy_tmdb"` // 没有刮削记录时为0
+	SeasonNumber  int        `json:"season_number"`
+	EpisodeNumber int        `json:"episode_number"`
+	Dead          bool       `json:"dead"`        // 最近一次获取下载链接失败
+	DeadReason    string     `json:"dead_reason"` // 获取下载链接失败的原因
+	DeadTime      int64      `json:"dead_time"`   // 标记失效的时间
+}
+
+func (*FileCopy) TableName() string {
+	return "file_copies"
+}
+
+// 只查询已记录的副本，不存在时返回nil
+func GetFileCopyByPickCode(pickCode string) *FileCopy {
+	if pickCode == "" {
+		return nil
+	}
+	var fc FileCopy
+	if err := db.Db.Where("pick_code = ?", pickCode).First(&fc).Error; err != nil {
+		return nil
+	}
+	return &fc
+}
+
+// 查询副本，还没有记录时通过同步文件和刮削记录生成，不是同步的网盘文件时返回nil
+func GetFileCopy(pickCode string) *FileCopy {
+	if fc := GetFileCopyByPickCode(pickCode); fc != nil {
+		return fc
+	}
+	return RecordFileCopy(pickCode)
+}
+
+// 记录或者更新副本的文件和媒体信息，保留失效状态
+func RecordFileCopy(pickCode string) *FileCopy {
+	syncFile := GetFileByPickCode(pickCode)
+	if syncFile == nil {
+		return nil
+	}
+	fc := GetFileCopyByPickCode(pickCode)
+	if fc == nil {
+		fc = &FileCopy{PickCode: pickCode}
+	}
+	fc.SourceType = syncFile.SourceType
+	fc.AccountId = syncFile.AccountId
+	fc.SyncFileId = syncFile.ID
+	fc.FileName = syncFile.FileName
+	fc.FileSize = syncFile.FileSize
+	fc.Sha1 = syncFile.Sha1
+	fc.fillMedia()
+	if err := db.Db.Save(fc).Error; err != nil {
+		helpers.AppLogger.Errorf("记录网盘文件副本失败: pickcode=%s %v", pickCode, err)
+		return nil
+	}
+	return fc
+}
+
+// 通过刮削记录填充TMDB ID和季、集，以最后一次刮削为准
+func (fc *FileCopy) fillMedia() {
+	var sm ScrapeMediaFile
+	err := db.Db.Where("video_pick_code = ? AND status IN ?", fc.PickCode, []ScrapeMediaStatus{ScrapeMediaStatusScraped, ScrapeMediaStatusRenaming, ScrapeMediaStatusRenamed}).
+		Order("id DESC").First(&sm).Error
+	if err != nil {
+		return
+	}
+	fc.MediaType = sm.MediaType
+	fc.TmdbId = sm.TmdbId
+	fc.SeasonNumber = sm.SeasonNumber
+	fc.EpisodeNumber = sm.EpisodeNumber
+	if sm.MediaId > 0 {
+		if media, err := GetMediaById(sm.MediaId); err == nil && media.TmdbId > 0 {
+			fc.TmdbId = media.TmdbId
+		}
+	}
+}
+
+// 查询可以替代的健康副本，同时记录还没有记录过的等价文件
+func (fc *FileCopy) Alternates() []*FileCopy {
+	fc.recordEquivalents()
+	query := db.Db.Where("pick_code != ? AND dead = ?", fc.PickCode, false)
+	switch {
+	case fc.Sha1 != "" && fc.TmdbId > 0:
+		query = query.Where("sha1 = ? OR (tmdb_id = ? AND media_type = ? AND season_number = ? AND episode_number = ? AND file_size = ?)",
+			fc.Sha1, fc.TmdbId, fc.MediaType, fc.SeasonNumber, fc.EpisodeNumber, fc.FileSize)
+	case fc.Sha1 != "":
+		query = query.Where("sha1 = ?", fc.Sha1)
+	case fc.TmdbId > 0:
+		query = query.Where("tmdb_id = ? AND media_type = ? AND season_number = ? AND episode_number = ? AND file_size = ?",
+			fc.TmdbId, fc.MediaType, fc.SeasonNumber, fc.EpisodeNumber, fc.FileSize)
+	default:
+		return nil
+	}
+	var copies []*FileCopy
+	if err := query.Order("id ASC").Find(&copies).Error; err != nil {
+		helpers.AppLogger.Errorf("查询网盘文件副本失败: pickcode=%s %v", fc.PickCode, err)
+		return nil
+	}
+	// 同步文件已经删除的副本不再使用
+	alternates := make([]*FileCopy, 0, len(copies))
+	for _, cp := range copies {
+		if GetFileByPickCode(cp.PickCode) == nil {
+			cp.delete()
+			continue
+		}
+		alternates = append(alternates, cp)
+	}
+	return alternates
+}
+
+// 记录sha1相同的同步文件和同一部电影或者同一集的刮削记录，功能上线前同步和刮削的文件也能找到副本
+func (fc *FileCopy) recordEquivalents() {
+	pickCodes := make([]string, 0)
+	if fc.Sha1 != "" {
+		var codes []string
+		db.Db.Model(&SyncFile{}).Where("sha1 = ? AND pick_code != ? AND pick_code != ''", fc.Sha1, fc.PickCode).Distinct().Pluck("pick_code", &codes)
+		pickCodes = append(pickCodes, codes...)
+	}
+	if fc.TmdbId > 0 {
+		var codes []string
+		mediaIds := db.Db.Model(&Media{}).Select("id").Where("tmdb_id = ? AND media_type = ?", fc.TmdbId, fc.MediaType)
+		db.Db.Model(&ScrapeMediaFile{}).
+			Where("media_type = ? AND season_number = ? AND episode_number = ?", fc.MediaType, fc.SeasonNumber, fc.EpisodeNumber).
+			Where("tmdb_id = ? OR media_id IN (?)", fc.TmdbId, mediaIds).
+			Where("video_pick_code != ? AND video_pick_code != ''", fc.PickCode).
+			Distinct().Pluck("video_pick_code", &codes)
+		pickCodes = append(pickCodes, codes...)
+	}
+	for _, pickCode := range pickCodes {
+		GetFileCopy(pickCode)
+	}
+}
+
+// 标记副本失效，从健康变为失效时发送通知
+func (fc *FileCopy) MarkDead(reason string, alternate *FileCopy) {
+	wasDead := fc.Dead
+	fc.Dead = true
+	fc.DeadReason = reason
+	fc.DeadTime = time.Now().Unix()
+	err := db.Db.Model(fc).Updates(map[string]any{"dead": fc.Dead, "dead_reason": fc.DeadReason, "dead_time": fc.DeadTime}).Error
+	if err != nil {
+		helpers.AppLogger.Errorf("标记网盘文件副本失效失败: pickcode=%s %v", fc.PickCode, err)
+		return
+	}
+	if !wasDead {
+		go fc.notifyDead(alternate)
+	}
+}
+
+// 重新获取到下载链接后恢复副本状态
+func (fc *FileCopy) MarkHealthy() {
+	if !fc.Dead {
+		return
+	}
+	fc.Dead = false
+	fc.DeadReason = ""
+	fc.DeadTime = 0
+	err := db.Db.Model(fc).Updates(map[string]any{"dead": false, "dead_reason": "", "dead_time": 0}).Error
+	if err != nil {
+		helpers.AppLogger.Errorf("恢复网盘文件副本状态失败: pickcode=%s %v", fc.PickCode, err)
+		return
+	}
+	helpers.AppLogger.Infof("网盘文件已恢复播放: %s pickcode=%s", fc.FileName, fc.PickCode)
+}
+
+// 最近失效的副本播放时先尝试其他副本
+func (fc *FileCopy) RecentlyDead(within time.Duration) bool {
+	return fc.Dead && time.Since(time.Unix(fc.DeadTime, 0)) < within
+}
+
+// 发送网盘文件失效通知
+func (fc *FileCopy) notifyDead(alternate *FileCopy) {
+	if notificationmanager.GlobalEnhancedNotificationManager == nil {
+		return
+	}
+	accountName := fmt.Sprintf("%d", fc.AccountId)
+	if account, err := GetAccountById(fc.AccountId); err == nil {
+		accountName = account.Name
+	}
+	fallback := "没有可以替代的健康副本"
+	if alternate != nil {
+		fallback = fmt.Sprintf("已自动切换到副本：%s (%s)", alternate.FileName, alternate.SourceType)
+	}
+	notif := &Notification{
+		Type:      SourceUnavailable,
+		Title:     fmt.Sprintf("⚠️ %s 无法播放", fc.FileName),
+		Content:   fmt.Sprintf("网盘：%s\n账号：%s\n原因：%s\n%s\n⏰ 时间: %s", fc.SourceType, accountName, fc.DeadReason, fallback, time.Now().Format("2006-01-02 15:04:05")),
+		Timestamp: time.Now(),
+		Priority:  HighPriority,
+	}
+	if err := notificationmanager.GlobalEnhancedNotificationManager.SendNotification(context.Background(), notif); err != nil {
+		helpers.AppLogger.Errorf("发送网盘文件失效通知失败: %v", err)
+	}
+}
+
+func (fc *FileCopy) delete() {
+	if err := db.Db.Delete(fc).Error; err != nil {
+		helpers.AppLogger.Errorf("删除网盘文件副本失败: pickcode=%s %v", fc.PickCode, err)
+	}
+}
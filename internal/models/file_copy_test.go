@@ -0,0 +1,97 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupFileCopyDb(t *testing.T) {
+	helpers.AppLogger = &helpers.QLogger{Logger: log.New(io.Discard, "", 0)}
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := gdb.AutoMigrate(&SyncFile{}, &ScrapeMediaFile{}, &Media{}, &FileCopy{}); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+	db.Db = gdb
+	media := &Media{TmdbId: 10, MediaType: MediaTypeMovie}
+	db.Db.Create(media)
+	files := []*SyncFile{
+		{SourceType: SourceType115, AccountId: 1, FileName: "A.mkv", FileSize: 100, PickCode: "a", Sha1: "S1"},
+		{SourceType: SourceTypeBaiduPan, AccountId: 2, FileName: "B.mkv", FileSize: 100, PickCode: "b"},
+		{SourceType: SourceType115, AccountId: 3, FileName: "C.mkv", FileSize: 100, PickCode: "c", Sha1: "S1"},
+		// 大小不同的版本和同一个TMDB ID的电视剧都不是副本
+		{SourceType: SourceType115, AccountId: 1, FileName: "D.mkv", FileSize: 200, PickCode: "d"},
+		{SourceType: SourceType115, AccountId: 1, FileName: "E.mkv", FileSize: 100, PickCode: "e"},
+	}
+	for _, file := range files {
+		db.Db.Create(file)
+	}
+	scraped := []*ScrapeMediaFile{
+		{MediaType: MediaTypeMovie, MediaId: media.ID, VideoPickCode: "a", Status: ScrapeMediaStatusRenamed},
+		{MediaType: MediaTypeMovie, TmdbId: 10, VideoPickCode: "b", Status: ScrapeMediaStatusScraped},
+		{MediaType: MediaTypeMovie, TmdbId: 10, VideoPickCode: "d", Status: ScrapeMediaStatusScraped},
+		{MediaType: MediaTypeTvShow, TmdbId: 10, SeasonNumber: 1, EpisodeNumber: 1, VideoPickCode: "e", Status: ScrapeMediaStatusScraped},
+	}
+	for _, sm := range scraped {
+		db.Db.Create(sm)
+	}
+}
+
+func alternatePickCodes(fc *FileCopy) []string {
+	codes := make([]string, 0)
+	for _, cp := range fc.Alternates() {
+		codes = append(codes, cp.PickCode)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+func TestFileCopyAlternates(t *testing.T) {
+	setupFileCopyDb(t)
+	fc := GetFileCopy("a")
+	if fc == nil || fc.TmdbId != 10 || fc.MediaType != MediaTypeMovie || fc.Sha1 != "S1" || fc.AccountId != 1 {
+		t.Fatalf("GetFileCopy(a) = %+v", fc)
+	}
+	if got := alternatePickCodes(fc); !slices.Equal(got, []string{"b", "c"}) {
+		t.Errorf("alternates = %v; want [b c]", got)
+	}
+
+	// 失效的副本不再作为替代
+	c := GetFileCopyByPickCode("c")
+	c.MarkDead("获取115下载链接失败", nil)
+	if got := alternatePickCodes(fc); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("alternates after c dead = %v; want [b]", got)
+	}
+	if c = GetFileCopyByPickCode("c"); !c.Dead || !c.RecentlyDead(time.Minute) || c.DeadReason == "" {
+		t.Errorf("c = %+v; want dead", c)
+	}
+	c.MarkHealthy()
+	if c = GetFileCopyByPickCode("c"); c.Dead || c.RecentlyDead(time.Minute) {
+		t.Errorf("c = %+v; want healthy", c)
+	}
+
+	// 同步文件删除后副本记录也删除
+	db.Db.Where("pick_code = ?", "b").Delete(&SyncFile{})
+	if got := alternatePickCodes(fc); !slices.Equal(got, []string{"c"}) {
+		t.Errorf("alternates after b removed = %v; want [c]", got)
+	}
+	if GetFileCopyByPickCode("b") != nil {
+		t.Error("copy of removed sync file should be deleted")
+	}
+
+	if GetFileCopy("missing") != nil {
+		t.Error("GetFileCopy of unknown pickcode should be nil")
+	}
+}
@@ -0,0 +1,269 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// 识别结果的可信度权重，缺少的信号不参与计算
+const (
+	matchTitleWeight   = 0.6
+	matchYearWeight    = 0.25
+	matchRuntimeWeight = 0.15
+	matchMaxCandidates = 5   // 待确认时最多保存的候选数量
+	matchAmbiguityGap  = 0.1 // 第二名和第一名的差距小于这个值时降低可信度
+)
+
+// 识别结果可信度低于刮削目录的阈值时返回，记录已经放入待确认队列，不是刮削失败
+var ErrScrapeNeedsReview = errors.New("识别结果可信度过低，已放入待确认队列")
+
+// 识别的候选条目
+type MatchCandidate struct {
+	TmdbId       int64   `json:"tmdb_id"`       // TMDB ID
+	Name         string  `json:"name"`          // 名称
+	OriginalName string  `json:"original_name"` // 原始名称
+	Year         int     `json:"year"`          // 年份
+	Runtime      int64   `json:"runtime"`       // 时长，单位：分钟，0表示未知
+	Overview     string  `json:"overview"`      // 简介
+	PosterPath   string  `json:"poster_path"`   // 海报
+	Confidence   float64 `json:"confidence"`    // 可信度，0-1
+}
+
+// 去掉大小写、空白和标点后再比较
+func normalizeMatchTitle(s string) []rune {
+	runes := make([]rune, 0, len(s))
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// TitleSimilarity 用编辑距离计算两个标题的相似度，0-1
+func TitleSimilarity(a, b string) float64 {
+	ra, rb := normalizeMatchTitle(a), normalizeMatchTitle(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// 年份相同为1，相差一年（上映日期和首映日期经常不一致）为0.7，相差两年为0.3
+func yearScore(a, b int) float64 {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	switch d {
+	case 0:
+		return 1
+	case 1:
+		return 0.7
+	case 2:
+		return 0.3
+	}
+	return 0
+}
+
+// 时长相差10%以内为1，25%以内为0.5
+func runtimeScore(runtime int64, duration int64) float64 {
+	diff := float64(runtime-duration) / float64(max(runtime, duration))
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff <= 0.1 {
+		return 1
+	}
+	if diff <= 0.25 {
+		return 0.5
+	}
+	return 0
+}
+
+// Score 按标题相似度、年份差和时长计算候选条目的可信度
+// name、year是从文件名提取的信息，duration是ffprobe提取的时长（分钟），为0表示未知
+func (c *MatchCandidate) Score(name string, year int, duration int64) float64 {
+	score := max(TitleSimilarity(name, c.Name), TitleSimilarity(name, c.OriginalName)) * matchTitleWeight
+	weight := matchTitleWeight
+	if year > 0 && c.Year > 0 {
+		score += yearScore(year, c.Year) * matchYearWeight
+		weight += matchYearWeight
+	}
+	if duration > 0 && c.Runtime > 0 {
+		score += runtimeScore(c.Runtime, duration) * matchRuntimeWeight
+		weight += matchRuntimeWeight
+	}
+	c.Confidence = score / weight
+	return c.Confidence
+}
+
+// ScoreMatchCandidates 计算所有候选条目的可信度并按可信度从高到低排序
+// 返回tmdbId对应条目的可信度，tmdbId为0时返回第一名的可信度；和其他候选差距太小时会降低可信度
+func ScoreMatchCandidates(candidates []*MatchCandidate, name string, year int, duration int64, tmdbId int64) float64 {
+	if len(candidates) == 0 {
+		return 0
+	}
+	for _, c := range candidates {
+		c.Score(name, year, duration)
+	}
+	slices.SortStableFunc(candidates, func(a, b *MatchCandidate) int {
+		if a.Confidence > b.Confidence {
+			return -1
+		}
+		if a.Confidence < b.Confidence {
+			return 1
+		}
+		return 0
+	})
+	chosen := candidates[0]
+	if tmdbId != 0 {
+		chosen = nil
+		for _, c := range candidates {
+			if c.TmdbId == tmdbId {
+				chosen = c
+				break
+			}
+		}
+		if chosen == nil {
+			return 0
+		}
+	}
+	confidence := chosen.Confidence
+	for _, c := range candidates {
+		if c == chosen {
+			continue
+		}
+		if gap := confidence - c.Confidence; gap < matchAmbiguityGap {
+			confidence -= matchAmbiguityGap - gap
+		}
+		break
+	}
+	return max(confidence, 0)
+}
+
+// 识别结果可信度是否低于刮削目录的阈值，阈值为0表示不检查
+func (sp *ScrapePath) NeedsReview(confidence float64) bool {
+	return sp.ReviewThreshold > 0 && confidence < sp.ReviewThreshold
+}
+
+// 放入待确认队列，电视剧会把同一批次的所有集都放入队列
+func (sm *ScrapeMediaFile) NeedsReview(candidates []*MatchCandidate, confidence float64) error {
+	if len(candidates) > matchMaxCandidates {
+		candidates = candidates[:matchMaxCandidates]
+	}
+	sm.MatchCandidates = candidates
+	sm.MatchCandidatesJson = helpers.JsonString(candidates)
+	sm.MatchConfidence = confidence
+	sm.Status = ScrapeMediaStatusNeedsReview
+	sm.FailedReason = fmt.Sprintf("识别结果可信度 %.2f 低于阈值，需要手工确认", confidence)
+	sm.ScrapeTime = time.Now().Unix()
+	updateData := map[string]any{
+		"match_candidates_json": sm.MatchCandidatesJson,
+		"match_confidence":      sm.MatchConfidence,
+		"status":                sm.Status,
+		"failed_reason":         sm.FailedReason,
+		"scrape_time":           sm.ScrapeTime,
+		"name":                  sm.Name,
+		"year":                  sm.Year,
+	}
+	tx := db.Db.Model(&ScrapeMediaFile{})
+	if sm.MediaType == MediaTypeTvShow && sm.TvshowPathId != "" {
+		tx = tx.Where("(id = ? OR (tvshow_path_id = ? AND batch_no = ?)) AND status IN ?", sm.ID, sm.TvshowPathId, sm.BatchNo, []ScrapeMediaStatus{ScrapeMediaStatusScanned, ScrapeMediaStatusScraping})
+	} else {
+		tx = tx.Where("id = ?", sm.ID)
+	}
+	if err := tx.Updates(updateData).Error; err != nil {
+		helpers.AppLogger.Errorf("放入待确认队列失败: id=%d %v", sm.ID, err)
+		return err
+	}
+	helpers.AppLogger.Infof("文件 %s 的识别结果可信度 %.2f 过低，已放入待确认队列，候选数量 %d", sm.VideoFilename, confidence, len(candidates))
+	return ErrScrapeNeedsReview
+}
+
+// 确认识别结果，写入TMDB ID后改为待刮削，下次刮削时继续刮削和整理流程
+func (sm *ScrapeMediaFile) AcceptMatch(tmdbId int64, name string, year int) error {
+	if sm.Status != ScrapeMediaStatusNeedsReview {
+		return errors.New("记录不在待确认队列中")
+	}
+	updateData := map[string]any{
+		"tmdb_id":               tmdbId,
+		"name":                  helpers.CleanFileName(name),
+		"year":                  year,
+		"status":                ScrapeMediaStatusScanned,
+		"failed_reason":         "",
+		"match_candidates_json": "",
+		"match_confidence":      1,
+	}
+	tx := db.Db.Model(&ScrapeMediaFile{})
+	if sm.MediaType == MediaTypeTvShow && sm.TvshowPathId != "" {
+		tx = tx.Where("(id = ? OR (tvshow_path_id = ? AND batch_no = ?)) AND status = ?", sm.ID, sm.TvshowPathId, sm.BatchNo, ScrapeMediaStatusNeedsReview)
+	} else {
+		tx = tx.Where("id = ?", sm.ID)
+	}
+	if err := tx.Updates(updateData).Error; err != nil {
+		helpers.AppLogger.Errorf("确认识别结果失败: id=%d %v", sm.ID, err)
+		return err
+	}
+	sm.TmdbId = tmdbId
+	sm.Name = updateData["name"].(string)
+	sm.Year = year
+	sm.Status = ScrapeMediaStatusScanned
+	sm.FailedReason = ""
+	sm.MatchCandidates = nil
+	sm.MatchCandidatesJson = ""
+	sm.MatchConfidence = 1
+	return nil
+}
+
+// 分页查询待确认队列，scrapePathIds为nil时查询所有刮削目录
+func GetNeedsReviewScrapeMediaFiles(page int, pageSize int, mediaType string, scrapePathIds []uint) (int64, []*ScrapeMediaFile) {
+	query := func() *gorm.DB {
+		tx := db.Db.Model(&ScrapeMediaFile{}).Where("status = ?", ScrapeMediaStatusNeedsReview)
+		if mediaType != "" {
+			tx = tx.Where("media_type = ?", mediaType)
+		}
+		if scrapePathIds != nil {
+			tx = tx.Where("scrape_path_id IN ?", scrapePathIds)
+		}
+		return tx
+	}
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		helpers.AppLogger.Errorf("查询待确认记录总数失败: %v", err)
+		return 0, nil
+	}
+	var scrapeMediaFiles []*ScrapeMediaFile
+	if err := query().Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&scrapeMediaFiles).Error; err != nil {
+		helpers.AppLogger.Errorf("查询待确认记录失败: %v", err)
+		return 0, nil
+	}
+	for _, sm := range scrapeMediaFiles {
+		sm.DecodeJson()
+	}
+	return total, scrapeMediaFiles
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected float64
+	}{
+		{"The Matrix", "the matrix", 1},
+		{"蝙蝠侠：黑暗骑士", "蝙蝠侠 黑暗骑士", 1},
+		{"Dune", "Dune Part Two", 4.0 / 11},
+		{"", "Dune", 0},
+	}
+	for _, tt := range tests {
+		if result := TitleSimilarity(tt.a, tt.b); math.Abs(result-tt.expected) > 0.001 {
+			t.Errorf("%s 和 %s 的相似度 %.3f 与预期 %.3f 不符", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+func TestScoreMatchCandidates(t *testing.T) {
+	newCandidates := func() []*MatchCandidate {
+		return []*MatchCandidate{
+			{TmdbId: 1, Name: "沙丘2", OriginalName: "Dune: Part Two", Year: 2024, Runtime: 167},
+			{TmdbId: 2, Name: "沙丘", OriginalName: "Dune", Year: 2021, Runtime: 155},
+			{TmdbId: 3, Name: "沙丘", OriginalName: "Dune", Year: 1984, Runtime: 137},
+		}
+	}
+	// 标题、年份和时长都一致
+	candidates := newCandidates()
+	confidence := ScoreMatchCandidates(candidates, "Dune", 2021, 156, 0)
+	if candidates[0].TmdbId != 2 || confidence < 0.9 {
+		t.Errorf("应该选择2021年的沙丘, 实际 %d 可信度 %.2f", candidates[0].TmdbId, confidence)
+	}
+	// 没有年份和时长时同名的两部无法区分，可信度降低
+	candidates = newCandidates()
+	confidence = ScoreMatchCandidates(candidates, "Dune", 0, 0, 0)
+	if confidence > 0.95 || candidates[0].Confidence != 1 {
+		t.Errorf("同名候选的可信度应该降低: %.2f", confidence)
+	}
+	// 自动识别的结果年份相差一年
+	candidates = newCandidates()
+	fuzzy := ScoreMatchCandidates(candidates, "Dune", 2022, 0, 2)
+	exact := ScoreMatchCandidates(newCandidates(), "Dune", 2021, 0, 2)
+	if fuzzy >= exact {
+		t.Errorf("年份不一致时可信度应该更低: %.2f >= %.2f", fuzzy, exact)
+	}
+	// 自动识别的结果不在候选中
+	if confidence := ScoreMatchCandidates(newCandidates(), "Dune", 2021, 0, 4); confidence != 0 {
+		t.Errorf("不在候选中的结果可信度应该为0: %.2f", confidence)
+	}
+	sp := &ScrapePath{ReviewThreshold: 0.8}
+	if !sp.NeedsReview(0.5) || sp.NeedsReview(0.8) {
+		t.Errorf("阈值判断错误")
+	}
+	if (&ScrapePath{}).NeedsReview(0) {
+		t.Errorf("没有设置阈值时不应该放入待确认队列")
+	}
+}
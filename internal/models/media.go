@@ -3,9 +3,11 @@ package models
 import (
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
 	"Q115-STRM/internal/tmdb"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -27,7 +29,7 @@ type Media struct {
 	Name                string             `json:"name" gorm:"index:nameyear"`               // TMDB名称
 	Year                int                `json:"year" gorm:"index:nameyear"`               // 年份
 	OriginalName        string             `json:"original_title"`                           // 原始标题
-	MediaType           MediaType          `json:"media_type"`                               // 媒体类型: movie-电影 tvshow-电视剧
+	MediaType           MediaType          `json:"media_type"`                               // 媒体类型: movie-电影 tvshow-电视剧 music-音乐
 	ReleaseDate         string             `json:"release_date"`                             // 上映时间，剧集为首播时间
 	Actors              []helpers.Actor    `json:"actors" gorm:"-"`                          // 演员列表
 	ActorsJson          string             `json:"-" gorm:"type:text"`                       // 演员列表JSON字符串
@@ -63,6 +65,16 @@ type Media struct {
 	Status              MediaStatus        `gorm:"index" json:"status"`                      // 状态
 	SubtitleFiles       []*MediaMetaFiles  `json:"subtitle_files" gorm:"-"`                  // 整理后的字幕文件列表
 	SubtitleFileJson    string             `json:"-"`                                        // SubtitleFiles的JSON字符串
+	ProviderIds         map[string]string  `json:"provider_ids" gorm:"-"`                    // 各元数据提供者的ID，key为提供者名称
+	ProviderIdsJson     string             `json:"-"`                                        // ProviderIds的JSON字符串
+	Artist              string             `json:"artist"`                                   // 音乐：专辑艺术家
+	Album               string             `json:"album"`                                    // 音乐：专辑名称
+	TrackNumber         int                `json:"track_number"`                             // 音乐：曲目编号
+	DiscNumber          int                `json:"disc_number"`                              // 音乐：碟片编号
+	MusicBrainzAlbumId  string             `json:"musicbrainz_album_id"`                     // 音乐：MusicBrainz Release ID
+	MusicBrainzTrackId  string             `json:"musicbrainz_track_id"`                     // 音乐：MusicBrainz Recording ID
+	CollectionId        int64              `json:"collection_id" gorm:"index"`               // 电影：所属的TMDB合集ID，0表示不属于合集
+	CollectionName      string             `json:"collection_name"`                          // 电影：所属的合集名称
 }
 
 // 刮削好数据的集
@@ -116,6 +128,7 @@ func (m *Media) Save() error {
 	m.OriginalCountryJson = helpers.JsonString(m.OriginCountry)
 	m.GenresJson = helpers.JsonString(m.Genres)
 	m.SubtitleFileJson = helpers.JsonString(m.SubtitleFiles)
+	m.ProviderIdsJson = helpers.JsonString(m.ProviderIds)
 	// 保存到数据库
 	err := db.Db.Save(m).Error
 	if err != nil {
@@ -147,6 +160,13 @@ func (m *Media) DecodeJson() {
 		helpers.AppLogger.Warnf("解码SubtitleFileJson失败: %v", err)
 		m.SubtitleFiles = []*MediaMetaFiles{}
 	}
+	// 旧数据没有这个字段
+	m.ProviderIds = map[string]string{}
+	if m.ProviderIdsJson != "" {
+		if err := json.Unmarshal([]byte(m.ProviderIdsJson), &m.ProviderIds); err != nil {
+			helpers.AppLogger.Warnf("解码ProviderIdsJson失败: %v", err)
+		}
+	}
 }
 
 func (m *Media) UpdateSeasonCount(i int) {
@@ -225,6 +245,12 @@ func (m *Media) FillInfoByTmdbInfo(tmdbInfo *TmdbInfo) {
 		m.VoteCount = tmdbInfo.MovieDetail.VoteCount
 		m.OriginalLanguage = tmdbInfo.MovieDetail.OriginalLanguage
 		m.ImdbId = tmdbInfo.MovieDetail.ImdbID
+		m.CollectionId = 0
+		m.CollectionName = ""
+		if tmdbInfo.MovieDetail.BelongsToCollection != nil {
+			m.CollectionId = tmdbInfo.MovieDetail.BelongsToCollection.ID
+			m.CollectionName = tmdbInfo.MovieDetail.BelongsToCollection.Name
+		}
 		// 提取分级信息
 		for _, releaseDate := range tmdbInfo.ReleasesDate {
 			if releaseDate.ISO_3166_1 == "US" {
@@ -294,10 +320,109 @@ func (m *Media) FillInfoByTmdbInfo(tmdbInfo *TmdbInfo) {
 			}
 		}
 	}
+	m.fillInfoByProviders(tmdbInfo)
 	m.Status = MediaStatusScraped
 	m.Save()
 }
 
+// 合并其他元数据提供者的信息
+// 主提供者不是TMDB时用主提供者的标题、简介和评分覆盖TMDB的值，其他提供者只补充空字段
+// 流派保留TMDB的值，二级分类依赖TMDB的流派ID
+func (m *Media) fillInfoByProviders(tmdbInfo *TmdbInfo) {
+	details := tmdbInfo.ProviderDetails
+	if len(details) > 0 && tmdbInfo.PrimaryProvider != metadata.ProviderTmdb && details[0].Provider == tmdbInfo.PrimaryProvider {
+		primary := details[0]
+		if primary.Title != "" {
+			m.Name = primary.Title
+		}
+		if overview := strings.TrimSpace(primary.Overview); overview != "" {
+			m.Overview = overview
+		}
+		if primary.Rating > 0 {
+			m.VoteAverage = primary.Rating
+			m.VoteCount = primary.VoteCount
+		}
+	}
+	merged := metadata.Merge(details...)
+	if m.Overview == "" {
+		m.Overview = merged.Overview
+	}
+	if m.VoteAverage == 0 {
+		m.VoteAverage = merged.Rating
+		m.VoteCount = merged.VoteCount
+	}
+	if m.PosterPath == "" {
+		m.PosterPath = merged.PosterUrl
+	}
+	if m.BackdropPath == "" {
+		m.BackdropPath = merged.BackdropUrl
+	}
+	ids := map[string]string{metadata.ProviderTmdb: fmt.Sprintf("%d", m.TmdbId)}
+	if m.ImdbId != "" {
+		ids[metadata.ProviderImdb] = m.ImdbId
+	}
+	for k, v := range merged.Ids {
+		if _, ok := ids[k]; !ok {
+			ids[k] = v
+		}
+	}
+	if m.ImdbId == "" {
+		m.ImdbId = ids[metadata.ProviderImdb]
+	}
+	m.ProviderIds = ids
+}
+
+// NFO中的uniqueid，每个提供者一条，跳过空ID
+// 主提供者的ID为默认值；主提供者是TMDB时和以前一样优先使用IMDB ID作为默认值
+func (m *Media) UniqueIds(primary string) []helpers.UniqueId {
+	ids := make(map[string]string, len(m.ProviderIds)+2)
+	for k, v := range m.ProviderIds {
+		if v != "" {
+			ids[k] = v
+		}
+	}
+	if m.TmdbId > 0 {
+		ids[metadata.ProviderTmdb] = fmt.Sprintf("%d", m.TmdbId)
+	}
+	if m.ImdbId != "" {
+		ids[metadata.ProviderImdb] = m.ImdbId
+	}
+	defaultType := primary
+	if primary == "" || primary == metadata.ProviderTmdb {
+		defaultType = metadata.ProviderImdb
+	}
+	if _, ok := ids[defaultType]; !ok {
+		defaultType = metadata.ProviderTmdb
+	}
+	// 默认ID在前，其他按TMDB、IMDB、名称顺序排列
+	types := make([]string, 0, len(ids))
+	for k := range ids {
+		if k != defaultType {
+			types = append(types, k)
+		}
+	}
+	order := map[string]int{metadata.ProviderTmdb: 0, metadata.ProviderImdb: 1}
+	sort.Slice(types, func(i, j int) bool {
+		oi, iok := order[types[i]]
+		oj, jok := order[types[j]]
+		if iok != jok {
+			return iok
+		}
+		if iok {
+			return oi < oj
+		}
+		return types[i] < types[j]
+	})
+	uniqueIds := make([]helpers.UniqueId, 0, len(ids))
+	if id, ok := ids[defaultType]; ok {
+		uniqueIds = append(uniqueIds, helpers.UniqueId{Type: defaultType, Default: true, Id: id})
+	}
+	for _, k := range types {
+		uniqueIds = append(uniqueIds, helpers.UniqueId{Type: k, Id: ids[k]})
+	}
+	return uniqueIds
+}
+
 func (ms *MediaSeason) FillInfoByTmdbInfo(seasonDetail *tmdb.SeasonDetail) {
 	if seasonDetail == nil {
 		ms.Save()
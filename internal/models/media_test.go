@@ -0,0 +1,66 @@
+package models
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
+	"reflect"
+	"testing"
+)
+
+func TestMediaFillInfoByProviders(t *testing.T) {
+	m := &Media{TmdbId: 1429, Name: "Attack on Titan", Overview: "", VoteAverage: 8.6, VoteCount: 6000}
+	m.fillInfoByProviders(&TmdbInfo{
+		PrimaryProvider: metadata.ProviderBangumi,
+		ProviderDetails: []*metadata.Detail{
+			{Provider: metadata.ProviderBangumi, Id: "55770", Ids: map[string]string{"bangumi": "55770"}, Title: "进击的巨人", Rating: 8.2, VoteCount: 30512},
+			{Provider: metadata.ProviderTvdb, Id: "267440", Ids: map[string]string{"tvdb": "267440", "imdb": "tt2560140"}, Overview: "简介"},
+		},
+	})
+	if m.Name != "进击的巨人" || m.VoteAverage != 8.2 || m.VoteCount != 30512 {
+		t.Errorf("主提供者的标题和评分应该覆盖TMDB: %+v", m)
+	}
+	if m.Overview != "简介" || m.ImdbId != "tt2560140" {
+		t.Errorf("空字段应该由后备提供者补充: %+v", m)
+	}
+	expected := map[string]string{"tmdb": "1429", "bangumi": "55770", "tvdb": "267440", "imdb": "tt2560140"}
+	if !reflect.DeepEqual(m.ProviderIds, expected) {
+		t.Errorf("提供者ID错误: %+v", m.ProviderIds)
+	}
+
+	// 主提供者是TMDB时不覆盖
+	m = &Media{TmdbId: 1429, Name: "进击的巨人", Overview: "TMDB简介"}
+	m.fillInfoByProviders(&TmdbInfo{
+		PrimaryProvider: metadata.ProviderTmdb,
+		ProviderDetails: []*metadata.Detail{{Provider: metadata.ProviderDouban, Ids: map[string]string{"douban": "10440138"}, Title: "进击的巨人 第一季", Overview: "豆瓣简介"}},
+	})
+	if m.Name != "进击的巨人" || m.Overview != "TMDB简介" || m.ProviderIds["douban"] != "10440138" {
+		t.Errorf("后备提供者不应该覆盖TMDB的值: %+v", m)
+	}
+}
+
+func TestMediaUniqueIds(t *testing.T) {
+	m := &Media{TmdbId: 1429, ProviderIds: map[string]string{"tmdb": "1429", "bangumi": "55770", "tvdb": "267440", "douban": ""}}
+	expected := []helpers.UniqueId{
+		{Type: "bangumi", Default: true, Id: "55770"},
+		{Type: "tmdb", Id: "1429"},
+		{Type: "tvdb", Id: "267440"},
+	}
+	if got := m.UniqueIds(metadata.ProviderBangumi); !reflect.DeepEqual(got, expected) {
+		t.Errorf("uniqueid错误: %+v", got)
+	}
+
+	// TMDB为主提供者时IMDB为默认值，没有IMDB时TMDB为默认值
+	m = &Media{TmdbId: 603, ImdbId: "tt0133093"}
+	expected = []helpers.UniqueId{
+		{Type: "imdb", Default: true, Id: "tt0133093"},
+		{Type: "tmdb", Id: "603"},
+	}
+	if got := m.UniqueIds(metadata.ProviderTmdb); !reflect.DeepEqual(got, expected) {
+		t.Errorf("uniqueid错误: %+v", got)
+	}
+	m = &Media{TmdbId: 603}
+	expected = []helpers.UniqueId{{Type: "tmdb", Default: true, Id: "603"}}
+	if got := m.UniqueIds(""); !reflect.DeepEqual(got, expected) {
+		t.Errorf("uniqueid错误: %+v", got)
+	}
+}
@@ -18,7 +18,7 @@ type Migrator struct {
 	VersionCode int `json:"version_code"` // 版本号
 }
 
-var MaxVersionCode = 38
+var MaxVersionCode = 57
 var AllTables = []any{
 	BackupConfig{}, BackupRecord{},
 	ApiKey{}, Settings{}, Sync{}, User{}, Account{},
@@ -27,7 +27,8 @@ var AllTables = []any{
 	ScrapeMediaFile{}, Media{}, MediaSeason{}, MediaEpisode{}, ScrapeStrmPath{},
 	RequestStat{}, EmbyConfig{}, EmbyMediaItem{}, EmbyMediaSyncFile{}, EmbyLibrary{}, EmbyLibrarySyncPath{},
 	DbDownloadTask{}, DbUploadTask{}, NotificationChannel{}, TelegramChannelConfig{}, MeoWChannelConfig{}, BarkChannelConfig{},
-	ServerChanChannelConfig{}, CustomWebhookChannelConfig{}, NotificationRule{},
+	ServerChanChannelConfig{}, CustomWebhookChannelConfig{}, NotificationRule{}, StrmSignKey{}, UserResource{}, BackupDestination{}, BackupUpload{},
+	MediaCollection{}, TvshowMissingReport{}, FileCopy{},
 }
 
 func (*Migrator) TableName() string {
@@ -469,6 +470,127 @@ func Migrate() {
 		helpers.AppLogger.Info("已添加刮削整理失败通知类型")
 		migrator.UpdateVersionCode(db.Db)
 	}
+	if migrator.VersionCode == 39 {
+		// 添加STRM链接签名相关字段和密钥表
+		db.Db.AutoMigrate(Settings{}, SyncPath{}, StrmSignKey{})
+		// 未自定义配置的同步路径使用STRM设置中的有效期
+		db.Db.Model(&SyncPath{}).Where("custom_config = ?", false).Update("sign_expire", -1)
+		helpers.AppLogger.Info("已添加STRM链接签名字段和签名密钥表")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 40 {
+		// 添加预演同步字段到Sync表
+		db.Db.AutoMigrate(Sync{})
+		helpers.AppLogger.Info("已添加is_dry_run和plan_json字段到Sync表")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 41 {
+		// 添加实时监控同步字段到SyncPath表
+		db.Db.AutoMigrate(SyncPath{})
+		helpers.AppLogger.Info("已添加enable_watch字段到SyncPath表")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 42 {
+		// WebDAV按目录查询文件，给SyncFile表的path字段添加索引
+		db.Db.AutoMigrate(SyncFile{})
+		helpers.AppLogger.Info("已添加SyncFile表path字段的索引")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 43 {
+		// 添加用户角色、API Key权限范围和用户资源授权表，已有用户都设为管理员
+		db.Db.AutoMigrate(User{}, ApiKey{}, UserResource{})
+		db.Db.Model(&User{}).Where("role = '' OR role IS NULL").Update("role", UserRoleAdmin)
+		helpers.AppLogger.Info("已添加用户角色、API Key权限范围和用户资源授权表")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 44 {
+		// 添加媒体服务器类型，关联表使用字符串的媒体项ID以支持Jellyfin和Plex
+		db.Db.AutoMigrate(EmbyConfig{}, EmbyMediaSyncFile{})
+		db.Db.Model(&EmbyConfig{}).Where("server_type = '' OR server_type IS NULL").Update("server_type", "emby")
+		db.Db.Exec("UPDATE emby_media_sync_files SET item_id = CAST(emby_item_id AS TEXT) WHERE item_id = '' OR item_id IS NULL")
+		helpers.AppLogger.Info("已添加媒体服务器类型字段和关联表的item_id字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 45 {
+		// 添加备份远程目标、加密和GFS保留策略
+		db.Db.AutoMigrate(BackupConfig{}, BackupRecord{}, BackupDestination{}, BackupUpload{})
+		helpers.AppLogger.Info("已添加备份目标表、备份上传记录表和备份加密、GFS保留策略字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 46 {
+		// 添加元数据提供者
+		db.Db.AutoMigrate(ScrapeSettings{}, ScrapePath{}, Media{})
+		helpers.AppLogger.Info("已添加元数据提供者设置、刮削目录主提供者和后备提供者字段、媒体提供者ID字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 47 {
+		// 添加动画模式
+		db.Db.AutoMigrate(ScrapePath{}, ScrapeMediaFile{})
+		helpers.AppLogger.Info("已添加刮削目录动画模式和集数偏移表字段、刮削文件绝对集数字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 48 {
+		// 添加音乐类型
+		db.Db.AutoMigrate(ScrapePath{}, Media{})
+		helpers.AppLogger.Info("已添加刮削目录MusicBrainz开关、媒体的艺术家、专辑和曲目字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 49 {
+		// 添加待确认队列
+		db.Db.AutoMigrate(ScrapePath{}, ScrapeMediaFile{})
+		helpers.AppLogger.Info("已添加刮削目录的识别可信度阈值、刮削记录的可信度和候选条目字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 50 {
+		// 添加附属视频和多版本
+		db.Db.AutoMigrate(ScrapeMediaFile{})
+		helpers.AppLogger.Info("已添加刮削记录的附属视频类型、正片文件夹和版本字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 51 {
+		// 添加内封字幕提取
+		db.Db.AutoMigrate(ScrapePath{})
+		helpers.AppLogger.Info("已添加刮削目录的内封字幕提取开关")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 52 {
+		// 添加电影合集
+		db.Db.AutoMigrate(MediaCollection{}, Media{}, ScrapePath{})
+		helpers.AppLogger.Info("已添加电影合集表、媒体的合集字段、刮削目录的合集目录字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 53 {
+		// 添加电视剧缺集报告和缺集通知类型
+		db.Db.AutoMigrate(TvshowMissingReport{})
+		addNewNotificationRulesForExistingChannels(db.Db)
+		helpers.AppLogger.Info("已添加电视剧缺集报告表和缺集通知类型")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 54 {
+		// 添加元数据刷新规则
+		db.Db.AutoMigrate(ScrapePath{}, ScrapeMediaFile{})
+		helpers.AppLogger.Info("已添加刮削目录的元数据刷新规则字段、刮削记录的刷新时间字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 55 {
+		// 添加nfo格式和自定义字段
+		db.Db.AutoMigrate(ScrapePath{})
+		helpers.AppLogger.Info("已添加刮削目录的nfo格式、自定义nfo字段和保留修改字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 56 {
+		// 添加账号的最大同时播放数
+		db.Db.AutoMigrate(Account{})
+		helpers.AppLogger.Info("已添加账号的最大同时播放数字段")
+		migrator.UpdateVersionCode(db.Db)
+	}
+	if migrator.VersionCode == 57 {
+		// 添加网盘文件副本表和文件失效通知类型
+		db.Db.AutoMigrate(FileCopy{})
+		addNewNotificationRulesForExistingChannels(db.Db)
+		helpers.AppLogger.Info("已添加网盘文件副本表和文件失效通知类型")
+		migrator.UpdateVersionCode(db.Db)
+	}
 	helpers.AppLogger.Infof("当前数据库版本 %d", migrator.VersionCode)
 }
 
@@ -563,6 +685,7 @@ func InitUser() {
 		// 设置默认值
 		Username: helpers.GlobalConfig.AdminUsername,
 		Password: helpers.GlobalConfig.AdminPassword,
+		Role:     UserRoleAdmin,
 	}
 	if defaultUser.Username == "" {
 		defaultUser.Username = "admin"
@@ -834,6 +957,8 @@ func addNewNotificationRulesForExistingChannels(dbConn *gorm.DB) {
 		notification.PlaybackPause,
 		notification.PlaybackStop,
 		notification.ScrapeError,
+		notification.MissingEpisodes,
+		notification.SourceUnavailable,
 	}
 
 	// 获取所有已有的通知渠道
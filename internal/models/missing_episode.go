@@ -0,0 +1,302 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/notificationmanager"
+	"Q115-STRM/internal/tmdb"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MissingEpisodeStatus string
+
+const (
+	MissingEpisodeStatusMissing MissingEpisodeStatus = "missing" // 已播出但是没有
+	MissingEpisodeStatusUnaired MissingEpisodeStatus = "unaired" // 还未播出
+	MissingEpisodeStatusSpecial MissingEpisodeStatus = "special" // 特别篇（第0季）
+)
+
+// 全库缺集检查是否正在执行
+var missingReportRunning atomic.Bool
+
+// 电视剧缺集报告，按TMDB ID保存，同一部剧分散在多个刮削目录时合并统计
+type TvshowMissingReport struct {
+	BaseModel
+	TmdbId             int64             `json:"tmdb_id" gorm:"uniqueIndex"`  // TMDB ID
+	MediaId            uint              `json:"media_id" gorm:"index"`       // 最近一次检查的媒体ID
+	ScrapePathId       uint              `json:"scrape_path_id" gorm:"index"` // 刮削路径ID
+	Name               string            `json:"name"`                        // 电视剧名称
+	Year               int               `json:"year"`                        // 年份
+	PosterPath         string            `json:"poster_path"`                 // 海报
+	TmdbStatus         string            `json:"tmdb_status"`                 // TMDB上的连载状态，例如：Returning Series、Ended
+	OwnedCount         int               `json:"owned_count"`                 // 已有的集数，不含特别篇
+	AiredCount         int               `json:"aired_count"`                 // 已播出的集数，不含特别篇
+	MissingCount       int               `json:"missing_count"`               // 已播出但是没有的集数
+	UnairedCount       int               `json:"unaired_count"`               // 还未播出的集数
+	SpecialCount       int               `json:"special_count"`               // 没有的特别篇集数
+	MissingSeasons     []int             `json:"missing_seasons" gorm:"-"`    // 已播出但是一集都没有的季
+	MissingSeasonsJson string            `json:"-"`                           // MissingSeasons的JSON字符串
+	Episodes           []*MissingEpisode `json:"episodes" gorm:"-"`           // 缺少的集
+	EpisodesJson       string            `json:"-" gorm:"type:text"`          // Episodes的JSON字符串
+	CheckTime          int64             `json:"check_time"`                  // 检查时间
+}
+
+// 缺少的集
+type MissingEpisode struct {
+	SeasonNumber  int                  `json:"season_number"`  // 季编号
+	EpisodeNumber int                  `json:"episode_number"` // 集编号
+	Name          string               `json:"name"`           // 集名称
+	AirDate       string               `json:"air_date"`       // 播出时间
+	Status        MissingEpisodeStatus `json:"status"`         // 缺集类型
+}
+
+func (e *MissingEpisode) Key() string {
+	return fmt.Sprintf("S%02dE%02d", e.SeasonNumber, e.EpisodeNumber)
+}
+
+// 全库缺集统计
+type TvshowMissingTotals struct {
+	Shows         int64 `json:"shows"`           // 已检查的电视剧数量
+	ShowsWithGaps int64 `json:"shows_with_gaps"` // 有缺集的电视剧数量
+	MissingCount  int64 `json:"missing_count"`   // 已播出但是没有的总集数
+	UnairedCount  int64 `json:"unaired_count"`   // 还未播出的总集数
+	SpecialCount  int64 `json:"special_count"`   // 没有的特别篇总集数
+	Running       bool  `json:"running"`         // 是否正在执行全库检查
+}
+
+func (r *TvshowMissingReport) Save() error {
+	r.MissingSeasonsJson = helpers.JsonString(r.MissingSeasons)
+	r.EpisodesJson = helpers.JsonString(r.Episodes)
+	if err := db.Db.Save(r).Error; err != nil {
+		helpers.AppLogger.Errorf("保存电视剧 %s 的缺集报告失败: %v", r.Name, err)
+		return err
+	}
+	return nil
+}
+
+func (r *TvshowMissingReport) DecodeJson() {
+	if r.MissingSeasonsJson != "" {
+		if seasons, err := helpers.StringJson[[]int](r.MissingSeasonsJson); err == nil {
+			r.MissingSeasons = seasons
+		}
+	}
+	if r.EpisodesJson != "" {
+		episodes, err := helpers.StringJson[[]*MissingEpisode](r.EpisodesJson)
+		if err != nil {
+			helpers.AppLogger.Warnf("解码电视剧 %s 的缺集列表失败: %v", r.Name, err)
+			return
+		}
+		r.Episodes = episodes
+	}
+}
+
+// 对比已有的集和TMDB的季详情生成缺集列表，today格式为2006-01-02
+// 播出时间为空或者晚于today的集算作未播出，第0季缺少的集算作特别篇
+func (r *TvshowMissingReport) Build(owned []*MediaEpisode, seasons []*tmdb.SeasonDetail, today string) {
+	ownedKeys := make(map[string]bool, len(owned))
+	for _, e := range owned {
+		ownedKeys[fmt.Sprintf("S%02dE%02d", e.SeasonNumber, e.EpisodeNumber)] = true
+	}
+	r.OwnedCount, r.AiredCount, r.MissingCount, r.UnairedCount, r.SpecialCount = 0, 0, 0, 0, 0
+	r.MissingSeasons = make([]int, 0)
+	r.Episodes = make([]*MissingEpisode, 0)
+	for _, season := range seasons {
+		ownedInSeason, airedInSeason := 0, 0
+		for _, ep := range season.Episodes {
+			e := &MissingEpisode{SeasonNumber: season.SeasonNumber, EpisodeNumber: ep.EpisodeNumber, Name: ep.Name, AirDate: ep.AirDate}
+			aired := ep.AirDate != "" && ep.AirDate <= today
+			if season.SeasonNumber > 0 && aired {
+				airedInSeason++
+			}
+			if ownedKeys[e.Key()] {
+				if season.SeasonNumber > 0 {
+					ownedInSeason++
+				}
+				continue
+			}
+			switch {
+			case season.SeasonNumber == 0:
+				e.Status = MissingEpisodeStatusSpecial
+				r.SpecialCount++
+			case !aired:
+				e.Status = MissingEpisodeStatusUnaired
+				r.UnairedCount++
+			default:
+				e.Status = MissingEpisodeStatusMissing
+				r.MissingCount++
+			}
+			r.Episodes = append(r.Episodes, e)
+		}
+		r.OwnedCount += ownedInSeason
+		r.AiredCount += airedInSeason
+		if season.SeasonNumber > 0 && ownedInSeason == 0 && airedInSeason > 0 {
+			r.MissingSeasons = append(r.MissingSeasons, season.SeasonNumber)
+		}
+	}
+	r.CheckTime = time.Now().Unix()
+}
+
+// 按TMDB ID查询缺集报告，不存在时返回nil
+func GetTvshowMissingReportByTmdbId(tmdbId int64) *TvshowMissingReport {
+	report := &TvshowMissingReport{}
+	if err := db.Db.Where("tmdb_id = ?", tmdbId).First(report).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.AppLogger.Errorf("查询电视剧 %d 的缺集报告失败: %v", tmdbId, err)
+		}
+		return nil
+	}
+	report.DecodeJson()
+	return report
+}
+
+// 从TMDB查询电视剧的所有季，和已刮削的集对比后保存缺集报告
+// 和上一次的报告相比有新的已播出缺集时发送通知，第一次检查不发送
+func RefreshTvshowMissingReport(media *Media) (*TvshowMissingReport, error) {
+	if media.MediaType != MediaTypeTvShow || media.TmdbId == 0 {
+		return nil, fmt.Errorf("媒体 %s 不是已识别的电视剧", media.Name)
+	}
+	client := GlobalScrapeSettings.GetTmdbClient()
+	language := GlobalScrapeSettings.GetTmdbLanguage()
+	detail, err := client.GetTvDetail(media.TmdbId, language)
+	if err != nil {
+		return nil, err
+	}
+	seasons := make([]*tmdb.SeasonDetail, 0, len(detail.Seasons))
+	for _, s := range detail.Seasons {
+		seasonDetail, err := client.GetTvSeasonDetail(media.TmdbId, s.SeasonNumber, language)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, seasonDetail)
+	}
+	// 同一部剧可能在多个刮削目录中各有一条媒体记录
+	var owned []*MediaEpisode
+	mediaIds := db.Db.Model(&Media{}).Where("tmdb_id = ? AND media_type = ?", media.TmdbId, MediaTypeTvShow).Select("id")
+	if err := db.Db.Where("media_id IN (?)", mediaIds).Select("season_number, episode_number").Find(&owned).Error; err != nil {
+		helpers.AppLogger.Errorf("查询电视剧 %s 已有的集失败: %v", media.Name, err)
+		return nil, err
+	}
+	previous := GetTvshowMissingReportByTmdbId(media.TmdbId)
+	report := &TvshowMissingReport{}
+	if previous != nil {
+		report.ID = previous.ID
+		report.CreatedAt = previous.CreatedAt
+	}
+	report.TmdbId = media.TmdbId
+	report.MediaId = media.ID
+	report.ScrapePathId = media.ScrapePathId
+	report.Name = media.Name
+	report.Year = media.Year
+	report.PosterPath = media.PosterPath
+	report.TmdbStatus = detail.Status
+	report.Build(owned, seasons, time.Now().Format("2006-01-02"))
+	if err := report.Save(); err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		report.notifyNewGaps(previous)
+	}
+	return report, nil
+}
+
+// 发送新发现的已播出缺集通知
+func (r *TvshowMissingReport) notifyNewGaps(previous *TvshowMissingReport) {
+	known := make(map[string]bool, len(previous.Episodes))
+	for _, e := range previous.Episodes {
+		if e.Status == MissingEpisodeStatusMissing {
+			known[e.Key()] = true
+		}
+	}
+	newKeys := make([]string, 0)
+	for _, e := range r.Episodes {
+		if e.Status == MissingEpisodeStatusMissing && !known[e.Key()] {
+			newKeys = append(newKeys, e.Key())
+		}
+	}
+	if len(newKeys) == 0 || notificationmanager.GlobalEnhancedNotificationManager == nil {
+		return
+	}
+	notif := &Notification{
+		Type:      MissingEpisodes,
+		Title:     fmt.Sprintf("📺 %s (%d) 发现新的缺集", r.Name, r.Year),
+		Content:   fmt.Sprintf("新缺少 %d 集：%s\n共缺少 %d 集\n⏰ 时间: %s", len(newKeys), strings.Join(newKeys, "、"), r.MissingCount, time.Now().Format("2006-01-02 15:04:05")),
+		Timestamp: time.Now(),
+		Priority:  NormalPriority,
+		Image:     r.PosterPath,
+	}
+	if err := notificationmanager.GlobalEnhancedNotificationManager.SendNotification(context.Background(), notif); err != nil {
+		helpers.AppLogger.Errorf("发送缺集通知失败: %v", err)
+	}
+}
+
+// 检查所有已刮削的电视剧，已经在执行时返回false
+func RefreshAllTvshowMissingReports() bool {
+	if !missingReportRunning.CompareAndSwap(false, true) {
+		return false
+	}
+	go func() {
+		defer missingReportRunning.Store(false)
+		var medias []*Media
+		// 同一部剧只检查一次
+		if err := db.Db.Where("id IN (?)", db.Db.Model(&Media{}).Where("media_type = ? AND tmdb_id > 0", MediaTypeTvShow).Select("MAX(id)").Group("tmdb_id")).Find(&medias).Error; err != nil {
+			helpers.AppLogger.Errorf("查询需要检查缺集的电视剧失败: %v", err)
+			return
+		}
+		helpers.AppLogger.Infof("开始检查 %d 部电视剧的缺集", len(medias))
+		for _, media := range medias {
+			if _, err := RefreshTvshowMissingReport(media); err != nil {
+				helpers.AppLogger.Errorf("检查电视剧 %s 的缺集失败: %v", media.Name, err)
+			}
+		}
+		helpers.AppLogger.Infof("已完成 %d 部电视剧的缺集检查", len(medias))
+	}()
+	return true
+}
+
+// 统计缺集报告，scrapePathIds为nil时统计所有刮削目录
+func GetTvshowMissingTotals(scrapePathIds []uint) *TvshowMissingTotals {
+	totals := &TvshowMissingTotals{Running: missingReportRunning.Load()}
+	tx := db.Db.Model(&TvshowMissingReport{})
+	if scrapePathIds != nil {
+		tx = tx.Where("scrape_path_id IN ?", scrapePathIds)
+	}
+	err := tx.Select("COUNT(*) AS shows, SUM(CASE WHEN missing_count > 0 THEN 1 ELSE 0 END) AS shows_with_gaps, " +
+		"COALESCE(SUM(missing_count), 0) AS missing_count, COALESCE(SUM(unaired_count), 0) AS unaired_count, COALESCE(SUM(special_count), 0) AS special_count").
+		Scan(totals).Error
+	if err != nil {
+		helpers.AppLogger.Errorf("统计缺集报告失败: %v", err)
+	}
+	return totals
+}
+
+// 分页查询有缺集的电视剧，按缺少的集数倒序，列表中不包含缺集明细
+func GetTvshowMissingReports(page int, pageSize int, scrapePathIds []uint) (int64, []*TvshowMissingReport) {
+	query := func() *gorm.DB {
+		tx := db.Db.Model(&TvshowMissingReport{}).Where("missing_count > 0")
+		if scrapePathIds != nil {
+			tx = tx.Where("scrape_path_id IN ?", scrapePathIds)
+		}
+		return tx
+	}
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		helpers.AppLogger.Errorf("查询缺集报告总数失败: %v", err)
+		return 0, nil
+	}
+	var reports []*TvshowMissingReport
+	if err := query().Omit("episodes_json").Order("missing_count desc, name asc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&reports).Error; err != nil {
+		helpers.AppLogger.Errorf("查询缺集报告列表失败: %v", err)
+		return 0, nil
+	}
+	for _, r := range reports {
+		r.DecodeJson()
+	}
+	return total, reports
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"Q115-STRM/internal/tmdb"
+	"slices"
+	"testing"
+)
+
+func TestTvshowMissingReportBuild(t *testing.T) {
+	seasons := []*tmdb.SeasonDetail{
+		{SeasonNumber: 0, Episodes: []tmdb.SeasonEpisode{
+			{EpisodeNumber: 1, AirDate: "2020-12-25"},
+		}},
+		{SeasonNumber: 1, Episodes: []tmdb.SeasonEpisode{
+			{EpisodeNumber: 1, AirDate: "2020-01-01"},
+			{EpisodeNumber: 2, AirDate: "2020-01-08"},
+			{EpisodeNumber: 3, AirDate: "2020-01-15"},
+		}},
+		{SeasonNumber: 2, Episodes: []tmdb.SeasonEpisode{
+			{EpisodeNumber: 1, AirDate: "2021-01-01"},
+			{EpisodeNumber: 2, AirDate: "2021-01-08"},
+		}},
+		{SeasonNumber: 3, Episodes: []tmdb.SeasonEpisode{
+			{EpisodeNumber: 1, AirDate: "2022-01-01"},
+			{EpisodeNumber: 2, AirDate: "2099-01-08"},
+			{EpisodeNumber: 3, AirDate: ""},
+		}},
+	}
+	owned := []*MediaEpisode{
+		{SeasonNumber: 1, EpisodeNumber: 1},
+		{SeasonNumber: 1, EpisodeNumber: 3},
+		{SeasonNumber: 3, EpisodeNumber: 1},
+	}
+	r := &TvshowMissingReport{}
+	r.Build(owned, seasons, "2024-06-01")
+	if r.OwnedCount != 3 || r.AiredCount != 6 || r.MissingCount != 3 || r.UnairedCount != 2 || r.SpecialCount != 1 {
+		t.Errorf("counts = owned %d aired %d missing %d unaired %d special %d", r.OwnedCount, r.AiredCount, r.MissingCount, r.UnairedCount, r.SpecialCount)
+	}
+	if !slices.Equal(r.MissingSeasons, []int{2}) {
+		t.Errorf("MissingSeasons = %v; want [2]", r.MissingSeasons)
+	}
+	statuses := make(map[string]MissingEpisodeStatus)
+	for _, e := range r.Episodes {
+		statuses[e.Key()] = e.Status
+	}
+	expected := map[string]MissingEpisodeStatus{
+		"S00E01": MissingEpisodeStatusSpecial,
+		"S01E02": MissingEpisodeStatusMissing,
+		"S02E01": MissingEpisodeStatusMissing,
+		"S02E02": MissingEpisodeStatusMissing,
+		"S03E02": MissingEpisodeStatusUnaired,
+		"S03E03": MissingEpisodeStatusUnaired,
+	}
+	if len(statuses) != len(expected) {
+		t.Fatalf("Episodes = %v; want %v", statuses, expected)
+	}
+	for key, status := range expected {
+		if statuses[key] != status {
+			t.Errorf("%s status = %q; want %q", key, statuses[key], status)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"Q115-STRM/internal/audiotag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 音乐默认按 艺术家/专辑 (年份)/曲目 - 标题 整理
+const (
+	DefaultMusicFolderTemplate = "{artist}/{album} ({year})"
+	DefaultMusicFileTemplate   = "{track} - {title}"
+	UnknownMusicArtist         = "Unknown Artist"
+	UnknownMusicAlbum          = "Unknown Album"
+)
+
+var (
+	// 01 - 标题、01. 标题、01 标题
+	musicTrackNameRe = regexp.MustCompile(`^(\d{1,3})\s*(?:[-.、_]\s*|\s+)(.+)$`)
+	// 专辑 (2003)、专辑 [2003]
+	musicAlbumYearRe = regexp.MustCompile(`^(.+?)\s*[(\[（](\d{4})[)\]）]$`)
+)
+
+// 是否是音乐目录
+func (sp *ScrapePath) IsMusic() bool {
+	return sp.MediaType == MediaTypeMusic
+}
+
+// 从音频标签创建媒体信息，标签缺少的字段从文件名和文件夹中补全
+// 来源目录一般是 艺术家/专辑/01 - 标题.mp3，没有标签的文件也能整理
+func (sm *ScrapeMediaFile) MakeMusicMedia(tags *audiotag.Tags) *Media {
+	if tags == nil {
+		tags = &audiotag.Tags{}
+	}
+	baseName := strings.TrimSuffix(filepath.Base(sm.VideoFilename), filepath.Ext(sm.VideoFilename))
+	title := tags.Title
+	track := tags.Track
+	if m := musicTrackNameRe.FindStringSubmatch(baseName); m != nil {
+		if track == 0 {
+			track, _ = strconv.Atoi(m[1])
+		}
+		baseName = strings.TrimSpace(m[2])
+	}
+	artist := tags.GetAlbumArtist()
+	if title == "" {
+		// 艺术家 - 标题
+		if a, t, ok := strings.Cut(baseName, " - "); ok {
+			title = strings.TrimSpace(t)
+			if artist == "" {
+				artist = strings.TrimSpace(a)
+			}
+		} else {
+			title = baseName
+		}
+	}
+	album := tags.Album
+	year := tags.Year
+	// 来源路径中去掉来源根目录后剩下的部分
+	remotePath := strings.Trim(filepath.ToSlash(sm.GetRemoteMoviePath()), "/")
+	dirs := make([]string, 0)
+	if remotePath != "" {
+		dirs = strings.Split(remotePath, "/")
+	}
+	if album == "" && len(dirs) > 0 {
+		album = dirs[len(dirs)-1]
+		if m := musicAlbumYearRe.FindStringSubmatch(album); m != nil {
+			album = strings.TrimSpace(m[1])
+			if year == 0 {
+				year, _ = strconv.Atoi(m[2])
+			}
+		}
+	}
+	if artist == "" && len(dirs) > 1 {
+		artist = dirs[len(dirs)-2]
+	}
+	if artist == "" {
+		artist = UnknownMusicArtist
+	}
+	if album == "" {
+		album = UnknownMusicAlbum
+	}
+	disc := tags.Disc
+	if disc == 0 {
+		disc = 1
+	}
+	return &Media{
+		ScrapePathId:       sm.ScrapePathId,
+		MediaType:          MediaTypeMusic,
+		Name:               CleanMusicName(title),
+		OriginalName:       title,
+		Year:               year,
+		Artist:             artist,
+		Album:              album,
+		TrackNumber:        track,
+		DiscNumber:         disc,
+		MusicBrainzAlbumId: tags.MusicBrainzAlbumId,
+		MusicBrainzTrackId: tags.MusicBrainzRecordingId,
+		Status:             MediaStatusUnScraped,
+	}
+}
+
+// MakeM3uPlaylist 生成扩展M3U播放列表，曲目使用相对路径，和播放列表放在同一个文件夹
+func MakeM3uPlaylist(tracks []string) string {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n")
+	for _, track := range tracks {
+		sb.WriteString(fmt.Sprintf("#EXTINF:-1,%s\n", strings.TrimSuffix(track, filepath.Ext(track))))
+		sb.WriteString(track)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"Q115-STRM/internal/audiotag"
+	"testing"
+)
+
+func TestMakeMusicMedia(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		filename string
+		tags     *audiotag.Tags
+		expected string
+	}{
+		{
+			name:     "标签完整",
+			path:     "/music/下载",
+			filename: "track03.mp3",
+			tags:     &audiotag.Tags{Title: "晴天", Artist: "周杰伦", Album: "叶惠美", Year: 2003, Track: 3},
+			expected: "周杰伦/叶惠美 (2003)/03 - 晴天",
+		},
+		{
+			name:     "合辑使用专辑艺术家",
+			path:     "/music",
+			filename: "01.flac",
+			tags:     &audiotag.Tags{Title: "Song", Artist: "Singer", AlbumArtist: "Various Artists", Album: "Hits", Year: 2020, Track: 1},
+			expected: "Various Artists/Hits (2020)/01 - Song",
+		},
+		{
+			name:     "没有标签从文件夹补全",
+			path:     "/music/Coldplay/Parachutes (2000)",
+			filename: "05 - Yellow.flac",
+			expected: "Coldplay/Parachutes (2000)/05 - Yellow",
+		},
+		{
+			name:     "名称中的路径分隔符",
+			path:     "/music",
+			filename: "AC_DC - Back In Black.mp3",
+			tags:     &audiotag.Tags{Title: "Back In Black", Artist: "AC/DC", Album: "Back In Black", Year: 1980, Track: 6},
+			expected: "AC_DC/Back In Black (1980)/06 - Back In Black",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := &ScrapeMediaFile{MediaType: MediaTypeMusic, SourcePath: "/music", Path: tt.path, VideoFilename: tt.filename}
+			sm.Media = sm.MakeMusicMedia(tt.tags)
+			sm.Name = sm.Media.Name
+			sm.Year = sm.Media.Year
+			result := sm.GenerateNameByTemplate(DefaultMusicFolderTemplate) + "/" + sm.GenerateNameByTemplate(DefaultMusicFileTemplate)
+			if result != tt.expected {
+				t.Errorf("生成的路径 %s 与预期 %s 不符", result, tt.expected)
+			}
+		})
+	}
+	// 新模板语法
+	sm := &ScrapeMediaFile{MediaType: MediaTypeMusic, VideoFilename: "x.mp3", Media: &Media{Artist: "A/B", Album: "C", TrackNumber: 7, DiscNumber: 2}}
+	if result := sm.GenerateNameByTemplate("{{artist}}/{{album}}/{{disc}}-{{track}}"); result != "A_B/C/2-07" {
+		t.Errorf("新模板生成的路径错误: %s", result)
+	}
+}
+
+func TestMakeM3uPlaylist(t *testing.T) {
+	expected := "#EXTM3U\n#EXTINF:-1,01 - Don't Panic\n01 - Don't Panic.strm\n#EXTINF:-1,05 - Yellow\n05 - Yellow.strm\n"
+	if result := MakeM3uPlaylist([]string{"01 - Don't Panic.strm", "05 - Yellow.strm"}); result != expected {
+		t.Errorf("播放列表内容错误:\n%s", result)
+	}
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"Q115-STRM/internal/helpers"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+
+	"github.com/flosch/pongo2/v5"
+)
+
+// 自定义nfo字段对应的nfo文件
+const (
+	NfoTargetMovie   = "movie"
+	NfoTargetTvShow  = "tvshow"
+	NfoTargetSeason  = "season"
+	NfoTargetEpisode = "episode"
+)
+
+// 自定义nfo字段，Value是模板，和文件名模板使用相同的变量，例如：{{ videoFormat }}
+// Target为空时写入所有nfo
+type NfoExtraField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Target string `json:"target"`
+}
+
+// 解析自定义nfo字段，解析失败返回空
+func (sp *ScrapePath) GetNfoExtraFields() []NfoExtraField {
+	if strings.TrimSpace(sp.NfoExtraFields) == "" {
+		return nil
+	}
+	fields := make([]NfoExtraField, 0)
+	if err := json.Unmarshal([]byte(sp.NfoExtraFields), &fields); err != nil {
+		helpers.AppLogger.Errorf("解析刮削目录 %s 的自定义nfo字段失败: %v", sp.SourcePath, err)
+		return nil
+	}
+	return fields
+}
+
+// 重新生成nfo时始终保留的元素
+func (sp *ScrapePath) GetNfoLockedFields() []string {
+	fields := make([]string, 0)
+	for _, name := range strings.Split(sp.NfoLockedFields, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// 检查nfo格式、自定义字段和保留的元素是否有效
+func (sp *ScrapePath) CheckNfoOptions() error {
+	if !helpers.IsValidNfoProfile(helpers.NfoProfile(sp.NfoProfile)) {
+		return fmt.Errorf("不支持的nfo格式: %s", sp.NfoProfile)
+	}
+	for _, name := range sp.GetNfoLockedFields() {
+		if !helpers.IsValidNfoElementName(name) {
+			return fmt.Errorf("保留的nfo元素名称 %s 无效", name)
+		}
+	}
+	if strings.TrimSpace(sp.NfoExtraFields) == "" {
+		return nil
+	}
+	fields := make([]NfoExtraField, 0)
+	if err := json.Unmarshal([]byte(sp.NfoExtraFields), &fields); err != nil {
+		return fmt.Errorf("自定义nfo字段格式错误: %v", err)
+	}
+	for _, field := range fields {
+		if !helpers.IsValidNfoElementName(field.Name) {
+			return fmt.Errorf("自定义nfo字段名称 %s 无效", field.Name)
+		}
+		switch field.Target {
+		case "", NfoTargetMovie, NfoTargetTvShow, NfoTargetSeason, NfoTargetEpisode:
+		default:
+			return fmt.Errorf("自定义nfo字段 %s 的nfo类型 %s 无效", field.Name, field.Target)
+		}
+		if _, err := pongo2.FromString(field.Value); err != nil {
+			return fmt.Errorf("自定义nfo字段 %s 的模板错误: %v", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// 渲染自定义nfo字段
+func (sm *ScrapeMediaFile) RenderNfoExtraFields(fields []NfoExtraField, target string) []helpers.NfoExtraField {
+	result := make([]helpers.NfoExtraField, 0, len(fields))
+	if len(fields) == 0 {
+		return result
+	}
+	ctx := sm.buildTemplateContext()
+	for _, field := range fields {
+		if field.Target != "" && field.Target != target {
+			continue
+		}
+		tpl, err := pongo2.FromString(field.Value)
+		if err != nil {
+			helpers.AppLogger.Errorf("自定义nfo字段 %s 的模板解析失败: %v", field.Name, err)
+			continue
+		}
+		out, err := tpl.Execute(ctx)
+		if err != nil {
+			helpers.AppLogger.Errorf("自定义nfo字段 %s 的模板渲染失败: %v", field.Name, err)
+			continue
+		}
+		// 模板会做HTML转义，写入nfo时再做XML转义
+		result = append(result, helpers.NfoExtraField{Name: field.Name, Value: html.UnescapeString(out)})
+	}
+	return result
+}
+
+// 生成nfo的选项，destPath是nfo在目标目录中的路径（不含文件名）
+// 开启保留修改时，旧nfo从本地目标目录和STRM同步目录中查找
+func (sp *ScrapePath) NfoOptions(mediaFile *ScrapeMediaFile, target string, destPath string, fileName string) *helpers.NfoOptions {
+	opts := &helpers.NfoOptions{
+		Profile:       helpers.NfoProfile(sp.NfoProfile),
+		ExtraFields:   mediaFile.RenderNfoExtraFields(sp.GetNfoExtraFields(), target),
+		PreserveEdits: sp.NfoPreserveEdits,
+		LockedFields:  sp.GetNfoLockedFields(),
+	}
+	if !sp.NfoPreserveEdits {
+		return opts
+	}
+	if sp.SourceType == SourceTypeLocal {
+		opts.ExistingNfo = append(opts.ExistingNfo, filepath.Join(destPath, fileName))
+	}
+	if mediaFile.Media != nil {
+		if syncPath := sp.GetSyncPathByPath(mediaFile.Media.Path); syncPath != nil {
+			opts.ExistingNfo = append(opts.ExistingNfo, filepath.Join(syncPath.LocalPath, destPath, fileName))
+		}
+	}
+	return opts
+}
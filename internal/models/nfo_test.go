@@ -0,0 +1,45 @@
+package models
+
+import "testing"
+
+func TestCheckNfoOptions(t *testing.T) {
+	cases := []struct {
+		name  string
+		sp    *ScrapePath
+		valid bool
+	}{
+		{"默认", &ScrapePath{}, true},
+		{"Emby", &ScrapePath{NfoProfile: "emby", NfoLockedFields: "title, plot"}, true},
+		{"不支持的格式", &ScrapePath{NfoProfile: "plex"}, false},
+		{"保留的元素名称无效", &ScrapePath{NfoLockedFields: "title,<plot>"}, false},
+		{"自定义字段", &ScrapePath{NfoExtraFields: `[{"name":"tag","value":"{{ videoFormat }}","target":"movie"}]`}, true},
+		{"自定义字段格式错误", &ScrapePath{NfoExtraFields: `{"name":"tag"}`}, false},
+		{"自定义字段名称无效", &ScrapePath{NfoExtraFields: `[{"name":"1tag","value":"x"}]`}, false},
+		{"自定义字段类型无效", &ScrapePath{NfoExtraFields: `[{"name":"tag","value":"x","target":"album"}]`}, false},
+		{"自定义字段模板错误", &ScrapePath{NfoExtraFields: `[{"name":"tag","value":"{% if %}"}]`}, false},
+	}
+	for _, c := range cases {
+		if err := c.sp.CheckNfoOptions(); (err == nil) != c.valid {
+			t.Errorf("%s: CheckNfoOptions() = %v; want valid %v", c.name, err, c.valid)
+		}
+	}
+}
+
+func TestRenderNfoExtraFields(t *testing.T) {
+	sm := &ScrapeMediaFile{Name: "Tom & Jerry", Year: 2021, Resolution: "2160p", MediaType: MediaTypeMovie}
+	fields := []NfoExtraField{
+		{Name: "tag", Value: "{{ videoFormat }}"},
+		{Name: "sorttitle", Value: "{{ title }} {{ year }}", Target: NfoTargetMovie},
+		{Name: "tag", Value: "S{{ season }}", Target: NfoTargetEpisode},
+	}
+	result := sm.RenderNfoExtraFields(fields, NfoTargetMovie)
+	if len(result) != 2 {
+		t.Fatalf("RenderNfoExtraFields() = %+v; want 2 fields", result)
+	}
+	if result[0].Value != "2160p" {
+		t.Errorf("tag = %q; want 2160p", result[0].Value)
+	}
+	if result[1].Value != "Tom & Jerry 2021" {
+		t.Errorf("sorttitle = %q; want Tom & Jerry 2021", result[1].Value)
+	}
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 用户角色
+type UserRole string
+
+const (
+	UserRoleAdmin    UserRole = "admin"    // 管理员，拥有所有权限，可以看到所有资源
+	UserRoleOperator UserRole = "operator" // 操作员，可以管理和执行被授权的同步、刮削任务，不能修改系统设置和账号
+	UserRoleViewer   UserRole = "viewer"   // 访客，只能查看被授权的资源和播放
+)
+
+// 权限范围，角色和API Key都用权限范围描述可以访问的接口
+const (
+	ScopeAdmin         = "admin"          // 用户管理、数据库、备份、更新等系统管理
+	ScopeSettingsRead  = "settings:read"  // 查看系统设置
+	ScopeSettingsWrite = "settings:write" // 修改系统设置
+	ScopeAccountRead   = "account:read"   // 查看网盘账号和浏览网盘目录
+	ScopeAccountWrite  = "account:write"  // 添加、删除、授权网盘账号
+	ScopeSyncRead      = "sync:read"      // 查看同步路径和同步记录
+	ScopeSyncRun       = "sync:run"       // 启动、停止同步任务，管理上传下载队列
+	ScopeSyncWrite     = "sync:write"     // 添加、修改、删除同步路径
+	ScopeScrapeRead    = "scrape:read"    // 查看刮削路径和刮削记录
+	ScopeScrapeRun     = "scrape:run"     // 启动、停止刮削任务，处理刮削记录
+	ScopeScrapeWrite   = "scrape:write"   // 添加、修改、删除刮削路径
+	ScopePlayback      = "playback"       // 通过WebDAV浏览和播放
+)
+
+var AllScopes = []string{
+	ScopeAdmin, ScopeSettingsRead, ScopeSettingsWrite, ScopeAccountRead, ScopeAccountWrite,
+	ScopeSyncRead, ScopeSyncRun, ScopeSyncWrite, ScopeScrapeRead, ScopeScrapeRun, ScopeScrapeWrite, ScopePlayback,
+}
+
+// 每个角色拥有的权限范围
+var RoleScopes = map[UserRole][]string{
+	UserRoleAdmin: AllScopes,
+	UserRoleOperator: {
+		ScopeSettingsRead, ScopeAccountRead,
+		ScopeSyncRead, ScopeSyncRun, ScopeSyncWrite, ScopeScrapeRead, ScopeScrapeRun, ScopeScrapeWrite, ScopePlayback,
+	},
+	UserRoleViewer: {
+		ScopeSettingsRead, ScopeAccountRead, ScopeSyncRead, ScopeScrapeRead, ScopePlayback,
+	},
+}
+
+func IsValidRole(role UserRole) bool {
+	_, ok := RoleScopes[role]
+	return ok
+}
+
+// 检查权限范围是否都有效
+func CheckScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(AllScopes, scope) {
+			return fmt.Errorf("无效的权限范围: %s", scope)
+		}
+	}
+	return nil
+}
+
+// 可以授权给非管理员用户的资源类型
+type ResourceType string
+
+const (
+	ResourceTypeSyncPath   ResourceType = "sync_path"   // 同步路径
+	ResourceTypeScrapePath ResourceType = "scrape_path" // 刮削路径
+	ResourceTypeAccount    ResourceType = "account"     // 网盘账号
+)
+
+func IsValidResourceType(resourceType ResourceType) bool {
+	return resourceType == ResourceTypeSyncPath || resourceType == ResourceTypeScrapePath || resourceType == ResourceTypeAccount
+}
+
+// 用户被授权的资源，管理员不需要授权
+type UserResource struct {
+	BaseModel
+	UserId       uint         `json:"user_id" gorm:"uniqueIndex:idx_user_resource"`
+	ResourceType ResourceType `json:"resource_type" gorm:"uniqueIndex:idx_user_resource"`
+	ResourceId   uint         `json:"resource_id" gorm:"uniqueIndex:idx_user_resource"`
+}
+
+func (UserResource) TableName() string {
+	return "user_resources"
+}
+
+// 查询用户被授权的某类资源的ID
+func GetUserResourceIds(userId uint, resourceType ResourceType) []uint {
+	ids := make([]uint, 0)
+	if err := db.Db.Model(&UserResource{}).Where("user_id = ? AND resource_type = ?", userId, resourceType).Pluck("resource_id", &ids).Error; err != nil {
+		helpers.AppLogger.Errorf("查询用户 %d 的授权资源失败: %v", userId, err)
+	}
+	return ids
+}
+
+// 覆盖保存用户被授权的某类资源
+func SaveUserResources(userId uint, resourceType ResourceType, ids []uint) error {
+	tx := db.Db.Begin()
+	if err := tx.Where("user_id = ? AND resource_type = ?", userId, resourceType).Delete(&UserResource{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, id := range slices.Compact(slices.Sorted(slices.Values(ids))) {
+		if err := tx.Create(&UserResource{UserId: userId, ResourceType: resourceType, ResourceId: id}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// 给用户授权单个资源，已授权的忽略
+func GrantUserResource(userId uint, resourceType ResourceType, resourceId uint) error {
+	var count int64
+	db.Db.Model(&UserResource{}).Where("user_id = ? AND resource_type = ? AND resource_id = ?", userId, resourceType, resourceId).Count(&count)
+	if count > 0 {
+		return nil
+	}
+	return db.Db.Create(&UserResource{UserId: userId, ResourceType: resourceType, ResourceId: resourceId}).Error
+}
+
+// 删除资源时清理所有用户的授权
+func DeleteResourceGrants(resourceType ResourceType, resourceId uint) {
+	if err := db.Db.Where("resource_type = ? AND resource_id = ?", resourceType, resourceId).Delete(&UserResource{}).Error; err != nil {
+		helpers.AppLogger.Errorf("删除资源 %s:%d 的授权失败: %v", resourceType, resourceId, err)
+	}
+}
+
+// 用户的角色拥有的权限范围
+func (user *User) GetScopes() []string {
+	return RoleScopes[user.GetRole()]
+}
+
+// 用户的角色，旧数据没有角色的视为管理员
+func (user *User) GetRole() UserRole {
+	if user.Role == "" {
+		return UserRoleAdmin
+	}
+	return user.Role
+}
+
+func (user *User) IsAdmin() bool {
+	return user.GetRole() == UserRoleAdmin
+}
+
+// 用户可以访问的某类资源的ID，all=true表示可以访问全部
+func (user *User) GetResourceIds(resourceType ResourceType) (ids []uint, all bool) {
+	if user.IsAdmin() {
+		return nil, true
+	}
+	return GetUserResourceIds(user.ID, resourceType), false
+}
+
+// 用户是否可以访问资源
+func (user *User) CanAccessResource(resourceType ResourceType, resourceId uint) bool {
+	ids, all := user.GetResourceIds(resourceType)
+	return all || slices.Contains(ids, resourceId)
+}
+
+// API Key的权限范围，空表示和所属用户的角色一致
+func (apiKey *ApiKey) GetScopes() []string {
+	if apiKey.Scopes == "" {
+		return nil
+	}
+	return strings.Split(apiKey.Scopes, ",")
+}
+
+// 使用API Key访问时实际拥有的权限范围：API Key的权限范围和用户角色权限范围的交集
+func (apiKey *ApiKey) GetEffectiveScopes(user *User) []string {
+	userScopes := user.GetScopes()
+	keyScopes := apiKey.GetScopes()
+	if len(keyScopes) == 0 {
+		return userScopes
+	}
+	scopes := make([]string, 0, len(keyScopes))
+	for _, scope := range keyScopes {
+		if slices.Contains(userScopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestApiKeyEffectiveScopes(t *testing.T) {
+	viewer := &User{Role: UserRoleViewer}
+	apiKey := &ApiKey{}
+	if scopes := apiKey.GetEffectiveScopes(viewer); !slices.Equal(scopes, RoleScopes[UserRoleViewer]) {
+		t.Errorf("没有设置权限范围的API Key应该和角色一致: %v", scopes)
+	}
+	// 超出角色的权限范围会被忽略
+	apiKey.Scopes = ScopeSyncRead + "," + ScopeSyncRun + "," + ScopePlayback
+	scopes := apiKey.GetEffectiveScopes(viewer)
+	if !slices.Equal(scopes, []string{ScopeSyncRead, ScopePlayback}) {
+		t.Errorf("API Key的权限范围应该是和角色的交集: %v", scopes)
+	}
+	// 旧数据没有角色的用户视为管理员
+	admin := &User{}
+	if !admin.IsAdmin() || !slices.Contains(apiKey.GetEffectiveScopes(admin), ScopeSyncRun) {
+		t.Errorf("没有角色的用户应该是管理员")
+	}
+}
+
+func TestCheckScopes(t *testing.T) {
+	if err := CheckScopes([]string{ScopeScrapeRead, ScopePlayback}); err != nil {
+		t.Errorf("有效的权限范围校验失败: %v", err)
+	}
+	if err := CheckScopes([]string{"sync:delete"}); err == nil {
+		t.Errorf("无效的权限范围应该校验失败")
+	}
+}
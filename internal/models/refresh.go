@@ -0,0 +1,107 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 是否开启了元数据刷新
+func (sp *ScrapePath) RefreshEnabled() bool {
+	if sp.RefreshCron == "" || (sp.RefreshAirDays <= 0 && !sp.RefreshPlaceholder) {
+		return false
+	}
+	return sp.MediaType == MediaTypeMovie || sp.MediaType == MediaTypeTvShow
+}
+
+// 检查元数据刷新规则
+func (sp *ScrapePath) CheckRefreshRules() error {
+	if sp.RefreshAirDays < 0 {
+		return fmt.Errorf("元数据刷新的天数不能小于0")
+	}
+	if sp.RefreshCron != "" && !sp.ValidateCronExpression(sp.RefreshCron) {
+		return fmt.Errorf("元数据刷新的Cron表达式 %s 无效", sp.RefreshCron)
+	}
+	return nil
+}
+
+// 只因为占位标题、没有播出时间或者没有剧照而刷新的集，两次尝试之间的最小间隔
+// TMDB长期没有补全的集不会在每次定时任务中重复刷新
+const placeholderRefreshInterval = 7 * 24 * time.Hour
+
+// 查询刮削目录中需要刷新元数据的已整理记录ID
+// 播出或上映时间在最近RefreshAirDays天内（包括未来）的条目，以及开启RefreshPlaceholder时标题是占位标题、没有播出时间或者没有剧照的集
+// 占位标题的集最近placeholderRefreshInterval内已经尝试过刷新的跳过
+func GetRefreshMediaFileIds(sp *ScrapePath, now time.Time) []uint {
+	finished := func() *gorm.DB {
+		return db.Db.Model(&ScrapeMediaFile{}).
+			Where("scrape_path_id = ? AND status = ? AND scrape_type != ? AND extra_type = ?", sp.ID, ScrapeMediaStatusRenamed, ScrapeTypeOnlyRename, "")
+	}
+	ids := make([]uint, 0)
+	if sp.RefreshAirDays > 0 {
+		since := now.AddDate(0, 0, -sp.RefreshAirDays).Format("2006-01-02")
+		var windowIds []uint
+		var err error
+		if sp.MediaType == MediaTypeTvShow {
+			episodes := db.Db.Model(&MediaEpisode{}).Where("scrape_path_id = ? AND release_date >= ?", sp.ID, since).Select("id")
+			err = finished().Where("media_episode_id IN (?)", episodes).Pluck("id", &windowIds).Error
+		} else {
+			medias := db.Db.Model(&Media{}).Where("scrape_path_id = ? AND release_date >= ?", sp.ID, since).Select("id")
+			err = finished().Where("media_id IN (?)", medias).Pluck("id", &windowIds).Error
+		}
+		if err != nil {
+			helpers.AppLogger.Errorf("查询刮削目录 %d 最近播出的条目失败: %v", sp.ID, err)
+		}
+		ids = append(ids, windowIds...)
+	}
+	if sp.RefreshPlaceholder && sp.MediaType == MediaTypeTvShow {
+		var episodes []*MediaEpisode
+		if err := db.Db.Where("scrape_path_id = ?", sp.ID).Select("id, episode_name, release_date, poster_path").Find(&episodes).Error; err != nil {
+			helpers.AppLogger.Errorf("查询刮削目录 %d 的集失败: %v", sp.ID, err)
+			return ids
+		}
+		seen := make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			seen[id] = true
+		}
+		episodeIds := make([]uint, 0)
+		for _, e := range episodes {
+			if e.ReleaseDate == "" || !e.HasStill() || helpers.IsPlaceholderTitle(e.EpisodeName) {
+				episodeIds = append(episodeIds, e.ID)
+			}
+		}
+		retryBefore := now.Add(-placeholderRefreshInterval).Unix()
+		for chunk := range slices.Chunk(episodeIds, 500) {
+			var placeholderIds []uint
+			if err := finished().Where("media_episode_id IN ? AND refresh_time < ?", chunk, retryBefore).Pluck("id", &placeholderIds).Error; err != nil {
+				helpers.AppLogger.Errorf("查询刮削目录 %d 占位标题的集失败: %v", sp.ID, err)
+				continue
+			}
+			for _, id := range placeholderIds {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// 是否有剧照，TMDB没有剧照时PosterPath只有图片地址前缀
+func (me *MediaEpisode) HasStill() bool {
+	return me.PosterPath != "" && !strings.HasSuffix(me.PosterPath, "/t/p/original")
+}
+
+// 记录最近一次尝试刷新元数据的时间，刷新失败也要记录，避免每次定时任务都重试
+func (sm *ScrapeMediaFile) Refreshed() {
+	sm.RefreshTime = time.Now().Unix()
+	if err := db.Db.Model(&ScrapeMediaFile{}).Where("id = ?", sm.ID).Update("refresh_time", sm.RefreshTime).Error; err != nil {
+		helpers.AppLogger.Errorf("更新刮削媒体刷新时间失败: id=%d %v", sm.ID, err)
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestScrapePathRefreshEnabled(t *testing.T) {
+	cases := []struct {
+		name string
+		sp   *ScrapePath
+		want bool
+	}{
+		{"未设置Cron", &ScrapePath{MediaType: MediaTypeTvShow, RefreshAirDays: 7}, false},
+		{"没有规则", &ScrapePath{MediaType: MediaTypeTvShow, RefreshCron: "0 3 * * *"}, false},
+		{"电视剧按播出时间", &ScrapePath{MediaType: MediaTypeTvShow, RefreshCron: "0 3 * * *", RefreshAirDays: 7}, true},
+		{"电视剧占位标题", &ScrapePath{MediaType: MediaTypeTvShow, RefreshCron: "0 3 * * *", RefreshPlaceholder: true}, true},
+		{"电影按上映时间", &ScrapePath{MediaType: MediaTypeMovie, RefreshCron: "0 3 * * *", RefreshAirDays: 30}, true},
+		{"其他类型", &ScrapePath{MediaType: MediaTypeOther, RefreshCron: "0 3 * * *", RefreshAirDays: 7}, false},
+	}
+	for _, c := range cases {
+		if got := c.sp.RefreshEnabled(); got != c.want {
+			t.Errorf("%s: RefreshEnabled() = %v; want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMediaEpisodeHasStill(t *testing.T) {
+	if (&MediaEpisode{PosterPath: "https://image.tmdb.org/t/p/original"}).HasStill() {
+		t.Error("HasStill() = true for url prefix only")
+	}
+	if !(&MediaEpisode{PosterPath: "https://image.tmdb.org/t/p/original/abc.jpg"}).HasStill() {
+		t.Error("HasStill() = false for full still url")
+	}
+}
+
+func TestGetRefreshMediaFileIdsPlaceholderBackoff(t *testing.T) {
+	helpers.AppLogger = &helpers.QLogger{Logger: log.New(io.Discard, "", 0)}
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := gdb.AutoMigrate(&ScrapeMediaFile{}, &MediaEpisode{}); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+	db.Db = gdb
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	sp := &ScrapePath{BaseModel: BaseModel{ID: 1}, MediaType: MediaTypeTvShow, RefreshCron: "0 3 * * *", RefreshAirDays: 7, RefreshPlaceholder: true}
+	episodes := []*MediaEpisode{
+		// 最近播出的集每次都刷新
+		{ScrapePathId: 1, EpisodeName: "第 1 集", ReleaseDate: "2026-09-30"},
+		// 很久以前播出的占位标题集
+		{ScrapePathId: 1, EpisodeName: "第 2 集", ReleaseDate: "2020-01-01"},
+		{ScrapePathId: 1, EpisodeName: "第 3 集", ReleaseDate: "2020-01-08"},
+	}
+	for _, e := range episodes {
+		db.Db.Create(e)
+	}
+	refreshTimes := []int64{now.Add(-time.Hour).Unix(), now.Add(-time.Hour).Unix(), now.Add(-placeholderRefreshInterval - time.Hour).Unix()}
+	files := make([]*ScrapeMediaFile, 0, len(episodes))
+	for i, e := range episodes {
+		file := &ScrapeMediaFile{ScrapePathId: 1, Status: ScrapeMediaStatusRenamed, ScrapeType: ScrapeTypeScrapeAndRename, MediaEpisodeId: e.ID, RefreshTime: refreshTimes[i]}
+		db.Db.Create(file)
+		files = append(files, file)
+	}
+	ids := GetRefreshMediaFileIds(sp, now)
+	slices.Sort(ids)
+	// 第2集最近已经尝试过刷新，等到间隔过后再刷新
+	if want := []uint{files[0].ID, files[2].ID}; !slices.Equal(ids, want) {
+		t.Errorf("GetRefreshMediaFileIds() = %v; want %v", ids, want)
+	}
+}
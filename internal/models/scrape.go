@@ -3,6 +3,7 @@ package models
 import (
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
 	"Q115-STRM/internal/openai"
 	"Q115-STRM/internal/tmdb"
 	"encoding/json"
@@ -33,6 +34,10 @@ type ScrapeSettings struct {
 	AiModelName       string   `json:"ai_model_name" form:"ai_model_name"`             // AI识别模型名称
 	AiPrompt          string   `json:"ai_prompt" form:"ai_prompt"`                     // AI识别提示词，如果留空则使用默认值
 	AiTimeout         int      `json:"ai_timeout" form:"ai_timeout"`                   // AI识别超时时间，单位秒，默认值为:120
+	TvdbApiKey        string   `json:"tvdb_api_key" form:"tvdb_api_key"`               // TheTVDB v4 API KEY
+	TvdbPin           string   `json:"tvdb_pin" form:"tvdb_pin"`                       // TheTVDB订阅PIN，可以为空
+	DoubanApiKey      string   `json:"douban_api_key" form:"douban_api_key"`           // 豆瓣API KEY
+	BangumiToken      string   `json:"bangumi_token" form:"bangumi_token"`             // Bangumi Access Token，可以为空
 }
 
 const (
@@ -118,6 +123,40 @@ func (s *ScrapeSettings) GetTmdbClient() *tmdb.Client {
 	return tmdb.NewClient(s.GetTmdbApiKey(), s.GetTmdbAccessToken(), s.GetTmdbApiUrl(), s.GetTmdbLanguage(), s.GetTmdbProxyUrl())
 }
 
+// 其他元数据提供者使用的配置
+func (s *ScrapeSettings) GetMetadataConfig() *metadata.Config {
+	return &metadata.Config{
+		Language:     s.GetTmdbLanguage(),
+		ProxyUrl:     s.GetTmdbProxyUrl(),
+		TmdbClient:   s.GetTmdbClient(),
+		TmdbImageUrl: s.GetTmdbImageUrl(),
+		TvdbApiKey:   s.TvdbApiKey,
+		TvdbPin:      s.TvdbPin,
+		DoubanApiKey: s.DoubanApiKey,
+		BangumiToken: s.BangumiToken,
+	}
+}
+
+// 保存其他元数据提供者的设置
+func (s *ScrapeSettings) SaveMetadataProviders(tvdbApiKey, tvdbPin, doubanApiKey, bangumiToken string) error {
+	s.TvdbApiKey = tvdbApiKey
+	s.TvdbPin = tvdbPin
+	s.DoubanApiKey = doubanApiKey
+	s.BangumiToken = bangumiToken
+	updateData := map[string]interface{}{
+		"tvdb_api_key":   tvdbApiKey,
+		"tvdb_pin":       tvdbPin,
+		"douban_api_key": doubanApiKey,
+		"bangumi_token":  bangumiToken,
+	}
+	if err := db.Db.Model(s).Updates(updateData).Error; err != nil {
+		helpers.AppLogger.Errorf("更新元数据提供者设置失败: %v", err)
+		return err
+	}
+	helpers.AppLogger.Infof("元数据提供者设置已成功更新，ID=%d", s.ID)
+	return nil
+}
+
 // 保存tmdb设置
 func (s *ScrapeSettings) SaveTmdb(apiKey, accessToken string, apiUrl string, imageUrl string, language string, imageLanguage string, enableProxy bool) error {
 	// 更新全局对象
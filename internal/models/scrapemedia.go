@@ -3,6 +3,7 @@ package models
 import (
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
 	"Q115-STRM/internal/notificationmanager"
 	"Q115-STRM/internal/tmdb"
 	"context"
@@ -32,6 +33,8 @@ const (
 	ScrapeMediaStatusIgnore       ScrapeMediaStatus = "ignore"        // 忽略
 	ScrapeMediaStatusScrapeFailed ScrapeMediaStatus = "scrape_failed" // 刮削失败
 	ScrapeMediaStatusRollbacking  ScrapeMediaStatus = "rollbacking"   // 回滚中
+	ScrapeMediaStatusNeedsReview  ScrapeMediaStatus = "needs_review"  // 识别结果可信度低，待手工确认
+	ScrapeMediaStatusExtra        ScrapeMediaStatus = "extra"         // 附属视频，等待正片整理后一起移动
 )
 
 type TmdbGender int
@@ -122,12 +125,26 @@ type TmdbInfo struct {
 	Credits      *tmdb.PepolesRes          `json:"credits"`       // 演职员信息
 	Images       *tmdb.Images              `json:"images"`        // 图片信息
 	ReleasesDate []tmdb.ReleasesDateResult `json:"releases_date"` // 发布日期信息
+	// 其他元数据提供者的详情，按优先级排列，主提供者不是TMDB时第一个是主提供者
+	PrimaryProvider string             `json:"primary_provider"`
+	ProviderDetails []*metadata.Detail `json:"provider_details"`
 }
 
 type MediaMetaFiles struct {
-	FileName string `json:"file_name"` // 文件名
-	FileId   string `json:"file_id"`   // 文件ID
-	PickCode string `json:"pick_code"` // 识别码
+	FileName string `json:"file_name"`          // 文件名
+	FileId   string `json:"file_id"`            // 文件ID
+	PickCode string `json:"pick_code"`          // 识别码
+	Language string `json:"language,omitempty"` // 字幕语言，ISO 639-1代码
+	Forced   bool   `json:"forced,omitempty"`   // 强制字幕
+	SDH      bool   `json:"sdh,omitempty"`      // 听障字幕
+}
+
+// 整理后的字幕文件名，识别出语言时使用 <视频文件名>.<语言>[.forced][.sdh].ext，否则只替换视频文件名部分
+func (sm *ScrapeMediaFile) NewSubtitleName(sub *MediaMetaFiles, oldBaseName string) string {
+	if sub.Language == "" {
+		return strings.Replace(sub.FileName, oldBaseName, sm.NewVideoBaseName, 1)
+	}
+	return helpers.SubtitleFileName(sm.NewVideoBaseName, helpers.SubtitleInfo{Language: sub.Language, Forced: sub.Forced, SDH: sub.SDH}, filepath.Ext(sub.FileName))
 }
 
 type WillDeleteFile struct {
@@ -163,6 +180,7 @@ type ScrapeMediaFile struct {
 	TmdbId               int64             `json:"tmdb_id"`                                         // TMDB ID，如果没有Media数据则使用该字段
 	SeasonNumber         int               `json:"season_number"`                                   // 季编号，例如：S01E01中的S01
 	EpisodeNumber        int               `json:"episode_number"`                                  // 集编号，例如：S01E01中的E01
+	AbsoluteEpisode      int               `json:"absolute_episode"`                                // 动画模式下文件名中的绝对集数，识别电视剧后换算成季和集，0表示不是绝对集数
 	Path                 string            `json:"path"`                                            // 媒体文件夹路径，相对ScrapePath.SourcePath的路径
 	PathId               string            `json:"path_id"`                                         // 媒体文件夹路径ID，local类型是绝对路径，网盘类型是文件ID
 	TvshowPath           string            `json:"tvshow_path"`                                     // 电视剧路径，相对ScrapePath.SourcePath的路径
@@ -205,6 +223,13 @@ type ScrapeMediaFile struct {
 	MediaSeason          *MediaSeason      `json:"-" gorm:"-"`                                      // 季信息
 	MediaEpisode         *MediaEpisode     `json:"-" gorm:"-"`                                      // 集信息
 	ScrapeRootPath       string            `json:"scrape_root_path" gorm:"-"`                       // 刮削根目录
+	MatchConfidence      float64           `json:"match_confidence"`                                // 识别结果可信度，0-1
+	MatchCandidates      []*MatchCandidate `json:"match_candidates" gorm:"-"`                       // 待确认时的候选条目
+	MatchCandidatesJson  string            `json:"-"`                                               // 候选条目json字符串
+	ExtraType            ExtraType         `json:"extra_type"`                                      // 附属视频类型，空表示正片
+	ExtraOwnerPath       string            `json:"extra_owner_path" gorm:"index"`                   // 附属视频对应的正片所在文件夹
+	Edition              string            `json:"edition"`                                         // 版本，例如导演剪辑版
+	RefreshTime          int64             `json:"refresh_time"`                                    // 整理后最近一次刷新元数据的时间
 }
 
 func (sm *ScrapeMediaFile) Save() error {
@@ -261,6 +286,14 @@ func (sm *ScrapeMediaFile) DecodeJson() {
 		}
 		sm.SeasonFiles = seasonFiles
 	}
+	// 解码候选条目json字符串
+	if sm.MatchCandidatesJson != "" {
+		candidates, err := helpers.StringJson[[]*MatchCandidate](sm.MatchCandidatesJson)
+		if err != nil {
+			helpers.AppLogger.Errorf("解码候选条目失败: %v", err)
+		}
+		sm.MatchCandidates = candidates
+	}
 	// 解码视频编码json字符串
 	if sm.VideoCodecJson != "" {
 		videoCodec, err := helpers.StringJson[*VideoCodec](sm.VideoCodecJson)
@@ -426,6 +459,10 @@ func (sm *ScrapeMediaFile) ScrapeFinish() {
 	}
 	sm.Media.Status = MediaStatusScraped
 	sm.Media.Save()
+	// 记录网盘文件的TMDB信息，播放失败时可以找到同一部电影或者同一集的其他副本
+	if sm.VideoPickCode != "" {
+		RecordFileCopy(sm.VideoPickCode)
+	}
 }
 
 func (sm *ScrapeMediaFile) StatusScrapeFinish() {
@@ -622,7 +659,7 @@ func (sm *ScrapeMediaFile) buildTemplateContext() pongo2.Context {
 		"year":          sm.Year,
 		"tmdbid":        sm.TmdbId,
 		"videoFormat":   sm.Resolution,
-		"edition":       sm.ResolutionLevel,
+		"edition":       pongo2.AsSafeValue(sm.GetEdition()), // 文件名不需要HTML转义
 		"fileExt":       sm.VideoExt,
 		"original_name": sm.VideoFilename,
 	}
@@ -682,9 +719,21 @@ func (sm *ScrapeMediaFile) buildTemplateContext() pongo2.Context {
 		}
 	}
 
+	if sm.MediaType == MediaTypeMusic && sm.Media != nil {
+		ctx["artist"] = CleanMusicName(sm.Media.Artist)
+		ctx["album"] = CleanMusicName(sm.Media.Album)
+		ctx["track"] = fmt.Sprintf("%02d", sm.Media.TrackNumber)
+		ctx["disc"] = sm.Media.DiscNumber
+	}
+
 	return ctx
 }
 
+// CleanMusicName 艺术家、专辑和曲目名称会作为目录名或文件名，不能包含路径分隔符
+func CleanMusicName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+}
+
 func (sm *ScrapeMediaFile) renderNewTemplate(template string) string {
 	ctx := sm.buildTemplateContext()
 	tpl, err := pongo2.FromString(template)
@@ -721,6 +770,7 @@ func (sm *ScrapeMediaFile) GenerateNameByTemplate(template string) string {
 	} else {
 		newName = strings.ReplaceAll(newName, "{resolution_level}", "")
 	}
+	newName = strings.ReplaceAll(newName, "{edition}", sm.GetEdition())
 	if sm.VideoCodec != nil && sm.VideoCodec.Bitrate != 0 {
 		newName = strings.ReplaceAll(newName, "{bitrate}", fmt.Sprintf("%dMbps", sm.VideoCodec.Bitrate/1000000))
 	} else {
@@ -793,6 +843,12 @@ func (sm *ScrapeMediaFile) GenerateNameByTemplate(template string) string {
 			newName = strings.ReplaceAll(newName, "{episode_name}", "")
 		}
 	}
+	if sm.MediaType == MediaTypeMusic && sm.Media != nil {
+		newName = strings.ReplaceAll(newName, "{artist}", CleanMusicName(sm.Media.Artist))
+		newName = strings.ReplaceAll(newName, "{album}", CleanMusicName(sm.Media.Album))
+		newName = strings.ReplaceAll(newName, "{track}", fmt.Sprintf("%02d", sm.Media.TrackNumber))
+		newName = strings.ReplaceAll(newName, "{disc}", fmt.Sprintf("%d", sm.Media.DiscNumber))
+	}
 	return newName
 }
 
@@ -912,6 +968,8 @@ func (sm *ScrapeMediaFile) ReScrape(name string, year int, tmdbId int64, season
 					return serr
 				} else {
 					sm.SeasonNumber = season
+					// 手工指定了季，不再按动画绝对集数换算
+					sm.AbsoluteEpisode = 0
 					hasEdit = true
 				}
 				// 检查集是否存在
@@ -969,7 +1027,7 @@ func (sm *ScrapeMediaFile) ReScrape(name string, year int, tmdbId int64, season
 }
 
 func (sm *ScrapeMediaFile) ExtractSeasonEpisode(sp *ScrapePath) error {
-	if sm.EpisodeNumber == -1 {
+	if sm.EpisodeNumber == -1 && !sm.ExtractAnimeEpisode(sp) {
 		// 先识别季集
 		info := helpers.ExtractMediaInfoRe(sm.VideoFilename, false, true, sp.VideoExtList, sp.DeleteKeyword...)
 		if info == nil {
@@ -1012,6 +1070,7 @@ func (sm *ScrapeMediaFile) ExtractSeasonEpisode(sp *ScrapePath) error {
 			helpers.AppLogger.Infof("从电视剧文件夹中提取到季数: %d", sm.SeasonNumber)
 		}
 	}
+	sm.CheckAbsoluteEpisode()
 	if sm.SeasonNumber == -1 {
 		sm.SeasonNumber = 1
 	}
@@ -1190,11 +1249,16 @@ func GetScrapeMediaByVideoFileId(videoFileId string) (*ScrapeMediaFile, error) {
 // id倒序
 // 先查询总数
 // 再查询列表
-func GetScrapeMediaFiles(page int, pageSize int, mediaType string, status string, name string) (int64, []*ScrapeMediaFile) {
+// scrapePathIds 为nil时查询所有刮削目录的记录
+func GetScrapeMediaFiles(page int, pageSize int, mediaType string, status string, name string, scrapePathIds []uint) (int64, []*ScrapeMediaFile) {
 	offset := (page - 1) * pageSize
 	var scrapeMediaFiles []*ScrapeMediaFile
 	tx := db.Db.Order("id desc").Offset(offset).Limit(pageSize).Order("id DESC")
 	txc := db.Db.Model(&ScrapeMediaFile{})
+	if scrapePathIds != nil {
+		tx.Where("scrape_path_id IN ?", scrapePathIds)
+		txc.Where("scrape_path_id IN ?", scrapePathIds)
+	}
 	condition := make(map[string]interface{})
 	if mediaType != "" {
 		condition["media_type"] = mediaType
@@ -1447,11 +1511,16 @@ func UpdateScrapeMediaStatus(old, new ScrapeMediaStatus, scrapePathId uint) {
 }
 
 // 清除所有刮削失败的记录，包括subtitlefiles
-func ClearFailedScrapeRecords(ids []uint) error {
+// ids 为空时清除scrapePathIds中所有失败的记录，scrapePathIds 为nil表示所有刮削目录
+func ClearFailedScrapeRecords(ids []uint, scrapePathIds []uint) error {
 	// 查询所有失败的记录
 	var failedScrapeMediaFiles []*ScrapeMediaFile
 	if len(ids) == 0 {
-		if err := db.Db.Where("status = ?", ScrapeMediaStatusScrapeFailed).Find(&failedScrapeMediaFiles).Error; err != nil {
+		tx := db.Db.Where("status = ?", ScrapeMediaStatusScrapeFailed)
+		if scrapePathIds != nil {
+			tx = tx.Where("scrape_path_id IN ?", scrapePathIds)
+		}
+		if err := tx.Find(&failedScrapeMediaFiles).Error; err != nil {
 			helpers.AppLogger.Errorf("查询所有失败的记录失败: %v", err)
 			return err
 		}
@@ -4,6 +4,8 @@ import (
 	"Q115-STRM/internal/baidupan"
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/openai"
 	"Q115-STRM/internal/openlist"
 	"Q115-STRM/internal/v115open"
@@ -27,6 +29,7 @@ const (
 	MediaTypeMovie  MediaType = "movie"  // 电影
 	MediaTypeTvShow MediaType = "tvshow" // 剧集
 	MediaTypeOther  MediaType = "other"  // 其他，无法刮削
+	MediaTypeMusic  MediaType = "music"  // 音乐，按标签整理
 )
 
 type RenameType string
@@ -57,6 +60,7 @@ const (
 
 var SubtitleExtArr = []string{".ass", ".srt", ".ssa", ".vtt", ".sup", ".idx", ".sub"}
 var ImageExtArr = []string{".jpg", ".png", ".jpeg", ".gif"}
+var MusicExtArr = []string{".mp3", ".flac", ".ogg", ".opus", ".m4a", ".wav", ".ape", ".wma", ".aac"}
 var AllowdExtArr = append(SubtitleExtArr, append(ImageExtArr, []string{".nfo", ".mp3", ".flac", ".aas"}...)...)
 
 type ScrapePathCategoryCollection struct {
@@ -98,11 +102,27 @@ type ScrapePath struct {
 	NextCronRun           string                       `json:"next_cron_run" form:"next_cron_run"`                       // 下次执行时间
 	CronEnabled           int                          `json:"cron_enabled" form:"cron_enabled"`                         // 定时任务启用状态（0/1）
 	EnableFanartTv        bool                         `json:"enable_fanart_tv" form:"enable_fanart_tv"`                 // 是否启用 fanart.tv，开启时会从 fanart.tv 下载高清图
+	MetadataProvider      string                       `json:"metadata_provider" form:"metadata_provider"`               // 主元数据提供者：tmdb、tvdb、douban、bangumi，为空时使用tmdb
+	FallbackProviders     string                       `json:"fallback_providers" form:"fallback_providers"`             // 后备元数据提供者，逗号分隔，按顺序补充主提供者缺少的信息
+	AnimeMode             bool                         `json:"anime_mode" form:"anime_mode"`                             // 动画模式，仅电视剧有效，按发布组格式识别绝对集数并换算成TMDB的季和集
+	AnimeEpisodeOffsets   string                       `json:"anime_episode_offsets" form:"anime_episode_offsets"`       // 动画绝对集数偏移表，json字符串，例如：[{"season":2,"start":26}]，为空时使用TMDB每季的集数换算
+	EnableMusicBrainz     bool                         `json:"enable_musicbrainz" form:"enable_musicbrainz"`             // 是否从MusicBrainz补全专辑信息，仅音乐有效
+	ReviewThreshold       float64                      `json:"review_threshold" form:"review_threshold"`                 // 识别结果可信度阈值（0-1），低于阈值时放入待确认队列，0表示不检查
+	ExtractSubtitles      bool                         `json:"extract_subtitles" form:"extract_subtitles"`               // 将内封的文本字幕提取为外挂字幕，仅本地来源支持
+	CollectionPath        string                       `json:"collection_path" form:"collection_path"`                   // 本地合集目录，设置后为每个电影合集生成海报和背景图，为空不生成
+	RefreshCron           string                       `json:"refresh_cron" form:"refresh_cron"`                         // 元数据刷新的Cron表达式，为空不刷新已整理的元数据
+	RefreshAirDays        int                          `json:"refresh_air_days" form:"refresh_air_days"`                 // 刷新播出或上映时间在最近N天内以及未来的条目，0表示不按时间刷新
+	RefreshPlaceholder    bool                         `json:"refresh_placeholder" form:"refresh_placeholder"`           // 刷新标题为占位标题（TBA、第N集）、没有播出时间或者没有剧照的集
+	NfoProfile            string                       `json:"nfo_profile" form:"nfo_profile"`                           // nfo格式：kodi、emby、jellyfin，为空时生成通用格式
+	NfoExtraFields        string                       `json:"nfo_extra_fields" form:"nfo_extra_fields"`                 // 自定义nfo字段，json字符串，例如：[{"name":"tag","value":"{{ videoFormat }}","target":"movie"}]
+	NfoPreserveEdits      bool                         `json:"nfo_preserve_edits" form:"nfo_preserve_edits"`             // 重新生成nfo时保留媒体服务器中锁定的字段（lockdata、lockedfields）
+	NfoLockedFields       string                       `json:"nfo_locked_fields" form:"nfo_locked_fields"`               // 重新生成nfo时始终保留的元素，逗号分隔，例如：title,plot,tag
 	IsScraping            bool                         `json:"is_scraping" form:"is_scraping"`                           // 是否正在刮削
 	MaxThreads            int                          `json:"max_threads" form:"max_threads"`                           // 刮削最大线程数，默认值为5
 	V115Client            *v115open.OpenClient         `json:"-" gorm:"-"`                                               // 115客户端
 	BaiduPanClient        *baidupan.Client             `json:"-" gorm:"-"`                                               // 百度网盘客户端
 	OpenListClient        *openlist.Client             `json:"-" gorm:"-"`                                               // openlist客户端
+	Open123Client         *open123.Client              `json:"-" gorm:"-"`                                               // 123云盘客户端
 	ExistsFiles           map[string]bool              `json:"-" gorm:"-"`                                               // 已存在的文件，key为文件路径，value为是否存在
 	ScrapeRootPath        string                       `json:"-" gorm:"-"`                                               // 刮削根路径
 	Category              ScrapePathCategoryCollection `json:"-" gorm:"-"`
@@ -237,6 +257,21 @@ func (m *ScrapePath) Save() error {
 			"exclude_no_image_actor":   m.ExcludeNoImageActor,
 			"force_delete_source_path": m.ForceDeleteSourcePath,
 			"enable_fanart_tv":         m.EnableFanartTv,
+			"metadata_provider":        m.MetadataProvider,
+			"fallback_providers":       m.FallbackProviders,
+			"anime_mode":               m.AnimeMode,
+			"anime_episode_offsets":    m.AnimeEpisodeOffsets,
+			"enable_musicbrainz":       m.EnableMusicBrainz,
+			"review_threshold":         m.ReviewThreshold,
+			"extract_subtitles":        m.ExtractSubtitles,
+			"collection_path":          m.CollectionPath,
+			"refresh_cron":             m.RefreshCron,
+			"refresh_air_days":         m.RefreshAirDays,
+			"refresh_placeholder":      m.RefreshPlaceholder,
+			"nfo_profile":              m.NfoProfile,
+			"nfo_extra_fields":         m.NfoExtraFields,
+			"nfo_preserve_edits":       m.NfoPreserveEdits,
+			"nfo_locked_fields":        m.NfoLockedFields,
 			"max_threads":              m.MaxThreads,
 			"enable_cron":              m.EnableCron,
 			"cron_expression":          m.CronExpression,
@@ -316,6 +351,38 @@ func (sp *ScrapePath) Init() bool {
 	return true
 }
 
+// 主元数据提供者，未设置时使用TMDB
+func (sp *ScrapePath) GetMetadataProvider() string {
+	if sp.MetadataProvider == "" {
+		return metadata.ProviderTmdb
+	}
+	return sp.MetadataProvider
+}
+
+// 后备元数据提供者列表，去掉空值、重复值和主提供者
+func (sp *ScrapePath) GetFallbackProviders() []string {
+	primary := sp.GetMetadataProvider()
+	providers := make([]string, 0)
+	for _, name := range strings.Split(sp.FallbackProviders, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == primary || slices.Contains(providers, name) {
+			continue
+		}
+		providers = append(providers, name)
+	}
+	return providers
+}
+
+// 检查元数据提供者是否都已支持
+func (sp *ScrapePath) CheckMetadataProviders() error {
+	for _, name := range append([]string{sp.GetMetadataProvider()}, sp.GetFallbackProviders()...) {
+		if !metadata.IsRegistered(name) {
+			return fmt.Errorf("不支持的元数据提供者: %s", name)
+		}
+	}
+	return nil
+}
+
 func (sp *ScrapePath) GetMaxThreads() int {
 	if sp.MaxThreads <= 0 {
 		return DEFAULT_LOCAL_MAX_THREADS
@@ -403,6 +470,12 @@ func (sp *ScrapePath) GetDownloadUrl(videoPathOrUrl string) string {
 	case SourceTypeOpenList:
 		videoPathOrUrl = sp.OpenListClient.GetRawUrl(videoPathOrUrl)
 	case SourceType123:
+		link, err := sp.Open123Client.GetDirectLink(context.Background(), helpers.StringToInt64(videoPathOrUrl))
+		if err != nil {
+			helpers.AppLogger.Errorf("获取123云盘下载链接失败: %v", err)
+			return ""
+		}
+		videoPathOrUrl = link
 	}
 	return videoPathOrUrl
 }
@@ -410,7 +483,7 @@ func (sp *ScrapePath) GetDownloadUrl(videoPathOrUrl string) string {
 // 给刮削目录生成二级目录文件夹
 // 先检查是否有对应的数据库纪录,然后比对目录分类和分类列表的差异,没有的创建,删除的删除,改名的改名
 func (sp *ScrapePath) GenerateCategory() {
-	if !sp.EnableCategory {
+	if !sp.EnableCategory || sp.IsMusic() {
 		helpers.AppLogger.Infof("刮削目录 %s 未启用二级分类", sp.SourcePath)
 		return
 	}
@@ -544,6 +617,26 @@ func (sp *ScrapePath) GenerateCategory() {
 			fileId = filepath.Join(sp.DestPathId, category.Name)
 			os.MkdirAll(fileId, 0777)
 		case SourceType123:
+			// 先在目标目录下查找同名目录，没有再创建
+			files, listErr := sp.Open123Client.ListAllFiles(context.Background(), helpers.StringToInt64(sp.DestPathId))
+			if listErr != nil {
+				helpers.AppLogger.Errorf("查询123云盘目录失败: %v", listErr)
+				continue
+			}
+			for _, f := range files {
+				if f.IsDir() && f.FileName == category.Name {
+					fileId = helpers.Int64ToString(f.FileID)
+					break
+				}
+			}
+			if fileId == "" {
+				resp, mkErr := sp.Open123Client.CreateFolder(context.Background(), category.Name, helpers.StringToInt64(sp.DestPathId))
+				if mkErr != nil {
+					helpers.AppLogger.Errorf("创建123云盘目录失败: %v", mkErr)
+					continue
+				}
+				fileId = helpers.Int64ToString(resp.DirID)
+			}
 		case SourceTypeBaiduPan:
 			fileId = sp.DestPathId + "/" + category.Name
 			// 先查询是否存在
@@ -612,7 +705,7 @@ func (sp *ScrapePath) CheckFileIsAllowed(fileName string, fileSize int64) bool {
 		helpers.AppLogger.Infof("非视频或元数据文件不需要处理: %s", fileName)
 		return false // 如果不需要处理，则跳过
 	}
-	if sp.IsVideoFile(fileName) && fileSize < sp.MinVideoFileSize*1024*1024 {
+	if sp.IsVideoFile(fileName) && !sp.IsMusic() && fileSize < sp.MinVideoFileSize*1024*1024 {
 		helpers.AppLogger.Infof("视频文件%s大小%d小于%d最小要求，不需要处理", fileName, fileSize, sp.MinVideoFileSize*1024*1024)
 		return false // 如果不需要处理，则跳过
 	}
@@ -928,6 +1021,9 @@ func (sp *ScrapePath) Decode() error {
 		if err != nil {
 			return fmt.Errorf("转换视频文件扩展名列表失败: %v", err)
 		}
+	} else if sp.IsMusic() {
+		// 音乐没有配置扩展名时使用常见的音频格式
+		sp.VideoExtList = MusicExtArr
 	} else {
 		sp.VideoExtList = helpers.GlobalConfig.Strm.VideoExt
 	}
@@ -11,12 +11,13 @@ import (
 var V115Login bool
 
 type SettingThreads struct {
-	DownloadThreads    int `form:"download_threads" json:"download_threads" binding:"required" gorm:"default:1"`          // 下载QPS
-	FileDetailThreads  int `form:"file_detail_threads" json:"file_detail_threads" binding:"required" gorm:"default:1"`    // 115接口QPS
-	OpenlistQPS        int `form:"openlist_qps" json:"openlist_qps" binding:"required" gorm:"default:3"`                  // OpenList QPS
-	OpenlistRetry      int `form:"openlist_retry" json:"openlist_retry" binding:"required" gorm:"default:1"`              // OpenList 重试次数
-	OpenlistRetryDelay int `form:"openlist_retry_delay" json:"openlist_retry_delay" binding:"required" gorm:"default:60"` // OpenList 重试间隔，单位秒
-	FileListPageSize   int `form:"file_list_page_size" json:"file_list_page_size" gorm:"default:1150"`                    // 115文件列表每页查询数量，范围100-1150
+	DownloadThreads       int   `form:"download_threads" json:"download_threads" binding:"required" gorm:"default:1"`          // 下载QPS
+	FileDetailThreads     int   `form:"file_detail_threads" json:"file_detail_threads" binding:"required" gorm:"default:1"`    // 115接口QPS
+	OpenlistQPS           int   `form:"openlist_qps" json:"openlist_qps" binding:"required" gorm:"default:3"`                  // OpenList QPS
+	OpenlistRetry         int   `form:"openlist_retry" json:"openlist_retry" binding:"required" gorm:"default:1"`              // OpenList 重试次数
+	OpenlistRetryDelay    int   `form:"openlist_retry_delay" json:"openlist_retry_delay" binding:"required" gorm:"default:60"` // OpenList 重试间隔，单位秒
+	FileListPageSize      int   `form:"file_list_page_size" json:"file_list_page_size" gorm:"default:1150"`                    // 115文件列表每页查询数量，范围100-1150
+	DiskSyncCacheMinFiles int64 `form:"disk_sync_cache_min_files" json:"disk_sync_cache_min_files" gorm:"default:100000"`      // 同步路径的文件数达到该值时使用磁盘同步缓存，0表示使用默认值
 }
 
 type SettingStrm struct {
@@ -35,6 +36,7 @@ type SettingStrm struct {
 	DeleteDir      int      `form:"delete_dir" json:"delete_dir" gorm:"default: 1"`            // 是否删除目录，-1表示使用STRM设置，0表示不删除，1表示删除
 	AddPath        int      `form:"add_path" json:"add_path" gorm:"default: 2"`                // 是否添加路径，默认-1(使用settings的值), 1- 表示添加路径， 2-表示不添加路径
 	CheckMetaMtime int      `form:"check_meta_mtime" json:"check_meta_mtime" gorm:"default:0"` // 是否检查元数据文件修改时间，默认-1(使用settings的值), 0表示不检查，1表示检查
+	SignExpire     int64    `form:"sign_expire" json:"sign_expire" gorm:"default:0"`           // STRM链接签名有效期，单位秒，-1表示使用STRM设置，0表示永不过期
 }
 
 type Settings struct {
@@ -49,16 +51,19 @@ type Settings struct {
 	EmbyApiKey       string `json:"emby_api_key"`       // @deprecated 已迁移到EmbyConfig Emby的API Key
 	HttpProxy        string `json:"http_proxy"`         // HTTP代理地址
 	// LocalProxy       int    `json:"local_proxy" gorm:"default:0"` // 是否启用本地代理，0表示不启用，1表示启用
+
+	StrmSignMode int `json:"strm_sign_mode" gorm:"default:0"` // STRM链接签名模式，0-关闭，1-兼容（未签名的旧链接依然可用），2-强制
 }
 
 func (t SettingThreads) ToMap() map[string]any {
 	return map[string]any{
-		"download_threads":     t.DownloadThreads,
-		"file_detail_threads":  t.FileDetailThreads,
-		"openlist_qps":         t.OpenlistQPS,
-		"openlist_retry":       t.OpenlistRetry,
-		"openlist_retry_delay": t.OpenlistRetryDelay,
-		"file_list_page_size":  t.FileListPageSize,
+		"download_threads":          t.DownloadThreads,
+		"file_detail_threads":       t.FileDetailThreads,
+		"openlist_qps":              t.OpenlistQPS,
+		"openlist_retry":            t.OpenlistRetry,
+		"openlist_retry_delay":      t.OpenlistRetryDelay,
+		"file_list_page_size":       t.FileListPageSize,
+		"disk_sync_cache_min_files": t.DiskSyncCacheMinFiles,
 	}
 }
 
@@ -74,6 +79,7 @@ func (s SettingStrm) ToMap(isDb bool, isSetting bool) map[string]any {
 		"add_path":         s.AddPath,
 		"check_meta_mtime": s.CheckMetaMtime,
 		"local_proxy":      s.LocalProxy,
+		"sign_expire":      s.SignExpire,
 	}
 	if s.Cron == "" && isSetting {
 		dataMap["cron"] = helpers.GlobalConfig.Strm.Cron // 使用默认配置
@@ -289,3 +295,15 @@ func GetFileListPageSize() int {
 	}
 	return pageSize
 }
+
+// 默认文件数达到10万时使用磁盘同步缓存
+const DefaultDiskSyncCacheMinFiles int64 = 100000
+
+// GetDiskSyncCacheMinFiles 获取使用磁盘同步缓存的文件数阈值
+// 如果配置不存在或小于等于0，返回默认值100000
+func GetDiskSyncCacheMinFiles() int64 {
+	if SettingsGlobal.DiskSyncCacheMinFiles <= 0 {
+		return DefaultDiskSyncCacheMinFiles
+	}
+	return SettingsGlobal.DiskSyncCacheMinFiles
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// STRM链接签名模式
+type StrmSignMode int
+
+const (
+	StrmSignModeOff     StrmSignMode = 0 // 不签名也不校验
+	StrmSignModeCompat  StrmSignMode = 1 // 生成签名，校验带签名的请求，没有签名的旧STRM依然可以播放（迁移期使用）
+	StrmSignModeEnforce StrmSignMode = 2 // 生成签名，没有签名或者签名错误的请求全部拒绝
+)
+
+var (
+	ErrStrmSignMissing = errors.New("STRM链接缺少签名")
+	ErrStrmSignInvalid = errors.New("STRM链接签名错误")
+	ErrStrmSignExpired = errors.New("STRM链接已过期")
+	ErrStrmSignNoKey   = errors.New("STRM签名密钥不存在或已被删除")
+)
+
+// STRM链接签名密钥，支持轮换
+// 轮换后旧密钥依然可以校验，直到被删除
+type StrmSignKey struct {
+	BaseModel
+	Secret    string `json:"-"`                              // HMAC密钥，不返回给前端
+	IsActive  bool   `json:"is_active" gorm:"default:false"` // 是否是当前用于签名的密钥，只有一个
+	RetiredAt int64  `json:"retired_at" gorm:"default:0"`    // 被轮换下来的时间
+}
+
+func (*StrmSignKey) TableName() string {
+	return "strm_sign_keys"
+}
+
+// 内存中缓存的密钥，避免每次播放都查库
+var (
+	strmSignKeys      map[uint]*StrmSignKey
+	strmSignActiveKey *StrmSignKey
+	strmSignKeysMutex sync.RWMutex
+)
+
+func generateStrmSignSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成随机字节失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 从数据库加载所有签名密钥
+func LoadStrmSignKeys() {
+	var keys []*StrmSignKey
+	if err := db.Db.Model(&StrmSignKey{}).Order("id ASC").Find(&keys).Error; err != nil {
+		helpers.AppLogger.Errorf("加载STRM签名密钥失败: %v", err)
+		return
+	}
+	strmSignKeysMutex.Lock()
+	defer strmSignKeysMutex.Unlock()
+	strmSignKeys = make(map[uint]*StrmSignKey, len(keys))
+	strmSignActiveKey = nil
+	for _, key := range keys {
+		strmSignKeys[key.ID] = key
+		if key.IsActive {
+			strmSignActiveKey = key
+		}
+	}
+}
+
+// 获取当前用于签名的密钥，如果没有则创建一个
+func GetActiveStrmSignKey() *StrmSignKey {
+	strmSignKeysMutex.RLock()
+	key := strmSignActiveKey
+	strmSignKeysMutex.RUnlock()
+	if key != nil {
+		return key
+	}
+	key, err := RotateStrmSignKey()
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+// 获取所有签名密钥
+func GetStrmSignKeys() []*StrmSignKey {
+	var keys []*StrmSignKey
+	if err := db.Db.Model(&StrmSignKey{}).Order("id DESC").Find(&keys).Error; err != nil {
+		helpers.AppLogger.Errorf("查询STRM签名密钥失败: %v", err)
+		return []*StrmSignKey{}
+	}
+	return keys
+}
+
+// 轮换签名密钥：生成新密钥作为当前密钥，旧密钥标记为已轮换但依然可以校验
+func RotateStrmSignKey() (*StrmSignKey, error) {
+	secret, err := generateStrmSignSecret()
+	if err != nil {
+		helpers.AppLogger.Errorf("生成STRM签名密钥失败: %v", err)
+		return nil, err
+	}
+	newKey := &StrmSignKey{Secret: secret, IsActive: true}
+	tx := db.Db.Begin()
+	if err := tx.Model(&StrmSignKey{}).Where("is_active = ?", true).Updates(map[string]any{"is_active": false, "retired_at": time.Now().Unix()}).Error; err != nil {
+		tx.Rollback()
+		helpers.AppLogger.Errorf("标记旧的STRM签名密钥失败: %v", err)
+		return nil, err
+	}
+	if err := tx.Create(newKey).Error; err != nil {
+		tx.Rollback()
+		helpers.AppLogger.Errorf("保存STRM签名密钥失败: %v", err)
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		helpers.AppLogger.Errorf("保存STRM签名密钥失败: %v", err)
+		return nil, err
+	}
+	helpers.AppLogger.Infof("已生成新的STRM签名密钥，ID=%d", newKey.ID)
+	LoadStrmSignKeys()
+	return newKey, nil
+}
+
+// 删除已轮换的密钥，删除后使用该密钥签名的STRM链接将无法播放
+func DeleteStrmSignKey(id uint) error {
+	var key StrmSignKey
+	if err := db.Db.First(&key, id).Error; err != nil {
+		return ErrStrmSignNoKey
+	}
+	if key.IsActive {
+		return errors.New("不能删除当前正在使用的签名密钥，请先轮换")
+	}
+	if err := db.Db.Delete(&key).Error; err != nil {
+		helpers.AppLogger.Errorf("删除STRM签名密钥失败: %v", err)
+		return err
+	}
+	LoadStrmSignKeys()
+	return nil
+}
+
+func getStrmSignKeyById(id uint) *StrmSignKey {
+	strmSignKeysMutex.RLock()
+	defer strmSignKeysMutex.RUnlock()
+	return strmSignKeys[id]
+}
+
+func makeStrmSign(secret, pickCode, userId string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(fmt.Appendf(nil, "%s\n%s\n%d", pickCode, userId, exp))
+	// 只取前16字节，够用且让STRM链接短一些
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// 使用当前密钥给STRM链接签名
+// expire 有效期，单位秒，0表示永不过期
+// 返回密钥ID，过期时间戳和签名
+func SignStrm(pickCode, userId string, expire int64) (kid uint, exp int64, sign string, err error) {
+	key := GetActiveStrmSignKey()
+	if key == nil {
+		return 0, 0, "", ErrStrmSignNoKey
+	}
+	if expire > 0 {
+		exp = time.Now().Unix() + expire
+	}
+	return key.ID, exp, makeStrmSign(key.Secret, pickCode, userId, exp), nil
+}
+
+// 校验STRM链接签名
+func VerifyStrmSign(pickCode, userId string, exp int64, kid uint, sign string) error {
+	if sign == "" {
+		return ErrStrmSignMissing
+	}
+	key := getStrmSignKeyById(kid)
+	if key == nil {
+		return ErrStrmSignNoKey
+	}
+	expected := makeStrmSign(key.Secret, pickCode, userId, exp)
+	if !hmac.Equal([]byte(expected), []byte(sign)) {
+		return ErrStrmSignInvalid
+	}
+	if exp > 0 && exp < time.Now().Unix() {
+		return ErrStrmSignExpired
+	}
+	return nil
+}
+
+// 按当前签名模式校验STRM链接，返回nil表示允许播放
+func CheckStrmSign(pickCode, userId string, exp int64, kid uint, sign string) error {
+	mode := GetStrmSignMode()
+	if mode == StrmSignModeOff {
+		return nil
+	}
+	if sign == "" && mode == StrmSignModeCompat {
+		// 兼容模式下未签名的旧STRM依然可以播放
+		return nil
+	}
+	return VerifyStrmSign(pickCode, userId, exp, kid, sign)
+}
+
+// 当前的签名模式
+func GetStrmSignMode() StrmSignMode {
+	return StrmSignMode(SettingsGlobal.StrmSignMode)
+}
+
+func (settings *Settings) UpdateStrmSignMode(mode StrmSignMode) bool {
+	settings.StrmSignMode = int(mode)
+	err := db.Db.Model(settings).Where("id = ?", settings.ID).Update("strm_sign_mode", int(mode)).Error
+	if err != nil {
+		helpers.AppLogger.Errorf("更新STRM签名模式失败: %v", err)
+		return false
+	}
+	return true
+}
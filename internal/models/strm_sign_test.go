@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func setTestStrmSignKeys(keys ...*StrmSignKey) {
+	strmSignKeysMutex.Lock()
+	defer strmSignKeysMutex.Unlock()
+	strmSignKeys = make(map[uint]*StrmSignKey)
+	strmSignActiveKey = nil
+	for _, key := range keys {
+		strmSignKeys[key.ID] = key
+		if key.IsActive {
+			strmSignActiveKey = key
+		}
+	}
+}
+
+func TestSignAndVerifyStrm(t *testing.T) {
+	setTestStrmSignKeys(&StrmSignKey{BaseModel: BaseModel{ID: 1}, Secret: "secret-1", IsActive: true})
+
+	kid, exp, sign, err := SignStrm("pickcode123", "10001", 0)
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if kid != 1 || exp != 0 || sign == "" {
+		t.Fatalf("签名结果不正确: kid=%d exp=%d sign=%s", kid, exp, sign)
+	}
+	if err := VerifyStrmSign("pickcode123", "10001", exp, kid, sign); err != nil {
+		t.Errorf("正确的签名校验失败: %v", err)
+	}
+	if err := VerifyStrmSign("pickcode456", "10001", exp, kid, sign); !errors.Is(err, ErrStrmSignInvalid) {
+		t.Errorf("篡改pickcode应该校验失败, got %v", err)
+	}
+	if err := VerifyStrmSign("pickcode123", "10002", exp, kid, sign); !errors.Is(err, ErrStrmSignInvalid) {
+		t.Errorf("篡改userid应该校验失败, got %v", err)
+	}
+	if err := VerifyStrmSign("pickcode123", "10001", exp, kid, ""); !errors.Is(err, ErrStrmSignMissing) {
+		t.Errorf("缺少签名应该校验失败, got %v", err)
+	}
+	if err := VerifyStrmSign("pickcode123", "10001", exp, 2, sign); !errors.Is(err, ErrStrmSignNoKey) {
+		t.Errorf("不存在的密钥应该校验失败, got %v", err)
+	}
+}
+
+func TestVerifyStrmSignExpire(t *testing.T) {
+	setTestStrmSignKeys(&StrmSignKey{BaseModel: BaseModel{ID: 1}, Secret: "secret-1", IsActive: true})
+
+	kid, exp, sign, err := SignStrm("pickcode123", "10001", 3600)
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if exp <= time.Now().Unix() {
+		t.Fatalf("过期时间不正确: %d", exp)
+	}
+	if err := VerifyStrmSign("pickcode123", "10001", exp, kid, sign); err != nil {
+		t.Errorf("未过期的签名校验失败: %v", err)
+	}
+	// 修改过期时间会导致签名不一致
+	if err := VerifyStrmSign("pickcode123", "10001", exp+3600, kid, sign); !errors.Is(err, ErrStrmSignInvalid) {
+		t.Errorf("篡改过期时间应该校验失败, got %v", err)
+	}
+	expired := time.Now().Unix() - 1
+	expiredSign := makeStrmSign("secret-1", "pickcode123", "10001", expired)
+	if err := VerifyStrmSign("pickcode123", "10001", expired, kid, expiredSign); !errors.Is(err, ErrStrmSignExpired) {
+		t.Errorf("过期的签名应该校验失败, got %v", err)
+	}
+}
+
+func TestVerifyStrmSignAfterRotate(t *testing.T) {
+	oldKey := &StrmSignKey{BaseModel: BaseModel{ID: 1}, Secret: "secret-1", IsActive: true}
+	setTestStrmSignKeys(oldKey)
+	kid, exp, sign, _ := SignStrm("pickcode123", "10001", 0)
+
+	// 轮换后旧密钥签名的链接依然可以校验
+	oldKey.IsActive = false
+	setTestStrmSignKeys(oldKey, &StrmSignKey{BaseModel: BaseModel{ID: 2}, Secret: "secret-2", IsActive: true})
+	if err := VerifyStrmSign("pickcode123", "10001", exp, kid, sign); err != nil {
+		t.Errorf("轮换后旧密钥签名校验失败: %v", err)
+	}
+	newKid, _, newSign, _ := SignStrm("pickcode123", "10001", 0)
+	if newKid != 2 || newSign == sign {
+		t.Errorf("轮换后应该使用新密钥签名: kid=%d", newKid)
+	}
+
+	// 删除旧密钥后旧链接失效
+	setTestStrmSignKeys(&StrmSignKey{BaseModel: BaseModel{ID: 2}, Secret: "secret-2", IsActive: true})
+	if err := VerifyStrmSign("pickcode123", "10001", exp, kid, sign); !errors.Is(err, ErrStrmSignNoKey) {
+		t.Errorf("删除旧密钥后应该校验失败, got %v", err)
+	}
+}
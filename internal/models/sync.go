@@ -64,6 +64,8 @@ type Sync struct {
 	BaseCid           string           `json:"base_cid"`                    // 基础CID，用于标识同步的根目录
 	FailReason        string           `json:"fail_reason"`                 // 失败原因
 	IsFullSync        bool             `json:"is_full_sync"`                // 是否全量同步
+	IsDryRun          bool             `json:"is_dry_run"`                  // 是否预演（只生成计划，不做任何修改）
+	PlanJson          string           `json:"-" gorm:"type:text"`          // 预演生成的计划，JSON格式
 	SyncPath          *SyncPath        `gorm:"-" json:"-"`                  // 同步路径实例
 	Logger            *helpers.QLogger `gorm:"-" json:"-"`                  // 日志句柄，不参与数据读写
 }
@@ -199,13 +201,22 @@ func (s *Sync) InitLogger() {
 
 // 获取所有同步记录
 func GetSyncRecords(page, pageSize int) ([]*Sync, int64, error) {
+	return GetSyncRecordsBySyncPathIds(page, pageSize, nil)
+}
+
+// 获取同步记录，syncPathIds不为nil时只查询这些同步路径的记录（用于非管理员用户）
+func GetSyncRecordsBySyncPathIds(page, pageSize int, syncPathIds []uint) ([]*Sync, int64, error) {
+	query := db.Db.Model(&Sync{})
+	if syncPathIds != nil {
+		query = query.Where("sync_path_id IN ?", syncPathIds)
+	}
 	var count int64
-	if err := db.Db.Model(&Sync{}).Count(&count).Error; err != nil {
+	if err := query.Count(&count).Error; err != nil {
 		helpers.AppLogger.Errorf("统计同步记录总数失败: %v", err)
 		return nil, 0, err
 	}
 	var syncs []*Sync
-	if err := db.Db.Offset((page - 1) * pageSize).Limit(pageSize).Order("id DESC").Find(&syncs).Error; err != nil {
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("id DESC").Find(&syncs).Error; err != nil {
 		helpers.AppLogger.Errorf("获取同步记录失败: %v", err)
 		return nil, 0, err
 	}
@@ -343,3 +354,12 @@ func GetTodayFirstSyncByPathId(syncPathId uint) *Sync {
 	}
 	return &sync
 }
+
+// 获取同步路径最近一次完成的同步（不含预演），excludeId是当前正在进行的同步
+func GetLastCompletedSyncByPathId(syncPathId uint, excludeId uint) *Sync {
+	var sync Sync
+	if err := db.Db.Where("sync_path_id = ? AND status = ? AND is_dry_run = ? AND id != ?", syncPathId, SyncStatusCompleted, false, excludeId).Order("id DESC").First(&sync).Error; err != nil {
+		return nil
+	}
+	return &sync
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"encoding/json"
+	"sync"
+)
+
+// 预演同步计划中的操作类型
+type SyncPlanAction string
+
+const (
+	SyncPlanActionCreateStrm   SyncPlanAction = "create_strm"   // 新建STRM文件
+	SyncPlanActionUpdateStrm   SyncPlanAction = "update_strm"   // 更新STRM文件内容
+	SyncPlanActionRenameLocal  SyncPlanAction = "rename_local"  // 重命名本地文件
+	SyncPlanActionDeleteLocal  SyncPlanAction = "delete_local"  // 删除本地STRM、元数据文件或空目录
+	SyncPlanActionDownload     SyncPlanAction = "download"      // 下载元数据
+	SyncPlanActionUpload       SyncPlanAction = "upload"        // 上传本地元数据
+	SyncPlanActionDeleteRemote SyncPlanAction = "delete_remote" // 删除网盘文件
+)
+
+// 每种操作最多保留的明细条数，超过的只计数，避免大型同步路径的计划过大
+const SyncPlanMaxItems = 5000
+
+type SyncPlanItem struct {
+	LocalPath  string `json:"local_path"`
+	RemotePath string `json:"remote_path"`
+	OldPath    string `json:"old_path,omitempty"` // 重命名前的本地路径
+}
+
+type SyncPlanGroup struct {
+	Total int            `json:"total"`
+	Items []SyncPlanItem `json:"items"`
+}
+
+// 预演同步的计划，记录同步会做的所有写入和删除操作
+type SyncPlan struct {
+	mu           sync.Mutex
+	CreateStrm   SyncPlanGroup `json:"create_strm"`
+	UpdateStrm   SyncPlanGroup `json:"update_strm"`
+	RenameLocal  SyncPlanGroup `json:"rename_local"`
+	DeleteLocal  SyncPlanGroup `json:"delete_local"`
+	Download     SyncPlanGroup `json:"download"`
+	Upload       SyncPlanGroup `json:"upload"`
+	DeleteRemote SyncPlanGroup `json:"delete_remote"`
+}
+
+func NewSyncPlan() *SyncPlan {
+	return &SyncPlan{}
+}
+
+func (p *SyncPlan) group(action SyncPlanAction) *SyncPlanGroup {
+	switch action {
+	case SyncPlanActionCreateStrm:
+		return &p.CreateStrm
+	case SyncPlanActionUpdateStrm:
+		return &p.UpdateStrm
+	case SyncPlanActionRenameLocal:
+		return &p.RenameLocal
+	case SyncPlanActionDeleteLocal:
+		return &p.DeleteLocal
+	case SyncPlanActionDownload:
+		return &p.Download
+	case SyncPlanActionUpload:
+		return &p.Upload
+	case SyncPlanActionDeleteRemote:
+		return &p.DeleteRemote
+	}
+	return nil
+}
+
+// 记录一条操作，并发安全
+func (p *SyncPlan) Add(action SyncPlanAction, item SyncPlanItem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	g := p.group(action)
+	if g == nil {
+		return
+	}
+	g.Total++
+	if len(g.Items) < SyncPlanMaxItems {
+		g.Items = append(g.Items, item)
+	}
+}
+
+// 把计划写入同步记录，随同步记录一起保存
+func (s *Sync) SetPlan(plan *SyncPlan) {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	s.PlanJson = helpers.JsonString(plan)
+}
+
+// 解析同步记录中的计划
+func (s *Sync) GetPlan() *SyncPlan {
+	if s.PlanJson == "" {
+		return nil
+	}
+	plan := NewSyncPlan()
+	if err := json.Unmarshal([]byte(s.PlanJson), plan); err != nil {
+		helpers.AppLogger.Errorf("解析同步计划失败: %v", err)
+		return nil
+	}
+	return plan
+}
+
+// 获取同步路径最近一次的预演记录
+func GetLastDryRunSync(syncPathId uint) *Sync {
+	var sync Sync
+	if err := db.Db.Where("sync_path_id = ? AND is_dry_run = ?", syncPathId, true).Order("id DESC").First(&sync).Error; err != nil {
+		return nil
+	}
+	return &sync
+}
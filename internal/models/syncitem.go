@@ -27,7 +27,7 @@ type SyncFile struct {
 	Sha1          string            `json:"sha1"`
 	MTime         int64             `json:"mtime"`                                        // 最后修改时间
 	LocalFilePath string            `json:"local_file_path" gorm:"index:local_file_path"` // 本地文件路径，包含文件名
-	Path          string            `json:"path"`                                         // 绝对路径，不包含FileName
+	Path          string            `json:"path" gorm:"index:idx_sync_file_path"`         // 绝对路径，不包含FileName
 	SyncPath      *SyncPath         `json:"-" gorm:"-"`                                   // 关联的同步路径
 	Sync          *Sync             `json:"-" gorm:"-"`                                   // 关联的同步项
 	Account       *Account          `json:"-" gorm:"-"`                                   // 关联的账号
@@ -82,3 +82,32 @@ func GetFilesBySyncPathId(syncPathId uint, offset, limit int) ([]*SyncFile, erro
 	}
 	return syncFiles, nil
 }
+
+// 查询同步路径下某个目录的直属子项
+// paths是同一个目录的几种写法（有无开头和结尾的/），不同来源保存的格式不完全一致
+func GetSyncFilesByPath(syncPathId uint, paths []string) ([]*SyncFile, error) {
+	var syncFiles []*SyncFile
+	err := db.Db.Model(&SyncFile{}).Where("sync_path_id = ? AND path IN ?", syncPathId, paths).Order("file_type ASC, file_name ASC").Find(&syncFiles).Error
+	if err != nil {
+		return nil, err
+	}
+	return syncFiles, nil
+}
+
+// 按所在目录和文件名查询同步路径下的文件或目录
+func GetSyncFileByPathAndName(syncPathId uint, paths []string, fileName string) *SyncFile {
+	// 客户端会频繁探测不存在的文件，不用First避免记录未找到的日志
+	var syncFiles []*SyncFile
+	err := db.Db.Model(&SyncFile{}).Where("sync_path_id = ? AND path IN ? AND file_name = ?", syncPathId, paths, fileName).Limit(1).Find(&syncFiles).Error
+	if err != nil || len(syncFiles) == 0 {
+		return nil
+	}
+	return syncFiles[0]
+}
+
+// 同步路径下的目录是否有子项，用于判断没有目录记录的中间目录是否存在
+func HasSyncFilesInPath(syncPathId uint, paths []string) bool {
+	var count int64
+	db.Db.Model(&SyncFile{}).Where("sync_path_id = ? AND path IN ?", syncPathId, paths).Limit(1).Count(&count)
+	return count > 0
+}
@@ -53,6 +53,7 @@ type SyncPath struct {
 	SourceType   SourceType `json:"source_type"`            // 同步源类型，主要分为：115网盘，本地目录，123网盘，无法编辑
 	AccountId    uint       `json:"account_id"`             // 115账号ID或者123账号ID，根据SourceType决定，无法编辑
 	EnableCron   bool       `json:"enable_cron"`            // 是否启用定时同步
+	EnableWatch  bool       `json:"enable_watch"`           // 是否启用实时监控同步，仅本地（含挂载）目录可用
 	LastSyncAt   int64      `json:"last_sync_at"`           // 上次同步时间
 	AccountName  string     `json:"account_name" gorm:"-"`  // 115账号名或者123账号名，不参与数据库操作，仅供前端使用
 	IsFullSync   bool       `json:"is_full_sync"`           // 是否全量同步，默认false
@@ -72,6 +73,7 @@ func GetStrmSettingDefault() SettingStrm {
 		MinVideoSize:   -1,
 		AddPath:        -1,
 		CheckMetaMtime: -1,
+		SignExpire:     -1,
 		UploadMeta:     -1,
 		DownloadMeta:   -1,
 		DeleteDir:      -1,
@@ -173,6 +175,14 @@ func (sp *SyncPath) GetAddPath() int {
 	return SettingsGlobal.AddPath
 }
 
+// STRM链接签名有效期，0表示永不过期
+func (sp *SyncPath) GetSignExpire() int64 {
+	if sp.SignExpire != -1 {
+		return sp.SignExpire
+	}
+	return max(SettingsGlobal.SignExpire, 0)
+}
+
 func (sp *SyncPath) GetCheckMetaMtime() int {
 	if sp.CheckMetaMtime == -1 {
 		return SettingsGlobal.CheckMetaMtime
@@ -296,6 +306,11 @@ func (sp *SyncPath) ToggleCron() {
 	db.Db.Save(sp)
 }
 
+func (sp *SyncPath) ToggleWatch() {
+	sp.EnableWatch = !sp.EnableWatch
+	db.Db.Save(sp)
+}
+
 func (sp *SyncPath) IsValidVideoExt(name string) bool {
 	ext := filepath.Ext(name)
 	ext = strings.ToLower(ext)
@@ -438,6 +453,11 @@ func GetSyncPathById(id uint) *SyncPath {
 
 // 查询同步路径列表
 func GetSyncPathList(page, pageSize int, enableCron bool, sourceType SourceType) ([]*SyncPath, int64) {
+	return GetSyncPathListByIds(page, pageSize, enableCron, sourceType, nil)
+}
+
+// 查询同步路径列表，ids不为nil时只查询其中的同步路径（用于非管理员用户）
+func GetSyncPathListByIds(page, pageSize int, enableCron bool, sourceType SourceType, ids []uint) ([]*SyncPath, int64) {
 	var syncPaths []*SyncPath
 	var total int64
 
@@ -456,6 +476,9 @@ func GetSyncPathList(page, pageSize int, enableCron bool, sourceType SourceType)
 	if sourceType != "" {
 		query.Where("source_type = ?", sourceType)
 	}
+	if ids != nil {
+		query.Where("id IN ?", ids)
+	}
 	query.Count(&total)
 	query.Offset(offset).Limit(pageSize).Order("id DESC").Find(&syncPaths)
 	accountCache := make(map[uint]*Account)
@@ -493,3 +516,26 @@ func GetAllSyncPathByAccountId(accountId uint) []SyncPath {
 	db.Db.Where("account_id = ?", accountId).Find(&syncPaths)
 	return syncPaths
 }
+
+// 获取开启了实时监控同步的本地同步路径
+func GetWatchSyncPaths() []*SyncPath {
+	var syncPaths []*SyncPath
+	if err := db.Db.Where("enable_watch = ? AND source_type = ?", true, SourceTypeLocal).Find(&syncPaths).Error; err != nil {
+		helpers.AppLogger.Errorf("查询开启实时监控的同步路径失败: %v", err)
+		return nil
+	}
+	for _, syncPath := range syncPaths {
+		syncPath.ParseVideoAndMetaExt()
+	}
+	return syncPaths
+}
+
+// 获取所有同步路径，按ID升序
+func GetAllSyncPaths() []*SyncPath {
+	var syncPaths []*SyncPath
+	if err := db.Db.Order("id ASC").Find(&syncPaths).Error; err != nil {
+		helpers.AppLogger.Errorf("查询同步路径失败: %v", err)
+		return nil
+	}
+	return syncPaths
+}
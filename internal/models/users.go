@@ -3,6 +3,7 @@ package models
 import (
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"errors"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -10,8 +11,9 @@ import (
 
 type User struct {
 	BaseModel
-	Username string `gorm:"unique;not null" json:"username"`
-	Password string `gorm:"not null"`
+	Username string   `gorm:"unique;not null" json:"username"`
+	Password string   `gorm:"not null" json:"-"`
+	Role     UserRole `gorm:"default:admin" json:"role"` // 角色：admin-管理员，operator-操作员，viewer-访客
 }
 
 // 表名
@@ -90,3 +92,71 @@ func GetPwd(pwd string) ([]byte, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
 	return hash, err
 }
+
+// 查询所有用户
+func GetAllUsers() ([]*User, error) {
+	var users []*User
+	if err := db.Db.Order("id ASC").Find(&users).Error; err != nil {
+		helpers.AppLogger.Errorf("查询用户列表失败: %v", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// 创建用户
+func CreateUser(username, password string, role UserRole) (*User, error) {
+	if !IsValidRole(role) {
+		return nil, errors.New("无效的角色")
+	}
+	var count int64
+	db.Db.Model(&User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		return nil, errors.New("用户名已存在")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := &User{Username: username, Password: string(hash), Role: role}
+	if err := db.Db.Create(user).Error; err != nil {
+		helpers.AppLogger.Errorf("创建用户失败: %v", err)
+		return nil, err
+	}
+	helpers.AppLogger.Infof("已创建用户 %s，角色 %s", username, role)
+	return user, nil
+}
+
+// 修改用户的角色，至少要保留一个管理员
+func (user *User) UpdateRole(role UserRole) error {
+	if !IsValidRole(role) {
+		return errors.New("无效的角色")
+	}
+	if user.IsAdmin() && role != UserRoleAdmin && CountAdminUsers() <= 1 {
+		return errors.New("至少需要保留一个管理员")
+	}
+	user.Role = role
+	return db.Db.Model(user).Update("role", role).Error
+}
+
+// 删除用户，同时删除用户的API Key和资源授权，至少要保留一个管理员
+func DeleteUser(user *User) error {
+	if user.IsAdmin() && CountAdminUsers() <= 1 {
+		return errors.New("至少需要保留一个管理员")
+	}
+	return db.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&ApiKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&UserResource{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+// 管理员数量，旧数据中没有角色的用户也是管理员
+func CountAdminUsers() int64 {
+	var count int64
+	db.Db.Model(&User{}).Where("role = ? OR role = '' OR role IS NULL", UserRoleAdmin).Count(&count)
+	return count
+}
@@ -70,16 +70,18 @@ type NotificationRule struct {
 type NotificationType string
 
 const (
-	SyncFinished   NotificationType = "sync_finish"
-	SyncError      NotificationType = "sync_error"
-	ScrapeFinished NotificationType = "scrape_finish"
-	ScrapeError    NotificationType = "scrape_error"
-	SystemAlert    NotificationType = "system_alert"
-	MediaAdded     NotificationType = "media_added"
-	MediaRemoved   NotificationType = "media_removed"
-	PlaybackStart  NotificationType = "playback_start" // 播放开始
-	PlaybackPause  NotificationType = "playback_pause" // 播放暂停
-	PlaybackStop   NotificationType = "playback_stop"  // 播放停止
+	SyncFinished      NotificationType = "sync_finish"
+	SyncError         NotificationType = "sync_error"
+	ScrapeFinished    NotificationType = "scrape_finish"
+	ScrapeError       NotificationType = "scrape_error"
+	SystemAlert       NotificationType = "system_alert"
+	MediaAdded        NotificationType = "media_added"
+	MediaRemoved      NotificationType = "media_removed"
+	PlaybackStart     NotificationType = "playback_start"     // 播放开始
+	PlaybackPause     NotificationType = "playback_pause"     // 播放暂停
+	PlaybackStop      NotificationType = "playback_stop"      // 播放停止
+	MissingEpisodes   NotificationType = "missing_episodes"   // 电视剧发现新的缺集
+	SourceUnavailable NotificationType = "source_unavailable" // 网盘文件无法获取下载链接
 )
 
 // AllNotificationTypes 所有通知类型，用于创建渠道时的默认规则
@@ -94,6 +96,8 @@ var AllNotificationTypes = []NotificationType{
 	PlaybackStart,
 	PlaybackPause,
 	PlaybackStop,
+	MissingEpisodes,
+	SourceUnavailable,
 }
 
 // NotificationPriority 通知优先级
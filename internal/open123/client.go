@@ -1,7 +1,9 @@
 package open123
 
 import (
+	"Q115-STRM/internal/helpers"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"sync"
@@ -12,6 +14,7 @@ import (
 )
 
 type Client struct {
+	AccountId    uint
 	clientID     string
 	clientSecret string
 	accessToken  string
@@ -22,7 +25,6 @@ type Client struct {
 
 	tokenMu          sync.RWMutex
 	isRefreshing     sync.Mutex
-	refreshOnce      sync.Once
 	refreshTokenChan chan struct{}
 
 	limiterLock sync.RWMutex
@@ -44,6 +46,45 @@ func NewClient(clientID, clientSecret string) *Client {
 	}
 }
 
+// 全局HTTP客户端实例
+var cachedClients map[string]*Client = make(map[string]*Client, 0)
+var cachedClientsMutex sync.Mutex
+
+// GetClient 获取账号对应的客户端，同一个账号复用同一个客户端（共享限速器和访问凭证）
+// accessToken和expiredAt是数据库中保存的访问凭证，过期后客户端会自动使用clientID和clientSecret重新获取
+func GetClient(accountId uint, clientID, clientSecret, accessToken string, expiredAt int64) *Client {
+	cachedClientsMutex.Lock()
+	defer cachedClientsMutex.Unlock()
+	clientKey := fmt.Sprintf("%d", accountId)
+	if client, exists := cachedClients[clientKey]; exists {
+		client.clientID = clientID
+		client.clientSecret = clientSecret
+		client.SetAuthToken(accessToken, expiredAt)
+		return client
+	}
+	client := NewClient(clientID, clientSecret)
+	client.AccountId = accountId
+	client.initDefaultRateLimits()
+	client.SetAuthToken(accessToken, expiredAt)
+	cachedClients[clientKey] = client
+	return client
+}
+
+// SetAuthToken 设置访问凭证，只有比当前凭证更新时才会覆盖
+func (c *Client) SetAuthToken(accessToken string, expiredAt int64) {
+	if accessToken == "" {
+		return
+	}
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	t := time.Unix(expiredAt, 0)
+	if c.accessToken != "" && t.Before(c.expiredAt) {
+		return
+	}
+	c.accessToken = accessToken
+	c.expiredAt = t
+}
+
 func (c *Client) initDefaultRateLimits() {
 	c.SetRateLimit("/api/v1/", 10)
 	c.SetRateLimit("/upload/v2/", 5)
@@ -127,40 +168,86 @@ func (c *Client) isTokenExpiredLocked() bool {
 }
 
 func (c *Client) ensureValidAccessToken(ctx context.Context) error {
-	var refreshErr error
-	c.refreshOnce.Do(func() {
-		refreshErr = c.refreshAccessToken()
-		if refreshErr != nil {
-			c.refreshOnce = sync.Once{}
-		} else {
-			close(c.refreshTokenChan)
-		}
-	})
-
-	if refreshErr != nil {
-		return refreshErr
-	}
-
-	select {
-	case <-c.refreshTokenChan:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(30 * time.Second):
-		return fmt.Errorf("token refresh timeout")
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	return c.refreshAccessToken(false)
 }
 
-func (c *Client) refreshAccessToken() error {
+// refreshAccessToken 刷新访问凭证，force=true时忽略过期时间强制刷新（接口返回401时使用）
+func (c *Client) refreshAccessToken(force bool) error {
 	c.isRefreshing.Lock()
 	defer c.isRefreshing.Unlock()
 
 	c.tokenMu.RLock()
-	if !c.isTokenExpiredLocked() {
+	if !force && !c.isTokenExpiredLocked() {
 		c.tokenMu.RUnlock()
 		return nil
 	}
 	c.tokenMu.RUnlock()
 
+	if err := c.performTokenRefresh(); err != nil {
+		helpers.AppLogger.Errorf("123云盘获取访问凭证失败: %v", err)
+		return err
+	}
+	if c.AccountId > 0 {
+		// 通知models保存token到数据库
+		helpers.PublishSync(helpers.Save123TokenEvent, map[string]any{
+			"account_id": c.AccountId,
+			"token":      c.GetAccessToken(),
+			"expired_at": c.GetExpiredAt().Unix(),
+		})
+	}
 	return nil
 }
+
+// RefreshToken 立即重新获取访问凭证
+func (c *Client) RefreshToken(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.refreshAccessToken(true)
+}
+
+// requestData 发起请求并解析统一的响应结构，访问凭证失效时会刷新后重试一次
+func requestData[T any](ctx context.Context, c *Client, method, requestURL string, body any) (*T, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request failed: %w", err)
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doRequest(ctx, method, requestURL, payload)
+		if err != nil {
+			return nil, err
+		}
+		data := resp.Bytes()
+		resp.Body.Close()
+		if resp.StatusCode() == 401 && attempt == 0 {
+			if err := c.refreshAccessToken(true); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !resp.IsSuccess() {
+			return nil, fmt.Errorf("request %s failed with status: %s", requestURL, resp.Status())
+		}
+		result := &RespBase[T]{}
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, fmt.Errorf("unmarshal response failed: %w", err)
+		}
+		if result.Code == ErrCodeUnauthorized && attempt == 0 {
+			if err := c.refreshAccessToken(true); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if result.Code != ErrCodeSuccess {
+			return nil, NewAPIError(result.Code, result.Message)
+		}
+		return &result.Data, nil
+	}
+}
@@ -8,3 +8,11 @@ const (
 	DEFAULT_TIMEOUT     = 30
 	DEFAULTUA           = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36 Edg/138.0.0.0"
 )
+
+const (
+	FileTypeFile   = 0
+	FileTypeFolder = 1
+
+	// 文件列表单页最大数量
+	MAX_LIST_LIMIT = 100
+)
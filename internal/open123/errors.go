@@ -1,6 +1,9 @@
 package open123
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type APIError struct {
 	Code    int
@@ -30,15 +33,25 @@ const (
 )
 
 func IsTokenExpired(err error) bool {
-	if apiErr, ok := err.(*APIError); ok {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
 		return apiErr.Code == ErrCodeUnauthorized
 	}
 	return false
 }
 
 func IsRateLimited(err error) bool {
-	if apiErr, ok := err.(*APIError); ok {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
 		return apiErr.Code == ErrCodeRateLimit
 	}
 	return false
 }
+
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == ErrCodeNotFound
+	}
+	return false
+}
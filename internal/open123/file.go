@@ -2,137 +2,188 @@ package open123
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 )
 
-func (c *Client) ListFiles(ctx context.Context, parentFileID int64, page, pageSize int) (*FileListResponse, error) {
-	url := fmt.Sprintf("%s/api/v2/file/list?driveID=0&parentFileID=%d&page=%d&pageSize=%d", c.baseURL, parentFileID, page, pageSize)
-
-	resp, err := c.doRequest(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
+// ListFiles 获取文件列表（v2），lastFileID为0表示第一页，返回的LastFileID为-1表示已经是最后一页
+func (c *Client) ListFiles(ctx context.Context, parentFileID int64, limit int, lastFileID int64) (*FileListResponse, error) {
+	if limit <= 0 || limit > MAX_LIST_LIMIT {
+		limit = MAX_LIST_LIMIT
 	}
-	defer resp.Body.Close()
-
-	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("list files failed with status: %s", resp.Status())
+	requestURL := fmt.Sprintf("%s/api/v2/file/list?parentFileId=%d&limit=%d", c.baseURL, parentFileID, limit)
+	if lastFileID > 0 {
+		requestURL = fmt.Sprintf("%s&lastFileId=%d", requestURL, lastFileID)
 	}
-
-	result := &RespBase[FileListResponse]{}
-	if err := json.Unmarshal(resp.Bytes(), result); err != nil {
-		return nil, fmt.Errorf("unmarshal list files response failed: %w", err)
-	}
-
-	if result.Code != 0 {
-		return nil, fmt.Errorf("list files failed: code=%d, message=%s", result.Code, result.Message)
+	data, err := requestData[FileListResponse](ctx, c, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list files failed: %w", err)
 	}
-
-	return &result.Data, nil
+	return data, nil
 }
 
-func (c *Client) CreateFolder(ctx context.Context, name string, parentFileID int64) (*CreateFolderResponse, error) {
-	url := fmt.Sprintf("%s/api/v1/dir/create", c.baseURL)
-
-	req := CreateFolderRequest{
-		DriveID:      0,
-		ParentFileID: parentFileID,
-		DirName:      name,
-	}
+// ListAllFiles 获取目录下的全部文件（自动翻页），回收站中的文件会被过滤掉
+func (c *Client) ListAllFiles(ctx context.Context, parentFileID int64) ([]FileInfo, error) {
+	files := make([]FileInfo, 0)
+	var lastFileID int64
+	for {
+		resp, err := c.ListFiles(ctx, parentFileID, MAX_LIST_LIMIT, lastFileID)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range resp.FileList {
+			if f.Trashed == 1 {
+				continue
+			}
+			files = append(files, f)
+		}
+		if resp.LastFileID == -1 || len(resp.FileList) == 0 {
+			break
+		}
+		lastFileID = resp.LastFileID
+	}
+	return files, nil
+}
 
-	body, err := json.Marshal(req)
+// GetFileDetail 获取单个文件详情
+func (c *Client) GetFileDetail(ctx context.Context, fileID int64) (*FileDetail, error) {
+	requestURL := fmt.Sprintf("%s/api/v1/file/detail?fileID=%d", c.baseURL, fileID)
+	data, err := requestData[FileDetail](ctx, c, "GET", requestURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("marshal create folder request failed: %w", err)
+		return nil, fmt.Errorf("get file detail failed: %w", err)
 	}
+	return data, nil
+}
 
-	resp, err := c.doRequest(ctx, "POST", url, body)
+// GetFileDetailWithPath 获取文件详情和所在目录的完整路径（以/开头）
+// 详情接口不返回路径，需要逐级向上查询父目录
+func (c *Client) GetFileDetailWithPath(ctx context.Context, fileID int64) (*FileDetail, string, error) {
+	detail, err := c.GetFileDetail(ctx, fileID)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("create folder failed with status: %s", resp.Status())
-	}
-
-	result := &RespBase[CreateFolderResponse]{}
-	if err := json.Unmarshal(resp.Bytes(), result); err != nil {
-		return nil, fmt.Errorf("unmarshal create folder response failed: %w", err)
-	}
-
-	if result.Code != 0 {
-		return nil, fmt.Errorf("create folder failed: code=%d, message=%s", result.Code, result.Message)
-	}
-
-	return &result.Data, nil
+		return nil, "", err
+	}
+	names := make([]string, 0)
+	parentID := detail.ParentFileID
+	for parentID != 0 {
+		parent, err := c.GetFileDetail(ctx, parentID)
+		if err != nil {
+			return nil, "", err
+		}
+		names = append([]string{parent.FileName}, names...)
+		parentID = parent.ParentFileID
+	}
+	return detail, "/" + strings.Join(names, "/"), nil
 }
 
-func (c *Client) DeleteFile(ctx context.Context, fileID int64) error {
-	url := fmt.Sprintf("%s/api/v1/file/delete", c.baseURL)
-
-	req := DeleteRequest{
-		FileID:  fileID,
-		DriveID: 0,
-	}
+// GetPathIdByPath 通过路径逐级查找文件（夹）ID，根目录为0
+// 123云盘没有按路径查询的接口，只能逐级列出目录查找
+func (c *Client) GetPathIdByPath(ctx context.Context, path string) (int64, error) {
+	path = strings.Trim(strings.ReplaceAll(path, "\\", "/"), "/")
+	if path == "" {
+		return 0, nil
+	}
+	var parentID int64
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		files, err := c.ListAllFiles(ctx, parentID)
+		if err != nil {
+			return 0, err
+		}
+		found := false
+		for _, f := range files {
+			if f.FileName == name {
+				parentID = f.FileID
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, NewAPIError(ErrCodeNotFound, fmt.Sprintf("路径不存在: %s", path))
+		}
+	}
+	return parentID, nil
+}
 
-	body, err := json.Marshal(req)
+// CreateFolder 创建目录，返回新目录的ID
+func (c *Client) CreateFolder(ctx context.Context, name string, parentFileID int64) (*CreateFolderResponse, error) {
+	requestURL := fmt.Sprintf("%s/upload/v1/file/mkdir", c.baseURL)
+	data, err := requestData[CreateFolderResponse](ctx, c, "POST", requestURL, CreateFolderRequest{
+		Name:     name,
+		ParentID: parentFileID,
+	})
 	if err != nil {
-		return fmt.Errorf("marshal delete file request failed: %w", err)
+		return nil, fmt.Errorf("create folder failed: %w", err)
 	}
+	return data, nil
+}
 
-	resp, err := c.doRequest(ctx, "POST", url, body)
-	if err != nil {
-		return err
+// MoveFiles 移动文件到目标目录
+func (c *Client) MoveFiles(ctx context.Context, fileIDs []int64, toParentFileID int64) error {
+	requestURL := fmt.Sprintf("%s/api/v1/file/move", c.baseURL)
+	if _, err := requestData[any](ctx, c, "POST", requestURL, MoveRequest{
+		FileIDs:        fileIDs,
+		ToParentFileID: toParentFileID,
+	}); err != nil {
+		return fmt.Errorf("move files failed: %w", err)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if !resp.IsSuccess() {
-		return fmt.Errorf("delete file failed with status: %s", resp.Status())
+// Rename 重命名单个文件（夹）
+func (c *Client) Rename(ctx context.Context, fileID int64, newName string) error {
+	requestURL := fmt.Sprintf("%s/api/v1/file/name", c.baseURL)
+	if _, err := requestData[any](ctx, c, "PUT", requestURL, RenameRequest{
+		FileID:   fileID,
+		FileName: newName,
+	}); err != nil {
+		return fmt.Errorf("rename file failed: %w", err)
 	}
+	return nil
+}
 
-	result := &RespBase[any]{}
-	if err := json.Unmarshal(resp.Bytes(), result); err != nil {
-		return fmt.Errorf("unmarshal delete file response failed: %w", err)
+// TrashFiles 将文件（夹）移入回收站
+func (c *Client) TrashFiles(ctx context.Context, fileIDs []int64) error {
+	requestURL := fmt.Sprintf("%s/api/v1/file/trash", c.baseURL)
+	if _, err := requestData[any](ctx, c, "POST", requestURL, FileIDsRequest{FileIDs: fileIDs}); err != nil {
+		return fmt.Errorf("trash files failed: %w", err)
 	}
+	return nil
+}
 
-	if result.Code != 0 {
-		return fmt.Errorf("delete file failed: code=%d, message=%s", result.Code, result.Message)
+// DeleteFile 彻底删除文件，文件必须已经在回收站中
+func (c *Client) DeleteFile(ctx context.Context, fileID int64) error {
+	requestURL := fmt.Sprintf("%s/api/v1/file/delete", c.baseURL)
+	if _, err := requestData[any](ctx, c, "POST", requestURL, FileIDsRequest{FileIDs: []int64{fileID}}); err != nil {
+		return fmt.Errorf("delete file failed: %w", err)
 	}
-
 	return nil
 }
 
+// DeleteFolder 删除目录（移入回收站）
 func (c *Client) DeleteFolder(ctx context.Context, dirID int64) error {
-	url := fmt.Sprintf("%s/api/v1/dir/delete", c.baseURL)
-
-	req := DeleteFolderRequest{
-		DirID:   dirID,
-		DriveID: 0,
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("marshal delete folder request failed: %w", err)
-	}
+	return c.TrashFiles(ctx, []int64{dirID})
+}
 
-	resp, err := c.doRequest(ctx, "POST", url, body)
+// GetUserInfo 获取用户信息
+func (c *Client) GetUserInfo(ctx context.Context) (*UserInfo, error) {
+	requestURL := fmt.Sprintf("%s/api/v1/user/info", c.baseURL)
+	data, err := requestData[UserInfo](ctx, c, "GET", requestURL, nil)
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if !resp.IsSuccess() {
-		return fmt.Errorf("delete folder failed with status: %s", resp.Status())
+		return nil, fmt.Errorf("get user info failed: %w", err)
 	}
+	return data, nil
+}
 
-	result := &RespBase[any]{}
-	if err := json.Unmarshal(resp.Bytes(), result); err != nil {
-		return fmt.Errorf("unmarshal delete folder response failed: %w", err)
+func parseTime(s string) int64 {
+	if s == "" {
+		return 0
 	}
-
-	if result.Code != 0 {
-		return fmt.Errorf("delete folder failed: code=%d, message=%s", result.Code, result.Message)
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+	if err != nil {
+		return 0
 	}
-
-	return nil
+	return t.Unix()
 }
@@ -19,65 +19,95 @@ type FileUploadCreateResponse struct {
 	UploadID     string `json:"uploadID"`
 	PartSize     int64  `json:"partSize"`
 	AlreadyExist bool   `json:"alreadyExist"`
+	Completed    bool   `json:"completed"`
 }
 
 type UploadDomainResponse struct {
 	Domains []string `json:"data"`
 }
 
+// FileInfo 文件列表（v2）中的文件信息
 type FileInfo struct {
-	FileID     int64  `json:"fileID"`
-	FileName   string `json:"fileName"`
-	FileSize   int64  `json:"fileSize"`
-	CreateTime int64  `json:"createTime"`
-	UpdateTime int64  `json:"updateTime"`
-	FileType   int    `json:"fileType"`
-	Etag       string `json:"etag"`
+	FileID       int64  `json:"fileId"`
+	FileName     string `json:"filename"`
+	ParentFileID int64  `json:"parentFileId"`
+	Type         int    `json:"type"` // 0-文件 1-文件夹
+	Size         int64  `json:"size"`
+	Etag         string `json:"etag"`
+	Status       int    `json:"status"` // 大于100为审核驳回文件
+	Category     int    `json:"category"`
+	Trashed      int    `json:"trashed"` // 1-在回收站
+	CreateAt     string `json:"createAt"`
+	UpdateAt     string `json:"updateAt"`
+}
+
+// IsDir 是否文件夹
+func (f *FileInfo) IsDir() bool {
+	return f.Type == FileTypeFolder
 }
 
-type FileListRequest struct {
-	DriveID      int64 `json:"driveID"`
-	ParentFileID int64 `json:"parentFileID"`
-	Page         int   `json:"page"`
-	PageSize     int   `json:"pageSize"`
+// UpdateTime 修改时间的时间戳
+func (f *FileInfo) UpdateTime() int64 {
+	return parseTime(f.UpdateAt)
 }
 
 type FileListResponse struct {
-	Total    int64      `json:"total"`
-	NextPage int        `json:"nextPage"`
-	FileList []FileInfo `json:"fileList"`
+	LastFileID int64      `json:"lastFileId"` // -1表示最后一页
+	FileList   []FileInfo `json:"fileList"`
 }
 
-type CreateFolderRequest struct {
-	DriveID      int64  `json:"driveID"`
+// FileDetail 文件详情（/api/v1/file/detail）
+type FileDetail struct {
+	FileID       int64  `json:"fileID"`
+	FileName     string `json:"filename"`
+	Type         int    `json:"type"`
+	Size         int64  `json:"size"`
+	Etag         string `json:"etag"`
+	Status       int    `json:"status"`
 	ParentFileID int64  `json:"parentFileID"`
-	DirName      string `json:"dirName"`
+	CreateAt     string `json:"createAt"`
+	Trashed      int    `json:"trashed"`
+}
+
+func (f *FileDetail) IsDir() bool {
+	return f.Type == FileTypeFolder
+}
+
+type CreateFolderRequest struct {
+	Name     string `json:"name"`
+	ParentID int64  `json:"parentID"`
 }
 
 type CreateFolderResponse struct {
-	DirID       int64 `json:"dirID"`
-	ParentDirID int64 `json:"parentDirID"`
+	DirID int64 `json:"dirID"`
 }
 
-type DeleteRequest struct {
-	FileID  int64 `json:"fileID"`
-	DriveID int64 `json:"driveID"`
+type FileIDsRequest struct {
+	FileIDs []int64 `json:"fileIDs"`
 }
 
-type DeleteFolderRequest struct {
-	DirID   int64 `json:"dirID"`
-	DriveID int64 `json:"driveID"`
+type MoveRequest struct {
+	FileIDs        []int64 `json:"fileIDs"`
+	ToParentFileID int64   `json:"toParentFileID"`
 }
 
-type FileDownloadInfoRequest struct {
-	FileID  int64 `json:"fileID" form:"fileID"`
-	DriveID int64 `json:"driveID"`
+type RenameRequest struct {
+	FileID   int64  `json:"fileId"`
+	FileName string `json:"fileName"`
 }
 
 type FileDownloadInfoResponse struct {
-	FileID      int64  `json:"fileID"`
-	FileName    string `json:"fileName"`
-	FileSize    int64  `json:"fileSize"`
 	DownloadURL string `json:"downloadUrl"`
-	ExpireTime  int64  `json:"expireTime"`
+}
+
+// UserInfo 用户信息
+type UserInfo struct {
+	UID            int64  `json:"uid"`
+	Nickname       string `json:"nickname"`
+	HeadImage      string `json:"headImage"`
+	Passport       string `json:"passport"`
+	SpaceUsed      int64  `json:"spaceUsed"`
+	SpacePermanent int64  `json:"spacePermanent"`
+	SpaceTemp      int64  `json:"spaceTemp"`
+	Vip            bool   `json:"vip"`
 }
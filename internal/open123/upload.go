@@ -3,6 +3,8 @@ package open123
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -40,7 +42,8 @@ func (c *Client) GetUploadDomain(ctx context.Context) (string, error) {
 	return result.Data[0], nil
 }
 
-func (c *Client) UploadFile(ctx context.Context, filePath string, parentFileID int64) (*FileUploadCreateResponse, error) {
+// UploadFile 单步上传文件，fileName为空时使用本地文件名
+func (c *Client) UploadFile(ctx context.Context, filePath string, fileName string, parentFileID int64) (*FileUploadCreateResponse, error) {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("get file info failed: %w", err)
@@ -52,7 +55,25 @@ func (c *Client) UploadFile(ctx context.Context, filePath string, parentFileID i
 	}
 	defer file.Close()
 
-	filename := filepath.Base(filePath)
+	filename := fileName
+	if filename == "" {
+		filename = filepath.Base(filePath)
+	}
+
+	// 123云盘要求上传时提供文件的md5作为etag
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("calculate file md5 failed: %w", err)
+	}
+	etag := hex.EncodeToString(hash.Sum(nil))
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek file failed: %w", err)
+	}
+	if c.isTokenExpired() {
+		if err := c.ensureValidAccessToken(ctx); err != nil {
+			return nil, err
+		}
+	}
 
 	domain, err := c.GetUploadDomain(ctx)
 	if err != nil {
@@ -66,6 +87,7 @@ func (c *Client) UploadFile(ctx context.Context, filePath string, parentFileID i
 
 	_ = writer.WriteField("parentFileID", fmt.Sprintf("%d", parentFileID))
 	_ = writer.WriteField("filename", filename)
+	_ = writer.WriteField("etag", etag)
 	_ = writer.WriteField("size", fmt.Sprintf("%d", fileInfo.Size()))
 
 	part, err := writer.CreateFormFile("file", filename)
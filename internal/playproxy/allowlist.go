@@ -0,0 +1,47 @@
+package playproxy
+
+import (
+	"Q115-STRM/internal/helpers"
+	"net/url"
+	"strings"
+)
+
+// 反代的下载链接来源
+type SourceType string
+
+const (
+	Source115      SourceType = "115"
+	SourceBaiduPan SourceType = "baidupan"
+)
+
+// 每种来源允许反代的域名，子域名也允许
+// 百度网盘只允许PCS下载域名，不允许整个baidu.com
+var allowedHosts = map[SourceType][]string{
+	Source115:      {"115.com", "115cdn.net", "115cdn.com"},
+	SourceBaiduPan: {"pcs.baidu.com", "baidupcs.com"},
+}
+
+// 下载链接是否允许反代，只允许http和https，并且域名在来源的白名单中
+func IsAllowedURL(source SourceType, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return isAllowedHost(source, u.Hostname())
+}
+
+func isAllowedHost(source SourceType, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return false
+	}
+	hosts := append([]string{}, allowedHosts[source]...)
+	hosts = append(hosts, helpers.GlobalConfig.PlayProxy.ExtraHosts[string(source)]...)
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed != "" && (host == allowed || strings.HasSuffix(host, "."+allowed)) {
+			return true
+		}
+	}
+	return false
+}
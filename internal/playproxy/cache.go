@@ -0,0 +1,265 @@
+package playproxy
+
+import (
+	"Q115-STRM/internal/helpers"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 文件开头和结尾固定缓存的大小，mp4的moov和mkv的cues通常在这里，Emby探测和拖动进度时直接命中
+const PinSize int64 = 1024 * 1024
+
+// 文件信息，和分块一起保存在缓存目录中
+type fileMeta struct {
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	ChunkSize   int64  `json:"chunk_size"`
+}
+
+// 分块是否固定缓存，固定的分块最后才淘汰
+func (m *fileMeta) pinned(index int64) bool {
+	start := index * m.ChunkSize
+	return start < PinSize || start+m.ChunkSize > m.Size-PinSize
+}
+
+func (m *fileMeta) chunks() int64 {
+	return (m.Size + m.ChunkSize - 1) / m.ChunkSize
+}
+
+type chunkKey struct {
+	key   string // key的哈希值
+	index int64
+}
+
+type chunkEntry struct {
+	chunkKey
+	size   int64
+	pinned bool
+}
+
+// 按key和分块序号保存的磁盘缓存，超过上限时按最近最少使用淘汰，先淘汰没有固定的分块
+// maxBytes小于等于0时只在内存中记录文件信息，不缓存分块
+type diskCache struct {
+	dir       string
+	maxBytes  int64
+	chunkSize int64
+	mutex     sync.Mutex
+	entries   map[chunkKey]*list.Element
+	lru       *list.List // 最近使用的在前面
+	used      int64
+	metas     map[string]*fileMeta
+}
+
+func newDiskCache(dir string, maxBytes int64, chunkSize int64) *diskCache {
+	c := &diskCache{
+		dir:       dir,
+		maxBytes:  maxBytes,
+		chunkSize: chunkSize,
+		entries:   make(map[chunkKey]*list.Element),
+		lru:       list.New(),
+		metas:     make(map[string]*fileMeta),
+	}
+	if c.enabled() {
+		c.load()
+	}
+	return c
+}
+
+func (c *diskCache) enabled() bool {
+	return c.maxBytes > 0
+}
+
+// key可能包含任意字符，缓存中使用key的哈希值作为ID和目录名
+func cacheId(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *diskCache) chunkPath(id string, index int64) string {
+	return filepath.Join(c.dir, id, fmt.Sprintf("%d.chunk", index))
+}
+
+// 启动时加载已有的缓存，分块大小变化的文件直接删除
+func (c *diskCache) load() {
+	dirs, err := os.ReadDir(c.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			helpers.AppLogger.Errorf("读取播放缓存目录 %s 失败: %v", c.dir, err)
+		}
+		return
+	}
+	type loaded struct {
+		chunkEntry
+		mtime int64
+	}
+	all := make([]loaded, 0)
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		keyDir := filepath.Join(c.dir, d.Name())
+		data, err := os.ReadFile(filepath.Join(keyDir, "meta.json"))
+		meta := &fileMeta{}
+		if err != nil || json.Unmarshal(data, meta) != nil || meta.ChunkSize != c.chunkSize || meta.Size <= 0 {
+			os.RemoveAll(keyDir)
+			continue
+		}
+		id := d.Name()
+		c.metas[id] = meta
+		files, _ := os.ReadDir(keyDir)
+		for _, f := range files {
+			name := f.Name()
+			if !strings.HasSuffix(name, ".chunk") {
+				continue
+			}
+			index, err := strconv.ParseInt(strings.TrimSuffix(name, ".chunk"), 10, 64)
+			info, infoErr := f.Info()
+			if err != nil || infoErr != nil {
+				os.Remove(filepath.Join(keyDir, name))
+				continue
+			}
+			all = append(all, loaded{chunkEntry{chunkKey{id, index}, info.Size(), meta.pinned(index)}, info.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].mtime > all[j].mtime })
+	for _, e := range all {
+		entry := e.chunkEntry
+		c.entries[entry.chunkKey] = c.lru.PushBack(&entry)
+		c.used += entry.size
+	}
+	c.evict()
+	helpers.AppLogger.Infof("已加载播放缓存 %d 个分块，共 %d MB", len(c.entries), c.used/1024/1024)
+}
+
+func (c *diskCache) getMeta(key string) *fileMeta {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.metas[cacheId(key)]
+}
+
+func (c *diskCache) setMeta(key string, meta *fileMeta) {
+	id := cacheId(key)
+	c.mutex.Lock()
+	c.metas[id] = meta
+	c.mutex.Unlock()
+	if !c.enabled() {
+		return
+	}
+	if err := os.MkdirAll(filepath.Join(c.dir, id), 0755); err != nil {
+		helpers.AppLogger.Errorf("创建播放缓存目录失败: %v", err)
+		return
+	}
+	data, _ := json.Marshal(meta)
+	if err := os.WriteFile(filepath.Join(c.dir, id, "meta.json"), data, 0644); err != nil {
+		helpers.AppLogger.Errorf("写入播放缓存文件信息失败: %v", err)
+	}
+}
+
+func (c *diskCache) get(key string, index int64) ([]byte, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+	k := chunkKey{cacheId(key), index}
+	c.mutex.Lock()
+	elem, ok := c.entries[k]
+	if ok {
+		c.lru.MoveToFront(elem)
+	}
+	c.mutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.chunkPath(k.key, index))
+	if err != nil || int64(len(data)) != elem.Value.(*chunkEntry).size {
+		c.mutex.Lock()
+		c.removeLocked(elem)
+		c.mutex.Unlock()
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *diskCache) put(key string, index int64, data []byte, pinned bool) {
+	if !c.enabled() || int64(len(data)) > c.maxBytes {
+		return
+	}
+	k := chunkKey{cacheId(key), index}
+	path := c.chunkPath(k.key, index)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		helpers.AppLogger.Errorf("创建播放缓存目录失败: %v", err)
+		return
+	}
+	// 先写临时文件再改名，避免读到写了一半的分块
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		helpers.AppLogger.Errorf("写入播放缓存分块失败: %v", err)
+		os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		helpers.AppLogger.Errorf("保存播放缓存分块失败: %v", err)
+		os.Remove(tmp)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.entries[k]; ok {
+		c.used -= elem.Value.(*chunkEntry).size
+		c.lru.Remove(elem)
+	}
+	c.entries[k] = c.lru.PushFront(&chunkEntry{k, int64(len(data)), pinned})
+	c.used += int64(len(data))
+	c.evict()
+}
+
+// 淘汰到上限以内，先从最久未使用的开始淘汰没有固定的分块
+func (c *diskCache) evict() {
+	for c.used > c.maxBytes {
+		var victim *list.Element
+		for e := c.lru.Back(); e != nil; e = e.Prev() {
+			if !e.Value.(*chunkEntry).pinned {
+				victim = e
+				break
+			}
+		}
+		if victim == nil {
+			victim = c.lru.Back()
+		}
+		if victim == nil {
+			return
+		}
+		c.removeLocked(victim)
+	}
+}
+
+func (c *diskCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*chunkEntry)
+	if c.entries[entry.chunkKey] != elem {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, entry.chunkKey)
+	c.used -= entry.size
+	os.Remove(c.chunkPath(entry.key, entry.index))
+}
+
+// 已缓存的大小、分块数和固定的分块数
+func (c *diskCache) usage() (used int64, chunks int, pinned int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, elem := range c.entries {
+		if elem.Value.(*chunkEntry).pinned {
+			pinned++
+		}
+	}
+	return c.used, len(c.entries), pinned
+}
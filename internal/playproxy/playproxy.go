@@ -0,0 +1,434 @@
+package playproxy
+
+import (
+	"Q115-STRM/internal/helpers"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 网盘不支持Range时直接透传
+var errRangeNotSupported = errors.New("网盘不支持Range请求")
+
+// 每个分块的下载超时时间和重试次数
+const (
+	chunkTimeout = 60 * time.Second
+	chunkRetries = 3
+)
+
+type sourceCtxKey struct{}
+
+// 反代请求
+type Request struct {
+	URL       string     // 网盘下载链接
+	Source    SourceType // 来源，用于检查域名白名单
+	Key       string     // 缓存key，一般是pickcode，为空时使用下载链接的路径
+	UserAgent string     // 请求网盘使用的UA，需要和获取下载链接时的UA一致
+}
+
+// 下载链接会过期，缓存按文件而不是按链接保存
+func (r *Request) cacheKey() string {
+	if r.Key != "" {
+		return string(r.Source) + ":" + r.Key
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return string(r.Source) + ":" + r.URL
+	}
+	return string(r.Source) + ":path:" + u.Path
+}
+
+// 播放代理统计
+type Stats struct {
+	Hits              int64   `json:"hits"`                // 命中缓存的分块数
+	Misses            int64   `json:"misses"`              // 从网盘下载的分块数
+	HitRate           float64 `json:"hit_rate"`            // 命中率
+	BytesFromCache    int64   `json:"bytes_from_cache"`    // 从缓存读取的字节数
+	BytesFromUpstream int64   `json:"bytes_from_upstream"` // 从网盘下载的字节数
+	CacheUsed         int64   `json:"cache_used"`          // 已使用的缓存大小
+	CacheLimit        int64   `json:"cache_limit"`         // 缓存上限，小于等于0表示不缓存
+	CachedChunks      int     `json:"cached_chunks"`       // 已缓存的分块数
+	PinnedChunks      int     `json:"pinned_chunks"`       // 固定缓存的文件开头和结尾分块数
+	ActiveStreams     int64   `json:"active_streams"`      // 正在播放的请求数
+}
+
+// 分块并发下载、预读并缓存到磁盘的播放代理
+type Proxy struct {
+	cache     *diskCache
+	client    *http.Client
+	chunkSize int64
+	threads   int
+	mutex     sync.Mutex
+	inflight  map[string]*fetchCall // 正在下载的分块，多个请求读取同一个分块时只下载一次
+
+	hits              atomic.Int64
+	misses            atomic.Int64
+	bytesFromCache    atomic.Int64
+	bytesFromUpstream atomic.Int64
+	activeStreams     atomic.Int64
+}
+
+type fetchCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+var (
+	defaultProxy *Proxy
+	defaultOnce  sync.Once
+)
+
+// 全局播放代理，第一次使用时按配置创建
+func GetProxy() *Proxy {
+	defaultOnce.Do(func() {
+		cfg := helpers.GlobalConfig.PlayProxy
+		defaultProxy = NewProxy(filepath.Join(helpers.ConfigDir, "play_cache"), cfg.CacheSize*1024*1024, cfg.ChunkSize*1024*1024, cfg.Threads)
+	})
+	return defaultProxy
+}
+
+func NewProxy(cacheDir string, maxBytes int64, chunkSize int64, threads int) *Proxy {
+	if chunkSize <= 0 {
+		chunkSize = 4 * 1024 * 1024
+	}
+	if threads <= 0 {
+		threads = 4
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = threads * 2
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return &Proxy{
+		cache:     newDiskCache(cacheDir, maxBytes, chunkSize),
+		chunkSize: chunkSize,
+		threads:   threads,
+		inflight:  make(map[string]*fetchCall),
+		client: &http.Client{
+			Transport: transport,
+			// 网盘的下载链接可能会重定向，重定向后的域名也需要在白名单中
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return errors.New("重定向次数过多")
+				}
+				source, _ := req.Context().Value(sourceCtxKey{}).(SourceType)
+				if !isAllowedHost(source, req.URL.Hostname()) {
+					return fmt.Errorf("重定向到不允许反代的域名 %s", req.URL.Hostname())
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// 反代网盘下载链接，支持Range请求
+// 返回错误时还没有写入响应，由调用方返回错误信息
+func (p *Proxy) Serve(w http.ResponseWriter, r *http.Request, req *Request) error {
+	if !IsAllowedURL(req.Source, req.URL) {
+		return fmt.Errorf("不允许反代该域名")
+	}
+	ctx := context.WithValue(r.Context(), sourceCtxKey{}, req.Source)
+	meta, err := p.stat(ctx, req)
+	if errors.Is(err, errRangeNotSupported) {
+		return p.passthrough(ctx, w, r, req)
+	}
+	if err != nil {
+		return err
+	}
+	start, end, partial, ok := parseRange(r.Header.Get("Range"), meta.Size)
+	h := w.Header()
+	h.Set("Accept-Ranges", "bytes")
+	if meta.ContentType != "" {
+		h.Set("Content-Type", meta.ContentType)
+	}
+	if !ok {
+		h.Set("Content-Range", fmt.Sprintf("bytes */%d", meta.Size))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+	h.Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	status := http.StatusOK
+	if partial {
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, meta.Size))
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead || end < start {
+		return nil
+	}
+	p.activeStreams.Add(1)
+	defer p.activeStreams.Add(-1)
+	p.stream(ctx, w, req, meta, start, end)
+	return nil
+}
+
+// 按分块顺序写入响应，同时预读后面的分块
+func (p *Proxy) stream(ctx context.Context, w http.ResponseWriter, req *Request, meta *fileMeta, start, end int64) {
+	type future struct {
+		done chan struct{}
+		data []byte
+		err  error
+	}
+	first, last := start/meta.ChunkSize, end/meta.ChunkSize
+	futures := make(map[int64]*future)
+	flusher, _ := w.(http.Flusher)
+	for index := first; index <= last; index++ {
+		for i := index; i <= last && i < index+int64(p.threads); i++ {
+			if _, ok := futures[i]; ok {
+				continue
+			}
+			f := &future{done: make(chan struct{})}
+			futures[i] = f
+			go func(i int64) {
+				f.data, f.err = p.fetchChunk(req, meta, i)
+				close(f.done)
+			}(i)
+		}
+		f := futures[index]
+		delete(futures, index)
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.done:
+		}
+		if f.err != nil {
+			helpers.AppLogger.Errorf("播放代理读取分块 %d 失败: %v", index, f.err)
+			return
+		}
+		chunkStart := index * meta.ChunkSize
+		from := max(start, chunkStart) - chunkStart
+		to := min(end+1, chunkStart+int64(len(f.data))) - chunkStart
+		if from >= to {
+			return
+		}
+		if _, err := w.Write(f.data[from:to]); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// 查询文件大小，没有缓存时用第一个分块的Range请求获取
+func (p *Proxy) stat(ctx context.Context, req *Request) (*fileMeta, error) {
+	key := req.cacheKey()
+	if meta := p.cache.getMeta(key); meta != nil {
+		return meta, nil
+	}
+	data, total, contentType, err := p.download(ctx, req, 0, p.chunkSize-1)
+	if err != nil {
+		return nil, err
+	}
+	meta := &fileMeta{Size: total, ContentType: contentType, ChunkSize: p.chunkSize}
+	p.cache.setMeta(key, meta)
+	p.misses.Add(1)
+	p.bytesFromUpstream.Add(int64(len(data)))
+	p.cache.put(key, 0, data, meta.pinned(0))
+	// 预先下载结尾的固定分块，mkv的cues和部分mp4的moov在文件结尾
+	go func() {
+		for i := meta.chunks() - 1; i > 0 && meta.pinned(i); i-- {
+			if _, err := p.fetchChunk(req, meta, i); err != nil {
+				helpers.AppLogger.Warnf("播放代理预读文件结尾失败: %v", err)
+				return
+			}
+		}
+	}()
+	return meta, nil
+}
+
+// 读取分块，先查缓存，没有缓存时下载，同一个分块同时只下载一次
+func (p *Proxy) fetchChunk(req *Request, meta *fileMeta, index int64) ([]byte, error) {
+	key := req.cacheKey()
+	if data, ok := p.cache.get(key, index); ok {
+		p.hits.Add(1)
+		p.bytesFromCache.Add(int64(len(data)))
+		return data, nil
+	}
+	callKey := fmt.Sprintf("%s#%d", key, index)
+	p.mutex.Lock()
+	if call, ok := p.inflight[callKey]; ok {
+		p.mutex.Unlock()
+		<-call.done
+		return call.data, call.err
+	}
+	call := &fetchCall{done: make(chan struct{})}
+	p.inflight[callKey] = call
+	p.mutex.Unlock()
+
+	call.data, call.err = p.downloadChunk(req, meta, index)
+	if call.err == nil {
+		p.misses.Add(1)
+		p.bytesFromUpstream.Add(int64(len(call.data)))
+		p.cache.put(key, index, call.data, meta.pinned(index))
+	}
+	p.mutex.Lock()
+	delete(p.inflight, callKey)
+	p.mutex.Unlock()
+	close(call.done)
+	return call.data, call.err
+}
+
+// 分块下载不跟随播放请求取消，下载完成后缓存给后面的请求使用
+func (p *Proxy) downloadChunk(req *Request, meta *fileMeta, index int64) ([]byte, error) {
+	start := index * meta.ChunkSize
+	end := min(start+meta.ChunkSize, meta.Size) - 1
+	var lastErr error
+	for i := 0; i < chunkRetries; i++ {
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), sourceCtxKey{}, req.Source), chunkTimeout)
+		data, _, _, err := p.download(ctx, req, start, end)
+		cancel()
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// 下载一段数据，返回数据、文件大小和文件类型
+func (p *Proxy) download(ctx context.Context, req *Request, start, end int64) ([]byte, int64, string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if req.UserAgent != "" {
+		httpReq.Header.Set("User-Agent", req.UserAgent)
+	}
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil, 0, "", errRangeNotSupported
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, 0, "", fmt.Errorf("网盘返回状态码 %d", resp.StatusCode)
+	}
+	total, err := parseContentRangeTotal(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, 0, "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, end-start+1))
+	if err != nil {
+		return nil, 0, "", err
+	}
+	if expected := min(end, total-1) - start + 1; int64(len(data)) != expected {
+		return nil, 0, "", fmt.Errorf("分块大小 %d 和请求的大小 %d 不一致", len(data), expected)
+	}
+	return data, total, resp.Header.Get("Content-Type"), nil
+}
+
+// 网盘不支持Range时按原来的方式直接透传
+func (p *Proxy) passthrough(ctx context.Context, w http.ResponseWriter, r *http.Request, req *Request) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		return err
+	}
+	// 复制客户端的 Range、Cookie、Referer 等头部
+	for _, k := range []string{"Range", "Cookie", "Referer"} {
+		if v, ok := r.Header[k]; ok {
+			httpReq.Header[k] = v
+		}
+	}
+	if req.UserAgent != "" {
+		httpReq.Header.Set("User-Agent", req.UserAgent)
+	}
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		for _, vv := range v {
+			w.Header().Add(k, vv)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+	return nil
+}
+
+// 播放代理的缓存命中统计
+func (p *Proxy) GetStats() *Stats {
+	used, chunks, pinned := p.cache.usage()
+	stats := &Stats{
+		Hits:              p.hits.Load(),
+		Misses:            p.misses.Load(),
+		BytesFromCache:    p.bytesFromCache.Load(),
+		BytesFromUpstream: p.bytesFromUpstream.Load(),
+		CacheUsed:         used,
+		CacheLimit:        p.cache.maxBytes,
+		CachedChunks:      chunks,
+		PinnedChunks:      pinned,
+		ActiveStreams:     p.activeStreams.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// 解析客户端的Range，只支持单个范围，多个范围时使用第一个
+// 格式错误时忽略Range返回整个文件，起始位置超出文件大小时ok为false
+func parseRange(header string, size int64) (start, end int64, partial bool, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if header == "" || !found {
+		return 0, size - 1, false, true
+	}
+	spec, _, _ = strings.Cut(spec, ",")
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, size - 1, false, true
+	}
+	if startStr == "" {
+		// 最后n个字节
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, size - 1, false, true
+		}
+		if size == 0 {
+			return 0, 0, false, false
+		}
+		return max(size-n, 0), size - 1, true, true
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, size - 1, false, true
+	}
+	if start >= size {
+		return 0, 0, false, false
+	}
+	end = size - 1
+	if endStr != "" {
+		e, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || e < start {
+			return 0, size - 1, false, true
+		}
+		end = min(e, size-1)
+	}
+	return start, end, true, true
+}
+
+// 解析响应的Content-Range中的文件大小，例如：bytes 0-1023/4096
+func parseContentRangeTotal(contentRange string) (int64, error) {
+	_, totalStr, found := strings.Cut(contentRange, "/")
+	if !found || totalStr == "*" {
+		return 0, fmt.Errorf("无法从Content-Range中获取文件大小: %s", contentRange)
+	}
+	total, err := strconv.ParseInt(strings.TrimSpace(totalStr), 10, 64)
+	if err != nil || total <= 0 {
+		return 0, fmt.Errorf("无法从Content-Range中获取文件大小: %s", contentRange)
+	}
+	return total, nil
+}
@@ -0,0 +1,225 @@
+package playproxy
+
+import (
+	"Q115-STRM/internal/helpers"
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	helpers.AppLogger = &helpers.QLogger{
+		Logger: log.New(io.Discard, "", 0),
+	}
+	os.Exit(m.Run())
+}
+
+func TestIsAllowedURL(t *testing.T) {
+	tests := []struct {
+		source SourceType
+		url    string
+		want   bool
+	}{
+		{Source115, "https://cdnfhnfile.115cdn.net/abc/video.mkv?t=1", true},
+		{Source115, "http://proapi.115.com/open/ufile/downurl", true},
+		{Source115, "https://evil115cdn.net/video.mkv", false},
+		{Source115, "https://115cdn.net.evil.com/video.mkv", false},
+		{Source115, "file:///etc/passwd", false},
+		{Source115, "http://127.0.0.1:8080/admin", false},
+		{Source115, "https://d.pcs.baidu.com/file/abc", false},
+		{SourceBaiduPan, "https://d.pcs.baidu.com/file/abc", true},
+		{SourceBaiduPan, "https://allall01.baidupcs.com/file/abc", true},
+		{SourceBaiduPan, "https://pan.baidu.com/api/list", false},
+		{SourceBaiduPan, "https://www.baidu.com/", false},
+		{SourceType("unknown"), "https://cdnfhnfile.115cdn.net/abc", false},
+	}
+	for _, tt := range tests {
+		if got := IsAllowedURL(tt.source, tt.url); got != tt.want {
+			t.Errorf("IsAllowedURL(%s, %q) = %v; want %v", tt.source, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	rawURL := "https://cdnfhnfile.115cdn.net/abc/video.mkv?t=1"
+	sign := Sign(Source115, "pc1", rawURL)
+	if !Verify(Source115, "pc1", rawURL, sign) {
+		t.Fatal("valid sign rejected")
+	}
+	if Verify(Source115, "pc2", rawURL, sign) || Verify(SourceBaiduPan, "pc1", rawURL, sign) || Verify(Source115, "pc1", rawURL+"2", sign) || Verify(Source115, "pc1", rawURL, "") {
+		t.Error("tampered sign accepted")
+	}
+	path := ProxyPath(SourceBaiduPan, "fs1", "https://d.pcs.baidu.com/file/abc")
+	if !strings.Contains(path, "baidupan=1") || !strings.Contains(path, "sign="+Sign(SourceBaiduPan, "fs1", "https://d.pcs.baidu.com/file/abc")) {
+		t.Errorf("ProxyPath = %s", path)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header               string
+		start, end           int64
+		partial, satisfiable bool
+	}{
+		{"", 0, 999, false, true},
+		{"bytes=0-", 0, 999, true, true},
+		{"bytes=100-199", 100, 199, true, true},
+		{"bytes=900-2000", 900, 999, true, true},
+		{"bytes=-100", 900, 999, true, true},
+		{"bytes=-5000", 0, 999, true, true},
+		{"bytes=100-199,300-399", 100, 199, true, true},
+		{"bytes=1000-", 0, 0, false, false},
+		{"bytes=abc", 0, 999, false, true},
+		{"items=0-1", 0, 999, false, true},
+	}
+	for _, tt := range tests {
+		start, end, partial, ok := parseRange(tt.header, 1000)
+		if start != tt.start || end != tt.end || partial != tt.partial || ok != tt.satisfiable {
+			t.Errorf("parseRange(%q) = %d, %d, %v, %v; want %d, %d, %v, %v", tt.header, start, end, partial, ok, tt.start, tt.end, tt.partial, tt.satisfiable)
+		}
+	}
+}
+
+// 支持Range的测试网盘，记录收到的请求数
+func newTestUpstream(t *testing.T, content []byte, ranges bool) (*httptest.Server, *atomic.Int64) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !ranges {
+			r.Header.Del("Range")
+			w.Header().Set("Content-Type", "video/x-matroska")
+			w.Write(content)
+			return
+		}
+		http.ServeContent(w, r, "video.mkv", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	allowedHosts["test"] = []string{"127.0.0.1"}
+	t.Cleanup(func() { delete(allowedHosts, "test") })
+	return server, &requests
+}
+
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i * 7 % 251)
+	}
+	return content
+}
+
+func doGet(t *testing.T, p *Proxy, req *Request, rangeHeader string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/proxy-115", nil)
+	if rangeHeader != "" {
+		r.Header.Set("Range", rangeHeader)
+	}
+	w := httptest.NewRecorder()
+	if err := p.Serve(w, r, req); err != nil {
+		t.Fatalf("Serve(%q): %v", rangeHeader, err)
+	}
+	return w
+}
+
+func TestProxyServeRanges(t *testing.T) {
+	content := testContent(3*1024*1024 + 12345)
+	server, requests := newTestUpstream(t, content, true)
+	p := NewProxy(t.TempDir(), 64*1024*1024, 256*1024, 3)
+	req := &Request{URL: server.URL + "/video.mkv", Source: "test", Key: "pickcode1"}
+
+	w := doGet(t, p, req, "")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("full body: status %d, %d bytes; want 200, %d bytes", w.Code, w.Body.Len(), len(content))
+	}
+	if w.Header().Get("Content-Type") != "video/x-matroska" {
+		t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+	}
+
+	w = doGet(t, p, req, "bytes=1000000-1300000")
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), content[1000000:1300001]) {
+		t.Fatalf("range body: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 1000000-1300000/3158073" {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	// 再次读取全部从缓存返回，不再请求网盘
+	before := requests.Load()
+	w = doGet(t, p, req, "bytes=-100")
+	if !bytes.Equal(w.Body.Bytes(), content[len(content)-100:]) {
+		t.Fatalf("suffix range body mismatch")
+	}
+	if requests.Load() != before {
+		t.Errorf("cached request hit upstream %d times", requests.Load()-before)
+	}
+	stats := p.GetStats()
+	if stats.Hits == 0 || stats.Misses == 0 || stats.CachedChunks == 0 || stats.PinnedChunks == 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	w = doGet(t, p, req, "bytes=99999999-")
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("unsatisfiable range status = %d", w.Code)
+	}
+}
+
+func TestProxyPassthroughWithoutRange(t *testing.T) {
+	content := testContent(100000)
+	server, _ := newTestUpstream(t, content, false)
+	p := NewProxy(t.TempDir(), 64*1024*1024, 16*1024, 2)
+	w := doGet(t, p, &Request{URL: server.URL + "/video.mkv", Source: "test", Key: "pickcode2"}, "bytes=100-")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("passthrough: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+}
+
+func TestProxyRejectsDisallowedHost(t *testing.T) {
+	p := NewProxy(t.TempDir(), 0, 0, 0)
+	r := httptest.NewRequest(http.MethodGet, "/proxy-115", nil)
+	err := p.Serve(httptest.NewRecorder(), r, &Request{URL: "http://127.0.0.1:1/video.mkv", Source: Source115})
+	if err == nil || !strings.Contains(err.Error(), "不允许") {
+		t.Errorf("Serve() error = %v; want host rejected", err)
+	}
+}
+
+func TestDiskCacheEvictKeepsPinned(t *testing.T) {
+	const chunk = 512 * 1024
+	meta := &fileMeta{Size: 8 * chunk, ChunkSize: chunk}
+	c := newDiskCache(t.TempDir(), 4*chunk, chunk)
+	data := make([]byte, chunk)
+	for i := int64(0); i < 8; i++ {
+		c.put("key", i, data, meta.pinned(i))
+	}
+	used, chunks, pinned := c.usage()
+	if used > 4*chunk || chunks != 4 {
+		t.Errorf("usage = %d bytes, %d chunks; want <= %d bytes, 4 chunks", used, chunks, 4*chunk)
+	}
+	// 开头两块和结尾两块是固定的
+	if pinned != 4 {
+		t.Errorf("pinned = %d; want 4", pinned)
+	}
+	for _, i := range []int64{0, 1, 6, 7} {
+		if _, ok := c.get("key", i); !ok {
+			t.Errorf("pinned chunk %d was evicted", i)
+		}
+	}
+
+	// 重新加载后保留已缓存的分块，没有文件信息的分块直接删除
+	c.setMeta("key", meta)
+	c.put("nometa", 0, data, true)
+	reloaded := newDiskCache(c.dir, 4*chunk, chunk)
+	if _, ok := reloaded.get("key", 7); !ok {
+		t.Errorf("chunk 7 missing after reload")
+	}
+	if reloaded.getMeta("key") == nil {
+		t.Errorf("meta missing after reload")
+	}
+	if _, ok := reloaded.get("nometa", 0); ok {
+		t.Errorf("chunk without meta should be dropped on reload")
+	}
+}
@@ -0,0 +1,47 @@
+package playproxy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+)
+
+// 本地代理链接的签名密钥，启动时随机生成
+// 代理链接只在获取下载链接后立即使用，重启后失效也没有影响
+var signSecret = func() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}()
+
+// 签名绑定来源、缓存key和下载链接，防止用任意pickcode和下载链接组合写入错误的缓存
+func Sign(source SourceType, key, rawURL string) string {
+	mac := hmac.New(sha256.New, signSecret)
+	mac.Write(fmt.Appendf(nil, "%s\n%s\n%s", source, key, rawURL))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// 校验本地代理链接的签名
+func Verify(source SourceType, key, rawURL, sign string) bool {
+	if sign == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(source, key, rawURL)), []byte(sign))
+}
+
+// 生成带签名的/proxy-115链接
+func ProxyPath(source SourceType, key, rawURL string) string {
+	query := url.Values{}
+	if source == SourceBaiduPan {
+		query.Set("baidupan", "1")
+	}
+	query.Set("pickcode", key)
+	query.Set("url", rawURL)
+	query.Set("sign", Sign(source, key, rawURL))
+	return "/proxy-115?" + query.Encode()
+}
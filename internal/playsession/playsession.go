@@ -0,0 +1,341 @@
+package playsession
+
+import (
+	ws "Q115-STRM/internal/websocket"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 超过同时播放数限制
+var ErrTooManyStreams = errors.New("同时播放数已达上限")
+
+// 会话类型
+type Kind string
+
+const (
+	KindEmby     Kind = "emby"     // emby302代理的Emby播放，播放进度续期，停止播放时移除
+	KindRedirect Kind = "redirect" // STRM直链跳转，无法知道何时停止播放，按最后一次请求的时间过期
+)
+
+// 会话多久没有续期就认为已经停止播放
+var sessionTTL = map[Kind]time.Duration{
+	KindEmby:     3 * time.Minute,
+	KindRedirect: 10 * time.Minute,
+}
+
+// 正在播放的会话
+type Session struct {
+	Key          string    `json:"key"`            // 会话标识，同一个设备或者同一个文件和IP只算一个播放
+	Kind         Kind      `json:"kind"`           // 会话类型
+	AccountId    uint      `json:"account_id"`     // 网盘账号ID，本地媒体或者无法识别时为0
+	AccountName  string    `json:"account_name"`   // 网盘账号备注
+	PickCode     string    `json:"pick_code"`      // 网盘文件PickCode
+	EmbyUserId   string    `json:"emby_user_id"`   // Emby用户ID
+	EmbyUserName string    `json:"emby_user_name"` // Emby用户名
+	ItemId       string    `json:"item_id"`        // Emby媒体ID
+	Client       string    `json:"client"`         // 客户端名称或UA
+	Device       string    `json:"device"`         // 设备名称
+	Ip           string    `json:"ip"`             // 客户端IP
+	BytesServed  int64     `json:"bytes_served"`   // 通过本地代理发送的字节数
+	StartedAt    time.Time `json:"started_at"`     // 开始播放时间
+	LastSeen     time.Time `json:"last_seen"`      // 最后一次续期时间
+}
+
+// 同时播放数限制，0表示不限制
+type Limits struct {
+	Account int // 网盘账号的最大同时播放数
+	User    int // Emby用户的最大同时播放数
+}
+
+// 网盘账号的播放统计
+type AccountStats struct {
+	AccountId     uint   `json:"account_id"`
+	AccountName   string `json:"account_name"`
+	ActiveStreams int    `json:"active_streams"` // 正在播放的会话数
+	BytesServed   int64  `json:"bytes_served"`   // 启动以来通过本地代理发送的字节数
+}
+
+// 当前所有会话和账号统计，通过EventHub推送给前端
+type Snapshot struct {
+	Sessions []Session      `json:"sessions"`
+	Accounts []AccountStats `json:"accounts"`
+}
+
+// 播放会话管理器
+type Manager struct {
+	mutex        sync.Mutex
+	sessions     map[string]*Session
+	accountBytes map[uint]int64
+	accountNames map[uint]string
+	now          func() time.Time
+	onChange     func(*Snapshot) // 会话增加或者移除时调用，在锁外执行
+}
+
+var (
+	defaultManager *Manager
+	defaultOnce    sync.Once
+)
+
+// 直链跳转的会话标识，同一个文件和IP只算一个播放，/proxy-115使用相同的标识统计流量
+func RedirectKey(pickCode, ip string) string {
+	return "redirect:" + pickCode + ":" + ip
+}
+
+// 全局会话管理器，第一次使用时启动过期清理并通过EventHub推送会话变化
+func GetManager() *Manager {
+	defaultOnce.Do(func() {
+		defaultManager = NewManager()
+		defaultManager.onChange = broadcast
+		go defaultManager.run(10 * time.Second)
+	})
+	return defaultManager
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		sessions:     make(map[string]*Session),
+		accountBytes: make(map[uint]int64),
+		accountNames: make(map[uint]string),
+		now:          time.Now,
+	}
+}
+
+// 检查是否允许新的播放，同一个会话续期不受限制
+func (m *Manager) Check(s Session, limits Limits) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pruneLocked()
+	return m.checkLocked(&s, limits)
+}
+
+// 检查同时播放数限制并登记会话，已经存在的会话只续期
+func (m *Manager) Admit(s Session, limits Limits) error {
+	m.mutex.Lock()
+	changed := m.pruneLocked()
+	if err := m.checkLocked(&s, limits); err != nil {
+		m.mutex.Unlock()
+		m.notify(changed)
+		return err
+	}
+	if m.upsertLocked(&s) {
+		changed = true
+	}
+	m.mutex.Unlock()
+	m.notify(changed)
+	return nil
+}
+
+// 登记或者续期会话，不检查限制，用于已经在播放的会话上报进度
+func (m *Manager) Touch(s Session) {
+	m.mutex.Lock()
+	changed := m.pruneLocked()
+	if m.upsertLocked(&s) {
+		changed = true
+	}
+	m.mutex.Unlock()
+	m.notify(changed)
+}
+
+// 停止播放，移除会话
+func (m *Manager) Remove(key string) {
+	m.mutex.Lock()
+	_, ok := m.sessions[key]
+	delete(m.sessions, key)
+	m.mutex.Unlock()
+	m.notify(ok)
+}
+
+// 记录通过本地代理发送的字节数，会话还在发送数据说明仍在播放，同时续期
+func (m *Manager) AddBytes(key string, n int64) {
+	if n <= 0 {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s, ok := m.sessions[key]
+	if !ok {
+		return
+	}
+	s.BytesServed += n
+	s.LastSeen = m.now()
+	if s.AccountId > 0 {
+		m.accountBytes[s.AccountId] += n
+	}
+}
+
+// 包装响应，统计写入的字节数
+func (m *Manager) Writer(w http.ResponseWriter, key string) http.ResponseWriter {
+	return &countingWriter{ResponseWriter: w, manager: m, key: key}
+}
+
+// 当前所有会话的副本，按开始时间排序
+func (m *Manager) Snapshot() *Snapshot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pruneLocked()
+	return m.snapshotLocked()
+}
+
+// 网盘账号当前的同时播放数
+func (m *Manager) AccountStreams(accountId uint) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pruneLocked()
+	return m.countLocked("", func(s *Session) bool { return s.AccountId == accountId })
+}
+
+func (m *Manager) checkLocked(s *Session, limits Limits) error {
+	if _, ok := m.sessions[s.Key]; ok {
+		return nil
+	}
+	if limits.Account > 0 && s.AccountId > 0 {
+		count := m.countLocked(s.Key, func(o *Session) bool { return o.AccountId == s.AccountId })
+		if count >= limits.Account {
+			return fmt.Errorf("%w：网盘账号 %s 最多同时播放 %d 个视频", ErrTooManyStreams, s.AccountName, limits.Account)
+		}
+	}
+	if limits.User > 0 && s.EmbyUserId != "" {
+		count := m.countLocked(s.Key, func(o *Session) bool { return o.EmbyUserId == s.EmbyUserId })
+		if count >= limits.User {
+			name := s.EmbyUserName
+			if name == "" {
+				name = s.EmbyUserId
+			}
+			return fmt.Errorf("%w：Emby用户 %s 最多同时播放 %d 个视频", ErrTooManyStreams, name, limits.User)
+		}
+	}
+	return nil
+}
+
+// 统计满足条件的会话数，不包括key对应的会话
+func (m *Manager) countLocked(key string, match func(*Session) bool) int {
+	count := 0
+	for k, s := range m.sessions {
+		if k != key && match(s) {
+			count++
+		}
+	}
+	return count
+}
+
+// 新增会话或者续期已有会话，返回是否是新增的会话
+func (m *Manager) upsertLocked(s *Session) bool {
+	now := m.now()
+	if s.AccountId > 0 && s.AccountName != "" {
+		m.accountNames[s.AccountId] = s.AccountName
+	}
+	old, ok := m.sessions[s.Key]
+	if !ok {
+		session := *s
+		session.StartedAt, session.LastSeen = now, now
+		m.sessions[s.Key] = &session
+		return true
+	}
+	// 续期时只更新有值的字段，进度上报时不知道网盘账号
+	old.LastSeen = now
+	if s.AccountId > 0 {
+		old.AccountId, old.AccountName, old.PickCode = s.AccountId, s.AccountName, s.PickCode
+	}
+	if s.EmbyUserId != "" {
+		old.EmbyUserId = s.EmbyUserId
+	}
+	if s.EmbyUserName != "" {
+		old.EmbyUserName = s.EmbyUserName
+	}
+	if s.ItemId != "" {
+		old.ItemId = s.ItemId
+	}
+	return false
+}
+
+// 移除过期的会话，返回是否有会话被移除
+func (m *Manager) pruneLocked() bool {
+	now := m.now()
+	removed := false
+	for key, s := range m.sessions {
+		if now.Sub(s.LastSeen) > sessionTTL[s.Kind] {
+			delete(m.sessions, key)
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (m *Manager) snapshotLocked() *Snapshot {
+	snapshot := &Snapshot{Sessions: make([]Session, 0, len(m.sessions)), Accounts: make([]AccountStats, 0)}
+	accounts := make(map[uint]*AccountStats)
+	getAccount := func(id uint) *AccountStats {
+		if stats, ok := accounts[id]; ok {
+			return stats
+		}
+		stats := &AccountStats{AccountId: id, AccountName: m.accountNames[id], BytesServed: m.accountBytes[id]}
+		accounts[id] = stats
+		return stats
+	}
+	for _, s := range m.sessions {
+		snapshot.Sessions = append(snapshot.Sessions, *s)
+		if s.AccountId > 0 {
+			getAccount(s.AccountId).ActiveStreams++
+		}
+	}
+	for id := range m.accountBytes {
+		getAccount(id)
+	}
+	for _, stats := range accounts {
+		snapshot.Accounts = append(snapshot.Accounts, *stats)
+	}
+	sort.Slice(snapshot.Sessions, func(i, j int) bool {
+		return snapshot.Sessions[i].StartedAt.Before(snapshot.Sessions[j].StartedAt)
+	})
+	sort.Slice(snapshot.Accounts, func(i, j int) bool {
+		return snapshot.Accounts[i].AccountId < snapshot.Accounts[j].AccountId
+	})
+	return snapshot
+}
+
+func (m *Manager) notify(changed bool) {
+	if !changed || m.onChange == nil {
+		return
+	}
+	m.onChange(m.Snapshot())
+}
+
+// 定时清理过期会话，有会话时定时推送，前端可以看到实时的代理流量
+func (m *Manager) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.mutex.Lock()
+		changed := m.pruneLocked()
+		active := len(m.sessions) > 0
+		m.mutex.Unlock()
+		m.notify(changed || active)
+	}
+}
+
+func broadcast(snapshot *Snapshot) {
+	ws.BroadcastEvent(ws.EventPlaySessions, snapshot)
+}
+
+// 统计写入字节数的响应
+type countingWriter struct {
+	http.ResponseWriter
+	manager *Manager
+	key     string
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.manager.AddBytes(w.key, int64(n))
+	return n, err
+}
+
+func (w *countingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,100 @@
+package playsession
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestManager() (*Manager, *time.Time) {
+	m := NewManager()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestAdmitAccountAndUserLimits(t *testing.T) {
+	m, _ := newTestManager()
+	limits := Limits{Account: 2, User: 1}
+
+	if err := m.Admit(Session{Key: "a", Kind: KindEmby, AccountId: 1, EmbyUserId: "u1"}, limits); err != nil {
+		t.Fatalf("first admit: %v", err)
+	}
+	// 同一个会话续期不受限制
+	if err := m.Admit(Session{Key: "a", Kind: KindEmby, AccountId: 1, EmbyUserId: "u1"}, limits); err != nil {
+		t.Fatalf("same key admit: %v", err)
+	}
+	if err := m.Admit(Session{Key: "b", Kind: KindEmby, AccountId: 1, EmbyUserId: "u1"}, limits); !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("user limit err = %v; want ErrTooManyStreams", err)
+	}
+	if err := m.Admit(Session{Key: "c", Kind: KindRedirect, AccountId: 1}, limits); err != nil {
+		t.Fatalf("second account stream: %v", err)
+	}
+	if err := m.Admit(Session{Key: "d", Kind: KindEmby, AccountId: 1, EmbyUserId: "u2"}, limits); !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("account limit err = %v; want ErrTooManyStreams", err)
+	}
+	// 其他账号和本地媒体不受影响
+	if err := m.Admit(Session{Key: "e", Kind: KindEmby, AccountId: 2, EmbyUserId: "u2"}, limits); err != nil {
+		t.Fatalf("other account: %v", err)
+	}
+	if got := m.AccountStreams(1); got != 2 {
+		t.Errorf("AccountStreams(1) = %d; want 2", got)
+	}
+
+	m.Remove("a")
+	if err := m.Check(Session{Key: "b", AccountId: 2, EmbyUserId: "u1"}, limits); err != nil {
+		t.Errorf("check after remove: %v", err)
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	m, now := newTestManager()
+	m.Touch(Session{Key: "emby", Kind: KindEmby, EmbyUserId: "u1"})
+	m.Touch(Session{Key: "redirect", Kind: KindRedirect, AccountId: 1})
+
+	*now = now.Add(5 * time.Minute)
+	// 续期时保留已有的账号信息
+	m.Touch(Session{Key: "redirect", Kind: KindRedirect, ItemId: "100"})
+	snapshot := m.Snapshot()
+	if len(snapshot.Sessions) != 1 || snapshot.Sessions[0].Key != "redirect" {
+		t.Fatalf("sessions = %+v; want only redirect", snapshot.Sessions)
+	}
+	if s := snapshot.Sessions[0]; s.AccountId != 1 || s.ItemId != "100" {
+		t.Errorf("merged session = %+v", s)
+	}
+
+	*now = now.Add(11 * time.Minute)
+	if got := len(m.Snapshot().Sessions); got != 0 {
+		t.Errorf("sessions after ttl = %d; want 0", got)
+	}
+}
+
+func TestWriterCountsBytes(t *testing.T) {
+	m, _ := newTestManager()
+	changes := 0
+	m.onChange = func(*Snapshot) { changes++ }
+	m.Touch(Session{Key: "a", Kind: KindRedirect, AccountId: 7, AccountName: "115"})
+
+	rec := httptest.NewRecorder()
+	w := m.Writer(rec, "a")
+	w.Write([]byte("hello"))
+	w.Write([]byte(" world"))
+	m.Writer(rec, "missing").Write([]byte("ignored"))
+
+	snapshot := m.Snapshot()
+	if got := snapshot.Sessions[0].BytesServed; got != 11 {
+		t.Errorf("session bytes = %d; want 11", got)
+	}
+	if len(snapshot.Accounts) != 1 || snapshot.Accounts[0].BytesServed != 11 || snapshot.Accounts[0].ActiveStreams != 1 || snapshot.Accounts[0].AccountName != "115" {
+		t.Errorf("accounts = %+v", snapshot.Accounts)
+	}
+	m.Remove("a")
+	snapshot = m.Snapshot()
+	if len(snapshot.Accounts) != 1 || snapshot.Accounts[0].BytesServed != 11 || snapshot.Accounts[0].ActiveStreams != 0 {
+		t.Errorf("accounts after remove = %+v", snapshot.Accounts)
+	}
+	if changes != 2 {
+		t.Errorf("changes = %d; want 2", changes)
+	}
+}
@@ -0,0 +1,92 @@
+package scrape
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"slices"
+)
+
+// 动画模式：识别电视剧后把绝对集数换算成TMDB的季和集，然后按新的季重新分组
+// 扫描阶段绝对集数的文件季都是1，换算后需要重新确定要处理的季
+func (t *tvShowScrapeImpl) MapAnimeEpisodes(tt *tvshowTask) error {
+	mediaFile := tt.mediaFile
+	var files []*models.ScrapeMediaFile
+	if err := db.Db.Where("scrape_path_id = ? AND tvshow_path = ? AND batch_no = ? AND absolute_episode > 0 AND status = ?", mediaFile.ScrapePathId, mediaFile.TvshowPath, mediaFile.BatchNo, models.ScrapeMediaStatusScanned).Find(&files).Error; err != nil {
+		helpers.AppLogger.Errorf("查询电视剧 %s 的绝对集数文件失败: %v", mediaFile.Name, err)
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	offsets := t.scrapePath.GetAnimeEpisodeOffsets()
+	var seasons []models.AnimeSeasonEpisodes
+	if len(offsets) == 0 {
+		var err error
+		if seasons, err = t.GetAnimeSeasonEpisodes(mediaFile.TmdbId); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		season, episode, ok := models.MapAbsoluteEpisode(file.AbsoluteEpisode, offsets, seasons)
+		if !ok {
+			helpers.AppLogger.Warnf("电视剧 %s 的绝对集数 %d 无法换算成季和集，文件 %s 保持季 %d 集 %d", mediaFile.Name, file.AbsoluteEpisode, file.VideoFilename, file.SeasonNumber, file.EpisodeNumber)
+			continue
+		}
+		if season == file.SeasonNumber && episode == file.EpisodeNumber {
+			continue
+		}
+		if err := db.Db.Model(&models.ScrapeMediaFile{}).Where("id = ?", file.ID).Updates(map[string]any{
+			"season_number":   season,
+			"episode_number":  episode,
+			"media_season_id": 0,
+		}).Error; err != nil {
+			helpers.AppLogger.Errorf("更新文件 %s 的季集失败: %v", file.VideoFilename, err)
+			return err
+		}
+		helpers.AppLogger.Infof("电视剧 %s 绝对集数 %d 换算为 S%02dE%02d，文件 %s", mediaFile.Name, file.AbsoluteEpisode, season, episode, file.VideoFilename)
+		// 电视剧任务的文件后面还会整体保存，需要同步内存中的季集
+		if file.ID == mediaFile.ID {
+			mediaFile.SeasonNumber = season
+			mediaFile.EpisodeNumber = episode
+			mediaFile.MediaSeasonId = 0
+		}
+	}
+	// 每个季取一个文件作为季任务
+	var all []*models.ScrapeMediaFile
+	if err := db.Db.Select("id", "season_number").Where("scrape_path_id = ? AND tvshow_path = ? AND batch_no = ? AND status IN ?", mediaFile.ScrapePathId, mediaFile.TvshowPath, mediaFile.BatchNo, []models.ScrapeMediaStatus{models.ScrapeMediaStatusScanned, models.ScrapeMediaStatusScraped}).Order("id asc").Find(&all).Error; err != nil {
+		helpers.AppLogger.Errorf("查询电视剧 %s 的所有季失败: %v", mediaFile.Name, err)
+		return err
+	}
+	seasonNumbers := make([]int, 0)
+	tt.seasons = tt.seasons[:0]
+	for _, file := range all {
+		if slices.Contains(seasonNumbers, file.SeasonNumber) {
+			continue
+		}
+		seasonNumbers = append(seasonNumbers, file.SeasonNumber)
+		tt.seasons = append(tt.seasons, file.ID)
+	}
+	helpers.AppLogger.Infof("电视剧 %s 换算绝对集数后共有 %d 个季待处理: %v", mediaFile.Name, len(seasonNumbers), seasonNumbers)
+	return nil
+}
+
+// 从TMDB查询每季的集数，按季编号排序，不含第0季特别篇
+func (t *tvShowScrapeImpl) GetAnimeSeasonEpisodes(tmdbId int64) ([]models.AnimeSeasonEpisodes, error) {
+	tvDetail, err := t.tmdbClient.GetTvDetail(tmdbId, models.GlobalScrapeSettings.GetTmdbLanguage())
+	if err != nil {
+		helpers.AppLogger.Errorf("查询tmdb电视剧 %d 的季列表失败: %v", tmdbId, err)
+		return nil, err
+	}
+	seasons := make([]models.AnimeSeasonEpisodes, 0, len(tvDetail.Seasons))
+	for _, s := range tvDetail.Seasons {
+		if s.SeasonNumber <= 0 {
+			continue
+		}
+		seasons = append(seasons, models.AnimeSeasonEpisodes{Season: s.SeasonNumber, Count: s.EpisodeCount})
+	}
+	slices.SortFunc(seasons, func(a, b models.AnimeSeasonEpisodes) int {
+		return a.Season - b.Season
+	})
+	return seasons, nil
+}
@@ -0,0 +1,90 @@
+package scrape
+
+import (
+	"Q115-STRM/internal/fanart"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/tmdb"
+	"Q115-STRM/internal/v115open"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 同一个合集的多部电影可能同时刮削，按合集ID加锁，避免重复插入和重复下载图片
+var collectionLock helpers.KeyLockWithTimeout
+
+// 记录电影所属的合集，合集信息超过缓存时间时从TMDB重新查询
+// 设置了合集目录时同时维护合集的海报和背景图
+func (m *movieScrapeImpl) SyncCollection(base *tmdb.CollectionBase) *models.MediaCollection {
+	lockKey := fmt.Sprintf("collection:%d", base.ID)
+	if !collectionLock.LockWithTimeout(lockKey, time.Minute) {
+		helpers.AppLogger.Warnf("等待合集 %s 的其他刮削任务超时，跳过更新", base.Name)
+		return models.GetMediaCollectionByTmdbId(base.ID)
+	}
+	defer collectionLock.Unlock(lockKey)
+	collection := models.GetMediaCollectionByTmdbId(base.ID)
+	refreshed := false
+	if collection == nil || collection.NeedsRefresh() {
+		detail, err := m.tmdbClient.GetCollectionDetail(base.ID, models.GlobalScrapeSettings.GetTmdbLanguage())
+		if err != nil {
+			helpers.AppLogger.Errorf("查询tmdb合集 %s 详情失败: %v", base.Name, err)
+			return collection
+		}
+		if collection == nil {
+			collection = &models.MediaCollection{}
+		}
+		collection.FillByTmdb(detail)
+		if err := collection.Save(); err != nil {
+			return collection
+		}
+		refreshed = true
+		helpers.AppLogger.Infof("已更新合集 %s，包含 %d 部电影", collection.Name, len(collection.Parts))
+	}
+	if m.scrapePath.CollectionPath != "" {
+		m.DownloadCollectionImages(collection, refreshed)
+	}
+	return collection
+}
+
+// 下载合集的海报和背景图到合集目录，目录结构和Kodi的电影合集信息目录一致：合集目录/合集名称/poster.jpg
+func (m *movieScrapeImpl) DownloadCollectionImages(collection *models.MediaCollection, refreshed bool) {
+	collectionPath := filepath.Join(m.scrapePath.CollectionPath, helpers.CleanFileName(collection.Name))
+	if !refreshed && helpers.PathExists(collectionPath) {
+		return
+	}
+	if err := os.MkdirAll(collectionPath, 0777); err != nil {
+		helpers.AppLogger.Errorf("创建合集目录 %s 失败: %v", collectionPath, err)
+		return
+	}
+	fileList := make(map[string]string)
+	if collection.PosterPath != "" {
+		fileList["poster"+filepath.Ext(collection.PosterPath)] = collection.PosterPath
+	}
+	if collection.BackdropPath != "" {
+		fileList["fanart"+filepath.Ext(collection.BackdropPath)] = collection.BackdropPath
+	}
+	if m.scrapePath.EnableFanartTv {
+		// fanart.tv的电影接口同样支持TMDB合集ID
+		resp, err := fanart.NewClient().GetMovieImages(collection.TmdbId)
+		if err != nil {
+			helpers.AppLogger.Errorf("从fanart.tv查询合集图片失败: tmdbId=%d, %v", collection.TmdbId, err)
+		} else {
+			if len(resp.HDMovieLogo) > 0 {
+				fileList["clearlogo.png"] = resp.HDMovieLogo[0].URL
+			}
+			if len(resp.HDMovieClearArt) > 0 {
+				fileList["clearart.png"] = resp.HDMovieClearArt[0].URL
+			}
+			if len(resp.MovieBanner) > 0 {
+				fileList["banner.jpg"] = resp.MovieBanner[0].URL
+			}
+			if len(resp.MovieThumb) > 0 {
+				fileList["landscape.jpg"] = resp.MovieThumb[0].URL
+			}
+		}
+	}
+	m.DownloadImages(collectionPath, v115open.DEFAULTUA, fileList)
+	helpers.AppLogger.Infof("已生成合集 %s 的图片，目录 %s", collection.Name, collectionPath)
+}
@@ -1,8 +1,16 @@
 package scrape
 
 import (
+	"Q115-STRM/internal/helpers"
 	"Q115-STRM/internal/models"
 	"context"
+	"errors"
+	"slices"
+)
+
+const (
+	reviewSearchLimit  = 10 // 计算可信度时最多比较的候选数量
+	reviewRuntimeLimit = 3  // 最多查询前几个候选的时长
 )
 
 type IdBase struct {
@@ -10,3 +18,44 @@ type IdBase struct {
 	scrapePath *models.ScrapePath
 	ctx        context.Context
 }
+
+// 检查识别结果的可信度，低于刮削目录的阈值时放入待确认队列并返回models.ErrScrapeNeedsReview
+// name、year是从文件名提取的信息；tmdbId是自动识别的结果，为0表示查询到多条记录，可信度足够时返回第一名
+func (b *IdBase) checkConfidence(mediaFile *models.ScrapeMediaFile, name string, year int, tmdbId int64) (*models.MatchCandidate, error) {
+	if name == "" {
+		name = mediaFile.Name
+	}
+	var duration int64
+	if mediaFile.VideoCodec != nil {
+		duration = mediaFile.VideoCodec.DurationInMinutes
+	}
+	candidates, err := b.tmdbImpl.SearchCandidates(name, year, duration > 0)
+	if err != nil {
+		if tmdbId != 0 {
+			// 查询失败时保留自动识别的结果
+			return nil, nil
+		}
+		return nil, err
+	}
+	if tmdbId != 0 && !slices.ContainsFunc(candidates, func(c *models.MatchCandidate) bool { return c.TmdbId == tmdbId }) {
+		// 自动识别的结果可能来自文件夹名称或其他提供者，补充到候选中一起比较
+		cname, cyear, cerr := b.tmdbImpl.CheckByTmdbId(tmdbId)
+		if cerr != nil {
+			return nil, nil
+		}
+		candidates = append(candidates, &models.MatchCandidate{TmdbId: tmdbId, Name: cname, Year: cyear})
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("tmdb没有数据")
+	}
+	confidence := models.ScoreMatchCandidates(candidates, name, year, duration, tmdbId)
+	mediaFile.MatchConfidence = confidence
+	if b.scrapePath.NeedsReview(confidence) {
+		return nil, mediaFile.NeedsReview(candidates, confidence)
+	}
+	helpers.AppLogger.Infof("文件 %s 的识别结果可信度 %.2f", mediaFile.VideoFilename, confidence)
+	if tmdbId == 0 {
+		return candidates[0], nil
+	}
+	return nil, nil
+}
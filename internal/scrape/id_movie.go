@@ -38,6 +38,20 @@ func (i *IdMovieImpl) Identify(mediaFile *models.ScrapeMediaFile) error {
 		mediaFile.Year = info.Year
 		mediaFile.Save()
 	}
+	checked := false
+	if err != nil && err.Error() == "多条记录" && i.scrapePath.ReviewThreshold > 0 {
+		// 查询到多部电影时按可信度选择，可信度不够时放入待确认队列
+		name, year := i.matchQuery(mediaFile)
+		candidate, cerr := i.checkConfidence(mediaFile, name, year, 0)
+		if cerr != nil {
+			return cerr
+		}
+		info = &helpers.MediaInfo{Name: candidate.Name, Year: candidate.Year, TmdbId: candidate.TmdbId}
+		mediaFile.Name = helpers.CleanFileName(info.Name)
+		mediaFile.Year = info.Year
+		checked = true
+		err = nil
+	}
 	if err != nil {
 		reason := err.Error()
 		if err.Error() == "多条记录" {
@@ -48,12 +62,34 @@ func (i *IdMovieImpl) Identify(mediaFile *models.ScrapeMediaFile) error {
 	if info.Name == "" || info.Year == 0 || info.TmdbId == 0 {
 		return errors.New("无法从文件名和文件夹名中提取到完整的剧集信息")
 	}
+	if i.scrapePath.ReviewThreshold > 0 && !checked {
+		// 检查自动识别结果的可信度
+		name, year := i.matchQuery(mediaFile)
+		if _, cerr := i.checkConfidence(mediaFile, name, year, info.TmdbId); cerr != nil {
+			return cerr
+		}
+	}
 	// 保存
 	mediaFile.TmdbId = info.TmdbId
 	mediaFile.Save()
 	return nil
 }
 
+// 从文件名提取用于计算可信度的名称和年份，缺少的部分从文件夹中补齐
+func (i *IdMovieImpl) matchQuery(mediaFile *models.ScrapeMediaFile) (string, int) {
+	info := helpers.ExtractMediaInfoRe(filepath.Base(mediaFile.VideoFilename), true, false, i.scrapePath.VideoExtList, i.scrapePath.DeleteKeyword...)
+	if info.Name == "" || info.Year == 0 {
+		folderInfo := helpers.ExtractMediaInfoRe(filepath.Base(mediaFile.Path), true, false, i.scrapePath.VideoExtList, i.scrapePath.DeleteKeyword...)
+		if info.Name == "" {
+			info.Name = folderInfo.Name
+		}
+		if info.Year == 0 {
+			info.Year = folderInfo.Year
+		}
+	}
+	return info.Name, info.Year
+}
+
 func (i *IdMovieImpl) extractInfo(mediaFile *models.ScrapeMediaFile) (*helpers.MediaInfo, error) {
 	disableAI := false
 	if i.scrapePath.EnableAi == models.AiActionEnforce {
@@ -15,7 +15,7 @@ type IdTvShowImpl struct {
 	IdBase
 }
 
-func NewIdTvShowImpl(scrapePath *models.ScrapePath, ctx context.Context, tmdbImpl *TmdbTvShowImpl) *IdTvShowImpl {
+func NewIdTvShowImpl(scrapePath *models.ScrapePath, ctx context.Context, tmdbImpl TmdbImpl) *IdTvShowImpl {
 	return &IdTvShowImpl{
 		IdBase: IdBase{
 			tmdbImpl:   tmdbImpl,
@@ -38,6 +38,20 @@ func (i *IdTvShowImpl) Identify(mediaFile *models.ScrapeMediaFile) error {
 		mediaFile.Year = info.Year
 		mediaFile.Save()
 	}
+	checked := false
+	if err != nil && err.Error() == "多条记录" && i.scrapePath.ReviewThreshold > 0 {
+		// 查询到多部电视剧时按可信度选择，可信度不够时放入待确认队列
+		name, year := i.matchQuery(mediaFile)
+		candidate, cerr := i.checkConfidence(mediaFile, name, year, 0)
+		if cerr != nil {
+			return cerr
+		}
+		info = &helpers.MediaInfo{Name: candidate.Name, Year: candidate.Year, TmdbId: candidate.TmdbId}
+		mediaFile.Name = helpers.CleanFileName(info.Name)
+		mediaFile.Year = info.Year
+		checked = true
+		err = nil
+	}
 	if err != nil {
 		reason := err.Error()
 		if err.Error() == "多条记录" {
@@ -49,12 +63,43 @@ func (i *IdTvShowImpl) Identify(mediaFile *models.ScrapeMediaFile) error {
 	if info.Name == "" || info.Year == 0 || info.TmdbId == 0 {
 		return errors.New("无法从文件名和文件夹名中提取到完整的电视剧信息")
 	}
+	if i.scrapePath.ReviewThreshold > 0 && !checked {
+		// 检查自动识别结果的可信度，只按名称查询后用季年份确认的结果也会在这里检查
+		name, year := i.matchQuery(mediaFile)
+		if _, cerr := i.checkConfidence(mediaFile, name, year, info.TmdbId); cerr != nil {
+			return cerr
+		}
+	}
 	// 保存
 	mediaFile.TmdbId = info.TmdbId
 	mediaFile.Save()
 	return nil
 }
 
+// 从文件名提取用于计算可信度的名称和年份，缺少的部分从电视剧文件夹中补齐
+func (i *IdTvShowImpl) matchQuery(mediaFile *models.ScrapeMediaFile) (string, int) {
+	filename := filepath.Base(mediaFile.VideoFilename)
+	info := helpers.ExtractMediaInfoRe(filename, false, false, i.scrapePath.VideoExtList, i.scrapePath.DeleteKeyword...)
+	if i.scrapePath.IsAnimeMode() {
+		if anime := helpers.ExtractAnimeEpisode(filename); anime.Name != "" {
+			info.Name = anime.Name
+			if info.Year == 0 {
+				info.Year = anime.Year
+			}
+		}
+	}
+	if info.Name == "" || info.Year == 0 {
+		folderInfo := helpers.ExtractMediaInfoRe(filepath.Base(mediaFile.TvshowPath), true, false, i.scrapePath.VideoExtList, i.scrapePath.DeleteKeyword...)
+		if info.Name == "" {
+			info.Name = folderInfo.Name
+		}
+		if info.Year == 0 {
+			info.Year = folderInfo.Year
+		}
+	}
+	return info.Name, info.Year
+}
+
 func (i *IdTvShowImpl) extractInfo(mediaFile *models.ScrapeMediaFile) (*helpers.MediaInfo, error) {
 	// 先从文件名中提取季和集
 	disableAI := false
@@ -249,6 +294,15 @@ func (i *IdTvShowImpl) extractInfoByREV2(mediaFile *models.ScrapeMediaFile) (*he
 	filename := filepath.Base(mediaFile.VideoFilename)
 	// 从文件名中获取媒体信息
 	info := helpers.ExtractMediaInfoRe(filename, false, false, i.scrapePath.VideoExtList, i.scrapePath.DeleteKeyword...)
+	// 动画模式下发布组、集数和分辨率标签会干扰标题提取，使用动画文件名规则提取的标题
+	if i.scrapePath.IsAnimeMode() {
+		if anime := helpers.ExtractAnimeEpisode(filename); anime.Name != "" {
+			info.Name = anime.Name
+			if info.Year == 0 {
+				info.Year = anime.Year
+			}
+		}
+	}
 	helpers.AppLogger.Infof("正则从文件名中提取信息，文件名 %s， 提取结果 %+v", filename, info)
 	info, err := i.find(mediaFile, info.TmdbId, info.Name, info.Year)
 	if err == nil {
@@ -0,0 +1,200 @@
+package scrape
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/v115open"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 刷新已整理条目的元数据，不移动和重命名视频文件
+// 返回是否有变化
+type refreshImpl interface {
+	Refresh(mediaFile *models.ScrapeMediaFile) (bool, error)
+}
+
+// 刷新刮削目录中播出时间临近或者标题是占位标题的已整理条目，返回有变化的条目数量
+func (s *Scrape) Refresh() int {
+	if !s.scrapePath.RefreshEnabled() {
+		return 0
+	}
+	if s.scrapePath.IsScraping {
+		helpers.AppLogger.Infof("刮削目录 %s 正在刮削，跳过本次元数据刷新", s.scrapePath.SourcePath)
+		return 0
+	}
+	ids := models.GetRefreshMediaFileIds(s.scrapePath, time.Now())
+	if len(ids) == 0 {
+		return 0
+	}
+	if err := s.initOpenClient(); err != nil {
+		helpers.AppLogger.Errorf("初始化刮削目录 %s 失败: %v", s.scrapePath.SourcePath, err)
+		return 0
+	}
+	s.CreateTmpRotDir()
+	s.initScrapeImpl()
+	impl, ok := s.scrapeImpl.(refreshImpl)
+	if !ok {
+		return 0
+	}
+	helpers.AppLogger.Infof("开始刷新刮削目录 %s 的元数据，共 %d 个条目", s.scrapePath.SourcePath, len(ids))
+	count := 0
+	for _, id := range ids {
+		select {
+		case <-s.ctx.Done():
+			helpers.AppLogger.Infof("刮削目录 %s 的元数据刷新已停止", s.scrapePath.SourcePath)
+			return count
+		default:
+		}
+		mediaFile := models.GetScrapeMediaFileById(id)
+		if mediaFile == nil {
+			continue
+		}
+		changed, err := impl.Refresh(mediaFile)
+		mediaFile.Refreshed()
+		if err != nil {
+			helpers.AppLogger.Errorf("刷新 %s 的元数据失败: %v", mediaFile.VideoFilename, err)
+			continue
+		}
+		if changed {
+			count++
+		}
+	}
+	helpers.AppLogger.Infof("刮削目录 %s 的元数据刷新完成，%d 个条目有变化", s.scrapePath.SourcePath, count)
+	return count
+}
+
+// 上传刷新后的nfo和图片
+// 本地直接覆盖，网盘先删除旧文件再加入上传队列，上传队列遇到同名文件时会直接跳过
+func (s *ScrapeBase) UploadRefreshedFiles(mediaFile *models.ScrapeMediaFile, files []uploadFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	if mediaFile.SourceType == models.SourceTypeLocal {
+		_, err := s.MoveLocalTempFileToDest(mediaFile, files)
+		return err
+	}
+	willDelete := make([]models.WillDeleteFile, 0, len(files))
+	for _, file := range files {
+		willDelete = append(willDelete, models.WillDeleteFile{FullFilePath: filepath.Join(file.DestPath, file.FileName)})
+	}
+	if err := s.renameImpl.CheckAndDeleteFiles(mediaFile, willDelete); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := models.AddUploadTaskFromMediaFile(mediaFile, s.scrapePath, file.FileName, file.SourcePath, filepath.Join(file.DestPath, file.FileName), file.DestPathId, false); err != nil {
+			helpers.AppLogger.Errorf("添加上传任务 %s 失败, 失败原因: %v", file.FileName, err)
+		}
+	}
+	return nil
+}
+
+// 重新查询集的元数据，只重新生成有变化的nfo和剧照
+func (t *tvShowScrapeImpl) Refresh(mediaFile *models.ScrapeMediaFile) (bool, error) {
+	if mediaFile.Media == nil || mediaFile.MediaSeason == nil || mediaFile.MediaEpisode == nil {
+		return false, fmt.Errorf("电视剧 %s 季 %d 集 %d 缺少刮削数据", mediaFile.Name, mediaFile.SeasonNumber, mediaFile.EpisodeNumber)
+	}
+	old := *mediaFile.MediaEpisode
+	if err := t.ScrapeEpisodeMedia(mediaFile); err != nil {
+		return false, err
+	}
+	episode := mediaFile.MediaEpisode
+	nfoChanged := old.EpisodeName != episode.EpisodeName || old.Overview != episode.Overview || old.ReleaseDate != episode.ReleaseDate || old.VoteAverage != episode.VoteAverage
+	imageChanged := episode.HasStill() && old.PosterPath != episode.PosterPath
+	if !nfoChanged && !imageChanged {
+		return false, nil
+	}
+	mediaFile.ScrapeRootPath = t.scrapePath.ScrapeRootPath
+	episodePath := mediaFile.GetTmpFullSeasonPath()
+	if err := os.MkdirAll(episodePath, 0777); err != nil {
+		helpers.AppLogger.Errorf("创建季目录 %s 失败, 失败原因: %v", episodePath, err)
+		return false, err
+	}
+	files := make([]uploadFile, 0, 2)
+	for _, file := range t.GetEpisodeUploadFiles(mediaFile) {
+		switch {
+		case nfoChanged && file.FileName == mediaFile.GetEpisodeNfoName():
+			if err := t.GenerateEpisodeNfo(mediaFile); err != nil {
+				return false, err
+			}
+		case imageChanged && file.FileName == mediaFile.GetEpisodePosterName():
+			os.Remove(file.SourcePath)
+			t.DownloadImages(episodePath, v115open.DEFAULTUA, map[string]string{file.FileName: episode.PosterPath})
+			if !helpers.PathExists(file.SourcePath) {
+				continue
+			}
+		default:
+			continue
+		}
+		files = append(files, file)
+	}
+	helpers.AppLogger.Infof("电视剧 %s 季 %d 集 %d 的元数据有变化，重新生成 %d 个文件", mediaFile.Name, mediaFile.SeasonNumber, mediaFile.EpisodeNumber, len(files))
+	t.SyncFilesToSTRMPath(mediaFile, files)
+	return true, t.UploadRefreshedFiles(mediaFile, files)
+}
+
+// 重新查询电影的元数据，只重新生成有变化的nfo和图片
+func (m *movieScrapeImpl) Refresh(mediaFile *models.ScrapeMediaFile) (bool, error) {
+	if mediaFile.Media == nil || mediaFile.MediaType != models.MediaTypeMovie {
+		return false, fmt.Errorf("电影 %s 缺少刮削数据", mediaFile.Name)
+	}
+	old := *mediaFile.Media
+	if err := m.ScrapeMovieMedia(mediaFile); err != nil {
+		return false, err
+	}
+	media := mediaFile.Media
+	nfoChanged := old.Name != media.Name || old.Overview != media.Overview || old.Tagline != media.Tagline ||
+		old.ReleaseDate != media.ReleaseDate || old.Runtime != media.Runtime || old.MpaaRating != media.MpaaRating ||
+		old.VoteAverage != media.VoteAverage || old.VoteCount != media.VoteCount || old.CollectionName != media.CollectionName
+	images := make(map[string]string)
+	for name, paths := range map[string][2]string{
+		"poster":    {old.PosterPath, media.PosterPath},
+		"clearlogo": {old.LogoPath, media.LogoPath},
+		"fanart":    {old.BackdropPath, media.BackdropPath},
+	} {
+		if paths[1] != "" && paths[0] != paths[1] {
+			images[m.GetMovieRealName(mediaFile, name+filepath.Ext(paths[1]), "image")] = paths[1]
+		}
+	}
+	if !nfoChanged && len(images) == 0 {
+		return false, nil
+	}
+	mediaFile.ScrapeRootPath = m.scrapePath.ScrapeRootPath
+	localTempPath := mediaFile.GetTmpFullMoviePath()
+	if err := os.MkdirAll(localTempPath, 0777); err != nil {
+		helpers.AppLogger.Errorf("创建临时目录 %s 失败: %v", localTempPath, err)
+		return false, err
+	}
+	fileNames := make([]string, 0, len(images)+1)
+	if nfoChanged {
+		nfoName := m.GetMovieRealName(mediaFile, "", "nfo")
+		if err := m.GenerateMovieNfo(mediaFile, localTempPath, nfoName, m.scrapePath.ExcludeNoImageActor); err != nil {
+			return false, err
+		}
+		fileNames = append(fileNames, nfoName)
+	}
+	for name := range images {
+		os.Remove(filepath.Join(localTempPath, name))
+	}
+	m.DownloadImages(localTempPath, v115open.DEFAULTUA, images)
+	for name := range images {
+		if helpers.PathExists(filepath.Join(localTempPath, name)) {
+			fileNames = append(fileNames, name)
+		}
+	}
+	files := make([]uploadFile, 0, len(fileNames))
+	for _, name := range fileNames {
+		files = append(files, uploadFile{
+			ID:         fmt.Sprintf("%d", mediaFile.ID),
+			FileName:   name,
+			SourcePath: filepath.Join(localTempPath, name),
+			DestPath:   mediaFile.GetDestFullMoviePath(),
+			DestPathId: mediaFile.NewPathId,
+		})
+	}
+	helpers.AppLogger.Infof("电影 %s 的元数据有变化，重新生成 %d 个文件", mediaFile.Name, len(files))
+	m.SyncFilesToSTRMPath(mediaFile, files)
+	return true, m.UploadRefreshedFiles(mediaFile, files)
+}
@@ -110,7 +110,7 @@ func (r *Rename115) move(mediaFile *models.ScrapeMediaFile, destPathId, destPath
 			// 检查是否需要改名
 			if mediaFile.VideoFilename != newName {
 				// 改名
-				newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+				newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 				if newSubName != sub.FileName {
 					// 改名
 					_, err := r.client.ReName(r.ctx, sub.FileId, newSubName)
@@ -242,7 +242,7 @@ func (r *Rename115) copy(mediaFile *models.ScrapeMediaFile, destPathId, destPath
 		}
 		for _, sub := range mediaFile.SubtitleFiles {
 			// 改名
-			newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 			newSub := &models.MediaMetaFiles{
 				FileName: newSubName,
 				FileId:   sub.FileId,
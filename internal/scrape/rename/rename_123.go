@@ -0,0 +1,334 @@
+package rename
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/open123"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+type Rename123 struct {
+	RenameBase
+	client *open123.Client
+}
+
+func NewRename123(ctx context.Context, scrapePath *models.ScrapePath, client *open123.Client) *Rename123 {
+	return &Rename123{
+		RenameBase: RenameBase{
+			scrapePath: scrapePath,
+			ctx:        ctx,
+		},
+		client: client,
+	}
+}
+
+func (r *Rename123) RenameAndMove(mediaFile *models.ScrapeMediaFile, destPath, destPathId, newName string) error {
+	switch mediaFile.RenameType {
+	case models.RenameTypeMove:
+		err := r.move(mediaFile, destPathId, destPath, newName)
+		if err != nil {
+			return err
+		}
+	case models.RenameTypeCopy:
+		helpers.AppLogger.Errorf("123云盘不支持复制方式整理文件：%s", mediaFile.VideoFilename)
+		return errors.New("123云盘不支持复制方式整理，请改为移动")
+	}
+	return nil
+}
+
+// 在目录下按名字查找文件或文件夹
+func (r *Rename123) findInDir(parentId int64, name string) (*open123.FileInfo, error) {
+	files, err := r.client.ListAllFiles(r.ctx, parentId)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.FileName == name {
+			return &f, nil
+		}
+	}
+	return nil, nil
+}
+
+// 移动文件到目标目录，如果名字不同则改名
+func (r *Rename123) moveAndRename(fileId string, destPathId string, oldName, newName string) error {
+	if err := r.client.MoveFiles(r.ctx, []int64{helpers.StringToInt64(fileId)}, helpers.StringToInt64(destPathId)); err != nil {
+		return err
+	}
+	if oldName != newName {
+		if err := r.client.Rename(r.ctx, helpers.StringToInt64(fileId), newName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Rename123) move(mediaFile *models.ScrapeMediaFile, destPathId, destPath, newName string) error {
+	// 先检查是否已存在，如果已存在，就不移动了
+	existsFile, _ := r.findInDir(helpers.StringToInt64(destPathId), newName)
+	videoFileId := mediaFile.VideoFileId
+	if existsFile == nil {
+		if err := r.moveAndRename(mediaFile.VideoFileId, destPathId, mediaFile.VideoFilename, newName); err != nil {
+			helpers.AppLogger.Errorf("123云盘移动文件失败: %v", err)
+			return err
+		}
+		helpers.AppLogger.Infof("文件 %s 成功移动到 %s", mediaFile.Path+"/"+mediaFile.VideoFilename, destPath+"/"+newName)
+	} else {
+		helpers.AppLogger.Infof("文件 %s 已存在, 无需移动", destPath+"/"+newName)
+		videoFileId = helpers.Int64ToString(existsFile.FileID)
+	}
+	// 123云盘没有pickcode，使用文件ID
+	if mediaFile.MediaType != models.MediaTypeTvShow {
+		mediaFile.Media.VideoFileId = videoFileId
+		mediaFile.Media.VideoPickCode = videoFileId
+	} else {
+		mediaFile.MediaEpisode.VideoFileId = videoFileId
+		mediaFile.MediaEpisode.VideoPickCode = videoFileId
+	}
+	oldBaseName := strings.TrimSuffix(mediaFile.VideoFilename, mediaFile.VideoExt)
+	// 移动字幕文件到新目录
+	if mediaFile.SubtitleFileJson != "" {
+		if mediaFile.MediaType != models.MediaTypeTvShow {
+			mediaFile.Media.SubtitleFiles = make([]*models.MediaMetaFiles, 0)
+		} else {
+			mediaFile.MediaEpisode.SubtitleFiles = make([]*models.MediaMetaFiles, 0)
+		}
+		for _, sub := range mediaFile.SubtitleFiles {
+			newSubName := sub.FileName
+			if mediaFile.VideoFilename != newName || sub.Language != "" {
+				newSubName = mediaFile.NewSubtitleName(sub, oldBaseName)
+			}
+			newSub := &models.MediaMetaFiles{
+				FileName: newSubName,
+				FileId:   sub.FileId,
+				PickCode: sub.PickCode,
+			}
+			if mediaFile.MediaType != models.MediaTypeTvShow {
+				mediaFile.Media.SubtitleFiles = append(mediaFile.Media.SubtitleFiles, newSub)
+			} else {
+				mediaFile.MediaEpisode.SubtitleFiles = append(mediaFile.MediaEpisode.SubtitleFiles, newSub)
+			}
+			if err := r.moveAndRename(sub.FileId, destPathId, sub.FileName, newSubName); err != nil {
+				helpers.AppLogger.Errorf("123云盘移动字幕文件 %s 失败: %v", sub.FileName, err)
+				continue
+			}
+			helpers.AppLogger.Infof("字幕文件 %s 成功移动为 %s", sub.FileName, newSubName)
+		}
+	}
+	if mediaFile.MediaType != models.MediaTypeTvShow {
+		// 保存
+		mediaFile.Media.Save()
+	} else {
+		// 保存
+		mediaFile.MediaEpisode.Save()
+	}
+
+	if mediaFile.ScrapeType == models.ScrapeTypeOnlyRename && mediaFile.MediaType == models.MediaTypeOther {
+		// 其他类型仅整理要把图片和nfo也转移过去
+		if mediaFile.ImageFilesJson != "" {
+			for _, imageFile := range mediaFile.ImageFiles {
+				newImageName := strings.Replace(imageFile.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+				if err := r.moveAndRename(imageFile.FileId, destPathId, imageFile.FileName, newImageName); err != nil {
+					helpers.AppLogger.Errorf("123云盘移动图片文件 %s 失败: %v", imageFile.FileName, err)
+					continue
+				}
+			}
+		}
+		// 移动nfo文件
+		if mediaFile.NfoFileId != "" {
+			newNfoName := strings.Replace(mediaFile.NfoFileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			if err := r.moveAndRename(mediaFile.NfoFileId, destPathId, mediaFile.NfoFileName, newNfoName); err != nil {
+				helpers.AppLogger.Errorf("123云盘移动nfo文件 %s 失败: %v", mediaFile.NfoFileName, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Rename123) CheckAndMkDir(destFullPath string, rootPath, rootPathId string) (string, error) {
+	relPath, err := filepath.Rel(rootPath, destFullPath)
+	if err != nil {
+		helpers.AppLogger.Errorf("获取相对路径失败: %v", err)
+		return "", err
+	}
+	// 将newPath中的\替换为/
+	relPath = strings.ReplaceAll(relPath, "\\", "/")
+	currentParentPath := rootPath
+	currentParentId := helpers.StringToInt64(rootPathId)
+	for p := range strings.SplitSeq(relPath, "/") {
+		if p == "" || p == "." {
+			continue
+		}
+		currentCheckPath := filepath.Join(currentParentPath, p)
+		// 先检查是否存在
+		existsDir, lErr := r.findInDir(currentParentId, p)
+		if lErr != nil {
+			helpers.AppLogger.Errorf("查询123云盘目录 %s 失败: %v", currentParentPath, lErr)
+			return "", lErr
+		}
+		if existsDir != nil && existsDir.IsDir() {
+			currentParentPath = currentCheckPath
+			currentParentId = existsDir.FileID
+			continue
+		}
+		// 分段创建目录
+		resp, mErr := r.client.CreateFolder(r.ctx, p, currentParentId)
+		if mErr != nil {
+			helpers.AppLogger.Errorf("创建父文件夹 %s 失败: %v", currentCheckPath, mErr)
+			return "", mErr
+		}
+		helpers.AppLogger.Infof("父文件夹创建成功，路径：%s，目录ID：%d", currentCheckPath, resp.DirID)
+		currentParentPath = currentCheckPath
+		currentParentId = resp.DirID
+	}
+	return helpers.Int64ToString(currentParentId), nil
+}
+
+func (r *Rename123) RemoveMediaSourcePath(mediaFile *models.ScrapeMediaFile, sp *models.ScrapePath) error {
+	hasSeason := true
+	sourcePathId := mediaFile.PathId
+	sourcePath := mediaFile.Path
+	if sourcePathId == "" {
+		sourcePathId = mediaFile.TvshowPathId
+		sourcePath = mediaFile.TvshowPath
+		hasSeason = false
+	}
+	if sourcePathId == mediaFile.SourcePathId {
+		helpers.AppLogger.Warnf("视频文件 %s 所在目录 %s 是来源根路径，不删除", mediaFile.Path, sourcePath)
+		return nil
+	}
+	files, err := r.client.ListAllFiles(r.ctx, helpers.StringToInt64(sourcePathId))
+	if err != nil {
+		helpers.AppLogger.Errorf("获取123云盘文件列表失败:路径：%s 文件夹ID=%s %v", sourcePath, sourcePathId, err)
+		return err
+	}
+	for _, file := range files {
+		if sp.IsVideoFile(file.FileName) {
+			helpers.AppLogger.Infof("目录 %s 下有其他视频文件，不删除", sourcePathId)
+			return nil
+		}
+	}
+	if len(files) == 0 || sp.ForceDeleteSourcePath {
+		if err := r.client.TrashFiles(r.ctx, []int64{helpers.StringToInt64(sourcePathId)}); err != nil {
+			helpers.AppLogger.Errorf("删除123云盘文件夹失败: 路径：%s 文件夹ID=%s %v", sourcePath, sourcePathId, err)
+			return err
+		}
+		helpers.AppLogger.Infof("刮削完成，删除123云盘中的文件夹成功, 路径：%s 文件夹ID=%s", sourcePath, sourcePathId)
+	}
+	// 再删除电视剧文件夹
+	if mediaFile.PathId != "" {
+		if mediaFile.TvshowPathId == sp.SourcePathId {
+			helpers.AppLogger.Infof("电视剧目录 %s 是来源根路径，不删除", mediaFile.TvshowPath)
+			return nil
+		}
+		tvshowFiles, err := r.client.ListAllFiles(r.ctx, helpers.StringToInt64(mediaFile.TvshowPathId))
+		if err != nil {
+			helpers.AppLogger.Errorf("获取123云盘文件列表失败:路径：%s 文件夹ID=%s %v", mediaFile.TvshowPath, mediaFile.TvshowPathId, err)
+			return err
+		}
+		if hasSeason {
+			// 如果含有季目录，需要检查电视剧目录下是否以己经没有季目录了
+			for _, tvshowFile := range tvshowFiles {
+				if tvshowFile.IsDir() {
+					helpers.AppLogger.Infof("电视剧目录 %s 下有其他目录 %s，不删除", mediaFile.TvshowPath, tvshowFile.FileName)
+					return nil
+				}
+			}
+		}
+		if len(tvshowFiles) == 0 || sp.ForceDeleteSourcePath {
+			if err := r.client.TrashFiles(r.ctx, []int64{helpers.StringToInt64(mediaFile.TvshowPathId)}); err != nil {
+				helpers.AppLogger.Errorf("删除123云盘文件夹失败: 路径：%s 文件夹ID=%s %v", mediaFile.TvshowPath, mediaFile.TvshowPathId, err)
+				return err
+			}
+			helpers.AppLogger.Infof("刮削完成，删除123云盘中的电视剧文件夹成功, 路径：%s 文件夹ID=%s", mediaFile.TvshowPath, mediaFile.TvshowPathId)
+		}
+	}
+	return nil
+}
+
+func (r *Rename123) ReadFileContent(fileId string) ([]byte, error) {
+	url, err := r.client.GetDirectLink(context.Background(), helpers.StringToInt64(fileId))
+	if err != nil || url == "" {
+		helpers.AppLogger.Errorf("获取123云盘文件下载链接失败: fileId=%s, %v", fileId, err)
+		return nil, errors.New("获取123云盘文件下载链接失败, url为空")
+	}
+	// 读取url的内容
+	content, err := helpers.ReadFromUrl(url, open123.DEFAULTUA)
+	if err != nil {
+		helpers.AppLogger.Errorf("123云盘读取文件下载链接内容失败: fileId=%s, url=%s, %v", fileId, url, err)
+		return nil, err
+	}
+	return content, nil
+}
+
+func (r *Rename123) CheckAndDeleteFiles(mediaFile *models.ScrapeMediaFile, files []models.WillDeleteFile) error {
+	for _, f := range files {
+		// 检查是否存在
+		fileId, err := r.client.GetPathIdByPath(r.ctx, f.FullFilePath)
+		if err != nil || fileId == 0 {
+			helpers.AppLogger.Infof("123云盘文件不存在，无需删除: 路径：%s", f.FullFilePath)
+			continue
+		}
+		if err := r.client.TrashFiles(r.ctx, []int64{fileId}); err != nil {
+			helpers.AppLogger.Errorf("删除123云盘文件失败: 路径：%s %v", f.FullFilePath, err)
+			continue
+		}
+		helpers.AppLogger.Infof("删除123云盘文件成功, 路径：%s", f.FullFilePath)
+	}
+	return nil
+}
+
+func (r *Rename123) MoveFiles(f models.MoveNewFileToSourceFile) error {
+	// 检查是否存在
+	existsFile, err := r.findInDir(helpers.StringToInt64(f.PathId), filepath.Base(f.FileFullPath))
+	if err == nil && existsFile != nil {
+		helpers.AppLogger.Infof("123云盘文件存在，无需移动: 路径：%s", f.FileFullPath)
+		return nil
+	}
+	// 移动文件
+	if err := r.client.MoveFiles(r.ctx, []int64{helpers.StringToInt64(f.FileId)}, helpers.StringToInt64(f.PathId)); err != nil {
+		helpers.AppLogger.Errorf("移动123云盘文件失败: 新路径：%s %v", f.FileFullPath, err)
+		return err
+	}
+	helpers.AppLogger.Infof("移动123云盘文件成功, %s => %s", f.FileId, f.FileFullPath)
+	return nil
+}
+
+func (r *Rename123) DeleteDir(path, pathId string) error {
+	if err := r.client.TrashFiles(r.ctx, []int64{helpers.StringToInt64(pathId)}); err != nil {
+		helpers.AppLogger.Errorf("删除123云盘目录失败: 路径：%s %v", path, err)
+		return err
+	}
+	helpers.AppLogger.Infof("删除123云盘目录成功, 路径：%s", path)
+	return nil
+}
+
+func (r *Rename123) Rename(fileId, newName string) error {
+	if err := r.client.Rename(r.ctx, helpers.StringToInt64(fileId), newName); err != nil {
+		helpers.AppLogger.Errorf("重命名123云盘文件失败：%s => %s 错误：%v", fileId, newName, err)
+		return err
+	}
+	helpers.AppLogger.Infof("重命名123云盘文件成功, %s => %s", fileId, newName)
+	return nil
+}
+
+// 检查是否存在，存在就改名字，然后返回新的fileId
+func (r *Rename123) ExistsAndRename(fileId, newName string) (string, error) {
+	detail, err := r.client.GetFileDetail(r.ctx, helpers.StringToInt64(fileId))
+	if err != nil || detail.Trashed == 1 {
+		helpers.AppLogger.Infof("123云盘文件不存在，无需重命名: 文件ID：%s", fileId)
+		return "", nil
+	}
+	// 如果名字没变则不需要改名字
+	if detail.FileName == newName {
+		helpers.AppLogger.Infof("123云盘文件名字没变，无需重命名: 文件ID：%s", fileId)
+		return fileId, nil
+	}
+	if err := r.Rename(fileId, newName); err != nil {
+		return "", err
+	}
+	return fileId, nil
+}
@@ -79,7 +79,7 @@ func (r *RenameBaiduPan) move(mediaFile *models.ScrapeMediaFile, newName, newPat
 			mediaFile.MediaEpisode.SubtitleFiles = make([]*models.MediaMetaFiles, 0)
 		}
 		for _, sub := range mediaFile.SubtitleFiles {
-			newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 			fileList = append(fileList, baidupan.MoveOrCopyItem{
 				Path:    filepath.ToSlash(filepath.Join(oldPath, sub.FileName)),
 				Dest:    newPathId,
@@ -156,7 +156,7 @@ func (r *RenameBaiduPan) copy(mediaFile *models.ScrapeMediaFile, newName, newPat
 			mediaFile.MediaEpisode.SubtitleFiles = make([]*models.MediaMetaFiles, 0)
 		}
 		for _, sub := range mediaFile.SubtitleFiles {
-			newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 			fileList = append(fileList, baidupan.MoveOrCopyItem{
 				Path:    filepath.ToSlash(filepath.Join(oldPath, sub.FileName)),
 				Dest:    newPathId,
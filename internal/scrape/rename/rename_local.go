@@ -76,7 +76,7 @@ func (r *RenameLocal) move(mediaFile *models.ScrapeMediaFile, destPathId, newNam
 		}
 		for _, sub := range mediaFile.SubtitleFiles {
 			// 改名+移动
-			newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 			newSubFullPath := filepath.Join(destPathId, newSubName)
 			err := helpers.MoveFile(sub.FileId, newSubFullPath, false)
 			if err != nil {
@@ -167,7 +167,7 @@ func (r *RenameLocal) copy(mediaFile *models.ScrapeMediaFile, destPathId, newNam
 		}
 		for _, sub := range mediaFile.SubtitleFiles {
 			// 改名+移动
-			newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 			newSubFullPath := filepath.Join(destPathId, newSubName)
 			err := helpers.CopyFile(sub.FileId, newSubFullPath)
 			if err != nil {
@@ -264,7 +264,7 @@ func (r *RenameLocal) symlink(mediaFile *models.ScrapeMediaFile, destPathId, new
 		}
 		for _, sub := range mediaFile.SubtitleFiles {
 			// 改名+移动
-			newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 			var err error
 			if isHard {
 				err = os.Link(sub.FileId, filepath.Join(destPathId, newSubName))
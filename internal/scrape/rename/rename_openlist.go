@@ -123,7 +123,7 @@ func (r *RenameOpenList) move(mediaFile *models.ScrapeMediaFile, newName, newPat
 		}
 		// 改名
 		for _, sub := range mediaFile.SubtitleFiles {
-			newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 			if newSubName != sub.FileName {
 				err := r.client.Rename(newPathId, sub.FileName, newSubName)
 				if err != nil {
@@ -251,7 +251,7 @@ func (r *RenameOpenList) copy(mediaFile *models.ScrapeMediaFile, newName, newPat
 		// 改名
 		for _, sub := range mediaFile.SubtitleFiles {
 			// 改名
-			newSubName := strings.Replace(sub.FileName, oldBaseName, mediaFile.NewVideoBaseName, 1)
+			newSubName := mediaFile.NewSubtitleName(sub, oldBaseName)
 			if newSubName != sub.FileName {
 				// 改名
 				err := r.client.Rename(newPathId, sub.FileName, newSubName)
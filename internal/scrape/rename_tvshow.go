@@ -4,6 +4,7 @@ import (
 	"Q115-STRM/internal/baidupan"
 	"Q115-STRM/internal/helpers"
 	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/openlist"
 	"Q115-STRM/internal/scrape/rename"
 	"Q115-STRM/internal/v115open"
@@ -19,7 +20,7 @@ type renameTvShowImpl struct {
 	renameImpl renameImpl
 }
 
-func NewRenameTvShowImpl(scrapePath *models.ScrapePath, ctx context.Context, v115Client *v115open.OpenClient, openlistClient *openlist.Client, baiduPanClient *baidupan.Client) renameImpl {
+func NewRenameTvShowImpl(scrapePath *models.ScrapePath, ctx context.Context, v115Client *v115open.OpenClient, openlistClient *openlist.Client, baiduPanClient *baidupan.Client, open123Client *open123.Client) renameImpl {
 	var ri renameImpl
 	switch scrapePath.SourceType {
 	case models.SourceType115:
@@ -28,6 +29,8 @@ func NewRenameTvShowImpl(scrapePath *models.ScrapePath, ctx context.Context, v11
 		ri = rename.NewRenameOpenList(ctx, scrapePath, openlistClient)
 	case models.SourceTypeBaiduPan:
 		ri = rename.NewRenameBaiduPan(ctx, scrapePath, baiduPanClient)
+	case models.SourceType123:
+		ri = rename.NewRename123(ctx, scrapePath, open123Client)
 	default:
 		ri = rename.NewRenameLocal(ctx, scrapePath)
 	}
@@ -0,0 +1,166 @@
+package scan
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/open123"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 从123云盘扫描需要刮削的文件入库
+type Scan123Impl struct {
+	scanBaseImpl
+	client   *open123.Client
+	dirPaths sync.Map // 目录ID => 目录完整路径，123云盘的列表接口不返回路径
+}
+
+func New123ScanImpl(scrapePath *models.ScrapePath, client *open123.Client, ctx context.Context) *Scan123Impl {
+	return &Scan123Impl{scanBaseImpl: scanBaseImpl{ctx: ctx, scrapePath: scrapePath}, client: client}
+}
+
+// 检查来源目录和目标目录是否存在
+func (s *Scan123Impl) CheckPathExists() error {
+	// 检查sourceId是否存在
+	sourceDetail, err := s.client.GetFileDetail(s.ctx, helpers.StringToInt64(s.scrapePath.SourcePathId))
+	if err != nil || sourceDetail.Trashed == 1 {
+		ferr := fmt.Errorf("刮削来源目录 %s => %s 疑似不存在，请检查或编辑重新选择来源目录: %v", s.scrapePath.SourcePathId, s.scrapePath.SourcePath, err)
+		return ferr
+	}
+	if s.scrapePath.ScrapeType != models.ScrapeTypeOnly {
+		// 检查targetId是否存在
+		targetDetail, err := s.client.GetFileDetail(s.ctx, helpers.StringToInt64(s.scrapePath.DestPathId))
+		if err != nil || targetDetail.Trashed == 1 {
+			ferr := fmt.Errorf("刮削目标目录 %s => %s 疑似不存在，请检查或编辑重新选择目标目录: %v", s.scrapePath.DestPathId, s.scrapePath.DestPath, err)
+			return ferr
+		}
+	}
+	return nil
+}
+
+// 扫描123云盘文件
+// 递归扫描指定路径下的所有文件
+func (s *Scan123Impl) GetNetFileFiles() error {
+	// 批次号
+	s.BatchNo = time.Now().Format("20060102150405000")
+	// 检查是否停止任务
+	if !s.CheckIsRunning() {
+		return errors.New("任务已停止")
+	}
+	if err := s.CheckPathExists(); err != nil {
+		return err
+	}
+	// 初始化路径队列，容量为接口线程数
+	s.pathTasks = make(chan string, models.SettingsGlobal.FileDetailThreads)
+	// 启动一个控制buffer的context
+	bufferCtx, cancelBuffer := context.WithCancel(context.Background())
+	// 启动buffer to task
+	go s.bufferMonitor(bufferCtx)
+	// 加入根目录
+	s.wg = sync.WaitGroup{}
+	s.dirPaths.Store(s.scrapePath.SourcePathId, s.scrapePath.SourcePath)
+	s.addPathToTasks(s.scrapePath.SourcePathId)
+	helpers.AppLogger.Infof("开始处理目录 %s, 开启 %d 个任务", s.scrapePath.SourcePath, models.SettingsGlobal.FileDetailThreads)
+	for i := 0; i < models.SettingsGlobal.FileDetailThreads; i++ {
+		go s.startPathWorkWithLimiter(i)
+	}
+	go func() {
+		<-s.ctx.Done()
+		for range s.pathTasks {
+			s.wg.Done()
+		}
+	}()
+	s.wg.Wait()        // 等待最后一个目录处理完
+	close(s.pathTasks) // 关闭pathTasks，释放资源
+	cancelBuffer()     // 取消bufferMonitor上下文，释放资源
+	return nil
+}
+
+func (s *Scan123Impl) startPathWorkWithLimiter(workerID int) {
+	// 从channel获取路径任务
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case pathId, ok := <-s.pathTasks:
+			if !ok {
+				return
+			}
+			picFiles := make([]*localFile, 0)
+			nfoFiles := make([]*localFile, 0)
+			subFiles := make([]*localFile, 0)
+			videoFiles := make([]*localFile, 0)
+			parentPath := ""
+			if p, ok := s.dirPaths.Load(pathId); ok {
+				parentPath = p.(string)
+			}
+			helpers.AppLogger.Infof("worker %d 开始处理目录 %s", workerID, parentPath)
+			fsList, err := s.client.ListAllFiles(s.ctx, helpers.StringToInt64(pathId))
+			if err != nil {
+				if strings.Contains(err.Error(), "context canceled") {
+					helpers.AppLogger.Infof("worker %d 处理目录 %s 失败 上下文已取消", workerID, pathId)
+				} else {
+					helpers.AppLogger.Errorf("worker %d 处理目录 %s 失败: %v", workerID, pathId, err)
+				}
+				s.wg.Done()
+				continue
+			}
+		fileloop:
+			for _, file := range fsList {
+				if !s.CheckIsRunning() {
+					s.wg.Done()
+					return
+				}
+				fileId := helpers.Int64ToString(file.FileID)
+				fullFilePathName := filepath.ToSlash(filepath.Join(parentPath, file.FileName))
+				if file.IsDir() {
+					// 是目录，加入队列
+					s.dirPaths.Store(fileId, fullFilePathName)
+					s.addPathToTasks(fileId)
+					continue fileloop
+				}
+				// 检查文件是否允许处理
+				if !s.scrapePath.CheckFileIsAllowed(file.FileName, file.Size) {
+					continue
+				}
+				// 检查文件是否已处理，如果已在数据库中，则直接跳过
+				if models.CheckExistsFileIdAndName(fileId, s.scrapePath.ID) {
+					helpers.AppLogger.Infof("文件 %s 已在数据库中，跳过", file.FileName)
+					continue
+				}
+				lf := &localFile{
+					Id:       fileId,
+					PickCode: fileId,
+					Name:     file.FileName,
+					Size:     file.Size,
+					Path:     fullFilePathName,
+				}
+				ext := filepath.Ext(file.FileName)
+				switch {
+				case slices.Contains(models.SubtitleExtArr, ext):
+					subFiles = append(subFiles, lf)
+				case slices.Contains(models.ImageExtArr, ext):
+					picFiles = append(picFiles, lf)
+				case ext == ".nfo":
+					nfoFiles = append(nfoFiles, lf)
+				case s.scrapePath.IsVideoFile(file.FileName):
+					videoFiles = append(videoFiles, lf)
+				}
+			}
+			// 处理视频文件
+			verr := s.processVideoFile(parentPath, pathId, videoFiles, picFiles, nfoFiles, subFiles)
+			if verr != nil {
+				s.wg.Done()
+				return
+			}
+			// 任务完成，通知WaitGroup
+			s.wg.Done()
+		}
+	}
+}
@@ -236,6 +236,17 @@ videoloop:
 			}
 		}
 		mediaFile.Status = models.ScrapeMediaStatusScanned
+		if s.scrapePath.MediaType == models.MediaTypeMovie {
+			// 预告片、花絮等附属视频不单独刮削，等正片整理后移动到正片目录
+			if extraType, ownerPath := models.ClassifyExtra(parentPath, videoFile.Name); extraType != "" {
+				helpers.AppLogger.Infof("文件 %s 是附属视频，类型 %s，正片文件夹 %s", videoFile.Name, extraType, ownerPath)
+				mediaFile.ExtraType = extraType
+				mediaFile.ExtraOwnerPath = ownerPath
+				mediaFile.Status = models.ScrapeMediaStatusExtra
+			} else {
+				mediaFile.Edition = models.ExtractEdition(videoFile.Name)
+			}
+		}
 		mediaFile.ScanTime = time.Now().Unix()
 		waitSaveFiles = append(waitSaveFiles, mediaFile)
 		if len(waitSaveFiles) > 100 {
@@ -252,7 +263,7 @@ videoloop:
 }
 
 func (m *scanBaseImpl) ExtractSeasonEpisode(mediaFile *models.ScrapeMediaFile) error {
-	if mediaFile.EpisodeNumber == -1 {
+	if mediaFile.EpisodeNumber == -1 && !mediaFile.ExtractAnimeEpisode(m.scrapePath) {
 		// 先识别季集
 		info := helpers.ExtractMediaInfoRe(mediaFile.VideoFilename, false, true, m.scrapePath.VideoExtList, m.scrapePath.DeleteKeyword...)
 		if info == nil {
@@ -271,6 +282,7 @@ func (m *scanBaseImpl) ExtractSeasonEpisode(mediaFile *models.ScrapeMediaFile) e
 			helpers.AppLogger.Infof("从电视剧文件夹中提取到季数: %d", mediaFile.SeasonNumber)
 		}
 	}
+	mediaFile.CheckAbsoluteEpisode()
 	if mediaFile.SeasonNumber == -1 {
 		mediaFile.SeasonNumber = 1
 	}
@@ -4,6 +4,7 @@ import (
 	"Q115-STRM/internal/baidupan"
 	"Q115-STRM/internal/helpers"
 	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/openlist"
 	"Q115-STRM/internal/scrape/scan"
 	"Q115-STRM/internal/tmdb"
@@ -40,6 +41,7 @@ type TmdbImpl interface {
 	CheckByNameAndYear(name string, year int, switchYear bool) (string, int64, int, error)
 	CheckByTmdbId(tmdbId int64) (string, int, error)
 	CheckSeasonByTmdbId(tmdbId int64, seasonNumber int) (*tmdb.SeasonDetail, error)
+	SearchCandidates(name string, year int, withRuntime bool) ([]*models.MatchCandidate, error)
 }
 
 type categoryImpl interface {
@@ -76,6 +78,7 @@ type Scrape struct {
 	V115Client     *v115open.OpenClient
 	OpenlistClient *openlist.Client
 	BaiduPanClient *baidupan.Client
+	Open123Client  *open123.Client
 }
 
 // scrapePath 要刮削的目录
@@ -105,6 +108,8 @@ func (s *Scrape) initOpenClient() error {
 		s.OpenlistClient = account.GetOpenListClient()
 	case models.SourceTypeBaiduPan:
 		s.BaiduPanClient = account.GetBaiDuPanClient()
+	case models.SourceType123:
+		s.Open123Client = account.Get123Client()
 	}
 	return nil
 }
@@ -115,6 +120,9 @@ func (s *Scrape) CreateTmpRotDir() {
 	if s.scrapePath.MediaType == models.MediaTypeTvShow {
 		s.scrapePath.ScrapeRootPath = filepath.Join(helpers.ConfigDir, "tmp", "刮削临时文件", fmt.Sprintf("%d", s.scrapePath.ID), "电视剧")
 	}
+	if s.scrapePath.MediaType == models.MediaTypeMusic {
+		s.scrapePath.ScrapeRootPath = filepath.Join(helpers.ConfigDir, "tmp", "刮削临时文件", fmt.Sprintf("%d", s.scrapePath.ID), "音乐")
+	}
 	if err := os.MkdirAll(s.scrapePath.ScrapeRootPath, 0777); err != nil {
 		helpers.AppLogger.Errorf("创建临时目录失败: %v", err)
 		return
@@ -141,12 +149,22 @@ func (s *Scrape) init() {
 		s.scanImpl = scan.NewOpenlistScanImpl(s.scrapePath, s.OpenlistClient, s.ctx)
 	case models.SourceTypeBaiduPan:
 		s.scanImpl = scan.NewBaiduPanScanImpl(s.scrapePath, s.BaiduPanClient, s.ctx)
+	case models.SourceType123:
+		s.scanImpl = scan.New123ScanImpl(s.scrapePath, s.Open123Client, s.ctx)
 	}
 	// 确定扫描接口，识别接口，刮削接口，重命名接口
-	if s.scrapePath.MediaType == models.MediaTypeTvShow {
-		s.scrapeImpl = NewTvShowScrapeImpl(s.scrapePath, s.ctx, s.V115Client, s.OpenlistClient, s.BaiduPanClient)
-	} else {
-		s.scrapeImpl = NewMovieScrapeImpl(s.scrapePath, s.ctx, s.V115Client, s.OpenlistClient, s.BaiduPanClient)
+	s.initScrapeImpl()
+}
+
+// 按媒体类型创建刮削接口
+func (s *Scrape) initScrapeImpl() {
+	switch s.scrapePath.MediaType {
+	case models.MediaTypeTvShow:
+		s.scrapeImpl = NewTvShowScrapeImpl(s.scrapePath, s.ctx, s.V115Client, s.OpenlistClient, s.BaiduPanClient, s.Open123Client)
+	case models.MediaTypeMusic:
+		s.scrapeImpl = NewMusicScrapeImpl(s.scrapePath, s.ctx, s.V115Client, s.OpenlistClient, s.BaiduPanClient, s.Open123Client)
+	default:
+		s.scrapeImpl = NewMovieScrapeImpl(s.scrapePath, s.ctx, s.V115Client, s.OpenlistClient, s.BaiduPanClient, s.Open123Client)
 	}
 }
 
@@ -177,6 +195,7 @@ func (s *Scrape) Start() bool {
 	s.scrapePath.V115Client = s.V115Client
 	s.scrapePath.OpenListClient = s.OpenlistClient
 	s.scrapePath.BaiduPanClient = s.BaiduPanClient
+	s.scrapePath.Open123Client = s.Open123Client
 	s.scrapePath.GenerateCategory()
 	// 获取视频文件列表并从文件名中提取媒体信息用来刮削
 	eerr := s.scanImpl.GetNetFileFiles()
@@ -4,13 +4,20 @@ import (
 	"Q115-STRM/internal/baidupan"
 	"Q115-STRM/internal/helpers"
 	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/openlist"
+	"Q115-STRM/internal/playproxy"
+	"Q115-STRM/internal/syncstrm"
 	"Q115-STRM/internal/tmdb"
 	"Q115-STRM/internal/v115open"
+	ws "Q115-STRM/internal/websocket"
 	"context"
 	"fmt"
-	"net/url"
+	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
 )
 
 type uploadFile struct {
@@ -29,9 +36,17 @@ type ScrapeBase struct {
 	categoryImpl   categoryImpl
 	renameImpl     renameImpl
 	tmdbClient     *tmdb.Client
+	providers      *metadataProviders // TMDB以外的元数据提供者
 	v115Client     *v115open.OpenClient
 	openlistClient *openlist.Client
 	baiduPanClient *baidupan.Client
+	open123Client  *open123.Client
+}
+
+// 查询TMDB以外的元数据提供者，结果在FillInfoByTmdbInfo中合并
+func (s *ScrapeBase) fillProviderDetails(tmdbInfo *models.TmdbInfo, titles []string, year int, ids map[string]string) {
+	tmdbInfo.PrimaryProvider = s.scrapePath.GetMetadataProvider()
+	tmdbInfo.ProviderDetails = s.providers.details(titles, year, ids)
 }
 
 // 下载图片到指定文件
@@ -75,7 +90,11 @@ func (s *ScrapeBase) FFprobe(mediaFile *models.ScrapeMediaFile) error {
 	}
 	if mediaFile.SourceType == models.SourceType115 {
 		// 代理访问
-		videoPathOrUrl = fmt.Sprintf("http://127.0.0.1:12333/proxy-115?url=%s", url.QueryEscape(videoPathOrUrl))
+		videoPathOrUrl = "http://127.0.0.1:12333" + playproxy.ProxyPath(playproxy.Source115, mediaFile.VideoPickCode, videoPathOrUrl)
+	}
+	if mediaFile.SourceType == models.SourceTypeBaiduPan {
+		// ffprobe不能指定百度网盘要求的UA，通过本地代理访问
+		videoPathOrUrl = "http://127.0.0.1:12333" + playproxy.ProxyPath(playproxy.SourceBaiduPan, mediaFile.VideoPickCode, videoPathOrUrl)
 	}
 	// 如果有下载连接，则提取视频信息
 	s.GetFFprobeInfoFromFileOrUrl(mediaFile, videoPathOrUrl)
@@ -156,6 +175,8 @@ func (sm *ScrapeBase) GetFFprobeInfoFromFileOrUrl(mediaFile *models.ScrapeMediaF
 				Micodec:     stream.CodecName,
 				Title:       stream.Tags["title"],
 				Language:    stream.Tags["language"],
+				Default:     strconv.Itoa(stream.Disposition["default"]),
+				Forced:      strconv.Itoa(stream.Disposition["forced"]),
 			}
 			mediaFile.SubtitleCodec = append(mediaFile.SubtitleCodec, sub)
 		}
@@ -180,10 +201,31 @@ func (s *ScrapeBase) GetDownloadUrl(mediaFile *models.ScrapeMediaFile) string {
 	case models.SourceTypeOpenList:
 		videoPathOrUrl = s.openlistClient.GetRawUrl(mediaFile.VideoPickCode)
 	case models.SourceType123:
+		directUrl, err := s.open123Client.GetDirectLink(context.Background(), helpers.StringToInt64(mediaFile.VideoPickCode))
+		if err != nil {
+			helpers.AppLogger.Errorf("获取123云盘下载链接失败: %v", err)
+			return ""
+		}
+		videoPathOrUrl = directUrl
+	case models.SourceTypeBaiduPan:
+		directUrl, err := s.baiduPanClient.GetDownloadUrl(context.Background(), mediaFile.VideoPickCode)
+		if err != nil {
+			helpers.AppLogger.Errorf("获取百度网盘下载链接失败: %v", err)
+			return ""
+		}
+		videoPathOrUrl = directUrl
 	}
 	return videoPathOrUrl
 }
 
+// 访问GetDownloadUrl返回的下载链接时使用的UA，115的下载链接和获取时的UA绑定，百度网盘只能使用pan.baidu.com
+func (s *ScrapeBase) GetDownloadUA(mediaFile *models.ScrapeMediaFile) string {
+	if mediaFile.SourceType == models.SourceTypeBaiduPan {
+		return baidupan.DEFAULTUA
+	}
+	return v115open.DEFAULTUA
+}
+
 // 将本地临时文件移动到本地目标路径
 func (m *ScrapeBase) MoveLocalTempFileToDest(mediaFile *models.ScrapeMediaFile, files []uploadFile) (bool, error) {
 	if mediaFile.SourceType != models.SourceTypeLocal {
@@ -206,3 +248,156 @@ func (m *ScrapeBase) MoveLocalTempFileToDest(mediaFile *models.ScrapeMediaFile,
 	}
 	return true, nil
 }
+
+// 电影和音乐共用的工作协程池：每次从数据库中查询maxthreads*2个任务加入队列，等待处理完成后继续下一次查询直到无法查询到数据
+// name用于日志，process是单个文件的处理流程
+func (s *ScrapeBase) runFileTasks(name string, process func(*models.ScrapeMediaFile) error) error {
+	s.fileTasks = make(chan *models.ScrapeMediaFile, s.scrapePath.GetMaxThreads())
+	// 启动N个协程协程，由s.ctx控制是否取消
+	wg := &sync.WaitGroup{}
+	max := s.scrapePath.GetMaxThreads()
+	// 查询数据库中所有待刮削和待整理的记录总数来决定要启动的工作协程数量
+	total := models.GetScannedScrapeMediaFilesTotal(s.scrapePath.ID, s.scrapePath.MediaType)
+	if total == 0 {
+		helpers.AppLogger.Infof("没有待刮削和待整理的记录，无需启动刮削任务")
+		return nil
+	}
+	threads := min(max, int(total))
+	for i := 0; i < threads; i++ {
+		go s.fileTaskWorker(name, i+1, wg, process)
+	}
+mainloop:
+	for {
+		select {
+		case <-s.ctx.Done():
+			helpers.AppLogger.Infof("%s主循环检测到停止信号，退出", name)
+			break mainloop
+		default:
+			// 从数据库取数据
+			mediaFiles := models.GetScannedScrapeMediaFiles(s.scrapePath.ID, s.scrapePath.MediaType, s.scrapePath.GetMaxThreads()*2)
+			if len(mediaFiles) == 0 {
+				helpers.AppLogger.Infof("所有待刮削和待整理记录都已加入处理队列，关闭队列通道，等待执行完成")
+				close(s.fileTasks)
+				break mainloop
+			}
+			for _, mediaFile := range mediaFiles {
+				s.fileTasks <- mediaFile
+				wg.Add(1) // 加进去之后，计数+1
+				helpers.AppLogger.Infof("文件 %s 已加入刮削处理队列", mediaFile.VideoFilename)
+			}
+			wg.Wait()
+		}
+	}
+	helpers.AppLogger.Infof("所有刮削整理任务都已完成，本次任务结束")
+	return nil
+}
+
+func (s *ScrapeBase) fileTaskWorker(name string, taskIndex int, wg *sync.WaitGroup, process func(*models.ScrapeMediaFile) error) {
+mainloop:
+	for {
+		select {
+		case <-s.ctx.Done():
+			helpers.AppLogger.Infof("%s工作线程 %d 检测到停止信号，退出", name, taskIndex)
+			return
+		case mediaFile, ok := <-s.fileTasks:
+			if !ok {
+				helpers.AppLogger.Infof("刮削整理任务队列 %d 已关闭", taskIndex)
+				return
+			}
+			err := process(mediaFile)
+			wg.Done() // 处理完成后，计数-1
+			if err != nil {
+				helpers.AppLogger.Errorf("任务队列 %d 刮削文件 %s 失败: %v", taskIndex, mediaFile.VideoFilename, err)
+			}
+			// 触发单个刮削项完成事件
+			ws.BroadcastEvent(ws.EventScraperItemComplete, map[string]any{
+				"item_id": mediaFile.ID,
+				"name":    mediaFile.VideoFilename,
+				"status":  string(mediaFile.Status),
+				"success": err == nil,
+			})
+			continue mainloop
+		case <-time.After(5 * time.Minute):
+			return // 5分钟没响应自动退出
+		}
+	}
+}
+
+// 先命中一个syncPath，使用newPath
+func (s *ScrapeBase) SyncFilesToSTRMPath(mediaFile *models.ScrapeMediaFile, files []uploadFile) {
+	syncPath := s.scrapePath.GetSyncPathByPath(mediaFile.Media.Path)
+	if syncPath == nil {
+		helpers.AppLogger.Errorf("未命中任何STRM同步目录, 无法将文件同步到STRM目录 %s", mediaFile.Media.Path)
+		return
+	}
+	// 先生成STRM文件
+	// 1. 构造STRM文件路径
+	syncStrm := syncstrm.NewSyncStrmFromSyncPath(syncPath)
+	strmErr := syncStrm.ProcessStrmFile(&syncstrm.SyncFileCache{
+		Path:          mediaFile.Media.Path,
+		ParentId:      mediaFile.Media.PathId,
+		FileType:      v115open.TypeFile,
+		FileName:      mediaFile.Media.VideoFileName,
+		FileId:        mediaFile.Media.VideoFileId,
+		PickCode:      mediaFile.Media.VideoPickCode,
+		OpenlistSign:  mediaFile.Media.VideoOpenListSign,
+		FileSize:      0,
+		MTime:         0,
+		IsVideo:       true,
+		IsMeta:        false,
+		LocalFilePath: filepath.Join(syncPath.LocalPath, mediaFile.Media.Path, mediaFile.NewVideoBaseName+".strm"),
+	})
+	if strmErr != nil {
+		helpers.AppLogger.Errorf("生成STRM文件失败, 失败原因: %v", strmErr)
+		return
+	}
+	models.DeleteSyncRecordById(syncStrm.Sync.ID)
+	if files == nil {
+		return
+	}
+	// 将其他文件放入STRM同步目录内
+	for _, file := range files {
+		destPath := filepath.Join(syncPath.LocalPath, file.DestPath)
+		if !helpers.PathExists(destPath) {
+			err := os.MkdirAll(destPath, 0755)
+			if err != nil {
+				helpers.AppLogger.Errorf("创建目录 %s 失败, 失败原因: %v", destPath, err)
+			}
+		}
+		destFile := filepath.Join(destPath, file.FileName)
+		// 复制过去
+		err := helpers.CopyFile(file.SourcePath, destFile)
+		if err != nil {
+			helpers.AppLogger.Errorf("复制文件 %s 到 %s 失败, 失败原因: %v", file.SourcePath, destFile, err)
+		}
+		helpers.AppLogger.Infof("复制文件 %s 到 %s 成功", file.SourcePath, destFile)
+	}
+}
+
+// 创建父文件夹，电影和音乐都使用GetDestFullMoviePath生成的目录
+func (s *ScrapeBase) MakeParentPath(mediaFile *models.ScrapeMediaFile, categoryMap map[uint]string) error {
+	if mediaFile.ScrapeType == models.ScrapeTypeOnly {
+		mediaFile.NewPathId = mediaFile.PathId
+		mediaFile.Save()
+		helpers.AppLogger.Infof("仅刮削模式下，使用旧目录存放元数据：%s，目录ID：%s", mediaFile.Path, mediaFile.PathId)
+		return nil
+	}
+	parentId := mediaFile.DestPathId
+	if mediaFile.ScrapePathCategoryId > 0 {
+		if category, ok := categoryMap[mediaFile.ScrapePathCategoryId]; ok {
+			parentId = category
+		}
+	}
+	destFullPath := mediaFile.GetDestFullMoviePath()
+	helpers.AppLogger.Infof("影视剧文件夹，目标路径：%s，根目录ID：%s", destFullPath, parentId)
+	newPathId, err := s.renameImpl.CheckAndMkDir(destFullPath, mediaFile.DestPath, mediaFile.DestPathId)
+	if err != nil {
+		helpers.AppLogger.Errorf("创建父文件夹失败: %v", err)
+		return err
+	}
+	mediaFile.NewPathId = newPathId
+	mediaFile.Media.PathId = newPathId
+	mediaFile.Save()
+	mediaFile.Media.Save()
+	return nil
+}
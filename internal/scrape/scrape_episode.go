@@ -89,6 +89,10 @@ func (t *tvShowScrapeImpl) Scrape(mediaFile *models.ScrapeMediaFile) error {
 	}
 	// 下载视频文件解析视频信息
 	t.FFprobe(mediaFile)
+	// 识别字幕语言，整理时按语言重命名
+	if mediaFile.ScrapeType != models.ScrapeTypeOnly {
+		t.ProcessSubtitles(mediaFile)
+	}
 	t.GenerateNewEpisodeName(mediaFile)
 	episodePath := mediaFile.GetTmpFullSeasonPath()
 	if !helpers.PathExists(episodePath) {
@@ -320,8 +324,9 @@ func (t *tvShowScrapeImpl) GenerateEpisodeNfo(mediaFile *models.ScrapeMediaFile)
 		episode.Actor = mediaFile.Media.Actors
 	}
 	episodePath := mediaFile.GetTmpFullSeasonPath()
-	episodeNfoFile := filepath.Join(episodePath, mediaFile.GetEpisodeNfoName())
-	err := helpers.WriteEpisodeNfo(episode, episodeNfoFile)
+	episodeNfoName := mediaFile.GetEpisodeNfoName()
+	episodeNfoFile := filepath.Join(episodePath, episodeNfoName)
+	err := helpers.WriteEpisodeNfo(episode, episodeNfoFile, t.scrapePath.NfoOptions(mediaFile, models.NfoTargetEpisode, mediaFile.GetDestFullSeasonPath(), episodeNfoName))
 	if err != nil {
 		helpers.AppLogger.Errorf("生成集的nfo文件失败，电视剧 %s 季 %d 集 %d 文件路径：%s 错误： %v", mediaFile.Name, mediaFile.SeasonNumber, mediaFile.EpisodeNumber, episodeNfoFile, err)
 		return err
@@ -4,13 +4,13 @@ import (
 	"Q115-STRM/internal/baidupan"
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
 	"Q115-STRM/internal/models"
 	"Q115-STRM/internal/notificationmanager"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/openlist"
-	"Q115-STRM/internal/syncstrm"
 	"Q115-STRM/internal/tmdb"
 	"Q115-STRM/internal/v115open"
-	ws "Q115-STRM/internal/websocket"
 	"context"
 	"errors"
 	"fmt"
@@ -25,94 +25,78 @@ type movieScrapeImpl struct {
 	ScrapeBase
 }
 
-func NewMovieScrapeImpl(scrapePath *models.ScrapePath, ctx context.Context, v115Client *v115open.OpenClient, openlistClient *openlist.Client, baiduPanClient *baidupan.Client) scrapeImpl {
+func NewMovieScrapeImpl(scrapePath *models.ScrapePath, ctx context.Context, v115Client *v115open.OpenClient, openlistClient *openlist.Client, baiduPanClient *baidupan.Client, open123Client *open123.Client) scrapeImpl {
 	tmdbImpl := NewTmdbMovieImpl(scrapePath, ctx)
+	providers := newMetadataProviders(scrapePath, ctx, metadata.KindMovie, tmdbImpl.Client)
 	return &movieScrapeImpl{
 		ScrapeBase: ScrapeBase{
 			scrapePath:     scrapePath,
 			ctx:            ctx,
-			identifyImpl:   NewIdMovieImpl(scrapePath, ctx, tmdbImpl),
+			identifyImpl:   NewIdMovieImpl(scrapePath, ctx, newProviderChainImpl(tmdbImpl, providers)),
 			tmdbClient:     tmdbImpl.Client,
+			providers:      providers,
 			categoryImpl:   NewCategoryMovieImpl(scrapePath),
-			renameImpl:     NewRenameMovieImpl(scrapePath, ctx, v115Client, openlistClient, baiduPanClient),
+			renameImpl:     NewRenameMovieImpl(scrapePath, ctx, v115Client, openlistClient, baiduPanClient, open123Client),
 			v115Client:     v115Client,
 			openlistClient: openlistClient,
 			baiduPanClient: baiduPanClient,
+			open123Client:  open123Client,
 		},
 	}
 }
 
 func (m *movieScrapeImpl) Start() error {
-	m.fileTasks = make(chan *models.ScrapeMediaFile, m.scrapePath.GetMaxThreads())
-	// 每次从数据库中查询maxthreads个任务加入队列，等待处理完成后继续下一次查询直到无法查询到数据
-	// 启动N个协程协程，由m.ctx控制是否取消
-	wg := &sync.WaitGroup{}
-	max := m.scrapePath.GetMaxThreads()
-	// 查询数据库中所有待刮削和待整理的记录总数来决定要启动的工作协程数量
-	total := models.GetScannedScrapeMediaFilesTotal(m.scrapePath.ID, m.scrapePath.MediaType)
-	if total == 0 {
-		helpers.AppLogger.Infof("没有待刮削和待整理的记录，无需启动刮削任务")
-		return nil
-	}
-	threads := min(max, int(total))
-	for i := 0; i < threads; i++ {
-		go m.scrapeWorker(i+1, wg)
+	// 先识别所有待刮削的电影，命名时才能知道同一部电影是否有多个版本
+	m.IdentifyAll()
+	return m.runFileTasks("电影", m.Process)
+}
+
+// 整理前识别所有待刮削电影的TMDB ID
+// 逐个文件识别和命名时，先命名的版本看不到后面的版本，同一部电影的多个版本会放到不同的文件夹
+func (m *movieScrapeImpl) IdentifyAll() {
+	if m.scrapePath.MediaType != models.MediaTypeMovie {
+		return
 	}
-mainloop:
+	threads := m.scrapePath.GetMaxThreads()
+	var lastId uint
 	for {
 		select {
 		case <-m.ctx.Done():
-			helpers.AppLogger.Infof("电影主循环检测到停止信号，退出")
-			break mainloop
+			return
 		default:
-			// 从数据库取数据
-			mediaFiles := models.GetScannedScrapeMediaFiles(m.scrapePath.ID, m.scrapePath.MediaType, m.scrapePath.GetMaxThreads()*2)
-			if len(mediaFiles) == 0 {
-				helpers.AppLogger.Infof("所有待刮削和待整理记录都已加入处理队列，关闭队列通道，等待执行完成")
-				close(m.fileTasks)
-				break mainloop
-			}
-			for _, mediaFile := range mediaFiles {
-				m.fileTasks <- mediaFile
-				wg.Add(1) // 加进去之后，计数+1
-				helpers.AppLogger.Infof("文件 %s 已加入刮削处理队列", mediaFile.VideoFilename)
-			}
-			wg.Wait()
 		}
+		mediaFiles := models.GetUnidentifiedMovieFiles(m.scrapePath.ID, lastId, threads)
+		if len(mediaFiles) == 0 {
+			return
+		}
+		wg := sync.WaitGroup{}
+		for _, mediaFile := range mediaFiles {
+			lastId = mediaFile.ID
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := m.Identify(mediaFile)
+				if errors.Is(err, models.ErrScrapeNeedsReview) {
+					return
+				}
+				if err != nil {
+					helpers.AppLogger.Errorf("识别电影 %s 失败: %v", mediaFile.VideoFilename, err)
+					mediaFile.Failed(err.Error())
+				}
+			}()
+		}
+		wg.Wait()
 	}
-	helpers.AppLogger.Infof("所有刮削整理任务都已完成，本次任务结束")
-	return nil
 }
 
-func (m *movieScrapeImpl) scrapeWorker(taskIndex int, wg *sync.WaitGroup) {
-mainloop:
-	for {
-		select {
-		case <-m.ctx.Done():
-			helpers.AppLogger.Infof("电影工作线程 %d 检测到停止信号，退出", taskIndex)
-			return
-		case mediaFile, ok := <-m.fileTasks:
-			if !ok {
-				helpers.AppLogger.Infof("刮削整理任务队列 %d 已关闭", taskIndex)
-				return
-			}
-			err := m.Process(mediaFile)
-			wg.Done() // 处理完成后，计数-1
-			if err != nil {
-				helpers.AppLogger.Errorf("任务队列 %d 刮削文件 %s 失败: %v", taskIndex, mediaFile.VideoFilename, err)
-			}
-			// 触发单个刮削项完成事件
-			ws.BroadcastEvent(ws.EventScraperItemComplete, map[string]any{
-				"item_id": mediaFile.ID,
-				"name":    mediaFile.VideoFilename,
-				"status":  string(mediaFile.Status),
-				"success": err == nil,
-			})
-			continue mainloop
-		case <-time.After(5 * time.Minute):
-			return // 5分钟没响应自动退出
+// 识别电影，开启了待确认队列时先提取视频信息，时长参与识别结果的可信度计算
+func (m *movieScrapeImpl) Identify(mediaFile *models.ScrapeMediaFile) error {
+	if m.scrapePath.ReviewThreshold > 0 && mediaFile.TmdbId == 0 {
+		if err := m.FFprobe(mediaFile); err != nil {
+			helpers.AppLogger.Errorf("提取视频信息失败, 文件名: %s, 错误: %v", mediaFile.VideoFilename, err)
 		}
 	}
+	return m.identifyImpl.Identify(mediaFile)
 }
 
 func (m *movieScrapeImpl) Process(mediaFile *models.ScrapeMediaFile) error {
@@ -126,6 +110,10 @@ func (m *movieScrapeImpl) Process(mediaFile *models.ScrapeMediaFile) error {
 	if mediaFile.Status == models.ScrapeMediaStatusScanned {
 		// 待刮削，启动刮削流程
 		err := m.Scrape(mediaFile)
+		if errors.Is(err, models.ErrScrapeNeedsReview) {
+			// 已放入待确认队列，确认后下次刮削时继续
+			return err
+		}
 		if err != nil {
 			mediaFile.Failed(err.Error())
 			return err
@@ -143,6 +131,8 @@ func (m *movieScrapeImpl) Process(mediaFile *models.ScrapeMediaFile) error {
 		}
 		mediaFile.Media.Status = models.MediaStatusRenamed
 		mediaFile.Media.Save()
+		// 附属视频要在删除来源文件夹之前移动
+		m.MoveExtras(mediaFile)
 	}
 	// 上传所有刮削好的元数据
 	if mediaFile.ScrapeType != models.ScrapeTypeOnlyRename {
@@ -164,15 +154,21 @@ func (m *movieScrapeImpl) Scrape(mediaFile *models.ScrapeMediaFile) error {
 	// 改为刮削中...
 	mediaFile.Scraping()
 	// 识别
-	if err := m.identifyImpl.Identify(mediaFile); err != nil {
+	if err := m.Identify(mediaFile); err != nil {
 		return err
 	}
 	if scrapeErr := m.ScrapeMovieMedia(mediaFile); scrapeErr != nil {
 		return scrapeErr
 	}
 	// 提取分辨率等信息
-	if err := m.FFprobe(mediaFile); err != nil {
-		helpers.AppLogger.Errorf("提取视频信息失败, 文件名: %s, 错误: %v", mediaFile.VideoFilename, err)
+	if mediaFile.VideoCodec == nil {
+		if err := m.FFprobe(mediaFile); err != nil {
+			helpers.AppLogger.Errorf("提取视频信息失败, 文件名: %s, 错误: %v", mediaFile.VideoFilename, err)
+		}
+	}
+	// 识别字幕语言，整理时按语言重命名
+	if mediaFile.ScrapeType != models.ScrapeTypeOnly {
+		m.ProcessSubtitles(mediaFile)
 	}
 	// 确定二级分类
 	if cerr := m.GenrateCategory(mediaFile); cerr != nil {
@@ -255,7 +251,14 @@ func (m *movieScrapeImpl) ScrapeMovieMedia(mediaFile *models.ScrapeMediaFile) er
 		}
 		tmdbInfo.ReleasesDate = releasesDate.Results
 	}
+	m.fillProviderDetails(tmdbInfo, []string{movieDetail.Title, movieDetail.OriginalTitle}, helpers.ParseYearFromDate(movieDetail.ReleaseDate), map[string]string{
+		metadata.ProviderTmdb: fmt.Sprintf("%d", movieDetail.ID),
+		metadata.ProviderImdb: movieDetail.ImdbID,
+	})
 	m.MakeMediaFromTMDB(mediaFile, tmdbInfo)
+	if movieDetail.BelongsToCollection != nil && mediaFile.ScrapeType != models.ScrapeTypeOnlyRename {
+		m.SyncCollection(movieDetail.BelongsToCollection)
+	}
 	return nil
 }
 
@@ -295,13 +298,23 @@ func (m *movieScrapeImpl) GenerateNewName(mediaFile *models.ScrapeMediaFile) {
 		mediaFile.Media.PathId = mediaFile.PathId
 		return
 	}
+	// 同一部电影有多个版本时放到同一个文件夹，文件名加上 " - 版本" 后缀
+	// Start时已经识别了本次所有待刮削的电影，先命名的版本也能知道后面还有其他版本
+	multiVersion := models.CountMovieVersions(mediaFile.ScrapePathId, mediaFile.TmdbId, mediaFile.ID) > 0
 	folderTemplate := m.scrapePath.FolderNameTemplate
-	if m.scrapePath.FolderNameTemplate == "" && remotePath == "" {
+	if m.scrapePath.FolderNameTemplate == "" && (remotePath == "" || multiVersion) {
 		folderTemplate = "{title} ({year})"
 	}
 	// 根据命名规则生成文件夹名称
 	if m.scrapePath.FolderNameTemplate == "" {
-		if remotePath == "" {
+		var version *models.ScrapeMediaFile
+		if multiVersion {
+			version = models.GetNamedMovieVersion(mediaFile.ScrapePathId, mediaFile.TmdbId, mediaFile.ID)
+		}
+		if version != nil {
+			// 之前整理的版本保留了原文件夹名称时，新版本也放到该文件夹
+			mediaFile.NewPathName = version.NewPathName
+		} else if remotePath == "" || multiVersion {
 			mediaFile.NewPathName = mediaFile.GenerateNameByTemplate(folderTemplate)
 		} else {
 			mediaFile.NewPathName = oldPathName
@@ -309,10 +322,19 @@ func (m *movieScrapeImpl) GenerateNewName(mediaFile *models.ScrapeMediaFile) {
 	} else {
 		mediaFile.NewPathName = mediaFile.GenerateNameByTemplate(m.scrapePath.FolderNameTemplate)
 	}
-	if m.scrapePath.FileNameTemplate == "" {
+	fileTemplate := m.scrapePath.FileNameTemplate
+	if fileTemplate == "" && multiVersion {
+		fileTemplate = "{title} ({year})"
+	}
+	if fileTemplate == "" {
 		mediaFile.NewVideoBaseName = baseName
 	} else {
-		mediaFile.NewVideoBaseName = mediaFile.GenerateNameByTemplate(m.scrapePath.FileNameTemplate) // 不含扩展名
+		mediaFile.NewVideoBaseName = mediaFile.GenerateNameByTemplate(fileTemplate) // 不含扩展名
+	}
+	if multiVersion && !strings.Contains(fileTemplate, "edition") {
+		if edition := mediaFile.GetEdition(); edition != "" {
+			mediaFile.NewVideoBaseName += " - " + helpers.CleanFileName(edition)
+		}
 	}
 	mediaFile.Media.Path = filepath.Join(mediaFile.DestPath, mediaFile.CategoryName, mediaFile.NewPathName)
 	mediaFile.Media.VideoFileName = mediaFile.NewVideoBaseName + mediaFile.VideoExt
@@ -321,57 +343,6 @@ func (m *movieScrapeImpl) GenerateNewName(mediaFile *models.ScrapeMediaFile) {
 	mediaFile.Media.Save()
 }
 
-// 先命中一个syncPath，使用newPath
-func (m *movieScrapeImpl) SyncFilesToSTRMPath(mediaFile *models.ScrapeMediaFile, files []uploadFile) {
-	syncPath := m.scrapePath.GetSyncPathByPath(mediaFile.Media.Path)
-	if syncPath == nil {
-		helpers.AppLogger.Errorf("未命中任何STRM同步目录, 无法将文件同步到STRM目录 %s", mediaFile.Media.Path)
-		return
-	}
-	// 先生成STRM文件
-	// 1. 构造STRM文件路径
-	syncStrm := syncstrm.NewSyncStrmFromSyncPath(syncPath)
-	strmErr := syncStrm.ProcessStrmFile(&syncstrm.SyncFileCache{
-		Path:          mediaFile.Media.Path,
-		ParentId:      mediaFile.Media.PathId,
-		FileType:      v115open.TypeFile,
-		FileName:      mediaFile.Media.VideoFileName,
-		FileId:        mediaFile.Media.VideoFileId,
-		PickCode:      mediaFile.Media.VideoPickCode,
-		OpenlistSign:  mediaFile.Media.VideoOpenListSign,
-		FileSize:      0,
-		MTime:         0,
-		IsVideo:       true,
-		IsMeta:        false,
-		LocalFilePath: filepath.Join(syncPath.LocalPath, mediaFile.Media.Path, mediaFile.NewVideoBaseName+".strm"),
-	})
-	if strmErr != nil {
-		helpers.AppLogger.Errorf("生成STRM文件失败, 失败原因: %v", strmErr)
-		return
-	}
-	models.DeleteSyncRecordById(syncStrm.Sync.ID)
-	if files == nil {
-		return
-	}
-	// 将其他文件放入STRM同步目录内
-	for _, file := range files {
-		destPath := filepath.Join(syncPath.LocalPath, file.DestPath)
-		if !helpers.PathExists(destPath) {
-			err := os.MkdirAll(destPath, 0755)
-			if err != nil {
-				helpers.AppLogger.Errorf("创建目录 %s 失败, 失败原因: %v", destPath, err)
-			}
-		}
-		destFile := filepath.Join(destPath, file.FileName)
-		// 复制过去
-		err := helpers.CopyFile(file.SourcePath, destFile)
-		if err != nil {
-			helpers.AppLogger.Errorf("复制文件 %s 到 %s 失败, 失败原因: %v", file.SourcePath, destFile, err)
-		}
-		helpers.AppLogger.Infof("复制文件 %s 到 %s 成功", file.SourcePath, destFile)
-	}
-}
-
 func (m *movieScrapeImpl) UploadMovieScrapeFile(mediaFile *models.ScrapeMediaFile) error {
 	if mediaFile.NewPathId == "" {
 		helpers.AppLogger.Errorf("父文件夹不存在，无法上传文件元数据 %s", mediaFile.NewPathName)
@@ -478,32 +449,44 @@ func (m *movieScrapeImpl) MoveLocalTempFileToDest(mediaFile *models.ScrapeMediaF
 	return true, nil
 }
 
-// 创建父文件夹，电影是电影目录
-func (m *movieScrapeImpl) MakeParentPath(mediaFile *models.ScrapeMediaFile, categoryMap map[uint]string) error {
-	if mediaFile.ScrapeType == models.ScrapeTypeOnly {
-		mediaFile.NewPathId = mediaFile.PathId
-		mediaFile.Save()
-		helpers.AppLogger.Infof("仅刮削模式下，使用旧目录存放元数据：%s，目录ID：%s", mediaFile.Path, mediaFile.PathId)
-		return nil
+// 将正片文件夹中的预告片、花絮等附属视频移动到正片新目录下Emby/Jellyfin约定的子目录
+// 只有移动模式才处理，链接和复制模式下附属视频保留在来源目录
+func (m *movieScrapeImpl) MoveExtras(mediaFile *models.ScrapeMediaFile) {
+	if mediaFile.RenameType != models.RenameTypeMove {
+		return
 	}
-	parentId := mediaFile.DestPathId
-	if mediaFile.ScrapePathCategoryId > 0 {
-		if category, ok := categoryMap[mediaFile.ScrapePathCategoryId]; ok {
-			parentId = category
-		}
+	extras := models.GetMovieExtras(mediaFile.ScrapePathId, mediaFile.Path)
+	if len(extras) == 0 {
+		return
 	}
 	destFullPath := mediaFile.GetDestFullMoviePath()
-	helpers.AppLogger.Infof("影视剧文件夹，目标路径：%s，根目录ID：%s", destFullPath, parentId)
-	newPathId, err := m.renameImpl.CheckAndMkDir(destFullPath, mediaFile.DestPath, mediaFile.DestPathId)
-	if err != nil {
-		helpers.AppLogger.Errorf("创建父文件夹失败: %v", err)
-		return err
+	pathIds := make(map[models.ExtraType]string)
+	for _, extra := range extras {
+		pathId, ok := pathIds[extra.ExtraType]
+		if !ok {
+			var err error
+			pathId, err = m.renameImpl.CheckAndMkDir(filepath.Join(destFullPath, string(extra.ExtraType)), mediaFile.DestPath, mediaFile.DestPathId)
+			if err != nil {
+				helpers.AppLogger.Errorf("创建附属视频目录 %s 失败: %v", extra.ExtraType, err)
+				continue
+			}
+			pathIds[extra.ExtraType] = pathId
+		}
+		if err := m.renameImpl.MoveFiles(models.MoveNewFileToSourceFile{
+			FileId:       extra.VideoFileId,
+			PathId:       pathId,
+			FileFullPath: filepath.Join(extra.Path, extra.VideoFilename),
+		}); err != nil {
+			helpers.AppLogger.Errorf("移动附属视频 %s 失败: %v", extra.VideoFilename, err)
+			continue
+		}
+		if extra.SourceType == models.SourceTypeLocal {
+			// 本地文件的ID就是路径，移动后跟着变化
+			extra.VideoFileId = filepath.Join(pathId, filepath.Base(extra.VideoFileId))
+		}
+		extra.ExtraRenamed(mediaFile, pathId)
+		helpers.AppLogger.Infof("已将附属视频 %s 移动到 %s", extra.VideoFilename, filepath.Join(destFullPath, string(extra.ExtraType)))
 	}
-	mediaFile.NewPathId = newPathId
-	mediaFile.Media.PathId = newPathId
-	mediaFile.Save()
-	mediaFile.Media.Save()
-	return nil
 }
 
 // 检查是否完成，不用管上传（上传负责删除自己产生的临时文件）
@@ -682,23 +665,12 @@ func (sm *movieScrapeImpl) GenerateMovieNfo(mediaFile *models.ScrapeMediaFile, l
 		Id:         mediaFile.Media.ImdbId,
 		TmdbId:     mediaFile.Media.TmdbId,
 		ImdbId:     mediaFile.Media.ImdbId,
-		Uniqueid: []helpers.UniqueId{
-			{
-				Id:      mediaFile.Media.ImdbId,
-				Type:    "imdb",
-				Default: true,
-			},
-			{
-				Id:      fmt.Sprintf("%d", mediaFile.Media.TmdbId),
-				Type:    "tmdb",
-				Default: false,
-			},
-		},
-		Genre:     genres,
-		Director:  mediaFile.Media.Director,
-		Premiered: mediaFile.Media.ReleaseDate,
-		Year:      mediaFile.Media.Year,
-		DateAdded: time.Now().Format("2006-01-02"),
+		Uniqueid:   mediaFile.Media.UniqueIds(sm.scrapePath.GetMetadataProvider()),
+		Genre:      genres,
+		Director:   mediaFile.Media.Director,
+		Premiered:  mediaFile.Media.ReleaseDate,
+		Year:       mediaFile.Media.Year,
+		DateAdded:  time.Now().Format("2006-01-02"),
 		FileInfo: struct {
 			StreamDetails struct {
 				Video    []helpers.StreamVideo    `xml:"video,omitempty"`
@@ -726,6 +698,14 @@ func (sm *movieScrapeImpl) GenerateMovieNfo(mediaFile *models.ScrapeMediaFile, l
 			},
 		},
 	}
+	// 所属合集，Emby/Jellyfin/Kodi根据<set>自动创建合集
+	if mediaFile.Media.CollectionId > 0 {
+		m.Set.Name = mediaFile.Media.CollectionName
+		if collection := models.GetMediaCollectionByTmdbId(mediaFile.Media.CollectionId); collection != nil {
+			m.Set.Name = collection.Name
+			m.Set.Overview = collection.Overview
+		}
+	}
 	if excludeNoImageActor {
 		m.Actor = make([]helpers.Actor, 0)
 		for _, actor := range mediaFile.Media.Actors {
@@ -736,7 +716,7 @@ func (sm *movieScrapeImpl) GenerateMovieNfo(mediaFile *models.ScrapeMediaFile, l
 	} else {
 		m.Actor = mediaFile.Media.Actors
 	}
-	err := helpers.WriteMovieNfo(m, nfoPath)
+	err := helpers.WriteMovieNfo(m, nfoPath, sm.scrapePath.NfoOptions(mediaFile, models.NfoTargetMovie, mediaFile.GetDestFullMoviePath(), nfoName))
 	if err != nil {
 		helpers.AppLogger.Errorf("生成电影nfo文件失败，文件路径：%s 错误： %v", nfoPath, err)
 		return err
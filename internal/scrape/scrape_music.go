@@ -0,0 +1,284 @@
+package scrape
+
+import (
+	"Q115-STRM/internal/audiotag"
+	"Q115-STRM/internal/baidupan"
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/open123"
+	"Q115-STRM/internal/openlist"
+	"Q115-STRM/internal/v115open"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 同一个专辑的多首歌可能同时完成，生成播放列表时需要加锁
+var musicPlaylistMutex sync.Mutex
+
+// 音乐刮削：从音频标签中读取艺术家、专辑和曲目，不查询TMDB，不生成nfo和图片
+// 工作协程池、整理和STRM同步使用ScrapeBase中和电影共用的流程
+type musicScrapeImpl struct {
+	ScrapeBase
+	musicProvider metadata.MusicProvider            // 为空时不查询MusicBrainz
+	releaseCache  map[string]*metadata.MusicRelease // 同一专辑只查询一次，key为艺术家和专辑
+	releaseMutex  sync.Mutex
+}
+
+func NewMusicScrapeImpl(scrapePath *models.ScrapePath, ctx context.Context, v115Client *v115open.OpenClient, openlistClient *openlist.Client, baiduPanClient *baidupan.Client, open123Client *open123.Client) scrapeImpl {
+	m := &musicScrapeImpl{
+		ScrapeBase: ScrapeBase{
+			scrapePath:     scrapePath,
+			ctx:            ctx,
+			renameImpl:     NewRenameMovieImpl(scrapePath, ctx, v115Client, openlistClient, baiduPanClient, open123Client),
+			v115Client:     v115Client,
+			openlistClient: openlistClient,
+			baiduPanClient: baiduPanClient,
+			open123Client:  open123Client,
+		},
+		releaseCache: make(map[string]*metadata.MusicRelease),
+	}
+	if scrapePath.EnableMusicBrainz {
+		m.musicProvider = metadata.NewMusicBrainzProvider(models.GlobalScrapeSettings.GetMetadataConfig())
+	}
+	return m
+}
+
+func (m *musicScrapeImpl) Start() error {
+	return m.runFileTasks("音乐", m.Process)
+}
+
+func (m *musicScrapeImpl) Process(mediaFile *models.ScrapeMediaFile) error {
+	mediaFile.ScrapeRootPath = filepath.Join(helpers.ConfigDir, "tmp", "刮削临时文件", fmt.Sprintf("%d", mediaFile.ScrapePathId), "音乐")
+	if mediaFile.Status == models.ScrapeMediaStatusScanned {
+		if err := m.Scrape(mediaFile); err != nil {
+			mediaFile.Failed(err.Error())
+			return err
+		}
+	}
+	mediaFile.Renaming()
+	if err := m.MakeParentPath(mediaFile, nil); err != nil {
+		mediaFile.RenameFailed(err.Error())
+		return err
+	}
+	if mediaFile.ScrapeType != models.ScrapeTypeOnly {
+		if err := m.renameImpl.RenameAndMove(mediaFile, "", "", ""); err != nil {
+			mediaFile.RenameFailed(err.Error())
+			return err
+		}
+		mediaFile.Media.Status = models.MediaStatusRenamed
+		mediaFile.Media.Save()
+	}
+	// 音乐没有需要上传的元数据，只生成STRM和专辑播放列表
+	m.SyncFilesToSTRMPath(mediaFile, nil)
+	m.WriteAlbumPlaylist(mediaFile)
+	m.FinishMusic(mediaFile)
+	return nil
+}
+
+func (m *musicScrapeImpl) Scrape(mediaFile *models.ScrapeMediaFile) error {
+	mediaFile.Scraping()
+	tags, err := m.ReadTags(mediaFile)
+	if err != nil {
+		helpers.AppLogger.Warnf("读取音频文件 %s 的标签失败，使用文件名和文件夹整理: %v", mediaFile.VideoFilename, err)
+	}
+	media := mediaFile.MakeMusicMedia(tags)
+	m.LookupMusicBrainz(media)
+	// 重新刮削时更新原来的记录
+	media.ID = mediaFile.MediaId
+	if err := media.Save(); err != nil {
+		return err
+	}
+	mediaFile.Media = media
+	mediaFile.MediaId = media.ID
+	mediaFile.Name = media.Name
+	mediaFile.Year = media.Year
+	mediaFile.Save()
+	helpers.AppLogger.Infof("音乐 %s 识别结果：艺术家 %s，专辑 %s (%d)，曲目 %d，标题 %s", mediaFile.VideoFilename, media.Artist, media.Album, media.Year, media.TrackNumber, media.Name)
+	m.GenerateNewName(mediaFile)
+	mediaFile.ScrapeFinish()
+	return nil
+}
+
+// 读取音频标签，本地文件直接读取，网盘文件通过下载链接分段读取文件头
+func (m *musicScrapeImpl) ReadTags(mediaFile *models.ScrapeMediaFile) (*audiotag.Tags, error) {
+	if mediaFile.SourceType == models.SourceTypeLocal {
+		return audiotag.ReadFile(mediaFile.VideoPickCode)
+	}
+	url := m.GetDownloadUrl(mediaFile)
+	if !strings.HasPrefix(url, "http") {
+		return nil, fmt.Errorf("无法获取下载链接")
+	}
+	return audiotag.ReadUrl(url, m.GetDownloadUA(mediaFile))
+}
+
+// 从MusicBrainz补全专辑ID、年份和曲目ID，查询失败不影响整理
+func (m *musicScrapeImpl) LookupMusicBrainz(media *models.Media) {
+	if m.musicProvider == nil || media.Album == models.UnknownMusicAlbum {
+		return
+	}
+	if media.MusicBrainzAlbumId == "" || media.Year == 0 {
+		if release := m.searchRelease(media.Artist, media.Album); release != nil {
+			if media.MusicBrainzAlbumId == "" {
+				media.MusicBrainzAlbumId = release.Id
+			}
+			if media.Year == 0 {
+				media.Year = release.Year
+			}
+			if media.Artist == models.UnknownMusicArtist && release.Artist != "" {
+				media.Artist = release.Artist
+			}
+		}
+	}
+	if media.MusicBrainzTrackId == "" && media.OriginalName != "" {
+		recording, err := m.musicProvider.SearchRecording(m.ctx, media.Artist, media.OriginalName, media.MusicBrainzAlbumId)
+		if err != nil {
+			helpers.AppLogger.Warnf("从MusicBrainz查询曲目 %s - %s 失败: %v", media.Artist, media.OriginalName, err)
+			return
+		}
+		media.MusicBrainzTrackId = recording.Id
+	}
+}
+
+func (m *musicScrapeImpl) searchRelease(artist, album string) *metadata.MusicRelease {
+	m.releaseMutex.Lock()
+	defer m.releaseMutex.Unlock()
+	key := artist + "\x00" + album
+	if release, ok := m.releaseCache[key]; ok {
+		return release
+	}
+	release, err := m.musicProvider.SearchRelease(m.ctx, artist, album)
+	if err != nil {
+		helpers.AppLogger.Warnf("从MusicBrainz查询专辑 %s - %s 失败: %v", artist, album, err)
+	}
+	// 查询失败也缓存，同一专辑的其他曲目不再重复查询
+	m.releaseCache[key] = release
+	return release
+}
+
+// 生成新的文件夹和文件名，默认为 艺术家/专辑 (年份)/曲目 - 标题
+func (m *musicScrapeImpl) GenerateNewName(mediaFile *models.ScrapeMediaFile) {
+	mediaFile.VideoExt = filepath.Ext(mediaFile.VideoFilename)
+	if mediaFile.ScrapeType == models.ScrapeTypeOnly {
+		mediaFile.NewPathName = filepath.Base(mediaFile.Path)
+		mediaFile.NewVideoBaseName = strings.TrimSuffix(mediaFile.VideoFilename, mediaFile.VideoExt)
+		mediaFile.Media.Path = mediaFile.Path
+		mediaFile.Media.PathId = mediaFile.PathId
+		mediaFile.Media.VideoFileName = mediaFile.VideoFilename
+	} else {
+		folderTemplate := m.scrapePath.FolderNameTemplate
+		if folderTemplate == "" {
+			folderTemplate = models.DefaultMusicFolderTemplate
+			if mediaFile.Media.Year == 0 {
+				folderTemplate = "{artist}/{album}"
+			}
+		}
+		fileTemplate := m.scrapePath.FileNameTemplate
+		if fileTemplate == "" {
+			fileTemplate = models.DefaultMusicFileTemplate
+			if mediaFile.Media.TrackNumber == 0 {
+				fileTemplate = "{title}"
+			}
+		}
+		mediaFile.NewPathName = mediaFile.GenerateNameByTemplate(folderTemplate)
+		mediaFile.NewVideoBaseName = mediaFile.GenerateNameByTemplate(fileTemplate)
+		mediaFile.Media.Path = filepath.Join(mediaFile.DestPath, mediaFile.NewPathName)
+		mediaFile.Media.VideoFileName = mediaFile.NewVideoBaseName + mediaFile.VideoExt
+	}
+	mediaFile.Save()
+	mediaFile.Media.Save()
+}
+
+// 在STRM同步目录的专辑文件夹中生成播放列表，包含文件夹中所有的STRM文件
+func (m *musicScrapeImpl) WriteAlbumPlaylist(mediaFile *models.ScrapeMediaFile) {
+	syncPath := m.scrapePath.GetSyncPathByPath(mediaFile.Media.Path)
+	if syncPath == nil {
+		return
+	}
+	albumPath := filepath.Join(syncPath.LocalPath, mediaFile.Media.Path)
+	musicPlaylistMutex.Lock()
+	defer musicPlaylistMutex.Unlock()
+	entries, err := os.ReadDir(albumPath)
+	if err != nil {
+		helpers.AppLogger.Errorf("读取专辑目录 %s 失败: %v", albumPath, err)
+		return
+	}
+	tracks := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".strm" {
+			tracks = append(tracks, entry.Name())
+		}
+	}
+	if len(tracks) == 0 {
+		return
+	}
+	slices.Sort(tracks)
+	name := models.CleanMusicName(mediaFile.Media.Album)
+	if mediaFile.ScrapeType == models.ScrapeTypeOnly {
+		name = filepath.Base(albumPath)
+	}
+	playlistPath := filepath.Join(albumPath, name+".m3u")
+	if err := os.WriteFile(playlistPath, []byte(models.MakeM3uPlaylist(tracks)), 0644); err != nil {
+		helpers.AppLogger.Errorf("生成专辑播放列表 %s 失败: %v", playlistPath, err)
+		return
+	}
+	helpers.AppLogger.Infof("生成专辑播放列表 %s 成功，共 %d 首", playlistPath, len(tracks))
+}
+
+// 音乐数量多，完成时不发送通知
+func (m *musicScrapeImpl) FinishMusic(mediaFile *models.ScrapeMediaFile) {
+	mediaFile.StatusFinish()
+	if mediaFile.SourceType == models.SourceTypeLocal {
+		mediaFile.RemoveTmpFiles(nil)
+	}
+	if mediaFile.ScrapeType == models.ScrapeTypeOnly || mediaFile.RenameType != models.RenameTypeMove || mediaFile.IsReScrape {
+		return
+	}
+	// 来源目录中还有其他音频文件时不会删除
+	if err := m.renameImpl.RemoveMediaSourcePath(mediaFile, m.scrapePath); err != nil {
+		helpers.AppLogger.Errorf("删除来源路径 %s 失败: %v", mediaFile.PathId, err)
+	}
+}
+
+// 音乐的重新刮削：移动模式把文件移回来源目录并恢复原文件名，其他模式删除整理后的文件
+// 专辑目录中可能还有其他曲目，不删除目录
+func (m *musicScrapeImpl) Rollback(mediaFile *models.ScrapeMediaFile) error {
+	mediaFile.QueryRelation()
+	if mediaFile.Media != nil && mediaFile.ScrapeType != models.ScrapeTypeOnly && mediaFile.Media.VideoFileId != "" {
+		if mediaFile.RenameType == models.RenameTypeMove {
+			pathId, err := m.renameImpl.CheckAndMkDir(mediaFile.Path, mediaFile.SourcePath, mediaFile.SourcePathId)
+			if err != nil {
+				helpers.AppLogger.Errorf("创建来源文件夹 %s 失败: %v", mediaFile.Path, err)
+				return err
+			}
+			if err := m.renameImpl.MoveFiles(models.MoveNewFileToSourceFile{
+				FileId:       mediaFile.Media.VideoFileId,
+				FileFullPath: filepath.Join(mediaFile.Path, mediaFile.Media.VideoFileName),
+				PathId:       pathId,
+			}); err != nil {
+				helpers.AppLogger.Errorf("移动音频文件回来源目录失败: %v", err)
+				return err
+			}
+			fileId := mediaFile.Media.VideoFileId
+			if mediaFile.SourceType != models.SourceType115 {
+				fileId = strings.Replace(fileId, mediaFile.Media.PathId, pathId, 1)
+			}
+			m.renameImpl.Rename(fileId, mediaFile.VideoFilename)
+		} else {
+			files := []models.WillDeleteFile{{FullFilePath: filepath.Join(mediaFile.Media.Path, mediaFile.Media.VideoFileName)}}
+			if err := m.renameImpl.CheckAndDeleteFiles(mediaFile, files); err != nil {
+				helpers.AppLogger.Errorf("删除整理后的音频文件失败: %v", err)
+				return err
+			}
+		}
+	}
+	db.Db.Delete(&models.Media{}, mediaFile.MediaId)
+	db.Db.Delete(&models.ScrapeMediaFile{}, mediaFile.ID)
+	return nil
+}
@@ -231,7 +231,7 @@ func (sm *tvShowScrapeImpl) GenerateSeasonNfo(mediaFile *models.ScrapeMediaFile)
 	seasonPath := mediaFile.GetTmpFullSeasonPath()
 	seasonFileName := mediaFile.GetSeasonNfoName()
 	seasonNfoFile := filepath.Join(seasonPath, seasonFileName)
-	err := helpers.WriteSeasonNfo(season, seasonNfoFile)
+	err := helpers.WriteSeasonNfo(season, seasonNfoFile, sm.scrapePath.NfoOptions(mediaFile, models.NfoTargetSeason, mediaFile.GetDestFullSeasonPath(), seasonFileName))
 	if err != nil {
 		helpers.AppLogger.Errorf("生成电视剧 %s 季 %d 的nfo文件失败: %v", mediaFile.Media.Name, mediaFile.MediaSeason.SeasonNumber, err)
 		return err
@@ -4,7 +4,9 @@ import (
 	"Q115-STRM/internal/baidupan"
 	"Q115-STRM/internal/db"
 	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
 	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/open123"
 	"Q115-STRM/internal/openlist"
 	"Q115-STRM/internal/tmdb"
 	"Q115-STRM/internal/v115open"
@@ -29,18 +31,21 @@ type tvshowTask struct {
 	seasons   []uint
 }
 
-func NewTvShowScrapeImpl(scrapePath *models.ScrapePath, ctx context.Context, v115Client *v115open.OpenClient, openlistClient *openlist.Client, baiduPanClient *baidupan.Client) scrapeImpl {
+func NewTvShowScrapeImpl(scrapePath *models.ScrapePath, ctx context.Context, v115Client *v115open.OpenClient, openlistClient *openlist.Client, baiduPanClient *baidupan.Client, open123Client *open123.Client) scrapeImpl {
 	tmdbImpl := NewTmdbTvShowImpl(scrapePath, ctx)
+	providers := newMetadataProviders(scrapePath, ctx, metadata.KindTvShow, tmdbImpl.Client)
 	return &tvShowScrapeImpl{
 		ScrapeBase: ScrapeBase{
 			scrapePath:     scrapePath,
 			ctx:            ctx,
-			identifyImpl:   NewIdTvShowImpl(scrapePath, ctx, tmdbImpl),
+			identifyImpl:   NewIdTvShowImpl(scrapePath, ctx, newProviderChainImpl(tmdbImpl, providers)),
 			categoryImpl:   NewCategoryTvShowImpl(scrapePath),
-			renameImpl:     NewRenameTvShowImpl(scrapePath, ctx, v115Client, openlistClient, baiduPanClient),
+			renameImpl:     NewRenameTvShowImpl(scrapePath, ctx, v115Client, openlistClient, baiduPanClient, open123Client),
 			tmdbClient:     tmdbImpl.Client,
+			providers:      providers,
 			v115Client:     v115Client,
 			baiduPanClient: baiduPanClient,
+			open123Client:  open123Client,
 			openlistClient: openlistClient,
 		},
 	}
@@ -221,6 +226,10 @@ func (t *tvShowScrapeImpl) ProcessTvShow(tt *tvshowTask) error {
 		t.FillTvshowPath(mediaFile)
 		// 待刮削，启动刮削流程
 		err := t.ScrapeTvshow(mediaFile)
+		if errors.Is(err, models.ErrScrapeNeedsReview) {
+			// 电视剧下所有集已放入待确认队列
+			return err
+		}
 		if err != nil {
 			// 将电视剧下所有集标记为失败
 			t.ScrapeFailedAllEdpisode(mediaFile, err.Error())
@@ -229,6 +238,13 @@ func (t *tvShowScrapeImpl) ProcessTvShow(tt *tvshowTask) error {
 		// 更新电视剧下的所有集的数据
 		t.UpdateTvshowDataToAllEpisode(mediaFile)
 	}
+	// 动画模式下识别出电视剧后才能把绝对集数换算成季和集
+	if t.scrapePath.IsAnimeMode() && mediaFile.TmdbId > 0 {
+		if err := t.MapAnimeEpisodes(tt); err != nil {
+			helpers.AppLogger.Errorf("电视剧 %s 换算绝对集数失败: %v", mediaFile.Name, err)
+			return err
+		}
+	}
 	if mediaFile.Media != nil && mediaFile.Media.Status == models.MediaStatusScraped {
 		// 如果已刮削则整理
 		// 整理电视剧
@@ -343,6 +359,17 @@ func (t *tvShowScrapeImpl) ScrapeTvshowMedia(mediaFile *models.ScrapeMediaFile)
 			})
 		}
 	}
+	// 查询其他元数据提供者，用TMDB的外部ID帮助匹配
+	if t.providers.enabled() {
+		ids := map[string]string{metadata.ProviderTmdb: fmt.Sprintf("%d", tvDetail.ID)}
+		if externalIds, err := t.tmdbClient.GetTvExternalIds(tvDetail.ID); err == nil {
+			ids[metadata.ProviderImdb] = externalIds.ImdbID
+			if externalIds.TvdbID > 0 {
+				ids[metadata.ProviderTvdb] = fmt.Sprintf("%d", externalIds.TvdbID)
+			}
+		}
+		t.fillProviderDetails(tmdbInfo, []string{tvDetail.Name, tvDetail.OriginalName}, helpers.ParseYearFromDate(tvDetail.FirstAirDate), ids)
+	}
 	// 使用tmdbinfo补全media的信息
 	t.MakeMediaFromTMDB(mediaFile, tmdbInfo)
 	return nil
@@ -539,18 +566,7 @@ func (t *tvShowScrapeImpl) GenerateTvShowNfo(mediaFile *models.ScrapeMediaFile,
 		ImdbId:    mediaFile.Media.ImdbId,
 		Premiered: mediaFile.Media.ReleaseDate,
 		Aired:     mediaFile.Media.ReleaseDate,
-		Uniqueid: []helpers.UniqueId{
-			{
-				Id:      mediaFile.Media.ImdbId,
-				Type:    "imdb",
-				Default: true,
-			},
-			{
-				Id:      fmt.Sprintf("%d", mediaFile.TmdbId),
-				Type:    "tmdb",
-				Default: false,
-			},
-		},
+		Uniqueid:  mediaFile.Media.UniqueIds(t.scrapePath.GetMetadataProvider()),
 	}
 	if excludeNoImageActor {
 		tv.Actor = make([]helpers.Actor, 0)
@@ -562,7 +578,7 @@ func (t *tvShowScrapeImpl) GenerateTvShowNfo(mediaFile *models.ScrapeMediaFile,
 	} else {
 		tv.Actor = mediaFile.Media.Actors
 	}
-	err := helpers.WriteTVShowNfo(tv, nfoPath)
+	err := helpers.WriteTVShowNfo(tv, nfoPath, t.scrapePath.NfoOptions(mediaFile, models.NfoTargetTvShow, mediaFile.GetDestFullTvshowPath(), "tvshow.nfo"))
 	if err != nil {
 		helpers.AppLogger.Errorf("生成电视剧nfo文件失败，文件路径：%s 错误： %v", nfoPath, err)
 		return err
@@ -0,0 +1,91 @@
+package scrape
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// 可以抽样内容识别语言的字幕格式
+var textSubtitleExts = []string{".srt", ".ass", ".ssa", ".vtt"}
+
+// 字幕阶段：识别外挂字幕的语言、强制和听障标记，开启后把内封的文本字幕提取为外挂字幕
+// 整理时按 <视频文件名>.<语言>[.forced][.sdh].ext 重命名，Emby/Jellyfin才能显示字幕语言
+func (s *ScrapeBase) ProcessSubtitles(mediaFile *models.ScrapeMediaFile) {
+	if s.scrapePath.ExtractSubtitles && mediaFile.SourceType == models.SourceTypeLocal {
+		s.extractEmbeddedSubtitles(mediaFile)
+	}
+	if len(mediaFile.SubtitleFiles) == 0 {
+		return
+	}
+	baseName := strings.TrimSuffix(mediaFile.VideoFilename, filepath.Ext(mediaFile.VideoFilename))
+	usedNames := make(map[string]bool)
+	for _, sub := range mediaFile.SubtitleFiles {
+		ext := strings.ToLower(filepath.Ext(sub.FileName))
+		if sub.Language == "" {
+			info := helpers.ParseSubtitleFileName(baseName, sub.FileName)
+			if info.Language == "" && slices.Contains(textSubtitleExts, ext) {
+				// 文件名中没有语言标记，抽样内容识别
+				content, err := s.renameImpl.ReadFileContent(sub.PickCode)
+				if err != nil {
+					helpers.AppLogger.Errorf("读取字幕文件 %s 失败: %v", sub.FileName, err)
+				} else {
+					info.Language = helpers.DetectSubtitleLanguage(content)
+				}
+			}
+			sub.Language, sub.Forced, sub.SDH = info.Language, info.Forced, info.SDH
+		}
+		if sub.Language == "" {
+			helpers.AppLogger.Infof("无法识别字幕文件 %s 的语言，保留原文件名", sub.FileName)
+			continue
+		}
+		// 同一种语言有多个字幕时只重命名第一个，其他保留原文件名，避免整理后重名
+		newName := helpers.SubtitleFileName("", helpers.SubtitleInfo{Language: sub.Language, Forced: sub.Forced, SDH: sub.SDH}, ext)
+		if usedNames[newName] {
+			helpers.AppLogger.Infof("字幕文件 %s 的语言 %s 与其他字幕重复，保留原文件名", sub.FileName, sub.Language)
+			sub.Language = ""
+			continue
+		}
+		usedNames[newName] = true
+		helpers.AppLogger.Infof("字幕文件 %s 的语言为 %s", sub.FileName, sub.Language)
+	}
+	mediaFile.SubtitleFileJson = helpers.JsonString(mediaFile.SubtitleFiles)
+}
+
+// 用ffmpeg把内封的文本字幕流提取到视频所在目录，图形字幕不处理
+func (s *ScrapeBase) extractEmbeddedSubtitles(mediaFile *models.ScrapeMediaFile) {
+	videoPath := mediaFile.VideoFileId
+	baseName := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	for _, stream := range mediaFile.SubtitleCodec {
+		ext := helpers.TextSubtitleExt(stream.Codec)
+		if ext == "" {
+			continue
+		}
+		info := helpers.SubtitleInfo{Language: helpers.NormalizeLanguageCode(stream.Language), Forced: stream.Forced == "1"}
+		outPath := fmt.Sprintf("%s.track%d%s", baseName, stream.StreamIndex, ext)
+		if info.Language != "" {
+			outPath = helpers.SubtitleFileName(baseName, info, ext)
+		}
+		fileName := filepath.Base(outPath)
+		if slices.ContainsFunc(mediaFile.SubtitleFiles, func(sub *models.MediaMetaFiles) bool { return sub.FileName == fileName }) {
+			continue
+		}
+		if !helpers.PathExists(outPath) {
+			if err := helpers.ExtractSubtitleStream(videoPath, stream.StreamIndex, stream.Codec, outPath); err != nil {
+				helpers.AppLogger.Errorf("提取 %s 的内封字幕流 %d 失败: %v", mediaFile.VideoFilename, stream.StreamIndex, err)
+				continue
+			}
+			helpers.AppLogger.Infof("已提取 %s 的内封字幕流 %d 到 %s", mediaFile.VideoFilename, stream.StreamIndex, fileName)
+		}
+		mediaFile.SubtitleFiles = append(mediaFile.SubtitleFiles, &models.MediaMetaFiles{
+			FileName: fileName,
+			FileId:   outPath,
+			PickCode: outPath,
+			Language: info.Language,
+			Forced:   info.Forced,
+		})
+	}
+}
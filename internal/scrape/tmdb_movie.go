@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -72,3 +73,51 @@ func (t *TmdbMovieImpl) CheckByTmdbId(tmdbId int64) (string, int, error) {
 func (t *TmdbMovieImpl) CheckSeasonByTmdbId(tmdbId int64, seasonNumber int) (*tmdb.SeasonDetail, error) {
 	return nil, nil
 }
+
+// 查询候选电影，年份附近的结果也会返回，用于计算识别结果的可信度
+// withRuntime为true时查询前几部电影的详情补充时长
+func (t *TmdbMovieImpl) SearchCandidates(name string, year int, withRuntime bool) ([]*models.MatchCandidate, error) {
+	candidates := make([]*models.MatchCandidate, 0)
+	years := []int{year}
+	if year > 0 {
+		// 不限年份再查一次，包含上映日期不一致的结果
+		years = append(years, 0)
+	}
+	for _, y := range years {
+		movieSearch, err := t.Client.SearchMovie(name, y, models.GlobalScrapeSettings.GetTmdbLanguage(), true, true)
+		if err != nil {
+			helpers.AppLogger.Errorf("查询tmdb候选电影失败, 名称 %s, 年份 %d, 失败原因: %v", name, y, err)
+			return nil, err
+		}
+		for _, r := range movieSearch.Results {
+			if len(candidates) >= reviewSearchLimit {
+				break
+			}
+			if slices.ContainsFunc(candidates, func(c *models.MatchCandidate) bool { return c.TmdbId == r.ID }) {
+				continue
+			}
+			candidates = append(candidates, &models.MatchCandidate{
+				TmdbId:       r.ID,
+				Name:         r.Title,
+				OriginalName: r.OriginalTitle,
+				Year:         helpers.ParseYearFromDate(r.ReleaseDate),
+				Overview:     r.Overview,
+				PosterPath:   r.PosterPath,
+			})
+		}
+	}
+	if withRuntime {
+		for index, c := range candidates {
+			if index >= reviewRuntimeLimit {
+				break
+			}
+			movieDetail, err := t.Client.GetMovieDetail(c.TmdbId, models.GlobalScrapeSettings.GetTmdbLanguage())
+			if err != nil {
+				helpers.AppLogger.Warnf("查询tmdb电影 %d 的时长失败: %v", c.TmdbId, err)
+				continue
+			}
+			c.Runtime = movieDetail.Runtime
+		}
+	}
+	return candidates, nil
+}
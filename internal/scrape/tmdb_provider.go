@@ -0,0 +1,165 @@
+package scrape
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/metadata"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/tmdb"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// 刮削目录配置的元数据提供者
+// 刮削流程仍然以TMDB ID为主键，其他提供者用来识别和补充元数据
+type metadataProviders struct {
+	ctx       context.Context
+	kind      metadata.MediaKind
+	client    *tmdb.Client
+	names     []string                     // 主提供者和后备提供者，按顺序，包含TMDB
+	providers map[string]metadata.Provider // 除TMDB以外的提供者
+}
+
+func newMetadataProviders(scrapePath *models.ScrapePath, ctx context.Context, kind metadata.MediaKind, client *tmdb.Client) *metadataProviders {
+	p := &metadataProviders{
+		ctx:       ctx,
+		kind:      kind,
+		client:    client,
+		providers: make(map[string]metadata.Provider),
+	}
+	cfg := models.GlobalScrapeSettings.GetMetadataConfig()
+	for _, name := range append([]string{scrapePath.GetMetadataProvider()}, scrapePath.GetFallbackProviders()...) {
+		if name == metadata.ProviderTmdb {
+			p.names = append(p.names, name)
+			continue
+		}
+		provider, err := metadata.New(name, cfg)
+		if err != nil {
+			helpers.AppLogger.Warnf("刮削目录 %s 的元数据提供者无效: %v", scrapePath.SourcePath, err)
+			continue
+		}
+		p.names = append(p.names, name)
+		p.providers[name] = provider
+	}
+	// 识别结果要转换成TMDB ID，TMDB始终作为最后的后备
+	if !slices.Contains(p.names, metadata.ProviderTmdb) {
+		p.names = append(p.names, metadata.ProviderTmdb)
+	}
+	return p
+}
+
+// 是否配置了TMDB以外的提供者
+func (p *metadataProviders) enabled() bool {
+	return p != nil && len(p.providers) > 0
+}
+
+// 查询其他提供者中和TMDB条目对应的详情，按提供者顺序返回
+// titles为TMDB的标题和原始标题，ids为已知的ID，匹配到的ID会用于后面的提供者
+func (p *metadataProviders) details(titles []string, year int, ids map[string]string) []*metadata.Detail {
+	if !p.enabled() {
+		return nil
+	}
+	known := make(map[string]string, len(ids))
+	for k, v := range ids {
+		if v != "" {
+			known[k] = v
+		}
+	}
+	details := make([]*metadata.Detail, 0, len(p.providers))
+	for _, name := range p.names {
+		provider, ok := p.providers[name]
+		if !ok {
+			continue
+		}
+		detail, err := metadata.Match(p.ctx, provider, p.kind, metadata.Query{Titles: titles, Year: year, Ids: known})
+		if err != nil {
+			helpers.AppLogger.Warnf("从 %s 查询 %v (%d) 的元数据失败: %v", name, titles, year, err)
+			continue
+		}
+		// 提供者返回了TMDB ID但是和当前条目不同，说明匹配错了
+		if tmdbId := known[metadata.ProviderTmdb]; tmdbId != "" && detail.Ids[metadata.ProviderTmdb] != "" && detail.Ids[metadata.ProviderTmdb] != tmdbId {
+			helpers.AppLogger.Warnf("从 %s 查询到的条目 %s 的TMDB ID %s 和 %s 不一致，忽略", name, detail.Id, detail.Ids[metadata.ProviderTmdb], tmdbId)
+			continue
+		}
+		for k, v := range detail.Ids {
+			if _, ok := known[k]; !ok {
+				known[k] = v
+			}
+		}
+		details = append(details, detail)
+	}
+	return details
+}
+
+// 先用主提供者识别，识别不到时按顺序使用后备提供者，最终都转换成TMDB ID
+// 按TMDB ID查询和查询季仍然直接使用TMDB
+type providerChainImpl struct {
+	TmdbImpl
+	providers *metadataProviders
+}
+
+// 没有配置其他提供者时直接返回TMDB实现
+func newProviderChainImpl(tmdbImpl TmdbImpl, providers *metadataProviders) TmdbImpl {
+	if !providers.enabled() {
+		return tmdbImpl
+	}
+	return &providerChainImpl{
+		TmdbImpl:  tmdbImpl,
+		providers: providers,
+	}
+}
+
+func (c *providerChainImpl) CheckByNameAndYear(name string, year int, switchYear bool) (string, int64, int, error) {
+	var lastErr error
+	for _, providerName := range c.providers.names {
+		if providerName == metadata.ProviderTmdb {
+			title, tmdbId, tmdbYear, err := c.TmdbImpl.CheckByNameAndYear(name, year, switchYear)
+			if err == nil {
+				return title, tmdbId, tmdbYear, nil
+			}
+			lastErr = err
+			continue
+		}
+		detail, err := metadata.Match(c.providers.ctx, c.providers.providers[providerName], c.providers.kind, metadata.Query{Titles: []string{name}, Year: year})
+		if err != nil {
+			helpers.AppLogger.Infof("通过 %s 识别 %s (%d) 失败: %v", providerName, name, year, err)
+			if lastErr == nil {
+				lastErr = err
+			}
+			continue
+		}
+		tmdbId := c.resolveTmdbId(detail, switchYear)
+		if tmdbId == 0 {
+			helpers.AppLogger.Infof("%s 的条目 %s %s 无法对应到TMDB", providerName, detail.Id, detail.Title)
+			lastErr = fmt.Errorf("%s 的条目 %s 无法对应到TMDB", providerName, detail.Id)
+			continue
+		}
+		title, tmdbYear, err := c.TmdbImpl.CheckByTmdbId(tmdbId)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		helpers.AppLogger.Infof("通过 %s 识别 %s (%d) 成功: %s %s => tmdbId %d", providerName, name, year, providerName, detail.Id, tmdbId)
+		return title, tmdbId, tmdbYear, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("tmdb没有数据")
+	}
+	return "", 0, 0, lastErr
+}
+
+// 先用外部ID查找TMDB ID，没有外部ID时用提供者的原始标题和标题在TMDB中搜索
+func (c *providerChainImpl) resolveTmdbId(detail *metadata.Detail, switchYear bool) int64 {
+	if tmdbId := metadata.FindTmdbId(c.providers.client, c.providers.kind, detail, models.GlobalScrapeSettings.GetTmdbLanguage()); tmdbId > 0 {
+		return tmdbId
+	}
+	for _, title := range []string{detail.OriginalTitle, detail.Title} {
+		if title == "" {
+			continue
+		}
+		if _, tmdbId, _, err := c.TmdbImpl.CheckByNameAndYear(title, detail.Year, switchYear); err == nil {
+			return tmdbId
+		}
+	}
+	return 0
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 )
 
 // 从tmdb刮削元数据
@@ -70,3 +71,38 @@ func (t *TmdbTvShowImpl) CheckSeasonByTmdbId(tmdbId int64, seasonNumber int) (*t
 	}
 	return seasonDetail, nil
 }
+
+// 查询候选电视剧，年份附近的结果也会返回，用于计算识别结果的可信度
+// 每集时长差别很大，电视剧不使用时长计算可信度
+func (t *TmdbTvShowImpl) SearchCandidates(name string, year int, withRuntime bool) ([]*models.MatchCandidate, error) {
+	candidates := make([]*models.MatchCandidate, 0)
+	years := []int{year}
+	if year > 0 {
+		// 不限年份再查一次，包含首播年份和季年份不一致的结果
+		years = append(years, 0)
+	}
+	for _, y := range years {
+		tvSearch, err := t.Client.SearchTv(name, y, models.GlobalScrapeSettings.GetTmdbLanguage(), true)
+		if err != nil {
+			helpers.AppLogger.Errorf("查询tmdb候选电视剧失败, 名称 %s, 年份 %d, 失败原因: %v", name, y, err)
+			return nil, err
+		}
+		for _, r := range tvSearch.Results {
+			if len(candidates) >= reviewSearchLimit {
+				break
+			}
+			if slices.ContainsFunc(candidates, func(c *models.MatchCandidate) bool { return c.TmdbId == r.ID }) {
+				continue
+			}
+			candidates = append(candidates, &models.MatchCandidate{
+				TmdbId:       r.ID,
+				Name:         r.Name,
+				OriginalName: r.OriginalName,
+				Year:         helpers.ParseYearFromDate(r.FirstAirDate),
+				Overview:     r.Overview,
+				PosterPath:   r.PosterPath,
+			})
+		}
+	}
+	return candidates, nil
+}
@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 )
@@ -16,8 +17,9 @@ import (
 type SyncTaskType string
 
 const (
-	SyncTaskTypeStrm   SyncTaskType = "STRM同步"
-	SyncTaskTypeScrape SyncTaskType = "刮削整理"
+	SyncTaskTypeStrm    SyncTaskType = "STRM同步"
+	SyncTaskTypeScrape  SyncTaskType = "刮削整理"
+	SyncTaskTypeRefresh SyncTaskType = "元数据刷新"
 )
 
 func logInfo(format string, args ...interface{}) {
@@ -53,13 +55,27 @@ type NewSyncTask struct {
 	IsFile       bool
 	SourceType   models.SourceType
 	AccountId    uint
+	DryRun       bool     // 预演，只生成同步计划
+	WatchPaths   []string // 实时监控发现变化的路径，不为空时只同步这些路径
 }
 
 func (t *NewSyncTask) Key() string {
+	key := fmt.Sprintf("%s-%s", t.SourcePathId, t.TaskType)
 	if t.ID > 0 {
-		return fmt.Sprintf("%d-%s", t.ID, t.TaskType)
-	} else {
-		return fmt.Sprintf("%s-%s", t.SourcePathId, t.TaskType)
+		key = fmt.Sprintf("%d-%s", t.ID, t.TaskType)
+	}
+	// 预演和正式同步可以同时排队，互不去重
+	if t.DryRun {
+		key += "-dryrun"
+	}
+	return key
+}
+
+// 同一个目录的正式任务和预演任务的key
+func taskKeys(id uint, taskType SyncTaskType) []string {
+	return []string{
+		(&NewSyncTask{ID: id, TaskType: taskType}).Key(),
+		(&NewSyncTask{ID: id, TaskType: taskType, DryRun: true}).Key(),
 	}
 }
 
@@ -222,6 +238,8 @@ func (q *NewSyncQueuePerType) executeTask(task *NewSyncTask) {
 		q.executeStrmSync(task)
 	case SyncTaskTypeScrape:
 		q.executeScrape(task)
+	case SyncTaskTypeRefresh:
+		q.executeRefresh(task)
 	}
 }
 
@@ -256,6 +274,9 @@ func (q *NewSyncQueuePerType) executeStrmSync(task *NewSyncTask) {
 			logError("创建同步任务失败")
 			return
 		}
+		if task.DryRun {
+			q.strmSync.EnableDryRun()
+		}
 	}
 
 	// 触发STRM同步任务开始事件
@@ -266,7 +287,13 @@ func (q *NewSyncQueuePerType) executeStrmSync(task *NewSyncTask) {
 	defer func() {
 		q.strmSync = nil
 	}()
-	if startErr := q.strmSync.Start(); startErr == nil {
+	var startErr error
+	if len(task.WatchPaths) > 0 {
+		startErr = q.strmSync.StartWatchBatch(task.WatchPaths)
+	} else {
+		startErr = q.strmSync.Start()
+	}
+	if startErr == nil {
 		logInfo("STRM同步任务执行成功: ID=%d", task.ID)
 		// 触发STRM同步任务完成事件
 		ws.BroadcastEvent(ws.EventStrmSyncTaskComplete, map[string]any{
@@ -332,24 +359,56 @@ func (q *NewSyncQueuePerType) executeScrape(task *NewSyncTask) {
 	}
 }
 
+// 刷新刮削目录中已整理条目的元数据，和刮削任务在同一个队列中排队，不会和刮削同时执行
+func (q *NewSyncQueuePerType) executeRefresh(task *NewSyncTask) {
+	scrapePath := models.GetScrapePathByID(task.ID)
+	if scrapePath == nil {
+		logError("获取刮削目录失败，ID=%d", task.ID)
+		return
+	}
+
+	if scrapePath.SourceType != q.sourceType {
+		logError("刮削目录类型不匹配: 预期=%s, 实际=%s", q.sourceType, scrapePath.SourceType)
+		return
+	}
+
+	logInfo("开始执行元数据刷新任务: ID=%d", task.ID)
+	q.scrapeInstance = scrape.NewScrape(scrapePath)
+	if q.scrapeInstance == nil {
+		logError("创建元数据刷新任务失败")
+		return
+	}
+	defer func() {
+		q.scrapeInstance = nil
+	}()
+	count := q.scrapeInstance.Refresh()
+	logInfo("元数据刷新任务执行完成: ID=%d, 有变化的条目数=%d", task.ID, count)
+}
+
 func (q *NewSyncQueuePerType) CancelTask(id uint, taskType SyncTaskType) error {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
-	key := fmt.Sprintf("%d-%s", id, taskType)
+	keys := taskKeys(id, taskType)
 
-	if _, exists := q.waitingQueue[key]; exists {
-		delete(q.waitingQueue, key)
+	removed := false
+	for _, key := range keys {
+		if _, exists := q.waitingQueue[key]; exists {
+			delete(q.waitingQueue, key)
+			removed = true
+		}
+	}
+	if removed {
 		logInfo("任务已从等待队列移除: 类型=%s, ID=%d", taskType, id)
 		return nil
 	}
 
-	if q.currentTask != nil && q.currentTask.Key() == key {
+	if q.currentTask != nil && slices.Contains(keys, q.currentTask.Key()) {
 		if taskType == SyncTaskTypeStrm && q.strmSync != nil {
 			q.strmSync.Stop()
 			q.strmSync = nil
 			logInfo("STRM同步任务已取消: ID=%d", id)
-		} else if taskType == SyncTaskTypeScrape && q.scrapeInstance != nil {
+		} else if (taskType == SyncTaskTypeScrape || taskType == SyncTaskTypeRefresh) && q.scrapeInstance != nil {
 			q.scrapeInstance.Stop()
 			logInfo("刮削任务已取消: ID=%d", id)
 		}
@@ -364,14 +423,16 @@ func (q *NewSyncQueuePerType) CheckTaskStatus(id uint, taskType SyncTaskType) in
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
 
-	key := fmt.Sprintf("%d-%s", id, taskType)
+	keys := taskKeys(id, taskType)
 
-	if _, exists := q.waitingQueue[key]; exists {
-		return TaskStatusWaiting
+	if q.currentTask != nil && slices.Contains(keys, q.currentTask.Key()) {
+		return TaskStatusRunning
 	}
 
-	if q.currentTask != nil && q.currentTask.Key() == key {
-		return TaskStatusRunning
+	for _, key := range keys {
+		if _, exists := q.waitingQueue[key]; exists {
+			return TaskStatusWaiting
+		}
 	}
 
 	return TaskStatusNone
@@ -537,7 +598,7 @@ func (m *NewSyncQueueManager) CancelTask(id uint, taskType SyncTaskType) error {
 		}
 		sourceType = syncPath.SourceType
 
-	case SyncTaskTypeScrape:
+	case SyncTaskTypeScrape, SyncTaskTypeRefresh:
 		scrapePath := models.GetScrapePathByID(id)
 		if scrapePath == nil {
 			return fmt.Errorf("获取刮削目录失败: ID=%d", id)
@@ -563,7 +624,7 @@ func (m *NewSyncQueueManager) CheckTaskStatus(id uint, taskType SyncTaskType) in
 		}
 		sourceType = syncPath.SourceType
 
-	case SyncTaskTypeScrape:
+	case SyncTaskTypeScrape, SyncTaskTypeRefresh:
 		scrapePath := models.GetScrapePathByID(id)
 		if scrapePath == nil {
 			return TaskStatusNone
@@ -205,3 +205,26 @@ func TestPauseResumeAll(t *testing.T) {
 
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestDryRunTaskKey(t *testing.T) {
+	queue := NewQueuePerType(models.SourceType115)
+	queue.Pause()
+
+	if err := queue.AddTask(&NewSyncTask{ID: 1, TaskType: SyncTaskTypeStrm}); err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+	// 预演和正式同步同一个目录不会去重
+	if err := queue.AddTask(&NewSyncTask{ID: 1, TaskType: SyncTaskTypeStrm, DryRun: true}); err != nil {
+		t.Fatalf("Failed to add dry run task: %v", err)
+	}
+	if err := queue.AddTask(&NewSyncTask{ID: 1, TaskType: SyncTaskTypeStrm, DryRun: true}); err == nil {
+		t.Error("Should return error when adding duplicate dry run task")
+	}
+
+	if err := queue.CancelTask(1, SyncTaskTypeStrm); err != nil {
+		t.Fatalf("Failed to cancel task: %v", err)
+	}
+	if status := queue.CheckTaskStatus(1, SyncTaskTypeStrm); status != TaskStatusNone {
+		t.Errorf("Expected status %d after cancel, got %d", TaskStatusNone, status)
+	}
+}
@@ -20,6 +20,7 @@ import (
 var GlobalCron *cron.Cron
 var SyncCron *cron.Cron
 var ScrapeCron *cron.Cron
+var RefreshCron *cron.Cron
 var TokenCron *cron.Cron
 
 var tokenRefreshRunning int32 = 0
@@ -108,6 +109,33 @@ func RefreshOAuthAccessToken() {
 	accounts, _ := models.GetAllAccount()
 	now := time.Now().Unix()
 	for _, account := range accounts {
+		if account.SourceType == models.SourceType123 {
+			// 123云盘没有刷新token，使用clientID和clientSecret重新获取，客户端会通过事件保存新token
+			if account.Password == "" || account.TokenExpiriesTime-86400 > now {
+				continue
+			}
+			client := account.Get123Client()
+			if err := client.RefreshToken(context.Background()); err != nil {
+				helpers.AppLogger.Errorf("刷新123云盘访问凭证失败: %s", err.Error())
+				account.ClearToken(err.Error())
+				ctx := context.Background()
+				notif := &models.Notification{
+					Type:      models.SystemAlert,
+					Title:     "🔐 123云盘开放平台访问凭证获取失败",
+					Content:   fmt.Sprintf("账号ID：%d\n用户名：%s\n请检查clientID和clientSecret\n⏰ 时间: %s", int(account.ID), account.Username, time.Now().Format("2006-01-02 15:04:05")),
+					Timestamp: time.Now(),
+					Priority:  models.HighPriority,
+				}
+				if notificationmanager.GlobalEnhancedNotificationManager != nil {
+					if err := notificationmanager.GlobalEnhancedNotificationManager.SendNotification(ctx, notif); err != nil {
+						helpers.AppLogger.Errorf("发送访问凭证失效通知失败: %v", err)
+					}
+				}
+				continue
+			}
+			helpers.AppLogger.Infof("刷新123云盘账号token成功，账号ID: %d, 新到期时间: %s", account.ID, client.GetExpiredAt().Format("2006-01-02 15:04:05"))
+			continue
+		}
 		if account.RefreshToken == "" {
 			helpers.AppLogger.Infof("账号 %d 没有刷新token，跳过", account.ID)
 			continue
@@ -409,6 +437,39 @@ func InitScrapeCron() {
 	ScrapeCron.Start()
 }
 
+// 初始化刮削目录的元数据刷新定时任务
+func InitRefreshCron() {
+	if RefreshCron != nil {
+		helpers.AppLogger.Info("已存在元数据刷新的定时任务，先停止")
+		RefreshCron.Stop()
+	}
+	RefreshCron = cron.New()
+	for _, scrapePath := range models.GetScrapePathes("") {
+		if !scrapePath.RefreshEnabled() {
+			continue
+		}
+		scrapePathID := scrapePath.ID // 捕获变量
+		_, err := RefreshCron.AddFunc(scrapePath.RefreshCron, func() {
+			// 添加到刮削的处理队列，不和刮削、备份还原同时执行
+			taskObj := &NewSyncTask{
+				ID:         scrapePathID,
+				AccountId:  scrapePath.AccountId,
+				TaskType:   SyncTaskTypeRefresh,
+				SourceType: scrapePath.SourceType,
+			}
+			if err := AddNewSyncTask(taskObj); err != nil {
+				helpers.AppLogger.Errorf("将元数据刷新任务添加到队列失败：%s", err.Error())
+			}
+		})
+		if err != nil {
+			helpers.AppLogger.Errorf("添加刮削目录 %d 的元数据刷新定时任务失败: %v", scrapePathID, err)
+			continue
+		}
+		helpers.AppLogger.Infof("已添加刮削目录 %d 的元数据刷新定时任务，cron 表达式：%s", scrapePathID, scrapePath.RefreshCron)
+	}
+	RefreshCron.Start()
+}
+
 func addBackupCron() {
 	backupConfig := models.GetOrCreateBackupConfig()
 	if backupConfig.BackupEnabled == 0 || backupConfig.BackupCron == "" {
@@ -0,0 +1,165 @@
+package synccron
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 最后一个事件之后等待多久再触发同步，复制大文件时会持续产生写入事件，等文件写完再同步
+var SyncWatchDebounce = 5 * time.Second
+
+// 监控时忽略的目录，和同步时对比本地文件跳过的目录一致
+var syncWatchIgnoreDirs = []string{".verysync", ".deletedByTMM"}
+
+// 单个同步路径的监控
+type syncPathWatcher struct {
+	syncPath *models.SyncPath
+	watcher  *helpers.AdvancedFolderWatcher
+	mu       sync.Mutex
+	pending  map[string]struct{} // 等待同步的路径
+	timer    *time.Timer
+	stopped  bool
+}
+
+var (
+	syncWatchers   = make(map[uint]*syncPathWatcher)
+	syncWatchersMu sync.Mutex
+)
+
+// 初始化同步目录的实时监控，新增、修改、删除同步目录或者切换监控开关后需要重新调用
+func InitSyncWatch() {
+	syncWatchersMu.Lock()
+	defer syncWatchersMu.Unlock()
+	for id, w := range syncWatchers {
+		w.stop()
+		delete(syncWatchers, id)
+	}
+	syncPaths := models.GetWatchSyncPaths()
+	if len(syncPaths) == 0 {
+		helpers.AppLogger.Info("没有启用实时监控的同步目录")
+		return
+	}
+	for _, syncPath := range syncPaths {
+		w, err := newSyncPathWatcher(syncPath)
+		if err != nil {
+			helpers.AppLogger.Errorf("同步目录 %d 启动实时监控失败: %v", syncPath.ID, err)
+			continue
+		}
+		syncWatchers[syncPath.ID] = w
+		helpers.AppLogger.Infof("已启动同步目录 %d 的实时监控: %s", syncPath.ID, syncPath.RemotePath)
+	}
+}
+
+func newSyncPathWatcher(syncPath *models.SyncPath) (*syncPathWatcher, error) {
+	w := &syncPathWatcher{
+		syncPath: syncPath,
+		pending:  make(map[string]struct{}),
+	}
+	exts := append(append([]string{}, syncPath.GetVideoExt()...), syncPath.GetMetaExt()...)
+	watcher, err := helpers.NewFolderWatcherWithHandler(syncPath.RemotePath, exts, syncWatchIgnoreDirs, w.onEvent)
+	if err != nil {
+		return nil, err
+	}
+	w.watcher = watcher
+	go func() {
+		if err := watcher.Run(); err != nil {
+			helpers.AppLogger.Errorf("同步目录 %d 实时监控退出: %v", syncPath.ID, err)
+		}
+	}()
+	return w, nil
+}
+
+func (w *syncPathWatcher) onEvent(event fsnotify.Event) {
+	if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) == 0 {
+		return
+	}
+	path := filepath.ToSlash(filepath.Clean(event.Name))
+	// STRM目录在来源目录下时，忽略同步自己写入的文件
+	localPath := filepath.ToSlash(filepath.Clean(w.syncPath.LocalPath))
+	if path == localPath || strings.HasPrefix(path, localPath+"/") {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	w.pending[path] = struct{}{}
+	w.resetTimerUnsafe()
+}
+
+func (w *syncPathWatcher) resetTimerUnsafe() {
+	if w.timer == nil {
+		w.timer = time.AfterFunc(SyncWatchDebounce, w.flush)
+		return
+	}
+	w.timer.Reset(SyncWatchDebounce)
+}
+
+// 把积累的路径作为一次增量同步任务加入队列，同步目录正在同步时稍后重试
+func (w *syncPathWatcher) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped || len(w.pending) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		paths = append(paths, path)
+	}
+	task := &NewSyncTask{
+		ID:         w.syncPath.ID,
+		TaskType:   SyncTaskTypeStrm,
+		SourceType: w.syncPath.SourceType,
+		AccountId:  w.syncPath.AccountId,
+		WatchPaths: compactWatchPaths(paths),
+	}
+	if err := AddNewSyncTask(task); err != nil {
+		helpers.AppLogger.Infof("同步目录 %d 暂时无法执行实时同步，稍后重试: %v", w.syncPath.ID, err)
+		w.resetTimerUnsafe()
+		return
+	}
+	helpers.AppLogger.Infof("同步目录 %d 有 %d 个路径发生变化，已加入同步队列", w.syncPath.ID, len(task.WatchPaths))
+	w.pending = make(map[string]struct{})
+}
+
+func (w *syncPathWatcher) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.watcher.Close()
+}
+
+// 去掉父目录已经在列表中的路径，同步目录时会递归处理其下所有内容
+func compactWatchPaths(paths []string) []string {
+	pathSet := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		pathSet[path] = struct{}{}
+	}
+	result := make([]string, 0, len(pathSet))
+	for path := range pathSet {
+		covered := false
+		child := path
+		for parent := filepath.ToSlash(filepath.Dir(child)); parent != child; child, parent = parent, filepath.ToSlash(filepath.Dir(parent)) {
+			if _, ok := pathSet[parent]; ok {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			result = append(result, path)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
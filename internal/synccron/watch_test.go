@@ -0,0 +1,22 @@
+package synccron
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompactWatchPaths(t *testing.T) {
+	paths := []string{
+		"/media/电影/A/a.mkv",
+		"/media/电影/A",
+		"/media/电影/A B/b.mkv",
+		"/media/电影/A/sub/c.nfo",
+		"/media/剧集/S01E01.mkv",
+		"/media/剧集/S01E01.mkv",
+	}
+	got := compactWatchPaths(paths)
+	want := []string{"/media/剧集/S01E01.mkv", "/media/电影/A", "/media/电影/A B/b.mkv"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
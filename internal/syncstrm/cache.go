@@ -0,0 +1,95 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"os"
+)
+
+// syncCache 同步缓存，同步过程中网盘文件全部先放入缓存，最后和SyncFile表对比
+// 有内存（MemorySyncCache）和磁盘（DiskSyncCache）两种实现
+// 注意：磁盘缓存返回的是副本，修改返回的记录后需要调用Update才能保存
+type syncCache interface {
+	Insert(file *SyncFileCache) error
+	// 修改查询返回的记录后写回同步缓存
+	Update(file *SyncFileCache) error
+	InsertDownloadIndex(file *SyncFileCache) error
+	BatchInsert(files []*SyncFileCache) error
+	GetByFileId(fileId string) (*SyncFileCache, error)
+	GetByLocalPath(localFilePath string) (*SyncFileCache, error)
+	GetByParentId(parentId string) ([]*SyncFileCache, error)
+	ExistsByLocalPath(localFilePath string) bool
+	DeleteByFileId(fileId string) error
+	DeleteByParentId(parentId string) error
+	UpdatePathByParentId(parentId string, newPath string, targetPath, sourcePath string) error
+	Count() int64
+	Clear()
+	// 遍历所有记录，fn返回false停止遍历；遍历过程中可以删除记录
+	Range(fn func(file *SyncFileCache) bool)
+	// 遍历所有需要下载的记录
+	RangeDownload(fn func(file *SyncFileCache) bool)
+	Close() error
+}
+
+// 根据同步路径的文件数选择同步缓存
+// 已经有磁盘缓存的同步路径继续使用磁盘缓存（保留上次同步的目录索引）
+func (s *SyncStrm) initSyncCache() {
+	if s.TmpSyncPath {
+		return
+	}
+	useDisk := HasDiskSyncCache(s.SyncPathId)
+	if !useDisk {
+		var total int64
+		if err := db.Db.Model(&models.SyncFile{}).Where("sync_path_id = ?", s.SyncPathId).Count(&total).Error; err != nil {
+			s.Sync.Logger.Warnf("查询同步路径文件总数失败，使用内存同步缓存: %v", err)
+			return
+		}
+		useDisk = total >= models.GetDiskSyncCacheMinFiles()
+	}
+	if !useDisk {
+		return
+	}
+	if s.DryRun {
+		// 预演使用临时的磁盘缓存，不修改正式同步的磁盘缓存和目录索引
+		diskCache, err := newDryRunDiskSyncCache(s.SyncPathId)
+		if err != nil {
+			s.Sync.Logger.Errorf("打开预演的磁盘同步缓存失败，使用内存同步缓存: %v", err)
+			return
+		}
+		s.memSyncCache = diskCache
+		s.Sync.Logger.Infof("预演使用临时磁盘同步缓存: %s", diskCache.dbFile)
+		return
+	}
+	diskCache, err := NewDiskSyncCache(s.SyncPathId)
+	if err != nil {
+		s.Sync.Logger.Errorf("打开磁盘同步缓存失败，使用内存同步缓存: %v", err)
+		return
+	}
+	// 上次同步中断留下的记录不能用于本次对比
+	diskCache.Clear()
+	if s.FullSync {
+		// 全量同步重新预取目录
+		diskCache.ClearDirs()
+	}
+	s.memSyncCache = diskCache
+	s.Sync.Logger.Infof("同步路径使用磁盘同步缓存: %s", diskCache.dbFile)
+}
+
+// 删除同步路径时一起删除磁盘缓存
+func RemoveDiskSyncCache(syncPathId uint) {
+	removeDiskSyncCacheFile(getDiskSyncCacheFile(syncPathId))
+}
+
+// 删除sqlite文件以及WAL文件
+func removeDiskSyncCacheFile(dbFile string) {
+	for _, f := range []string{"", "-wal", "-shm"} {
+		file := dbFile + f
+		if !helpers.PathExists(file) {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			helpers.AppLogger.Warnf("删除磁盘同步缓存 %s 失败: %v", file, err)
+		}
+	}
+}
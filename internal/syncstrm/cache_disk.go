@@ -0,0 +1,387 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/v115open"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// 磁盘同步缓存中的文件记录（本次同步的网盘文件）
+type diskSyncFile struct {
+	FileId        string `gorm:"primaryKey"`
+	ParentId      string `gorm:"index"`
+	FileType      v115open.FileType
+	FileName      string
+	Path          string
+	LocalFilePath string `gorm:"index"`
+	FileSize      int64
+	MTime         int64
+	PickCode      string
+	IsVideo       bool
+	IsMeta        bool
+	NeedDownload  bool `gorm:"index"`
+	Sha1          string
+	ThumbUrl      string
+	OpenlistSign  string
+	SourceType    models.SourceType
+}
+
+func (*diskSyncFile) TableName() string {
+	return "sync_files"
+}
+
+// 磁盘同步缓存中的目录索引，同步完成后不会清空，重启后依然可用
+// 下次增量同步直接用来补全路径，不需要重新预取目录
+type diskSyncDir struct {
+	FileId   string `gorm:"primaryKey"`
+	ParentId string
+	FileName string
+	Path     string // 不包含FileName
+	MTime    int64
+}
+
+func (*diskSyncDir) TableName() string {
+	return "sync_dirs"
+}
+
+// DiskSyncCache 磁盘同步缓存，使用同步路径独立的sqlite文件
+// 用于文件数很多的同步路径，避免把所有文件都放在内存中
+type DiskSyncCache struct {
+	db     *gorm.DB
+	dbFile string
+
+	// 同步路径ID
+	syncPathId uint
+	// 临时缓存，关闭时删除文件
+	temporary bool
+}
+
+func getDiskSyncCacheFile(syncPathId uint) string {
+	return filepath.Join(helpers.ConfigDir, "sync_cache", fmt.Sprintf("%d.db", syncPathId))
+}
+
+// 同步路径是否已经有磁盘缓存
+func HasDiskSyncCache(syncPathId uint) bool {
+	return helpers.PathExists(getDiskSyncCacheFile(syncPathId))
+}
+
+// NewDiskSyncCache 打开同步路径的磁盘同步缓存，不存在则创建
+func NewDiskSyncCache(syncPathId uint) (*DiskSyncCache, error) {
+	return openDiskSyncCache(getDiskSyncCacheFile(syncPathId), syncPathId)
+}
+
+// 预演使用的临时磁盘同步缓存，每次重新创建，关闭时删除
+func newDryRunDiskSyncCache(syncPathId uint) (*DiskSyncCache, error) {
+	dbFile := filepath.Join(helpers.ConfigDir, "sync_cache", fmt.Sprintf("%d-dryrun.db", syncPathId))
+	removeDiskSyncCacheFile(dbFile)
+	c, err := openDiskSyncCache(dbFile, syncPathId)
+	if err != nil {
+		return nil, err
+	}
+	c.temporary = true
+	return c, nil
+}
+
+func openDiskSyncCache(dbFile string, syncPathId uint) (*DiskSyncCache, error) {
+	if err := os.MkdirAll(filepath.Dir(dbFile), 0777); err != nil {
+		return nil, fmt.Errorf("创建磁盘同步缓存目录失败: %w", err)
+	}
+	// 只是缓存，丢了可以重建，所以关闭同步写盘换取写入速度
+	cacheDb, err := gorm.Open(sqlite.Open(dbFile+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(OFF)"), &gorm.Config{
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("打开磁盘同步缓存失败: %w", err)
+	}
+	sqlDb, err := cacheDb.DB()
+	if err != nil {
+		return nil, err
+	}
+	// sqlite只允许一个写连接，多个协程同时写会报database is locked
+	sqlDb.SetMaxOpenConns(1)
+	if err := cacheDb.AutoMigrate(&diskSyncFile{}, &diskSyncDir{}); err != nil {
+		sqlDb.Close()
+		return nil, fmt.Errorf("初始化磁盘同步缓存失败: %w", err)
+	}
+	return &DiskSyncCache{db: cacheDb, dbFile: dbFile, syncPathId: syncPathId}, nil
+}
+
+func newDiskSyncFile(file *SyncFileCache) *diskSyncFile {
+	return &diskSyncFile{
+		FileId:        file.GetFileId(),
+		ParentId:      file.ParentId,
+		FileType:      file.FileType,
+		FileName:      file.FileName,
+		Path:          file.Path,
+		LocalFilePath: file.LocalFilePath,
+		FileSize:      file.FileSize,
+		MTime:         file.MTime,
+		PickCode:      file.PickCode,
+		IsVideo:       file.IsVideo,
+		IsMeta:        file.IsMeta,
+		NeedDownload:  file.NeedDownload,
+		Sha1:          file.Sha1,
+		ThumbUrl:      file.ThumbUrl,
+		OpenlistSign:  file.OpenlistSign,
+		SourceType:    file.SourceType,
+	}
+}
+
+func (f *diskSyncFile) toSyncFileCache() *SyncFileCache {
+	return &SyncFileCache{
+		FileId:        f.FileId,
+		ParentId:      f.ParentId,
+		FileType:      f.FileType,
+		FileName:      f.FileName,
+		Path:          f.Path,
+		LocalFilePath: f.LocalFilePath,
+		FileSize:      f.FileSize,
+		MTime:         f.MTime,
+		PickCode:      f.PickCode,
+		IsVideo:       f.IsVideo,
+		IsMeta:        f.IsMeta,
+		NeedDownload:  f.NeedDownload,
+		Sha1:          f.Sha1,
+		ThumbUrl:      f.ThumbUrl,
+		OpenlistSign:  f.OpenlistSign,
+		SourceType:    f.SourceType,
+	}
+}
+
+// Insert 插入单条记录，已存在则覆盖
+func (c *DiskSyncCache) Insert(file *SyncFileCache) error {
+	if file.GetFileId() == "" {
+		return fmt.Errorf("file_id不能为空")
+	}
+	// 115路径完整的目录同时写入目录索引
+	if file.SourceType != models.SourceType115 || file.FileType != v115open.TypeDir || file.Path == "" {
+		return c.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(newDiskSyncFile(file)).Error
+	}
+	dir := &diskSyncDir{
+		FileId:   file.FileId,
+		ParentId: file.ParentId,
+		FileName: file.FileName,
+		Path:     file.Path,
+		MTime:    file.MTime,
+	}
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(newDiskSyncFile(file)).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(dir).Error
+	})
+}
+
+// Update 写回修改过的记录，查询返回的是副本，修改后必须调用
+func (c *DiskSyncCache) Update(file *SyncFileCache) error {
+	return c.Insert(file)
+}
+
+// 放入待下载索引
+func (c *DiskSyncCache) InsertDownloadIndex(file *SyncFileCache) error {
+	if file.GetFileId() == "" {
+		return nil
+	}
+	file.NeedDownload = true
+	return c.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(newDiskSyncFile(file)).Error
+}
+
+// BatchInsert 批量插入
+func (c *DiskSyncCache) BatchInsert(files []*SyncFileCache) error {
+	for _, file := range files {
+		if err := c.Insert(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByFileId 根据 file_id 查询
+func (c *DiskSyncCache) GetByFileId(fileId string) (*SyncFileCache, error) {
+	var file diskSyncFile
+	if err := c.db.Where("file_id = ?", fileId).Limit(1).Find(&file).Error; err != nil {
+		return nil, err
+	}
+	if file.FileId == "" {
+		return nil, fmt.Errorf("未找到记录: file_id=%s", fileId)
+	}
+	return file.toSyncFileCache(), nil
+}
+
+// GetByLocalPath 根据本地路径查询
+func (c *DiskSyncCache) GetByLocalPath(localFilePath string) (*SyncFileCache, error) {
+	var file diskSyncFile
+	if localFilePath != "" {
+		if err := c.db.Where("local_file_path = ?", localFilePath).Limit(1).Find(&file).Error; err != nil {
+			return nil, err
+		}
+	}
+	if file.FileId == "" {
+		return nil, fmt.Errorf("未找到记录: local_file_path=%s", localFilePath)
+	}
+	return file.toSyncFileCache(), nil
+}
+
+// GetByParentId 根据 parent_id 查询
+func (c *DiskSyncCache) GetByParentId(parentId string) ([]*SyncFileCache, error) {
+	var files []*diskSyncFile
+	if err := c.db.Where("parent_id = ?", parentId).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("未找到记录: parent_id=%s", parentId)
+	}
+	result := make([]*SyncFileCache, 0, len(files))
+	for _, file := range files {
+		result = append(result, file.toSyncFileCache())
+	}
+	return result, nil
+}
+
+// ExistsByLocalPath 检查本地路径是否存在
+func (c *DiskSyncCache) ExistsByLocalPath(localFilePath string) bool {
+	file, _ := c.GetByLocalPath(localFilePath)
+	return file != nil
+}
+
+// DeleteByFileId 根据 file_id 删除，目录索引保留
+func (c *DiskSyncCache) DeleteByFileId(fileId string) error {
+	return c.db.Where("file_id = ?", fileId).Delete(&diskSyncFile{}).Error
+}
+
+// DeleteByParentId 根据 parent_id 删除所有子项
+func (c *DiskSyncCache) DeleteByParentId(parentId string) error {
+	if err := c.db.Where("parent_id = ?", parentId).Delete(&diskSyncFile{}).Error; err != nil {
+		return err
+	}
+	// 被排除的目录也不再保留索引
+	return c.db.Where("parent_id = ?", parentId).Delete(&diskSyncDir{}).Error
+}
+
+// UpdatePathByParentId 更新指定父目录下所有文件的路径
+func (c *DiskSyncCache) UpdatePathByParentId(parentId string, newPath string, targetPath, sourcePath string) error {
+	files, _ := c.GetByParentId(parentId)
+	for _, file := range files {
+		file.Path = newPath
+		file.LocalFilePath = ""
+		// 更新完整本地路径
+		file.GetLocalFilePath(targetPath, sourcePath)
+		if err := c.Insert(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count 统计记录数
+func (c *DiskSyncCache) Count() int64 {
+	var total int64
+	c.db.Model(&diskSyncFile{}).Count(&total)
+	return total
+}
+
+// Clear 清空本次同步的文件记录，目录索引保留
+func (c *DiskSyncCache) Clear() {
+	c.db.Where("1 = 1").Delete(&diskSyncFile{})
+}
+
+// DeleteDir 从目录索引中删除网盘已经不存在的目录
+func (c *DiskSyncCache) DeleteDir(fileId string) error {
+	return c.db.Where("file_id = ?", fileId).Delete(&diskSyncDir{}).Error
+}
+
+// ClearDirs 清空目录索引
+func (c *DiskSyncCache) ClearDirs() {
+	c.db.Where("1 = 1").Delete(&diskSyncDir{})
+}
+
+// DirCount 目录索引中的目录数
+func (c *DiskSyncCache) DirCount() int64 {
+	var total int64
+	c.db.Model(&diskSyncDir{}).Count(&total)
+	return total
+}
+
+// 按file_id分页遍历，每页查完再回调，所以回调中可以删除记录
+func (c *DiskSyncCache) rangeWhere(query *gorm.DB, fn func(file *SyncFileCache) bool) {
+	lastFileId := ""
+	limit := 1000
+	for {
+		var files []*diskSyncFile
+		if err := query.Session(&gorm.Session{}).Where("file_id > ?", lastFileId).Order("file_id ASC").Limit(limit).Find(&files).Error; err != nil {
+			helpers.AppLogger.Errorf("遍历磁盘同步缓存失败: %v", err)
+			return
+		}
+		for _, file := range files {
+			if !fn(file.toSyncFileCache()) {
+				return
+			}
+		}
+		if len(files) < limit {
+			return
+		}
+		lastFileId = files[len(files)-1].FileId
+	}
+}
+
+// Range 遍历所有记录
+func (c *DiskSyncCache) Range(fn func(file *SyncFileCache) bool) {
+	c.rangeWhere(c.db.Model(&diskSyncFile{}), fn)
+}
+
+// RangeDownload 遍历所有需要下载的记录
+func (c *DiskSyncCache) RangeDownload(fn func(file *SyncFileCache) bool) {
+	c.rangeWhere(c.db.Model(&diskSyncFile{}).Where("need_download = ?", true), fn)
+}
+
+// RangeDirs 遍历目录索引
+func (c *DiskSyncCache) RangeDirs(fn func(dir *SyncFileCache) bool) {
+	lastFileId := ""
+	limit := 1000
+	for {
+		var dirs []*diskSyncDir
+		if err := c.db.Where("file_id > ?", lastFileId).Order("file_id ASC").Limit(limit).Find(&dirs).Error; err != nil {
+			helpers.AppLogger.Errorf("遍历磁盘同步缓存目录索引失败: %v", err)
+			return
+		}
+		for _, dir := range dirs {
+			item := &SyncFileCache{
+				FileId:   dir.FileId,
+				ParentId: dir.ParentId,
+				FileType: v115open.TypeDir,
+				FileName: dir.FileName,
+				Path:     dir.Path,
+				MTime:    dir.MTime,
+			}
+			if !fn(item) {
+				return
+			}
+		}
+		if len(dirs) < limit {
+			return
+		}
+		lastFileId = dirs[len(dirs)-1].FileId
+	}
+}
+
+// Close 关闭缓存文件
+func (c *DiskSyncCache) Close() error {
+	sqlDb, err := c.db.DB()
+	if err != nil {
+		return err
+	}
+	err = sqlDb.Close()
+	if c.temporary {
+		removeDiskSyncCacheFile(c.dbFile)
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/v115open"
+	"path/filepath"
+	"testing"
+)
+
+func newTestDiskSyncCache(t *testing.T, dbFile string) *DiskSyncCache {
+	cache, err := openDiskSyncCache(dbFile, 1)
+	if err != nil {
+		t.Fatalf("打开磁盘同步缓存失败: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestDiskSyncCacheInsertAndQuery(t *testing.T) {
+	cache := newTestDiskSyncCache(t, filepath.Join(t.TempDir(), "1.db"))
+	files := []*SyncFileCache{
+		{FileId: "f1", ParentId: "d1", FileName: "a.mkv", FileType: v115open.TypeFile, SourceType: models.SourceType115, IsVideo: true},
+		{FileId: "f2", ParentId: "d1", FileName: "a.nfo", FileType: v115open.TypeFile, SourceType: models.SourceType115, IsMeta: true},
+		{FileId: "f3", ParentId: "d2", FileName: "b.mkv", FileType: v115open.TypeFile, SourceType: models.SourceType115, IsVideo: true},
+	}
+	if err := cache.BatchInsert(files); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+	if cache.Count() != 3 {
+		t.Fatalf("记录数不正确: %d", cache.Count())
+	}
+	if _, err := cache.GetByFileId("f4"); err == nil {
+		t.Errorf("不存在的记录应该返回错误")
+	}
+	// 补全路径后可以按本地路径查询
+	if err := cache.UpdatePathByParentId("d1", "电影/A", "/strm", ""); err != nil {
+		t.Fatalf("更新路径失败: %v", err)
+	}
+	children, err := cache.GetByParentId("d1")
+	if err != nil || len(children) != 2 {
+		t.Fatalf("按父目录查询不正确: %v %d", err, len(children))
+	}
+	file, err := cache.GetByLocalPath("/strm/电影/A/a.strm")
+	if err != nil || file.FileId != "f1" || !file.IsVideo {
+		t.Fatalf("按本地路径查询不正确: %v %+v", err, file)
+	}
+	// 下载索引
+	if err := cache.InsertDownloadIndex(children[1]); err != nil {
+		t.Fatalf("加入下载索引失败: %v", err)
+	}
+	var downloads []string
+	cache.RangeDownload(func(file *SyncFileCache) bool {
+		downloads = append(downloads, file.FileId)
+		return true
+	})
+	if len(downloads) != 1 || downloads[0] != children[1].FileId {
+		t.Errorf("下载索引不正确: %v", downloads)
+	}
+	// 遍历的时候删除
+	cache.Range(func(file *SyncFileCache) bool {
+		cache.DeleteByFileId(file.FileId)
+		return true
+	})
+	if cache.Count() != 0 {
+		t.Errorf("遍历删除后应该为空: %d", cache.Count())
+	}
+}
+
+func TestDiskSyncCacheDirsSurviveReopen(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "1.db")
+	cache := newTestDiskSyncCache(t, dbFile)
+	cache.Insert(&SyncFileCache{FileId: "d1", ParentId: "0", FileName: "A", Path: "电影", FileType: v115open.TypeDir, SourceType: models.SourceType115})
+	cache.Insert(&SyncFileCache{FileId: "d2", ParentId: "d1", FileName: "B", FileType: v115open.TypeDir, SourceType: models.SourceType115})
+	cache.Insert(&SyncFileCache{FileId: "f1", ParentId: "d1", FileName: "a.mkv", Path: "电影/A", FileType: v115open.TypeFile, SourceType: models.SourceType115})
+	cache.Clear()
+	cache.Close()
+
+	cache = newTestDiskSyncCache(t, dbFile)
+	if cache.Count() != 0 {
+		t.Errorf("清空后文件记录应该为空: %d", cache.Count())
+	}
+	// 只有路径完整的目录才会进入目录索引
+	if cache.DirCount() != 1 {
+		t.Fatalf("目录索引数量不正确: %d", cache.DirCount())
+	}
+	cache.RangeDirs(func(dir *SyncFileCache) bool {
+		if dir.FileId != "d1" || dir.Path != "电影" || dir.FileName != "A" {
+			t.Errorf("目录索引内容不正确: %+v", dir)
+		}
+		return true
+	})
+	cache.ClearDirs()
+	if cache.DirCount() != 0 {
+		t.Errorf("清空目录索引失败: %d", cache.DirCount())
+	}
+}
+
+func TestDryRunDiskSyncCacheRemovedOnClose(t *testing.T) {
+	oldConfigDir := helpers.ConfigDir
+	helpers.ConfigDir = t.TempDir()
+	t.Cleanup(func() { helpers.ConfigDir = oldConfigDir })
+
+	cache, err := newDryRunDiskSyncCache(1)
+	if err != nil {
+		t.Fatalf("打开预演的磁盘同步缓存失败: %v", err)
+	}
+	if cache.dbFile == getDiskSyncCacheFile(1) {
+		t.Fatalf("预演不能使用正式的磁盘同步缓存: %s", cache.dbFile)
+	}
+	if err := cache.Insert(&SyncFileCache{FileId: "f1", FileName: "a.mkv"}); err != nil {
+		t.Fatalf("插入失败: %v", err)
+	}
+	cache.Close()
+	if helpers.PathExists(cache.dbFile) || HasDiskSyncCache(1) {
+		t.Errorf("预演的磁盘同步缓存关闭后应该删除，并且不能创建正式的磁盘同步缓存")
+	}
+}
+
+func TestSyncCacheUpdateAndDeleteDir(t *testing.T) {
+	caches := map[string]syncCache{
+		"memory": NewMemorySyncCache(1),
+		"disk":   newTestDiskSyncCache(t, filepath.Join(t.TempDir(), "1.db")),
+	}
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			cache.Insert(&SyncFileCache{FileId: "d1", ParentId: "0", FileName: "A", Path: "电影", FileType: v115open.TypeDir, SourceType: models.SourceType115})
+			cache.Insert(&SyncFileCache{FileId: "f1", ParentId: "d1", FileName: "a.nfo", FileType: v115open.TypeFile, SourceType: models.SourceType115, IsMeta: true})
+			// 修改查询返回的记录后需要写回
+			file, _ := cache.GetByFileId("f1")
+			file.Path = "电影/A"
+			file.GetLocalFilePath("/strm", "")
+			if err := cache.Update(file); err != nil {
+				t.Fatalf("更新失败: %v", err)
+			}
+			if found, _ := cache.GetByLocalPath("/strm/电影/A/a.nfo"); found == nil || found.FileId != "f1" {
+				t.Errorf("更新后应该可以按本地路径查询: %+v", found)
+			}
+			if children, _ := cache.GetByParentId("d1"); len(children) != 1 {
+				t.Errorf("更新后父目录索引不能重复: %d", len(children))
+			}
+			s := &SyncStrm{memSyncCache: cache}
+			if err := s.deleteCachedDir("d1"); err != nil {
+				t.Fatalf("删除目录失败: %v", err)
+			}
+			if found, _ := cache.GetByFileId("d1"); found != nil {
+				t.Errorf("目录应该从同步缓存中删除")
+			}
+			if diskCache, ok := cache.(*DiskSyncCache); ok && diskCache.DirCount() != 0 {
+				t.Errorf("目录应该从目录索引中删除: %d", diskCache.DirCount())
+			}
+		})
+	}
+}
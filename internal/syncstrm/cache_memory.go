@@ -223,6 +223,24 @@ func (c *MemorySyncCache) Insert(file *SyncFileCache) error {
 	return nil
 }
 
+// Update 写回修改过的记录
+// 查询返回的就是缓存中的指针，只需要补上本地路径索引；不是同一个指针时替换原记录
+func (c *MemorySyncCache) Update(file *SyncFileCache) error {
+	c.mu.Lock()
+	if cached, ok := c.fileIndex[file.GetFileId()]; ok && cached == file {
+		if file.GetPath() != "" {
+			c.localPathIndex[file.LocalFilePath] = file
+		}
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+	if err := c.DeleteByFileId(file.GetFileId()); err != nil {
+		return err
+	}
+	return c.Insert(file)
+}
+
 // 放入待下载索引
 func (c *MemorySyncCache) InsertDownloadIndex(file *SyncFileCache) error {
 	c.mu.Lock()
@@ -422,3 +440,38 @@ func (c *MemorySyncCache) Clear() {
 func (c *MemorySyncCache) GetAllFile() map[string]*SyncFileCache {
 	return c.fileIndex
 }
+
+// Range 遍历所有记录，先复制一份再回调，所以回调中可以删除记录
+func (c *MemorySyncCache) Range(fn func(file *SyncFileCache) bool) {
+	c.mu.RLock()
+	files := make([]*SyncFileCache, 0, len(c.fileIndex))
+	for _, file := range c.fileIndex {
+		files = append(files, file)
+	}
+	c.mu.RUnlock()
+	for _, file := range files {
+		if !fn(file) {
+			return
+		}
+	}
+}
+
+// RangeDownload 遍历所有需要下载的记录
+func (c *MemorySyncCache) RangeDownload(fn func(file *SyncFileCache) bool) {
+	c.mu.RLock()
+	files := make([]*SyncFileCache, 0, len(c.downloadIndex))
+	for _, file := range c.downloadIndex {
+		files = append(files, file)
+	}
+	c.mu.RUnlock()
+	for _, file := range files {
+		if !fn(file) {
+			return
+		}
+	}
+}
+
+// Close 内存缓存不需要关闭
+func (c *MemorySyncCache) Close() error {
+	return nil
+}
@@ -167,6 +167,7 @@ func (d *open115Driver) MakeStrmContent(sf *SyncFileCache) string {
 	params := url.Values{}
 	params.Add("pickcode", sf.PickCode)
 	params.Add("userid", d.s.Account.UserId)
+	d.s.AddStrmSignParams(params, sf.PickCode)
 	u.RawQuery = params.Encode()
 	urlStr := u.String()
 	if d.s.Config.StrmUrlNeedPath == 1 {
@@ -239,6 +240,20 @@ func (d *open115Driver) GetFilesByPathId(ctx context.Context, rootPathId string,
 	return resp.Data, nil
 }
 
+var _ utimeListDriver = (*open115Driver)(nil)
+
+// 按修改时间倒序查询目录下的文件和目录，增量同步使用
+func (d *open115Driver) GetFilesByPathIdOrderByUtime(ctx context.Context, pathId string, showCur bool, offset, limit int) ([]v115open.File, error) {
+	resp, err := d.client.GetFsListOrder(ctx, pathId, showCur, true, true, v115open.FsListOrderUtime, false, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, nil
+	}
+	return resp.Data, nil
+}
+
 // 所有文件详情，含路径
 func (d *open115Driver) DetailByFileId(ctx context.Context, fileId string) (*SyncFileCache, error) {
 	resp, err := d.client.GetFsDetailByCid(ctx, fileId)
@@ -0,0 +1,197 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/baidupan"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/open123"
+	"Q115-STRM/internal/v115open"
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+type open123Driver struct {
+	s      *SyncStrm
+	client *open123.Client
+}
+
+func NewOpen123Driver(client *open123.Client) *open123Driver {
+	return &open123Driver{
+		client: client,
+	}
+}
+
+func (d *open123Driver) SetSyncStrm(s *SyncStrm) {
+	d.s = s
+}
+
+func (d *open123Driver) GetNetFileFiles(ctx context.Context, parentPath, parentPathId string) ([]*SyncFileCache, error) {
+	files, err := d.client.ListAllFiles(ctx, helpers.StringToInt64(parentPathId))
+	if err != nil {
+		d.s.Sync.Logger.Errorf("获取123云盘文件列表失败: %v", err)
+		return nil, err
+	}
+	fileItems := make([]*SyncFileCache, 0, len(files))
+	for _, file := range files {
+		atomic.AddInt64(&d.s.TotalFile, 1)
+		fileId := helpers.Int64ToString(file.FileID)
+		fileItem := &SyncFileCache{
+			ParentId:   parentPathId,
+			FileId:     fileId,
+			PickCode:   fileId, // 123云盘没有pickcode，使用文件ID
+			Path:       filepath.ToSlash(parentPath),
+			FileName:   file.FileName,
+			FileType:   v115open.TypeFile,
+			FileSize:   file.Size,
+			Sha1:       file.Etag,
+			MTime:      file.UpdateTime(),
+			SourceType: models.SourceType123,
+		}
+		if file.IsDir() {
+			fileItem.FileType = v115open.TypeDir
+			fileItem.PickCode = ""
+			fileItem.IsVideo = false
+			fileItem.IsMeta = false
+		}
+		fileItems = append(fileItems, fileItem)
+	}
+	return fileItems, nil
+}
+
+// 从根目录开始逐级检查，不存在就创建
+func (d *open123Driver) CreateDirRecursively(ctx context.Context, path string) (pathId, remotePath string, err error) {
+	relPath, err := filepath.Rel(d.s.TargetPath, path)
+	if err != nil {
+		return "", "", fmt.Errorf("计算相对路径失败: %s 错误：%v", path, err)
+	}
+	relPath = filepath.ToSlash(relPath)
+	if !strings.HasPrefix(relPath, "/") {
+		relPath = "/" + relPath
+	}
+	var parentId int64
+	currentPath := ""
+	for _, name := range strings.Split(strings.Trim(relPath, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		parentPath := currentPath
+		currentPath = currentPath + "/" + name
+		files, listErr := d.client.ListAllFiles(ctx, parentId)
+		if listErr != nil {
+			return "", "", fmt.Errorf("查询目录失败: %s 错误：%v", currentPath, listErr)
+		}
+		var currentId int64
+		for _, f := range files {
+			if f.IsDir() && f.FileName == name {
+				currentId = f.FileID
+				break
+			}
+		}
+		if currentId == 0 {
+			resp, mkErr := d.client.CreateFolder(ctx, name, parentId)
+			if mkErr != nil {
+				return "", "", fmt.Errorf("创建目录失败: %s 错误：%v", currentPath, mkErr)
+			}
+			currentId = resp.DirID
+			// 将新添加的目录加入同步缓存
+			syncFileCache := &SyncFileCache{
+				FileId:     helpers.Int64ToString(currentId),
+				ParentId:   helpers.Int64ToString(parentId),
+				Path:       parentPath,
+				FileName:   name,
+				FileType:   v115open.TypeDir,
+				IsVideo:    false,
+				IsMeta:     false,
+				SourceType: models.SourceType123,
+			}
+			syncFileCache.GetLocalFilePath(d.s.TargetPath, d.s.SourcePath)
+			d.s.memSyncCache.Insert(syncFileCache)
+			d.s.Sync.Logger.Infof("创建目录成功: %s 目录ID: %d", currentPath, currentId)
+		}
+		parentId = currentId
+	}
+	return helpers.Int64ToString(parentId), relPath, nil
+}
+
+func (d *open123Driver) GetPathIdByPath(ctx context.Context, path string) (string, error) {
+	fileId, err := d.client.GetPathIdByPath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	return helpers.Int64ToString(fileId), nil
+}
+
+func (d *open123Driver) MakeStrmContent(sf *SyncFileCache) string {
+	// 生成URL
+	u, _ := url.Parse(d.s.Config.StrmBaseUrl)
+	ext := filepath.Ext(sf.FileName)
+	u.Path = fmt.Sprintf("/123/url/video%s", ext)
+	params := url.Values{}
+	params.Add("pickcode", sf.PickCode)
+	params.Add("userid", d.s.Account.UserId)
+	d.s.AddStrmSignParams(params, sf.PickCode)
+	u.RawQuery = params.Encode()
+	urlStr := u.String()
+	if d.s.Config.StrmUrlNeedPath == 1 {
+		urlStr += fmt.Sprintf("&path=%s", d.s.GetRemoteFilePathUrlEncode(sf.GetFullRemotePath()))
+	}
+	return urlStr
+}
+
+func (d *open123Driver) GetTotalFileCount(ctx context.Context) (int64, string, error) {
+	return 0, "", nil
+}
+
+func (d *open123Driver) GetDirsByPathId(ctx context.Context, pathId string) ([]pathQueueItem, error) {
+	return nil, nil
+}
+
+func (d *open123Driver) GetFilesByPathId(ctx context.Context, rootPathId string, offset, limit int) ([]v115open.File, error) {
+	return nil, nil
+}
+
+// 所有文件详情，含路径
+func (d *open123Driver) DetailByFileId(ctx context.Context, fileId string) (*SyncFileCache, error) {
+	resp, parentPath, err := d.client.GetFileDetailWithPath(ctx, helpers.StringToInt64(fileId))
+	if err != nil {
+		return nil, err
+	}
+	fileItem := &SyncFileCache{
+		FileId:     helpers.Int64ToString(resp.FileID),
+		FileName:   resp.FileName,
+		FileType:   v115open.TypeFile,
+		SourceType: models.SourceType123,
+		Path:       parentPath,
+		ParentId:   helpers.Int64ToString(resp.ParentFileID),
+		FileSize:   resp.Size,
+		Sha1:       resp.Etag,
+		Paths:      []v115open.FileDetailPath{},
+	}
+	if resp.IsDir() {
+		fileItem.FileType = v115open.TypeDir
+		fileItem.IsVideo = false
+		fileItem.IsMeta = false
+	} else {
+		fileItem.PickCode = fileItem.FileId
+		fileItem.IsVideo = d.s.IsValidVideoExt(fileItem.FileName)
+		fileItem.IsMeta = d.s.IsValidMetaExt(fileItem.FileName)
+	}
+	return fileItem, nil
+}
+
+// 删除目录下的某些文件（移入回收站）
+func (d *open123Driver) DeleteFile(ctx context.Context, parentId string, fileIds []string) error {
+	ids := make([]int64, 0, len(fileIds))
+	for _, id := range fileIds {
+		ids = append(ids, helpers.StringToInt64(id))
+	}
+	return d.client.TrashFiles(ctx, ids)
+}
+
+func (d *open123Driver) GetFilesByPathMtime(ctx context.Context, rootPathId string, offset, limit int, mtime int64) (*baidupan.FileListAllResponse, error) {
+	return nil, nil
+}
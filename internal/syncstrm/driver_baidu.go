@@ -103,6 +103,7 @@ func (d *BaiduPanDriver) MakeStrmContent(sf *SyncFileCache) string {
 	params := url.Values{}
 	params.Add("pickcode", sf.PickCode)
 	params.Add("userid", d.s.Account.UserId)
+	d.s.AddStrmSignParams(params, sf.PickCode)
 	u.RawQuery = params.Encode()
 	urlStr := u.String()
 	if d.s.Config.StrmUrlNeedPath == 1 {
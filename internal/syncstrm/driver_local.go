@@ -123,8 +123,29 @@ func (d *localDriver) GetFilesByPathId(ctx context.Context, rootPathId string, o
 }
 
 // 所有文件详情，含路径
+// 本地文件的fileId就是完整路径
 func (d *localDriver) DetailByFileId(ctx context.Context, fileId string) (*SyncFileCache, error) {
-	return nil, nil
+	fullPath := filepath.ToSlash(fileId)
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("文件 %s 不存在: %v", fullPath, err)
+	}
+	parentId := filepath.ToSlash(filepath.Dir(fullPath))
+	fileItem := &SyncFileCache{
+		ParentId:   parentId,
+		FileName:   stat.Name(),
+		FileType:   v115open.TypeFile,
+		FileSize:   stat.Size(),
+		MTime:      stat.ModTime().Unix(),
+		SourceType: models.SourceTypeLocal,
+	}
+	if stat.IsDir() {
+		fileItem.FileType = v115open.TypeDir
+	} else {
+		fileItem.IsVideo = d.s.IsValidVideoExt(fileItem.FileName)
+		fileItem.IsMeta = d.s.IsValidMetaExt(fileItem.FileName)
+	}
+	return fileItem, nil
 }
 
 // 删除目录下的某些文件
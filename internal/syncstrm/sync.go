@@ -48,6 +48,7 @@ type SyncStrm struct {
 	Cancel       context.CancelFunc
 	FullSync     bool // 是否是全量同步
 	IsFile       bool // 是否是文件
+	DryRun       bool // 预演模式：只生成计划，不写入、不删除任何文件
 
 	// 路径队列
 	PathWorkerMax int64
@@ -69,7 +70,9 @@ type SyncStrm struct {
 	// 115 同步器
 	sync115 *Sync115
 
-	memSyncCache *MemorySyncCache // 同步缓存
+	memSyncCache syncCache // 同步缓存，大型同步路径使用磁盘缓存
+
+	plan *models.SyncPlan // 预演模式下生成的计划
 }
 
 type pathQueueItem struct {
@@ -90,6 +93,8 @@ func NewSyncStrm(account *models.Account, syncPathId uint, sourcePath, sourcePat
 		syncDriver = NewLocalDriver()
 	case models.SourceTypeBaiduPan:
 		syncDriver = NewBaiduPanDriver(account.GetBaiDuPanClient())
+	case models.SourceType123:
+		syncDriver = NewOpen123Driver(account.Get123Client())
 	}
 	pathWorkerMax := int64(models.SettingsGlobal.FileDetailThreads)
 	switch account.SourceType {
@@ -101,6 +106,8 @@ func NewSyncStrm(account *models.Account, syncPathId uint, sourcePath, sourcePat
 		pathWorkerMax = int64(models.SettingsGlobal.FileDetailThreads)
 	case models.SourceTypeBaiduPan:
 		pathWorkerMax = int64(models.SettingsGlobal.FileDetailThreads)
+	case models.SourceType123:
+		pathWorkerMax = int64(models.SettingsGlobal.FileDetailThreads)
 	}
 	if pathWorkerMax <= 1 {
 		pathWorkerMax = 2 // 最小为2，否则并发操作会出错
@@ -163,8 +170,8 @@ func NewSyncStrmFromSyncPath(syncPath *models.SyncPath) *SyncStrm {
 	}
 	// 重新load一下设置
 	models.LoadSettings()
-	if (account.SourceType == models.SourceType115 || account.SourceType == models.SourceTypeBaiduPan) && syncPath.GetStrmBaseUrl() == "" {
-		helpers.AppLogger.Errorf("115或百度网盘同步路径 %s 未配置STRM直连地址", syncPath.RemotePath)
+	if (account.SourceType == models.SourceType115 || account.SourceType == models.SourceTypeBaiduPan || account.SourceType == models.SourceType123) && syncPath.GetStrmBaseUrl() == "" {
+		helpers.AppLogger.Errorf("115、百度网盘或123云盘同步路径 %s 未配置STRM直连地址", syncPath.RemotePath)
 		return nil
 	}
 	config := SyncStrmConfig{
@@ -178,6 +185,7 @@ func NewSyncStrmFromSyncPath(syncPath *models.SyncPath) *SyncStrm {
 		DelEmptyLocalDir:      syncPath.GetDeleteDir() == 1,
 		CheckMetaMtime:        syncPath.GetCheckMetaMtime(),
 		StrmBaseUrl:           syncPath.GetStrmBaseUrl(),
+		SignExpire:            syncPath.GetSignExpire(),
 	}
 	if account.SourceType == models.SourceTypeOpenList {
 		// openlist只使用自定义的strm直连地址
@@ -199,6 +207,7 @@ func NewSyncStrmByPath(account *models.Account, sourcePath, sourcePathId string,
 		DelEmptyLocalDir:      models.SettingsGlobal.DeleteDir == 1,
 		CheckMetaMtime:        models.SettingsGlobal.CheckMetaMtime,
 		StrmBaseUrl:           models.SettingsGlobal.StrmBaseUrl,
+		SignExpire:            max(models.SettingsGlobal.SignExpire, 0),
 	}
 	return NewSyncStrm(account, 0, sourcePath, sourcePathId, targetPath, config, false, 0, isFile)
 }
@@ -216,17 +225,23 @@ func (s *SyncStrm) Stop() {
 func (s *SyncStrm) Start() error {
 	// 开始任务时先暂停下载和上传队列
 	// 关闭上传下载队列
-	models.GlobalDownloadQueue.Stop()
-	models.GlobalUploadQueue.Stop()
-	defer func() {
-		// 任务完成后启动上传下载队列
-		models.GlobalDownloadQueue.Start()
-		models.GlobalUploadQueue.Start()
-	}()
+	// 预演不会添加上传下载任务，不需要暂停队列
+	if !s.DryRun {
+		models.GlobalDownloadQueue.Stop()
+		models.GlobalUploadQueue.Stop()
+		defer func() {
+			// 任务完成后启动上传下载队列
+			models.GlobalDownloadQueue.Start()
+			models.GlobalUploadQueue.Start()
+		}()
+	}
 	atomic.StoreInt64(&s.NewMeta, 0)
 	atomic.StoreInt64(&s.NewStrm, 0)
 	atomic.StoreInt64(&s.NewUpload, 0)
 	atomic.StoreInt64(&s.TotalFile, 0)
+	// 大型同步路径使用磁盘同步缓存
+	s.initSyncCache()
+	defer s.memSyncCache.Close()
 	s.Sync.Logger.Infof("本次同步的入口目录：%s，目标目录：%s", s.SourcePath, s.TargetPath)
 	s.Sync.Logger.Infof("本次同步使用的STRM配置%+v", s.Config)
 	s.Sync.UpdateStatus(models.SyncStatusInProgress)
@@ -255,7 +270,7 @@ func (s *SyncStrm) Start() error {
 		}
 		// 创建本地根目录
 		localBaseDir := s.GetLocalBaseDir()
-		if !s.checkPathExists(localBaseDir) {
+		if !s.checkPathExists(localBaseDir) && !s.DryRun {
 			if err := os.MkdirAll(localBaseDir, 0777); err != nil {
 				reason := fmt.Sprintf("创建本地根目录失败: %s %v", localBaseDir, err)
 				s.Sync.Failed(reason)
@@ -269,7 +284,7 @@ func (s *SyncStrm) Start() error {
 			s.StartBaiduPanSync()
 		default:
 			// 如果是本地类型，先删除所有数据表中的数据
-			if s.Account.SourceType == models.SourceTypeLocal {
+			if s.Account.SourceType == models.SourceTypeLocal && !s.DryRun {
 				db.Db.Where("sync_path_id = ?", s.SyncPathId).Delete(&models.SyncFile{})
 			}
 			// 其他来源走一套逻辑
@@ -286,7 +301,7 @@ func (s *SyncStrm) Start() error {
 		default:
 		}
 		// 处理完所有路径和文件后，更新最后同步时间
-		if s.SyncPathId > 0 {
+		if s.SyncPathId > 0 && !s.DryRun {
 			syncPath := models.GetSyncPathById(s.SyncPathId)
 			if syncPath != nil {
 				syncPath.UpdateLastSync()
@@ -304,6 +319,12 @@ func (s *SyncStrm) Start() error {
 	s.Sync.NewStrm = int(s.NewStrm)
 	s.Sync.NewUpload = int(s.NewUpload)
 	s.Sync.Total = int(s.TotalFile)
+	if s.DryRun {
+		// 预演只保存计划，不更新同步路径、不刷新媒体库、不处理SyncFile表
+		s.Sync.SetPlan(s.plan)
+		s.Sync.Complete(s.Account.SourceType)
+		return nil
+	}
 	s.Sync.Complete(s.Account.SourceType)
 	// 如果有syncpathid，则更新最后同步时间
 	if !s.TmpSyncPath {
@@ -313,37 +334,45 @@ func (s *SyncStrm) Start() error {
 		}
 		db.Db.Model(&models.SyncPath{}).Where("id = ?", s.SyncPathId).Update("last_sync_at", s.Sync.FinishAt)
 		// 触发刷新Emby媒体库，延迟30s，等待文件下载完成
-		go func() {
-			time.Sleep(30 * time.Second)
-			if s.NewMeta > 0 || s.NewStrm > 0 {
-				s.Sync.Logger.Info("有新的元数据文件或STRM文件，触发刷新Emby媒体库，是否可以刷新受到 Emby设置 - STRM同步完成后刷新媒体库 选项是否开启的影响")
-				models.RefreshEmbyLibraryBySyncPathId(s.SyncPathId)
-
-			}
-			if s.NewStrm > 0 {
-				s.Sync.Logger.Info("准备触发关联的刮削任务")
-				syncPath := models.GetSyncPathById(s.SyncPathId)
-				scrapePathIds := syncPath.GetScrapePathIds()
-				if len(scrapePathIds) > 0 {
-					// 发送异步消息，防止循环引用
-					helpers.Publish(helpers.StrmSyncCompleteEvent, scrapePathIds)
-				} else {
-					s.Sync.Logger.Info("关联的刮削目录为空，跳过触发刮削任务")
-				}
-			} else {
-				s.Sync.Logger.Info("没有新的strm生成，跳过关联的刮削任务")
-			}
-		}()
-		// 处理差异
-		go func() {
-			s.Sync.Logger.Info("115路径和文件同步完成，开始处理SyncFile表和临时表的数据差异")
-			s.handleTempTableDiff()
-			s.Sync.Logger.Info("完成差异比对，并更新了SyncFile表，任务彻底完成")
-		}()
+		go s.triggerAfterSync()
+		// 处理差异，必须在返回之前完成：返回时会关闭同步缓存，磁盘缓存关闭后无法再读取
+		// 同一个同步路径的下一次同步也会清空磁盘缓存，放在后台执行会读到空缓存而删除所有SyncFile记录
+		s.Sync.Logger.Info("路径和文件同步完成，开始处理SyncFile表和同步缓存的数据差异")
+		if err := s.handleTempTableDiff(); err != nil {
+			s.Sync.Logger.Errorf("处理SyncFile表和同步缓存的数据差异失败: %v", err)
+			return err
+		}
+		s.Sync.Logger.Info("完成差异比对，并更新了SyncFile表，任务彻底完成")
 	}
 	return nil
 }
 
+// 同步完成后刷新Emby媒体库并触发关联的刮削任务，延迟30s，等待文件下载完成
+func (s *SyncStrm) triggerAfterSync() {
+	time.Sleep(30 * time.Second)
+	if s.NewMeta > 0 || s.NewStrm > 0 {
+		s.Sync.Logger.Info("有新的元数据文件或STRM文件，触发刷新Emby媒体库，是否可以刷新受到 Emby设置 - STRM同步完成后刷新媒体库 选项是否开启的影响")
+		models.RefreshEmbyLibraryBySyncPathId(s.SyncPathId)
+
+	}
+	if s.NewStrm > 0 {
+		s.Sync.Logger.Info("准备触发关联的刮削任务")
+		syncPath := models.GetSyncPathById(s.SyncPathId)
+		if syncPath == nil {
+			return
+		}
+		scrapePathIds := syncPath.GetScrapePathIds()
+		if len(scrapePathIds) > 0 {
+			// 发送异步消息，防止循环引用
+			helpers.Publish(helpers.StrmSyncCompleteEvent, scrapePathIds)
+		} else {
+			s.Sync.Logger.Info("关联的刮削目录为空，跳过触发刮削任务")
+		}
+	} else {
+		s.Sync.Logger.Info("没有新的strm生成，跳过关联的刮削任务")
+	}
+}
+
 // 处理网盘文件，生成strm或者添加下载任务
 func (s *SyncStrm) processNetFile(file *SyncFileCache) error {
 	// 1. 检查对应的本地文件是否存在
@@ -357,13 +386,15 @@ func (s *SyncStrm) processNetFile(file *SyncFileCache) error {
 		if err == nil {
 			// 如果SyncFiles存在，检查是否需要重命名，所在目录必须相同才可以重命名，否则只能走删除重建流程
 			if existingFile.FileName != file.FileName && existingFile.Path == file.Path {
-				// 需要重命名
-				err := os.Rename(existingFile.LocalFilePath, localFilePath)
-				if err != nil {
-					// 只记录日志，不报错（因为重命名失败不影响后续处理，会自动转入删除、重建流程）
-					s.Sync.Logger.Errorf("重命名失败 %s => %s: %w", existingFile.LocalFilePath, localFilePath, err)
-				} else {
-					s.Sync.Logger.Infof("重命名成功 %s => %s", existingFile.LocalFilePath, localFilePath)
+				// 需要重命名，预演只记录到计划中
+				if !s.planRename(existingFile.LocalFilePath, localFilePath, file.GetFullRemotePath()) {
+					err := os.Rename(existingFile.LocalFilePath, localFilePath)
+					if err != nil {
+						// 只记录日志，不报错（因为重命名失败不影响后续处理，会自动转入删除、重建流程）
+						s.Sync.Logger.Errorf("重命名失败 %s => %s: %w", existingFile.LocalFilePath, localFilePath, err)
+					} else {
+						s.Sync.Logger.Infof("重命名成功 %s => %s", existingFile.LocalFilePath, localFilePath)
+					}
 				}
 			}
 		}
@@ -397,6 +428,17 @@ func (s *SyncStrm) processNetFile(file *SyncFileCache) error {
 	return nil
 }
 
+// 从同步缓存中删除网盘已经不存在的目录，磁盘缓存同时删除目录索引，下次同步不再用它补全路径
+func (s *SyncStrm) deleteCachedDir(fileId string) error {
+	if err := s.memSyncCache.DeleteByFileId(fileId); err != nil {
+		return err
+	}
+	if diskCache, ok := s.memSyncCache.(*DiskSyncCache); ok {
+		return diskCache.DeleteDir(fileId)
+	}
+	return nil
+}
+
 // 添加下载任务（不实际添加，先记录起来，任务完成后，统一处理）
 func (s *SyncStrm) AddDownloadTaskTemp(file *SyncFileCache) {
 	file.NeedDownload = true
@@ -431,11 +473,13 @@ func (s *SyncStrm) AddDownloadTaskFromMemCache() {
 		offset += limit
 	}
 	// 遍历内存同步缓存的下载索引
-	s.memSyncCache.mu.RLock()
-	for _, file := range s.memSyncCache.downloadIndex {
+	s.memSyncCache.RangeDownload(func(file *SyncFileCache) bool {
 		if _, exists := existingDownloads[file.GetPickCode(s.Account.BaseUrl)]; exists {
 			// 已经存在下载任务，跳过
-			continue
+			return true
+		}
+		if s.planOnly(models.SyncPlanActionDownload, file.GetLocalFilePath(s.TargetPath, s.SourcePath), file.GetFullRemotePath()) {
+			return true
 		}
 		// 添加下载任务
 		err := models.AddDownloadTaskFromSyncFile(file.GetSyncFile(s, s.Account.BaseUrl))
@@ -443,8 +487,8 @@ func (s *SyncStrm) AddDownloadTaskFromMemCache() {
 			s.Sync.Logger.Infof("添加下载任务成功: %s=>%s", file.Path+"/"+file.FileName, file.GetLocalFilePath(s.TargetPath, s.SourcePath))
 			atomic.AddInt64(&s.NewMeta, 1)
 		}
-	}
-	s.memSyncCache.mu.RUnlock()
+		return true
+	})
 }
 
 // 对比本地文件和临时表中的文件
@@ -484,6 +528,9 @@ func (s *SyncStrm) compareLocalFilesWithTempTable() error {
 							return nil
 						}
 						if len(dirEntries) == 0 {
+							if s.planOnly(models.SyncPlanActionDeleteLocal, path, "") {
+								return nil
+							}
 							os.Remove(path)
 							s.Sync.Logger.Infof("删除空目录 %s", path)
 						} else {
@@ -565,6 +612,10 @@ func (s *SyncStrm) compareLocalFilesWithTempTable() error {
 								s.RemoveFileAndCheckDirEmtry(path)
 								return nil
 							} else {
+								if s.planOnly(models.SyncPlanActionUpload, path, s.planRemotePath(path)) {
+									// 预演不创建网盘目录
+									return nil
+								}
 								// 递归创建目录, 调用不同的driver
 								parentPathId, remotePath, err = s.SyncDriver.CreateDirRecursively(s.Context, parentDir)
 								if err != nil {
@@ -606,6 +657,9 @@ func (s *SyncStrm) compareLocalFilesWithTempTable() error {
 						} else {
 							db115File.FileId = filepath.Join(sourceRootPath, db115File.Path, db115File.FileName)
 						}
+						if s.planOnly(models.SyncPlanActionUpload, path, s.planRemotePath(path)) {
+							return nil
+						}
 						models.AddUploadTaskFromSyncFile(db115File)
 						atomic.AddInt64(&s.NewUpload, 1)
 						return nil
@@ -622,6 +676,9 @@ func (s *SyncStrm) compareLocalFilesWithTempTable() error {
 							s.RemoveFileAndCheckDirEmtry(path)
 
 							// 2. 添加下载任务
+							if s.planOnly(models.SyncPlanActionDownload, path, existsFile.GetFullRemotePath()) {
+								return nil
+							}
 							models.AddDownloadTaskFromSyncFile(existsFile.GetSyncFile(s, s.Account.BaseUrl))
 							return nil
 						}
@@ -629,6 +686,10 @@ func (s *SyncStrm) compareLocalFilesWithTempTable() error {
 						if localMTime > existsFile.MTime && s.Config.NetNotFoundFileAction == models.SyncTreeItemMetaActionUpload {
 							// 本地比网盘新，需要删除网盘旧文件并上传新文件
 							s.Sync.Logger.Infof("本地元数据文件 %s 由于修改时间比网盘新 %d > %d 所以需要上传", path, localMTime, existsFile.MTime)
+							if s.planOnly(models.SyncPlanActionDeleteRemote, "", existsFile.GetFullRemotePath()) {
+								s.planOnly(models.SyncPlanActionUpload, path, existsFile.GetFullRemotePath())
+								return nil
+							}
 							// 1. 删除网盘旧文件
 							err := s.SyncDriver.DeleteFile(s.Context, existsFile.ParentId, []string{existsFile.GetFileId()})
 							if err != nil {
@@ -679,6 +740,10 @@ func (s *SyncStrm) handleTempTableDiff() error {
 			if syncFileCache == nil {
 				// 同步缓存中没有该文件，删除SyncFile记录
 				waitDeleteIds = append(waitDeleteIds, file.ID)
+				if file.FileType == v115open.TypeDir {
+					// 网盘已经删除的目录也从目录索引中删除
+					s.deleteCachedDir(file.FileId)
+				}
 				s.Sync.Logger.Infof("SyncFile表数据 ID=%d 在同步缓存中不存在，已标记为删除", file.ID)
 			} else {
 				// 双方都有，更新SyncFile记录
@@ -753,19 +818,19 @@ func (s *SyncStrm) handleTempTableDiff() error {
 	waitDeleteIds = nil // 清空切片
 	// 然后插入同步缓存中剩余的新增数据
 	// 不会并发执行该方法，所以可以直接读取
-	fileItems := s.memSyncCache.GetAllFile()
-	s.Sync.Logger.Infof("内存同步缓存中共有 %d 条新增数据需要插入", len(fileItems))
-	if len(fileItems) == 0 {
+	newCount := s.memSyncCache.Count()
+	s.Sync.Logger.Infof("内存同步缓存中共有 %d 条新增数据需要插入", newCount)
+	if newCount == 0 {
 		// s.Sync.Logger.Info("内存同步缓存数据全部处理完毕")
 		return nil
 	}
 	i = 0
-	for _, file := range fileItems {
+	s.memSyncCache.Range(func(file *SyncFileCache) bool {
 		syncFile := file.GetSyncFile(s, s.Account.BaseUrl)
 		err := db.Db.Save(syncFile).Error
 		if err != nil {
 			s.Sync.Logger.Errorf("插入SyncFile表数据失败 FileID=%s: %v", file.GetFileId(), err)
-			continue
+			return true
 		}
 		// s.Sync.Logger.Infof("插入SyncFile表数据成功 FileID=%s", file.GetFileId())
 		// 插入成功后，从同步缓存中移除该记录
@@ -776,8 +841,8 @@ func (s *SyncStrm) handleTempTableDiff() error {
 		} else {
 			i++
 		}
-
-	}
+		return true
+	})
 	s.Sync.Logger.Infof("已插入所有新增文件记录，内存同步缓存中剩余 %d 条数据", s.memSyncCache.Count())
 	return nil
 }
@@ -47,6 +47,32 @@ func (s *SyncStrm) Start115Sync() {
 	s.Sync.Total = int(total)
 	// 更新回数据库
 	s.Sync.UpdateTotal()
+	// 非全量同步优先使用增量同步，只查询上次同步后变化的文件和目录
+	if s.can115Incremental(existsPathesCount) {
+		fallback, err := s.Start115IncrementalSync(s.get115IncrementalSince())
+		if err != nil {
+			s.Sync.Logger.Errorf("115增量同步失败: %v", err)
+			s.PathErrChan <- err
+			return
+		}
+		if !fallback {
+			s.Sync.Logger.Infof("启动115路径调度器，补全新文件的路径")
+			if patherr := s.Start115PathDispathcer(); patherr != nil {
+				s.Sync.Logger.Errorf("启动115路径调度器失败: %v", patherr)
+				s.PathErrChan <- patherr
+				return
+			}
+			s.Sync.Logger.Infof("115增量同步完成")
+			return
+		}
+		// 改为完整同步，清空增量同步加载的数据，重新读取已存在的路径
+		s.memSyncCache.Clear()
+		s.sync115 = &Sync115{
+			existsPathes:  sync.Map{},
+			excludePathId: sync.Map{},
+		}
+		existsPathesCount = s.GetExistsPath()
+	}
 	// 如果没有路径缓存或者全量同步，则先预取
 	if existsPathesCount == 0 || s.FullSync {
 		// 如果没有已存在的路径，则开始预取两层目录，入库，加入existsPathes
@@ -80,6 +106,10 @@ func (s *SyncStrm) Start115Sync() {
 
 // 将已存在的路径全部读取到内存中
 func (s *SyncStrm) GetExistsPath() int64 {
+	// 磁盘同步缓存中保留了上次同步的目录索引，直接使用（上次同步中断也可以用）
+	if diskCache, ok := s.memSyncCache.(*DiskSyncCache); ok && diskCache.DirCount() > 0 {
+		return s.GetExistsPathFromDiskCache(diskCache)
+	}
 	// 从数据库中查询所有已存在的路径
 	var pathes []models.SyncFile
 	offset := 0
@@ -129,3 +159,25 @@ func (s *SyncStrm) GetExistsPath() int64 {
 	}
 	return existsPathesCount
 }
+
+// 从磁盘同步缓存的目录索引中读取已存在的路径
+func (s *SyncStrm) GetExistsPathFromDiskCache(diskCache *DiskSyncCache) int64 {
+	var existsPathesCount int64 = 0
+	diskCache.RangeDirs(func(dir *SyncFileCache) bool {
+		// 如果名字被排除，则不加入
+		if s.IsExcludeName(dir.FileName) {
+			s.sync115.excludePathId.Store(dir.FileId, true)
+			return true
+		}
+		pathStr := filepath.ToSlash(filepath.Join(dir.Path, dir.FileName))
+		s.sync115.existsPathes.Store(dir.FileId, pathStr)
+		existsPathesCount++
+		// 写入同步缓存
+		dir.SourceType = models.SourceType115
+		dir.GetLocalFilePath(s.TargetPath, s.SourcePath) // 生成本地路径缓存
+		s.memSyncCache.Insert(dir)
+		return true
+	})
+	s.Sync.Logger.Infof("从磁盘同步缓存读取已存在路径 %d 个", existsPathesCount)
+	return existsPathesCount
+}
@@ -24,13 +24,13 @@ func (s *SyncStrm) Start115PathDispathcer() error {
 		s.Sync.Logger.Infof("同步缓存中没有文件记录需要处理")
 		return nil
 	}
-	fileItems := s.memSyncCache.GetAllFile()
-	for _, item := range fileItems {
+	s.memSyncCache.Range(func(item *SyncFileCache) bool {
 		if item.FileType == v115open.TypeDir || item.Path != "" {
-			continue
+			return true
 		}
 		parentIds[item.ParentId] = true
-	}
+		return true
+	})
 	// 将路径ID加入任务队列
 	s.Sync.Logger.Infof("开始路径补全任务，共有 %d 个需要补全路径的目录", len(parentIds))
 	for pathId := range parentIds {
@@ -169,8 +169,14 @@ func (s *SyncStrm) handelTempFileByPathId(pathId string) error {
 		return nil
 	}
 	for _, file := range files {
-		// 更新文件路径
-		file.GetLocalFilePath(s.TargetPath, s.SourcePath)
+		// 更新文件路径，磁盘缓存返回的是副本，补全后写回
+		if file.LocalFilePath == "" {
+			file.GetLocalFilePath(s.TargetPath, s.SourcePath)
+			if err := s.memSyncCache.Update(file); err != nil {
+				s.Sync.Logger.Errorf("更新同步缓存中文件 %s 的本地路径失败: %v", file.FileId, err)
+				return err
+			}
+		}
 		// s.Sync.Logger.Infof("文件ID %s 路径 %s 本地路径 %s 路径已补全，开始处理文件", file.FileId, file.Path, file.LocalFilePath)
 		// 开始处理文件
 		s.processNetFile(file)
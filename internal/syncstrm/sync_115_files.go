@@ -61,59 +61,67 @@ func (s *SyncStrm) process115FilePage(ctx context.Context, page, limit int) erro
 	}
 	// 处理查询到的文件
 	for _, file := range files {
-		// s.Sync.Logger.Infof("文件 %s => %s 开始处理", file.FileId, file.FileName)
-		// 检查文件是否被排除
-		if s.IsExcludeName(file.FileName) {
-			s.Sync.Logger.Warnf("文件 %s 被排除", file.FileName)
-			continue
-		}
-		// 检查目录ID是否被排除
-		if _, excluded := s.sync115.excludePathId.Load(file.Pid); excluded {
-			s.Sync.Logger.Warnf("文件 %s 的父目录ID %s 被排除", file.FileName, file.Pid)
-			continue
-		}
-		// 处理文件
-		// 生成一个临时的SyncFile
-		syncFile := SyncFileCache{
-			Path:       "",
-			FileId:     file.FileId,
-			FileName:   file.FileName,
-			SourceType: models.SourceType115,
-			ParentId:   file.Pid,
-			FileSize:   file.FileSize,
-			FileType:   file.FileCategory,
-			PickCode:   file.PickCode,
-			Sha1:       file.Sha1,
-			MTime:      file.Ptime,
-			ThumbUrl:   file.Thumbnail,
-		}
-		// 验证文件本身，然后入临时表
-		if !s.ValidFile(&syncFile) {
-			continue
-		}
-		if parentPath, ok := s.sync115.existsPathes.Load(file.Pid); ok {
-			syncFile.Path = parentPath.(string)
-			// s.Sync.Logger.Infof("文件 %s 的父路径已存在，路径为 %s", file.FileName, syncFile.Path)
-			syncFile.GetLocalFilePath(s.TargetPath, s.SourcePath)
-			// 检查是否被排除
-			if s.IsExcludePath(syncFile.Path) {
-				s.Sync.Logger.Warnf("文件 %s 的路径 %s 中有排除项，被排除", file.FileName, syncFile.LocalFilePath)
-				continue
-			}
-		}
-		// 放入同步缓存
-		err := s.memSyncCache.Insert(&syncFile)
-		if err != nil {
-			s.Sync.Logger.Errorf("文件 %s => %s 插入同步缓存失败: %v", syncFile.FileId, syncFile.FileName, err)
+		if err := s.process115File(file); err != nil {
 			return err
 		}
-		// s.Sync.Logger.Infof("文件 %s => %s 插入同步缓存成功, 路径 %s", syncFile.FileId, syncFile.FileName, syncFile.LocalFilePath)
-		// 如果路径完整，直接处理文件
-		if syncFile.LocalFilePath != "" {
-			s.processNetFile(&syncFile)
-		}
-		// s.Sync.Logger.Infof("文件 %s => %s 处理完成", syncFile.FileId, syncFile.FileName)
 	}
 	s.Sync.Logger.Infof("文件处理器处理完成offset=%d, limit=%d，共处理 %d 个文件", offset, limit, len(files))
 	return nil
 }
+
+// 处理一个115文件，放入同步缓存，路径完整的直接生成STRM或者加入下载
+func (s *SyncStrm) process115File(file v115open.File) error {
+	// s.Sync.Logger.Infof("文件 %s => %s 开始处理", file.FileId, file.FileName)
+	// 检查文件是否被排除
+	if s.IsExcludeName(file.FileName) {
+		s.Sync.Logger.Warnf("文件 %s 被排除", file.FileName)
+		return nil
+	}
+	// 检查目录ID是否被排除
+	if _, excluded := s.sync115.excludePathId.Load(file.Pid); excluded {
+		s.Sync.Logger.Warnf("文件 %s 的父目录ID %s 被排除", file.FileName, file.Pid)
+		return nil
+	}
+	// 处理文件
+	// 生成一个临时的SyncFile
+	syncFile := SyncFileCache{
+		Path:       "",
+		FileId:     file.FileId,
+		FileName:   file.FileName,
+		SourceType: models.SourceType115,
+		ParentId:   file.Pid,
+		FileSize:   file.FileSize,
+		FileType:   file.FileCategory,
+		PickCode:   file.PickCode,
+		Sha1:       file.Sha1,
+		MTime:      file.Ptime,
+		ThumbUrl:   file.Thumbnail,
+	}
+	// 验证文件本身，然后入临时表
+	if !s.ValidFile(&syncFile) {
+		return nil
+	}
+	if parentPath, ok := s.sync115.existsPathes.Load(file.Pid); ok {
+		syncFile.Path = parentPath.(string)
+		// s.Sync.Logger.Infof("文件 %s 的父路径已存在，路径为 %s", file.FileName, syncFile.Path)
+		syncFile.GetLocalFilePath(s.TargetPath, s.SourcePath)
+		// 检查是否被排除
+		if s.IsExcludePath(syncFile.Path) {
+			s.Sync.Logger.Warnf("文件 %s 的路径 %s 中有排除项，被排除", file.FileName, syncFile.LocalFilePath)
+			return nil
+		}
+	}
+	// 放入同步缓存
+	err := s.memSyncCache.Insert(&syncFile)
+	if err != nil {
+		s.Sync.Logger.Errorf("文件 %s => %s 插入同步缓存失败: %v", syncFile.FileId, syncFile.FileName, err)
+		return err
+	}
+	// s.Sync.Logger.Infof("文件 %s => %s 插入同步缓存成功, 路径 %s", syncFile.FileId, syncFile.FileName, syncFile.LocalFilePath)
+	// 如果路径完整，直接处理文件
+	if syncFile.LocalFilePath != "" {
+		s.processNetFile(&syncFile)
+	}
+	// s.Sync.Logger.Infof("文件 %s => %s 处理完成", syncFile.FileId, syncFile.FileName)
+	return nil
+}
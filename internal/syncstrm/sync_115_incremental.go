@@ -0,0 +1,340 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/v115open"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 支持按修改时间倒序查询文件列表的驱动，目前只有115开放平台驱动实现
+type utimeListDriver interface {
+	GetFilesByPathIdOrderByUtime(ctx context.Context, pathId string, showCur bool, offset, limit int) ([]v115open.File, error)
+}
+
+// 是否可以使用115增量同步：只有同步路径的非全量同步，且上次同步留下了目录记录才可以
+func (s *SyncStrm) can115Incremental(existsPathesCount int64) bool {
+	if _, ok := s.SyncDriver.(utimeListDriver); !ok {
+		return false
+	}
+	return !s.TmpSyncPath && !s.FullSync && s.LastSyncAt > 0 && existsPathesCount > 0
+}
+
+// 增量同步的起始时间
+// 使用上次完成的同步的开始时间，保证上次同步过程中发生的修改也能查到
+func (s *SyncStrm) get115IncrementalSince() int64 {
+	since := s.LastSyncAt
+	if last := models.GetLastCompletedSyncByPathId(s.SyncPathId, s.Sync.ID); last != nil && last.CreatedAt > 0 && last.CreatedAt < since {
+		since = last.CreatedAt
+	}
+	return since
+}
+
+// 115增量同步
+// 1. 把上次同步的文件从SyncFile表加载到同步缓存，作为本次同步的基础（目录已经由GetExistsPath加载）
+// 2. 按修改时间倒序查询来源目录下所有修改时间晚于since的文件和目录，查到更早的就停止
+// 3. 新增或修改的文件直接处理；新目录查询其下所有文件；修改过的目录重新列出直属子项，和同步缓存对比，找出删除和移入移出的文件
+// 4. 目录被重命名或移动时无法只更新变化的部分，返回fallback=true，由调用方改为完整同步
+// 路径不完整的文件由后面的路径调度器补全
+func (s *SyncStrm) Start115IncrementalSync(since int64) (fallback bool, err error) {
+	s.Sync.Logger.Infof("开始115增量同步，查询修改时间晚于 %s 的文件和目录", time.Unix(since, 0).Format("2006-01-02 15:04:05"))
+	if err := s.load115SyncFilesToCache(); err != nil {
+		return false, err
+	}
+	changed, err := s.list115Changed(since)
+	if err != nil {
+		return false, err
+	}
+	s.Sync.Logger.Infof("共有 %d 个文件和目录在上次同步后发生变化", len(changed))
+	processed := make(map[string]bool) // 已经处理过的文件ID
+	newDirIds := make(map[string]bool)
+	// 来源目录本身不在列表中，它的直属子项总是需要对比
+	reconcileDirIds := []string{s.SourcePathId}
+	for _, dir := range changed {
+		if dir.FileCategory != v115open.TypeDir {
+			continue
+		}
+		if dir.Aid != "1" {
+			// 回收站中的目录，由父目录对比时删除
+			continue
+		}
+		cached, _ := s.memSyncCache.GetByFileId(dir.FileId)
+		if cached == nil {
+			newDirIds[dir.FileId] = true
+			continue
+		}
+		if s.is115DirMoved(cached, dir) {
+			s.Sync.Logger.Infof("目录 %s => %s 被重命名或移动，增量同步无法处理，改为完整同步", dir.FileId, dir.FileName)
+			return true, nil
+		}
+		reconcileDirIds = append(reconcileDirIds, dir.FileId)
+	}
+	// 新目录查询其下所有文件（移入的目录中文件的修改时间不会变化）
+	for dirId := range newDirIds {
+		if err := s.process115NewDir(dirId, newDirIds, processed); err != nil {
+			return false, err
+		}
+	}
+	// 处理新增或修改的文件
+	for _, file := range changed {
+		if file.FileCategory == v115open.TypeDir || processed[file.FileId] {
+			continue
+		}
+		processed[file.FileId] = true
+		if err := s.upsert115File(file); err != nil {
+			return false, err
+		}
+	}
+	// 对比修改过的目录，删除已经不存在的文件和目录，加入移入的文件
+	for _, dirId := range reconcileDirIds {
+		moved, err := s.reconcile115Dir(dirId, newDirIds, processed)
+		if err != nil {
+			return false, err
+		}
+		if moved {
+			return true, nil
+		}
+	}
+	s.Sync.Logger.Infof("115增量同步完成，共对比了 %d 个目录", len(reconcileDirIds))
+	return false, nil
+}
+
+// 把上次同步的文件加载到同步缓存
+// 按主键分批读取，每批通过syncCache写入，大型同步路径使用磁盘缓存时不会把所有记录放在内存中
+func (s *SyncStrm) load115SyncFilesToCache() error {
+	var total int64
+	var batch []models.SyncFile
+	err := db.Db.Where("sync_path_id = ? AND file_type = ?", s.SyncPathId, v115open.TypeFile).FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+		files := make([]*SyncFileCache, 0, len(batch))
+		for _, item := range batch {
+			files = append(files, &SyncFileCache{
+				FileId:        item.FileId,
+				ParentId:      item.ParentId,
+				FileType:      item.FileType,
+				FileName:      item.FileName,
+				Path:          item.Path,
+				LocalFilePath: item.LocalFilePath,
+				FileSize:      item.FileSize,
+				MTime:         item.MTime,
+				PickCode:      item.PickCode,
+				IsVideo:       item.IsVideo,
+				IsMeta:        item.IsMeta,
+				Sha1:          item.Sha1,
+				ThumbUrl:      item.ThumbUrl,
+				SourceType:    models.SourceType115,
+			})
+		}
+		if err := s.memSyncCache.BatchInsert(files); err != nil {
+			return err
+		}
+		total += int64(len(files))
+		return nil
+	}).Error
+	if err != nil {
+		s.Sync.Logger.Errorf("从数据库加载上次同步的文件失败，已加载 %d 个，错误: %v", total, err)
+		return err
+	}
+	s.Sync.Logger.Infof("已从数据库加载上次同步的 %d 个文件", total)
+	return nil
+}
+
+// 115驱动按修改时间倒序查询，showCur=false时包含所有子目录
+func (s *SyncStrm) list115ByUtime(pathId string, showCur bool, offset, limit int) ([]v115open.File, error) {
+	driver, ok := s.SyncDriver.(utimeListDriver)
+	if !ok {
+		return nil, errors.New("同步驱动不支持按修改时间查询文件列表")
+	}
+	return driver.GetFilesByPathIdOrderByUtime(s.Context, pathId, showCur, offset, limit)
+}
+
+// 按修改时间倒序查询所有修改时间晚于since的文件和目录
+func (s *SyncStrm) list115Changed(since int64) ([]v115open.File, error) {
+	limit := models.GetFileListPageSize()
+	offset := 0
+	changed := make([]v115open.File, 0)
+	for {
+		select {
+		case <-s.Context.Done():
+			return nil, s.Context.Err()
+		default:
+		}
+		files, err := s.list115ByUtime(s.SourcePathId, false, offset, limit)
+		if err != nil {
+			s.Sync.Logger.Errorf("按修改时间查询115网盘文件列表失败: 目录ID %s, offset=%d, limit=%d, %v", s.SourcePathId, offset, limit, err)
+			return nil, err
+		}
+		for _, file := range files {
+			if file.Utime < since {
+				return changed, nil
+			}
+			changed = append(changed, file)
+		}
+		if len(files) < limit {
+			return changed, nil
+		}
+		offset += limit
+	}
+}
+
+// 目录是否被重命名或者移动
+func (s *SyncStrm) is115DirMoved(cached *SyncFileCache, dir v115open.File) bool {
+	if cached.FileName != dir.FileName {
+		return true
+	}
+	if cached.ParentId != "" {
+		return cached.ParentId != dir.Pid
+	}
+	// 路径补全生成的第一层目录没有记录ParentId，用父目录的路径判断
+	if parentPath, ok := s.sync115.existsPathes.Load(dir.Pid); ok {
+		return parentPath.(string) != cached.Path
+	}
+	return false
+}
+
+// 新增或修改的文件，先删除同步缓存中的旧记录再处理
+func (s *SyncStrm) upsert115File(file v115open.File) error {
+	if err := s.memSyncCache.DeleteByFileId(file.FileId); err != nil {
+		return err
+	}
+	if file.Aid != "1" {
+		// 已经删除到回收站
+		return nil
+	}
+	return s.process115File(file)
+}
+
+// 查询新目录下的所有文件，父目录也是新目录的跳过（父目录查询时已经包含）
+func (s *SyncStrm) process115NewDir(dirId string, newDirIds map[string]bool, processed map[string]bool) error {
+	if processed[dirId] {
+		return nil
+	}
+	processed[dirId] = true
+	if _, excluded := s.sync115.excludePathId.Load(dirId); excluded {
+		return nil
+	}
+	limit := models.GetFileListPageSize()
+	offset := 0
+	for {
+		files, err := s.SyncDriver.GetFilesByPathId(s.Context, dirId, offset, limit)
+		if err != nil {
+			s.Sync.Logger.Errorf("获取115网盘新目录的文件列表失败: 目录ID %s, offset=%d, limit=%d, %v", dirId, offset, limit, err)
+			return err
+		}
+		for _, file := range files {
+			if newDirIds[file.Pid] && file.Pid != dirId {
+				// 子目录也是新目录，标记为已处理
+				processed[file.Pid] = true
+			}
+			if processed[file.FileId] {
+				continue
+			}
+			processed[file.FileId] = true
+			if err := s.upsert115File(file); err != nil {
+				return err
+			}
+		}
+		if len(files) < limit {
+			return nil
+		}
+		offset += limit
+	}
+}
+
+// 对比目录的直属子项和同步缓存，返回moved=true表示发现了重命名或移动的子目录
+func (s *SyncStrm) reconcile115Dir(dirId string, newDirIds map[string]bool, processed map[string]bool) (moved bool, err error) {
+	if _, excluded := s.sync115.excludePathId.Load(dirId); excluded {
+		return false, nil
+	}
+	limit := models.GetFileListPageSize()
+	offset := 0
+	present := make(map[string]bool)
+	for {
+		children, err := s.list115ByUtime(dirId, true, offset, limit)
+		if err != nil {
+			s.Sync.Logger.Errorf("获取115网盘目录的直属子项失败: 目录ID %s, offset=%d, limit=%d, %v", dirId, offset, limit, err)
+			return false, err
+		}
+		for _, child := range children {
+			if child.Aid != "1" {
+				continue
+			}
+			present[child.FileId] = true
+			if child.FileCategory == v115open.TypeDir {
+				cached, _ := s.memSyncCache.GetByFileId(child.FileId)
+				if cached == nil {
+					// 移入的目录
+					newDirIds[child.FileId] = true
+					if err := s.process115NewDir(child.FileId, newDirIds, processed); err != nil {
+						return false, err
+					}
+				} else if s.is115DirMoved(cached, child) {
+					s.Sync.Logger.Infof("目录 %s => %s 被重命名或移动，增量同步无法处理，改为完整同步", child.FileId, child.FileName)
+					return true, nil
+				}
+				continue
+			}
+			if processed[child.FileId] {
+				continue
+			}
+			if cached, _ := s.memSyncCache.GetByFileId(child.FileId); cached != nil && cached.ParentId == child.Pid && cached.FileName == child.FileName {
+				continue
+			}
+			// 移入的文件或者上次同步时被跳过的文件
+			processed[child.FileId] = true
+			if err := s.upsert115File(child); err != nil {
+				return false, err
+			}
+		}
+		if len(children) < limit {
+			break
+		}
+		offset += limit
+	}
+	for _, cached := range s.get115CachedChildren(dirId) {
+		if present[cached.FileId] {
+			continue
+		}
+		s.Sync.Logger.Infof("%s 已经从网盘删除或移出来源目录", cached.GetFullRemotePath())
+		s.remove115CachedTree(cached)
+	}
+	return false, nil
+}
+
+// 同步缓存中目录的直属子项
+func (s *SyncStrm) get115CachedChildren(dirId string) []*SyncFileCache {
+	// 内存缓存返回的是内部切片，删除时会被修改，先复制一份
+	children, _ := s.memSyncCache.GetByParentId(dirId)
+	children = slices.Clone(children)
+	if dirId != s.SourcePathId {
+		return children
+	}
+	// 路径补全生成的第一层目录没有记录ParentId，按路径查找
+	rootPath := strings.Trim(s.SourcePath, "/")
+	s.memSyncCache.Range(func(file *SyncFileCache) bool {
+		if file.FileType == v115open.TypeDir && file.ParentId == "" && strings.Trim(file.Path, "/") == rootPath {
+			children = append(children, file)
+		}
+		return true
+	})
+	return children
+}
+
+// 从同步缓存中删除文件，如果是目录则删除其下所有内容
+func (s *SyncStrm) remove115CachedTree(file *SyncFileCache) {
+	if file.FileType == v115open.TypeDir {
+		children, _ := s.memSyncCache.GetByParentId(file.FileId)
+		for _, child := range slices.Clone(children) {
+			s.remove115CachedTree(child)
+		}
+		s.sync115.existsPathes.Delete(file.FileId)
+		s.deleteCachedDir(file.FileId)
+		return
+	}
+	s.memSyncCache.DeleteByFileId(file.FileId)
+}
@@ -0,0 +1,174 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/v115open"
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 模拟115网盘，只实现增量同步用到的查询
+type fake115Driver struct {
+	driverImpl
+	byUtime  []v115open.File            // 来源目录下所有文件和目录，按修改时间倒序
+	children map[string][]v115open.File // 目录的直属子项
+	files    map[string][]v115open.File // 目录下的所有文件
+}
+
+func pageFiles(files []v115open.File, offset, limit int) []v115open.File {
+	if offset >= len(files) {
+		return nil
+	}
+	return files[offset:min(offset+limit, len(files))]
+}
+
+func (d *fake115Driver) GetFilesByPathIdOrderByUtime(ctx context.Context, pathId string, showCur bool, offset, limit int) ([]v115open.File, error) {
+	if showCur {
+		return pageFiles(d.children[pathId], offset, limit), nil
+	}
+	return pageFiles(d.byUtime, offset, limit), nil
+}
+
+func (d *fake115Driver) GetFilesByPathId(ctx context.Context, rootPathId string, offset, limit int) ([]v115open.File, error) {
+	return pageFiles(d.files[rootPathId], offset, limit), nil
+}
+
+func newTest115IncrementalSync(t *testing.T, cache syncCache, driver driverImpl) *SyncStrm {
+	helpers.AppLogger = &helpers.QLogger{Logger: log.New(io.Discard, "", 0)}
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.SyncFile{}); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+	db.Db = gdb
+	// 上次同步的结果：电影/A 下有 a.nfo 和 b.nfo，电影/B 下有 c.nfo
+	records := []*models.SyncFile{
+		{SyncPathId: 1, FileId: "d1", ParentId: "0", FileName: "A", Path: "电影", FileType: v115open.TypeDir},
+		{SyncPathId: 1, FileId: "d2", ParentId: "0", FileName: "B", Path: "电影", FileType: v115open.TypeDir},
+		{SyncPathId: 1, FileId: "f1", ParentId: "d1", FileName: "a.nfo", Path: "电影/A", FileType: v115open.TypeFile, IsMeta: true},
+		{SyncPathId: 1, FileId: "f2", ParentId: "d1", FileName: "b.nfo", Path: "电影/A", FileType: v115open.TypeFile, IsMeta: true},
+		{SyncPathId: 1, FileId: "f3", ParentId: "d2", FileName: "c.nfo", Path: "电影/B", FileType: v115open.TypeFile, IsMeta: true},
+	}
+	for _, record := range records {
+		db.Db.Create(record)
+	}
+	s := &SyncStrm{
+		SyncDriver:   driver,
+		Sync:         &models.Sync{Logger: helpers.AppLogger},
+		SourcePath:   "电影",
+		SourcePathId: "0",
+		LastSyncAt:   1000,
+		TargetPath:   t.TempDir(),
+		Config:       SyncStrmConfig{MetaExt: []string{".nfo"}, EnableDownloadMeta: 1},
+		Context:      context.Background(),
+		SyncPathId:   1,
+		sync115:      &Sync115{existsPathes: sync.Map{}, excludePathId: sync.Map{}},
+		memSyncCache: cache,
+	}
+	if count := s.GetExistsPath(); count != 2 {
+		t.Fatalf("已存在路径数不正确: %d", count)
+	}
+	return s
+}
+
+func TestStart115IncrementalSync(t *testing.T) {
+	dir := func(id, pid, name string, utime int64) v115open.File {
+		return v115open.File{FileId: id, Pid: pid, FileName: name, FileCategory: v115open.TypeDir, Aid: "1", Utime: utime}
+	}
+	file := func(id, pid, name string, utime int64) v115open.File {
+		return v115open.File{FileId: id, Pid: pid, FileName: name, FileCategory: v115open.TypeFile, Aid: "1", Utime: utime}
+	}
+	// 本次网盘的状态：A 下删除了 b.nfo 新增了 d.nfo，B 被删除，移入了目录 C
+	driver := &fake115Driver{
+		byUtime: []v115open.File{
+			dir("d3", "0", "C", 2000),
+			file("f4", "d1", "d.nfo", 1500),
+			dir("d1", "0", "A", 1500),
+			file("f1", "d1", "a.nfo", 500),
+			file("f5", "d3", "e.nfo", 100),
+		},
+		children: map[string][]v115open.File{
+			"0":  {dir("d3", "0", "C", 2000), dir("d1", "0", "A", 1500)},
+			"d1": {file("f4", "d1", "d.nfo", 1500), file("f1", "d1", "a.nfo", 500)},
+		},
+		files: map[string][]v115open.File{
+			"d3": {file("f5", "d3", "e.nfo", 100)},
+		},
+	}
+	caches := map[string]func(t *testing.T) syncCache{
+		"memory": func(t *testing.T) syncCache { return NewMemorySyncCache(1) },
+		"disk": func(t *testing.T) syncCache {
+			return newTestDiskSyncCache(t, filepath.Join(t.TempDir(), "1.db"))
+		},
+	}
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			s := newTest115IncrementalSync(t, newCache(t), driver)
+			fallback, err := s.Start115IncrementalSync(1000)
+			if err != nil || fallback {
+				t.Fatalf("增量同步失败: fallback=%v %v", fallback, err)
+			}
+			for _, fileId := range []string{"f1", "f4", "f5", "d1"} {
+				if cached, _ := s.memSyncCache.GetByFileId(fileId); cached == nil {
+					t.Errorf("%s 应该在同步缓存中", fileId)
+				}
+			}
+			for _, fileId := range []string{"f2", "f3", "d2"} {
+				if cached, _ := s.memSyncCache.GetByFileId(fileId); cached != nil {
+					t.Errorf("%s 应该从同步缓存中删除", fileId)
+				}
+			}
+			if _, ok := s.sync115.existsPathes.Load("d2"); ok {
+				t.Errorf("删除的目录应该从已存在路径中移除")
+			}
+			if diskCache, ok := s.memSyncCache.(*DiskSyncCache); ok {
+				diskCache.RangeDirs(func(dir *SyncFileCache) bool {
+					if dir.FileId == "d2" {
+						t.Errorf("删除的目录应该从目录索引中移除")
+					}
+					return true
+				})
+			}
+			if cached, _ := s.memSyncCache.GetByFileId("f4"); cached == nil || cached.Path != "电影/A" {
+				t.Errorf("父目录已知的新文件应该补全路径: %+v", cached)
+			}
+		})
+	}
+}
+
+func TestStart115IncrementalSyncDirMoved(t *testing.T) {
+	// 目录 A 被重命名，只能改为完整同步
+	driver := &fake115Driver{
+		byUtime: []v115open.File{
+			{FileId: "d1", Pid: "0", FileName: "A2", FileCategory: v115open.TypeDir, Aid: "1", Utime: 1500},
+		},
+	}
+	s := newTest115IncrementalSync(t, NewMemorySyncCache(1), driver)
+	fallback, err := s.Start115IncrementalSync(1000)
+	if err != nil || !fallback {
+		t.Fatalf("目录重命名应该改为完整同步: fallback=%v %v", fallback, err)
+	}
+}
+
+func TestCan115IncrementalNeedsUtimeDriver(t *testing.T) {
+	s := &SyncStrm{LastSyncAt: 1000, SyncDriver: &fake115Driver{}}
+	if !s.can115Incremental(1) {
+		t.Errorf("支持按修改时间查询的驱动应该可以增量同步")
+	}
+	s.SyncDriver = NewLocalDriver()
+	if s.can115Incremental(1) {
+		t.Errorf("不支持按修改时间查询的驱动不能增量同步")
+	}
+}
@@ -0,0 +1,47 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/models"
+	"path/filepath"
+)
+
+// 开启预演模式，只能用于同步路径的同步
+// 预演时网盘文件照常读取，所有本地文件、网盘文件、SyncFile表以及上传下载队列的写入和删除都只记录到计划中
+func (s *SyncStrm) EnableDryRun() {
+	s.DryRun = true
+	s.plan = models.NewSyncPlan()
+	s.Sync.IsDryRun = true
+	s.Sync.Logger.Infof("本次同步为预演模式，不会修改任何文件")
+}
+
+// 预演模式下把操作记录到计划中，返回true表示调用方不能真正执行该操作
+func (s *SyncStrm) planOnly(action models.SyncPlanAction, localPath, remotePath string) bool {
+	if !s.DryRun {
+		return false
+	}
+	s.plan.Add(action, models.SyncPlanItem{LocalPath: localPath, RemotePath: remotePath})
+	s.Sync.Logger.Infof("[预演] %s: %s => %s", action, localPath, remotePath)
+	return true
+}
+
+// 预演模式下记录重命名
+func (s *SyncStrm) planRename(oldPath, newPath, remotePath string) bool {
+	if !s.DryRun {
+		return false
+	}
+	s.plan.Add(models.SyncPlanActionRenameLocal, models.SyncPlanItem{LocalPath: newPath, RemotePath: remotePath, OldPath: oldPath})
+	s.Sync.Logger.Infof("[预演] %s: %s => %s", models.SyncPlanActionRenameLocal, oldPath, newPath)
+	return true
+}
+
+// 根据本地路径推算网盘路径，只用于计划展示
+func (s *SyncStrm) planRemotePath(localPath string) string {
+	relPath, err := filepath.Rel(s.TargetPath, localPath)
+	if err != nil {
+		return ""
+	}
+	if s.Account.SourceType == models.SourceTypeLocal {
+		return filepath.ToSlash(filepath.Join(s.SourcePath, relPath))
+	}
+	return filepath.ToSlash(relPath)
+}
@@ -0,0 +1,127 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// 给STRM链接添加签名参数：kid-密钥ID，exp-过期时间，sign-签名
+// 签名关闭时不添加任何参数
+func (s *SyncStrm) AddStrmSignParams(params url.Values, pickCode string) {
+	if models.GetStrmSignMode() == models.StrmSignModeOff {
+		return
+	}
+	kid, exp, sign, err := models.SignStrm(pickCode, s.Account.UserId, s.Config.SignExpire)
+	if err != nil {
+		s.Sync.Logger.Errorf("生成STRM链接签名失败: %v", err)
+		return
+	}
+	params.Add("kid", fmt.Sprintf("%d", kid))
+	if exp > 0 {
+		params.Add("exp", fmt.Sprintf("%d", exp))
+	}
+	params.Add("sign", sign)
+}
+
+// 检查STRM链接中的签名是否依然可用，返回false表示需要重新生成STRM文件
+func (s *SyncStrm) CheckStrmSign(strmData *StrmData, st *SyncFileCache) bool {
+	filePath := filepath.Join(st.Path, st.FileName)
+	if models.GetStrmSignMode() == models.StrmSignModeOff {
+		if strmData.Sign != "" {
+			s.Sync.Logger.Warnf("文件 %s 的STRM内容含有签名，但是已关闭签名，重新生成以去掉签名", filePath)
+			return false
+		}
+		return true
+	}
+	key := models.GetActiveStrmSignKey()
+	if key == nil {
+		// 没有密钥也无法重新签名，保持原样
+		return true
+	}
+	if strmData.Sign == "" {
+		s.Sync.Logger.Warnf("文件 %s 的STRM内容缺少签名，重新生成", filePath)
+		return false
+	}
+	if strmData.Kid != key.ID {
+		s.Sync.Logger.Warnf("文件 %s 的STRM内容的签名密钥 %d 已被轮换为 %d，重新生成", filePath, strmData.Kid, key.ID)
+		return false
+	}
+	if err := models.VerifyStrmSign(strmData.PickCode, strmData.UserId, strmData.Exp, strmData.Kid, strmData.Sign); err != nil {
+		s.Sync.Logger.Warnf("文件 %s 的STRM内容的签名不可用: %v，重新生成", filePath, err)
+		return false
+	}
+	expire := s.Config.SignExpire
+	if expire <= 0 {
+		if strmData.Exp != 0 {
+			s.Sync.Logger.Warnf("文件 %s 的STRM内容含有过期时间，但是设置为永不过期，重新生成", filePath)
+			return false
+		}
+		return true
+	}
+	if strmData.Exp == 0 {
+		s.Sync.Logger.Warnf("文件 %s 的STRM内容没有过期时间，但是设置了有效期 %d 秒，重新生成", filePath, expire)
+		return false
+	}
+	// 剩余有效期不足一半或者超过设置的有效期（有效期被改短了）都重新生成
+	remain := strmData.Exp - time.Now().Unix()
+	if remain < expire/2 || remain > expire {
+		s.Sync.Logger.Infof("文件 %s 的STRM链接剩余有效期 %d 秒，重新生成", filePath, remain)
+		return false
+	}
+	return true
+}
+
+// 使用数据库中已同步的文件记录重新生成同步路径下所有STRM文件的签名
+// 不需要重新查询网盘，只会改写签名不可用的STRM文件（通过CompareStrm判断）
+func MigrateStrmSign(syncPath *models.SyncPath) (int64, error) {
+	if syncPath.SourceType != models.SourceType115 && syncPath.SourceType != models.SourceTypeBaiduPan && syncPath.SourceType != models.SourceType123 {
+		return 0, errors.New("只有115、百度网盘和123云盘的同步路径支持STRM链接签名")
+	}
+	s := NewSyncStrmFromSyncPath(syncPath)
+	if s == nil {
+		return 0, fmt.Errorf("初始化同步路径 %s 失败", syncPath.RemotePath)
+	}
+	defer models.DeleteSyncRecordById(s.Sync.ID)
+	limit := 1000
+	offset := 0
+	for {
+		files, err := models.GetFilesBySyncPathId(syncPath.ID, offset, limit)
+		if err != nil {
+			helpers.AppLogger.Errorf("查询同步路径 %s 的文件记录失败: %v", syncPath.RemotePath, err)
+			return s.NewStrm, err
+		}
+		for _, file := range files {
+			if !file.IsVideo {
+				continue
+			}
+			sf := &SyncFileCache{
+				FileId:        file.FileId,
+				ParentId:      file.ParentId,
+				FileType:      file.FileType,
+				FileName:      file.FileName,
+				Path:          file.Path,
+				LocalFilePath: file.LocalFilePath,
+				FileSize:      file.FileSize,
+				MTime:         file.MTime,
+				PickCode:      file.PickCode,
+				Sha1:          file.Sha1,
+				IsVideo:       true,
+				SourceType:    file.SourceType,
+			}
+			if err := s.ProcessStrmFile(sf); err != nil {
+				helpers.AppLogger.Errorf("重新生成STRM文件 %s 失败: %v", file.LocalFilePath, err)
+			}
+		}
+		if len(files) < limit {
+			break
+		}
+		offset += limit
+	}
+	helpers.AppLogger.Infof("同步路径 %s 的STRM签名迁移完成，共改写 %d 个STRM文件", syncPath.RemotePath, s.NewStrm)
+	return s.NewStrm, nil
+}
@@ -16,6 +16,8 @@ type StrmData struct {
 	UserId   string `json:"userid"`    // 用户ID
 	PickCode string `json:"pick_code"` // 文件ID
 	Sign     string `json:"sign"`      // 文件签名
+	Kid      uint   `json:"kid"`       // 签名密钥ID
+	Exp      int64  `json:"exp"`       // 签名过期时间，0表示永不过期
 	Path     string `json:"path"`      // 115的路径
 	BaseUrl  string `json:"base_url"`  // 115的base_url
 	UrlPath  string `json:"url_path"`  // 115的url_path
@@ -31,6 +33,13 @@ func (s *SyncStrm) ProcessStrmFile(sf *SyncFileCache) error {
 	}
 	// localFilePath := sf.GetLocalFilePath()
 	strmFullPath := sf.GetLocalFilePath(s.TargetPath, s.SourcePath)
+	action := models.SyncPlanActionCreateStrm
+	if helpers.PathExists(strmFullPath) {
+		action = models.SyncPlanActionUpdateStrm
+	}
+	if s.planOnly(action, strmFullPath, sf.GetFullRemotePath()) {
+		return nil
+	}
 	strmContent := s.SyncDriver.MakeStrmContent(sf)
 	if strmContent == "" {
 		s.Sync.Logger.Errorf("生成strm文件内容失败，可能是STRM直连地址格式不正确: %s", filepath.Join(sf.Path, sf.FileName))
@@ -96,7 +105,7 @@ func (s *SyncStrm) CompareStrm(st *SyncFileCache) int {
 			return 0
 		}
 	}
-	if st.SourceType == models.SourceType115 || st.SourceType == models.SourceTypeBaiduPan {
+	if st.SourceType == models.SourceType115 || st.SourceType == models.SourceTypeBaiduPan || st.SourceType == models.SourceType123 {
 		// 比较路径是否相同
 		if s.Config.StrmUrlNeedPath == 1 {
 			stPath := filepath.ToSlash(filepath.Join(st.Path, st.FileName))
@@ -139,6 +148,10 @@ func (s *SyncStrm) CompareStrm(st *SyncFileCache) int {
 			s.Sync.Logger.Warnf("文件 %s 的STRM内容的Url路径 %s 没有以 %s 结尾，重新生成", filepath.Join(st.Path, st.FileName), strmData.UrlPath, ext)
 			return 0
 		}
+		// 比较签名，密钥轮换、有效期变化或者快要过期都需要重新生成
+		if !s.CheckStrmSign(strmData, st) {
+			return 0
+		}
 	}
 	return 1
 }
@@ -172,6 +185,8 @@ func (s *SyncStrm) LoadDataFromStrm(strmPath string) *StrmData {
 	if sign := queryParams.Get("sign"); sign != "" {
 		strmData.Sign = sign
 	}
+	strmData.Kid = uint(helpers.StringToInt(queryParams.Get("kid")))
+	strmData.Exp = helpers.StringToInt64(queryParams.Get("exp"))
 	strmData.Path = ""
 	if path := queryParams.Get("path"); path != "" {
 		strmData.Path = path
@@ -0,0 +1,94 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/helpers"
+	"Q115-STRM/internal/models"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupSyncStartDb(t *testing.T) {
+	oldConfigDir := helpers.ConfigDir
+	helpers.ConfigDir = t.TempDir()
+	helpers.AppLogger = &helpers.QLogger{Logger: log.New(io.Discard, "", 0)}
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.SyncFile{}, &models.Sync{}, &models.SyncPath{}, &models.DbDownloadTask{}, &models.DbUploadTask{}); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+	db.Db = gdb
+	oldMinFiles := models.SettingsGlobal.DiskSyncCacheMinFiles
+	models.GlobalDownloadQueue = models.NewDq(1)
+	models.GlobalUploadQueue = models.NewUq(1)
+	t.Cleanup(func() {
+		models.GlobalDownloadQueue.Stop()
+		models.GlobalUploadQueue.Stop()
+		models.SettingsGlobal.DiskSyncCacheMinFiles = oldMinFiles
+		helpers.ConfigDir = oldConfigDir
+	})
+}
+
+// 完整执行两次本地同步，第二次使用磁盘同步缓存，同步完成后SyncFile表要和来源目录一致
+func TestSyncStrmStartWithDiskSyncCache(t *testing.T) {
+	setupSyncStartDb(t)
+	// 有记录就使用磁盘同步缓存
+	models.SettingsGlobal.DiskSyncCacheMinFiles = 1
+	sourcePath := filepath.ToSlash(t.TempDir())
+	targetPath := filepath.ToSlash(t.TempDir())
+	for _, name := range []string{"电影/A/a.mkv", "电影/B/b.mkv"} {
+		file := filepath.Join(sourcePath, name)
+		os.MkdirAll(filepath.Dir(file), 0777)
+		os.WriteFile(file, []byte("video"), 0644)
+	}
+	syncPath := &models.SyncPath{RemotePath: sourcePath, BaseCid: sourcePath, LocalPath: targetPath}
+	db.Db.Create(syncPath)
+	config := SyncStrmConfig{VideoExt: []string{".mkv"}, StrmUrlNeedPath: 2}
+	start := func() {
+		t.Helper()
+		s := NewSyncStrm(&models.Account{SourceType: models.SourceTypeLocal}, syncPath.ID, sourcePath, sourcePath, targetPath, config, false, 0, false)
+		if s == nil {
+			t.Fatal("创建同步任务失败")
+		}
+		if err := s.Start(); err != nil {
+			t.Fatalf("同步失败: %v", err)
+		}
+		if s.Sync.Status != models.SyncStatusCompleted {
+			t.Fatalf("同步没有完成: %s", s.Sync.FailReason)
+		}
+	}
+	countSyncFiles := func() int64 {
+		var total int64
+		db.Db.Model(&models.SyncFile{}).Where("sync_path_id = ? AND file_name LIKE ?", syncPath.ID, "%.mkv").Count(&total)
+		return total
+	}
+	// 第一次同步时SyncFile表为空，使用内存同步缓存
+	start()
+	if total := countSyncFiles(); total != 2 {
+		t.Fatalf("第一次同步后SyncFile记录数不正确: %d", total)
+	}
+	if HasDiskSyncCache(syncPath.ID) {
+		t.Fatalf("第一次同步不应该使用磁盘同步缓存")
+	}
+	// 删除一个文件后再次同步，这次使用磁盘同步缓存
+	os.Remove(filepath.Join(sourcePath, "电影/B/b.mkv"))
+	start()
+	if !HasDiskSyncCache(syncPath.ID) {
+		t.Fatalf("第二次同步应该使用磁盘同步缓存")
+	}
+	if total := countSyncFiles(); total != 1 {
+		t.Fatalf("第二次同步后SyncFile记录数不正确: %d", total)
+	}
+	if !helpers.PathExists(filepath.Join(targetPath, "电影/A/a.strm")) || helpers.PathExists(filepath.Join(targetPath, "电影/B/b.strm")) {
+		t.Errorf("STRM文件和来源目录不一致")
+	}
+}
@@ -46,6 +46,9 @@ func (s *SyncStrm) MakeFullLocalPath(file *models.SyncFile) string {
 }
 
 func (s *SyncStrm) RemoveFileAndCheckDirEmtry(filePath string) error {
+	if s.planOnly(models.SyncPlanActionDeleteLocal, filePath, "") {
+		return nil
+	}
 	// 删除文件
 	if err := os.Remove(filePath); err != nil {
 		return fmt.Errorf("删除文件失败: %w", err)
@@ -68,8 +71,8 @@ func (s *SyncStrm) RemoveFileAndCheckDirEmtry(filePath string) error {
 				s.Sync.Logger.Warnf("查询空目录对应的网盘记录失败:  %s %s", filePath, err.Error())
 				return nil
 			}
-			// 从同步缓存中删除
-			err = s.memSyncCache.DeleteByFileId(file.GetFileId())
+			// 从同步缓存和目录索引中删除
+			err = s.deleteCachedDir(file.GetFileId())
 			if err != nil {
 				s.Sync.Logger.Warnf("删除空目录对应的网盘记录失败:  %s %s", file.GetFileId(), err.Error())
 				return nil
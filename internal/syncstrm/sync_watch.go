@@ -0,0 +1,206 @@
+package syncstrm
+
+import (
+	"Q115-STRM/internal/db"
+	"Q115-STRM/internal/models"
+	"Q115-STRM/internal/v115open"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// 实时监控触发的增量同步，只处理发生变化的文件和目录，不和本地文件做全量对比
+// paths是来源目录下发生变化的完整路径，存在的文件生成STRM或者下载元数据，存在的目录递归处理，不存在的路径删除对应的本地文件
+func (s *SyncStrm) StartWatchBatch(paths []string) error {
+	if s.Account.SourceType != models.SourceTypeLocal {
+		reason := "只有本地目录支持实时监控同步"
+		s.Sync.Failed(reason)
+		return errors.New(reason)
+	}
+	if !s.checkPathExists(s.TargetPath) {
+		reason := fmt.Sprintf("目标路径 %s 不存在", s.TargetPath)
+		s.Sync.Failed(reason)
+		return errors.New(reason)
+	}
+	s.Sync.Logger.Infof("实时监控同步，共有 %d 个路径发生变化，来源目录：%s，目标目录：%s", len(paths), s.SourcePath, s.TargetPath)
+	s.Sync.UpdateStatus(models.SyncStatusInProgress)
+	s.Sync.UpdateSubStatus(models.SyncSubStatusProcessNetFileList)
+	for _, path := range paths {
+		select {
+		case <-s.Context.Done():
+			s.Sync.Failed(fmt.Sprintf("同步任务被取消: %v", s.Context.Err()))
+			return nil
+		default:
+		}
+		path = filepath.ToSlash(filepath.Clean(path))
+		relPath, err := filepath.Rel(s.SourcePath, path)
+		if err != nil || relPath == "." || strings.HasPrefix(relPath, "..") {
+			s.Sync.Logger.Warnf("路径 %s 不在来源目录 %s 下，跳过", path, s.SourcePath)
+			continue
+		}
+		if s.IsExcludePath(filepath.ToSlash(relPath)) {
+			s.Sync.Logger.Infof("路径 %s 被排除，跳过", path)
+			continue
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// 删除或者重命名前的路径
+				s.removeWatchPath(path)
+			} else {
+				s.Sync.Logger.Errorf("获取文件 %s 信息失败，跳过: %v", path, err)
+			}
+			continue
+		}
+		if stat.IsDir() {
+			s.syncWatchDir(path)
+		} else {
+			s.syncWatchFile(path)
+		}
+	}
+	s.Sync.Logger.Info("开始将要下载的任务添加到下载队列")
+	s.AddDownloadTaskFromMemCache()
+	s.saveWatchSyncFiles()
+	s.Sync.NewMeta = int(s.NewMeta)
+	s.Sync.NewStrm = int(s.NewStrm)
+	s.Sync.NewUpload = int(s.NewUpload)
+	s.Sync.Total = int(s.TotalFile)
+	s.Sync.Complete(s.Account.SourceType)
+	if !s.TmpSyncPath {
+		go s.triggerAfterSync()
+	}
+	return nil
+}
+
+// 同步单个文件，复用StartOther中的文件处理流程
+func (s *SyncStrm) syncWatchFile(path string) {
+	atomic.AddInt64(&s.TotalFile, 1)
+	file, err := s.SyncDriver.DetailByFileId(s.Context, path)
+	if err != nil {
+		s.Sync.Logger.Errorf("获取文件 %s 详情失败: %v", path, err)
+		return
+	}
+	if !s.ValidFile(file) {
+		return
+	}
+	file.GetLocalFilePath(s.TargetPath, s.SourcePath) // 生成本地路径缓存
+	s.memSyncCache.Insert(file)
+	if err := s.processNetFile(file); err != nil {
+		s.Sync.Logger.Errorf("处理文件 %s 失败: %v", path, err)
+	}
+}
+
+// 同步新增的目录，包括其下所有子目录和文件
+func (s *SyncStrm) syncWatchDir(path string) {
+	err := filepath.WalkDir(path, func(walkPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			s.Sync.Logger.Warnf("遍历 %s 失败，跳过: %v", walkPath, err)
+			return nil
+		}
+		if s.Context.Err() != nil {
+			return s.Context.Err()
+		}
+		walkPath = filepath.ToSlash(walkPath)
+		if s.IsExcludeName(d.Name()) {
+			s.Sync.Logger.Infof("%s 被排除，跳过它和其下所有内容", walkPath)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			s.syncWatchFile(walkPath)
+			return nil
+		}
+		dir, derr := s.SyncDriver.DetailByFileId(s.Context, walkPath)
+		if derr != nil {
+			s.Sync.Logger.Errorf("获取目录 %s 详情失败: %v", walkPath, derr)
+			return nil
+		}
+		dir.GetLocalFilePath(s.TargetPath, s.SourcePath)
+		s.memSyncCache.Insert(dir)
+		return nil
+	})
+	if err != nil {
+		s.Sync.Logger.Warnf("处理目录 %s 中断: %v", path, err)
+	}
+}
+
+// 来源路径已经不存在，删除它（如果是目录则包括其下所有内容）对应的本地文件和SyncFile记录
+func (s *SyncStrm) removeWatchPath(path string) {
+	var files []models.SyncFile
+	err := db.Db.Where("sync_path_id = ? AND (file_id = ? OR file_id LIKE ?)", s.SyncPathId, path, path+"/%").Find(&files).Error
+	if err != nil {
+		s.Sync.Logger.Errorf("查询 %s 对应的SyncFile记录失败: %v", path, err)
+		return
+	}
+	// LIKE会把路径中的_和%当成通配符，这里再精确过滤一次
+	files = slices.DeleteFunc(files, func(file models.SyncFile) bool {
+		return file.FileId != path && !strings.HasPrefix(file.FileId, path+"/")
+	})
+	if len(files) == 0 {
+		return
+	}
+	// 先处理深层的路径，目录最后处理，保证删除目录时目录已经为空
+	sort.Slice(files, func(i, j int) bool {
+		return len(files[i].LocalFilePath) > len(files[j].LocalFilePath)
+	})
+	ids := make([]uint, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, file.ID)
+		if file.LocalFilePath == "" {
+			continue
+		}
+		switch {
+		case file.FileType == v115open.TypeDir:
+			if !s.Config.DelEmptyLocalDir {
+				continue
+			}
+			if entries, rerr := os.ReadDir(file.LocalFilePath); rerr == nil && len(entries) == 0 {
+				if os.Remove(file.LocalFilePath) == nil {
+					s.Sync.Logger.Infof("删除空目录 %s", file.LocalFilePath)
+				}
+			}
+		case file.IsVideo:
+			if err := s.RemoveFileAndCheckDirEmtry(file.LocalFilePath); err != nil && !os.IsNotExist(errors.Unwrap(err)) {
+				s.Sync.Logger.Warnf("删除STRM文件 %s 失败: %v", file.LocalFilePath, err)
+			}
+		case file.IsMeta:
+			// 上传会把文件传回来源目录，监控同步只处理删除，其他设置交给完整同步
+			if s.Config.NetNotFoundFileAction != models.SyncTreeItemMetaActionDelete {
+				continue
+			}
+			if err := s.RemoveFileAndCheckDirEmtry(file.LocalFilePath); err != nil && !os.IsNotExist(errors.Unwrap(err)) {
+				s.Sync.Logger.Warnf("删除元数据文件 %s 失败: %v", file.LocalFilePath, err)
+			}
+		}
+	}
+	if err := db.Db.Where("id IN ?", ids).Delete(&models.SyncFile{}).Error; err != nil {
+		s.Sync.Logger.Errorf("删除 %s 对应的SyncFile记录失败: %v", path, err)
+		return
+	}
+	s.Sync.Logger.Infof("来源路径 %s 已删除，清理了 %d 条SyncFile记录", path, len(ids))
+}
+
+// 把本次处理的文件写入SyncFile表，已存在的记录更新
+func (s *SyncStrm) saveWatchSyncFiles() {
+	s.memSyncCache.Range(func(file *SyncFileCache) bool {
+		syncFile := file.GetSyncFile(s, s.Account.BaseUrl)
+		var existing models.SyncFile
+		if err := db.Db.Where("file_id = ? AND sync_path_id = ?", syncFile.FileId, s.SyncPathId).First(&existing).Error; err == nil {
+			syncFile.ID = existing.ID
+			syncFile.CreatedAt = existing.CreatedAt
+		}
+		if err := db.Db.Save(syncFile).Error; err != nil {
+			s.Sync.Logger.Errorf("保存SyncFile表数据失败 FileID=%s: %v", syncFile.FileId, err)
+		}
+		s.memSyncCache.DeleteByFileId(file.GetFileId())
+		return true
+	})
+}
@@ -0,0 +1,58 @@
+package tmdb
+
+import (
+	"Q115-STRM/internal/helpers"
+	"fmt"
+	"net/url"
+)
+
+// 外部ID来源
+const (
+	ExternalSourceImdb = "imdb_id"
+	ExternalSourceTvdb = "tvdb_id"
+)
+
+type FindResponse struct {
+	MovieResults []SearchMovie `json:"movie_results"`
+	TvResults    []SearchTv    `json:"tv_results"`
+}
+
+type ExternalIds struct {
+	ID     int64  `json:"id"`
+	ImdbID string `json:"imdb_id"`
+	TvdbID int64  `json:"tvdb_id"`
+}
+
+// https://api.themoviedb.org/3/find/{external_id}
+// 通过IMDB ID或者TVDB ID查询TMDB中的条目
+func (c *Client) FindByExternalId(externalId string, source string, language string) (*FindResponse, error) {
+	respResult := FindResponse{}
+	req := c.resty.R().SetMethod("GET").SetResult(&respResult)
+	resp, err := c.doRequest(fmt.Sprintf("/find/%s?external_source=%s&language=%s", url.PathEscape(externalId), source, language), req, MakeRequestConfig(2, 5, 5))
+	if err != nil {
+		helpers.TMDBLog.Errorf("通过外部ID查询失败:%+v", err)
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		helpers.TMDBLog.Errorf("通过外部ID查询失败:%s", resp.String())
+		return nil, fmt.Errorf("通过外部ID查询失败:%s", resp.String())
+	}
+	return &respResult, nil
+}
+
+// https://api.themoviedb.org/3/tv/{series_id}/external_ids
+// 查询电视剧的外部ID
+func (c *Client) GetTvExternalIds(tvId int64) (*ExternalIds, error) {
+	respResult := ExternalIds{}
+	req := c.resty.R().SetMethod("GET").SetResult(&respResult)
+	resp, err := c.doRequest(fmt.Sprintf("/tv/%d/external_ids", tvId), req, MakeRequestConfig(2, 5, 5))
+	if err != nil {
+		helpers.TMDBLog.Errorf("获取TV外部ID失败:%+v", err)
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		helpers.TMDBLog.Errorf("获取TV外部ID失败:%s", resp.String())
+		return nil, fmt.Errorf("获取TV外部ID失败:%s", resp.String())
+	}
+	return &respResult, nil
+}
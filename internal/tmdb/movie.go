@@ -52,16 +52,32 @@ type Language struct {
 // 电影详情
 type MovieDetail struct {
 	SearchMovie
-	Genres              []Genre             `json:"genres"`               // 流派
-	ProductionCompanies []ProductionCompany `json:"production_companies"` // 生产公司
-	ProductionCountries []Country           `json:"production_countries"` // 生产国家
-	Revenue             int64               `json:"revenue"`              // 票房
-	Runtime             int64               `json:"runtime"`              // 运行时间
-	SpokenLanguages     []Language          `json:"spoken_languages"`     //  口语化语言
-	Status              string              `json:"status"`               // 状态
-	Tagline             string              `json:"tagline"`              // 标语
-	Homepage            string              `json:"homepage"`             // 首页
-	ImdbID              string              `json:"imdb_id"`              // IMDB ID
+	Genres              []Genre             `json:"genres"`                // 流派
+	ProductionCompanies []ProductionCompany `json:"production_companies"`  // 生产公司
+	ProductionCountries []Country           `json:"production_countries"`  // 生产国家
+	Revenue             int64               `json:"revenue"`               // 票房
+	Runtime             int64               `json:"runtime"`               // 运行时间
+	SpokenLanguages     []Language          `json:"spoken_languages"`      //  口语化语言
+	Status              string              `json:"status"`                // 状态
+	Tagline             string              `json:"tagline"`               // 标语
+	Homepage            string              `json:"homepage"`              // 首页
+	ImdbID              string              `json:"imdb_id"`               // IMDB ID
+	BelongsToCollection *CollectionBase     `json:"belongs_to_collection"` // 所属合集，不属于合集时为nil
+}
+
+// 电影合集（系列）
+type CollectionBase struct {
+	ID           int64  `json:"id"`            // 合集ID
+	Name         string `json:"name"`          // 合集名称
+	PosterPath   string `json:"poster_path"`   // 合集海报
+	BackdropPath string `json:"backdrop_path"` // 合集背景图片
+}
+
+// 合集详情
+type CollectionDetail struct {
+	CollectionBase
+	Overview string        `json:"overview"` // 合集描述
+	Parts    []SearchMovie `json:"parts"`    // 合集包含的电影
 }
 
 type PeopleBase struct {
@@ -274,3 +290,20 @@ func (c *Client) GetReleasesDate(movieID int64) (*ReleasesDateResp, error) {
 	}
 	return &respResult, nil
 }
+
+// https://api.themoviedb.org/3/collection/{collection_id}
+// 查询合集详情，包含合集中的所有电影
+func (c *Client) GetCollectionDetail(collectionID int64, language string) (*CollectionDetail, error) {
+	respResult := CollectionDetail{}
+	req := c.resty.R().SetMethod("GET").SetResult(&respResult)
+	resp, err := c.doRequest(fmt.Sprintf("/collection/%d?language=%s", collectionID, language), req, MakeRequestConfig(2, 5, 5))
+	if err != nil {
+		helpers.TMDBLog.Errorf("获取合集详情失败:%+v", err)
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		helpers.TMDBLog.Errorf("获取合集详情失败:%s", resp.String())
+		return nil, fmt.Errorf("获取合集详情失败:%s", resp.String())
+	}
+	return &respResult, nil
+}
@@ -98,10 +98,20 @@ type SeasonDetail struct {
 	AirDate      string `json:"air_date"`      // 播出时间
 	EpisodeCount int    `json:"episode_count"` // 集数
 	// Episodes     []Episode   `json:"episodes"`      // 集列表，不收集集列表
-	PosterPath   string      `json:"poster_path"`   // 季封面图片
-	SeasonNumber int         `json:"season_number"` // 季编号
-	VoteAverage  float64     `json:"vote_average"`  // 季平均评分
-	Network      []TvNetwork `json:"network"`       // 播放平台
+	PosterPath   string          `json:"poster_path"`   // 季封面图片
+	SeasonNumber int             `json:"season_number"` // 季编号
+	VoteAverage  float64         `json:"vote_average"`  // 季平均评分
+	Network      []TvNetwork     `json:"network"`       // 播放平台
+	Episodes     []SeasonEpisode `json:"episodes"`      // 集列表，只保留统计缺集需要的字段
+}
+
+// 季详情中的集，不包含演职人员
+type SeasonEpisode struct {
+	AirDate       string `json:"air_date"`       // 播出时间
+	EpisodeNumber int    `json:"episode_number"` // 集编号
+	EpisodeType   string `json:"episode_type"`   // 集类型
+	Name          string `json:"name"`           // 集名称
+	SeasonNumber  int    `json:"season_number"`  // 季编号
 }
 
 func (c *Client) SearchTv(tvName string, year int, language string, switchLanguage bool) (*SearchTvResponse, error) {
@@ -98,8 +98,32 @@ func (d *FileDetail) GetFullPath() string {
 // showCur bool true-只显示当前目录下的列表，false-查询当前目录以及子目录内的所有列表
 // offset 和 limit 搭配实现分页，limit最大1150
 func (c *OpenClient) GetFsList(ctx context.Context, fileId string, showCur bool, onlyDir bool, showDir bool, offset int, limit int) (*FileListResp, error) {
+	return c.GetFsListOrder(ctx, fileId, showCur, onlyDir, showDir, "", false, offset, limit)
+}
+
+// 排序字段
+const (
+	FsListOrderName  = "file_name"  // 文件名
+	FsListOrderSize  = "file_size"  // 文件大小
+	FsListOrderUtime = "user_utime" // 修改时间
+	FsListOrderType  = "file_type"  // 文件类型
+)
+
+// 查询文件（夹）列表并指定排序
+// order 排序字段，为空使用115默认排序
+// asc true-升序，false-降序
+func (c *OpenClient) GetFsListOrder(ctx context.Context, fileId string, showCur bool, onlyDir bool, showDir bool, order string, asc bool, offset int, limit int) (*FileListResp, error) {
 	data := make(map[string]string)
 	data["cid"] = fileId
+	if order != "" {
+		data["o"] = order
+		data["custom_order"] = "1"
+		if asc {
+			data["asc"] = "1"
+		} else {
+			data["asc"] = "0"
+		}
+	}
 	if limit != 0 {
 		data["limit"] = helpers.IntToString(limit)
 	}
@@ -15,6 +15,7 @@ const (
 	EventScraperItemComplete  = "scraper_item_complete"
 	EventStrmSyncTaskStart    = "strm_sync_task_start"
 	EventStrmSyncTaskComplete = "strm_sync_task_complete"
+	EventPlaySessions         = "play_sessions"
 )
 
 // WSEvent WebSocket事件结构
@@ -378,6 +378,12 @@ func init() {
 func startEmby302() {
 	dataRoot := helpers.ConfigDir
 	data, err := embedFiles.ReadFile("emby302.yml")
+	// 配置目录中有emby302.yml时优先使用，可以在其中配置播放策略等
+	userConfigFile := filepath.Join(dataRoot, "emby302.yml")
+	if helpers.PathExists(userConfigFile) {
+		helpers.AppLogger.Infof("使用配置目录中的Emby302配置文件: %s", userConfigFile)
+		data, err = os.ReadFile(userConfigFile)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -388,6 +394,10 @@ func startEmby302() {
 		helpers.AppLogger.Warnf("Emby302未配置Emby地址，跳过启动emby302服务")
 		return
 	}
+	if !models.GlobalEmbyConfig.IsEmby() {
+		helpers.AppLogger.Warnf("Emby302只支持Emby，当前媒体服务器是%s，跳过启动emby302服务", models.GlobalEmbyConfig.GetServerType().DisplayName())
+		return
+	}
 	config.C.Emby.Host = models.GlobalEmbyConfig.EmbyUrl
 	config.C.Emby.EpisodesUnplayPrior = false // 关闭剧集排序
 	certFile := filepath.Join(dataRoot, "server.crt")
@@ -423,8 +433,9 @@ func initLogger() {
 }
 
 func initOthers() {
-	helpers.InitEventBus() // 初始化事件总线
-	models.LoadSettings()  // 从数据库加载设置
+	helpers.InitEventBus()    // 初始化事件总线
+	models.LoadSettings()     // 从数据库加载设置
+	models.LoadStrmSignKeys() // 加载STRM链接签名密钥
 	// 初始化GitHub访问管理器
 	github.InitManager(models.SettingsGlobal.HttpProxy)
 	helpers.AppLogger.Infof("已加载配置，准备初始化115请求队列，线程数: %d", models.SettingsGlobal.FileDetailThreads)
@@ -441,6 +452,7 @@ func initOthers() {
 	models.GetEmbyConfig()               // 加载Emby配置
 	helpers.SubscribeSync(helpers.V115TokenInValidEvent, models.HandleV115TokenInvalid)
 	helpers.SubscribeSync(helpers.SaveOpenListTokenEvent, models.HandleOpenListTokenSaveSync)
+	helpers.SubscribeSync(helpers.Save123TokenEvent, models.Handle123TokenSaveSync)
 	models.FailAllRunningSyncTasks()   // 将所有运行中的同步任务设置为失败状态
 	synccron.RefreshOAuthAccessToken() // 启动时刷新一次115的访问凭证，防止有过期的token导致同步失败
 
@@ -465,10 +477,12 @@ func initOthers() {
 	wsHub := websocket.NewEventHub()
 	websocket.GlobalEventHub = wsHub
 	go wsHub.Run()
-	synccron.InitCron()       // 初始化定时任务（包含备份定时任务）
-	synccron.InitSyncCron()   // 初始化同步目录的定时任务
-	synccron.InitScrapeCron() // 初始化刮削目录的自定义定时任务
-	synccron.InitTokenCron()  // 初始化定时刷新115的访问凭证
+	synccron.InitCron()        // 初始化定时任务（包含备份定时任务）
+	synccron.InitSyncCron()    // 初始化同步目录的定时任务
+	synccron.InitSyncWatch()   // 初始化同步目录的实时监控
+	synccron.InitScrapeCron()  // 初始化刮削目录的自定义定时任务
+	synccron.InitRefreshCron() // 初始化刮削目录的元数据刷新定时任务
+	synccron.InitTokenCron()   // 初始化定时刷新115的访问凭证
 	// 初始化备份服务
 	models.InitBackupService()
 	// 将所有刮削中和整理中的记录改为未执行
@@ -530,11 +544,18 @@ func setRouter(r *gin.Engine) {
 	r.GET("/115/url/*filename", controllers.Get115UrlByPickCode)           // 查询115直链 by pickcode 支持iso，路径最后一部分是.扩展名格式
 	r.GET("/115/newurl", controllers.Get115UrlByPickCode)                  // 查询115直链 by pickcode
 	r.GET("/baidupan/url/*filename", controllers.GetBaiduPanUrlByPickCode) // 查询百度网盘直链 by fsid 支持iso，路径最后一部分是.扩展名格式
+	r.GET("/123/url/*filename", controllers.Get123UrlByPickCode)           // 查询123云盘直链 by fileId 支持iso，路径最后一部分是.扩展名格式
 
 	r.GET("/openlist/url", controllers.GetOpenListFileUrl) // 查询OpenList直链
 
 	r.GET("/proxy-115", controllers.Proxy115) // 115CDN反代路由
 
+	// 只读WebDAV，浏览所有同步路径
+	for _, method := range controllers.WebDavMethods {
+		r.Handle(method, controllers.WebDavPrefix, controllers.WebDav)
+		r.Handle(method, controllers.WebDavPrefix+"/*path", controllers.WebDav)
+	}
+
 	r.GET("/api/scrape/tmp-image", controllers.ScrapeTmpImage)           // 获取临时图片
 	r.GET("/api/scrape/records/export", controllers.ExportScrapeRecords) // 导出刮削记录
 	r.GET("/api/logs/ws", controllers.LogWebSocket)                      // WebSocket日志查看
@@ -547,6 +568,20 @@ func setRouter(r *gin.Engine) {
 
 	api := r.Group("/api")
 	api.Use(controllers.JWTAuthMiddleware())
+	// 按权限范围分组，没有分组的接口所有登录用户都可以访问
+	adminApi := api.Group("", controllers.RequireScope(models.ScopeAdmin))
+	settingsReadApi := api.Group("", controllers.RequireScope(models.ScopeSettingsRead))
+	settingsWriteApi := api.Group("", controllers.RequireScope(models.ScopeSettingsWrite))
+	accountReadApi := api.Group("", controllers.RequireScope(models.ScopeAccountRead))
+	accountWriteApi := api.Group("", controllers.RequireScope(models.ScopeAccountWrite))
+	syncReadApi := api.Group("", controllers.RequireScope(models.ScopeSyncRead))
+	syncRunApi := api.Group("", controllers.RequireScope(models.ScopeSyncRun))
+	syncWriteApi := api.Group("", controllers.RequireScope(models.ScopeSyncWrite))
+	scrapeReadApi := api.Group("", controllers.RequireScope(models.ScopeScrapeRead))
+	scrapeRunApi := api.Group("", controllers.RequireScope(models.ScopeScrapeRun))
+	scrapeWriteApi := api.Group("", controllers.RequireScope(models.ScopeScrapeWrite))
+	// API Key只能登录后管理，避免权限范围较小的API Key启用或删除同一个用户的其他API Key
+	loginApi := api.Group("", controllers.RequireLogin())
 	{
 		api.GET("/version", func(c *gin.Context) {
 			c.JSON(http.StatusOK, map[string]interface{}{
@@ -556,166 +591,198 @@ func setRouter(r *gin.Engine) {
 				"isRelease": helpers.IsRelease,
 			})
 		})
-		api.POST("/database/delete-all-table", controllers.DeleteAllTabble) // 删除所有表
-		api.GET("/announce", controllers.GetAnnounce)                       // 获取公告
-		api.POST("/database/repair", controllers.RepairDB)                  // 更新系统设置
-		api.POST("/auth/115-qrcode-open", controllers.GetLoginQrCodeOpen)   // 获取115开放平台登录二维码
-		api.POST("/auth/115-qrcode-status", controllers.GetQrCodeStatus)    // 查询115二维码扫码状态
-		api.GET("/115/status", controllers.Get115Status)                    // 查询115状态
-		api.GET("/115/oauth-url", controllers.GetOAuthUrl)                  // 获取115 OAuth登录地址
-		api.POST("115/oauth-confirm", controllers.ConfirmOAuthCode)         // 确认OAuth登录
-		api.GET("/115/queue/stats", controllers.GetQueueStats)              // 获取115 OpenAPI请求队列统计数据
-		api.POST("/115/queue/rate-limit", controllers.SetQueueRateLimit)    // 设置115 OpenAPI请求队列速率限制
-		api.GET("/115/stats/daily", controllers.GetRequestStatsByDay)       // 获取115请求统计（按天）
-		api.GET("/115/stats/hourly", controllers.GetRequestStatsByHour)     // 获取115请求统计（按小时）
-		api.POST("/115/stats/clean", controllers.CleanOldRequestStats)      // 清理旧的请求统计数据
+		adminApi.POST("/database/delete-all-table", controllers.DeleteAllTabble)      // 删除所有表
+		api.GET("/announce", controllers.GetAnnounce)                                 // 获取公告
+		adminApi.POST("/database/repair", controllers.RepairDB)                       // 更新系统设置
+		accountWriteApi.POST("/auth/115-qrcode-open", controllers.GetLoginQrCodeOpen) // 获取115开放平台登录二维码
+		accountWriteApi.POST("/auth/115-qrcode-status", controllers.GetQrCodeStatus)  // 查询115二维码扫码状态
+		accountReadApi.GET("/115/status", controllers.Get115Status)                   // 查询115状态
+		accountWriteApi.GET("/115/oauth-url", controllers.GetOAuthUrl)                // 获取115 OAuth登录地址
+		accountWriteApi.POST("115/oauth-confirm", controllers.ConfirmOAuthCode)       // 确认OAuth登录
+		accountReadApi.GET("/115/queue/stats", controllers.GetQueueStats)             // 获取115 OpenAPI请求队列统计数据
+		adminApi.POST("/115/queue/rate-limit", controllers.SetQueueRateLimit)         // 设置115 OpenAPI请求队列速率限制
+		accountReadApi.GET("/115/stats/daily", controllers.GetRequestStatsByDay)      // 获取115请求统计（按天）
+		accountReadApi.GET("/115/stats/hourly", controllers.GetRequestStatsByHour)    // 获取115请求统计（按小时）
+		adminApi.POST("/115/stats/clean", controllers.CleanOldRequestStats)           // 清理旧的请求统计数据
 		// 百度网盘相关路由
-		api.GET("/baidupan/oauth-url", controllers.GetBaiDuPanOAuthUrl)           // 获取百度网盘OAuth登录地址
-		api.POST("/baidupan/oauth-confirm", controllers.ConfirmBaiDuPanOAuthCode) // 确认百度网盘OAuth登录
-		api.GET("/baidupan/status", controllers.GetBaiDuPanStatus)                // 查询百度网盘状态
+		accountWriteApi.GET("/baidupan/oauth-url", controllers.GetBaiDuPanOAuthUrl)           // 获取百度网盘OAuth登录地址
+		accountWriteApi.POST("/baidupan/oauth-confirm", controllers.ConfirmBaiDuPanOAuthCode) // 确认百度网盘OAuth登录
+		accountReadApi.GET("/baidupan/status", controllers.GetBaiDuPanStatus)                 // 查询百度网盘状态
+		accountReadApi.GET("/123/status", controllers.GetOpen123Status)                       // 查询123云盘状态
 
-		api.GET("/update/last", controllers.GetLastRelease)         // 获取最新版本
-		api.POST("/update/to-version", controllers.UpdateToVersion) // 获取更新版本
-		api.GET("/update/progress", controllers.UpdateProgress)     // 获取更新进度
-		api.POST("/update/cancel", controllers.CancelUpdate)        // 取消更新
+		adminApi.GET("/update/last", controllers.GetLastRelease)         // 获取最新版本
+		adminApi.POST("/update/to-version", controllers.UpdateToVersion) // 获取更新版本
+		adminApi.GET("/update/progress", controllers.UpdateProgress)     // 获取更新进度
+		adminApi.POST("/update/cancel", controllers.CancelUpdate)        // 取消更新
 
 		api.GET("/user/info", controllers.GetUserInfo)
-		api.GET("/path/list", controllers.GetPathList)     // 目录列表
-		api.POST("/path/create", controllers.CreateDir)    // 创建目录接口
-		api.DELETE("/path", controllers.DeleteDir)         // 删除目录接口
-		api.GET("/path/files", controllers.GetNetFileList) // 查询网盘文件列表
+		accountReadApi.GET("/path/list", controllers.GetPathList)     // 目录列表
+		accountWriteApi.POST("/path/create", controllers.CreateDir)   // 创建目录接口
+		accountWriteApi.DELETE("/path", controllers.DeleteDir)        // 删除目录接口
+		accountReadApi.GET("/path/files", controllers.GetNetFileList) // 查询网盘文件列表
 		api.POST("/user/change", controllers.ChangePassword)
-
-		api.POST("/setting/http-proxy", controllers.UpdateHttpProxy)    // 更改HTTP代理
-		api.GET("/setting/http-proxy", controllers.GetHttpProxy)        // 获取HTTP代理
-		api.POST("/setting/test-http-proxy", controllers.TestHttpProxy) // 测试HTTP代理
+		adminApi.GET("/users", controllers.ListUsers)                        // 获取用户列表
+		adminApi.POST("/users", controllers.CreateUser)                      // 创建用户
+		adminApi.PUT("/users/:id", controllers.UpdateUser)                   // 修改用户角色或密码
+		adminApi.DELETE("/users/:id", controllers.DeleteUser)                // 删除用户
+		adminApi.GET("/users/:id/resources", controllers.GetUserResources)   // 获取用户被授权的资源
+		adminApi.POST("/users/:id/resources", controllers.SaveUserResources) // 保存用户被授权的资源
+
+		settingsWriteApi.POST("/setting/http-proxy", controllers.UpdateHttpProxy)    // 更改HTTP代理
+		settingsReadApi.GET("/setting/http-proxy", controllers.GetHttpProxy)         // 获取HTTP代理
+		settingsWriteApi.POST("/setting/test-http-proxy", controllers.TestHttpProxy) // 测试HTTP代理
 		// api.GET("/setting/telegram", controllers.GetTelegram)                                      // 获取telegram消息通知配置
 		// api.POST("/setting/telegram", controllers.UpdateTelegram)                                  // 更改telegram消息通知配置
 		// api.POST("/telegram/test", controllers.TestTelegram)                                       // 测试telegram连通性
-		api.GET("/setting/notification/channels", controllers.GetNotificationChannels)             // 获取所有通知渠道
-		api.POST("/setting/notification/channels/telegram", controllers.CreateTelegramChannel)     // 创建Telegram渠道
-		api.GET("/setting/notification/channels/telegram/:id", controllers.GetTelegramChannel)     // 查询Telegram渠道
-		api.PUT("/setting/notification/channels/telegram", controllers.UpdateTelegramChannel)      // 更新Telegram渠道
-		api.POST("/setting/notification/channels/meow", controllers.CreateMeoWChannel)             // 创建MeoW渠道
-		api.GET("/setting/notification/channels/meow/:id", controllers.GetMeoWChannel)             // 查询MeoW渠道
-		api.PUT("/setting/notification/channels/meow", controllers.UpdateMeoWChannel)              // 更新MeoW渠道
-		api.POST("/setting/notification/channels/bark", controllers.CreateBarkChannel)             // 创建Bark渠道
-		api.GET("/setting/notification/channels/bark/:id", controllers.GetBarkChannel)             // 查询Bark渠道
-		api.PUT("/setting/notification/channels/bark", controllers.UpdateBarkChannel)              // 更新Bark渠道
-		api.POST("/setting/notification/channels/serverchan", controllers.CreateServerChanChannel) // 创建Server酱渠道
-		api.GET("/setting/notification/channels/serverchan/:id", controllers.GetServerChanChannel) // 查询Server酱渠道
-		api.PUT("/setting/notification/channels/serverchan", controllers.UpdateServerChanChannel)  // 更新Server酱渠道
-		api.POST("/setting/notification/channels/webhook", controllers.CreateCustomWebhookChannel) // 创建自定义Webhook渠道
-		api.GET("/setting/notification/channels/webhook/:id", controllers.GetCustomWebhookChannel) // 查询自定义Webhook渠道
-		api.PUT("/setting/notification/channels/webhook", controllers.UpdateCustomWebhookChannel)  // 更新自定义Webhook渠道
-		api.POST("/setting/notification/channels/status", controllers.UpdateChannelStatus)         // 启用/禁用渠道
-		api.DELETE("/setting/notification/channels/:id", controllers.DeleteChannel)                // 删除渠道
-		api.GET("/setting/notification/rules", controllers.GetNotificationRules)                   // 获取通知规则
-		api.PUT("/setting/notification/rules", controllers.UpdateNotificationRule)                 // 更新通知规则
-		api.POST("/setting/notification/channels/test", controllers.TestChannelConnection)         // 测试通知渠道连接
-		api.GET("/setting/strm-config", controllers.GetStrmConfig)                                 // 获取STRM配置
-		api.POST("/setting/strm-config", controllers.UpdateStrmConfig)                             // 更新STRM配置
-		api.GET("/setting/cron", controllers.GetCronNextTime)                                      // 获取Cron表达式的下5次执行时间
-		api.POST("/cron/validate", controllers.ValidateCron)                                       // 验证Cron表达式并返回描述
-		api.POST("/setting/emby/parse", controllers.ParseEmby)                                     // 解析Emby媒体信息
-		api.GET("/setting/emby-config", controllers.GetEmbyConfig)                                 // 获取新的Emby配置
-		api.POST("/setting/emby-config", controllers.UpdateEmbyConfig)                             // 更新新的Emby配置
-		api.POST("/setting/threads", controllers.UpdateThreads)                                    // 更新线程数
-		api.GET("/setting/threads", controllers.GetThreads)                                        // 获取线程数
-
-		api.POST("/emby/sync/start", controllers.StartEmbySync)     // 手动启动Emby同步
-		api.GET("/emby/sync/status", controllers.GetEmbySyncStatus) // 获取Emby同步状态
-		api.GET("/emby/libraries", controllers.GetEmbyLibraries)    // 获取Emby媒体库列表
+		settingsReadApi.GET("/setting/notification/channels", controllers.GetNotificationChannels)              // 获取所有通知渠道
+		settingsWriteApi.POST("/setting/notification/channels/telegram", controllers.CreateTelegramChannel)     // 创建Telegram渠道
+		settingsReadApi.GET("/setting/notification/channels/telegram/:id", controllers.GetTelegramChannel)      // 查询Telegram渠道
+		settingsWriteApi.PUT("/setting/notification/channels/telegram", controllers.UpdateTelegramChannel)      // 更新Telegram渠道
+		settingsWriteApi.POST("/setting/notification/channels/meow", controllers.CreateMeoWChannel)             // 创建MeoW渠道
+		settingsReadApi.GET("/setting/notification/channels/meow/:id", controllers.GetMeoWChannel)              // 查询MeoW渠道
+		settingsWriteApi.PUT("/setting/notification/channels/meow", controllers.UpdateMeoWChannel)              // 更新MeoW渠道
+		settingsWriteApi.POST("/setting/notification/channels/bark", controllers.CreateBarkChannel)             // 创建Bark渠道
+		settingsReadApi.GET("/setting/notification/channels/bark/:id", controllers.GetBarkChannel)              // 查询Bark渠道
+		settingsWriteApi.PUT("/setting/notification/channels/bark", controllers.UpdateBarkChannel)              // 更新Bark渠道
+		settingsWriteApi.POST("/setting/notification/channels/serverchan", controllers.CreateServerChanChannel) // 创建Server酱渠道
+		settingsReadApi.GET("/setting/notification/channels/serverchan/:id", controllers.GetServerChanChannel)  // 查询Server酱渠道
+		settingsWriteApi.PUT("/setting/notification/channels/serverchan", controllers.UpdateServerChanChannel)  // 更新Server酱渠道
+		settingsWriteApi.POST("/setting/notification/channels/webhook", controllers.CreateCustomWebhookChannel) // 创建自定义Webhook渠道
+		settingsReadApi.GET("/setting/notification/channels/webhook/:id", controllers.GetCustomWebhookChannel)  // 查询自定义Webhook渠道
+		settingsWriteApi.PUT("/setting/notification/channels/webhook", controllers.UpdateCustomWebhookChannel)  // 更新自定义Webhook渠道
+		settingsWriteApi.POST("/setting/notification/channels/status", controllers.UpdateChannelStatus)         // 启用/禁用渠道
+		settingsWriteApi.DELETE("/setting/notification/channels/:id", controllers.DeleteChannel)                // 删除渠道
+		settingsReadApi.GET("/setting/notification/rules", controllers.GetNotificationRules)                    // 获取通知规则
+		settingsWriteApi.PUT("/setting/notification/rules", controllers.UpdateNotificationRule)                 // 更新通知规则
+		settingsWriteApi.POST("/setting/notification/channels/test", controllers.TestChannelConnection)         // 测试通知渠道连接
+		settingsReadApi.GET("/setting/strm-config", controllers.GetStrmConfig)                                  // 获取STRM配置
+		settingsWriteApi.POST("/setting/strm-config", controllers.UpdateStrmConfig)                             // 更新STRM配置
+		settingsWriteApi.GET("/setting/strm-sign", controllers.GetStrmSignSetting)                              // 获取STRM链接签名设置
+		settingsWriteApi.POST("/setting/strm-sign", controllers.UpdateStrmSignSetting)                          // 更新STRM链接签名模式
+		settingsWriteApi.POST("/setting/strm-sign/rotate", controllers.RotateStrmSignKey)                       // 轮换STRM签名密钥
+		settingsWriteApi.DELETE("/setting/strm-sign/key/:id", controllers.DeleteStrmSignKey)                    // 删除已轮换的STRM签名密钥
+		settingsWriteApi.POST("/setting/strm-sign/migrate", controllers.MigrateStrmSign)                        // 使用新密钥重新签名已有的STRM文件
+		api.GET("/setting/cron", controllers.GetCronNextTime)                                                   // 获取Cron表达式的下5次执行时间
+		api.POST("/cron/validate", controllers.ValidateCron)                                                    // 验证Cron表达式并返回描述
+		settingsWriteApi.POST("/setting/emby/parse", controllers.ParseEmby)                                     // 解析Emby媒体信息
+		settingsWriteApi.GET("/setting/emby-config", controllers.GetEmbyConfig)                                 // 获取新的Emby配置
+		settingsWriteApi.POST("/setting/emby-config", controllers.UpdateEmbyConfig)                             // 更新新的Emby配置
+		settingsWriteApi.POST("/setting/threads", controllers.UpdateThreads)                                    // 更新线程数
+		settingsReadApi.GET("/setting/threads", controllers.GetThreads)                                         // 获取线程数
+
+		syncRunApi.POST("/emby/sync/start", controllers.StartEmbySync)      // 手动启动Emby同步
+		syncReadApi.GET("/emby/sync/status", controllers.GetEmbySyncStatus) // 获取Emby同步状态
+		syncReadApi.GET("/emby/libraries", controllers.GetEmbyLibraries)    // 获取Emby媒体库列表
 		// 删除媒体库与同步目录关联
 
-		api.POST("/sync/start", controllers.StartSync)                       // 启动同步
-		api.GET("/sync/records", controllers.GetSyncRecords)                 // 同步列表
-		api.GET("/sync/task", controllers.GetSyncTask)                       // 获取同步任务详情
-		api.GET("/sync/path-list", controllers.GetSyncPathList)              // 获取同步路径列表
-		api.POST("/sync/path-add", controllers.AddSyncPath)                  // 创建同步路径
-		api.POST("/sync/path-update", controllers.UpdateSyncPath)            // 更新同步路径
-		api.POST("/sync/path-delete", controllers.DeleteSyncPath)            // 删除同步路径
-		api.POST("/sync/path/stop", controllers.StopSyncByPath)              // 停止同步路径的同步任务
-		api.POST("/sync/path/start", controllers.StartSyncByPath)            // 启动同步路径的同步任务
-		api.POST("/sync/path/full-start", controllers.FullStart115Sync)      // 启动115的全量同步任务
-		api.POST("/sync/delete-records", controllers.DelSyncRecords)         // 批量删除同步记录
-		api.POST("/sync/path/toggle-cron", controllers.ToggleSyncByPath)     // 关闭或开启同步目录的定时同步
-		api.GET("/sync/path/:id", controllers.GetSyncPathById)               // 获取同步路径详情
-		api.GET("/sync/path/:id/scrape-paths", controllers.GetRelScrapePath) // 获取同步路径关联的刮削路径
-		api.POST("/sync/path/scrape-paths", controllers.SaveRelScrapePath)   // 更新同步路径关联的刮削路径
-		api.POST("/sync/manual", controllers.ManualSync)                     // 手动同步
-
-		api.GET("/account/list", controllers.GetAccountList)             // 获取开放平台账号列表
-		api.POST("/account/add", controllers.CreateTmpAccount)           // 创建开放平台账号
-		api.POST("/account/delete", controllers.DeleteAccount)           // 删除开放平台账号
-		api.POST("/account/openlist", controllers.CreateOpenListAccount) // 创建openlist账号
+		adminApi.POST("/sync/start", controllers.StartSync)                          // 启动同步
+		syncReadApi.GET("/sync/records", controllers.GetSyncRecords)                 // 同步列表
+		syncReadApi.GET("/sync/task", controllers.GetSyncTask)                       // 获取同步任务详情
+		syncReadApi.GET("/sync/path-list", controllers.GetSyncPathList)              // 获取同步路径列表
+		syncWriteApi.POST("/sync/path-add", controllers.AddSyncPath)                 // 创建同步路径
+		syncWriteApi.POST("/sync/path-update", controllers.UpdateSyncPath)           // 更新同步路径
+		syncWriteApi.POST("/sync/path-delete", controllers.DeleteSyncPath)           // 删除同步路径
+		syncRunApi.POST("/sync/path/stop", controllers.StopSyncByPath)               // 停止同步路径的同步任务
+		syncRunApi.POST("/sync/path/start", controllers.StartSyncByPath)             // 启动同步路径的同步任务
+		syncRunApi.POST("/sync/path/full-start", controllers.FullStart115Sync)       // 启动115的全量同步任务
+		syncRunApi.POST("/sync/path/:id/plan", controllers.StartSyncPlan)            // 预演同步路径，生成同步计划
+		syncReadApi.GET("/sync/path/:id/plan", controllers.GetSyncPlan)              // 获取同步路径最近一次的同步计划
+		syncWriteApi.POST("/sync/delete-records", controllers.DelSyncRecords)        // 批量删除同步记录
+		syncWriteApi.POST("/sync/path/toggle-cron", controllers.ToggleSyncByPath)    // 关闭或开启同步目录的定时同步
+		syncWriteApi.POST("/sync/path/toggle-watch", controllers.ToggleWatchByPath)  // 关闭或开启同步目录的实时监控同步
+		syncReadApi.GET("/sync/path/:id", controllers.GetSyncPathById)               // 获取同步路径详情
+		syncReadApi.GET("/sync/path/:id/scrape-paths", controllers.GetRelScrapePath) // 获取同步路径关联的刮削路径
+		syncWriteApi.POST("/sync/path/scrape-paths", controllers.SaveRelScrapePath)  // 更新同步路径关联的刮削路径
+		syncRunApi.POST("/sync/manual", controllers.ManualSync)                      // 手动同步
+
+		accountReadApi.GET("/account/list", controllers.GetAccountList)                // 获取开放平台账号列表
+		accountWriteApi.POST("/account/add", controllers.CreateTmpAccount)             // 创建开放平台账号
+		accountWriteApi.POST("/account/delete", controllers.DeleteAccount)             // 删除开放平台账号
+		accountWriteApi.POST("/account/openlist", controllers.CreateOpenListAccount)   // 创建openlist账号
+		accountWriteApi.POST("/account/123", controllers.SaveOpen123Account)           // 创建或更新123云盘账号
+		accountWriteApi.POST("/account/max-streams", controllers.SetAccountMaxStreams) // 设置账号的最大同时播放数
+		accountReadApi.GET("/play/sessions", controllers.GetPlaySessions)              // 查询正在播放的会话
 
 		// API Key管理接口
-		api.POST("/api-keys", controllers.CreateAPIKey)                 // 创建API Key
-		api.GET("/api-keys", controllers.ListAPIKeys)                   // 获取API Key列表
-		api.PUT("/api-keys/:id/status", controllers.UpdateAPIKeyStatus) // 更新API Key状态
-		api.DELETE("/api-keys/:id", controllers.DeleteAPIKey)           // 删除API Key
-
-		api.GET("/scrape/movie-genre", controllers.GetMovieGenre)                     // 获取电影类别
-		api.GET("/scrape/tvshow-genre", controllers.GetTvshowGenre)                   // 获取电视剧类别
-		api.GET("/scrape/language", controllers.GetLanguage)                          // 获取语言数组
-		api.GET("/scrape/countries", controllers.GetCountries)                        // 获取国家数组
-		api.GET("/scrape/tmdb", controllers.GetTmdbSettings)                          // 获取TMDB设置
-		api.POST("/scrape/tmdb", controllers.SaveTmdbSettings)                        // 保存TMDB设置
-		api.POST("/scrape/tmdb-test", controllers.TestTmdbSettings)                   // 测试TMDB设置
-		api.GET("/scrape/ai-settings", controllers.GetAiSettings)                     // 获取AI识别设置
-		api.POST("/scrape/ai-settings", controllers.SaveAiSettings)                   // 保存AI识别设置
-		api.POST("/scrape/ai-test", controllers.TestAiSettings)                       // 测试AI识别设置
-		api.GET("/scrape/movie-categories", controllers.GetMovieCategories)           // 获取电影分类列表
-		api.GET("/scrape/tvshow-categories", controllers.GetTvshowCategories)         // 获取电视剧分类列表
-		api.POST("/scrape/movie-categories", controllers.SaveMovieCategory)           // 保存电影分类
-		api.POST("/scrape/tvshow-categories", controllers.SaveTvshowCategory)         // 保存电视剧分类
-		api.DELETE("/scrape/movie-categories/:id", controllers.DeleteMovieCategory)   // 删除电影分类
-		api.DELETE("/scrape/tvshow-categories/:id", controllers.DeleteTvshowCategory) // 删除电视剧分类
-		api.GET("/scrape/pathes", controllers.GetScrapePathes)                        // 获取刮削路径列表
-		api.POST("/scrape/pathes", controllers.SaveScrapePath)                        // 保存刮削路径列表
-		api.DELETE("/scrape/pathes/:id", controllers.DeleteScrapePath)                // 删除刮削路径
-		api.GET("/scrape/pathes/:id", controllers.GetScrapePath)                      // 获取刮削路径详情
-		api.POST("/scrape/pathes/start", controllers.ScanScrapePath)                  // 扫描刮削路径
-		api.POST("/scrape/pathes/stop", controllers.StopScrape)                       // 停止刮削任务
-		api.POST("/scrape/pathes/toggle-cron", controllers.ToggleScrapePathCron)      // 关闭或开启刮削路径的定时刮削
-		api.GET("/scrape/records", controllers.GetScrapeRecords)                      // 获取刮削记录
-		api.POST("/scrape/re-scrape", controllers.ReScrape)                           // 重新刮削记录
-		api.POST("/scrape/clear-failed", controllers.ClearFailedScrapeRecords)        // 清除所有刮削失败的记录
-		api.POST("/scrape/truncate-all", controllers.TruncateAllScrapeRecords)        // 一键清空所有刮削记录
-		api.DELETE("/scrape/records", controllers.DeleteScrapeMediaFile)              // 删除刮削记录
-		api.POST("/scrape/finish", controllers.FinishScrapeMediaFile)                 // 完成刮削记录
-		api.POST("/scrape/rename-failed", controllers.RenameFailedScrapeMediaFile)    // 标记所有失败的记录为待整理
-		api.POST("/scrape/sync-pathes", controllers.SaveScrapeStrmPath)               // 保存刮削目录关联的同步目录
-		api.GET("/scrape/sync-pathes", controllers.GetScrapeStrmPaths)                // 获取刮削目录关联的同步目录
-		api.GET("/scrape/tmdb-search", controllers.TmdbSearch)                        // 搜索TMDB媒体
-
-		api.GET("/upload/queue", controllers.UploadList)                                             // 获取上传队列列表
-		api.POST("/upload/queue/clear-pending", controllers.ClearPendingUploadTasks)                 // 清除上传队列中未开始的任务
-		api.POST("/upload/queue/start", controllers.StartUploadQueue)                                // 启动上传队列
-		api.POST("/upload/queue/stop", controllers.StopUploadQueue)                                  // 停止上传队列
-		api.GET("/upload/queue/status", controllers.UploadQueueStatus)                               // 查询上传队列状态
-		api.POST("/upload/queue/clear-success-failed", controllers.ClearUploadSuccessAndFailedTasks) // 清除上传队列中已完成和失败的任务
-		api.POST("/upload/queue/retry-failed", controllers.RetryFailedUploadTasks)                   // 重试所有失败的上传任务
-
-		api.GET("/download/queue", controllers.DownloadList)                                             // 获取下载队列列表
-		api.POST("/download/queue/clear-pending", controllers.ClearPendingDownloadTasks)                 // 清除下载队列中未开始的任务
-		api.POST("/download/queue/start", controllers.StartDownloadQueue)                                // 启动下载队列
-		api.POST("/download/queue/stop", controllers.StopDownloadQueue)                                  // 停止下载队列
-		api.GET("/download/queue/status", controllers.DownloadQueueStatus)                               // 查询下载队列状态
-		api.POST("/download/queue/clear-success-failed", controllers.ClearDownloadSuccessAndFailedTasks) // 清除下载队列中已完成和失败的任务
+		loginApi.POST("/api-keys", controllers.CreateAPIKey)                 // 创建API Key
+		loginApi.GET("/api-keys", controllers.ListAPIKeys)                   // 获取API Key列表
+		loginApi.PUT("/api-keys/:id/status", controllers.UpdateAPIKeyStatus) // 更新API Key状态
+		loginApi.DELETE("/api-keys/:id", controllers.DeleteAPIKey)           // 删除API Key
+
+		scrapeReadApi.GET("/scrape/movie-genre", controllers.GetMovieGenre)                        // 获取电影类别
+		scrapeReadApi.GET("/scrape/tvshow-genre", controllers.GetTvshowGenre)                      // 获取电视剧类别
+		scrapeReadApi.GET("/scrape/language", controllers.GetLanguage)                             // 获取语言数组
+		scrapeReadApi.GET("/scrape/countries", controllers.GetCountries)                           // 获取国家数组
+		settingsWriteApi.GET("/scrape/tmdb", controllers.GetTmdbSettings)                          // 获取TMDB设置
+		settingsWriteApi.POST("/scrape/tmdb", controllers.SaveTmdbSettings)                        // 保存TMDB设置
+		settingsWriteApi.POST("/scrape/tmdb-test", controllers.TestTmdbSettings)                   // 测试TMDB设置
+		settingsWriteApi.GET("/scrape/providers", controllers.GetMetadataProviders)                // 获取元数据提供者设置
+		settingsWriteApi.POST("/scrape/providers", controllers.SaveMetadataProviders)              // 保存元数据提供者设置
+		settingsWriteApi.GET("/scrape/ai-settings", controllers.GetAiSettings)                     // 获取AI识别设置
+		settingsWriteApi.POST("/scrape/ai-settings", controllers.SaveAiSettings)                   // 保存AI识别设置
+		settingsWriteApi.POST("/scrape/ai-test", controllers.TestAiSettings)                       // 测试AI识别设置
+		settingsReadApi.GET("/scrape/movie-categories", controllers.GetMovieCategories)            // 获取电影分类列表
+		settingsReadApi.GET("/scrape/tvshow-categories", controllers.GetTvshowCategories)          // 获取电视剧分类列表
+		settingsWriteApi.POST("/scrape/movie-categories", controllers.SaveMovieCategory)           // 保存电影分类
+		settingsWriteApi.POST("/scrape/tvshow-categories", controllers.SaveTvshowCategory)         // 保存电视剧分类
+		settingsWriteApi.DELETE("/scrape/movie-categories/:id", controllers.DeleteMovieCategory)   // 删除电影分类
+		settingsWriteApi.DELETE("/scrape/tvshow-categories/:id", controllers.DeleteTvshowCategory) // 删除电视剧分类
+		scrapeReadApi.GET("/scrape/pathes", controllers.GetScrapePathes)                           // 获取刮削路径列表
+		scrapeWriteApi.POST("/scrape/pathes", controllers.SaveScrapePath)                          // 保存刮削路径列表
+		scrapeWriteApi.DELETE("/scrape/pathes/:id", controllers.DeleteScrapePath)                  // 删除刮削路径
+		scrapeReadApi.GET("/scrape/pathes/:id", controllers.GetScrapePath)                         // 获取刮削路径详情
+		scrapeRunApi.POST("/scrape/pathes/start", controllers.ScanScrapePath)                      // 扫描刮削路径
+		scrapeRunApi.POST("/scrape/pathes/stop", controllers.StopScrape)                           // 停止刮削任务
+		scrapeWriteApi.POST("/scrape/pathes/toggle-cron", controllers.ToggleScrapePathCron)        // 关闭或开启刮削路径的定时刮削
+		scrapeReadApi.GET("/scrape/records", controllers.GetScrapeRecords)                         // 获取刮削记录
+		scrapeRunApi.POST("/scrape/re-scrape", controllers.ReScrape)                               // 重新刮削记录
+		scrapeReadApi.GET("/scrape/reviews", controllers.GetScrapeReviews)                         // 获取待确认队列
+		scrapeRunApi.POST("/scrape/reviews/accept", controllers.AcceptScrapeReview)                // 确认识别结果
+		scrapeReadApi.GET("/scrape/collections", controllers.GetMovieCollections)                  // 获取电影合集列表
+		scrapeReadApi.GET("/scrape/tvshows/missing", controllers.GetTvshowMissingSummary)          // 获取全库缺集统计
+		scrapeRunApi.POST("/scrape/tvshows/missing/refresh", controllers.RefreshTvshowMissing)     // 检查全库电视剧的缺集
+		scrapeReadApi.GET("/scrape/tvshows/:id/missing", controllers.GetTvshowMissing)             // 获取电视剧的缺集报告
+		scrapeWriteApi.POST("/scrape/clear-failed", controllers.ClearFailedScrapeRecords)          // 清除所有刮削失败的记录
+		adminApi.POST("/scrape/truncate-all", controllers.TruncateAllScrapeRecords)                // 一键清空所有刮削记录
+		scrapeWriteApi.DELETE("/scrape/records", controllers.DeleteScrapeMediaFile)                // 删除刮削记录
+		scrapeRunApi.POST("/scrape/finish", controllers.FinishScrapeMediaFile)                     // 完成刮削记录
+		scrapeRunApi.POST("/scrape/rename-failed", controllers.RenameFailedScrapeMediaFile)        // 标记所有失败的记录为待整理
+		scrapeWriteApi.POST("/scrape/sync-pathes", controllers.SaveScrapeStrmPath)                 // 保存刮削目录关联的同步目录
+		scrapeReadApi.GET("/scrape/sync-pathes", controllers.GetScrapeStrmPaths)                   // 获取刮削目录关联的同步目录
+		scrapeReadApi.GET("/scrape/tmdb-search", controllers.TmdbSearch)                           // 搜索TMDB媒体
+
+		syncReadApi.GET("/upload/queue", controllers.UploadList)                                            // 获取上传队列列表
+		syncRunApi.POST("/upload/queue/clear-pending", controllers.ClearPendingUploadTasks)                 // 清除上传队列中未开始的任务
+		syncRunApi.POST("/upload/queue/start", controllers.StartUploadQueue)                                // 启动上传队列
+		syncRunApi.POST("/upload/queue/stop", controllers.StopUploadQueue)                                  // 停止上传队列
+		syncReadApi.GET("/upload/queue/status", controllers.UploadQueueStatus)                              // 查询上传队列状态
+		syncRunApi.POST("/upload/queue/clear-success-failed", controllers.ClearUploadSuccessAndFailedTasks) // 清除上传队列中已完成和失败的任务
+		syncRunApi.POST("/upload/queue/retry-failed", controllers.RetryFailedUploadTasks)                   // 重试所有失败的上传任务
+
+		syncReadApi.GET("/download/queue", controllers.DownloadList)                                            // 获取下载队列列表
+		syncRunApi.POST("/download/queue/clear-pending", controllers.ClearPendingDownloadTasks)                 // 清除下载队列中未开始的任务
+		syncRunApi.POST("/download/queue/start", controllers.StartDownloadQueue)                                // 启动下载队列
+		syncRunApi.POST("/download/queue/stop", controllers.StopDownloadQueue)                                  // 停止下载队列
+		syncReadApi.GET("/download/queue/status", controllers.DownloadQueueStatus)                              // 查询下载队列状态
+		syncRunApi.POST("/download/queue/clear-success-failed", controllers.ClearDownloadSuccessAndFailedTasks) // 清除下载队列中已完成和失败的任务
 
 		// 备份与恢复相关路由
-		api.GET("/backup/list", controllers.GetBackupList)               // 获取备份列表
-		api.GET("/backup/records/:id", controllers.GetBackupRecord)      // 获取备份记录详情
-		api.POST("/backup/create", controllers.CreateBackup)             // 创建手动备份
-		api.DELETE("/backup/records/:id", controllers.DeleteBackup)      // 删除备份记录
-		api.POST("/backup/restore", controllers.RestoreFromBackup)       // 从备份恢复
-		api.POST("/backup/upload-restore", controllers.UploadAndRestore) // 上传文件并恢复
-		api.GET("/backup/download/:id", controllers.DownloadBackup)      // 下载备份文件
-		api.GET("/backup/config", controllers.GetBackupConfig)           // 获取备份配置
-		api.PUT("/backup/config", controllers.UpdateBackupConfig)        // 更新备份配置
-		api.GET("/backup/status", controllers.GetBackupStatus)           // 获取备份状态
+		adminApi.GET("/backup/list", controllers.GetBackupList)                               // 获取备份列表
+		adminApi.GET("/backup/records/:id", controllers.GetBackupRecord)                      // 获取备份记录详情
+		adminApi.POST("/backup/create", controllers.CreateBackup)                             // 创建手动备份
+		adminApi.DELETE("/backup/records/:id", controllers.DeleteBackup)                      // 删除备份记录
+		adminApi.POST("/backup/restore", controllers.RestoreFromBackup)                       // 从备份恢复
+		adminApi.POST("/backup/upload-restore", controllers.UploadAndRestore)                 // 上传文件并恢复
+		adminApi.GET("/backup/download/:id", controllers.DownloadBackup)                      // 下载备份文件
+		adminApi.GET("/backup/config", controllers.GetBackupConfig)                           // 获取备份配置
+		adminApi.PUT("/backup/config", controllers.UpdateBackupConfig)                        // 更新备份配置
+		adminApi.GET("/backup/status", controllers.GetBackupStatus)                           // 获取备份状态
+		adminApi.GET("/backup/records/:id/uploads", controllers.GetBackupRecordUploads)       // 获取备份上传到远程目标的结果
+		adminApi.GET("/backup/destinations", controllers.GetBackupDestinations)               // 获取备份目标列表
+		adminApi.POST("/backup/destinations", controllers.SaveBackupDestination)              // 添加或修改备份目标
+		adminApi.DELETE("/backup/destinations/:id", controllers.DeleteBackupDestination)      // 删除备份目标
+		adminApi.POST("/backup/destinations/test", controllers.TestBackupDestination)         // 测试备份目标连接
+		adminApi.GET("/backup/destinations/:id/files", controllers.GetBackupDestinationFiles) // 列出备份目标中的备份文件
 
 	}
 }